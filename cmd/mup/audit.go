@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zph/mup/pkg/apply"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/trail"
+)
+
+// auditTrailAnnotation marks the commands recorded in the audit trail
+const auditTrailAnnotation = "mup/audit-trail"
+
+var (
+	// auditPlanID is the plan the running command applies, recorded in
+	// its audit record
+	auditPlanID string
+
+	// Audit list command flags
+	auditListCluster string
+	auditListSince   string
+	auditListFormat  string
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit trail of mup commands",
+	Long: `Every mup command that changes a cluster appends a record to the audit
+trail at ~/.mup/audit.log: who ran it (user@host:pid), the command line, the
+cluster, the plan it applied, when it started and ended, and its outcome.
+
+Set MUP_AUDIT_LOG to write the trail elsewhere, or to "off" to turn it off.
+Set MUP_AUDIT_CLUSTER_LOG=true to also append each record to audit.log in
+the cluster's directory.`,
+}
+
+var auditListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List audit trail records",
+	Example: `  mup audit list
+  mup audit list --cluster prod --since 7d
+  mup audit list --since 2026-10-01 --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if auditListFormat != "text" && auditListFormat != "json" {
+			return fmt.Errorf("unknown format %q (expected text or json)", auditListFormat)
+		}
+		since, err := trail.ParseSince(auditListSince, time.Now())
+		if err != nil {
+			return err
+		}
+		path, err := trail.DefaultPath()
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("the audit trail is turned off (%s=off)", trail.PathEnv)
+		}
+
+		records, err := trail.Read(path, trail.Filter{Cluster: auditListCluster, Since: since})
+		if err != nil {
+			return err
+		}
+
+		if auditListFormat == "json" {
+			if records == nil {
+				records = []trail.Record{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(records)
+		}
+
+		if len(records) == 0 {
+			fmt.Println("No audit records found")
+			return nil
+		}
+		fmt.Printf("%-20s  %-9s  %-8s  %-16s  %-30s  %s\n", "STARTED", "DURATION", "OUTCOME", "CLUSTER", "IDENTITY", "COMMAND")
+		for _, rec := range records {
+			fmt.Printf("%-20s  %-9s  %-8s  %-16s  %-30s  mup %s\n",
+				rec.Start.Local().Format("2006-01-02 15:04:05"),
+				rec.Duration().Round(time.Second),
+				rec.Outcome,
+				rec.Cluster,
+				rec.Identity,
+				strings.Join(rec.Args, " "))
+			if rec.PlanID != "" {
+				fmt.Printf("%-20s  plan: %s\n", "", rec.PlanID)
+			}
+			if rec.Error != "" {
+				fmt.Printf("%-20s  error: %s\n", "", rec.Error)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditListCmd)
+
+	// Audit list command flags
+	auditListCmd.Flags().StringVar(&auditListCluster, "cluster", "", "Only show records of this cluster")
+	auditListCmd.Flags().StringVar(&auditListSince, "since", "", "Only show records since a duration ago (7d, 12h) or a date (2006-01-02)")
+	auditListCmd.Flags().StringVar(&auditListFormat, "format", "text", "Output format: text, json")
+
+	// Commands that change a cluster are recorded in the audit trail
+	for _, cmd := range []*cobra.Command{
+		clusterDeployCmd,
+		clusterStartCmd,
+		clusterStopCmd,
+		clusterDestroyCmd,
+		clusterProtectCmd,
+		clusterUnprotectCmd,
+		clusterExecCmd,
+		clusterRotateCertsCmd,
+		clusterEnableAuthCmd,
+		clusterCredentialsSetCmd,
+		clusterCredentialsRotateCmd,
+		clusterCredentialsRekeyCmd,
+		clusterUsersApplyCmd,
+		clusterBackupCmd,
+		clusterUpgradeCmd,
+		clusterImportCmd,
+		playgroundStartCmd,
+		playgroundStopCmd,
+		playgroundDestroyCmd,
+	} {
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		cmd.Annotations[auditTrailAnnotation] = "true"
+	}
+}
+
+// recordCommand appends cmd's run to the audit trail when cmd changes a
+// cluster. Failing to record is reported but does not fail the command.
+func recordCommand(cmd *cobra.Command, start time.Time, runErr error) {
+	if cmd == nil || cmd.Annotations[auditTrailAnnotation] != "true" {
+		return
+	}
+	path, err := trail.DefaultPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record audit trail: %v\n", err)
+		return
+	}
+	if path == "" {
+		return
+	}
+
+	rec := &trail.Record{
+		Identity: apply.Identity(),
+		Command:  cmd.CommandPath(),
+		Args:     os.Args[1:],
+		Cluster:  commandCluster(cmd),
+		PlanID:   auditPlanID,
+		Start:    start.UTC(),
+		End:      time.Now().UTC(),
+		Outcome:  trail.OutcomeSuccess,
+	}
+	if runErr != nil {
+		rec.Outcome = trail.OutcomeFailure
+		rec.Error = runErr.Error()
+	}
+
+	if err := trail.Append(path, rec); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record audit trail: %v\n", err)
+	}
+	if rec.Cluster == "" || !trail.ClusterLogEnabled() {
+		return
+	}
+	metaMgr, err := meta.NewManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record cluster audit trail: %v\n", err)
+		return
+	}
+	// A destroyed cluster has no directory left to record in
+	clusterDir := metaMgr.GetClusterDir(rec.Cluster)
+	if _, err := os.Stat(clusterDir); err != nil {
+		return
+	}
+	if err := trail.Append(filepath.Join(clusterDir, trail.FileName), rec); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record cluster audit trail: %v\n", err)
+	}
+}
+
+// commandCluster returns the cluster cmd acts on: the playground, or the
+// first argument of cluster commands
+func commandCluster(cmd *cobra.Command) string {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c {
+		case playgroundCmd:
+			return playgroundClusterName
+		case clusterCmd:
+			if args := cmd.Flags().Args(); len(args) > 0 {
+				return args[0]
+			}
+			return ""
+		}
+	}
+	return ""
+}
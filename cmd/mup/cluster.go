@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -15,10 +20,12 @@ import (
 	"github.com/zph/mup/pkg/deploy"
 	"github.com/zph/mup/pkg/executor"
 	importer "github.com/zph/mup/pkg/import"
+	"github.com/zph/mup/pkg/lint"
 	"github.com/zph/mup/pkg/meta"
 	"github.com/zph/mup/pkg/mongo"
 	"github.com/zph/mup/pkg/operation"
 	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
 	"github.com/zph/mup/pkg/simulation" // REQ-SIM-016: Simulation executor
 	"github.com/zph/mup/pkg/topology"
 	"github.com/zph/mup/pkg/upgrade"
@@ -38,10 +45,12 @@ var (
 	clusterDeploySimulate         bool   // REQ-SIM-001: Simulation mode flag
 	clusterDeploySimulateScenario string // REQ-SIM-041: Scenario file path
 	clusterDeploySimulateVerbose  bool   // REQ-SIM-049: Verbose simulation output
+	clusterDeployRecord           string // Record executor calls to a cassette file for replay in tests
 
 	clusterNodeFilter    string
 	clusterDisplayFormat string
 	clusterKeepData      bool
+	clusterListPorts     bool
 
 	// Upgrade command flags [UPG-013]
 	clusterUpgradeToVersion         string
@@ -63,6 +72,50 @@ var (
 	clusterImportSkipRestart bool
 	clusterImportKeepSystemd bool
 	clusterImportSSHHost     string
+
+	// Template command flags
+	clusterTemplateOptions topology.TemplateOptions
+	clusterTemplateOutput  string
+
+	// Exec command flags
+	clusterExecCommand string
+	clusterExecRole    string
+	clusterExecHost    string
+	clusterExecTimeout time.Duration
+
+	// Rotate-certs command flags
+	clusterRotateNewCA   bool
+	clusterRotateTimeout time.Duration
+
+	// Enable-auth command flags
+	clusterEnableAuthUser    string
+	clusterEnableAuthKeyFile string
+	clusterEnableAuthResume  bool
+	clusterEnableAuthTimeout time.Duration
+
+	// Credentials command flags
+	clusterCredentialsUser         string
+	clusterCredentialsPasswordFile string
+	clusterCredentialsNoVerify     bool
+	clusterCredentialsReveal       bool
+	clusterCredentialsKeySource    string
+
+	// Users command flags
+	clusterUsersFile      string
+	clusterUsersAllowDrop bool
+	clusterUsersTimeout   time.Duration
+
+	// Audit command flags
+	clusterAuditFormat  string
+	clusterAuditFailOn  string
+	clusterAuditTimeout time.Duration
+
+	// Backup command flags
+	clusterBackupDir     string
+	clusterBackupTimeout time.Duration
+
+	// Protection flags
+	clusterIKnowThisIsProd string
 )
 
 var clusterCmd = &cobra.Command{
@@ -138,7 +191,8 @@ Examples:
 		clusterName := args[0]
 		topologyFile := args[1]
 
-		ctx, cancel := context.WithTimeout(context.Background(), clusterDeployTimeout)
+		// Ctrl-C and --timeout both cancel in-flight executor commands
+		ctx, cancel := newCommandContext(clusterDeployTimeout)
 		defer cancel()
 
 		// Parse variant
@@ -214,6 +268,21 @@ Examples:
 			}
 		}
 
+		// Optionally capture every executor call for deterministic replay in tests
+		if clusterDeployRecord != "" {
+			cassette := executor.NewCassette()
+			for host, exec := range executors {
+				executors[host] = executor.NewRecordingExecutor(exec, host, cassette)
+			}
+			defer func() {
+				if err := cassette.Save(clusterDeployRecord); err != nil {
+					fmt.Printf("Warning: failed to save cassette: %v\n", err)
+					return
+				}
+				fmt.Printf("📼 Recorded %d executor calls to %s\n", cassette.Len(), clusterDeployRecord)
+			}()
+		}
+
 		// Create deploy planner
 		plannerConfig := &deploy.PlannerConfig{
 			ClusterName: clusterName,
@@ -239,6 +308,11 @@ Examples:
 			return fmt.Errorf("failed to generate plan: %w", err)
 		}
 
+		// Re-deploys must not remove directories of a protected cluster
+		if err := validateClusterPlan(deployPlan); err != nil {
+			return err
+		}
+
 		// Display validation results
 		if !deployPlan.Validation.Valid {
 			fmt.Println(plan.FormatValidationResult(deployPlan.Validation))
@@ -262,6 +336,7 @@ Examples:
 		if err != nil {
 			return fmt.Errorf("failed to save plan: %w", err)
 		}
+		auditPlanID = planID
 
 		planPath := planStore.GetPlanPath(clusterName, planID)
 		fmt.Printf("\n✅ Plan saved: %s\n", planID)
@@ -354,6 +429,9 @@ Examples:
 			// Get the simulation executor from the executors map
 			var simExec *simulation.SimulationExecutor
 			for _, exec := range executors {
+				if rec, ok := exec.(*executor.RecordingExecutor); ok {
+					exec = rec.Unwrap()
+				}
 				if se, ok := exec.(*simulation.SimulationExecutor); ok {
 					simExec = se
 					break
@@ -418,8 +496,19 @@ var clusterStopCmd = &cobra.Command{
 		clusterName := args[0]
 		ctx := context.Background()
 
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+
+		// Protected clusters require their name instead of y/N
+		protected, err := mgr.ConfirmProtected(clusterName, "stop", clusterIKnowThisIsProd, os.Stdin, os.Stdout)
+		if err != nil {
+			return err
+		}
+
 		// Require confirmation unless --yes flag is passed
-		if !clusterDeployYes {
+		if !clusterDeployYes && !protected {
 			fmt.Printf("Are you sure you want to stop cluster '%s'? [y/N]: ", clusterName)
 			var response string
 			_, _ = fmt.Scanln(&response)
@@ -429,11 +518,6 @@ var clusterStopCmd = &cobra.Command{
 			}
 		}
 
-		mgr, err := cluster.NewManager()
-		if err != nil {
-			return fmt.Errorf("failed to create manager: %w", err)
-		}
-
 		return mgr.Stop(ctx, clusterName, clusterNodeFilter)
 	},
 }
@@ -463,8 +547,19 @@ var clusterDestroyCmd = &cobra.Command{
 		clusterName := args[0]
 		ctx := context.Background()
 
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+
+		// Protected clusters require their name, even with --yes
+		protected, err := mgr.ConfirmProtected(clusterName, "destroy", clusterIKnowThisIsProd, os.Stdin, os.Stdout)
+		if err != nil {
+			return err
+		}
+
 		// Require confirmation unless --yes flag is passed
-		if !clusterDeployYes {
+		if !clusterDeployYes && !protected {
 			action := "destroy"
 			if clusterKeepData {
 				action = "destroy (keeping data)"
@@ -482,12 +577,762 @@ var clusterDestroyCmd = &cobra.Command{
 			}
 		}
 
+		return mgr.Destroy(ctx, clusterName, clusterKeepData)
+	},
+}
+
+var clusterProtectCmd = &cobra.Command{
+	Use:   "protect <cluster-name>",
+	Short: "Protect a cluster from destructive commands",
+	Long: `Protect a cluster from destructive commands.
+
+While a cluster is protected, 'mup cluster destroy', 'mup cluster stop' and
+'mup cluster users apply' plans that drop users or roles refuse to run, even
+with --yes, until the operator types the cluster's name or passes
+--i-know-this-is-prod=<cluster-name>. Plans that stop processes, remove
+directories or drop users and roles of a protected cluster fail validation
+and are refused by the applier.`,
+	Example: `  mup cluster protect prod
+  mup cluster destroy prod --i-know-this-is-prod=prod`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+		return mgr.SetProtected(args[0], true)
+	},
+}
+
+var clusterUnprotectCmd = &cobra.Command{
+	Use:   "unprotect <cluster-name>",
+	Short: "Lift a cluster's protection from destructive commands",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		mgr, err := cluster.NewManager()
 		if err != nil {
 			return fmt.Errorf("failed to create manager: %w", err)
 		}
+		return mgr.SetProtected(args[0], false)
+	},
+}
 
-		return mgr.Destroy(ctx, clusterName, clusterKeepData)
+var clusterExecCmd = &cobra.Command{
+	Use:   "exec <cluster-name>",
+	Short: "Run a shell command on cluster hosts",
+	Long: `Run the same shell command on every host of a cluster in parallel.
+
+Output is grouped by host along with each host's exit status. Works for
+local and remote (SSH) clusters alike.
+
+Examples:
+  # Check disk space on every host
+  mup cluster exec my-cluster --cmd 'df -h'
+
+  # Check transparent huge pages on mongod hosts only
+  mup cluster exec my-cluster --role mongod --cmd 'cat /sys/kernel/mm/transparent_hugepage/enabled'
+
+  # Single host, JSON output
+  mup cluster exec my-cluster --host db1.example.com --cmd 'ulimit -a' --format json
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clusterName := args[0]
+		ctx, cancel := newCommandContext(clusterExecTimeout)
+		defer cancel()
+
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+
+		_, err = mgr.Exec(ctx, clusterName, cluster.ExecOptions{
+			Command: clusterExecCommand,
+			Role:    clusterExecRole,
+			Host:    clusterExecHost,
+			Format:  clusterDisplayFormat,
+		})
+		return err
+	},
+}
+
+var clusterRotateCertsCmd = &cobra.Command{
+	Use:   "rotate-certs <cluster-name>",
+	Short: "Reissue node TLS certificates with a rolling restart",
+	Long: `Reissue the TLS certificate of every node and restart the nodes one at a
+time so the cluster stays available.
+
+Each replica set restarts its secondaries first, then steps down the primary
+and restarts it. A node must rejoin its replica set before the next one
+restarts. Config servers go first and mongos routers last.
+
+With --new-ca the cluster also moves to a new CA. Nodes first restart
+trusting both the old and the new CA, then restart again with certificates
+of the new CA, so members never reject each other during the transition.
+
+Examples:
+  # Renew node certificates before they expire
+  mup cluster rotate-certs my-cluster
+
+  # Replace the CA as well
+  mup cluster rotate-certs my-cluster --new-ca
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clusterName := args[0]
+
+		if !clusterDeployYes {
+			fmt.Printf("This restarts every node of cluster '%s', one at a time. Continue? [y/N]: ", clusterName)
+			var response string
+			_, _ = fmt.Scanln(&response)
+			if response != "y" && response != "Y" && response != "yes" {
+				fmt.Println("Cancelled.")
+				return nil
+			}
+		}
+
+		ctx, cancel := newCommandContext(clusterRotateTimeout)
+		defer cancel()
+
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+
+		return mgr.RotateCerts(ctx, clusterName, cluster.RotateCertsOptions{NewCA: clusterRotateNewCA})
+	},
+}
+
+var clusterEnableAuthCmd = &cobra.Command{
+	Use:   "enable-auth <cluster-name>",
+	Short: "Turn on access control without downtime",
+	Long: `Turn on access control on a running cluster following MongoDB's
+transitionToAuth procedure, so clients keep working throughout:
+
+1. prepare:     create the keyFile and admin password and copy the keyFile
+                to every host
+2. transition:  restart every node with the keyFile and transitionToAuth,
+                which accepts both authenticated and anonymous clients
+3. create_user: create the admin user with the root role
+4. enforce:     restart every node again without transitionToAuth
+
+Restarts go one node at a time: mongos routers, then config servers and
+shards, secondaries before their primary. A primary steps down before it
+restarts.
+
+Progress is checkpointed after every operation. If enable-auth is
+interrupted, run it again with --resume to continue where it stopped.
+Clients must log in once the enforce phase starts.
+
+Examples:
+  # Review the plan
+  mup cluster enable-auth my-rs --plan-only
+
+  # Enable access control with admin user "root"
+  mup cluster enable-auth my-rs --user root
+
+  # Continue an interrupted run
+  mup cluster enable-auth my-rs --resume
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clusterName := args[0]
+
+		ctx, cancel := newCommandContext(clusterEnableAuthTimeout)
+		defer cancel()
+
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+		storageDir, err := getStorageDir()
+		if err != nil {
+			return err
+		}
+		clusterDir, err := getClusterDir(clusterName)
+		if err != nil {
+			return err
+		}
+		planStore, err := plan.NewPlanStore(storageDir)
+		if err != nil {
+			return fmt.Errorf("failed to create plan store: %w", err)
+		}
+		stateManager := apply.NewStateManager(clusterDir)
+
+		var authPlan *plan.Plan
+		var resumeState *apply.ApplyState
+		if clusterEnableAuthResume {
+			if resumeState, err = latestResumableState(stateManager, cluster.EnableAuthOperation); err != nil {
+				return err
+			}
+			if authPlan, err = planStore.LoadPlan(clusterName, resumeState.PlanID); err != nil {
+				return fmt.Errorf("failed to load plan: %w", err)
+			}
+			fmt.Printf("Resuming enable-auth plan %s from phase %s\n", authPlan.PlanID, resumeState.CurrentPhase)
+		} else {
+			fmt.Printf("\n📋 Generating enable-auth plan for cluster '%s'...\n\n", clusterName)
+			authPlan, err = mgr.PlanEnableAuth(ctx, clusterName, cluster.EnableAuthOptions{
+				User:    clusterEnableAuthUser,
+				KeyFile: clusterEnableAuthKeyFile,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to generate plan: %w", err)
+			}
+			fmt.Println(authPlan.Summary())
+
+			planID, err := planStore.SavePlan(authPlan)
+			if err != nil {
+				return fmt.Errorf("failed to save plan: %w", err)
+			}
+			fmt.Printf("✅ Plan saved: %s\n", planID)
+			fmt.Printf("   Path: %s\n", planStore.GetPlanPath(clusterName, planID))
+			if verified, err := planStore.VerifyPlan(clusterName, planID); err != nil {
+				fmt.Printf("⚠️  Warning: Failed to verify plan: %v\n", err)
+			} else if verified {
+				fmt.Printf("   ✓ Integrity verified (SHA-256)\n")
+			}
+
+			if clusterDeployPlanOnly {
+				return nil
+			}
+		}
+		auditPlanID = authPlan.PlanID
+		if err := validateClusterPlan(authPlan); err != nil {
+			return err
+		}
+
+		if !clusterDeployAutoApprove && !clusterDeployYes {
+			fmt.Printf("\nThis restarts every node of cluster '%s' twice, one at a time. Continue? [y/N]: ", clusterName)
+			var response string
+			_, _ = fmt.Scanln(&response)
+			if response != "y" && response != "Y" && response != "yes" {
+				fmt.Println("Cancelled.")
+				return nil
+			}
+		}
+
+		lockMgr, err := apply.NewLockManager(storageDir)
+		if err != nil {
+			return fmt.Errorf("failed to create lock manager: %w", err)
+		}
+		lock, err := lockMgr.AcquireLock(clusterName, authPlan.PlanID, cluster.EnableAuthOperation, clusterEnableAuthTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to acquire cluster lock: %w", err)
+		}
+		defer func() {
+			if err := lockMgr.ReleaseLock(clusterName, lock); err != nil {
+				fmt.Printf("Warning: failed to release lock: %v\n", err)
+			}
+		}()
+
+		executors, err := mgr.EnableAuthExecutors(clusterName)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			for _, exec := range executors {
+				_ = exec.Close()
+			}
+		}()
+
+		applier := apply.NewDefaultApplier(operation.NewExecutor(executors), stateManager)
+		var state *apply.ApplyState
+		if resumeState != nil {
+			state, err = applier.Resume(ctx, resumeState)
+		} else {
+			state, err = applier.Apply(ctx, authPlan)
+		}
+		if err != nil {
+			fmt.Printf("\n❌ enable-auth failed: %v\n", err)
+			if state != nil {
+				fmt.Printf("Resume with: mup cluster enable-auth %s --resume\n", clusterName)
+			}
+			return err
+		}
+
+		if err := mgr.CompleteEnableAuth(clusterName, authPlan); err != nil {
+			return err
+		}
+		fmt.Printf("\n✅ Access control is enabled on cluster '%s'\n", clusterName)
+		fmt.Printf("   User: %s (password: mup cluster credentials show %s --reveal)\n", authPlan.Config["user"], clusterName)
+		return nil
+	},
+}
+
+// latestResumableState returns the most recent paused or failed apply of
+// operation
+func latestResumableState(stateManager *apply.StateManager, operation string) (*apply.ApplyState, error) {
+	states, err := stateManager.ListStates()
+	if err != nil {
+		return nil, err
+	}
+	var latest *apply.ApplyState
+	for _, state := range states {
+		if state.Operation != operation || !state.CanResume() {
+			continue
+		}
+		if latest == nil || state.StartedAt.After(latest.StartedAt) {
+			latest = state
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no interrupted %s to resume", operation)
+	}
+	return latest, nil
+}
+
+var clusterCredentialsCmd = &cobra.Command{
+	Use:   "credentials",
+	Short: "Manage the encrypted credentials mup connects with",
+	Long: `Manage the per-cluster credential store mup uses to log in for health
+checks, upgrades and connect.
+
+The store lives in the cluster's secrets directory and is encrypted with
+chacha20poly1305 under a key derived with scrypt from the passphrase in
+` + security.PassphraseEnv + `, or from a random keyring in ~/.mup/keyring when
+the variable is unset. Passwords are never written to meta.yaml.`,
+}
+
+var clusterCredentialsSetCmd = &cobra.Command{
+	Use:   "set <cluster-name>",
+	Short: "Store a user's password",
+	Long: `Store a user's password in the cluster's credential store. The password is
+read from --password-file, or from the first line of stdin.
+
+mup logs in with the password before storing it unless --no-verify is given.
+Storing the admin password removes the plaintext password file deploy and
+enable-auth created.
+
+Examples:
+  # Move the admin password into the store
+  mup cluster credentials set my-cluster --password-file ~/.mup/storage/clusters/my-cluster/secrets/admin.password
+
+  # Store a password typed on stdin
+  echo "$PASSWORD" | mup cluster credentials set my-cluster --user backup
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var password string
+		var err error
+		if clusterCredentialsPasswordFile != "" {
+			password, err = security.ReadPassword(clusterCredentialsPasswordFile)
+		} else {
+			password, err = bufio.NewReader(os.Stdin).ReadString('\n')
+			if err == io.EOF {
+				err = nil
+			}
+			password = strings.TrimSpace(password)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+		return mgr.SetCredentials(cmd.Context(), args[0], cluster.SetCredentialsOptions{
+			User:     clusterCredentialsUser,
+			Password: password,
+			NoVerify: clusterCredentialsNoVerify,
+		})
+	},
+}
+
+var clusterCredentialsShowCmd = &cobra.Command{
+	Use:   "show <cluster-name>",
+	Short: "List the stored credentials",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+		info, err := mgr.ShowCredentials(args[0], clusterCredentialsReveal)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Store: %s\n", info.File)
+		fmt.Printf("Key:   %s\n\n", info.KeySource)
+		for _, user := range info.Users {
+			line := fmt.Sprintf("  %-20s updated %s", user.User, user.UpdatedAt.Local().Format(time.RFC3339))
+			if clusterCredentialsReveal {
+				line += "  password: " + user.Password
+			}
+			fmt.Println(line)
+		}
+		return nil
+	},
+}
+
+var clusterCredentialsRekeyCmd = &cobra.Command{
+	Use:   "rekey <cluster-name>",
+	Short: "Re-encrypt the credential store with another key source",
+	Long: `Re-encrypt the cluster's credential store with the passphrase in
+` + security.PassphraseEnv + ` or with the keyring in ~/.mup/keyring.
+
+A store keeps the key source it was created with, even when
+` + security.PassphraseEnv + ` is set later; this command is how it changes.
+Both the current and the new key must be available.
+
+Examples:
+  # Move a keyring store to a passphrase
+  ` + security.PassphraseEnv + `=... mup cluster credentials rekey my-cluster --to passphrase
+
+  # And back
+  ` + security.PassphraseEnv + `=... mup cluster credentials rekey my-cluster --to keyring
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+		return mgr.RekeyCredentials(args[0], clusterCredentialsKeySource)
+	},
+}
+
+var clusterCredentialsRotateCmd = &cobra.Command{
+	Use:   "rotate <cluster-name>",
+	Short: "Replace a user's password with a generated one",
+	Long: `Generate a new password for a user, change it on the cluster while logged
+in as the admin user, and store it in the credential store.
+
+Clients other than mup that log in as the user need the new password, which
+'mup cluster credentials show --reveal' prints.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clusterName := args[0]
+
+		if !clusterDeployYes {
+			user := clusterCredentialsUser
+			if user == "" {
+				user = "the admin user"
+			}
+			fmt.Printf("This changes the password of %s in cluster '%s'. Continue? [y/N]: ", user, clusterName)
+			var response string
+			_, _ = fmt.Scanln(&response)
+			if response != "y" && response != "Y" && response != "yes" {
+				fmt.Println("Cancelled.")
+				return nil
+			}
+		}
+
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+		return mgr.RotateCredentials(cmd.Context(), clusterName, clusterCredentialsUser)
+	},
+}
+
+var clusterUsersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage database users and roles from a file",
+	Long: `Keep a cluster's database users and custom roles in a YAML file, reviewed
+in version control like any other change:
+
+  roles:
+    - name: appReader
+      db: admin
+      privileges:
+        - resource: {db: app, collection: ""}
+          actions: [find, listCollections]
+      roles:
+        - {role: read, db: reporting}
+  users:
+    - name: app
+      db: app
+      roles:
+        - {role: readWrite, db: app}
+      password: app-prod     # Credential store entry (default: name@db, generated)
+    - name: "CN=etl,O=example"
+      db: $external          # x509 or LDAP user, no password
+      roles:
+        - {role: appReader, db: admin}
+
+'users plan' compares the file with the cluster and shows the operations
+that would create, update or drop users and roles. 'users apply' runs them.
+Users and roles missing from the file are only dropped with --allow-drop.
+The admin user mup logs in as cannot be managed from the file.`,
+}
+
+var clusterUsersPlanCmd = &cobra.Command{
+	Use:   "plan <cluster-name>",
+	Short: "Show the changes that bring users and roles in line with a file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runClusterUsers(args[0], true)
+	},
+}
+
+var clusterUsersApplyCmd = &cobra.Command{
+	Use:   "apply <cluster-name>",
+	Short: "Create, update and drop users and roles to match a file",
+	Long: `Create, update and drop users and roles to match a file.
+
+Created users get their password from the cluster's credential store. A
+user without a password entry gets a generated password, stored under
+name@db. Passwords of existing users are not changed; use
+'mup cluster credentials rotate' for that.
+
+Examples:
+  # Review, then apply
+  mup cluster users plan my-cluster -f users.yaml
+  mup cluster users apply my-cluster -f users.yaml
+
+  # Also drop users and roles missing from the file
+  mup cluster users apply my-cluster -f users.yaml --allow-drop
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runClusterUsers(args[0], false)
+	},
+}
+
+// runClusterUsers plans the users file against clusterName and applies the
+// plan unless planOnly is set
+func runClusterUsers(clusterName string, planOnly bool) error {
+	ctx, cancel := newCommandContext(clusterUsersTimeout)
+	defer cancel()
+
+	mgr, err := cluster.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to create manager: %w", err)
+	}
+	storageDir, err := getStorageDir()
+	if err != nil {
+		return err
+	}
+	clusterDir, err := getClusterDir(clusterName)
+	if err != nil {
+		return err
+	}
+
+	usersPlan, err := mgr.PlanUsers(ctx, clusterName, cluster.UsersOptions{
+		File:      clusterUsersFile,
+		AllowDrop: clusterUsersAllowDrop,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate plan: %w", err)
+	}
+	for _, warning := range usersPlan.Validation.Warnings {
+		fmt.Printf("⚠️  %s\n", warning.Message)
+	}
+
+	changes := 0
+	for _, phase := range usersPlan.Phases {
+		for _, op := range phase.Operations {
+			fmt.Printf("  %s\n", op.Description)
+			changes++
+		}
+	}
+	if changes == 0 {
+		fmt.Printf("✅ Users and roles of cluster '%s' match %s\n", clusterName, clusterUsersFile)
+		return nil
+	}
+
+	planStore, err := plan.NewPlanStore(storageDir)
+	if err != nil {
+		return fmt.Errorf("failed to create plan store: %w", err)
+	}
+	planID, err := planStore.SavePlan(usersPlan)
+	if err != nil {
+		return fmt.Errorf("failed to save plan: %w", err)
+	}
+	auditPlanID = planID
+	fmt.Printf("\n%d changes. Plan saved: %s\n", changes, planStore.GetPlanPath(clusterName, planID))
+	if planOnly {
+		return nil
+	}
+
+	// Dropping users and roles of a protected cluster requires its name
+	protected := false
+	if plan.HasDestructiveOperations(usersPlan) {
+		if protected, err = mgr.ConfirmProtected(clusterName, "drop users and roles of", clusterIKnowThisIsProd, os.Stdin, os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if !clusterDeployAutoApprove && !clusterDeployYes && !protected {
+		fmt.Printf("\nApply %d changes to cluster '%s'? [y/N]: ", changes, clusterName)
+		var response string
+		_, _ = fmt.Scanln(&response)
+		if response != "y" && response != "Y" && response != "yes" {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	if err := checkClusterPlan(mgr, usersPlan); err != nil {
+		return err
+	}
+
+	lockMgr, err := apply.NewLockManager(storageDir)
+	if err != nil {
+		return fmt.Errorf("failed to create lock manager: %w", err)
+	}
+	lock, err := lockMgr.AcquireLock(clusterName, usersPlan.PlanID, cluster.UsersOperation, clusterUsersTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to acquire cluster lock: %w", err)
+	}
+	defer func() {
+		if err := lockMgr.ReleaseLock(clusterName, lock); err != nil {
+			fmt.Printf("Warning: failed to release lock: %v\n", err)
+		}
+	}()
+
+	executors := mgr.UsersExecutors()
+	defer func() {
+		for _, exec := range executors {
+			_ = exec.Close()
+		}
+	}()
+
+	applier := apply.NewDefaultApplier(operation.NewExecutor(executors), apply.NewStateManager(clusterDir))
+	if protected {
+		applier.AllowProtected()
+	}
+	if _, err := applier.Apply(ctx, usersPlan); err != nil {
+		fmt.Printf("\n❌ users apply failed: %v\n", err)
+		return err
+	}
+	fmt.Printf("\n✅ Users and roles of cluster '%s' match %s\n", clusterName, clusterUsersFile)
+	return nil
+}
+
+var clusterAuditCmd = &cobra.Command{
+	Use:   "audit <cluster-name>",
+	Short: "Check a cluster's security settings",
+	Long: `Check a cluster's generated configs, meta.yaml and the options its nodes
+run with (getCmdLineOpts) for security problems: nodes listening on all
+interfaces without authorization, TLS disabled or accepting invalid
+certificates, keyFiles other users can read, a default admin password, an end
+of life MongoDB version and exposed monitoring ports.
+
+Findings have a severity of info, low, medium or high. The command exits
+non-zero when a finding is at least as severe as --fail-on, so CI can gate on
+it.`,
+	Example: `  mup cluster audit prod
+  mup cluster audit prod --format json --fail-on medium`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		failOn, err := lint.ParseSeverity(clusterAuditFailOn)
+		if err != nil {
+			return err
+		}
+		if clusterAuditFormat != "text" && clusterAuditFormat != "json" {
+			return fmt.Errorf("unknown format %q (expected text or json)", clusterAuditFormat)
+		}
+
+		ctx, cancel := newCommandContext(clusterAuditTimeout)
+		defer cancel()
+
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+		report, err := mgr.Audit(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		if clusterAuditFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			for _, f := range report.Findings {
+				fmt.Printf("%-6s  %-24s  %s: %s\n", strings.ToUpper(string(f.Severity)), f.Check, f.Target, f.Message)
+				if f.Remediation != "" {
+					fmt.Printf("        %-24s  fix: %s\n", "", f.Remediation)
+				}
+			}
+			if len(report.Findings) == 0 {
+				fmt.Printf("✓ No findings for cluster '%s'\n", report.Cluster)
+			} else {
+				fmt.Printf("\n%d finding(s): %d high, %d medium, %d low, %d info\n", len(report.Findings),
+					report.Count(lint.SeverityHigh), report.Count(lint.SeverityMedium),
+					report.Count(lint.SeverityLow), report.Count(lint.SeverityInfo))
+			}
+		}
+
+		if report.HasAtLeast(failOn) {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("cluster '%s' has findings of severity %s or higher", report.Cluster, failOn)
+		}
+		return nil
+	},
+}
+
+var clusterBackupCmd = &cobra.Command{
+	Use:   "backup <cluster-name>",
+	Short: "Take a hot backup of a Percona Server for MongoDB cluster",
+	Long: `Take a hot backup of a Percona Server for MongoDB cluster with createBackup,
+without stopping any node.
+
+Each replica set is backed up from a healthy secondary, or from its primary
+when no secondary is available, and each node outside a replica set from
+itself. The backups are written on the nodes' hosts under
+<dir>/<UTC timestamp>/<replica set>, where dir is --dir or the topology's
+percona.backup.dir.
+
+The shards of a sharded cluster are backed up one after another, not at a
+single point in time; stop the balancer first for a consistent backup.`,
+	Example: `  mup cluster backup prod
+  mup cluster backup prod --dir /backups/mongodb`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := newCommandContext(clusterBackupTimeout)
+		defer cancel()
+
+		mgr, err := cluster.NewManager()
+		if err != nil {
+			return fmt.Errorf("failed to create manager: %w", err)
+		}
+
+		return mgr.Backup(ctx, args[0], cluster.BackupOptions{Dir: clusterBackupDir})
+	},
+}
+
+var clusterTemplateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Generate a starter topology file",
+	Long: `Generate a commented, valid topology YAML to start a new cluster from.
+
+Replica set members are spread across the given hosts so that each replica
+set starts on a different host. Ports are assigned per host: mongod from the
+base port, mongos from base+1000 and config servers from base+2000.
+
+Examples:
+  # 3-member replica set across three hosts
+  mup cluster template --type replica-set --hosts db1,db2,db3 > rs.yaml
+
+  # 2 shards of 3 members, 2 mongos, with runtime_config and monitoring notes
+  mup cluster template --type sharded --shards 2 --replicas 3 --mongos 2 \
+    --hosts db1,db2,db3 --runtime-config --monitoring -o sharded.yaml
+
+  # Everything on localhost
+  mup cluster template --type sharded --local`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := topology.GenerateTemplate(clusterTemplateOptions)
+		if err != nil {
+			return err
+		}
+
+		if clusterTemplateOutput == "" {
+			_, err = os.Stdout.Write(out)
+			return err
+		}
+		if err := os.WriteFile(clusterTemplateOutput, out, 0644); err != nil {
+			return fmt.Errorf("failed to write topology file: %w", err)
+		}
+		fmt.Printf("✓ Wrote %s topology to %s\n", clusterTemplateOptions.Type, clusterTemplateOutput)
+		return nil
 	},
 }
 
@@ -498,12 +1343,20 @@ var clusterListCmd = &cobra.Command{
 
 Shows cluster name, status, version, topology type, and creation time.
 
+With --ports, shows the port registry instead: every port reserved by a
+managed cluster, including its supervisord API, monitoring services and
+exporters. Reserved ports are not allocated to new clusters, even while
+the cluster holding them is stopped.
+
 Examples:
   # List all clusters
   mup cluster list
 
   # List with JSON output
   mup cluster list --format json
+
+  # Show reserved ports
+  mup cluster list --ports
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		mgr, err := cluster.NewManager()
@@ -511,6 +1364,9 @@ Examples:
 			return fmt.Errorf("failed to create manager: %w", err)
 		}
 
+		if clusterListPorts {
+			return mgr.ListPorts(clusterDisplayFormat)
+		}
 		return mgr.List(clusterDisplayFormat)
 	},
 }
@@ -553,6 +1409,16 @@ Examples:
 			return fmt.Errorf("could not determine connection string for cluster '%s'", clusterName)
 		}
 
+		// With access control the shell prompts for the admin password
+		if metadata.Security != nil && metadata.Security.Auth {
+			connStr = withAdminUser(connStr, metadata.Security.AdminUser)
+			if _, err := os.Stat(metadata.Security.CredentialsFile); metadata.Security.CredentialsFile != "" && err == nil {
+				fmt.Printf("Password for %s: mup cluster credentials show %s --reveal\n", metadata.Security.AdminUser, clusterName)
+			} else {
+				fmt.Printf("Password for %s is stored in %s\n", metadata.Security.AdminUser, metadata.Security.PasswordFile)
+			}
+		}
+
 		// Get correct shell binary based on MongoDB version
 		shellBinary := mongo.GetShellBinary(metadata.Version)
 
@@ -563,6 +1429,12 @@ Examples:
 
 		// Build connection command
 		connectionCmd := fmt.Sprintf("%s \"%s\"", shellPath, connStr)
+		// TLS clusters are verified against the cluster CA
+		if metadata.Security.TLSEnabled() {
+			for _, arg := range security.ShellTLSFlags(shellPath, metadata.Security.CAFile) {
+				connectionCmd += " " + executor.ShellQuote(arg)
+			}
+		}
 
 		fmt.Printf("Connecting to cluster '%s'...\n", clusterName)
 		fmt.Printf("Executing: %s\n\n", connectionCmd)
@@ -629,7 +1501,8 @@ Examples:
 
 		clusterName := args[0]
 
-		ctx, cancel := context.WithTimeout(context.Background(), clusterDeployTimeout)
+		// Ctrl-C and --timeout both cancel in-flight executor commands
+		ctx, cancel := newCommandContext(clusterDeployTimeout)
 		defer cancel()
 
 		// Get metadata directory
@@ -849,8 +1722,24 @@ func init() {
 	clusterCmd.AddCommand(clusterStopCmd)
 	clusterCmd.AddCommand(clusterDisplayCmd)
 	clusterCmd.AddCommand(clusterDestroyCmd)
+	clusterCmd.AddCommand(clusterProtectCmd)
+	clusterCmd.AddCommand(clusterUnprotectCmd)
 	clusterCmd.AddCommand(clusterListCmd)
 	clusterCmd.AddCommand(clusterConnectCmd)
+	clusterCmd.AddCommand(clusterExecCmd)
+	clusterCmd.AddCommand(clusterTemplateCmd)
+	clusterCmd.AddCommand(clusterRotateCertsCmd)
+	clusterCmd.AddCommand(clusterEnableAuthCmd)
+	clusterCmd.AddCommand(clusterCredentialsCmd)
+	clusterCredentialsCmd.AddCommand(clusterCredentialsSetCmd)
+	clusterCredentialsCmd.AddCommand(clusterCredentialsShowCmd)
+	clusterCredentialsCmd.AddCommand(clusterCredentialsRotateCmd)
+	clusterCredentialsCmd.AddCommand(clusterCredentialsRekeyCmd)
+	clusterCmd.AddCommand(clusterUsersCmd)
+	clusterUsersCmd.AddCommand(clusterUsersPlanCmd)
+	clusterUsersCmd.AddCommand(clusterUsersApplyCmd)
+	clusterCmd.AddCommand(clusterAuditCmd)
+	clusterCmd.AddCommand(clusterBackupCmd)
 
 	// Deploy command flags
 	clusterDeployCmd.Flags().StringVarP(&clusterDeployVersion, "version", "v", "7.0", "MongoDB version to deploy")
@@ -866,6 +1755,7 @@ func init() {
 	clusterDeployCmd.Flags().BoolVar(&clusterDeploySimulate, "simulate", false, "REQ-SIM-001: Run command in simulation mode (no filesystem/process/network changes)")
 	clusterDeployCmd.Flags().StringVar(&clusterDeploySimulateScenario, "simulate-scenario", "", "REQ-SIM-041: Path to scenario YAML file for simulation")
 	clusterDeployCmd.Flags().BoolVar(&clusterDeploySimulateVerbose, "simulate-verbose", false, "REQ-SIM-049: Show detailed operation log in simulation mode")
+	clusterDeployCmd.Flags().StringVar(&clusterDeployRecord, "record", "", "Record all executor calls to a cassette file (replay with executor.ReplayExecutor)")
 
 	// Start/stop command flags
 	clusterStartCmd.Flags().StringVar(&clusterNodeFilter, "node", "", "Start specific node only (host:port)")
@@ -877,11 +1767,82 @@ func init() {
 
 	// List command flags
 	clusterListCmd.Flags().StringVar(&clusterDisplayFormat, "format", "text", "Output format: text, json, yaml")
+	clusterListCmd.Flags().BoolVar(&clusterListPorts, "ports", false, "Show ports reserved by each cluster")
+
+	// Exec command flags
+	clusterExecCmd.Flags().StringVar(&clusterExecCommand, "cmd", "", "Shell command to run on each host (required)")
+	clusterExecCmd.Flags().StringVar(&clusterExecRole, "role", "", "Only hosts running this role: mongod, mongos, config")
+	clusterExecCmd.Flags().StringVar(&clusterExecHost, "host", "", "Only this host")
+	clusterExecCmd.Flags().StringVar(&clusterDisplayFormat, "format", "text", "Output format: text, json")
+	clusterExecCmd.Flags().DurationVarP(&clusterExecTimeout, "timeout", "t", 5*time.Minute, "Command timeout")
+	_ = clusterExecCmd.MarkFlagRequired("cmd")
+
+	// Rotate-certs command flags
+	clusterRotateCertsCmd.Flags().BoolVar(&clusterRotateNewCA, "new-ca", false, "Replace the cluster CA as well as the node certificates")
+	clusterRotateCertsCmd.Flags().BoolVar(&clusterDeployYes, "yes", false, "Skip confirmation prompt")
+	clusterRotateCertsCmd.Flags().DurationVarP(&clusterRotateTimeout, "timeout", "t", 30*time.Minute, "Rotation timeout")
+
+	// Enable-auth command flags
+	clusterEnableAuthCmd.Flags().StringVar(&clusterEnableAuthUser, "user", "", "Admin user to create (default: admin)")
+	clusterEnableAuthCmd.Flags().StringVar(&clusterEnableAuthKeyFile, "key-file", "", "Existing keyFile to use instead of generating one")
+	clusterEnableAuthCmd.Flags().BoolVar(&clusterEnableAuthResume, "resume", false, "Resume an interrupted enable-auth")
+	clusterEnableAuthCmd.Flags().BoolVar(&clusterDeployPlanOnly, "plan-only", false, "Generate the plan without executing it")
+	clusterEnableAuthCmd.Flags().BoolVar(&clusterDeployAutoApprove, "auto-approve", false, "Skip confirmation and apply the plan")
+	clusterEnableAuthCmd.Flags().BoolVar(&clusterDeployYes, "yes", false, "Skip confirmation prompt")
+	clusterEnableAuthCmd.Flags().DurationVarP(&clusterEnableAuthTimeout, "timeout", "t", time.Hour, "Enable-auth timeout")
+
+	// Credentials command flags
+	clusterCredentialsSetCmd.Flags().StringVar(&clusterCredentialsUser, "user", "", "User the password belongs to (default: the admin user)")
+	clusterCredentialsSetCmd.Flags().StringVar(&clusterCredentialsPasswordFile, "password-file", "", "Read the password from a file instead of stdin")
+	clusterCredentialsSetCmd.Flags().BoolVar(&clusterCredentialsNoVerify, "no-verify", false, "Store the password without logging in with it")
+	clusterCredentialsShowCmd.Flags().BoolVar(&clusterCredentialsReveal, "reveal", false, "Print the passwords")
+	clusterCredentialsRotateCmd.Flags().StringVar(&clusterCredentialsUser, "user", "", "User whose password to rotate (default: the admin user)")
+	clusterCredentialsRotateCmd.Flags().BoolVar(&clusterDeployYes, "yes", false, "Skip confirmation prompt")
+	clusterCredentialsRekeyCmd.Flags().StringVar(&clusterCredentialsKeySource, "to", "", "Key source to encrypt with: passphrase or keyring (required)")
+	_ = clusterCredentialsRekeyCmd.MarkFlagRequired("to")
+
+	// Users command flags
+	for _, cmd := range []*cobra.Command{clusterUsersPlanCmd, clusterUsersApplyCmd} {
+		cmd.Flags().StringVarP(&clusterUsersFile, "file", "f", "", "Users and roles file (required)")
+		cmd.Flags().BoolVar(&clusterUsersAllowDrop, "allow-drop", false, "Drop users and roles missing from the file")
+		cmd.Flags().DurationVarP(&clusterUsersTimeout, "timeout", "t", 10*time.Minute, "Command timeout")
+		_ = cmd.MarkFlagRequired("file")
+	}
+	clusterUsersApplyCmd.Flags().BoolVar(&clusterDeployAutoApprove, "auto-approve", false, "Skip confirmation and apply the plan")
+	clusterUsersApplyCmd.Flags().BoolVar(&clusterDeployYes, "yes", false, "Skip confirmation prompt")
+
+	// Audit command flags
+	clusterAuditCmd.Flags().StringVar(&clusterAuditFormat, "format", "text", "Output format: text, json")
+	clusterAuditCmd.Flags().StringVar(&clusterAuditFailOn, "fail-on", "high", "Exit non-zero on findings of this severity or higher: info, low, medium, high")
+	clusterAuditCmd.Flags().DurationVarP(&clusterAuditTimeout, "timeout", "t", 5*time.Minute, "Command timeout")
+
+	// Backup command flags
+	clusterBackupCmd.Flags().StringVar(&clusterBackupDir, "dir", "", "Backup directory on the nodes' hosts (default: the topology's percona.backup.dir)")
+	clusterBackupCmd.Flags().DurationVarP(&clusterBackupTimeout, "timeout", "t", time.Hour, "Backup timeout")
+
+	// Template command flags
+	tf := clusterTemplateCmd.Flags()
+	tf.StringVar(&clusterTemplateOptions.Type, "type", topology.TemplateReplicaSet, "Topology type: standalone, replica-set, sharded")
+	tf.IntVar(&clusterTemplateOptions.Shards, "shards", 2, "Number of shards (sharded only)")
+	tf.IntVar(&clusterTemplateOptions.Replicas, "replicas", 3, "Members per replica set")
+	tf.IntVar(&clusterTemplateOptions.Mongos, "mongos", 1, "Number of mongos routers (sharded only)")
+	tf.IntVar(&clusterTemplateOptions.ConfigReplicas, "config-replicas", 3, "Config server replica set members (sharded only)")
+	tf.StringSliceVar(&clusterTemplateOptions.Hosts, "hosts", nil, "Comma-separated hosts to spread nodes across")
+	tf.BoolVar(&clusterTemplateOptions.Local, "local", false, "Put every node on localhost")
+	tf.IntVar(&clusterTemplateOptions.BasePort, "base-port", 0, "First port on each host (default 27017, or 30000 with --local)")
+	tf.BoolVar(&clusterTemplateOptions.RuntimeConfig, "runtime-config", false, "Include a documented runtime_config section")
+	tf.BoolVar(&clusterTemplateOptions.Monitoring, "monitoring", false, "Include documentation of the monitoring stack")
+	tf.StringVarP(&clusterTemplateOutput, "output", "o", "", "Write to this file instead of stdout")
 
 	// Destroy command flags
 	clusterDestroyCmd.Flags().BoolVar(&clusterKeepData, "keep-data", false, "Keep data directories")
 	clusterDestroyCmd.Flags().BoolVar(&clusterDeployYes, "yes", false, "Skip confirmation prompt")
 
+	// Protection flags
+	for _, c := range []*cobra.Command{clusterStopCmd, clusterDestroyCmd, clusterUsersApplyCmd} {
+		c.Flags().StringVar(&clusterIKnowThisIsProd, "i-know-this-is-prod", "", "Confirm the action on a protected cluster by passing its name")
+	}
+
 	// Upgrade command flags [UPG-013]
 	clusterUpgradeCmd.Flags().StringVar(&clusterUpgradeToVersion, "to-version", "", "Target MongoDB version (required)")
 	clusterUpgradeCmd.Flags().StringVar(&clusterUpgradeVariant, "variant", "", "Target variant (default: current cluster variant)")
@@ -904,6 +1865,37 @@ func init() {
 	clusterImportCmd.Flags().StringVar(&clusterImportSSHHost, "ssh-host", "", "Remote host for import (user@host format)")
 }
 
+// newCommandContext returns a context that is cancelled on SIGINT/SIGTERM
+// or once timeout elapses
+func newCommandContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := context.WithTimeout(sigCtx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// validateClusterPlan fails p when the state of its cluster forbids it,
+// such as destructive operations on a protected cluster
+func validateClusterPlan(p *plan.Plan) error {
+	mgr, err := cluster.NewManager()
+	if err != nil {
+		return fmt.Errorf("failed to create manager: %w", err)
+	}
+	return checkClusterPlan(mgr, p)
+}
+
+// checkClusterPlan validates p with mgr, which allows destructive
+// operations on protected clusters confirmed with mgr.ConfirmProtected
+func checkClusterPlan(mgr *cluster.Manager, p *plan.Plan) error {
+	if err := mgr.ValidatePlan(p); err != nil {
+		fmt.Println(plan.FormatValidationResult(p.Validation))
+		return err
+	}
+	return nil
+}
+
 // Helper functions for upgrade command
 
 func parsePromptLevelFromFlags() (upgrade.PromptLevel, error) {
@@ -982,7 +1974,7 @@ func getConnectionString(metadata *meta.ClusterMetadata) string {
 		// Connect via mongos
 		for _, node := range metadata.Nodes {
 			if node.Type == "mongos" {
-				return fmt.Sprintf("mongodb://%s:%d", node.Host, node.Port)
+				return "mongodb://" + node.Address()
 			}
 		}
 
@@ -997,7 +1989,7 @@ func getConnectionString(metadata *meta.ClusterMetadata) string {
 					rsName = node.ReplicaSet
 				}
 				if rsName == node.ReplicaSet {
-					hosts = append(hosts, fmt.Sprintf("%s:%d", node.Host, node.Port))
+					hosts = append(hosts, node.Address())
 				}
 			}
 		}
@@ -1010,10 +2002,20 @@ func getConnectionString(metadata *meta.ClusterMetadata) string {
 		// Connect to single mongod
 		for _, node := range metadata.Nodes {
 			if node.Type == "mongod" {
-				return fmt.Sprintf("mongodb://%s:%d", node.Host, node.Port)
+				return "mongodb://" + node.Address()
 			}
 		}
 	}
 
 	return "mongodb://localhost:27017"
 }
+
+// withAdminUser adds user, authenticating against the admin database, to a
+// connection string from getConnectionString
+func withAdminUser(connStr, user string) string {
+	connStr = strings.Replace(connStr, "mongodb://", "mongodb://"+user+"@", 1)
+	if strings.Contains(connStr, "/?") {
+		return connStr + "&authSource=admin"
+	}
+	return connStr + "/?authSource=admin"
+}
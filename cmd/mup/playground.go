@@ -251,7 +251,7 @@ var playgroundConnectCmd = &cobra.Command{
 		rsName := ""
 		for _, node := range metadata.Nodes {
 			if node.Type == "mongod" {
-				hosts = append(hosts, fmt.Sprintf("%s:%d", node.Host, node.Port))
+				hosts = append(hosts, node.Address())
 				if rsName == "" && node.ReplicaSet != "" {
 					rsName = node.ReplicaSet
 				}
@@ -314,7 +314,7 @@ func displayPlaygroundInfo(metadata *meta.ClusterMetadata) {
 	rsName := ""
 	for _, node := range metadata.Nodes {
 		if node.Type == "mongod" {
-			hosts = append(hosts, fmt.Sprintf("%s:%d", node.Host, node.Port))
+			hosts = append(hosts, node.Address())
 			if rsName == "" && node.ReplicaSet != "" {
 				rsName = node.ReplicaSet
 			}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zph/mup/pkg/topology"
+	"gopkg.in/yaml.v3"
+)
+
+var topologyCmd = &cobra.Command{
+	Use:   "topology",
+	Short: "Work with topology files",
+	Long:  `Inspect topology YAML files before deploying them.`,
+}
+
+var topologyRenderCmd = &cobra.Command{
+	Use:   "render <topology-file>",
+	Short: "Print a topology with vars, host ranges and shard templates expanded",
+	Long: `Print the topology that mup will deploy from a topology file.
+
+The output has ${var} references substituted from the vars block, host
+ranges such as db[01:03].example.com expanded into one node per host, and
+shard_templates expanded into mongod_servers entries with their computed
+ports and replica set names. Global defaults are applied to every node.
+
+Examples:
+  mup topology render sharded.yaml
+  mup topology render sharded.yaml > expanded.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		topo, err := topology.ParseTopologyFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(topo); err != nil {
+			return fmt.Errorf("failed to render topology: %w", err)
+		}
+		return enc.Close()
+	},
+}
+
+var topologyValidateFormat string
+
+var topologyValidateCmd = &cobra.Command{
+	Use:   "validate <topology-file>",
+	Short: "Check a topology file and report every problem with its position",
+	Long: `Check a topology file without deploying it.
+
+Unknown keys (such as a misspelled replicaset:), values of the wrong type
+and topology errors (missing hosts, duplicate ports, invalid replica set
+settings) are all reported, each with the line and column it refers to.
+
+Examples:
+  mup topology validate replica-set.yaml
+  mup topology validate replica-set.yaml --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := args[0]
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read topology file: %w", err)
+		}
+
+		topo, problems := topology.CheckTopology(data)
+
+		switch topologyValidateFormat {
+		case "json":
+			if problems == nil {
+				problems = topology.Problems{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(map[string]any{"file": file, "valid": len(problems) == 0, "problems": problems}); err != nil {
+				return err
+			}
+		case "text":
+			for _, p := range problems {
+				switch {
+				case p.Line > 0 && p.Column > 0:
+					fmt.Printf("%s:%d:%d: %s\n", file, p.Line, p.Column, p.Message)
+				case p.Line > 0:
+					fmt.Printf("%s:%d: %s\n", file, p.Line, p.Message)
+				default:
+					fmt.Printf("%s: %s\n", file, p.Message)
+				}
+			}
+			if len(problems) == 0 {
+				fmt.Printf("✓ %s is valid: %s topology with %d mongod, %d mongos, %d config server(s)\n",
+					file, topo.GetTopologyType(), len(topo.Mongod), len(topo.Mongos), len(topo.ConfigSvr))
+			}
+		default:
+			return fmt.Errorf("unknown format %q (expected text or json)", topologyValidateFormat)
+		}
+
+		if len(problems) > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%s has %d problem(s)", file, len(problems))
+		}
+		return nil
+	},
+}
+
+var topologySchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print a JSON Schema for topology files",
+	Long: `Print a JSON Schema describing topology files, for editor validation and
+autocompletion.
+
+For example, with the YAML language server (VS Code, Neovim) save the output
+and reference it from the top of a topology file:
+
+  mup topology schema > ~/.mup/topology.schema.json
+  # yaml-language-server: $schema=/home/me/.mup/topology.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(topology.JSONSchema())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(topologyCmd)
+	topologyCmd.AddCommand(topologyRenderCmd)
+	topologyCmd.AddCommand(topologyValidateCmd)
+	topologyCmd.AddCommand(topologySchemaCmd)
+
+	topologyValidateCmd.Flags().StringVar(&topologyValidateFormat, "format", "text", "Output format: text, json")
+}
@@ -0,0 +1,240 @@
+package access
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Change kinds
+const (
+	KindRole = "role"
+	KindUser = "user"
+)
+
+// Change actions
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDrop   = "drop"
+)
+
+// State is the custom roles and users a cluster has
+type State struct {
+	Roles []Role
+	Users []User
+}
+
+// Change creates, updates or drops one role or user. Exactly one of Role
+// and User is set.
+type Change struct {
+	Kind   string
+	Action string
+	Role   *Role
+	User   *User
+	Detail string // What an update changes
+}
+
+// ID returns the changed role's or user's name@db
+func (c *Change) ID() string {
+	if c.Role != nil {
+		return c.Role.ID()
+	}
+	return c.User.ID()
+}
+
+// DiffOptions controls Diff
+type DiffOptions struct {
+	AllowDrop bool     // Drop roles and users missing from the spec
+	Reserved  []string // name@db of users the spec must not manage
+}
+
+// DiffResult is the changes bringing a cluster's roles and users in line
+// with a spec
+type DiffResult struct {
+	Changes []Change
+	// Unmanaged lists roles and users missing from the spec that are kept
+	// because drops were not allowed
+	Unmanaged []string
+}
+
+// Diff compares spec with current. Roles are created and updated before
+// users, which may be granted them, and users are dropped before roles.
+func Diff(spec *Spec, current *State, opts DiffOptions) (*DiffResult, error) {
+	reserved := make(map[string]bool)
+	for _, id := range opts.Reserved {
+		reserved[id] = true
+	}
+	for i := range spec.Users {
+		if reserved[spec.Users[i].ID()] {
+			return nil, fmt.Errorf("user %s is managed by mup and cannot be in the users file", spec.Users[i].ID())
+		}
+	}
+
+	order, err := spec.roleOrder()
+	if err != nil {
+		return nil, err
+	}
+	result := &DiffResult{}
+
+	currentRoles := make(map[string]*Role)
+	for i := range current.Roles {
+		currentRoles[current.Roles[i].ID()] = &current.Roles[i]
+	}
+	for _, role := range order {
+		existing, ok := currentRoles[role.ID()]
+		if !ok {
+			result.Changes = append(result.Changes, Change{Kind: KindRole, Action: ActionCreate, Role: role})
+			continue
+		}
+		if detail := roleDiff(existing, role); detail != "" {
+			result.Changes = append(result.Changes, Change{Kind: KindRole, Action: ActionUpdate, Role: role, Detail: detail})
+		}
+	}
+
+	currentUsers := make(map[string]*User)
+	for i := range current.Users {
+		currentUsers[current.Users[i].ID()] = &current.Users[i]
+	}
+	users := make([]*User, 0, len(spec.Users))
+	for i := range spec.Users {
+		users = append(users, &spec.Users[i])
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID() < users[j].ID() })
+	for _, user := range users {
+		existing, ok := currentUsers[user.ID()]
+		if !ok {
+			result.Changes = append(result.Changes, Change{Kind: KindUser, Action: ActionCreate, User: user})
+			continue
+		}
+		if !sameRoleRefs(existing.Roles, user.Roles) {
+			detail := fmt.Sprintf("roles %s -> %s", FormatRoleRefs(existing.Roles), FormatRoleRefs(user.Roles))
+			result.Changes = append(result.Changes, Change{Kind: KindUser, Action: ActionUpdate, User: user, Detail: detail})
+		}
+	}
+
+	// Drops, in name order
+	wantedUsers := make(map[string]bool)
+	for _, user := range users {
+		wantedUsers[user.ID()] = true
+	}
+	wantedRoles := make(map[string]bool)
+	for _, role := range order {
+		wantedRoles[role.ID()] = true
+	}
+	var drops []Change
+	for i := range current.Users {
+		user := &current.Users[i]
+		if !wantedUsers[user.ID()] && !reserved[user.ID()] {
+			drops = append(drops, Change{Kind: KindUser, Action: ActionDrop, User: user})
+		}
+	}
+	for i := range current.Roles {
+		role := &current.Roles[i]
+		if !wantedRoles[role.ID()] {
+			drops = append(drops, Change{Kind: KindRole, Action: ActionDrop, Role: role})
+		}
+	}
+	sort.SliceStable(drops, func(i, j int) bool {
+		if drops[i].Kind != drops[j].Kind {
+			return drops[i].Kind == KindUser
+		}
+		return drops[i].ID() < drops[j].ID()
+	})
+	for _, drop := range drops {
+		if opts.AllowDrop {
+			result.Changes = append(result.Changes, drop)
+		} else {
+			result.Unmanaged = append(result.Unmanaged, drop.Kind+" "+drop.ID())
+		}
+	}
+
+	return result, nil
+}
+
+// roleDiff describes how want differs from have, or returns "" when they
+// grant the same
+func roleDiff(have, want *Role) string {
+	var details []string
+	if !reflect.DeepEqual(normalizePrivileges(have.Privileges), normalizePrivileges(want.Privileges)) {
+		details = append(details, "privileges")
+	}
+	if !sameRoleRefs(have.Roles, want.Roles) {
+		details = append(details, fmt.Sprintf("roles %s -> %s", FormatRoleRefs(have.Roles), FormatRoleRefs(want.Roles)))
+	}
+	return strings.Join(details, ", ")
+}
+
+// sameRoleRefs reports whether a and b grant the same roles in any order
+func sameRoleRefs(a, b []RoleRef) bool {
+	return FormatRoleRefs(a) == FormatRoleRefs(b)
+}
+
+// FormatRoleRefs lists refs sorted, as [role@db ...]
+func FormatRoleRefs(refs []RoleRef) string {
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, ref.String())
+	}
+	sort.Strings(names)
+	return "[" + strings.Join(names, " ") + "]"
+}
+
+// normalizePrivileges returns privileges as sorted "resource: actions"
+// strings, merging privileges on the same resource
+func normalizePrivileges(privileges []Privilege) []string {
+	actions := make(map[string]map[string]bool)
+	for _, privilege := range privileges {
+		key := privilege.Resource.String()
+		if actions[key] == nil {
+			actions[key] = make(map[string]bool)
+		}
+		for _, action := range privilege.Actions {
+			actions[key][action] = true
+		}
+	}
+	normalized := make([]string, 0, len(actions))
+	for resource, set := range actions {
+		names := make([]string, 0, len(set))
+		for action := range set {
+			names = append(names, action)
+		}
+		sort.Strings(names)
+		normalized = append(normalized, resource+": "+strings.Join(names, ","))
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// BSON returns the resource document MongoDB expects: {cluster: true}, or
+// {db, collection} with empty strings matching any
+func (r Resource) BSON() bson.D {
+	if r.Cluster {
+		return bson.D{{Key: "cluster", Value: true}}
+	}
+	return bson.D{{Key: "db", Value: r.DB}, {Key: "collection", Value: r.Collection}}
+}
+
+// PrivilegesBSON returns privileges as createRole and updateRole take them
+func PrivilegesBSON(privileges []Privilege) bson.A {
+	docs := bson.A{}
+	for _, privilege := range privileges {
+		docs = append(docs, bson.D{
+			{Key: "resource", Value: privilege.Resource.BSON()},
+			{Key: "actions", Value: privilege.Actions},
+		})
+	}
+	return docs
+}
+
+// RolesBSON returns granted roles as createUser and createRole take them
+func RolesBSON(refs []RoleRef) bson.A {
+	docs := bson.A{}
+	for _, ref := range refs {
+		docs = append(docs, bson.D{{Key: "role", Value: ref.Role}, {Key: "db", Value: ref.DB}})
+	}
+	return docs
+}
@@ -0,0 +1,84 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	spec := &Spec{
+		Roles: []Role{
+			{Name: "appReader", DB: "admin", Privileges: []Privilege{
+				{Resource: Resource{DB: "app"}, Actions: []string{"listCollections", "find"}},
+			}},
+			{Name: "newRole", DB: "admin", Roles: []RoleRef{{Role: "read", DB: "app"}}},
+		},
+		Users: []User{
+			{Name: "app", DB: "app", Roles: []RoleRef{{Role: "readWrite", DB: "app"}}},
+			{Name: "report", DB: "app", Roles: []RoleRef{{Role: "read", DB: "app"}, {Role: "appReader", DB: "admin"}}},
+			{Name: "new", DB: "app", Roles: []RoleRef{{Role: "read", DB: "app"}}},
+		},
+	}
+	current := &State{
+		Roles: []Role{
+			// Same privileges in another order
+			{Name: "appReader", DB: "admin", Privileges: []Privilege{
+				{Resource: Resource{DB: "app"}, Actions: []string{"find", "listCollections"}},
+			}},
+			{Name: "legacy", DB: "admin", Roles: []RoleRef{{Role: "read", DB: "old"}}},
+		},
+		Users: []User{
+			{Name: "admin", DB: "admin", Roles: []RoleRef{{Role: "root", DB: "admin"}}},
+			{Name: "app", DB: "app", Roles: []RoleRef{{Role: "readWrite", DB: "app"}}},
+			{Name: "report", DB: "app", Roles: []RoleRef{{Role: "read", DB: "app"}}},
+			{Name: "old", DB: "app", Roles: []RoleRef{{Role: "read", DB: "old"}}},
+		},
+	}
+	opts := DiffOptions{Reserved: []string{"admin@admin"}}
+
+	result, err := Diff(spec, current, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"create role newRole@admin", "create user new@app", "update user report@app"}, describe(result.Changes))
+	assert.Equal(t, "roles [read@app] -> [appReader@admin read@app]", result.Changes[2].Detail)
+	assert.Equal(t, []string{"user old@app", "role legacy@admin"}, result.Unmanaged, "drops need AllowDrop")
+
+	opts.AllowDrop = true
+	result, err = Diff(spec, current, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"create role newRole@admin", "create user new@app", "update user report@app",
+		"drop user old@app", "drop role legacy@admin",
+	}, describe(result.Changes), "the reserved admin user is never dropped")
+	assert.Empty(t, result.Unmanaged)
+}
+
+func TestDiff_RoleUpdate(t *testing.T) {
+	spec := &Spec{Roles: []Role{{Name: "ops", DB: "admin", Privileges: []Privilege{
+		{Resource: Resource{Cluster: true}, Actions: []string{"serverStatus", "top"}},
+	}}}}
+	current := &State{Roles: []Role{{Name: "ops", DB: "admin", Privileges: []Privilege{
+		{Resource: Resource{Cluster: true}, Actions: []string{"serverStatus"}},
+	}}}}
+
+	result, err := Diff(spec, current, DiffOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Changes, 1)
+	assert.Equal(t, ActionUpdate, result.Changes[0].Action)
+	assert.Equal(t, "privileges", result.Changes[0].Detail)
+}
+
+func TestDiff_ReservedUser(t *testing.T) {
+	spec := &Spec{Users: []User{{Name: "admin", DB: "admin", Roles: []RoleRef{{Role: "read", DB: "app"}}}}}
+	_, err := Diff(spec, &State{}, DiffOptions{Reserved: []string{"admin@admin"}})
+	assert.ErrorContains(t, err, "managed by mup")
+}
+
+func describe(changes []Change) []string {
+	var out []string
+	for _, change := range changes {
+		out = append(out, change.Action+" "+change.Kind+" "+change.ID())
+	}
+	return out
+}
@@ -0,0 +1,224 @@
+// Package access describes database users and custom roles declaratively
+// and computes the changes that bring a cluster in line with a description.
+package access
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExternalDB is the database of users authenticated outside MongoDB, such
+// as x509 or LDAP users, which have no password
+const ExternalDB = "$external"
+
+// Spec is the desired set of custom roles and users of a cluster
+type Spec struct {
+	Roles []Role `yaml:"roles,omitempty"`
+	Users []User `yaml:"users,omitempty"`
+}
+
+// Role is a custom role
+type Role struct {
+	Name       string      `yaml:"name" json:"name" bson:"role"`
+	DB         string      `yaml:"db" json:"db" bson:"db"`
+	Privileges []Privilege `yaml:"privileges,omitempty" json:"privileges,omitempty" bson:"privileges"`
+	Roles      []RoleRef   `yaml:"roles,omitempty" json:"roles,omitempty" bson:"roles"` // Inherited roles
+}
+
+// User is a database user. Password names the credential store entry
+// holding the password; without it the entry is the user's name@db and a
+// password is generated when the user is created.
+type User struct {
+	Name     string    `yaml:"name" json:"name" bson:"user"`
+	DB       string    `yaml:"db" json:"db" bson:"db"`
+	Roles    []RoleRef `yaml:"roles" json:"roles" bson:"roles"`
+	Password string    `yaml:"password,omitempty" json:"password,omitempty" bson:"-"`
+}
+
+// RoleRef grants a built-in or custom role
+type RoleRef struct {
+	Role string `yaml:"role" json:"role" bson:"role"`
+	DB   string `yaml:"db" json:"db" bson:"db"`
+}
+
+// Privilege allows actions on a resource
+type Privilege struct {
+	Resource Resource `yaml:"resource" json:"resource" bson:"resource"`
+	Actions  []string `yaml:"actions" json:"actions" bson:"actions"`
+}
+
+// Resource is a privilege's target: the cluster, or a collection where an
+// empty DB or Collection matches any
+type Resource struct {
+	Cluster    bool   `yaml:"cluster,omitempty" json:"cluster,omitempty" bson:"cluster,omitempty"`
+	DB         string `yaml:"db,omitempty" json:"db,omitempty" bson:"db"`
+	Collection string `yaml:"collection,omitempty" json:"collection,omitempty" bson:"collection"`
+}
+
+// ID returns the role's name@db
+func (r *Role) ID() string {
+	return r.Name + "@" + r.DB
+}
+
+// ID returns the user's name@db
+func (u *User) ID() string {
+	return u.Name + "@" + u.DB
+}
+
+// CredentialName returns the credential store entry of the user's password
+func (u *User) CredentialName() string {
+	if u.Password != "" {
+		return u.Password
+	}
+	return u.ID()
+}
+
+// String returns role@db
+func (r RoleRef) String() string {
+	return r.Role + "@" + r.DB
+}
+
+// String describes the resource
+func (r Resource) String() string {
+	if r.Cluster {
+		return "cluster"
+	}
+	db, coll := r.DB, r.Collection
+	if db == "" {
+		db = "*"
+	}
+	if coll == "" {
+		coll = "*"
+	}
+	return db + "." + coll
+}
+
+// LoadSpec reads a users and roles file, rejecting unknown fields
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var spec Spec
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+// Validate checks names are complete and unique and that custom roles do
+// not inherit from each other in a cycle
+func (s *Spec) Validate() error {
+	roles := make(map[string]bool)
+	for i := range s.Roles {
+		role := &s.Roles[i]
+		if role.Name == "" || role.DB == "" {
+			return fmt.Errorf("role %d needs a name and db", i+1)
+		}
+		if roles[role.ID()] {
+			return fmt.Errorf("role %s is defined twice", role.ID())
+		}
+		roles[role.ID()] = true
+		if len(role.Privileges) == 0 && len(role.Roles) == 0 {
+			return fmt.Errorf("role %s grants no privileges or roles", role.ID())
+		}
+		for _, privilege := range role.Privileges {
+			if len(privilege.Actions) == 0 {
+				return fmt.Errorf("role %s has a privilege on %s without actions", role.ID(), privilege.Resource)
+			}
+			if privilege.Resource.Cluster && (privilege.Resource.DB != "" || privilege.Resource.Collection != "") {
+				return fmt.Errorf("role %s has a cluster privilege with a db or collection", role.ID())
+			}
+		}
+		if err := validateRoleRefs(role.ID(), role.Roles); err != nil {
+			return err
+		}
+	}
+	if _, err := s.roleOrder(); err != nil {
+		return err
+	}
+
+	users := make(map[string]bool)
+	for i := range s.Users {
+		user := &s.Users[i]
+		if user.Name == "" || user.DB == "" {
+			return fmt.Errorf("user %d needs a name and db", i+1)
+		}
+		if users[user.ID()] {
+			return fmt.Errorf("user %s is defined twice", user.ID())
+		}
+		users[user.ID()] = true
+		if user.DB == ExternalDB && user.Password != "" {
+			return fmt.Errorf("user %s is authenticated externally and takes no password", user.ID())
+		}
+		if err := validateRoleRefs(user.ID(), user.Roles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRoleRefs checks the roles granted to owner name a role and db
+func validateRoleRefs(owner string, refs []RoleRef) error {
+	for _, ref := range refs {
+		if ref.Role == "" || ref.DB == "" {
+			return fmt.Errorf("%s grants a role without a role name and db", owner)
+		}
+	}
+	return nil
+}
+
+// roleOrder returns the spec's roles with every custom role after the
+// custom roles it inherits, so each can be created once its parents exist
+func (s *Spec) roleOrder() ([]*Role, error) {
+	byID := make(map[string]*Role)
+	ids := make([]string, 0, len(s.Roles))
+	for i := range s.Roles {
+		byID[s.Roles[i].ID()] = &s.Roles[i]
+		ids = append(ids, s.Roles[i].ID())
+	}
+	sort.Strings(ids)
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var order []*Role
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("role %s inherits itself", id)
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		for _, ref := range byID[id].Roles {
+			if _, ok := byID[ref.String()]; ok {
+				if err := visit(ref.String()); err != nil {
+					return err
+				}
+			}
+		}
+		state[id] = done
+		order = append(order, byID[id])
+		return nil
+	}
+	for _, id := range ids {
+		if err := visit(id); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,86 @@
+package access
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const usersFile = `roles:
+  - name: appReader
+    db: admin
+    privileges:
+      - resource: {db: app, collection: ""}
+        actions: [find, listCollections]
+  - name: appOps
+    db: admin
+    privileges:
+      - resource: {cluster: true}
+        actions: [serverStatus]
+    roles:
+      - {role: appReader, db: admin}
+users:
+  - name: app
+    db: app
+    roles:
+      - {role: readWrite, db: app}
+    password: app-prod
+  - name: "CN=reporting"
+    db: $external
+    roles:
+      - {role: appReader, db: admin}
+`
+
+func TestLoadSpec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(usersFile), 0644))
+
+	spec, err := LoadSpec(path)
+	require.NoError(t, err)
+	require.Len(t, spec.Roles, 2)
+	require.Len(t, spec.Users, 2)
+	assert.Equal(t, "app-prod", spec.Users[0].CredentialName())
+	assert.Equal(t, "CN=reporting@$external", spec.Users[1].CredentialName())
+	assert.True(t, spec.Roles[1].Privileges[0].Resource.Cluster)
+
+	order, err := spec.roleOrder()
+	require.NoError(t, err)
+	assert.Equal(t, "appReader@admin", order[0].ID(), "inherited roles come first")
+	assert.Equal(t, "appOps@admin", order[1].ID())
+}
+
+func TestLoadSpec_UnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("users:\n  - name: app\n    database: app\n"), 0644))
+
+	_, err := LoadSpec(path)
+	assert.ErrorContains(t, err, "database")
+}
+
+func TestSpec_Validate(t *testing.T) {
+	read := []RoleRef{{Role: "read", DB: "app"}}
+	tests := []struct {
+		name string
+		spec Spec
+		err  string
+	}{
+		{"user without db", Spec{Users: []User{{Name: "app", Roles: read}}}, "needs a name and db"},
+		{"duplicate user", Spec{Users: []User{{Name: "app", DB: "app"}, {Name: "app", DB: "app"}}}, "defined twice"},
+		{"external password", Spec{Users: []User{{Name: "CN=x", DB: ExternalDB, Password: "x"}}}, "takes no password"},
+		{"empty role", Spec{Roles: []Role{{Name: "r", DB: "admin"}}}, "grants no privileges"},
+		{"privilege without actions", Spec{Roles: []Role{{Name: "r", DB: "admin", Privileges: []Privilege{{}}}}}, "without actions"},
+		{"incomplete role ref", Spec{Users: []User{{Name: "app", DB: "app", Roles: []RoleRef{{Role: "read"}}}}}, "without a role name and db"},
+		{"inheritance cycle", Spec{Roles: []Role{
+			{Name: "a", DB: "admin", Roles: []RoleRef{{Role: "b", DB: "admin"}}},
+			{Name: "b", DB: "admin", Roles: []RoleRef{{Role: "a", DB: "admin"}}},
+		}}, "inherits itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.spec.Validate(), tt.err)
+		})
+	}
+}
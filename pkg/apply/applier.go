@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/zph/mup/pkg/meta"
 	"github.com/zph/mup/pkg/plan"
 )
 
@@ -38,6 +39,9 @@ type DefaultApplier struct {
 	hooks        *HookManager
 	checkpointer *Checkpointer
 	paused       bool
+
+	// allowProtected lets destructive operations run on a protected cluster
+	allowProtected bool
 }
 
 // NewDefaultApplier creates a new default applier
@@ -51,8 +55,39 @@ func NewDefaultApplier(executor OperationExecutor, stateManager *StateManager) *
 	}
 }
 
+// AllowProtected lets the applier run destructive operations on a protected
+// cluster, once the operator confirmed the cluster's name
+func (a *DefaultApplier) AllowProtected() {
+	a.allowProtected = true
+}
+
+// checkProtected refuses plans that stop processes, remove directories or
+// drop users and roles of a protected cluster, unless AllowProtected was
+// called
+func (a *DefaultApplier) checkProtected(p *plan.Plan) error {
+	if a.allowProtected || !plan.HasDestructiveOperations(p) {
+		return nil
+	}
+	metaMgr, err := meta.NewManager()
+	if err != nil {
+		return err
+	}
+	protected, err := metaMgr.IsProtected(p.ClusterName)
+	if err != nil {
+		return err
+	}
+	if result := plan.ValidateProtected(p, protected); !result.Valid {
+		return plan.NewValidationError(result.Errors)
+	}
+	return nil
+}
+
 // Apply executes the plan
 func (a *DefaultApplier) Apply(ctx context.Context, p *plan.Plan) (*ApplyState, error) {
+	if err := a.checkProtected(p); err != nil {
+		return nil, err
+	}
+
 	// Create new apply state
 	state := NewApplyState(p.PlanID, p.ClusterName, p.Operation)
 	state.UpdateStatus(StatusRunning)
@@ -245,6 +280,12 @@ func (a *DefaultApplier) executeParallel(ctx context.Context, operations []plan.
 
 // executeOperation executes a single operation
 func (a *DefaultApplier) executeOperation(ctx context.Context, op *plan.PlannedOperation, _ *plan.Plan, state *ApplyState) error {
+	// Operations completed before a resume are not repeated
+	if state.OperationCompleted(op.ID) {
+		state.Log("info", state.CurrentPhase, op.ID, fmt.Sprintf("Skipping completed: %s", op.Description))
+		return nil
+	}
+
 	state.StartOperation(op.ID)
 	state.Log("info", state.CurrentPhase, op.ID, fmt.Sprintf("Executing: %s", op.Description))
 
@@ -286,17 +327,20 @@ func (a *DefaultApplier) Resume(ctx context.Context, state *ApplyState) (*ApplyS
 		return nil, fmt.Errorf("cannot resume apply in status: %s", state.Status)
 	}
 
-	state.UpdateStatus(StatusRunning)
-	if err := a.stateManager.SaveState(state); err != nil {
-		return nil, fmt.Errorf("failed to save resumed state: %w", err)
-	}
-
 	// Load the original plan
 	planPath := a.stateManager.GetPlanPath(state.PlanID)
 	p, err := plan.LoadFromFile(planPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load plan: %w", err)
 	}
+	if err := a.checkProtected(p); err != nil {
+		return nil, err
+	}
+
+	state.UpdateStatus(StatusRunning)
+	if err := a.stateManager.SaveState(state); err != nil {
+		return nil, fmt.Errorf("failed to save resumed state: %w", err)
+	}
 
 	// Find where we left off and continue
 	currentPhaseIndex := a.findPhaseIndex(p, state.CurrentPhase)
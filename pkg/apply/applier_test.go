@@ -0,0 +1,92 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/plan"
+)
+
+// recordingExecutor records executed operations and fails the configured one once
+type recordingExecutor struct {
+	executed []string
+	failOnce string
+}
+
+func (e *recordingExecutor) Execute(ctx context.Context, op *plan.PlannedOperation) (*OperationResult, error) {
+	if op.ID == e.failOnce {
+		e.failOnce = ""
+		return nil, errors.New("node unreachable")
+	}
+	e.executed = append(e.executed, op.ID)
+	return &OperationResult{Success: true}, nil
+}
+
+func (e *recordingExecutor) Validate(ctx context.Context, op *plan.PlannedOperation) error {
+	return nil
+}
+
+func TestDefaultApplier_ResumeSkipsCompletedOperations(t *testing.T) {
+	clusterDir := t.TempDir()
+	p := &plan.Plan{
+		PlanID:      "plan-123",
+		Operation:   "enable-auth",
+		ClusterName: "test-cluster",
+		Phases: []plan.PlannedPhase{
+			{Name: "prepare", Operations: []plan.PlannedOperation{{ID: "op-001"}}},
+			{Name: "transition", Operations: []plan.PlannedOperation{{ID: "op-002"}, {ID: "op-003"}, {ID: "op-004"}}},
+		},
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(clusterDir, "plans"), 0755))
+	require.NoError(t, p.SaveToFile(filepath.Join(clusterDir, "plans", "plan-123.json")))
+
+	executor := &recordingExecutor{failOnce: "op-003"}
+	applier := NewDefaultApplier(executor, NewStateManager(clusterDir))
+
+	state, err := applier.Apply(context.Background(), p)
+	require.Error(t, err)
+	assert.Equal(t, StatusFailed, state.Status)
+	assert.Equal(t, []string{"op-001", "op-002"}, executor.executed)
+
+	state, err = applier.Resume(context.Background(), state)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, state.Status)
+	assert.Equal(t, []string{"op-001", "op-002", "op-003", "op-004"}, executor.executed)
+}
+
+func TestDefaultApplier_RefusesDestructivePlansOfProtectedClusters(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	metaMgr, err := meta.NewManager()
+	require.NoError(t, err)
+	require.NoError(t, metaMgr.Save(&meta.ClusterMetadata{Name: "prod", Protected: true}))
+
+	p := &plan.Plan{
+		PlanID:      "plan-456",
+		Operation:   "users",
+		ClusterName: "prod",
+		Phases: []plan.PlannedPhase{{Name: "drop", Operations: []plan.PlannedOperation{{
+			ID:      "drop-001",
+			Type:    plan.OpManageDBUser,
+			Changes: []plan.Change{{ResourceType: "db_user", ResourceID: "old@admin", Action: plan.ActionDelete}},
+		}}}},
+	}
+
+	executor := &recordingExecutor{}
+	applier := NewDefaultApplier(executor, NewStateManager(t.TempDir()))
+	_, err = applier.Apply(context.Background(), p)
+	assert.True(t, plan.IsValidationError(err))
+	assert.ErrorContains(t, err, "cluster prod is protected")
+	assert.Empty(t, executor.executed)
+
+	applier.AllowProtected()
+	state, err := applier.Apply(context.Background(), p)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, state.Status)
+	assert.Equal(t, []string{"drop-001"}, executor.executed)
+}
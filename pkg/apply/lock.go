@@ -73,7 +73,7 @@ func (m *LockManager) AcquireLock(clusterName, planID, operation string, timeout
 		ClusterName: clusterName,
 		PlanID:      planID,
 		Operation:   operation,
-		LockedBy:    getLockedByIdentifier(),
+		LockedBy:    Identity(),
 		LockedAt:    time.Now(),
 		ExpiresAt:   time.Now().Add(timeout),
 		LockTimeout: timeout.String(),
@@ -304,9 +304,10 @@ func (m *LockManager) saveLock(lock *ClusterLock) error {
 	return nil
 }
 
-// getLockedByIdentifier returns a string identifying the current process
+// Identity returns a string identifying the current process, as recorded
+// in locks and the audit trail
 // Format: "user@hostname:pid"
-func getLockedByIdentifier() string {
+func Identity() string {
 	hostname, _ := os.Hostname()
 	user := os.Getenv("USER")
 	if user == "" {
@@ -218,6 +218,15 @@ func (s *ApplyState) CompleteOperation(operationID string, result *OperationResu
 	s.UpdatedAt = now
 }
 
+// OperationCompleted returns whether an operation has already completed
+func (s *ApplyState) OperationCompleted(operationID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.OperationStates[operationID]
+	return ok && state.Status == StatusCompleted
+}
+
 // FailOperation marks an operation as failed
 func (s *ApplyState) FailOperation(operationID string, err error, recoverable bool) {
 	s.mu.Lock()
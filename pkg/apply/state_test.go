@@ -84,6 +84,10 @@ func TestApplyState_OperationLifecycle(t *testing.T) {
 	assert.Equal(t, StatusFailed, state.OperationStates["op-002"].Status)
 	assert.Len(t, state.Errors, 1)
 	assert.True(t, state.Errors[0].Recoverable)
+
+	assert.True(t, state.OperationCompleted("op-001"))
+	assert.False(t, state.OperationCompleted("op-002"))
+	assert.False(t, state.OperationCompleted("op-003"))
 }
 
 func TestApplyState_Checkpoints(t *testing.T) {
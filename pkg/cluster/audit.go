@@ -0,0 +1,224 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v3"
+
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/lint"
+	"github.com/zph/mup/pkg/meta"
+)
+
+// auditTimeout bounds reading one node's running options
+const auditTimeout = 10 * time.Second
+
+// Audit checks a cluster's security: its generated configs, meta.yaml and
+// the options its nodes run with. Nodes that cannot be queried are checked
+// against their generated config.
+func (m *Manager) Audit(ctx context.Context, clusterName string) (*lint.Report, error) {
+	metadata, err := m.metaMgr.Load(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	executors, err := m.createExecutors(metadata)
+	if err != nil {
+		return nil, err
+	}
+	defer m.closeExecutors(executors)
+
+	return lint.Run(auditInput(ctx, metadata, executors), time.Now()), nil
+}
+
+// auditInput gathers what the checks look at
+func auditInput(ctx context.Context, metadata *meta.ClusterMetadata, executors map[string]executor.Executor) *lint.Input {
+	in := &lint.Input{Cluster: metadata.Name, Version: metadata.Version}
+	keyFiles := make(map[string]bool)
+
+	for i := range metadata.Nodes {
+		node := &metadata.Nodes[i]
+		exec := executors[node.Host]
+		auditNode := lint.Node{ID: node.ID(), Type: node.Type}
+
+		if exec != nil && node.ConfigFile != "" {
+			auditNode.Config = readNodeConfig(ctx, exec, node.ConfigFile)
+		}
+		live, err := readCmdLineOpts(ctx, metadata, node)
+		if err != nil {
+			auditNode.LiveError = err.Error()
+		} else {
+			auditNode.Live = live
+		}
+		in.Nodes = append(in.Nodes, auditNode)
+
+		keyFile := documentString(auditNode.Live, "security", "keyFile")
+		if keyFile == "" {
+			keyFile = documentString(auditNode.Config, "security", "keyFile")
+		}
+		if exec == nil || keyFile == "" || keyFiles[node.Host+":"+keyFile] {
+			continue
+		}
+		keyFiles[node.Host+":"+keyFile] = true
+		if mode, err := fileMode(ctx, exec, keyFile); err == nil {
+			in.KeyFiles = append(in.KeyFiles, lint.KeyFile{Host: node.Host, Path: keyFile, Mode: mode})
+		}
+	}
+
+	// The keyFile mup keeps for the cluster
+	if sec := metadata.Security; sec != nil && sec.KeyFile != "" && !keyFiles["localhost:"+sec.KeyFile] {
+		if info, err := os.Stat(sec.KeyFile); err == nil {
+			in.KeyFiles = append(in.KeyFiles, lint.KeyFile{Host: "localhost", Path: sec.KeyFile, Mode: info.Mode()})
+		}
+	}
+
+	if sec := metadata.Security; sec != nil && sec.Auth {
+		in.AdminUser = sec.AdminUser
+		if password, err := sec.AdminPassword(); err == nil {
+			in.AdminPassword = password
+		}
+		if sec.PasswordFile != "" {
+			if _, err := os.Stat(sec.PasswordFile); err == nil {
+				in.PlaintextPasswordFile = sec.PasswordFile
+			}
+		}
+	}
+
+	in.Monitoring = monitoringEndpoints(metadata.Monitoring)
+	return in
+}
+
+// readNodeConfig reads a node's generated config file, or returns nil
+func readNodeConfig(ctx context.Context, exec executor.Executor, path string) map[string]interface{} {
+	content, err := exec.ExecuteContext(ctx, "cat "+executor.ShellQuote(path))
+	if err != nil {
+		return nil
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+// readCmdLineOpts returns the options a node runs with, as getCmdLineOpts
+// parsed them
+func readCmdLineOpts(ctx context.Context, metadata *meta.ClusterMetadata, node *meta.NodeMetadata) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, auditTimeout)
+	defer cancel()
+
+	client, err := connectNode(ctx, metadata, node, auditTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+
+	raw, err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "getCmdLineOpts", Value: 1}}).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run getCmdLineOpts: %w", err)
+	}
+	parsed, err := raw.LookupErr("parsed")
+	if err != nil {
+		return nil, fmt.Errorf("getCmdLineOpts returned no parsed options: %w", err)
+	}
+
+	// Round trip through JSON so nested documents are plain maps
+	data, err := bson.MarshalExtJSON(parsed.Document(), false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode getCmdLineOpts: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode getCmdLineOpts: %w", err)
+	}
+	return doc, nil
+}
+
+// connectNode connects to node alone, as the cluster's security requires
+func connectNode(ctx context.Context, metadata *meta.ClusterMetadata, node *meta.NodeMetadata, timeout time.Duration) (*mongo.Client, error) {
+	opts := options.Client().
+		ApplyURI("mongodb://" + node.Address()).
+		SetConnectTimeout(timeout).
+		SetServerSelectionTimeout(timeout).
+		SetDirect(true)
+	if err := metadata.Security.ApplyTLS(opts); err != nil {
+		return nil, err
+	}
+	if err := metadata.Security.ApplyAuth(opts); err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", node.Address(), err)
+	}
+	return client, nil
+}
+
+// documentString returns the string at keys in a nested config document
+func documentString(doc map[string]interface{}, keys ...string) string {
+	var value interface{} = doc
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[key]
+	}
+	s, _ := value.(string)
+	return s
+}
+
+// fileMode returns a file's permission bits on exec's host
+func fileMode(ctx context.Context, exec executor.Executor, path string) (os.FileMode, error) {
+	quoted := executor.ShellQuote(path)
+	// GNU stat, then BSD stat
+	output, err := exec.ExecuteContext(ctx, fmt.Sprintf("stat -c %%a %s 2>/dev/null || stat -f %%Lp %s", quoted, quoted))
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	mode, err := strconv.ParseUint(strings.TrimSpace(output), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse mode of %s: %w", path, err)
+	}
+	return os.FileMode(mode), nil
+}
+
+// monitoringEndpoints returns where the monitoring components listen.
+// Exporters on a loopback host listen on all interfaces so Victoria Metrics
+// in Docker can reach them.
+func monitoringEndpoints(monitoring *meta.MonitoringMetadata) []lint.Endpoint {
+	if monitoring == nil || !monitoring.Enabled {
+		return nil
+	}
+	listen := func(host string, port int) string {
+		if host == "localhost" || net.ParseIP(host).IsLoopback() {
+			host = "0.0.0.0"
+		}
+		return net.JoinHostPort(host, strconv.Itoa(port))
+	}
+
+	var endpoints []lint.Endpoint
+	for _, exporter := range monitoring.NodeExporters {
+		endpoints = append(endpoints, lint.Endpoint{Name: "node_exporter", Address: listen(exporter.Host, exporter.Port)})
+	}
+	for _, exporter := range monitoring.MongoDBExporters {
+		endpoints = append(endpoints, lint.Endpoint{Name: "mongodb_exporter", Address: listen(exporter.Host, exporter.ExporterPort)})
+	}
+	if monitoring.GrafanaURL != "" {
+		endpoints = append(endpoints, lint.Endpoint{Name: "grafana", Address: monitoring.GrafanaURL})
+	}
+	if monitoring.VictoriaMetricsURL != "" {
+		endpoints = append(endpoints, lint.Endpoint{Name: "victoriametrics", Address: monitoring.VictoriaMetricsURL})
+	}
+	return endpoints
+}
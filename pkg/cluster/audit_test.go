@@ -0,0 +1,58 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/lint"
+	"github.com/zph/mup/pkg/meta"
+)
+
+func TestReadNodeConfig(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "mongod.conf")
+	keyFile := filepath.Join(dir, "keyfile")
+	require.NoError(t, os.WriteFile(configFile, []byte("net:\n  bindIp: 0.0.0.0\nsecurity:\n  keyFile: "+keyFile+"\n"), 0644))
+	require.NoError(t, os.WriteFile(keyFile, []byte("key"), 0644))
+	exec := executor.NewLocalExecutor()
+	ctx := context.Background()
+
+	doc := readNodeConfig(ctx, exec, configFile)
+	require.NotNil(t, doc)
+	assert.Equal(t, keyFile, documentString(doc, "security", "keyFile"))
+	assert.Empty(t, documentString(doc, "net", "tls", "mode"))
+	assert.Nil(t, readNodeConfig(ctx, exec, filepath.Join(dir, "missing.conf")))
+
+	mode, err := fileMode(ctx, exec, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), mode)
+
+	// Cancelling the command stops the reads
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Nil(t, readNodeConfig(cancelled, exec, configFile))
+	_, err = fileMode(cancelled, exec, keyFile)
+	assert.Error(t, err)
+}
+
+func TestMonitoringEndpoints(t *testing.T) {
+	endpoints := monitoringEndpoints(&meta.MonitoringMetadata{
+		Enabled:          true,
+		GrafanaURL:       "http://localhost:3000",
+		NodeExporters:    []meta.NodeExporterMetadata{{Host: "localhost", Port: 9100}},
+		MongoDBExporters: []meta.MongoDBExporterMetadata{{Host: "10.0.0.5", ExporterPort: 9216}},
+	})
+
+	assert.Equal(t, []lint.Endpoint{
+		{Name: "node_exporter", Address: "0.0.0.0:9100"},
+		{Name: "mongodb_exporter", Address: "10.0.0.5:9216"},
+		{Name: "grafana", Address: "http://localhost:3000"},
+	}, endpoints)
+	assert.Nil(t, monitoringEndpoints(&meta.MonitoringMetadata{}))
+}
@@ -0,0 +1,174 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/topology"
+	"github.com/zph/mup/pkg/upgrade"
+)
+
+// backupConnectTimeout bounds connecting to a node; the backup itself is
+// bounded by the caller's context
+const backupConnectTimeout = 10 * time.Second
+
+// BackupOptions controls a hot backup
+type BackupOptions struct {
+	Dir string // Overrides the topology's percona.backup.dir
+}
+
+// backupTarget is the node a replica set, or a node outside any replica
+// set, is backed up from
+type backupTarget struct {
+	name string // Replica set name, or host-port
+	node *meta.NodeMetadata
+}
+
+// Backup takes a hot backup of a Percona Server for MongoDB cluster with
+// createBackup. Each replica set is backed up from a secondary when one is
+// healthy, so the primary keeps serving writes. Backups are written on the
+// nodes' hosts under <dir>/<UTC timestamp>/<replica set>.
+//
+// The backups of a sharded cluster's shards are not taken at one point in
+// time; stop the balancer first for a consistent set.
+func (m *Manager) Backup(ctx context.Context, clusterName string, opts BackupOptions) error {
+	metadata, err := m.metaMgr.Load(clusterName)
+	if err != nil {
+		return err
+	}
+	if metadata.Variant != "percona" {
+		return fmt.Errorf("cluster '%s' runs variant %s: hot backup requires Percona Server for MongoDB", clusterName, metadata.Variant)
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = metadata.Topology.BackupDir()
+	}
+	if dir == "" {
+		return fmt.Errorf("no backup directory: set percona.backup.dir in the topology or pass --dir")
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("backup directory %q must be an absolute path", dir)
+	}
+
+	members := make(map[string][]upgrade.ReplicaSetMember)
+	for _, group := range rotationGroups(metadata) {
+		if group.replicaSet == "" {
+			continue
+		}
+		var allHosts []string
+		for _, node := range group.nodes {
+			allHosts = append(allHosts, node.Address())
+		}
+		status, err := upgrade.GetReplicaSetStatus(ctx, group.replicaSet, allHosts, metadata.Security)
+		if err != nil {
+			return fmt.Errorf("failed to get status of replica set %s: %w", group.replicaSet, err)
+		}
+		members[group.replicaSet] = status
+	}
+	targets, err := backupTargets(metadata, members)
+	if err != nil {
+		return err
+	}
+
+	executors, err := m.createExecutors(metadata)
+	if err != nil {
+		return err
+	}
+	defer m.closeExecutors(executors)
+
+	backupDir := filepath.Join(dir, time.Now().UTC().Format("20060102T150405Z"))
+	fmt.Printf("Backing up cluster '%s' to %s\n", clusterName, backupDir)
+	for _, target := range targets {
+		dest := filepath.Join(backupDir, target.name)
+		fmt.Printf("  %s from %s ... ", target.name, target.node.ID())
+		if err := createBackup(ctx, metadata, executors[target.node.Host], target.node, dest); err != nil {
+			fmt.Println("failed")
+			return fmt.Errorf("backup of %s failed: %w", target.name, err)
+		}
+		fmt.Println("done")
+	}
+
+	fmt.Printf("\n✓ Backed up %d replica set(s) and node(s) to %s on the nodes' hosts\n", len(targets), backupDir)
+	return nil
+}
+
+// backupTargets picks the node to back up each replica set and standalone
+// node from: a healthy secondary, else the primary. Arbiters hold no data
+// and mongos routers store none.
+func backupTargets(metadata *meta.ClusterMetadata, members map[string][]upgrade.ReplicaSetMember) ([]backupTarget, error) {
+	var targets []backupTarget
+	for _, group := range rotationGroups(metadata) {
+		if group.replicaSet == "" {
+			node := group.nodes[0]
+			if node.Type == "mongos" {
+				continue
+			}
+			targets = append(targets, backupTarget{name: fmt.Sprintf("%s-%d", node.Host, node.Port), node: node})
+			continue
+		}
+
+		states := make(map[string]upgrade.ReplicaSetMember)
+		for _, member := range members[group.replicaSet] {
+			states[topology.GetNodeID(member.Host, member.Port)] = member
+		}
+		var secondary, primary *meta.NodeMetadata
+		for _, node := range group.nodes {
+			member, ok := states[node.Address()]
+			if !ok || member.Health != 1 {
+				continue
+			}
+			switch member.StateStr {
+			case "SECONDARY":
+				if secondary == nil {
+					secondary = node
+				}
+			case "PRIMARY":
+				primary = node
+			}
+		}
+
+		switch {
+		case secondary != nil:
+			targets = append(targets, backupTarget{name: group.replicaSet, node: secondary})
+		case primary != nil:
+			targets = append(targets, backupTarget{name: group.replicaSet, node: primary})
+		default:
+			return nil, fmt.Errorf("replica set %s has no healthy primary or secondary to back up from", group.replicaSet)
+		}
+	}
+	return targets, nil
+}
+
+// createBackup creates dest on node's host and runs createBackup into it
+func createBackup(ctx context.Context, metadata *meta.ClusterMetadata, exec executor.Executor, node *meta.NodeMetadata, dest string) error {
+	if exec == nil {
+		return fmt.Errorf("no executor for host %s", node.Host)
+	}
+	if _, err := exec.Execute("mkdir -p " + executor.ShellQuote(dest)); err != nil {
+		return fmt.Errorf("failed to create backup directory %s: %w", dest, err)
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, backupConnectTimeout)
+	client, err := connectNode(connectCtx, metadata, node, backupConnectTimeout)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+
+	cmd := bson.D{{Key: "createBackup", Value: 1}, {Key: "backupDir", Value: dest}}
+	if err := client.Database("admin").RunCommand(ctx, cmd).Err(); err != nil {
+		if strings.Contains(err.Error(), "no such command") {
+			return fmt.Errorf("%s does not support createBackup (is it Percona Server for MongoDB?): %w", node.ID(), err)
+		}
+		return fmt.Errorf("createBackup failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/upgrade"
+)
+
+func TestBackupTargets(t *testing.T) {
+	metadata := &meta.ClusterMetadata{
+		Nodes: []meta.NodeMetadata{
+			{Type: "mongos", Host: "app1", Port: 27017},
+			{Type: "config", Host: "cfg1", Port: 27019, ReplicaSet: "configRS"},
+			{Type: "mongod", Host: "db1", Port: 27018, ReplicaSet: "rs0"},
+			{Type: "mongod", Host: "db2", Port: 27018, ReplicaSet: "rs0"},
+			{Type: "mongod", Host: "db3", Port: 27018, ReplicaSet: "rs0"},
+			{Type: "mongod", Host: "db4", Port: 27017},
+		},
+	}
+	members := map[string][]upgrade.ReplicaSetMember{
+		"configRS": {{Host: "cfg1", Port: 27019, StateStr: "PRIMARY", Health: 1}},
+		"rs0": {
+			{Host: "db1", Port: 27018, StateStr: "PRIMARY", Health: 1},
+			{Host: "db2", Port: 27018, StateStr: "ARBITER", Health: 1},
+			{Host: "db3", Port: 27018, StateStr: "SECONDARY", Health: 1},
+		},
+	}
+
+	targets, err := backupTargets(metadata, members)
+	require.NoError(t, err)
+	var got []string
+	for _, target := range targets {
+		got = append(got, target.name+"="+target.node.ID())
+	}
+	assert.Equal(t, []string{
+		"configRS=cfg1:27019",
+		"rs0=db3:27018",
+		"db4-27017=db4:27017",
+	}, got, "secondaries over the primary, no arbiters or mongos")
+
+	members["rs0"][2].Health = 0
+	targets, err = backupTargets(metadata, members)
+	require.NoError(t, err)
+	assert.Equal(t, "db1:27018", targets[1].node.ID(), "the primary when no secondary is healthy")
+
+	members["rs0"] = nil
+	_, err = backupTargets(metadata, members)
+	assert.EqualError(t, err, "replica set rs0 has no healthy primary or secondary to back up from")
+}
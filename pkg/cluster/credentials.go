@@ -0,0 +1,303 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/security"
+)
+
+// credentialsTimeout bounds logging in to verify or change a password
+const credentialsTimeout = 30 * time.Second
+
+// SetCredentialsOptions controls storing a password
+type SetCredentialsOptions struct {
+	User     string // Defaults to the cluster's admin user
+	Password string
+	NoVerify bool // Store the password without logging in with it
+}
+
+// CredentialsInfo describes a cluster's credential store
+type CredentialsInfo struct {
+	File      string
+	KeySource string
+	Users     []CredentialInfo
+}
+
+// CredentialInfo describes one stored credential. Password is only set
+// when the caller asked to reveal it.
+type CredentialInfo struct {
+	User      string
+	UpdatedAt time.Time
+	Password  string
+}
+
+// SetCredentials stores opts.Password in the cluster's encrypted credential
+// store. The plaintext admin password file is removed once the admin
+// password is in the store.
+func (m *Manager) SetCredentials(ctx context.Context, clusterName string, opts SetCredentialsOptions) error {
+	metadata, err := m.loadAuthMetadata(clusterName)
+	if err != nil {
+		return err
+	}
+	if opts.Password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+	user := opts.User
+	if user == "" {
+		user = metadata.Security.AdminUser
+	}
+
+	if !opts.NoVerify {
+		if err := verifyLogin(ctx, metadata, user, opts.Password); err != nil {
+			return fmt.Errorf("failed to log in as %s with the new password (use --no-verify to store it anyway): %w", user, err)
+		}
+	}
+
+	keys, err := security.DefaultKeySource()
+	if err != nil {
+		return err
+	}
+	return m.storeCredential(clusterName, metadata, keys, user, opts.Password)
+}
+
+// ShowCredentials describes the cluster's credential store, including the
+// passwords when reveal is set
+func (m *Manager) ShowCredentials(clusterName string, reveal bool) (*CredentialsInfo, error) {
+	metadata, err := m.loadAuthMetadata(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	file := m.credentialsFile(clusterName, metadata)
+	if _, err := os.Stat(file); err != nil {
+		return nil, fmt.Errorf("cluster '%s' has no credential store: run 'mup cluster credentials set %s'", clusterName, clusterName)
+	}
+
+	keys, err := security.DefaultKeySource()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := security.LoadCredentials(file, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &CredentialsInfo{File: file, KeySource: creds.KeySource}
+	for _, user := range creds.UserNames() {
+		stored := creds.Users[user]
+		entry := CredentialInfo{User: user, UpdatedAt: stored.UpdatedAt}
+		if reveal {
+			entry.Password = stored.Password
+		}
+		info.Users = append(info.Users, entry)
+	}
+	return info, nil
+}
+
+// RotateCredentials replaces user's password with a generated one, logging
+// in as the admin user to change it. The store is written before the
+// cluster changes and restored if the change fails, so the password in use
+// is never lost.
+func (m *Manager) RotateCredentials(ctx context.Context, clusterName, user string) error {
+	metadata, err := m.loadAuthMetadata(clusterName)
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		user = metadata.Security.AdminUser
+	}
+
+	// The current admin password logs in to make the change
+	adminPassword, err := metadata.Security.AdminPassword()
+	if err != nil {
+		return err
+	}
+	password, err := security.GeneratePassword()
+	if err != nil {
+		return err
+	}
+
+	keys, err := security.DefaultKeySource()
+	if err != nil {
+		return err
+	}
+	file := m.credentialsFile(clusterName, metadata)
+	_, statErr := os.Stat(file)
+	hadStore := statErr == nil
+	creds, err := security.LoadCredentials(file, keys)
+	if err != nil {
+		return err
+	}
+	previous, hadUser := creds.Users[user]
+
+	creds.Set(user, password)
+	if err := security.SaveCredentials(file, creds, keys); err != nil {
+		return err
+	}
+	if err := updatePassword(ctx, metadata, adminPassword, user, password); err != nil {
+		if !hadStore {
+			_ = os.Remove(file)
+			return err
+		}
+		if hadUser {
+			creds.Users[user] = previous
+		} else {
+			delete(creds.Users, user)
+		}
+		if restoreErr := security.SaveCredentials(file, creds, keys); restoreErr != nil {
+			return fmt.Errorf("%w (restoring %s also failed: %v)", err, file, restoreErr)
+		}
+		return err
+	}
+
+	fmt.Printf("Rotated password of %s in cluster '%s'\n", user, clusterName)
+	return m.recordCredentials(metadata, file, user)
+}
+
+// RekeyCredentials re-encrypts the cluster's credential store with the
+// named key source, security.KeySourcePassphrase or
+// security.KeySourceKeyring. Saving a store otherwise keeps its source.
+func (m *Manager) RekeyCredentials(clusterName, source string) error {
+	metadata, err := m.loadAuthMetadata(clusterName)
+	if err != nil {
+		return err
+	}
+	file := m.credentialsFile(clusterName, metadata)
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("cluster '%s' has no credential store: run 'mup cluster credentials set %s'", clusterName, clusterName)
+	}
+
+	keys, err := security.DefaultKeySource()
+	if err != nil {
+		return err
+	}
+	previous, err := security.RekeyCredentials(file, keys, source)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Re-encrypted credentials of cluster '%s' with the %s (was: %s)\n", clusterName, source, previous)
+	return nil
+}
+
+// storeCredential saves user's password in the cluster's credential store
+func (m *Manager) storeCredential(clusterName string, metadata *meta.ClusterMetadata, keys security.KeySource, user, password string) error {
+	file := m.credentialsFile(clusterName, metadata)
+	creds, err := security.LoadCredentials(file, keys)
+	if err != nil {
+		return err
+	}
+	creds.Set(user, password)
+	if err := security.SaveCredentials(file, creds, keys); err != nil {
+		return err
+	}
+	fmt.Printf("Stored password for %s in %s (key: %s)\n", user, file, creds.KeySource)
+	return m.recordCredentials(metadata, file, user)
+}
+
+// recordCredentials points the metadata at the credential store file,
+// removing the plaintext admin password file once user's password is the
+// admin password
+func (m *Manager) recordCredentials(metadata *meta.ClusterMetadata, file, user string) error {
+	metadata.Security.CredentialsFile = file
+	if user == metadata.Security.AdminUser && metadata.Security.PasswordFile != "" {
+		if err := os.Remove(metadata.Security.PasswordFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove plaintext password file: %w", err)
+		}
+	}
+	if err := m.metaMgr.Save(metadata); err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+	return nil
+}
+
+// loadAuthMetadata loads a cluster that has access control
+func (m *Manager) loadAuthMetadata(clusterName string) (*meta.ClusterMetadata, error) {
+	metadata, err := m.metaMgr.Load(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.Security == nil || !metadata.Security.Auth {
+		return nil, fmt.Errorf("cluster '%s' does not have access control enabled: run 'mup cluster enable-auth %s'", clusterName, clusterName)
+	}
+	if len(metadata.Nodes) == 0 {
+		return nil, fmt.Errorf("cluster '%s' has no nodes", clusterName)
+	}
+	return metadata, nil
+}
+
+// credentialsFile returns the cluster's credential store
+func (m *Manager) credentialsFile(clusterName string, metadata *meta.ClusterMetadata) string {
+	if metadata.Security.CredentialsFile != "" {
+		return metadata.Security.CredentialsFile
+	}
+	return paths.NewClusterLayout(m.metaMgr.GetClusterDir(clusterName)).CredentialsFile()
+}
+
+// clusterAddress returns a mongos, or the seed list of the cluster's
+// replica set, for commands acting on the whole cluster
+func clusterAddress(metadata *meta.ClusterMetadata) string {
+	address, _ := adminUserAddress(metadata, enableAuthOrder(metadata, nil))
+	return address
+}
+
+// connectAs connects to the cluster through a mongos or its replica set as
+// user, verifying the nodes with the cluster CA when it uses TLS
+func connectAs(ctx context.Context, metadata *meta.ClusterMetadata, user, password string) (*mongo.Client, error) {
+	address := clusterAddress(metadata)
+	opts := options.Client().
+		ApplyURI("mongodb://" + address).
+		SetConnectTimeout(10 * time.Second).
+		SetServerSelectionTimeout(10 * time.Second).
+		SetAuth(options.Credential{AuthSource: "admin", Username: user, Password: password})
+	if err := metadata.Security.ApplyTLS(opts); err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	return client, nil
+}
+
+// verifyLogin checks that user can log in with password
+func verifyLogin(ctx context.Context, metadata *meta.ClusterMetadata, user, password string) error {
+	ctx, cancel := context.WithTimeout(ctx, credentialsTimeout)
+	defer cancel()
+
+	client, err := connectAs(ctx, metadata, user, password)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+
+	return client.Database("admin").RunCommand(ctx, bson.D{{Key: "ping", Value: 1}}).Err()
+}
+
+// updatePassword sets user's password, logged in as the admin user
+func updatePassword(ctx context.Context, metadata *meta.ClusterMetadata, adminPassword, user, password string) error {
+	ctx, cancel := context.WithTimeout(ctx, credentialsTimeout)
+	defer cancel()
+
+	client, err := connectAs(ctx, metadata, metadata.Security.AdminUser, adminPassword)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+
+	err = client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "updateUser", Value: user},
+		{Key: "pwd", Value: password},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to change password of %s: %w", user, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/security"
+)
+
+func TestSetCredentials_ReplacesPasswordFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(security.PassphraseEnv, "test passphrase")
+
+	m, err := NewManager()
+	require.NoError(t, err)
+	passwordFile := filepath.Join(m.metaMgr.GetClusterDir("prod"), "secrets", "admin.password")
+	require.NoError(t, security.WriteSecret(passwordFile, []byte("old\n"), security.SecretMode))
+	require.NoError(t, m.metaMgr.Save(&meta.ClusterMetadata{
+		Name:     "prod",
+		Nodes:    []meta.NodeMetadata{{Type: "mongod", Host: "localhost", Port: 27017}},
+		Security: &meta.SecurityMetadata{Auth: true, AdminUser: "admin", PasswordFile: passwordFile},
+	}))
+
+	err = m.SetCredentials(context.Background(), "prod", SetCredentialsOptions{Password: "n3w", NoVerify: true})
+	require.NoError(t, err)
+
+	_, err = os.Stat(passwordFile)
+	assert.True(t, os.IsNotExist(err), "the plaintext password file is removed")
+
+	metadata, err := m.metaMgr.Load("prod")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(m.metaMgr.GetClusterDir("prod"), "secrets", "credentials.enc"), metadata.Security.CredentialsFile)
+	password, err := metadata.Security.AdminPassword()
+	require.NoError(t, err)
+	assert.Equal(t, "n3w", password)
+
+	content, err := os.ReadFile(m.metaMgr.GetMetaFile("prod"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "n3w", "passwords never reach meta.yaml")
+
+	info, err := m.ShowCredentials("prod", false)
+	require.NoError(t, err)
+	assert.Equal(t, security.KeySourcePassphrase, info.KeySource)
+	require.Len(t, info.Users, 1)
+	assert.Equal(t, "admin", info.Users[0].User)
+	assert.Empty(t, info.Users[0].Password)
+
+	info, err = m.ShowCredentials("prod", true)
+	require.NoError(t, err)
+	assert.Equal(t, "n3w", info.Users[0].Password)
+}
+
+func TestRekeyCredentials(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(security.PassphraseEnv, "")
+
+	m, err := NewManager()
+	require.NoError(t, err)
+	require.NoError(t, m.metaMgr.Save(&meta.ClusterMetadata{
+		Name:     "prod",
+		Nodes:    []meta.NodeMetadata{{Type: "mongod", Host: "localhost", Port: 27017}},
+		Security: &meta.SecurityMetadata{Auth: true, AdminUser: "admin"},
+	}))
+	assert.ErrorContains(t, m.RekeyCredentials("prod", security.KeySourcePassphrase), "has no credential store")
+
+	require.NoError(t, m.SetCredentials(context.Background(), "prod", SetCredentialsOptions{Password: "s3cret", NoVerify: true}))
+
+	// Storing another password with the passphrase set keeps the keyring
+	t.Setenv(security.PassphraseEnv, "test passphrase")
+	require.NoError(t, m.SetCredentials(context.Background(), "prod", SetCredentialsOptions{User: "backup", Password: "other", NoVerify: true}))
+	info, err := m.ShowCredentials("prod", false)
+	require.NoError(t, err)
+	assert.Equal(t, security.KeySourceKeyring, info.KeySource)
+
+	require.NoError(t, m.RekeyCredentials("prod", security.KeySourcePassphrase))
+	info, err = m.ShowCredentials("prod", false)
+	require.NoError(t, err)
+	assert.Equal(t, security.KeySourcePassphrase, info.KeySource)
+	assert.Len(t, info.Users, 2)
+}
+
+func TestSetCredentials_RequiresAuth(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := NewManager()
+	require.NoError(t, err)
+	require.NoError(t, m.metaMgr.Save(&meta.ClusterMetadata{
+		Name:  "dev",
+		Nodes: []meta.NodeMetadata{{Type: "mongod", Host: "localhost", Port: 27017}},
+	}))
+
+	err = m.SetCredentials(context.Background(), "dev", SetCredentialsOptions{Password: "x", NoVerify: true})
+	assert.ErrorContains(t, err, "enable-auth")
+}
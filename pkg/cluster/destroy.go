@@ -3,6 +3,10 @@ package cluster
 import (
 	"context"
 	"fmt"
+
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/ports"
 )
 
 // Destroy destroys a cluster
@@ -12,6 +16,9 @@ func (m *Manager) Destroy(ctx context.Context, clusterName string, keepData bool
 	if err != nil {
 		return err
 	}
+	if err := m.checkProtected(metadata, "destroy"); err != nil {
+		return err
+	}
 
 	fmt.Printf("Destroying cluster '%s'...\n", clusterName)
 
@@ -21,6 +28,13 @@ func (m *Manager) Destroy(ctx context.Context, clusterName string, keepData bool
 		fmt.Printf("Warning: failed to stop some nodes: %v\n", err)
 	}
 
+	// Remote clusters are cleaned up on every host in parallel
+	if !keepData && metadata.DeployMode == "remote" {
+		if err := m.destroyRemote(ctx, metadata); err != nil {
+			return err
+		}
+	}
+
 	// Remove data directories if requested
 	if !keepData && metadata.DeployMode == "local" {
 		fmt.Println("Removing data directories...")
@@ -74,7 +88,58 @@ func (m *Manager) Destroy(ctx context.Context, clusterName string, keepData bool
 	if err := m.metaMgr.Delete(clusterName); err != nil {
 		return fmt.Errorf("failed to delete metadata: %w", err)
 	}
+	if err := ports.ReleaseCluster(clusterName); err != nil {
+		return fmt.Errorf("failed to release ports: %w", err)
+	}
 
 	fmt.Printf("\n✓ Cluster '%s' destroyed\n", clusterName)
 	return nil
 }
+
+// destroyRemote removes node and supervisor directories on every host of a
+// remote cluster. Metadata is kept if any host fails so destroy can be retried.
+func (m *Manager) destroyRemote(ctx context.Context, metadata *meta.ClusterMetadata) error {
+	fmt.Println("Removing directories on remote hosts...")
+
+	executors, failures, err := m.connectHosts(metadata)
+	if err != nil {
+		return err
+	}
+	defer m.closeExecutors(executors)
+
+	supervisorDir, err := paths.NewRemotePathResolver(&metadata.Topology.Global).SupervisorDir()
+	if err != nil {
+		return err
+	}
+
+	grouped := nodesByHost(metadata, "")
+	results := forEachHost(ctx, sortedHosts(grouped), func(ctx context.Context, host string) (string, error) {
+		if err := failures[host]; err != nil {
+			return "", err
+		}
+		exec := executors[host]
+
+		var dirs []string
+		for _, node := range grouped[host] {
+			dirs = append(dirs, node.DataDir, node.LogDir, node.ConfigDir)
+		}
+		dirs = append(dirs, supervisorDir)
+
+		removed := 0
+		for _, dir := range dirs {
+			if dir == "" {
+				continue
+			}
+			if err := exec.RemoveDirectoryContext(ctx, dir); err != nil {
+				return "", fmt.Errorf("failed to remove %s: %w", dir, err)
+			}
+			removed++
+		}
+		return fmt.Sprintf("removed %d director(ies)", removed), nil
+	})
+
+	if failed := printHostResults(results); failed > 0 {
+		return fmt.Errorf("failed to clean up %d of %d host(s); metadata kept so destroy can be retried", failed, len(results))
+	}
+	return nil
+}
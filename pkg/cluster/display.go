@@ -3,6 +3,7 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -11,6 +12,9 @@ import (
 	"github.com/zph/mup/pkg/executor"
 	"github.com/zph/mup/pkg/meta"
 	"github.com/zph/mup/pkg/mongo"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/topology"
 )
 
 // Display shows cluster information
@@ -36,15 +40,13 @@ func (m *Manager) Display(ctx context.Context, clusterName string, format string
 // displayText displays cluster info in text format with comprehensive health checks
 func (m *Manager) displayText(metadata *meta.ClusterMetadata) error {
 	// Perform health checks
-	exec := executor.NewLocalExecutor()
-	defer func() { _ = exec.Close() }()
-
-	checker, err := health.NewChecker(metadata, exec)
+	ctx := context.Background()
+	checker, cleanup, err := m.newHealthChecker(ctx, metadata)
 	if err != nil {
 		return fmt.Errorf("failed to create health checker: %w", err)
 	}
+	defer cleanup()
 
-	ctx := context.Background()
 	clusterHealth, err := checker.Check(ctx)
 	if err != nil {
 		return fmt.Errorf("health check failed: %w", err)
@@ -66,6 +68,20 @@ func (m *Manager) displayText(metadata *meta.ClusterMetadata) error {
 	}
 	fmt.Printf("Topology Type:   %s\n", topoType)
 
+	// Display per-host reachability for remote clusters
+	if len(clusterHealth.Hosts) > 0 {
+		fmt.Println("\n" + strings.Repeat("━", 70))
+		fmt.Println("HOSTS")
+		fmt.Println(strings.Repeat("━", 70))
+		for _, host := range clusterHealth.Hosts {
+			if host.Reachable {
+				fmt.Printf("  %-40s %s reachable\n", host.Host, m.getStatusIcon("running"))
+			} else {
+				fmt.Printf("  %-40s %s unreachable: %s\n", host.Host, m.getStatusIcon("failed"), host.Error)
+			}
+		}
+	}
+
 	// Display nodes with health status
 	fmt.Println("\n" + strings.Repeat("━", 70))
 	fmt.Println("NODES")
@@ -116,6 +132,11 @@ func (m *Manager) displayText(metadata *meta.ClusterMetadata) error {
 	// Display port mapping
 	m.displayPortMapping(clusterHealth.Ports)
 
+	// Display certificate expiry for TLS clusters
+	if metadata.Security.TLSEnabled() {
+		displayCertificateExpiry(metadata, paths.NewClusterLayout(m.metaMgr.GetClusterDir(metadata.Name)))
+	}
+
 	// Display connection info
 	fmt.Println("\n" + strings.Repeat("━", 70))
 	fmt.Println("CONNECTION")
@@ -139,6 +160,9 @@ func (m *Manager) displayText(metadata *meta.ClusterMetadata) error {
 	fmt.Printf("Stop cluster:    mup cluster stop %s\n", metadata.Name)
 	fmt.Printf("Cluster status:  mup cluster display %s\n", metadata.Name)
 	fmt.Printf("Destroy cluster: mup cluster destroy %s\n", metadata.Name)
+	if metadata.Security.TLSEnabled() {
+		fmt.Printf("Rotate certs:    mup cluster rotate-certs %s\n", metadata.Name)
+	}
 
 	if clusterHealth.Monitoring.Enabled {
 		fmt.Printf("\nMonitoring:\n")
@@ -151,13 +175,72 @@ func (m *Manager) displayText(metadata *meta.ClusterMetadata) error {
 	return nil
 }
 
+// newHealthChecker builds a health checker for the cluster's deploy mode.
+// Remote clusters query each host's supervisord over SSH; hosts that cannot be
+// reached are reported rather than failing the whole display.
+func (m *Manager) newHealthChecker(ctx context.Context, metadata *meta.ClusterMetadata) (*health.Checker, func(), error) {
+	if metadata.DeployMode == "remote" {
+		supervisors, failures, cleanup, err := m.withRemoteSupervisors(ctx, metadata)
+		if err != nil {
+			return nil, nil, err
+		}
+		return health.NewRemoteChecker(metadata, supervisors, failures), cleanup, nil
+	}
+
+	exec := executor.NewLocalExecutor()
+	checker, err := health.NewChecker(metadata, exec)
+	if err != nil {
+		_ = exec.Close()
+		return nil, nil, err
+	}
+	return checker, func() { _ = exec.Close() }, nil
+}
+
+// displayCertificateExpiry shows when the cluster CA and each node
+// certificate expire, from the copies kept in the cluster directory
+func displayCertificateExpiry(metadata *meta.ClusterMetadata, layout *paths.ClusterLayout) {
+	fmt.Println("\n" + strings.Repeat("━", 70))
+	fmt.Println("CERTIFICATES")
+	fmt.Println(strings.Repeat("━", 70))
+
+	displayCertificateFile("CA", layout.CAFile())
+	for _, node := range metadata.Nodes {
+		displayCertificateFile(fmt.Sprintf("%s %s", node.Type, node.ID()), layout.CertificateFile(node.Host, node.Port))
+	}
+}
+
+// displayCertificateFile prints the expiry of the first certificate in
+// file, flagging certificates due for renewal
+func displayCertificateFile(label, file string) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Printf("  %-40s ✗ unreadable: %v\n", label, err)
+		return
+	}
+	expiry, err := security.CertificateExpiry(content)
+	if err != nil {
+		fmt.Printf("  %-40s ✗ %v\n", label, err)
+		return
+	}
+
+	remaining := time.Until(expiry)
+	switch {
+	case remaining <= 0:
+		fmt.Printf("  %-40s ✗ expired %s\n", label, expiry.Format("2006-01-02"))
+	case remaining < security.RenewBefore:
+		fmt.Printf("  %-40s ! expires %s (in %s)\n", label, expiry.Format("2006-01-02"), formatDuration(remaining))
+	default:
+		fmt.Printf("  %-40s ✓ expires %s (in %s)\n", label, expiry.Format("2006-01-02"), formatDuration(remaining))
+	}
+}
+
 // displayNodeHealth displays detailed health info for a node
 func (m *Manager) displayNodeHealth(node health.NodeHealth) {
 	// Status indicator
 	statusIcon := m.getStatusIcon(node.Status)
 
 	// Build node description
-	desc := fmt.Sprintf("%s:%d", node.Host, node.Port)
+	desc := topology.GetNodeID(node.Host, node.Port)
 	if node.ReplicaSet != "" {
 		desc += fmt.Sprintf(" [%s]", node.ReplicaSet)
 	}
@@ -173,6 +256,9 @@ func (m *Manager) displayNodeHealth(node health.NodeHealth) {
 	if node.PID > 0 {
 		statusLine += fmt.Sprintf(" PID: %d", node.PID)
 	}
+	if node.Limits != "" && node.Limits != "unlimited" {
+		statusLine += fmt.Sprintf(" limits: %s", node.Limits)
+	}
 
 	fmt.Printf("  %-40s %s\n", desc, statusLine)
 }
@@ -297,7 +383,7 @@ func (m *Manager) getConnectionString(metadata *meta.ClusterMetadata) string {
 		// Connect via mongos
 		for _, node := range metadata.Nodes {
 			if node.Type == "mongos" {
-				return fmt.Sprintf("mongodb://%s:%d", node.Host, node.Port)
+				return "mongodb://" + node.Address()
 			}
 		}
 
@@ -312,7 +398,7 @@ func (m *Manager) getConnectionString(metadata *meta.ClusterMetadata) string {
 					rsName = node.ReplicaSet
 				}
 				if rsName == node.ReplicaSet {
-					hosts = append(hosts, fmt.Sprintf("%s:%d", node.Host, node.Port))
+					hosts = append(hosts, node.Address())
 				}
 			}
 		}
@@ -325,7 +411,7 @@ func (m *Manager) getConnectionString(metadata *meta.ClusterMetadata) string {
 		// Connect to single mongod
 		for _, node := range metadata.Nodes {
 			if node.Type == "mongod" {
-				return fmt.Sprintf("mongodb://%s:%d", node.Host, node.Port)
+				return "mongodb://" + node.Address()
 			}
 		}
 	}
@@ -0,0 +1,391 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/topology"
+	"github.com/zph/mup/pkg/upgrade"
+)
+
+// EnableAuthOperation is the plan operation of enable-auth
+const EnableAuthOperation = "enable-auth"
+
+// EnableAuthOptions controls enabling access control on a running cluster
+type EnableAuthOptions struct {
+	User    string // Admin user to create (default admin)
+	KeyFile string // Existing keyFile to use instead of generating one
+}
+
+// enableAuthSpec is everything an enable-auth plan is built from
+type enableAuthSpec struct {
+	user            string
+	keyFile         string // On the machine running mup
+	nodeKeyFile     string // Where the nodes read the keyFile
+	sourceKeyFile   string
+	passwordFile    string
+	credentialsFile string            // Encrypted store, preferred over passwordFile once it exists
+	caFile          string            // Cluster CA when the cluster uses TLS
+	restart         map[string]string // Restart command by node ID
+	primaries       map[string]string // Primary node ID by replica set
+}
+
+// PlanEnableAuth plans enabling access control on a running cluster
+// without downtime, following MongoDB's transitionToAuth procedure: the
+// nodes restart one at a time with the keyFile and transitionToAuth, the
+// admin user is created, and a second rolling restart enforces
+// authentication. Replica set members restart secondaries first.
+func (m *Manager) PlanEnableAuth(ctx context.Context, clusterName string, opts EnableAuthOptions) (*plan.Plan, error) {
+	metadata, err := m.metaMgr.Load(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.Security != nil && metadata.Security.Auth {
+		return nil, fmt.Errorf("cluster '%s' already has access control enabled", clusterName)
+	}
+	if metadata.Topology == nil {
+		return nil, fmt.Errorf("cluster metadata has no topology - cannot resolve node paths")
+	}
+	for _, node := range metadata.Nodes {
+		if node.ConfigFile == "" || node.SupervisorProgramName == "" {
+			return nil, fmt.Errorf("node %s missing config file or supervisor program name - cluster may need redeployment", node.ID())
+		}
+	}
+
+	// The topology records access control once the plan is applied
+	topo := *metadata.Topology
+	topo.Security = &topology.SecurityConfig{Auth: true, AdminUser: opts.User, KeyFile: opts.KeyFile}
+	if metadata.Topology.Security != nil {
+		topo.Security.TLS = metadata.Topology.Security.TLS
+	}
+	if err := topo.Validate(); err != nil {
+		return nil, err
+	}
+	user := topo.Security.AdminUsername()
+
+	layout := paths.NewClusterLayout(m.metaMgr.GetClusterDir(clusterName))
+	spec := &enableAuthSpec{
+		user:            user,
+		keyFile:         layout.KeyFile(),
+		nodeKeyFile:     layout.KeyFile(),
+		sourceKeyFile:   opts.KeyFile,
+		passwordFile:    layout.AdminPasswordFile(),
+		credentialsFile: layout.CredentialsFile(),
+		restart:         make(map[string]string),
+		primaries:       make(map[string]string),
+	}
+	if metadata.Security.TLSEnabled() {
+		spec.caFile = metadata.Security.CAFile
+	}
+
+	if err := m.resolveRestartCommands(ctx, metadata, spec); err != nil {
+		return nil, err
+	}
+	if metadata.DeployMode == "remote" {
+		if spec.nodeKeyFile, err = paths.NewRemotePathResolver(&metadata.Topology.Global).KeyFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Primaries restart last; the restart steps down a node that has
+	// become primary since
+	for _, group := range rotationGroups(metadata) {
+		if group.replicaSet == "" {
+			continue
+		}
+		var allHosts []string
+		for _, node := range group.nodes {
+			allHosts = append(allHosts, node.Address())
+		}
+		for _, node := range group.nodes {
+			role, err := upgrade.DetectNodeRole(ctx, node.Address(), group.replicaSet, allHosts, metadata.Security)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check %s: %w", node.ID(), err)
+			}
+			if role == "PRIMARY" {
+				spec.primaries[group.replicaSet] = node.ID()
+			}
+		}
+	}
+
+	return buildEnableAuthPlan(metadata, spec), nil
+}
+
+// resolveRestartCommands sets the supervisord command restarting each node
+func (m *Manager) resolveRestartCommands(ctx context.Context, metadata *meta.ClusterMetadata, spec *enableAuthSpec) error {
+	r := &certRotator{metadata: metadata}
+	cleanup, err := m.connectRotator(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	for _, node := range metadata.Nodes {
+		if r.local != nil {
+			spec.restart[node.ID()] = r.local.CtlCommand("restart", node.SupervisorProgramName)
+		} else {
+			spec.restart[node.ID()] = r.remote[node.Host].CtlCommand("restart", node.SupervisorProgramName)
+		}
+	}
+	return nil
+}
+
+// buildEnableAuthPlan lays out the enable-auth phases for metadata's nodes
+func buildEnableAuthPlan(metadata *meta.ClusterMetadata, spec *enableAuthSpec) *plan.Plan {
+	order := enableAuthOrder(metadata, spec.primaries)
+	opIndex := 0
+	nextID := func(phase string) string {
+		opIndex++
+		return plan.NewOperationID(phase, opIndex)
+	}
+
+	// prepare: the keyFile and admin password, copied to every host
+	keyFileParams := map[string]interface{}{
+		"key_file":         spec.keyFile,
+		"admin_user":       spec.user,
+		"credentials_file": spec.credentialsFile,
+		"password_file":    spec.passwordFile,
+	}
+	if spec.sourceKeyFile != "" {
+		keyFileParams["source_key_file"] = spec.sourceKeyFile
+	}
+	prepare := []plan.PlannedOperation{{
+		ID:          nextID("prepare"),
+		Type:        plan.OpGenerateKeyFile,
+		Description: fmt.Sprintf("Generate keyFile %s and password for user %s", spec.keyFile, spec.user),
+		Target:      plan.OperationTarget{Type: "keyfile", Name: metadata.Name},
+		Params:      keyFileParams,
+		Changes: []plan.Change{
+			{ResourceType: "file", ResourceID: spec.keyFile, Action: plan.ActionCreate},
+			{ResourceType: "file", ResourceID: spec.credentialsFile, Action: plan.ActionCreate},
+		},
+	}}
+	if metadata.DeployMode == "remote" {
+		hosts := clusterHosts(metadata)
+		sort.Strings(hosts)
+		for _, host := range hosts {
+			prepare = append(prepare, plan.PlannedOperation{
+				ID:          nextID("prepare"),
+				Type:        plan.OpDistributeKeyFile,
+				Description: fmt.Sprintf("Distribute keyFile to %s:%s", host, spec.nodeKeyFile),
+				Target:      plan.OperationTarget{Type: "host", Name: host, Host: host},
+				Params: map[string]interface{}{
+					"source": spec.keyFile,
+					"dest":   spec.nodeKeyFile,
+				},
+				Changes: []plan.Change{
+					{ResourceType: "file", ResourceID: fmt.Sprintf("%s:%s", host, spec.nodeKeyFile), Action: plan.ActionCreate},
+				},
+				Parallel: true,
+			})
+		}
+	}
+
+	var tls *deploy.TLSParams
+	if spec.caFile != "" {
+		tls = &deploy.TLSParams{CAFile: spec.caFile}
+	}
+	auth := &deploy.AuthParams{User: spec.user, PasswordFile: spec.passwordFile, CredentialsFile: spec.credentialsFile}
+
+	// roll configures every node, then restarts them one at a time
+	roll := func(phase string, transition bool, auth *deploy.AuthParams) []plan.PlannedOperation {
+		var operations []plan.PlannedOperation
+		for _, node := range order {
+			operations = append(operations, plan.PlannedOperation{
+				ID:          nextID(phase),
+				Type:        plan.OpConfigureNodeAuth,
+				Description: fmt.Sprintf("Set keyFile of %s %s (transitionToAuth: %t)", node.Type, node.ID(), transition),
+				Target:      nodeTarget(node),
+				Params: map[string]interface{}{
+					"config_file":        node.ConfigFile,
+					"key_file":           spec.nodeKeyFile,
+					"authorization":      node.Type != "mongos",
+					"transition_to_auth": transition,
+				},
+				Changes: []plan.Change{
+					{ResourceType: "file", ResourceID: fmt.Sprintf("%s:%s", node.Host, node.ConfigFile), Action: plan.ActionUpdate},
+				},
+				Parallel: true,
+			})
+		}
+		for _, node := range order {
+			params := map[string]interface{}{
+				"node":            node.Address(),
+				"restart_command": spec.restart[node.ID()],
+			}
+			if node.ReplicaSet != "" {
+				params["replica_set"] = node.ReplicaSet
+				params["members"] = replicaSetSize(metadata, node.ReplicaSet)
+			}
+			if tls != nil {
+				params["tls"] = tls
+			}
+			if auth != nil {
+				params["auth"] = auth
+			}
+			operations = append(operations, plan.PlannedOperation{
+				ID:          nextID(phase),
+				Type:        plan.OpRestartNode,
+				Description: fmt.Sprintf("Restart %s %s", node.Type, node.ID()),
+				Target:      nodeTarget(node),
+				Params:      params,
+				Changes: []plan.Change{
+					{ResourceType: "process", ResourceID: node.SupervisorProgramName, Action: plan.ActionStart},
+				},
+			})
+		}
+		return operations
+	}
+
+	transition := roll("transition", true, nil)
+
+	// create_user: through a mongos, the replica set, or the only node
+	address, host := adminUserAddress(metadata, order)
+	createParams := map[string]interface{}{
+		"address": address,
+		"auth":    auth,
+	}
+	if tls != nil {
+		createParams["tls"] = tls
+	}
+	createUser := []plan.PlannedOperation{{
+		ID:          nextID("create_user"),
+		Type:        plan.OpCreateAdminUser,
+		Description: fmt.Sprintf("Create user %s with the root role", spec.user),
+		Target:      plan.OperationTarget{Type: "cluster", Name: metadata.Name, Host: host},
+		Params:      createParams,
+		Changes: []plan.Change{
+			{ResourceType: "user", ResourceID: "admin." + spec.user, Action: plan.ActionCreate},
+		},
+	}}
+
+	enforce := roll("enforce", false, auth)
+
+	return &plan.Plan{
+		PlanID:      plan.NewPlanID(),
+		Operation:   EnableAuthOperation,
+		ClusterName: metadata.Name,
+		Version:     metadata.Version,
+		Variant:     metadata.Variant,
+		Topology:    metadata.Topology,
+		Config: map[string]interface{}{
+			"user":          spec.user,
+			"key_file":      spec.keyFile,
+			"password_file": spec.passwordFile,
+		},
+		Validation: plan.ValidationResult{Valid: true},
+		Phases: []plan.PlannedPhase{
+			{Name: "prepare", Description: "Create and distribute the keyFile", Order: 1, Operations: prepare},
+			{Name: "transition", Description: "Restart every node with the keyFile and transitionToAuth", Order: 2, Operations: transition},
+			{Name: "create_user", Description: "Create the admin user", Order: 3, Operations: createUser},
+			{Name: "enforce", Description: "Restart every node enforcing authentication", Order: 4, Operations: enforce},
+		},
+	}
+}
+
+// enableAuthOrder orders nodes for the rolling restarts: mongos routers,
+// then the config servers and shards, secondaries before their primary
+func enableAuthOrder(metadata *meta.ClusterMetadata, primaries map[string]string) []*meta.NodeMetadata {
+	var routers, others []*meta.NodeMetadata
+	for _, group := range rotationGroups(metadata) {
+		var primary *meta.NodeMetadata
+		for _, node := range group.nodes {
+			switch {
+			case node.Type == "mongos":
+				routers = append(routers, node)
+			case group.replicaSet != "" && primaries[group.replicaSet] == node.ID():
+				primary = node
+			default:
+				others = append(others, node)
+			}
+		}
+		if primary != nil {
+			others = append(others, primary)
+		}
+	}
+	return append(routers, others...)
+}
+
+// adminUserAddress returns where the admin user is created and the host
+// whose executor runs the operation
+func adminUserAddress(metadata *meta.ClusterMetadata, order []*meta.NodeMetadata) (string, string) {
+	first := order[0]
+	if first.Type == "mongos" || first.ReplicaSet == "" {
+		return first.Address(), first.Host
+	}
+	var members []string
+	for _, node := range order {
+		if node.ReplicaSet == first.ReplicaSet {
+			members = append(members, node.Address())
+		}
+	}
+	return fmt.Sprintf("%s/?replicaSet=%s", strings.Join(members, ","), first.ReplicaSet), first.Host
+}
+
+// replicaSetSize returns the number of members of rsName
+func replicaSetSize(metadata *meta.ClusterMetadata, rsName string) int {
+	size := 0
+	for _, node := range metadata.Nodes {
+		if node.ReplicaSet == rsName {
+			size++
+		}
+	}
+	return size
+}
+
+// nodeTarget returns the operation target for node
+func nodeTarget(node *meta.NodeMetadata) plan.OperationTarget {
+	return plan.OperationTarget{Type: node.Type, Name: node.ID(), Host: node.Host, Port: node.Port}
+}
+
+// EnableAuthExecutors returns executors for every host of the cluster.
+// The caller closes them.
+func (m *Manager) EnableAuthExecutors(clusterName string) (map[string]executor.Executor, error) {
+	metadata, err := m.metaMgr.Load(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return m.createExecutors(metadata)
+}
+
+// CompleteEnableAuth records in the cluster metadata that access control
+// is on, once the enable-auth plan p has been applied
+func (m *Manager) CompleteEnableAuth(clusterName string, p *plan.Plan) error {
+	metadata, err := m.metaMgr.Load(clusterName)
+	if err != nil {
+		return err
+	}
+
+	user, _ := p.Config["user"].(string)
+	keyFile, _ := p.Config["key_file"].(string)
+	passwordFile, _ := p.Config["password_file"].(string)
+
+	if metadata.Security == nil {
+		metadata.Security = &meta.SecurityMetadata{}
+	}
+	metadata.Security.Auth = true
+	metadata.Security.AdminUser = user
+	metadata.Security.KeyFile = keyFile
+	metadata.Security.PasswordFile = passwordFile
+	metadata.Security.CredentialsFile = paths.NewClusterLayout(m.metaMgr.GetClusterDir(clusterName)).CredentialsFile()
+
+	if metadata.Topology != nil {
+		if metadata.Topology.Security == nil {
+			metadata.Topology.Security = &topology.SecurityConfig{}
+		}
+		metadata.Topology.Security.Auth = true
+		metadata.Topology.Security.AdminUser = user
+	}
+
+	if err := m.metaMgr.Save(metadata); err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/plan"
+)
+
+func TestBuildEnableAuthPlan(t *testing.T) {
+	metadata := &meta.ClusterMetadata{
+		Name:       "prod",
+		DeployMode: "remote",
+		Nodes: []meta.NodeMetadata{
+			{Type: "mongos", Host: "app1", Port: 27017, ConfigFile: "/etc/mongos.conf", SupervisorProgramName: "mongos-27017"},
+			{Type: "config", Host: "cfg1", Port: 27019, ReplicaSet: "configRS", ConfigFile: "/etc/config.conf", SupervisorProgramName: "config-27019"},
+			{Type: "mongod", Host: "db1", Port: 27018, ReplicaSet: "shard1", ConfigFile: "/etc/mongod.conf", SupervisorProgramName: "mongod-27018"},
+			{Type: "mongod", Host: "db2", Port: 27018, ReplicaSet: "shard1", ConfigFile: "/etc/mongod.conf", SupervisorProgramName: "mongod-27018"},
+		},
+	}
+	spec := &enableAuthSpec{
+		user:         "root",
+		keyFile:      "/home/me/.mup/prod/secrets/keyfile",
+		nodeKeyFile:  "/opt/mup/secrets/keyfile",
+		passwordFile: "/home/me/.mup/prod/secrets/admin.password",
+		caFile:       "/home/me/.mup/prod/tls/ca.pem",
+		restart: map[string]string{
+			"app1:27017": "restart mongos", "cfg1:27019": "restart config",
+			"db1:27018": "restart db1", "db2:27018": "restart db2",
+		},
+		primaries: map[string]string{"configRS": "cfg1:27019", "shard1": "db1:27018"},
+	}
+
+	p := buildEnableAuthPlan(metadata, spec)
+
+	assert.Equal(t, EnableAuthOperation, p.Operation)
+	assert.Equal(t, "root", p.Config["user"])
+	require.Len(t, p.Phases, 4)
+	assert.Equal(t, []string{"prepare", "transition", "create_user", "enforce"},
+		[]string{p.Phases[0].Name, p.Phases[1].Name, p.Phases[2].Name, p.Phases[3].Name})
+
+	// The keyFile is generated, then copied to every host
+	prepare := p.Phases[0].Operations
+	require.Len(t, prepare, 5)
+	assert.Equal(t, plan.OpGenerateKeyFile, prepare[0].Type)
+	for _, op := range prepare[1:] {
+		assert.Equal(t, plan.OpDistributeKeyFile, op.Type)
+		assert.Equal(t, "/opt/mup/secrets/keyfile", op.Params["dest"])
+	}
+
+	// Every node is configured, then restarted: mongos first, secondaries
+	// before their primary
+	for _, phase := range []plan.PlannedPhase{p.Phases[1], p.Phases[3]} {
+		require.Len(t, phase.Operations, 8)
+		var restarted []string
+		for _, op := range phase.Operations[:4] {
+			assert.Equal(t, plan.OpConfigureNodeAuth, op.Type)
+			assert.Equal(t, phase.Name == "transition", op.Params["transition_to_auth"])
+			assert.Equal(t, op.Target.Type != "mongos", op.Params["authorization"])
+		}
+		for _, op := range phase.Operations[4:] {
+			assert.Equal(t, plan.OpRestartNode, op.Type)
+			assert.Equal(t, &deploy.TLSParams{CAFile: spec.caFile}, op.Params["tls"])
+			_, hasAuth := op.Params["auth"]
+			assert.Equal(t, phase.Name == "enforce", hasAuth, "only the enforcing restarts log in")
+			restarted = append(restarted, op.Params["restart_command"].(string))
+		}
+		assert.Equal(t, []string{"restart mongos", "restart config", "restart db2", "restart db1"}, restarted)
+		assert.Equal(t, 2, phase.Operations[6].Params["members"])
+	}
+
+	// The admin user is created through mongos
+	createUser := p.Phases[2].Operations
+	require.Len(t, createUser, 1)
+	assert.Equal(t, plan.OpCreateAdminUser, createUser[0].Type)
+	assert.Equal(t, "app1:27017", createUser[0].Params["address"])
+	assert.Equal(t, "app1", createUser[0].Target.Host)
+
+	ids := make(map[string]bool)
+	for _, phase := range p.Phases {
+		for _, op := range phase.Operations {
+			assert.False(t, ids[op.ID], "duplicate operation ID %s", op.ID)
+			ids[op.ID] = true
+		}
+	}
+}
+
+func TestAdminUserAddress_ReplicaSet(t *testing.T) {
+	metadata := &meta.ClusterMetadata{
+		Nodes: []meta.NodeMetadata{
+			{Type: "mongod", Host: "db1", Port: 27017, ReplicaSet: "rs0"},
+			{Type: "mongod", Host: "db2", Port: 27017, ReplicaSet: "rs0"},
+		},
+	}
+	order := enableAuthOrder(metadata, map[string]string{"rs0": "db1:27017"})
+
+	address, host := adminUserAddress(metadata, order)
+	assert.Equal(t, "db2:27017,db1:27017/?replicaSet=rs0", address)
+	assert.Equal(t, "db2", host)
+}
@@ -0,0 +1,178 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/meta"
+)
+
+// ExecOptions controls which hosts an ad-hoc command runs on
+type ExecOptions struct {
+	Command string
+	Role    string // "mongod", "mongos" or "config"; empty for all
+	Host    string // Single host; empty for all
+	Format  string // "text" or "json"
+}
+
+// ExecResult is the outcome of an ad-hoc command on one host
+type ExecResult struct {
+	Host     string   `json:"host"`
+	Roles    []string `json:"roles"`
+	ExitCode int      `json:"exit_code"`
+	Output   string   `json:"output"`
+	Error    string   `json:"error,omitempty"`
+	Duration string   `json:"duration"`
+}
+
+// Exec runs a shell command on every selected host in parallel and prints the
+// output grouped by host. It returns an error if any host failed.
+func (m *Manager) Exec(ctx context.Context, clusterName string, opts ExecOptions) ([]ExecResult, error) {
+	if strings.TrimSpace(opts.Command) == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	switch opts.Role {
+	case "", "mongod", "mongos", "config":
+	default:
+		return nil, fmt.Errorf("invalid role %q (must be mongod, mongos or config)", opts.Role)
+	}
+
+	metadata, err := m.metaMgr.Load(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := execTargets(metadata, opts)
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("no hosts matched (role=%q, host=%q)", opts.Role, opts.Host)
+	}
+	hosts := make([]string, 0, len(roles))
+	for host := range roles {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	executors, failures, err := m.execExecutors(metadata)
+	if err != nil {
+		return nil, err
+	}
+	defer m.closeExecutors(executors)
+
+	results := make(map[string]*ExecResult)
+	var mu sync.Mutex
+
+	forEachHost(ctx, hosts, func(ctx context.Context, host string) (string, error) {
+		res := &ExecResult{Host: host, Roles: roles[host]}
+		start := time.Now()
+
+		if err := failures[host]; err != nil {
+			res.ExitCode = executor.ExitCode(err)
+			res.Error = err.Error()
+		} else {
+			var lines []string
+			err := executors[host].ExecuteStream(ctx, opts.Command, func(line string) {
+				lines = append(lines, line)
+			})
+			res.Output = strings.Join(lines, "\n")
+			res.ExitCode = executor.ExitCode(err)
+			if err != nil {
+				res.Error = firstLine(err.Error())
+			}
+		}
+		res.Duration = time.Since(start).Round(time.Millisecond).String()
+
+		mu.Lock()
+		results[host] = res
+		mu.Unlock()
+		return "", nil
+	})
+
+	ordered := make([]ExecResult, 0, len(results))
+	failed := 0
+	for _, host := range hosts {
+		res := results[host]
+		if res.Error != "" {
+			failed++
+		}
+		ordered = append(ordered, *res)
+	}
+
+	if opts.Format == "json" {
+		data, err := json.MarshalIndent(ordered, "", "  ")
+		if err != nil {
+			return ordered, fmt.Errorf("failed to marshal results: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printExecResults(ordered)
+	}
+
+	if failed > 0 {
+		return ordered, fmt.Errorf("command failed on %d of %d host(s)", failed, len(ordered))
+	}
+	return ordered, nil
+}
+
+// execTargets returns the selected hosts and the node roles on each
+func execTargets(metadata *meta.ClusterMetadata, opts ExecOptions) map[string][]string {
+	roles := make(map[string][]string)
+	for _, node := range metadata.Nodes {
+		if opts.Role != "" && node.Type != opts.Role {
+			continue
+		}
+		if opts.Host != "" && node.Host != opts.Host {
+			continue
+		}
+		role := fmt.Sprintf("%s:%d", node.Type, node.Port)
+		roles[node.Host] = append(roles[node.Host], role)
+	}
+	return roles
+}
+
+// execExecutors returns an executor per host. For remote clusters, hosts that
+// could not be reached are returned in the failure map instead of aborting.
+func (m *Manager) execExecutors(metadata *meta.ClusterMetadata) (map[string]executor.Executor, map[string]error, error) {
+	if metadata.DeployMode == "remote" {
+		return m.connectHosts(metadata)
+	}
+
+	executors, err := m.createExecutors(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	return executors, map[string]error{}, nil
+}
+
+// printExecResults prints command output grouped by host
+func printExecResults(results []ExecResult) {
+	for _, res := range results {
+		icon := "✓"
+		if res.Error != "" {
+			icon = "✗"
+		}
+		fmt.Printf("%s %s [%s] exit=%d (%s)\n", icon, res.Host, strings.Join(res.Roles, ", "), res.ExitCode, res.Duration)
+		if res.Output != "" {
+			for _, line := range strings.Split(res.Output, "\n") {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+		if res.Error != "" && res.Output == "" {
+			fmt.Printf("  error: %s\n", res.Error)
+		}
+		fmt.Println()
+	}
+}
+
+// firstLine returns s up to the first newline
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
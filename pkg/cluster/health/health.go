@@ -7,8 +7,10 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,6 +20,7 @@ import (
 	"github.com/zph/mup/pkg/executor"
 	"github.com/zph/mup/pkg/meta"
 	"github.com/zph/mup/pkg/supervisor"
+	"github.com/zph/mup/pkg/topology"
 )
 
 // Checker performs comprehensive health checks on clusters
@@ -25,6 +28,11 @@ type Checker struct {
 	metadata   *meta.ClusterMetadata
 	executor   executor.Executor
 	supervisor *supervisor.Manager
+
+	// Remote clusters: one supervisord controller per reachable host, and the
+	// reason each unreachable host could not be queried
+	remote     map[string]*supervisor.RemoteManager
+	hostErrors map[string]error
 }
 
 // NewChecker creates a new health checker
@@ -58,6 +66,17 @@ func NewChecker(metadata *meta.ClusterMetadata, exec executor.Executor) (*Checke
 	}, nil
 }
 
+// NewRemoteChecker creates a health checker for a remote cluster. Each host's
+// supervisord is queried through its own controller; hosts listed in
+// hostErrors are reported as unreachable and their nodes fall back to port checks.
+func NewRemoteChecker(metadata *meta.ClusterMetadata, supervisors map[string]*supervisor.RemoteManager, hostErrors map[string]error) *Checker {
+	return &Checker{
+		metadata:   metadata,
+		remote:     supervisors,
+		hostErrors: hostErrors,
+	}
+}
+
 // Check performs comprehensive health checks
 func (c *Checker) Check(ctx context.Context) (*ClusterHealth, error) {
 	health := &ClusterHealth{
@@ -66,9 +85,25 @@ func (c *Checker) Check(ctx context.Context) (*ClusterHealth, error) {
 		Monitoring: MonitoringHealth{},
 	}
 
-	// Check each node
-	for _, node := range c.metadata.Nodes {
-		nodeHealth := c.checkNode(ctx, node)
+	if c.remote != nil || c.hostErrors != nil {
+		health.Hosts = c.checkHosts()
+	}
+
+	// Check nodes in parallel; remote clusters can span many hosts and each
+	// check may wait on a network timeout
+	nodeHealths := make([]NodeHealth, len(c.metadata.Nodes))
+	var wg sync.WaitGroup
+	for i, node := range c.metadata.Nodes {
+		wg.Add(1)
+		go func(i int, node meta.NodeMetadata) {
+			defer wg.Done()
+			nodeHealths[i] = c.checkNode(ctx, node)
+		}(i, node)
+	}
+	wg.Wait()
+
+	for i, node := range c.metadata.Nodes {
+		nodeHealth := nodeHealths[i]
 		health.Nodes = append(health.Nodes, nodeHealth)
 
 		// Add to port mapping
@@ -170,8 +205,8 @@ func (c *Checker) checkNode(ctx context.Context, node meta.NodeMetadata) NodeHea
 	}
 
 	// Check 1: Supervisord process status
-	if c.supervisor != nil && node.SupervisorProgramName != "" {
-		status, err := c.supervisor.GetProcessStatus(node.SupervisorProgramName)
+	if node.SupervisorProgramName != "" {
+		status, err := c.processStatus(ctx, node)
 		if err == nil {
 			health.SupervisorStatus = status.State
 			health.PID = status.PID
@@ -193,13 +228,22 @@ func (c *Checker) checkNode(ctx context.Context, node meta.NodeMetadata) NodeHea
 		}
 	}
 
-	// Check 2: Port availability (if supervisor check failed or unavailable)
+	// Check 2: cgroup limits actually applied to the process
+	if health.PID > 0 {
+		if exec := c.nodeExecutor(node); exec != nil {
+			if limits, err := supervisor.ReadEffectiveLimits(ctx, exec, health.PID); err == nil {
+				health.Limits = limits.String()
+			}
+		}
+	}
+
+	// Check 3: Port availability (if supervisor check failed or unavailable)
 	if health.Status == "unknown" {
 		portStatus := c.checkPort(node.Host, node.Port)
 		health.Status = portStatus
 	}
 
-	// Check 3: Get MongoDB version (if node is running)
+	// Check 4: Get MongoDB version (if node is running)
 	if health.Status == "running" {
 		version, err := c.getMongoDBVersion(ctx, node.Host, node.Port)
 		if err == nil {
@@ -213,6 +257,53 @@ func (c *Checker) checkNode(ctx context.Context, node meta.NodeMetadata) NodeHea
 	return health
 }
 
+// processStatus queries the supervisord responsible for node, local or remote
+func (c *Checker) processStatus(ctx context.Context, node meta.NodeMetadata) (*supervisor.ProcessStatus, error) {
+	if c.remote != nil {
+		mgr, ok := c.remote[node.Host]
+		if !ok {
+			return nil, fmt.Errorf("no supervisor connection to %s", node.Host)
+		}
+		return mgr.GetProcessStatus(ctx, node.SupervisorProgramName)
+	}
+	if c.supervisor == nil {
+		return nil, fmt.Errorf("supervisor not available")
+	}
+	return c.supervisor.GetProcessStatus(node.SupervisorProgramName)
+}
+
+// nodeExecutor returns the executor for the host running node, or nil
+func (c *Checker) nodeExecutor(node meta.NodeMetadata) executor.Executor {
+	if c.remote != nil {
+		if mgr, ok := c.remote[node.Host]; ok {
+			return mgr.Executor()
+		}
+		return nil
+	}
+	return c.executor
+}
+
+// checkHosts reports per-host reachability for remote clusters
+func (c *Checker) checkHosts() []HostHealth {
+	seen := make(map[string]bool)
+	var hosts []HostHealth
+	for _, node := range c.metadata.Nodes {
+		if seen[node.Host] {
+			continue
+		}
+		seen[node.Host] = true
+
+		hh := HostHealth{Host: node.Host, Reachable: true}
+		if err := c.hostErrors[node.Host]; err != nil {
+			hh.Reachable = false
+			hh.Error = err.Error()
+		}
+		hosts = append(hosts, hh)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	return hosts
+}
+
 // checkPort checks if a port is listening
 func (c *Checker) checkPort(host string, port int) string {
 	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
@@ -274,11 +365,19 @@ func (c *Checker) checkMonitoring(_ context.Context) MonitoringHealth {
 
 // ClusterHealth represents the complete health state of a cluster
 type ClusterHealth struct {
+	Hosts      []HostHealth // Only populated for remote clusters
 	Nodes      []NodeHealth
 	Ports      PortMapping
 	Monitoring MonitoringHealth
 }
 
+// HostHealth represents whether a remote host could be queried
+type HostHealth struct {
+	Host      string
+	Reachable bool
+	Error     string
+}
+
 // NodeHealth represents health information for a single node
 type NodeHealth struct {
 	Host             string
@@ -290,6 +389,7 @@ type NodeHealth struct {
 	PID              int
 	Uptime           time.Duration
 	Version          string // MongoDB binary version
+	Limits           string // Effective cgroup limits, e.g. "memory=4GiB cpu=200%"
 }
 
 // PortMapping contains all ports used by the cluster
@@ -333,7 +433,7 @@ type ExporterHealth struct {
 // getMongoDBVersion connects to a MongoDB node and retrieves its version
 func (c *Checker) getMongoDBVersion(ctx context.Context, host string, port int) (string, error) {
 	// Create connection URI
-	uri := fmt.Sprintf("mongodb://%s:%d", host, port)
+	uri := "mongodb://" + topology.GetNodeID(host, port)
 
 	// Create client options with short timeout
 	clientOpts := options.Client().
@@ -341,6 +441,9 @@ func (c *Checker) getMongoDBVersion(ctx context.Context, host string, port int)
 		SetConnectTimeout(2 * time.Second).
 		SetServerSelectionTimeout(2 * time.Second).
 		SetDirect(true) // Direct connection to this specific node
+	if err := c.metadata.Security.ApplyTLS(clientOpts); err != nil {
+		return "", err
+	}
 
 	// Connect to MongoDB
 	client, err := mongo.Connect(ctx, clientOpts)
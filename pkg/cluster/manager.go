@@ -3,6 +3,7 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/zph/mup/pkg/executor"
@@ -13,6 +14,16 @@ import (
 // Manager manages cluster lifecycle operations
 type Manager struct {
 	metaMgr *meta.Manager
+
+	// dial opens an SSH executor to a host of a remote cluster
+	dial func(config executor.SSHConfig) (executor.Executor, error)
+
+	// members checks replica set members during rolling restarts
+	members memberOps
+
+	// confirmed holds the protected clusters the operator confirmed with
+	// ConfirmProtected
+	confirmed map[string]bool
 }
 
 // NewManager creates a new cluster manager
@@ -24,6 +35,8 @@ func NewManager() (*Manager, error) {
 
 	return &Manager{
 		metaMgr: metaMgr,
+		dial:    dialSSH,
+		members: upgradeMemberOps{},
 	}, nil
 }
 
@@ -35,6 +48,10 @@ func (m *Manager) Start(ctx context.Context, clusterName string, nodeFilter stri
 		return err
 	}
 
+	if metadata.DeployMode == "remote" {
+		return m.startRemote(ctx, metadata, nodeFilter)
+	}
+
 	// Load supervisor manager
 	clusterDir := m.metaMgr.GetClusterDir(clusterName)
 	supMgr, err := supervisor.LoadManager(clusterDir, clusterName)
@@ -58,7 +75,7 @@ func (m *Manager) Start(ctx context.Context, clusterName string, nodeFilter stri
 
 	for i := range metadata.Nodes {
 		node := &metadata.Nodes[i]
-		if nodeFilter != "" && fmt.Sprintf("%s:%d", node.Host, node.Port) != nodeFilter {
+		if nodeFilter != "" && node.ID() != nodeFilter {
 			continue
 		}
 
@@ -163,6 +180,13 @@ func (m *Manager) Stop(ctx context.Context, clusterName string, nodeFilter strin
 	if err != nil {
 		return err
 	}
+	if err := m.checkProtected(metadata, "stop"); err != nil {
+		return err
+	}
+
+	if metadata.DeployMode == "remote" {
+		return m.stopRemote(ctx, metadata, nodeFilter)
+	}
 
 	// Load supervisor manager
 	clusterDir := m.metaMgr.GetClusterDir(clusterName)
@@ -202,7 +226,7 @@ func (m *Manager) Stop(ctx context.Context, clusterName string, nodeFilter strin
 
 	for i := range metadata.Nodes {
 		node := &metadata.Nodes[i]
-		if nodeFilter != "" && fmt.Sprintf("%s:%d", node.Host, node.Port) != nodeFilter {
+		if nodeFilter != "" && node.ID() != nodeFilter {
 			continue
 		}
 
@@ -269,23 +293,30 @@ func (m *Manager) stopMonitoring(_ context.Context, clusterName string) error {
 }
 
 // createExecutors creates executors for the cluster
+// Local clusters get a LocalExecutor per host; remote clusters get an
+// SSHExecutor per host built from the stored topology's global settings.
 func (m *Manager) createExecutors(metadata *meta.ClusterMetadata) (map[string]executor.Executor, error) {
-	executors := make(map[string]executor.Executor)
-
-	// Get unique hosts
-	hosts := make(map[string]bool)
-	for _, node := range metadata.Nodes {
-		hosts[node.Host] = true
+	if metadata.DeployMode != "local" {
+		executors, failures, err := m.connectHosts(metadata)
+		if err != nil {
+			return nil, err
+		}
+		if len(failures) > 0 {
+			m.closeExecutors(executors)
+			var msgs []string
+			for _, host := range clusterHosts(metadata) {
+				if err := failures[host]; err != nil {
+					msgs = append(msgs, fmt.Sprintf("%s: %v", host, err))
+				}
+			}
+			return nil, fmt.Errorf("failed to connect to %d host(s): %s", len(failures), strings.Join(msgs, "; "))
+		}
+		return executors, nil
 	}
 
-	// Create executor for each host
-	for host := range hosts {
-		if metadata.DeployMode == "local" {
-			executors[host] = executor.NewLocalExecutor()
-		} else {
-			// TODO: Create SSH executor
-			return nil, fmt.Errorf("remote mode not yet implemented")
-		}
+	executors := make(map[string]executor.Executor)
+	for _, host := range clusterHosts(metadata) {
+		executors[host] = executor.NewLocalExecutor()
 	}
 
 	return executors, nil
@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zph/mup/pkg/ports"
+)
+
+// ListPorts displays the port registry: every port reserved by a managed
+// cluster
+func (m *Manager) ListPorts(format string) error {
+	registry, err := ports.LoadDefault()
+	if err != nil {
+		return err
+	}
+	reservations := registry.Reservations
+	if reservations == nil {
+		reservations = []ports.Reservation{}
+	}
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(map[string]interface{}{"ports": reservations}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal to JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	case "yaml":
+		data, err := yaml.Marshal(map[string]interface{}{"ports": reservations})
+		if err != nil {
+			return fmt.Errorf("failed to marshal to YAML: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Println("============================================================")
+	fmt.Printf("Reserved Ports (%d)\n", len(reservations))
+	fmt.Println("============================================================")
+	fmt.Println()
+
+	if len(reservations) == 0 {
+		fmt.Println("No ports reserved")
+	} else {
+		fmt.Printf("%-15s  %-20s  %-6s  %-17s  %s\n", "CLUSTER", "HOST", "PORT", "SERVICE", "NODE")
+		fmt.Println("------------------------------------------------------------")
+		for _, r := range reservations {
+			fmt.Printf("%-15s  %-20s  %-6d  %-17s  %s\n", r.Cluster, r.Host, r.Port, r.Service, r.Node)
+		}
+	}
+
+	// Clusters deployed before the registry existed hold ports it does not know
+	clusterNames, err := m.metaMgr.List()
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+	var missing []string
+	for _, name := range clusterNames {
+		if len(registry.Cluster(name)) == 0 {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Println()
+		fmt.Printf("Not in the registry (deployed before it existed): %v\n", missing)
+	}
+
+	fmt.Println("============================================================")
+	return nil
+}
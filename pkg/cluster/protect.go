@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/plan"
+)
+
+// SetProtected protects a cluster from destructive commands, or lifts the
+// protection
+func (m *Manager) SetProtected(clusterName string, protected bool) error {
+	metadata, err := m.metaMgr.Load(clusterName)
+	if err != nil {
+		return err
+	}
+	if metadata.Protected == protected {
+		if protected {
+			fmt.Printf("Cluster '%s' is already protected\n", clusterName)
+		} else {
+			fmt.Printf("Cluster '%s' is not protected\n", clusterName)
+		}
+		return nil
+	}
+
+	metadata.Protected = protected
+	if err := m.metaMgr.Save(metadata); err != nil {
+		return err
+	}
+	if protected {
+		fmt.Printf("✓ Cluster '%s' is protected: destroy, stop and dropping users now require its name\n", clusterName)
+	} else {
+		fmt.Printf("✓ Cluster '%s' is no longer protected\n", clusterName)
+	}
+	return nil
+}
+
+// ConfirmProtected guards a destructive action on a cluster. Unprotected
+// clusters pass. A protected cluster passes when acknowledged, the value of
+// --i-know-this-is-prod, is its name, or else when the operator types its
+// name on in; m then allows destructive actions and plans on it. It reports
+// whether the cluster is protected, so callers can skip their own
+// confirmation prompt.
+func (m *Manager) ConfirmProtected(clusterName, action, acknowledged string, in io.Reader, out io.Writer) (bool, error) {
+	// Missing clusters are reported by the action itself
+	protected, err := m.metaMgr.IsProtected(clusterName)
+	if err != nil || !protected {
+		return false, err
+	}
+	if err := confirmClusterName(clusterName, action, acknowledged, in, out); err != nil {
+		return true, err
+	}
+	if m.confirmed == nil {
+		m.confirmed = make(map[string]bool)
+	}
+	m.confirmed[clusterName] = true
+	return true, nil
+}
+
+// checkProtected refuses action on a protected cluster the operator has not
+// confirmed with ConfirmProtected
+func (m *Manager) checkProtected(metadata *meta.ClusterMetadata, action string) error {
+	if !metadata.Protected || m.confirmed[metadata.Name] {
+		return nil
+	}
+	return fmt.Errorf("cluster '%s' is protected: refusing to %s without confirming its name", metadata.Name, action)
+}
+
+// confirmClusterName requires acknowledged, or a line read from in, to be
+// clusterName
+func confirmClusterName(clusterName, action, acknowledged string, in io.Reader, out io.Writer) error {
+	refused := fmt.Errorf("cluster '%s' is protected: refusing to %s (type its name or pass --i-know-this-is-prod=%s)", clusterName, action, clusterName)
+	if acknowledged != "" {
+		if acknowledged != clusterName {
+			return fmt.Errorf("--i-know-this-is-prod=%s does not match cluster '%s'", acknowledged, clusterName)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(out, "Cluster '%s' is PROTECTED. Type its name to %s it: ", clusterName, action)
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(out)
+		return refused
+	}
+	if strings.TrimSpace(line) != clusterName {
+		return refused
+	}
+	return nil
+}
+
+// ValidatePlan adds the checks that depend on the state of p's cluster to
+// p's validation: plans must not stop processes, remove directories or drop
+// users and roles of a protected cluster the operator has not confirmed
+func (m *Manager) ValidatePlan(p *plan.Plan) error {
+	protected, err := m.metaMgr.IsProtected(p.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	result := plan.ValidateProtected(p, protected && !m.confirmed[p.ClusterName])
+	p.Validation = plan.CombineValidationResults(p.Validation, result)
+	if !result.Valid {
+		return plan.NewValidationError(result.Errors)
+	}
+	return nil
+}
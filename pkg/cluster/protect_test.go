@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/plan"
+)
+
+func TestProtect(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m, err := NewManager()
+	require.NoError(t, err)
+	require.NoError(t, m.metaMgr.Save(&meta.ClusterMetadata{Name: "prod"}))
+
+	var out bytes.Buffer
+	protected, err := m.ConfirmProtected("prod", "destroy", "", strings.NewReader(""), &out)
+	require.NoError(t, err)
+	assert.False(t, protected)
+	assert.Empty(t, out.String(), "unprotected clusters are not prompted")
+
+	require.NoError(t, m.SetProtected("prod", true))
+	metadata, err := m.metaMgr.Load("prod")
+	require.NoError(t, err)
+	assert.True(t, metadata.Protected)
+
+	tests := []struct {
+		name         string
+		acknowledged string
+		input        string
+		wantErr      string
+	}{
+		{name: "flag", acknowledged: "prod"},
+		{name: "flag for another cluster", acknowledged: "dev", wantErr: "--i-know-this-is-prod=dev does not match cluster 'prod'"},
+		{name: "typed name", input: "prod\n"},
+		{name: "typed something else", input: "yes\n", wantErr: "cluster 'prod' is protected: refusing to destroy"},
+		{name: "no input", wantErr: "cluster 'prod' is protected: refusing to destroy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protected, err := m.ConfirmProtected("prod", "destroy", tt.acknowledged, strings.NewReader(tt.input), &bytes.Buffer{})
+			assert.True(t, protected)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Another manager has not confirmed the cluster
+	m, err = NewManager()
+	require.NoError(t, err)
+	assert.ErrorContains(t, m.Stop(context.Background(), "prod", ""), "cluster 'prod' is protected: refusing to stop")
+	assert.ErrorContains(t, m.Destroy(context.Background(), "prod", false), "cluster 'prod' is protected: refusing to destroy")
+
+	p := &plan.Plan{
+		ClusterName: "prod",
+		Validation:  plan.ValidationResult{Valid: true},
+		Phases: []plan.PlannedPhase{{Operations: []plan.PlannedOperation{
+			{ID: "users-001", Type: plan.OpManageDBUser, Target: plan.OperationTarget{Name: "app@admin"},
+				Changes: []plan.Change{{ResourceType: "db_user", ResourceID: "app@admin", Action: plan.ActionCreate}}},
+			{ID: "drop-002", Type: plan.OpManageDBUser, Target: plan.OperationTarget{Name: "old@admin"},
+				Changes: []plan.Change{{ResourceType: "db_user", ResourceID: "old@admin", Action: plan.ActionDelete}}},
+			{ID: "stop-003", Type: plan.OpStopProcess, Target: plan.OperationTarget{Name: "mongod-27017"}},
+			{ID: "cleanup-004", Type: plan.OpRemoveDirectory, Target: plan.OperationTarget{Name: "/data/db"}},
+		}}},
+	}
+	err = m.ValidatePlan(p)
+	assert.True(t, plan.IsValidationError(err))
+	assert.False(t, p.Validation.Valid)
+	assert.Len(t, p.Validation.Errors, 3, "only the drop, stop and removal are refused")
+
+	// Confirming the cluster allows its destructive plans
+	_, err = m.ConfirmProtected("prod", "drop users of", "prod", strings.NewReader(""), &bytes.Buffer{})
+	require.NoError(t, err)
+	p.Validation = plan.ValidationResult{Valid: true}
+	require.NoError(t, m.ValidatePlan(p))
+
+	m, err = NewManager()
+	require.NoError(t, err)
+	require.NoError(t, m.SetProtected("prod", false))
+	p.Validation = plan.ValidationResult{Valid: true}
+	require.NoError(t, m.ValidatePlan(p))
+	assert.True(t, p.Validation.Valid)
+
+	p.ClusterName = "new"
+	assert.NoError(t, m.ValidatePlan(p), "clusters not deployed yet are not protected")
+}
+
+func TestConfirmProtected_MetadataErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m, err := NewManager()
+	require.NoError(t, err)
+
+	protected, err := m.ConfirmProtected("missing", "destroy", "", strings.NewReader(""), &bytes.Buffer{})
+	require.NoError(t, err, "missing clusters are reported by the action itself")
+	assert.False(t, protected)
+
+	// Metadata that cannot be read must not count as unprotected
+	require.NoError(t, m.metaMgr.Save(&meta.ClusterMetadata{Name: "prod", Protected: true}))
+	require.NoError(t, os.WriteFile(m.metaMgr.GetMetaFile("prod"), []byte("protected: [\n"), 0644))
+	protected, err = m.ConfirmProtected("prod", "destroy", "", strings.NewReader("prod\n"), &bytes.Buffer{})
+	assert.Error(t, err)
+	assert.False(t, protected)
+}
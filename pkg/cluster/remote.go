@@ -0,0 +1,338 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/supervisor"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// sshConnectTimeout bounds how long we wait for each host's SSH handshake
+const sshConnectTimeout = 15 * time.Second
+
+// SSHPasswordEnv holds the SSH password for hosts that accept neither the
+// topology's ssh_key_file nor the agent's keys. Passwords are never stored
+// in the topology.
+const SSHPasswordEnv = "MUP_SSH_PASSWORD"
+
+// HostResult is the outcome of an operation fanned out to one host
+type HostResult struct {
+	Host    string
+	Summary string
+	Err     error
+}
+
+// clusterHosts returns the unique hosts in the cluster, sorted
+func clusterHosts(metadata *meta.ClusterMetadata) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, node := range metadata.Nodes {
+		if !seen[node.Host] {
+			seen[node.Host] = true
+			hosts = append(hosts, node.Host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// forEachHost runs fn concurrently for every host and returns the results
+// in host order. A failure on one host never cancels the others.
+func forEachHost(ctx context.Context, hosts []string, fn func(ctx context.Context, host string) (string, error)) []HostResult {
+	results := make([]HostResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			summary, err := fn(ctx, host)
+			results[i] = HostResult{Host: host, Summary: summary, Err: err}
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
+
+// printHostResults prints one line per host and returns the number of failures
+func printHostResults(results []HostResult) int {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("  ✗ %s: %v\n", r.Host, r.Err)
+			continue
+		}
+		fmt.Printf("  ✓ %s: %s\n", r.Host, r.Summary)
+	}
+	return failed
+}
+
+// sshConfig returns the SSH settings for host from the cluster's global
+// topology settings
+func sshConfig(global topology.GlobalConfig, host string) executor.SSHConfig {
+	return executor.SSHConfig{
+		Host:     host,
+		Port:     global.SSHPort,
+		User:     global.User,
+		KeyFile:  global.SSHKeyFile,
+		Password: os.Getenv(SSHPasswordEnv),
+		Timeout:  sshConnectTimeout,
+	}
+}
+
+// dialSSH opens an SSH executor, see Manager.dial
+func dialSSH(config executor.SSHConfig) (executor.Executor, error) {
+	return executor.NewSSHExecutor(config)
+}
+
+// connectHosts opens an SSH executor to every host in a remote cluster in
+// parallel. Hosts that could not be reached are returned in the error map so
+// callers can report per-host results instead of failing the whole cluster.
+func (m *Manager) connectHosts(metadata *meta.ClusterMetadata) (map[string]executor.Executor, map[string]error, error) {
+	if metadata.Topology == nil {
+		return nil, nil, fmt.Errorf("cluster metadata has no topology - cannot resolve SSH settings")
+	}
+	global := metadata.Topology.Global
+
+	executors := make(map[string]executor.Executor)
+	failures := make(map[string]error)
+	var mu sync.Mutex
+
+	forEachHost(context.Background(), clusterHosts(metadata), func(_ context.Context, host string) (string, error) {
+		exec, err := m.dial(sshConfig(global, host))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failures[host] = err
+			return "", err
+		}
+		executors[host] = exec
+		return "connected", nil
+	})
+
+	return executors, failures, nil
+}
+
+// remoteSupervisors loads the supervisord controller on every connected host
+func remoteSupervisors(ctx context.Context, metadata *meta.ClusterMetadata, executors map[string]executor.Executor) (map[string]*supervisor.RemoteManager, map[string]error, error) {
+	resolver := paths.NewRemotePathResolver(&metadata.Topology.Global)
+	supervisorDir, err := resolver.SupervisorDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	binDir, err := resolver.BinDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hosts := make([]string, 0, len(executors))
+	for host := range executors {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	managers := make(map[string]*supervisor.RemoteManager)
+	failures := make(map[string]error)
+	var mu sync.Mutex
+
+	forEachHost(ctx, hosts, func(ctx context.Context, host string) (string, error) {
+		mgr, err := supervisor.NewRemoteManager(ctx, executors[host], host, supervisorDir, binDir)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failures[host] = err
+			return "", err
+		}
+		managers[host] = mgr
+		return "loaded", nil
+	})
+
+	return managers, failures, nil
+}
+
+// nodesByHost groups the nodes matching nodeFilter by host
+func nodesByHost(metadata *meta.ClusterMetadata, nodeFilter string) map[string][]*meta.NodeMetadata {
+	grouped := make(map[string][]*meta.NodeMetadata)
+	for i := range metadata.Nodes {
+		node := &metadata.Nodes[i]
+		if nodeFilter != "" && node.ID() != nodeFilter {
+			continue
+		}
+		grouped[node.Host] = append(grouped[node.Host], node)
+	}
+	return grouped
+}
+
+// sortedHosts returns the keys of a host-grouped node map, sorted
+func sortedHosts(grouped map[string][]*meta.NodeMetadata) []string {
+	hosts := make([]string, 0, len(grouped))
+	for host := range grouped {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// withRemoteSupervisors connects to every host and loads its supervisord
+// controller. The returned cleanup closes all SSH connections.
+func (m *Manager) withRemoteSupervisors(ctx context.Context, metadata *meta.ClusterMetadata) (map[string]*supervisor.RemoteManager, map[string]error, func(), error) {
+	executors, failures, err := m.connectHosts(metadata)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cleanup := func() { m.closeExecutors(executors) }
+
+	managers, supFailures, err := remoteSupervisors(ctx, metadata, executors)
+	if err != nil {
+		cleanup()
+		return nil, nil, nil, err
+	}
+	for host, err := range supFailures {
+		failures[host] = err
+	}
+
+	return managers, failures, cleanup, nil
+}
+
+// startRemote starts a remote cluster by driving each host's supervisord over SSH
+func (m *Manager) startRemote(ctx context.Context, metadata *meta.ClusterMetadata, nodeFilter string) error {
+	grouped := nodesByHost(metadata, nodeFilter)
+	if len(grouped) == 0 {
+		return fmt.Errorf("no nodes matched filter '%s'", nodeFilter)
+	}
+
+	fmt.Printf("Starting cluster '%s' on %d host(s) via SSH...\n", metadata.Name, len(grouped))
+
+	managers, failures, cleanup, err := m.withRemoteSupervisors(ctx, metadata)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	results := forEachHost(ctx, sortedHosts(grouped), func(ctx context.Context, host string) (string, error) {
+		if err := failures[host]; err != nil {
+			return "", err
+		}
+		mgr := managers[host]
+
+		if err := mgr.Start(ctx); err != nil {
+			return "", err
+		}
+
+		var names []string
+		for _, node := range grouped[host] {
+			if node.SupervisorProgramName == "" {
+				return "", fmt.Errorf("node %s:%d missing supervisor program name - cluster may need redeployment", node.Host, node.Port)
+			}
+			names = append(names, node.SupervisorProgramName)
+		}
+		if err := mgr.StartProcesses(ctx, names); err != nil {
+			return "", err
+		}
+
+		var started []string
+		for _, node := range grouped[host] {
+			status, err := mgr.GetProcessStatus(ctx, node.SupervisorProgramName)
+			if err != nil {
+				started = append(started, fmt.Sprintf("%s:%d (status unknown)", node.Type, node.Port))
+				continue
+			}
+			node.PID = status.PID
+			started = append(started, fmt.Sprintf("%s:%d %s", node.Type, node.Port, strings.ToLower(status.State)))
+		}
+		return strings.Join(started, ", "), nil
+	})
+
+	failed := printHostResults(results)
+
+	if nodeFilter == "" && failed == 0 {
+		metadata.Status = "running"
+		metadata.SupervisorRunning = true
+	}
+	if err := m.metaMgr.Save(metadata); err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to start nodes on %d of %d host(s)", failed, len(results))
+	}
+
+	fmt.Printf("\n✓ Started nodes on %d host(s) via supervisor\n", len(results))
+	return nil
+}
+
+// stopRemote stops a remote cluster by driving each host's supervisord over SSH
+func (m *Manager) stopRemote(ctx context.Context, metadata *meta.ClusterMetadata, nodeFilter string) error {
+	grouped := nodesByHost(metadata, nodeFilter)
+	if len(grouped) == 0 {
+		if nodeFilter != "" {
+			return fmt.Errorf("no nodes matched filter '%s'", nodeFilter)
+		}
+		return nil
+	}
+
+	fmt.Printf("Stopping cluster '%s' on %d host(s) via SSH...\n", metadata.Name, len(grouped))
+
+	managers, failures, cleanup, err := m.withRemoteSupervisors(ctx, metadata)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	results := forEachHost(ctx, sortedHosts(grouped), func(ctx context.Context, host string) (string, error) {
+		if err := failures[host]; err != nil {
+			return "", err
+		}
+		mgr := managers[host]
+
+		if !mgr.IsRunning(ctx) {
+			return "supervisor not running", nil
+		}
+
+		var names []string
+		for _, node := range grouped[host] {
+			if node.SupervisorProgramName != "" {
+				names = append(names, node.SupervisorProgramName)
+			}
+		}
+		if err := mgr.StopProcesses(ctx, names); err != nil {
+			return "", err
+		}
+
+		// Only shut supervisord down when the whole cluster is stopping
+		if nodeFilter == "" {
+			if err := mgr.Stop(ctx); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("stopped %d node(s)", len(names)), nil
+	})
+
+	failed := printHostResults(results)
+
+	if nodeFilter == "" && failed == 0 {
+		metadata.Status = "stopped"
+		metadata.SupervisorRunning = false
+	}
+	if err := m.metaMgr.Save(metadata); err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to stop nodes on %d of %d host(s)", failed, len(results))
+	}
+
+	fmt.Printf("\n✓ Stopped nodes on %d host(s)\n", len(results))
+	return nil
+}
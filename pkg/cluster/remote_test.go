@@ -0,0 +1,212 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/simulation"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// fakeSupervisorHost is a simulated host whose supervisord stops running
+// once it is shut down, and reports every program as RUNNING
+type fakeSupervisorHost struct {
+	*simulation.SimulationExecutor
+
+	mu       sync.Mutex
+	stopped  bool
+	commands []string
+
+	// onCommand, when set, sees every command before it runs
+	onCommand func(command string)
+}
+
+func newFakeSupervisorHost() *fakeSupervisorHost {
+	return &fakeSupervisorHost{SimulationExecutor: simulation.NewExecutor(simulation.NewConfig())}
+}
+
+func (h *fakeSupervisorHost) ExecuteContext(ctx context.Context, command string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.commands = append(h.commands, command)
+	if h.onCommand != nil {
+		h.onCommand(command)
+	}
+
+	switch {
+	case strings.HasPrefix(command, "test -f") && strings.Contains(command, "supervisor.pid"):
+		if h.stopped {
+			return "", errors.New("exit status 1")
+		}
+	case strings.Contains(command, " ctl ") && strings.HasSuffix(command, " shutdown"):
+		h.stopped = true
+	case strings.Contains(command, " ctl ") && strings.Contains(command, " status "):
+		fields := strings.Fields(command)
+		return fields[len(fields)-1] + " RUNNING pid 4242, uptime 0:00:01\n", nil
+	}
+	return h.SimulationExecutor.ExecuteContext(ctx, command)
+}
+
+func (h *fakeSupervisorHost) ctlCommands(verb string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var matched []string
+	for _, command := range h.commands {
+		if strings.Contains(command, " ctl ") && strings.Contains(command, " "+verb) {
+			matched = append(matched, command)
+		}
+	}
+	return matched
+}
+
+// newRemoteTestManager returns a manager whose SSH connections reach the
+// fake hosts, failing for hosts that are not in hosts
+func newRemoteTestManager(t *testing.T, hosts map[string]*fakeSupervisorHost) (*Manager, *meta.ClusterMetadata, *[]executor.SSHConfig) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(SSHPasswordEnv, "")
+
+	m, err := NewManager()
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var dialed []executor.SSHConfig
+	m.dial = func(config executor.SSHConfig) (executor.Executor, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dialed = append(dialed, config)
+		if host, ok := hosts[config.Host]; ok {
+			return host, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	metadata := &meta.ClusterMetadata{
+		Name:       "prod",
+		DeployMode: "remote",
+		Status:     "stopped",
+		Topology: &topology.Topology{Global: topology.GlobalConfig{
+			User:       "mongo",
+			SSHPort:    2222,
+			SSHKeyFile: "/home/ops/.ssh/mongo_ed25519",
+			DeployDir:  "/opt/mongodb",
+		}},
+	}
+	for _, host := range []string{"db1", "db2", "db3"} {
+		metadata.Nodes = append(metadata.Nodes, meta.NodeMetadata{
+			Type:                  "mongod",
+			Host:                  host,
+			Port:                  27017,
+			ReplicaSet:            "rs0",
+			DataDir:               "/data/mongodb/" + host,
+			LogDir:                "/var/log/mongodb/" + host,
+			ConfigDir:             "/etc/mongodb/" + host,
+			SupervisorProgramName: "mongod-27017",
+		})
+	}
+	require.NoError(t, m.metaMgr.Save(metadata))
+	return m, metadata, &dialed
+}
+
+func TestConnectHosts_SSHConfig(t *testing.T) {
+	m, metadata, dialed := newRemoteTestManager(t, map[string]*fakeSupervisorHost{})
+	t.Setenv(SSHPasswordEnv, "hunter2")
+
+	executors, failures, err := m.connectHosts(metadata)
+	require.NoError(t, err)
+	assert.Empty(t, executors)
+	assert.Len(t, failures, 3)
+
+	require.Len(t, *dialed, 3)
+	for _, config := range *dialed {
+		assert.Equal(t, "mongo", config.User)
+		assert.Equal(t, 2222, config.Port)
+		assert.Equal(t, "/home/ops/.ssh/mongo_ed25519", config.KeyFile)
+		assert.Equal(t, "hunter2", config.Password)
+		assert.Equal(t, sshConnectTimeout, config.Timeout)
+	}
+}
+
+func TestStartStopRemote(t *testing.T) {
+	ctx := context.Background()
+	hosts := map[string]*fakeSupervisorHost{"db1": newFakeSupervisorHost(), "db2": newFakeSupervisorHost(), "db3": newFakeSupervisorHost()}
+	m, metadata, _ := newRemoteTestManager(t, hosts)
+
+	require.NoError(t, m.startRemote(ctx, metadata, ""))
+	assert.Equal(t, "running", metadata.Status)
+	for name, host := range hosts {
+		assert.Len(t, host.ctlCommands("start"), 1, name)
+	}
+	for _, node := range metadata.Nodes {
+		assert.Equal(t, 4242, node.PID)
+	}
+
+	require.NoError(t, m.stopRemote(ctx, metadata, "db2:27017"))
+	assert.Len(t, hosts["db2"].ctlCommands("stop"), 1)
+	assert.Empty(t, hosts["db2"].ctlCommands("shutdown"), "a single node stop keeps supervisord running")
+	assert.Empty(t, hosts["db1"].ctlCommands("stop"))
+	assert.Equal(t, "running", metadata.Status)
+
+	require.NoError(t, m.stopRemote(ctx, metadata, ""))
+	for name, host := range hosts {
+		assert.Len(t, host.ctlCommands("shutdown"), 1, name)
+	}
+	saved, err := m.metaMgr.Load("prod")
+	require.NoError(t, err)
+	assert.Equal(t, "stopped", saved.Status)
+	assert.False(t, saved.SupervisorRunning)
+}
+
+func TestStartStopRemote_HostFailures(t *testing.T) {
+	ctx := context.Background()
+	hosts := map[string]*fakeSupervisorHost{"db1": newFakeSupervisorHost(), "db2": newFakeSupervisorHost()}
+	m, metadata, _ := newRemoteTestManager(t, hosts)
+
+	err := m.startRemote(ctx, metadata, "")
+	assert.EqualError(t, err, "failed to start nodes on 1 of 3 host(s)")
+	assert.Len(t, hosts["db1"].ctlCommands("start"), 1, "reachable hosts are started anyway")
+	assert.Len(t, hosts["db2"].ctlCommands("start"), 1, "reachable hosts are started anyway")
+	assert.Equal(t, "stopped", metadata.Status)
+
+	metadata.Status = "running"
+	err = m.stopRemote(ctx, metadata, "")
+	assert.EqualError(t, err, "failed to stop nodes on 1 of 3 host(s)")
+	assert.Len(t, hosts["db1"].ctlCommands("shutdown"), 1)
+	assert.Equal(t, "running", metadata.Status, "a partial stop keeps the cluster status")
+
+	err = m.startRemote(ctx, metadata, "db9:27017")
+	assert.EqualError(t, err, "no nodes matched filter 'db9:27017'")
+}
+
+func TestDestroyRemote(t *testing.T) {
+	ctx := context.Background()
+	hosts := map[string]*fakeSupervisorHost{"db1": newFakeSupervisorHost(), "db2": newFakeSupervisorHost()}
+	m, metadata, _ := newRemoteTestManager(t, hosts)
+
+	err := m.destroyRemote(ctx, metadata)
+	assert.EqualError(t, err, "failed to clean up 1 of 3 host(s); metadata kept so destroy can be retried")
+
+	var removed []string
+	for _, op := range hosts["db1"].GetOperations() {
+		if op.Type == "remove_directory" {
+			removed = append(removed, op.Target)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"/data/mongodb/db1",
+		"/var/log/mongodb/db1",
+		"/etc/mongodb/db1",
+		"/opt/mongodb/supervisor",
+	}, removed)
+
+	hosts["db3"] = newFakeSupervisorHost()
+	require.NoError(t, m.destroyRemote(ctx, metadata))
+}
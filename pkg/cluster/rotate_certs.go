@@ -0,0 +1,425 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/supervisor"
+	"github.com/zph/mup/pkg/upgrade"
+)
+
+// rotationTimeout bounds how long a restarted node may take to rejoin
+const rotationTimeout = 2 * time.Minute
+
+// RotateCertsOptions controls a certificate rotation
+type RotateCertsOptions struct {
+	NewCA bool // Replace the cluster CA before reissuing node certificates
+}
+
+// rotationGroup is a set of nodes restarted one after another: a replica
+// set, or a node outside any replica set
+type rotationGroup struct {
+	replicaSet string
+	nodes      []*meta.NodeMetadata
+}
+
+// rotationGroups orders a cluster's nodes for a rolling restart: the config
+// server replica set, the other replica sets by name, then nodes outside a
+// replica set with the mongos routers last
+func rotationGroups(metadata *meta.ClusterMetadata) []rotationGroup {
+	sets := make(map[string]*rotationGroup)
+	var names []string
+	var standalone, routers []rotationGroup
+	configSet := ""
+
+	for i := range metadata.Nodes {
+		node := &metadata.Nodes[i]
+		switch {
+		case node.Type == "mongos":
+			routers = append(routers, rotationGroup{nodes: []*meta.NodeMetadata{node}})
+		case node.ReplicaSet == "":
+			standalone = append(standalone, rotationGroup{nodes: []*meta.NodeMetadata{node}})
+		default:
+			if node.Type == "config" {
+				configSet = node.ReplicaSet
+			}
+			if _, ok := sets[node.ReplicaSet]; !ok {
+				sets[node.ReplicaSet] = &rotationGroup{replicaSet: node.ReplicaSet}
+				names = append(names, node.ReplicaSet)
+			}
+			sets[node.ReplicaSet].nodes = append(sets[node.ReplicaSet].nodes, node)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == configSet) != (names[j] == configSet) {
+			return names[i] == configSet
+		}
+		return names[i] < names[j]
+	})
+
+	groups := make([]rotationGroup, 0, len(names)+len(standalone)+len(routers))
+	for _, name := range names {
+		groups = append(groups, *sets[name])
+	}
+	groups = append(groups, standalone...)
+	return append(groups, routers...)
+}
+
+// memberOps are the MongoDB calls a rolling restart makes to order and
+// check the nodes it restarts
+type memberOps interface {
+	DetectRole(ctx context.Context, hostPort, rsName string, allHosts []string, sec *meta.SecurityMetadata) (string, error)
+	StepDown(ctx context.Context, primary, rsName string, allHosts []string, sec *meta.SecurityMetadata) error
+	WaitForMember(ctx context.Context, hostPort, rsName string, allHosts []string, sec *meta.SecurityMetadata, timeout time.Duration) error
+	WaitForNode(ctx context.Context, hostPort string, sec *meta.SecurityMetadata, timeout time.Duration) error
+}
+
+// upgradeMemberOps makes the calls with the upgrade package's helpers
+type upgradeMemberOps struct{}
+
+func (upgradeMemberOps) DetectRole(ctx context.Context, hostPort, rsName string, allHosts []string, sec *meta.SecurityMetadata) (string, error) {
+	return upgrade.DetectNodeRole(ctx, hostPort, rsName, allHosts, sec)
+}
+
+func (upgradeMemberOps) StepDown(ctx context.Context, primary, rsName string, allHosts []string, sec *meta.SecurityMetadata) error {
+	_, err := upgrade.StepDownPrimary(ctx, primary, rsName, allHosts, sec)
+	return err
+}
+
+func (upgradeMemberOps) WaitForMember(ctx context.Context, hostPort, rsName string, allHosts []string, sec *meta.SecurityMetadata, timeout time.Duration) error {
+	return upgrade.WaitForMemberState(ctx, hostPort, rsName, allHosts, sec, timeout)
+}
+
+func (upgradeMemberOps) WaitForNode(ctx context.Context, hostPort string, sec *meta.SecurityMetadata, timeout time.Duration) error {
+	return upgrade.WaitForNode(ctx, hostPort, sec, timeout)
+}
+
+// certRotator writes certificates for a cluster's nodes and restarts them
+type certRotator struct {
+	metadata *meta.ClusterMetadata
+	layout   *paths.ClusterLayout
+	members  memberOps
+	local    *supervisor.Manager                  // Local deployments
+	remote   map[string]*supervisor.RemoteManager // Remote deployments, by host
+}
+
+// RotateCerts reissues every node certificate and restarts the nodes one at
+// a time so the cluster stays available. Secondaries restart first, then
+// the primary after stepping down, and each node must rejoin before the
+// next one restarts.
+//
+// With NewCA the cluster moves to a new CA in two rolling restarts: the
+// first makes every node trust both CAs, the second switches the nodes to
+// certificates of the new CA. The old CA is dropped from the CA file
+// afterwards and nodes stop trusting it on their next restart.
+func (m *Manager) RotateCerts(ctx context.Context, clusterName string, opts RotateCertsOptions) error {
+	metadata, err := m.metaMgr.Load(clusterName)
+	if err != nil {
+		return err
+	}
+	if !metadata.Security.TLSEnabled() {
+		return fmt.Errorf("cluster '%s' does not use TLS", clusterName)
+	}
+	if metadata.Topology == nil {
+		return fmt.Errorf("cluster metadata has no topology - cannot resolve certificate names")
+	}
+
+	layout := paths.NewClusterLayout(m.metaMgr.GetClusterDir(clusterName))
+	ca, err := loadClusterCA(layout)
+	if err != nil {
+		return err
+	}
+
+	r := &certRotator{metadata: metadata, layout: layout, members: m.members}
+	cleanup, err := m.connectRotator(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	groups := rotationGroups(metadata)
+	fmt.Printf("Rotating certificates of cluster '%s' (%d nodes)\n", clusterName, len(metadata.Nodes))
+
+	if opts.NewCA {
+		newCA, err := security.NewCA(clusterName)
+		if err != nil {
+			return err
+		}
+		// The new CA comes first so later deploys issue from it
+		bundle := security.CABundle(newCA, ca)
+		if err := r.installCA(newCA, bundle); err != nil {
+			return err
+		}
+		if err := r.distributeCA(ctx, bundle); err != nil {
+			return err
+		}
+		fmt.Println("\nPhase 1/2: restarting nodes to trust the new CA")
+		if err := r.roll(ctx, groups); err != nil {
+			return err
+		}
+		ca = newCA
+		fmt.Println("\nPhase 2/2: restarting nodes with certificates of the new CA")
+	}
+
+	if err := r.issueCertificates(ctx, ca); err != nil {
+		return err
+	}
+	if err := r.roll(ctx, groups); err != nil {
+		return err
+	}
+
+	if opts.NewCA {
+		if err := r.writeCA(ctx, ca.CertPEM()); err != nil {
+			return err
+		}
+		fmt.Println("\n  Removed the old CA from the CA file; nodes stop trusting it on their next restart")
+	}
+
+	fmt.Printf("\n✓ Rotated certificates of %d nodes\n", len(metadata.Nodes))
+	displayCertificateExpiry(metadata, layout)
+	return nil
+}
+
+// connectRotator loads the supervisord controllers the rotator restarts
+// nodes through. Every host must be reachable.
+func (m *Manager) connectRotator(ctx context.Context, r *certRotator) (func(), error) {
+	if r.metadata.DeployMode != "remote" {
+		supMgr, err := supervisor.LoadManager(m.metaMgr.GetClusterDir(r.metadata.Name), r.metadata.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load supervisor: %w", err)
+		}
+		r.local = supMgr
+		return func() {}, nil
+	}
+
+	managers, failures, cleanup, err := m.withRemoteSupervisors(ctx, r.metadata)
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range clusterHosts(r.metadata) {
+		if err := failures[host]; err != nil {
+			cleanup()
+			return nil, fmt.Errorf("host %s: %w", host, err)
+		}
+	}
+	r.remote = managers
+	return cleanup, nil
+}
+
+// loadClusterCA reads the CA deploy created for the cluster
+func loadClusterCA(layout *paths.ClusterLayout) (*security.CA, error) {
+	certPEM, err := os.ReadFile(layout.CAFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(layout.CAKeyFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	return security.LoadCA(certPEM, keyPEM)
+}
+
+// installCA replaces the CA certificate and key on this machine; the key
+// never leaves it. Both are written to temporary files and renamed into
+// place together, so a failed write keeps the old pair.
+func (r *certRotator) installCA(ca *security.CA, certPEM []byte) error {
+	keyPEM, err := ca.KeyPEM()
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		path    string
+		content []byte
+		mode    os.FileMode
+	}{
+		{r.layout.CAFile(), certPEM, 0644},
+		{r.layout.CAKeyFile(), keyPEM, security.SecretMode},
+	}
+	var staged []string
+	defer func() {
+		for _, tmp := range staged {
+			_ = os.Remove(tmp)
+		}
+	}()
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0700); err != nil {
+			return fmt.Errorf("failed to create secrets directory: %w", err)
+		}
+		tmp := file.path + ".rotate"
+		staged = append(staged, tmp)
+		if err := os.WriteFile(tmp, file.content, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.path, err)
+		}
+		if err := os.Chmod(tmp, file.mode); err != nil {
+			return fmt.Errorf("failed to set permissions on %s: %w", file.path, err)
+		}
+	}
+	for _, file := range files {
+		if err := os.Rename(file.path+".rotate", file.path); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", file.path, err)
+		}
+	}
+	return nil
+}
+
+// writeCA replaces the CA file here and on every host
+func (r *certRotator) writeCA(ctx context.Context, content []byte) error {
+	if err := security.WriteSecret(r.layout.CAFile(), content, 0644); err != nil {
+		return fmt.Errorf("failed to write CA certificate: %w", err)
+	}
+	return r.distributeCA(ctx, content)
+}
+
+// distributeCA uploads the CA file to every host of a remote cluster
+func (r *certRotator) distributeCA(ctx context.Context, content []byte) error {
+	if r.remote == nil {
+		return nil
+	}
+	dest, err := paths.NewRemotePathResolver(&r.metadata.Topology.Global).CAFile()
+	if err != nil {
+		return err
+	}
+	for _, host := range clusterHosts(r.metadata) {
+		if err := executor.UploadSecret(ctx, r.remote[host].Executor(), content, dest, 0644); err != nil {
+			return fmt.Errorf("host %s: %w", host, err)
+		}
+	}
+	return nil
+}
+
+// issueCertificates issues a new certificate for every node and writes it
+// here and on the node's host. Nodes load it when they restart.
+func (r *certRotator) issueCertificates(ctx context.Context, ca *security.CA) error {
+	resolver := paths.NewRemotePathResolver(&r.metadata.Topology.Global)
+	for _, node := range r.metadata.Nodes {
+		hosts := r.metadata.Topology.NodeCertificateHosts(node.Host, node.Port)
+		if len(hosts) == 0 {
+			return fmt.Errorf("node %s is not in the cluster topology", node.ID())
+		}
+		bundle, err := ca.Issue(hosts)
+		if err != nil {
+			return err
+		}
+		if err := security.WriteSecret(r.layout.CertificateFile(node.Host, node.Port), bundle, security.CertificateMode); err != nil {
+			return fmt.Errorf("node %s: %w", node.ID(), err)
+		}
+		if r.remote == nil {
+			continue
+		}
+		dest, err := resolver.CertificateFile(node.Host, node.Port)
+		if err != nil {
+			return err
+		}
+		if err := executor.UploadSecret(ctx, r.remote[node.Host].Executor(), bundle, dest, security.CertificateMode); err != nil {
+			return fmt.Errorf("node %s: %w", node.ID(), err)
+		}
+	}
+	fmt.Printf("  ✓ Issued %d node certificates\n", len(r.metadata.Nodes))
+	return nil
+}
+
+// roll restarts every node one at a time. Replica set members restart
+// secondaries first, then the primary after it steps down.
+func (r *certRotator) roll(ctx context.Context, groups []rotationGroup) error {
+	sec := r.metadata.Security
+	for _, group := range groups {
+		if group.replicaSet == "" {
+			for _, node := range group.nodes {
+				if err := r.restart(ctx, node); err != nil {
+					return err
+				}
+				if err := r.members.WaitForNode(ctx, node.Address(), sec, rotationTimeout); err != nil {
+					return fmt.Errorf("node %s did not come back: %w", node.ID(), err)
+				}
+				fmt.Printf("  ✓ %s %s healthy\n", node.Type, node.ID())
+			}
+			continue
+		}
+
+		fmt.Printf("\n  Replica set %s\n", group.replicaSet)
+		var allHosts []string
+		for _, node := range group.nodes {
+			allHosts = append(allHosts, node.Address())
+		}
+
+		var primary *meta.NodeMetadata
+		var secondaries []*meta.NodeMetadata
+		for _, node := range group.nodes {
+			role, err := r.members.DetectRole(ctx, node.Address(), group.replicaSet, allHosts, sec)
+			if err != nil {
+				return fmt.Errorf("failed to check %s before restarting: %w", node.ID(), err)
+			}
+			if role == "PRIMARY" {
+				primary = node
+			} else {
+				secondaries = append(secondaries, node)
+			}
+		}
+
+		for _, node := range secondaries {
+			if err := r.restartMember(ctx, node, group.replicaSet, allHosts); err != nil {
+				return err
+			}
+		}
+		if primary == nil {
+			continue
+		}
+		// A single-member replica set has no one to hand over to
+		if len(group.nodes) > 1 {
+			if err := r.members.StepDown(ctx, primary.Address(), group.replicaSet, allHosts, sec); err != nil {
+				return fmt.Errorf("failed to step down primary %s: %w", primary.ID(), err)
+			}
+		}
+		if err := r.restartMember(ctx, primary, group.replicaSet, allHosts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartMember restarts a replica set member and waits until it rejoins
+func (r *certRotator) restartMember(ctx context.Context, node *meta.NodeMetadata, rsName string, allHosts []string) error {
+	if err := r.restart(ctx, node); err != nil {
+		return err
+	}
+	if err := r.members.WaitForMember(ctx, node.Address(), rsName, allHosts, r.metadata.Security, rotationTimeout); err != nil {
+		return fmt.Errorf("node %s did not rejoin %s: %w", node.ID(), rsName, err)
+	}
+	fmt.Printf("  ✓ %s %s healthy\n", node.Type, node.ID())
+	return nil
+}
+
+// restart restarts a node through supervisord so it loads its certificate
+func (r *certRotator) restart(ctx context.Context, node *meta.NodeMetadata) error {
+	if node.SupervisorProgramName == "" {
+		return fmt.Errorf("node %s missing supervisor program name - cluster may need redeployment", node.ID())
+	}
+	fmt.Printf("  Restarting %s %s...\n", node.Type, node.ID())
+
+	if r.local != nil {
+		if err := r.local.RestartProcess(node.SupervisorProgramName); err != nil {
+			return fmt.Errorf("failed to restart %s: %w", node.ID(), err)
+		}
+		return nil
+	}
+
+	mgr := r.remote[node.Host]
+	names := []string{node.SupervisorProgramName}
+	if err := mgr.StopProcesses(ctx, names); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", node.ID(), err)
+	}
+	if err := mgr.StartProcesses(ctx, names); err != nil {
+		return fmt.Errorf("failed to start %s: %w", node.ID(), err)
+	}
+	return nil
+}
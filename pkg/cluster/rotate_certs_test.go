@@ -0,0 +1,295 @@
+package cluster
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/topology"
+)
+
+func TestRotationGroups(t *testing.T) {
+	metadata := &meta.ClusterMetadata{
+		Nodes: []meta.NodeMetadata{
+			{Type: "mongos", Host: "app1", Port: 27017},
+			{Type: "mongod", Host: "db1", Port: 27018, ReplicaSet: "shard2"},
+			{Type: "mongod", Host: "db1", Port: 27019, ReplicaSet: "shard1"},
+			{Type: "mongod", Host: "db2", Port: 27018, ReplicaSet: "shard2"},
+			{Type: "config", Host: "cfg1", Port: 27020, ReplicaSet: "configRS"},
+			{Type: "mongod", Host: "db3", Port: 27017},
+		},
+	}
+
+	groups := rotationGroups(metadata)
+
+	var order []string
+	for _, group := range groups {
+		var ids []string
+		for _, node := range group.nodes {
+			ids = append(ids, node.ID())
+		}
+		order = append(order, group.replicaSet+"="+strings.Join(ids, ","))
+	}
+	assert.Equal(t, []string{
+		"configRS=cfg1:27020",
+		"shard1=db1:27019",
+		"shard2=db1:27018,db2:27018",
+		"=db3:27017",
+		"=app1:27017",
+	}, order, "config servers first, shards by name, mongos last")
+}
+
+// rotationLog records the rotator's MongoDB calls and restarts in order
+type rotationLog struct {
+	mu     sync.Mutex
+	events []string
+
+	// onRestart, when set, runs as a host's program is started
+	onRestart func(host string)
+}
+
+func (l *rotationLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *rotationLog) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+// fakeMembers answers the rotator's MongoDB calls with a fixed primary
+type fakeMembers struct {
+	log     *rotationLog
+	primary string
+	failOn  string // hostPort whose step down or wait fails
+}
+
+func (f *fakeMembers) DetectRole(_ context.Context, hostPort, _ string, _ []string, _ *meta.SecurityMetadata) (string, error) {
+	f.log.add("role " + hostPort)
+	if hostPort == f.primary {
+		return "PRIMARY", nil
+	}
+	return "SECONDARY", nil
+}
+
+func (f *fakeMembers) StepDown(_ context.Context, primary, _ string, _ []string, _ *meta.SecurityMetadata) error {
+	f.log.add("step down " + primary)
+	if primary == f.failOn {
+		return errors.New("no electable secondary")
+	}
+	return nil
+}
+
+func (f *fakeMembers) WaitForMember(_ context.Context, hostPort, _ string, _ []string, _ *meta.SecurityMetadata, _ time.Duration) error {
+	f.log.add("wait " + hostPort)
+	if hostPort == f.failOn {
+		return errors.New("timed out")
+	}
+	return nil
+}
+
+func (f *fakeMembers) WaitForNode(_ context.Context, hostPort string, _ *meta.SecurityMetadata, _ time.Duration) error {
+	f.log.add("wait " + hostPort)
+	return nil
+}
+
+// newRotateTestManager returns a remote TLS cluster of a three member
+// replica set with db2 as primary and a mongos on app1, whose certificates
+// were issued by the returned CA
+func newRotateTestManager(t *testing.T) (*Manager, *fakeMembers, *paths.ClusterLayout, *security.CA) {
+	t.Helper()
+	log := &rotationLog{}
+	hosts := map[string]*fakeSupervisorHost{}
+	for _, name := range []string{"db1", "db2", "db3", "app1"} {
+		host := newFakeSupervisorHost()
+		host.onCommand = func(command string) {
+			if strings.Contains(command, " ctl ") && strings.Contains(command, " start ") {
+				log.add("restart " + name)
+				if log.onRestart != nil {
+					log.onRestart(name)
+				}
+			}
+		}
+		hosts[name] = host
+	}
+
+	m, metadata, _ := newRemoteTestManager(t, hosts)
+	members := &fakeMembers{log: log, primary: "db2:27017"}
+	m.members = members
+
+	layout := paths.NewClusterLayout(m.metaMgr.GetClusterDir(metadata.Name))
+	ca, err := security.NewCA(metadata.Name)
+	require.NoError(t, err)
+	keyPEM, err := ca.KeyPEM()
+	require.NoError(t, err)
+	require.NoError(t, security.WriteSecret(layout.CAFile(), ca.CertPEM(), 0644))
+	require.NoError(t, security.WriteSecret(layout.CAKeyFile(), keyPEM, security.SecretMode))
+
+	metadata.Security = &meta.SecurityMetadata{TLS: "requireTLS", CAFile: layout.CAFile()}
+	metadata.Nodes = append(metadata.Nodes, meta.NodeMetadata{
+		Type:                  "mongos",
+		Host:                  "app1",
+		Port:                  27016,
+		SupervisorProgramName: "mongos-27016",
+	})
+	for _, node := range metadata.Nodes {
+		if node.Type == "mongos" {
+			metadata.Topology.Mongos = append(metadata.Topology.Mongos, topology.MongosNode{Host: node.Host, Port: node.Port})
+		} else {
+			metadata.Topology.Mongod = append(metadata.Topology.Mongod, topology.MongodNode{Host: node.Host, Port: node.Port, ReplicaSet: node.ReplicaSet})
+		}
+		bundle, err := ca.Issue([]string{node.Host})
+		require.NoError(t, err)
+		require.NoError(t, security.WriteSecret(layout.CertificateFile(node.Host, node.Port), bundle, security.CertificateMode))
+	}
+	require.NoError(t, m.metaMgr.Save(metadata))
+
+	return m, members, layout, ca
+}
+
+// signedBy reports whether the first certificate in path was issued by ca
+func signedBy(t *testing.T, path string, ca *security.CA) bool {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		return cert.CheckSignatureFrom(ca.Certificate()) == nil
+	}
+	t.Fatalf("no certificate in %s", path)
+	return false
+}
+
+// rotationPorts are the ports of the test cluster's nodes by host
+var rotationPorts = map[string]int{"db1": 27017, "db2": 27017, "db3": 27017, "app1": 27016}
+
+func TestRotateCerts_RollingOrder(t *testing.T) {
+	m, members, layout, ca := newRotateTestManager(t)
+
+	require.NoError(t, m.RotateCerts(context.Background(), "prod", RotateCertsOptions{}))
+
+	assert.Equal(t, []string{
+		"role db1:27017", "role db2:27017", "role db3:27017",
+		"restart db1", "wait db1:27017",
+		"restart db3", "wait db3:27017",
+		"step down db2:27017",
+		"restart db2", "wait db2:27017",
+		"restart app1", "wait app1:27016",
+	}, members.log.list(), "secondaries, then the primary after stepping down, each rejoining before the next restart")
+
+	for host, port := range rotationPorts {
+		assert.True(t, signedBy(t, layout.CertificateFile(host, port), ca), host)
+	}
+}
+
+func TestRotateCerts_StopsWhenANodeDoesNotRejoin(t *testing.T) {
+	m, members, _, _ := newRotateTestManager(t)
+	members.failOn = "db1:27017"
+
+	err := m.RotateCerts(context.Background(), "prod", RotateCertsOptions{})
+	assert.EqualError(t, err, "node db1:27017 did not rejoin rs0: timed out")
+	assert.Equal(t, []string{
+		"role db1:27017", "role db2:27017", "role db3:27017",
+		"restart db1", "wait db1:27017",
+	}, members.log.list())
+}
+
+func TestRotateCerts_StopsWhenThePrimaryCannotStepDown(t *testing.T) {
+	m, members, _, _ := newRotateTestManager(t)
+	members.failOn = "db2:27017"
+
+	err := m.RotateCerts(context.Background(), "prod", RotateCertsOptions{})
+	assert.ErrorContains(t, err, "failed to step down primary db2:27017")
+	assert.NotContains(t, members.log.list(), "restart db2", "the primary is never restarted before handing over")
+}
+
+func TestRotateCerts_NewCA(t *testing.T) {
+	m, members, layout, oldCA := newRotateTestManager(t)
+
+	type restart struct {
+		host        string
+		trustedCAs  int
+		oldCertSign bool
+	}
+	var restarts []restart
+	members.log.onRestart = func(host string) {
+		content, err := os.ReadFile(layout.CAFile())
+		require.NoError(t, err)
+		restarts = append(restarts, restart{
+			host:        host,
+			trustedCAs:  strings.Count(string(content), "BEGIN CERTIFICATE"),
+			oldCertSign: signedBy(t, layout.CertificateFile(host, rotationPorts[host]), oldCA),
+		})
+	}
+
+	require.NoError(t, m.RotateCerts(context.Background(), "prod", RotateCertsOptions{NewCA: true}))
+
+	order := []string{"db1", "db3", "db2", "app1"}
+	require.Len(t, restarts, 2*len(order), "two rolling restarts")
+	for i, r := range restarts {
+		assert.Equal(t, order[i%len(order)], r.host)
+		assert.Equal(t, 2, r.trustedCAs, "nodes trust both CAs while the cluster moves over")
+		// Phase 1 restarts with the old certificates, phase 2 with new ones
+		assert.Equal(t, i < len(order), r.oldCertSign, "restart %d of %s", i, r.host)
+	}
+
+	// The CA file ends up holding only the new CA, which matches the key
+	newCA, err := loadClusterCA(layout)
+	require.NoError(t, err)
+	assert.False(t, newCA.Certificate().Equal(oldCA.Certificate()))
+	content, err := os.ReadFile(layout.CAFile())
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "BEGIN CERTIFICATE"))
+	for host, port := range rotationPorts {
+		assert.True(t, signedBy(t, layout.CertificateFile(host, port), newCA), host)
+	}
+	_, err = newCA.Issue([]string{"db4"})
+	assert.NoError(t, err)
+}
+
+func TestCertRotator_InstallCA(t *testing.T) {
+	layout := paths.NewClusterLayout(t.TempDir())
+	r := &certRotator{layout: layout}
+
+	ca, err := security.NewCA("prod")
+	require.NoError(t, err)
+	require.NoError(t, r.installCA(ca, ca.CertPEM()))
+
+	loaded, err := loadClusterCA(layout)
+	require.NoError(t, err)
+	assert.True(t, loaded.Certificate().Equal(ca.Certificate()))
+
+	info, err := os.Stat(layout.CAKeyFile())
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(security.SecretMode), info.Mode().Perm())
+
+	// A failed write keeps the installed pair
+	require.NoError(t, os.Mkdir(layout.CAKeyFile()+".rotate", 0700))
+	other, err := security.NewCA("prod")
+	require.NoError(t, err)
+	assert.Error(t, r.installCA(other, other.CertPEM()))
+	loaded, err = loadClusterCA(layout)
+	require.NoError(t, err)
+	assert.True(t, loaded.Certificate().Equal(ca.Certificate()))
+	_, err = os.Stat(layout.CAFile() + ".rotate")
+	assert.True(t, os.IsNotExist(err), "staged files are removed")
+}
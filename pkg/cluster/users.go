@@ -0,0 +1,275 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/zph/mup/pkg/access"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+)
+
+// UsersOperation is the plan operation of `mup cluster users`
+const UsersOperation = "users"
+
+// UsersOptions controls planning users and roles
+type UsersOptions struct {
+	File      string // Users and roles file
+	AllowDrop bool   // Drop users and roles missing from File
+}
+
+// usersSpec is what buildUsersPlan needs besides the diff
+type usersSpec struct {
+	file            string
+	allowDrop       bool
+	address         string
+	auth            *deploy.AuthParams
+	tls             *deploy.TLSParams
+	credentialsFile string
+}
+
+// PlanUsers plans bringing the cluster's users and custom roles in line
+// with opts.File. The cluster's state is read with usersInfo and the
+// admin.system.roles collection. The admin user mup logs in as is never
+// changed.
+func (m *Manager) PlanUsers(ctx context.Context, clusterName string, opts UsersOptions) (*plan.Plan, error) {
+	metadata, err := m.loadAuthMetadata(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := access.LoadSpec(opts.File)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := readAccessState(ctx, metadata)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := access.Diff(spec, current, access.DiffOptions{
+		AllowDrop: opts.AllowDrop,
+		Reserved:  []string{metadata.Security.AdminUser + "@admin"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	us := &usersSpec{
+		file:            opts.File,
+		allowDrop:       opts.AllowDrop,
+		address:         clusterAddress(metadata),
+		credentialsFile: m.credentialsFile(clusterName, metadata),
+		auth: &deploy.AuthParams{
+			User:            metadata.Security.AdminUser,
+			PasswordFile:    metadata.Security.PasswordFile,
+			CredentialsFile: metadata.Security.CredentialsFile,
+		},
+	}
+	if metadata.Security.TLSEnabled() {
+		us.tls = &deploy.TLSParams{CAFile: metadata.Security.CAFile}
+	}
+	if err := checkPasswordReferences(diff, us.credentialsFile); err != nil {
+		return nil, err
+	}
+
+	return buildUsersPlan(metadata, diff, us), nil
+}
+
+// UsersExecutors returns the executor users plans run with: the Go driver
+// connects from the machine running mup. The caller closes it.
+func (m *Manager) UsersExecutors() map[string]executor.Executor {
+	return map[string]executor.Executor{"localhost": executor.NewLocalExecutor()}
+}
+
+// readAccessState reads the cluster's users and custom roles as the admin
+// user
+func readAccessState(ctx context.Context, metadata *meta.ClusterMetadata) (*access.State, error) {
+	password, err := metadata.Security.AdminPassword()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, credentialsTimeout)
+	defer cancel()
+
+	client, err := connectAs(ctx, metadata, metadata.Security.AdminUser, password)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+
+	admin := client.Database("admin")
+	var usersInfo struct {
+		Users []access.User `bson:"users"`
+	}
+	err = admin.RunCommand(ctx, bson.D{{Key: "usersInfo", Value: bson.D{{Key: "forAllDBs", Value: true}}}}).Decode(&usersInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	cursor, err := admin.Collection("system.roles").Find(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	var roles []access.Role
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	return &access.State{Roles: roles, Users: usersInfo.Users}, nil
+}
+
+// checkPasswordReferences fails when a user to create names a password
+// that is not in the credential store. Users without a reference get a
+// generated password.
+func checkPasswordReferences(diff *access.DiffResult, credentialsFile string) error {
+	var creds *security.Credentials
+	for _, change := range diff.Changes {
+		if change.User == nil || change.Action != access.ActionCreate || change.User.Password == "" {
+			continue
+		}
+		if creds == nil {
+			if _, err := os.Stat(credentialsFile); err != nil {
+				creds = &security.Credentials{}
+			} else {
+				keys, err := security.DefaultKeySource()
+				if err != nil {
+					return err
+				}
+				if creds, err = security.LoadCredentials(credentialsFile, keys); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := creds.Password(change.User.Password); err != nil {
+			return fmt.Errorf("password %q of user %s is not in the credential store: store it with 'mup cluster credentials set <cluster> --user %s --no-verify'",
+				change.User.Password, change.User.ID(), change.User.Password)
+		}
+	}
+	return nil
+}
+
+// buildUsersPlan turns diff into a plan: roles first, then users, then
+// drops
+func buildUsersPlan(metadata *meta.ClusterMetadata, diff *access.DiffResult, us *usersSpec) *plan.Plan {
+	var roles, users, drops []plan.PlannedOperation
+	opIndex := 0
+
+	for i := range diff.Changes {
+		change := &diff.Changes[i]
+		phase := "users"
+		switch {
+		case change.Action == access.ActionDrop:
+			phase = "drop"
+		case change.Kind == access.KindRole:
+			phase = "roles"
+		}
+		opIndex++
+		op := usersOperation(plan.NewOperationID(phase, opIndex), change, us)
+
+		switch phase {
+		case "roles":
+			roles = append(roles, op)
+		case "users":
+			users = append(users, op)
+		default:
+			drops = append(drops, op)
+		}
+	}
+
+	validation := plan.ValidationResult{Valid: true}
+	for _, id := range diff.Unmanaged {
+		validation.Warnings = append(validation.Warnings, plan.ValidationIssue{
+			Code:     "UNMANAGED",
+			Message:  fmt.Sprintf("%s is not in %s and is kept; --allow-drop drops it", id, us.file),
+			Severity: "warning",
+		})
+	}
+
+	var phases []plan.PlannedPhase
+	for _, phase := range []plan.PlannedPhase{
+		{Name: "roles", Description: "Create and update custom roles", Operations: roles},
+		{Name: "users", Description: "Create and update users", Operations: users},
+		{Name: "drop", Description: "Drop users and roles missing from the file", Operations: drops},
+	} {
+		if len(phase.Operations) > 0 {
+			phase.Order = len(phases) + 1
+			phases = append(phases, phase)
+		}
+	}
+
+	return &plan.Plan{
+		PlanID:      plan.NewPlanID(),
+		Operation:   UsersOperation,
+		ClusterName: metadata.Name,
+		Version:     metadata.Version,
+		Variant:     metadata.Variant,
+		Config: map[string]interface{}{
+			"file":       us.file,
+			"allow_drop": us.allowDrop,
+		},
+		Validation: validation,
+		Phases:     phases,
+	}
+}
+
+// usersOperation returns the operation applying change
+func usersOperation(id string, change *access.Change, us *usersSpec) plan.PlannedOperation {
+	params := map[string]interface{}{
+		"action":  change.Action,
+		"address": us.address,
+		"auth":    us.auth,
+	}
+	if us.tls != nil {
+		params["tls"] = us.tls
+	}
+
+	action := map[string]plan.ActionType{
+		access.ActionCreate: plan.ActionCreate,
+		access.ActionUpdate: plan.ActionUpdate,
+		access.ActionDrop:   plan.ActionDelete,
+	}[change.Action]
+	verb := map[string]string{
+		access.ActionCreate: "Create",
+		access.ActionUpdate: "Update",
+		access.ActionDrop:   "Drop",
+	}[change.Action]
+
+	var opType plan.OperationType
+	var description string
+	if change.User != nil {
+		opType = plan.OpManageDBUser
+		params["user"] = change.User
+		description = fmt.Sprintf("%s user %s", verb, change.ID())
+		if change.Action == access.ActionCreate {
+			description += " with roles " + access.FormatRoleRefs(change.User.Roles)
+			if change.User.DB != access.ExternalDB {
+				params["credentials_file"] = us.credentialsFile
+				params["generate_password"] = change.User.Password == ""
+			}
+		}
+	} else {
+		opType = plan.OpManageDBRole
+		params["role"] = change.Role
+		description = fmt.Sprintf("%s role %s", verb, change.ID())
+	}
+	if change.Detail != "" {
+		description += ": " + change.Detail
+	}
+
+	return plan.PlannedOperation{
+		ID:          id,
+		Type:        opType,
+		Description: description,
+		Target:      plan.OperationTarget{Type: "db_" + change.Kind, Name: change.ID()},
+		Params:      params,
+		Changes: []plan.Change{
+			{ResourceType: "db_" + change.Kind, ResourceID: change.ID(), Action: action},
+		},
+	}
+}
@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zph/mup/pkg/access"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/plan"
+)
+
+func TestBuildUsersPlan(t *testing.T) {
+	metadata := &meta.ClusterMetadata{Name: "prod", Version: "7.0.5", Variant: "mongo"}
+	spec := &access.Spec{
+		Roles: []access.Role{{Name: "appReader", DB: "admin", Roles: []access.RoleRef{{Role: "read", DB: "app"}}}},
+		Users: []access.User{
+			{Name: "app", DB: "app", Roles: []access.RoleRef{{Role: "readWrite", DB: "app"}}, Password: "app-prod"},
+			{Name: "report", DB: "app", Roles: []access.RoleRef{{Role: "appReader", DB: "admin"}}},
+			{Name: "CN=etl", DB: access.ExternalDB, Roles: []access.RoleRef{{Role: "read", DB: "app"}}},
+		},
+	}
+	current := &access.State{Users: []access.User{
+		{Name: "admin", DB: "admin", Roles: []access.RoleRef{{Role: "root", DB: "admin"}}},
+		{Name: "old", DB: "app"},
+	}}
+	us := &usersSpec{
+		file:            "users.yaml",
+		allowDrop:       true,
+		address:         "app1:27017",
+		auth:            &deploy.AuthParams{User: "admin", PasswordFile: "/p", CredentialsFile: "/c"},
+		tls:             &deploy.TLSParams{CAFile: "/ca.pem"},
+		credentialsFile: "/c",
+	}
+
+	diff, err := access.Diff(spec, current, access.DiffOptions{AllowDrop: true, Reserved: []string{"admin@admin"}})
+	require.NoError(t, err)
+	p := buildUsersPlan(metadata, diff, us)
+
+	assert.Equal(t, UsersOperation, p.Operation)
+	assert.Equal(t, true, p.Config["allow_drop"])
+	require.Len(t, p.Phases, 3)
+	assert.Equal(t, []string{"roles", "users", "drop"}, []string{p.Phases[0].Name, p.Phases[1].Name, p.Phases[2].Name})
+
+	role := p.Phases[0].Operations[0]
+	assert.Equal(t, plan.OpManageDBRole, role.Type)
+	assert.Equal(t, "Create role appReader@admin", role.Description)
+	assert.Equal(t, us.tls, role.Params["tls"])
+
+	users := p.Phases[1].Operations
+	require.Len(t, users, 3)
+	assert.Equal(t, "Create user CN=etl@$external with roles [read@app]", users[0].Description)
+	assert.NotContains(t, users[0].Params, "credentials_file", "external users have no password")
+	assert.Equal(t, false, users[1].Params["generate_password"], "app@app names its password")
+	assert.Equal(t, true, users[2].Params["generate_password"])
+	assert.Equal(t, "/c", users[2].Params["credentials_file"])
+
+	drop := p.Phases[2].Operations
+	require.Len(t, drop, 1)
+	assert.Equal(t, "Drop user old@app", drop[0].Description)
+	assert.Equal(t, plan.ActionDelete, drop[0].Changes[0].Action)
+
+	ids := make(map[string]bool)
+	for _, phase := range p.Phases {
+		for _, op := range phase.Operations {
+			assert.False(t, ids[op.ID], "duplicate operation ID %s", op.ID)
+			ids[op.ID] = true
+		}
+	}
+}
+
+func TestBuildUsersPlan_NoChanges(t *testing.T) {
+	diff := &access.DiffResult{Unmanaged: []string{"user old@app"}}
+	p := buildUsersPlan(&meta.ClusterMetadata{Name: "prod"}, diff, &usersSpec{file: "users.yaml"})
+
+	assert.Empty(t, p.Phases)
+	require.Len(t, p.Validation.Warnings, 1)
+	assert.Equal(t, "user old@app is not in users.yaml and is kept; --allow-drop drops it", p.Validation.Warnings[0].Message)
+}
+
+func TestCheckPasswordReferences(t *testing.T) {
+	diff := &access.DiffResult{Changes: []access.Change{{
+		Kind: access.KindUser, Action: access.ActionCreate,
+		User: &access.User{Name: "app", DB: "app", Password: "app-prod"},
+	}}}
+	err := checkPasswordReferences(diff, "/nonexistent/credentials.enc")
+	assert.ErrorContains(t, err, `password "app-prod" of user app@app is not in the credential store`)
+}
@@ -20,6 +20,8 @@ import (
 	"golang.org/x/mod/semver"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
+
+	"github.com/zph/mup/pkg/executor"
 )
 
 // Platform represents a target platform for MongoDB binaries
@@ -43,9 +45,10 @@ func GetCurrentPlatform() Platform {
 
 // BinaryManager manages MongoDB binaries for multiple platforms
 type BinaryManager struct {
-	cacheDir    string            // Base cache directory (~/.mup/storage/packages)
-	storageDir  string            // Storage directory (~/.mup/storage)
-	binPaths    map[string]string // platformKey -> binPath
+	cacheDir    string                   // Base cache directory (~/.mup/storage/packages)
+	storageDir  string                   // Storage directory (~/.mup/storage)
+	binPaths    map[string]string        // platformKey -> binPath
+	packages    map[string]*packageEntry // Distribution tarballs, built once per platform
 	mu          sync.Mutex
 	versionJSON *MongoDBFullJSON // Cached version data
 	versionMu   sync.Mutex
@@ -76,7 +79,7 @@ func (bm *BinaryManager) Close() error {
 
 // getMongoDBVersions fetches or loads cached MongoDB versions
 // Caches the full.json file for 24 hours in ~/.mup/storage/mongo-versions.json
-func (bm *BinaryManager) getMongoDBVersions() (*MongoDBFullJSON, error) {
+func (bm *BinaryManager) getMongoDBVersions(ctx context.Context) (*MongoDBFullJSON, error) {
 	bm.versionMu.Lock()
 	defer bm.versionMu.Unlock()
 
@@ -106,7 +109,7 @@ func (bm *BinaryManager) getMongoDBVersions() (*MongoDBFullJSON, error) {
 	}
 
 	// Need to fetch fresh data
-	resp, err := http.Get("https://downloads.mongodb.org/full.json")
+	resp, err := httpGet(ctx, "https://downloads.mongodb.org/full.json")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch MongoDB versions: %w", err)
 	}
@@ -150,6 +153,12 @@ func (bm *BinaryManager) GetBinPathForPlatform(version string, platform Platform
 // [UPG-002] Supports both "mongo" and "percona" variants
 // Downloads and caches binaries for any variant/platform/architecture combination
 func (bm *BinaryManager) GetBinPathWithVariant(version string, variant Variant, platform Platform) (string, error) {
+	return bm.GetBinPathWithVariantContext(context.Background(), version, variant, platform)
+}
+
+// GetBinPathWithVariantContext is GetBinPathWithVariant bound to ctx:
+// cancelling it stops downloads and extraction before it returns
+func (bm *BinaryManager) GetBinPathWithVariantContext(ctx context.Context, version string, variant Variant, platform Platform) (string, error) {
 	platformKey := platform.Key()
 	cacheKey := fmt.Sprintf("%s-%s-%s", variant.String(), version, platformKey)
 
@@ -166,14 +175,14 @@ func (bm *BinaryManager) GetBinPathWithVariant(version string, variant Variant,
 	resolvedVersion := version
 	if variant == VariantMongo {
 		var err error
-		resolvedVersion, err = bm.resolveVersion(version)
+		resolvedVersion, err = bm.resolveVersion(ctx, version)
 		if err != nil {
 			return "", fmt.Errorf("failed to resolve version: %w", err)
 		}
 	}
 
 	// Download and cache for this variant/platform
-	binPath, err := bm.downloadWithVariant(resolvedVersion, variant, platform)
+	binPath, err := bm.downloadWithVariant(ctx, resolvedVersion, variant, platform)
 	if err != nil {
 		return "", fmt.Errorf("failed to download %s %s for platform %s: %w", variant, resolvedVersion, platformKey, err)
 	}
@@ -188,12 +197,12 @@ func (bm *BinaryManager) GetBinPathWithVariant(version string, variant Variant,
 // downloadForPlatform downloads MongoDB binaries for a specific platform
 // Uses VariantMongo for backward compatibility
 func (bm *BinaryManager) downloadForPlatform(version string, platform Platform) (string, error) {
-	return bm.downloadWithVariant(version, VariantMongo, platform)
+	return bm.downloadWithVariant(context.Background(), version, VariantMongo, platform)
 }
 
 // downloadWithVariant downloads binaries for a specific variant and platform
 // [UPG-002] Supports both "mongo" and "percona" variants
-func (bm *BinaryManager) downloadWithVariant(version string, variant Variant, platform Platform) (string, error) {
+func (bm *BinaryManager) downloadWithVariant(ctx context.Context, version string, variant Variant, platform Platform) (string, error) {
 	// Cache location: ~/.mup/storage/packages/{variant}-{version}-{os}-{arch}/bin
 	platformKey := platform.Key()
 	fullVersion := fmt.Sprintf("%s-%s", variant, version)
@@ -213,12 +222,12 @@ func (bm *BinaryManager) downloadWithVariant(version string, variant Variant, pl
 			if len(versionParts) >= 2 {
 				majorVersion := versionParts[0]
 				if majorVersion >= "4" {
-					if err := bm.ensureMongosh(version, platform, binPath); err != nil {
+					if err := bm.ensureMongosh(ctx, version, platform, binPath); err != nil {
 						// Log warning but don't fail - mongosh might not be available for all versions
 						fmt.Printf("  Warning: failed to ensure mongosh: %v\n", err)
 					}
 				} else {
-					if err := bm.ensureMongo(version, platform, binPath); err != nil {
+					if err := bm.ensureMongo(ctx, version, platform, binPath); err != nil {
 						// Log warning but don't fail - mongo might not be available for all versions
 						fmt.Printf("  Warning: failed to ensure mongo: %v\n", err)
 					}
@@ -237,7 +246,7 @@ func (bm *BinaryManager) downloadWithVariant(version string, variant Variant, pl
 
 	switch variant {
 	case VariantMongo:
-		url, err = bm.getDownloadURLForPlatform(version, platform)
+		url, err = bm.getDownloadURLForPlatform(ctx, version, platform)
 		if err != nil {
 			// For Apple Silicon, fall back to x86_64 (runs via Rosetta)
 			if platform.OS == "darwin" && platform.Arch == "arm64" {
@@ -246,7 +255,7 @@ func (bm *BinaryManager) downloadWithVariant(version string, variant Variant, pl
 
 				// Retry with x86_64
 				fallbackPlatform := Platform{OS: "darwin", Arch: "amd64"}
-				url, err = bm.getDownloadURLForPlatform(version, fallbackPlatform)
+				url, err = bm.getDownloadURLForPlatform(ctx, version, fallbackPlatform)
 				if err != nil {
 					return "", fmt.Errorf("failed to get download URL (tried arm64 and x86_64): %w", err)
 				}
@@ -276,11 +285,11 @@ func (bm *BinaryManager) downloadWithVariant(version string, variant Variant, pl
 		}
 	case VariantPercona:
 		// Try tarball first
-		url, err = bm.buildPerconaURL(version, platform)
+		url, err = bm.buildPerconaURL(ctx, version, platform)
 		if err != nil {
 			// Tarball not found, try .deb packages for Linux
 			fmt.Printf("  Tarball not available, trying .deb packages...\n")
-			debURLs, err = bm.buildPerconaDebURLs(version, platform)
+			debURLs, err = bm.buildPerconaDebURLs(ctx, version, platform)
 			if err != nil {
 				return "", fmt.Errorf("failed to get Percona binaries: no tarballs or .deb packages available: %w", err)
 			}
@@ -304,12 +313,12 @@ func (bm *BinaryManager) downloadWithVariant(version string, variant Variant, pl
 	// Handle .deb packages differently from tarballs
 	if useDebPackages {
 		// Download and extract .deb packages
-		if err := bm.downloadAndExtractDebPackages(debURLs, binPath); err != nil {
+		if err := bm.downloadAndExtractDebPackages(ctx, debURLs, binPath); err != nil {
 			return "", fmt.Errorf("failed to download and extract .deb packages: %w", err)
 		}
 	} else {
 		// Download tarball
-		resp, err := http.Get(url)
+		resp, err := httpGet(ctx, url)
 		if err != nil {
 			return "", fmt.Errorf("failed to download: %w", err)
 		}
@@ -368,13 +377,13 @@ func (bm *BinaryManager) downloadWithVariant(version string, variant Variant, pl
 		majorVersion := versionParts[0]
 		if majorVersion >= "4" {
 			// MongoDB >= 4.0: ensure mongosh
-			if err := bm.ensureMongosh(version, platform, binPath); err != nil {
+			if err := bm.ensureMongosh(ctx, version, platform, binPath); err != nil {
 				// Log warning but don't fail - mongosh might not be available for all versions
 				fmt.Printf("  Warning: failed to ensure mongosh: %v\n", err)
 			}
 		} else {
 			// MongoDB < 4.0: ensure mongo (legacy shell)
-			if err := bm.ensureMongo(version, platform, binPath); err != nil {
+			if err := bm.ensureMongo(ctx, version, platform, binPath); err != nil {
 				// Log warning but don't fail - mongo might not be available for all versions
 				fmt.Printf("  Warning: failed to ensure mongo: %v\n", err)
 			}
@@ -385,6 +394,24 @@ func (bm *BinaryManager) downloadWithVariant(version string, variant Variant, pl
 	return binPath, nil
 }
 
+// httpGet is http.Get bound to ctx
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	return httpDo(ctx, http.MethodGet, url)
+}
+
+// httpHead is http.Head bound to ctx
+func httpHead(ctx context.Context, url string) (*http.Response, error) {
+	return httpDo(ctx, http.MethodHead, url)
+}
+
+func httpDo(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
 // MongoDBFullJSON represents the full.json structure from MongoDB
 type MongoDBFullJSON struct {
 	Versions []MongoDBVersionInfo `json:"versions"`
@@ -410,9 +437,9 @@ type MongoDBArchive struct {
 }
 
 // getDownloadURLForPlatform gets the download URL for a specific platform using full.json
-func (bm *BinaryManager) getDownloadURLForPlatform(version string, platform Platform) (string, error) {
+func (bm *BinaryManager) getDownloadURLForPlatform(ctx context.Context, version string, platform Platform) (string, error) {
 	// Get cached or fetch MongoDB versions
-	fullJSON, err := bm.getMongoDBVersions()
+	fullJSON, err := bm.getMongoDBVersions(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -513,11 +540,11 @@ func (bm *BinaryManager) getDownloadURLForPlatform(version string, platform Plat
 	}
 
 	// Fallback: try to construct URL directly
-	return bm.constructFallbackURL(targetVersion.Version, targetOS, mongoArch)
+	return bm.constructFallbackURL(ctx, targetVersion.Version, targetOS, mongoArch)
 }
 
 // constructFallbackURL constructs a download URL directly as fallback
-func (bm *BinaryManager) constructFallbackURL(version, targetOS, mongoArch string) (string, error) {
+func (bm *BinaryManager) constructFallbackURL(ctx context.Context, version, targetOS, mongoArch string) (string, error) {
 	var urls []string
 
 	switch targetOS {
@@ -542,7 +569,7 @@ func (bm *BinaryManager) constructFallbackURL(version, targetOS, mongoArch strin
 
 	// Try each URL
 	for _, url := range urls {
-		resp, err := http.Head(url)
+		resp, err := httpHead(ctx, url)
 		if err == nil {
 			_ = resp.Body.Close()
 			if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusFound {
@@ -562,7 +589,7 @@ func (bm *BinaryManager) constructFallbackURL(version, targetOS, mongoArch strin
 // buildPerconaURL constructs a download URL for Percona Server for MongoDB
 // [UPG-002] Percona URL format:
 // https://downloads.percona.com/downloads/percona-server-mongodb-{major.minor}/percona-server-mongodb-{version}/binary/tarball/percona-server-mongodb-{version}-{platform}-{arch}.tar.gz
-func (bm *BinaryManager) buildPerconaURL(version string, platform Platform) (string, error) {
+func (bm *BinaryManager) buildPerconaURL(ctx context.Context, version string, platform Platform) (string, error) {
 	// Extract major.minor version (e.g., "7.0" from "7.0.5-4")
 	versionParts := strings.Split(version, ".")
 	if len(versionParts) < 2 {
@@ -623,7 +650,7 @@ func (bm *BinaryManager) buildPerconaURL(version string, platform Platform) (str
 	var lastErr error
 	for _, url := range urlVariants {
 		// Verify URL exists with HEAD request
-		resp, err := http.Head(url)
+		resp, err := httpHead(ctx, url)
 		if err != nil {
 			lastErr = err
 			continue
@@ -647,7 +674,7 @@ func (bm *BinaryManager) buildPerconaURL(version string, platform Platform) (str
 // buildPerconaDebURL constructs URLs for Percona .deb packages
 // [UPG-002] Fallback for older versions without minimal tarballs (6.0, 4.4, 4.2, 4.0, 3.6)
 // Format: http://repo.percona.com/psmdb-{major.minor}/apt/pool/main/p/percona-server-mongodb/percona-server-mongodb-{component}_{version}.{distro}_amd64.deb
-func (bm *BinaryManager) buildPerconaDebURLs(version string, platform Platform) (map[string]string, error) {
+func (bm *BinaryManager) buildPerconaDebURLs(ctx context.Context, version string, platform Platform) (map[string]string, error) {
 	// Only support Linux for .deb packages
 	if platform.OS != "linux" {
 		return nil, fmt.Errorf(".deb packages only available for Linux")
@@ -729,7 +756,7 @@ func (bm *BinaryManager) buildPerconaDebURLs(version string, platform Platform)
 			url := fmt.Sprintf("%s/%s", baseURL, packageName)
 
 			// Verify URL exists
-			resp, err := http.Head(url)
+			resp, err := httpHead(ctx, url)
 			if err != nil || resp.StatusCode != http.StatusOK {
 				allFound = false
 				break
@@ -750,9 +777,9 @@ func (bm *BinaryManager) buildPerconaDebURLs(version string, platform Platform)
 // resolveVersion resolves a version string to the exact patch version
 // If user specified patch version (X.Y.Z), returns it as-is
 // If user specified minor version (X.Y), finds and returns the latest patch version
-func (bm *BinaryManager) resolveVersion(version string) (string, error) {
+func (bm *BinaryManager) resolveVersion(ctx context.Context, version string) (string, error) {
 	// Get cached or fetch MongoDB versions
-	fullJSON, err := bm.getMongoDBVersions()
+	fullJSON, err := bm.getMongoDBVersions(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -814,7 +841,7 @@ func (bm *BinaryManager) resolveVersion(version string) (string, error) {
 
 // downloadAndExtractDebPackages downloads and extracts Percona .deb packages
 // [UPG-002] Extracts binaries from .deb packages for older Percona versions
-func (bm *BinaryManager) downloadAndExtractDebPackages(debURLs map[string]string, targetBinDir string) error {
+func (bm *BinaryManager) downloadAndExtractDebPackages(ctx context.Context, debURLs map[string]string, targetBinDir string) error {
 	// Create temp directory for .deb extraction
 	tempDir, err := os.MkdirTemp("", "percona-deb-*")
 	if err != nil {
@@ -827,7 +854,7 @@ func (bm *BinaryManager) downloadAndExtractDebPackages(debURLs map[string]string
 		fmt.Printf("  Downloading %s from %s...\n", component, url)
 
 		// Download .deb file
-		resp, err := http.Get(url)
+		resp, err := httpGet(ctx, url)
 		if err != nil {
 			return fmt.Errorf("failed to download %s: %w", component, err)
 		}
@@ -853,7 +880,7 @@ func (bm *BinaryManager) downloadAndExtractDebPackages(debURLs map[string]string
 		// Extract .deb package (ar format)
 		// .deb contains: debian-binary, control.tar.*, data.tar.*
 		// We need data.tar.* which contains the actual binaries
-		if err := bm.extractDebPackage(debFile, targetBinDir); err != nil {
+		if err := bm.extractDebPackage(ctx, debFile, targetBinDir); err != nil {
 			return fmt.Errorf("failed to extract %s: %w", component, err)
 		}
 	}
@@ -862,7 +889,7 @@ func (bm *BinaryManager) downloadAndExtractDebPackages(debURLs map[string]string
 }
 
 // extractDebPackage extracts binaries from a .deb package
-func (bm *BinaryManager) extractDebPackage(debFile, targetBinDir string) error {
+func (bm *BinaryManager) extractDebPackage(ctx context.Context, debFile, targetBinDir string) error {
 	// Create temp directory for this .deb
 	tempDir, err := os.MkdirTemp("", "deb-extract-*")
 	if err != nil {
@@ -871,7 +898,7 @@ func (bm *BinaryManager) extractDebPackage(debFile, targetBinDir string) error {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 
 	// Extract .deb using ar
-	arCmd := exec.Command("ar", "-x", debFile)
+	arCmd := exec.CommandContext(ctx, "ar", "-x", debFile)
 	arCmd.Dir = tempDir
 	if output, err := arCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("ar extraction failed: %w\nOutput: %s", err, output)
@@ -920,7 +947,7 @@ func (bm *BinaryManager) extractDebPackage(debFile, targetBinDir string) error {
 		tarReader = tar.NewReader(gzReader)
 	} else if strings.HasSuffix(dataTar, ".xz") {
 		// xz compression - use external xz command
-		xzCmd := exec.Command("xz", "-dc", dataTar)
+		xzCmd := exec.CommandContext(ctx, "xz", "-dc", dataTar)
 		xzOut, err := xzCmd.StdoutPipe()
 		if err != nil {
 			return fmt.Errorf("failed to create xz pipe: %w", err)
@@ -1181,7 +1208,7 @@ func (bm *BinaryManager) copyBinaries(sourceBinDir, targetBinDir string) error {
 
 // ensureMongosh ensures mongosh is available in the binPath directory
 // mongosh is downloaded separately from the server binaries (from GitHub releases)
-func (bm *BinaryManager) ensureMongosh(version string, platform Platform, binPath string) error {
+func (bm *BinaryManager) ensureMongosh(ctx context.Context, version string, platform Platform, binPath string) error {
 	// Check if mongosh already exists
 	mongoshPath := filepath.Join(binPath, "mongosh")
 	if platform.OS == "windows" {
@@ -1207,14 +1234,14 @@ func (bm *BinaryManager) ensureMongosh(version string, platform Platform, binPat
 
 	// Get latest mongosh version and download URL
 	// mongosh version doesn't need to match MongoDB server version
-	mongoshVersion, mongoshURL, err := bm.getLatestMongoshDownloadURL(platform)
+	mongoshVersion, mongoshURL, err := bm.getLatestMongoshDownloadURL(ctx, platform)
 	if err != nil {
 		return fmt.Errorf("failed to get mongosh download URL: %w", err)
 	}
 
 	fmt.Printf("  Downloading mongosh %s for %s from %s...\n", mongoshVersion, platform.Key(), mongoshURL)
 	// Download mongosh archive
-	resp, err := http.Get(mongoshURL)
+	resp, err := httpGet(ctx, mongoshURL)
 	if err != nil {
 		return fmt.Errorf("failed to download mongosh: %w", err)
 	}
@@ -1298,7 +1325,7 @@ func (bm *BinaryManager) ensureMongosh(version string, platform Platform, binPat
 
 // getLatestMongoshDownloadURL gets the mongosh version and download URL from GitHub releases
 // Uses a hardcoded version (2.5.9) to avoid GitHub API rate limiting
-func (bm *BinaryManager) getLatestMongoshDownloadURL(platform Platform) (string, string, error) {
+func (bm *BinaryManager) getLatestMongoshDownloadURL(ctx context.Context, platform Platform) (string, string, error) {
 	// Use hardcoded version to avoid GitHub API rate limiting (403 errors)
 	mongoshVersion := "2.5.9"
 	tagName := "v" + mongoshVersion
@@ -1338,7 +1365,7 @@ func (bm *BinaryManager) getLatestMongoshDownloadURL(platform Platform) (string,
 		tagName, mongoshVersion, targetOS, mongoshArch, ext)
 
 	// Verify URL exists before returning
-	resp, err := http.Head(url)
+	resp, err := httpHead(ctx, url)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to verify mongosh URL: %w", err)
 	}
@@ -1384,7 +1411,7 @@ func (bm *BinaryManager) findMongoshBinary(extractDir string, platform Platform)
 
 // ensureMongo ensures mongo (legacy shell) is available in the binPath directory
 // mongo is typically included in server archives for versions < 4.0, but we verify it exists
-func (bm *BinaryManager) ensureMongo(_ string, platform Platform, binPath string) error {
+func (bm *BinaryManager) ensureMongo(ctx context.Context, _ string, platform Platform, binPath string) error {
 	// Check if mongo already exists
 	mongoPath := filepath.Join(binPath, "mongo")
 	if platform.OS == "windows" {
@@ -1402,6 +1429,24 @@ func (bm *BinaryManager) ensureMongo(_ string, platform Platform, binPath string
 	return fmt.Errorf("mongo binary not found in binPath (expected in server archive for MongoDB < 4.0)")
 }
 
+// PlatformFromOSInfo maps executor-reported OS/arch to a Platform,
+// normalizing uname-style arch names
+func PlatformFromOSInfo(osInfo *executor.OSInfo) Platform {
+	platform := Platform{
+		OS:   osInfo.OS,
+		Arch: osInfo.Arch,
+	}
+
+	switch platform.Arch {
+	case "x86_64":
+		platform.Arch = "amd64"
+	case "aarch64":
+		platform.Arch = "arm64"
+	}
+
+	return platform
+}
+
 // CollectPlatforms collects all unique platforms from the topology
 func (d *Deployer) CollectPlatforms(ctx context.Context) (map[Platform]bool, error) {
 	platforms := make(map[Platform]bool)
@@ -1422,16 +1467,7 @@ func (d *Deployer) CollectPlatforms(ctx context.Context) (map[Platform]bool, err
 				return
 			}
 
-			// Map executor OS/Arch to Platform
-			platform := Platform{
-				OS:   osInfo.OS,
-				Arch: osInfo.Arch,
-			}
-
-			// Normalize arch names
-			if platform.Arch == "x86_64" {
-				platform.Arch = "amd64"
-			}
+			platform := PlatformFromOSInfo(osInfo)
 
 			mu.Lock()
 			platforms[platform] = true
@@ -1,6 +1,7 @@
 package deploy
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -135,7 +136,7 @@ func TestBinaryManager_BuildPerconaURL(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			url, err := bm.buildPerconaURL(tc.version, tc.platform)
+			url, err := bm.buildPerconaURL(context.Background(), tc.version, tc.platform)
 
 			if tc.wantErr {
 				if err == nil {
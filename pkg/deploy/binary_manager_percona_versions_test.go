@@ -1,6 +1,7 @@
 package deploy
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"testing"
@@ -94,12 +95,12 @@ func TestPercona_AvailableVersions(t *testing.T) {
 	for _, tv := range testVersions {
 		t.Run(fmt.Sprintf("percona-%s", tv.version), func(t *testing.T) {
 			// Try tarball first
-			url, err := bm.buildPerconaURL(tv.version, platform)
+			url, err := bm.buildPerconaURL(context.Background(), tv.version, platform)
 			downloadMethod := "tarball"
 
 			// If tarball not found, try .deb packages
 			if err != nil {
-				debURLs, debErr := bm.buildPerconaDebURLs(tv.version, platform)
+				debURLs, debErr := bm.buildPerconaDebURLs(context.Background(), tv.version, platform)
 				if debErr == nil && len(debURLs) > 0 {
 					// .deb packages found
 					err = nil
@@ -3,6 +3,7 @@ package deploy
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 // newTestBinaryManager creates a BinaryManager with a temp directory for testing
@@ -689,7 +691,7 @@ func TestBinaryManager_constructFallbackURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			url, err := bm.constructFallbackURL(tt.version, tt.targetOS, tt.mongoArch)
+			url, err := bm.constructFallbackURL(context.Background(), tt.version, tt.targetOS, tt.mongoArch)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("constructFallbackURL() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -704,3 +706,31 @@ func TestBinaryManager_constructFallbackURL(t *testing.T) {
 		})
 	}
 }
+
+func TestBinaryManager_DownloadStopsOnCancel(t *testing.T) {
+	bm, _ := newTestBinaryManager(t)
+
+	// The server sends part of a package and then stalls until the client
+	// goes away
+	clientGone := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("!<arch>\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(clientGone)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	err := bm.downloadAndExtractDebPackages(ctx, map[string]string{"server": server.URL + "/server.deb"}, t.TempDir())
+	if err == nil {
+		t.Fatal("downloadAndExtractDebPackages() succeeded after cancellation")
+	}
+	select {
+	case <-clientGone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the transfer kept running after cancellation")
+	}
+}
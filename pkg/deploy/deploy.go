@@ -1,7 +1,6 @@
 package deploy
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -130,65 +129,27 @@ func (d *Deployer) GenerateMongodConfig(node topology.MongodNode) error {
 		"mongod.conf",
 	)
 
-	// Build template data
-	data := template.MongodConfig{
-		Net: template.NetConfig{
-			Port:   node.Port,
-			BindIP: "127.0.0.1",
-		},
-		Storage: template.StorageConfig{
-			DBPath: d.getNodeDataDir(node.Host, node.Port, node.DataDir),
-			Journal: template.JournalConfig{
-				Enabled: true,
-			},
-			Engine: "wiredTiger",
-			WiredTiger: &template.WiredTigerConfig{
-				EngineConfig: template.WiredTigerEngineConfig{
-					CacheSizeGB: 1.0,
-				},
-			},
-		},
-		SystemLog: template.SystemLogConfig{
-			Destination: "file",
-			Path:        filepath.Join(d.getNodeLogDir(node.Host, node.Port, node.LogDir), naming.GetLogFileName()),
-			LogAppend:   true,
-		},
-		ProcessManagement: template.ProcessManagementConfig{
-			Fork:        false,
-			PIDFilePath: filepath.Join(d.getNodeDataDir(node.Host, node.Port, node.DataDir), naming.GetPIDFileName()),
-		},
-	}
-
-	// Add replication config if this is part of a replica set
-	if node.ReplicaSet != "" {
-		data.Replication = &template.ReplicationConfig{
-			ReplSetName:               node.ReplicaSet,
-			EnableMajorityReadConcern: true,
-		}
-	}
-
-	// Add sharding config if this is a sharded cluster
-	// Mongod nodes in sharded clusters need to be configured as shard servers
+	role := "standalone"
 	if d.topology.GetTopologyType() == "sharded" {
-		data.Sharding = &template.ShardingConfig{
-			ClusterRole: "shardsvr",
-		}
-	}
-
-	// Get appropriate template for MongoDB version
-	tmpl, err := d.templateMgr.GetTemplate("mongod", d.version)
+		// Mongod nodes in sharded clusters need to be configured as shard servers
+		role = "shardsvr"
+	}
+
+	content, err := d.templateMgr.RenderMongod(d.version, template.MongodOptions{
+		Role:          role,
+		ReplicaSet:    node.ReplicaSet,
+		Port:          node.Port,
+		BindIP:        "127.0.0.1",
+		DataDir:       d.getNodeDataDir(node.Host, node.Port, node.DataDir),
+		LogDir:        d.getNodeLogDir(node.Host, node.Port, node.LogDir),
+		RuntimeConfig: node.RuntimeConfig,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get template: %w", err)
-	}
-
-	// Execute template
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		return err
 	}
 
 	// Upload configuration
-	if err := exec.UploadContent(buf.Bytes(), configPath); err != nil {
+	if err := exec.UploadContent(content, configPath); err != nil {
 		return fmt.Errorf("failed to upload config: %w", err)
 	}
 
@@ -206,37 +167,19 @@ func (d *Deployer) GenerateMongosConfig(node topology.MongosNode) error {
 		"mongos.conf",
 	)
 
-	// Build template data
-	data := template.MongosConfig{
-		Net: template.NetConfig{
-			Port:   node.Port,
-			BindIP: "127.0.0.1",
-		},
-		SystemLog: template.SystemLogConfig{
-			Destination: "file",
-			Path:        filepath.Join(d.getNodeLogDirWithType(node.Host, node.Port, node.LogDir, "mongos"), naming.GetLogFileName()),
-			LogAppend:   true,
-		},
-		// ProcessManagement is nil (omitted) for mongos 3.6 compatibility
-		Sharding: template.MongosShardingConfig{
-			ConfigDB: d.getConfigServerConnectionString(),
-		},
-	}
-
-	// Get appropriate template for MongoDB version
-	tmpl, err := d.templateMgr.GetTemplate("mongos", d.version)
+	content, err := d.templateMgr.RenderMongos(d.version, template.MongosOptions{
+		Port:          node.Port,
+		BindIP:        "127.0.0.1",
+		LogDir:        d.getNodeLogDirWithType(node.Host, node.Port, node.LogDir, "mongos"),
+		ConfigDB:      d.getConfigServerConnectionString(),
+		RuntimeConfig: node.RuntimeConfig,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get template: %w", err)
-	}
-
-	// Execute template
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		return err
 	}
 
 	// Upload configuration
-	if err := exec.UploadContent(buf.Bytes(), configPath); err != nil {
+	if err := exec.UploadContent(content, configPath); err != nil {
 		return fmt.Errorf("failed to upload config: %w", err)
 	}
 
@@ -254,56 +197,22 @@ func (d *Deployer) GenerateConfigServerConfig(node topology.ConfigNode) error {
 		"config.conf",
 	)
 
-	// Build template data (config servers are mongod with clusterRole: configsvr)
-	data := template.MongodConfig{
-		Net: template.NetConfig{
-			Port:   node.Port,
-			BindIP: "127.0.0.1",
-		},
-		Storage: template.StorageConfig{
-			DBPath: d.getNodeDataDir(node.Host, node.Port, node.DataDir),
-			Journal: template.JournalConfig{
-				Enabled: true,
-			},
-			Engine: "wiredTiger",
-			WiredTiger: &template.WiredTigerConfig{
-				EngineConfig: template.WiredTigerEngineConfig{
-					CacheSizeGB: 1.0,
-				},
-			},
-		},
-		SystemLog: template.SystemLogConfig{
-			Destination: "file",
-			Path:        filepath.Join(d.getNodeLogDirWithType(node.Host, node.Port, node.LogDir, "config"), naming.GetLogFileName()),
-			LogAppend:   true,
-		},
-		ProcessManagement: template.ProcessManagementConfig{
-			Fork:        false,
-			PIDFilePath: filepath.Join(d.getNodeDataDir(node.Host, node.Port, node.DataDir), naming.GetPIDFileName()),
-		},
-		Replication: &template.ReplicationConfig{
-			ReplSetName:               node.ReplicaSet,
-			EnableMajorityReadConcern: true,
-		},
-		Sharding: &template.ShardingConfig{
-			ClusterRole: "configsvr",
-		},
-	}
-
-	// Get appropriate template for MongoDB version (config servers use config templates)
-	tmpl, err := d.templateMgr.GetTemplate("config", d.version)
+	// Config servers are mongod with clusterRole: configsvr
+	content, err := d.templateMgr.RenderMongod(d.version, template.MongodOptions{
+		Role:          "configsvr",
+		ReplicaSet:    node.ReplicaSet,
+		Port:          node.Port,
+		BindIP:        "127.0.0.1",
+		DataDir:       d.getNodeDataDir(node.Host, node.Port, node.DataDir),
+		LogDir:        d.getNodeLogDirWithType(node.Host, node.Port, node.LogDir, "config"),
+		RuntimeConfig: node.RuntimeConfig,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get template: %w", err)
-	}
-
-	// Execute template
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		return err
 	}
 
 	// Upload configuration
-	if err := exec.UploadContent(buf.Bytes(), configPath); err != nil {
+	if err := exec.UploadContent(content, configPath); err != nil {
 		return fmt.Errorf("failed to upload config: %w", err)
 	}
 
@@ -557,7 +466,7 @@ func (d *Deployer) getNodeDataDir(host string, port int, _ string) string {
 	dataDir, err := d.pathResolver.DataDir(host, port)
 	if err != nil {
 		// Fallback to old behavior if error (shouldn't happen in practice)
-		return filepath.Join(d.metaDir, "data", fmt.Sprintf("%s-%d", host, port))
+		return filepath.Join(d.metaDir, "data", naming.GetHostPortDir(host, port))
 	}
 	return dataDir
 }
@@ -606,7 +515,7 @@ func (d *Deployer) getConfigServerConnectionString() string {
 	// Build connection string
 	var members []string
 	for _, node := range d.topology.ConfigSvr {
-		members = append(members, fmt.Sprintf("%s:%d", normalizeHost(node.Host), node.Port))
+		members = append(members, topology.GetNodeID(normalizeHost(node.Host), node.Port))
 	}
 
 	return fmt.Sprintf("%s/%s", rsName, joinStrings(members, ","))
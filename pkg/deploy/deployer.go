@@ -10,6 +10,7 @@ import (
 	"github.com/zph/mup/pkg/executor"
 	"github.com/zph/mup/pkg/monitoring"
 	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/ports"
 	"github.com/zph/mup/pkg/supervisor"
 	"github.com/zph/mup/pkg/template"
 	"github.com/zph/mup/pkg/topology"
@@ -121,7 +122,11 @@ func NewDeployer(cfg DeployConfig) (*Deployer, error) {
 		// Allocate ports for local deployment with availability checking
 		fmt.Println("Allocating ports for local deployment...")
 
-		if err := topology.AllocatePortsForTopology(topo, nil); err != nil {
+		registry, err := ports.LoadDefault()
+		if err != nil {
+			return nil, err
+		}
+		if err := topology.AllocatePortsForTopology(topo, registry.Checker("localhost", cfg.ClusterName)); err != nil {
 			return nil, fmt.Errorf("failed to allocate ports: %w", err)
 		}
 	} else {
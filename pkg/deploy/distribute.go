@@ -0,0 +1,254 @@
+package deploy
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zph/mup/pkg/executor"
+)
+
+// ChecksumFileName is written next to distributed binaries on each host so
+// later deploys can skip hosts that already have the same tarball extracted
+const ChecksumFileName = ".mup-sha256"
+
+// DefaultDistributionConcurrency limits how many hosts receive uploads at once
+const DefaultDistributionConcurrency = 4
+
+// BinaryPackage is a tarball of one platform's binaries, built once and
+// uploaded to every host of that platform
+type BinaryPackage struct {
+	Platform Platform
+	Path     string // Local path to the .tar.gz
+	Checksum string // Hex-encoded sha256 of the tarball
+}
+
+// packageEntry dedupes concurrent PackageBinaries calls for the same key
+type packageEntry struct {
+	once sync.Once
+	pkg  *BinaryPackage
+	err  error
+}
+
+// PackageBinaries downloads (if needed) and packs the binaries for one
+// variant/version/platform into a tarball under the package cache.
+// Concurrent callers for the same platform share a single download and tarball.
+func (bm *BinaryManager) PackageBinaries(version string, variant Variant, platform Platform) (*BinaryPackage, error) {
+	key := packageKey(version, variant, platform)
+
+	bm.mu.Lock()
+	if bm.packages == nil {
+		bm.packages = make(map[string]*packageEntry)
+	}
+	entry, ok := bm.packages[key]
+	if !ok {
+		entry = &packageEntry{}
+		bm.packages[key] = entry
+	}
+	bm.mu.Unlock()
+
+	entry.once.Do(func() {
+		binPath, err := bm.GetBinPathWithVariant(version, variant, platform)
+		if err != nil {
+			entry.err = err
+			return
+		}
+
+		tarballPath := bm.packagePath(key)
+		checksum, err := buildTarball(binPath, tarballPath)
+		if err != nil {
+			entry.err = fmt.Errorf("failed to package binaries for %s: %w", platform.Key(), err)
+			return
+		}
+
+		entry.pkg = &BinaryPackage{
+			Platform: platform,
+			Path:     tarballPath,
+			Checksum: checksum,
+		}
+	})
+
+	if entry.err != nil {
+		// Allow a later call to retry after a transient failure
+		bm.mu.Lock()
+		delete(bm.packages, key)
+		bm.mu.Unlock()
+		return nil, entry.err
+	}
+
+	return entry.pkg, nil
+}
+
+// CachedPackage returns the package PackageBinaries built for
+// variant/version/platform, by this or an earlier run, without downloading
+// or building anything. ok is false when no tarball is cached.
+func (bm *BinaryManager) CachedPackage(version string, variant Variant, platform Platform) (pkg *BinaryPackage, ok bool) {
+	tarballPath := bm.packagePath(packageKey(version, variant, platform))
+	checksum, err := fileChecksum(tarballPath)
+	if err != nil {
+		return nil, false
+	}
+	return &BinaryPackage{Platform: platform, Path: tarballPath, Checksum: checksum}, true
+}
+
+// packageKey identifies a package by variant, version and platform
+func packageKey(version string, variant Variant, platform Platform) string {
+	return fmt.Sprintf("%s-%s-%s", variant.String(), version, platform.Key())
+}
+
+// packagePath returns where the tarball for key is cached
+func (bm *BinaryManager) packagePath(key string) string {
+	return filepath.Join(bm.cacheDir, "dist", key+".tar.gz")
+}
+
+// fileChecksum returns the hex-encoded sha256 of the file at path
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// buildTarball packs the regular files in srcDir into a gzipped tarball at
+// destPath and returns its sha256. Files are added in sorted order so the
+// checksum is stable for identical inputs.
+func buildTarball(srcDir, destPath string) (string, error) {
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", srcDir, err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create package directory: %w", err)
+	}
+
+	tmpPath := destPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tarball: %w", err)
+	}
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, hash))
+	tw := tar.NewWriter(gz)
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := addFileToTar(tw, filepath.Join(srcDir, entry.Name()), entry.Name()); err != nil {
+			_ = f.Close()
+			return "", err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("failed to finalize tarball: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("failed to finalize tarball: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close tarball: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		return "", fmt.Errorf("failed to move tarball into place: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// addFileToTar writes a single file into tw under name
+func addFileToTar(tw *tar.Writer, path, name string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("failed to build tar header for %s: %w", path, err)
+	}
+	header.Name = name
+	// Drop timestamps and ownership so repacking identical binaries yields the same checksum
+	header.ModTime = time.Unix(0, 0)
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header for %s: %w", path, err)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() { _ = src.Close() }()
+
+	if _, err := io.Copy(tw, src); err != nil {
+		return fmt.Errorf("failed to add %s to tarball: %w", path, err)
+	}
+	return nil
+}
+
+// RemoteChecksum returns the checksum recorded in destDir on the host, or ""
+// if none has been written yet
+func RemoteChecksum(ctx context.Context, exec executor.Executor, destDir string) string {
+	out, err := exec.ExecuteContext(ctx, fmt.Sprintf("cat '%s' 2>/dev/null", filepath.Join(destDir, ChecksumFileName)))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// InstallPackage uploads pkg to the host, extracts it into destDir and
+// records the checksum. It is a no-op if destDir already holds the same package.
+// Returns true if an upload was performed.
+func InstallPackage(ctx context.Context, exec executor.Executor, pkg *BinaryPackage, destDir string) (bool, error) {
+	if RemoteChecksum(ctx, exec, destDir) == pkg.Checksum {
+		return false, nil
+	}
+
+	if err := exec.CreateDirectoryContext(ctx, destDir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", destDir, err)
+	}
+
+	remoteTarball := filepath.Join(destDir, fmt.Sprintf(".mup-%s.tar.gz", pkg.Checksum[:12]))
+	if err := exec.UploadFileContext(ctx, pkg.Path, remoteTarball); err != nil {
+		return false, fmt.Errorf("failed to upload %s: %w", filepath.Base(pkg.Path), err)
+	}
+	defer func() { _ = exec.RemoveFileContext(context.WithoutCancel(ctx), remoteTarball) }()
+
+	if out, err := exec.ExecuteContext(ctx, fmt.Sprintf("tar -xzf '%s' -C '%s'", remoteTarball, destDir)); err != nil {
+		return false, fmt.Errorf("failed to extract binaries: %w (output: %s)", err, out)
+	}
+
+	// Write the checksum last so an interrupted install is retried next time
+	if err := exec.UploadContentContext(ctx, []byte(pkg.Checksum+"\n"), filepath.Join(destDir, ChecksumFileName)); err != nil {
+		return false, fmt.Errorf("failed to record checksum: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,106 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/simulation"
+	"github.com/zph/mup/pkg/topology"
+)
+
+func TestBuildTarball_StableChecksum(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "mongod"), []byte("mongod-binary"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "mongos"), []byte("mongos-binary"), 0755))
+
+	outDir := t.TempDir()
+	first, err := buildTarball(srcDir, filepath.Join(outDir, "a.tar.gz"))
+	require.NoError(t, err)
+
+	// Touch a file; identical content must still produce the same checksum
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(srcDir, "mongod"), later, later))
+
+	second, err := buildTarball(srcDir, filepath.Join(outDir, "b.tar.gz"))
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Len(t, first, 64)
+}
+
+func TestCachedPackage(t *testing.T) {
+	bm := &BinaryManager{cacheDir: t.TempDir()}
+	platform := Platform{OS: "linux", Arch: "amd64"}
+
+	_, ok := bm.CachedPackage("7.0.12", VariantMongo, platform)
+	assert.False(t, ok)
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "mongod"), []byte("mongod-binary"), 0755))
+	checksum, err := buildTarball(srcDir, bm.packagePath(packageKey("7.0.12", VariantMongo, platform)))
+	require.NoError(t, err)
+
+	pkg, ok := bm.CachedPackage("7.0.12", VariantMongo, platform)
+	require.True(t, ok)
+	assert.Equal(t, checksum, pkg.Checksum)
+	assert.Equal(t, platform, pkg.Platform)
+}
+
+func TestInstallPackage_SkipsMatchingChecksum(t *testing.T) {
+	ctx := context.Background()
+	pkg := &BinaryPackage{
+		Platform: Platform{OS: "linux", Arch: "amd64"},
+		Path:     "/cache/mongo-7.0-linux-amd64.tar.gz",
+		Checksum: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+
+	config := simulation.NewConfig()
+	config.Responses["cat '/opt/mup/7.0/bin/.mup-sha256' 2>/dev/null"] = pkg.Checksum + "\n"
+	exec := simulation.NewExecutor(config)
+
+	uploaded, err := InstallPackage(ctx, exec, pkg, "/opt/mup/7.0/bin")
+	require.NoError(t, err)
+	assert.False(t, uploaded)
+
+	for _, op := range exec.GetOperations() {
+		assert.NotEqual(t, "upload_file", op.Type, "no upload expected when checksum matches")
+	}
+}
+
+func TestPlatformFromOSInfo(t *testing.T) {
+	tests := []struct {
+		arch string
+		want string
+	}{
+		{"x86_64", "amd64"},
+		{"aarch64", "arm64"},
+		{"arm64", "arm64"},
+	}
+
+	for _, tt := range tests {
+		p := PlatformFromOSInfo(&executor.OSInfo{OS: "linux", Arch: tt.arch})
+		assert.Equal(t, tt.want, p.Arch)
+	}
+}
+
+func TestPlanner_DistributeRequiresDeployDir(t *testing.T) {
+	p := newAuthPlanner(&topology.Topology{
+		Mongod: []topology.MongodNode{{Host: "db1", Port: 27017}},
+	})
+
+	_, err := p.generatePreparePhase()
+	assert.ErrorContains(t, err, "deploy_dir is empty")
+
+	p.topology.Global.DeployDir = "/opt/mongodb"
+	prepare, err := p.generatePreparePhase()
+	require.NoError(t, err)
+	distribute := opsOfType(prepare, plan.OpDistributeBinary)
+	require.Len(t, distribute, 1)
+	assert.Equal(t, "/opt/mongodb/7.0.0/bin", distribute[0].Params["dest_dir"])
+}
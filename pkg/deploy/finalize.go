@@ -11,6 +11,9 @@ import (
 
 	"github.com/zph/mup/pkg/meta"
 	"github.com/zph/mup/pkg/mongo"
+	"github.com/zph/mup/pkg/naming"
+	"github.com/zph/mup/pkg/ports"
+	"github.com/zph/mup/pkg/supervisor"
 	"github.com/zph/mup/pkg/topology"
 )
 
@@ -68,6 +71,10 @@ type NodeMetadata struct {
 	ConfigFile string `yaml:"config_file"`
 	PID        int    `yaml:"pid,omitempty"` // Deprecated: supervisord manages PIDs now
 
+	// AdvertiseHost is the host other members and clients use, when it
+	// differs from Host
+	AdvertiseHost string `yaml:"advertise_host,omitempty"`
+
 	// Supervisord fields
 	SupervisorProgramName string `yaml:"supervisor_program_name,omitempty"` // Name in supervisor config (e.g., "mongod-27017")
 	SupervisorConfigFile  string `yaml:"supervisor_config_file,omitempty"`  // Path to node's supervisor config
@@ -118,6 +125,19 @@ func (d *Deployer) saveMetadata(_ context.Context) error {
 	}
 
 	fmt.Printf("  ✓ Metadata saved: %s\n", metaFile)
+
+	// Reserve the cluster's ports so they are not reused while it is stopped
+	reserved := &meta.ClusterMetadata{Name: d.clusterName, Monitoring: metadata.Monitoring}
+	for _, node := range metadata.Nodes {
+		reserved.Nodes = append(reserved.Nodes, meta.NodeMetadata{Type: node.Type, Host: node.Host, Port: node.Port})
+	}
+	conflicts, err := ports.Record(reserved, supervisor.GetSupervisorHTTPPortForDir(d.metaDir))
+	if err != nil {
+		return fmt.Errorf("failed to reserve ports: %w", err)
+	}
+	for _, c := range conflicts {
+		fmt.Printf("  ! Port %d on %s is also reserved by cluster %s (%s)\n", c.Port, c.Host, c.Cluster, c.Service)
+	}
 	return nil
 }
 
@@ -129,7 +149,7 @@ func (d *Deployer) collectNodeMetadata() []NodeMetadata {
 	for _, node := range d.topology.Mongod {
 		configDir := d.getNodeConfigDir(node.Host, node.Port, node.ConfigDir)
 		programName := fmt.Sprintf("mongod-%d", node.Port)
-		supervisorConfigFile := filepath.Join(d.metaDir, "conf", fmt.Sprintf("%s-%d", node.Host, node.Port), "supervisor-mongod.ini")
+		supervisorConfigFile := filepath.Join(d.metaDir, "conf", naming.GetHostPortDir(node.Host, node.Port), "supervisor-mongod.ini")
 
 		// Get PID from supervisor if available
 		var pid int
@@ -142,6 +162,7 @@ func (d *Deployer) collectNodeMetadata() []NodeMetadata {
 		nodes = append(nodes, NodeMetadata{
 			Type:                  "mongod",
 			Host:                  node.Host,
+			AdvertiseHost:         node.AdvertiseHost,
 			Port:                  node.Port,
 			ReplicaSet:            node.ReplicaSet,
 			DataDir:               d.getNodeDataDir(node.Host, node.Port, node.DataDir),
@@ -158,7 +179,7 @@ func (d *Deployer) collectNodeMetadata() []NodeMetadata {
 	for _, node := range d.topology.Mongos {
 		configDir := d.getNodeConfigDirWithType(node.Host, node.Port, node.ConfigDir, "mongos")
 		programName := fmt.Sprintf("mongos-%d", node.Port)
-		supervisorConfigFile := filepath.Join(d.metaDir, "conf", fmt.Sprintf("%s-%d", node.Host, node.Port), "supervisor-mongos.ini")
+		supervisorConfigFile := filepath.Join(d.metaDir, "conf", naming.GetHostPortDir(node.Host, node.Port), "supervisor-mongos.ini")
 
 		// Get PID from supervisor if available
 		var pid int
@@ -171,6 +192,7 @@ func (d *Deployer) collectNodeMetadata() []NodeMetadata {
 		nodes = append(nodes, NodeMetadata{
 			Type:                  "mongos",
 			Host:                  node.Host,
+			AdvertiseHost:         node.AdvertiseHost,
 			Port:                  node.Port,
 			LogDir:                d.getNodeLogDirWithType(node.Host, node.Port, node.LogDir, "mongos"),
 			ConfigDir:             configDir,
@@ -185,7 +207,7 @@ func (d *Deployer) collectNodeMetadata() []NodeMetadata {
 	for _, node := range d.topology.ConfigSvr {
 		configDir := d.getNodeConfigDir(node.Host, node.Port, node.ConfigDir)
 		programName := fmt.Sprintf("mongod-%d", node.Port)
-		supervisorConfigFile := filepath.Join(d.metaDir, "conf", fmt.Sprintf("%s-%d", node.Host, node.Port), "supervisor-mongod.ini")
+		supervisorConfigFile := filepath.Join(d.metaDir, "conf", naming.GetHostPortDir(node.Host, node.Port), "supervisor-mongod.ini")
 
 		// Get PID from supervisor if available
 		var pid int
@@ -198,6 +220,7 @@ func (d *Deployer) collectNodeMetadata() []NodeMetadata {
 		nodes = append(nodes, NodeMetadata{
 			Type:                  "config",
 			Host:                  node.Host,
+			AdvertiseHost:         node.AdvertiseHost,
 			Port:                  node.Port,
 			ReplicaSet:            node.ReplicaSet,
 			DataDir:               d.getNodeDataDir(node.Host, node.Port, node.DataDir),
@@ -299,7 +322,7 @@ func (d *Deployer) getConnectionString() string {
 		// Connect via mongos
 		if len(d.topology.Mongos) > 0 {
 			mongos := d.topology.Mongos[0]
-			return fmt.Sprintf("mongodb://%s:%d", mongos.Host, mongos.Port)
+			return "mongodb://" + mongos.Address()
 		}
 
 	case "replica_set":
@@ -309,7 +332,7 @@ func (d *Deployer) getConnectionString() string {
 			var hosts []string
 			for _, node := range d.topology.Mongod {
 				if node.ReplicaSet == rsName {
-					hosts = append(hosts, fmt.Sprintf("%s:%d", node.Host, node.Port))
+					hosts = append(hosts, node.Address())
 				}
 			}
 			return fmt.Sprintf("mongodb://%s/?replicaSet=%s", joinStrings(hosts, ","), rsName)
@@ -319,7 +342,7 @@ func (d *Deployer) getConnectionString() string {
 		// Connect to single mongod
 		if len(d.topology.Mongod) > 0 {
 			node := d.topology.Mongod[0]
-			return fmt.Sprintf("mongodb://%s:%d", node.Host, node.Port)
+			return "mongodb://" + node.Address()
 		}
 	}
 
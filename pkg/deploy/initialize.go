@@ -14,6 +14,7 @@ import (
 
 	"github.com/zph/mup/pkg/logger"
 	"github.com/zph/mup/pkg/naming"
+	"github.com/zph/mup/pkg/topology"
 )
 
 // initialize implements Phase 4: Initialize
@@ -198,15 +199,6 @@ func (d *Deployer) initializeReplicaSets(ctx context.Context) error {
 	return nil
 }
 
-// ReplicaSetMember represents a member of a replica set
-type ReplicaSetMember struct {
-	Host     string
-	Port     int
-	Priority float64
-	Hidden   bool
-	Votes    int
-}
-
 // normalizeHost converts localhost to 127.0.0.1 to avoid IPv6 resolution issues
 // The MongoDB Go driver resolves "localhost" to IPv6 ([::1]) by default, but mongod
 // processes typically only listen on IPv4. This normalization ensures connections use IPv4.
@@ -222,40 +214,17 @@ func (d *Deployer) collectReplicaSets() map[string][]ReplicaSetMember {
 	replicaSets := make(map[string][]ReplicaSetMember)
 
 	// Collect from mongod nodes
-	for _, node := range d.topology.Mongod {
+	for i := range d.topology.Mongod {
+		node := &d.topology.Mongod[i]
 		if node.ReplicaSet != "" {
-			member := ReplicaSetMember{
-				Host:     normalizeHost(node.Host),
-				Port:     node.Port,
-				Priority: 1.0,
-				Hidden:   false,
-				Votes:    1,
-			}
-
-			if node.Priority != nil {
-				member.Priority = *node.Priority
-			}
-			if node.Hidden != nil {
-				member.Hidden = *node.Hidden
-			}
-			if node.Votes != nil {
-				member.Votes = *node.Votes
-			}
-
-			replicaSets[node.ReplicaSet] = append(replicaSets[node.ReplicaSet], member)
+			replicaSets[node.ReplicaSet] = append(replicaSets[node.ReplicaSet], NewReplicaSetMember(node))
 		}
 	}
 
 	// Collect from config servers
-	for _, node := range d.topology.ConfigSvr {
-		member := ReplicaSetMember{
-			Host:     normalizeHost(node.Host),
-			Port:     node.Port,
-			Priority: 1.0,
-			Hidden:   false,
-			Votes:    1,
-		}
-		replicaSets[node.ReplicaSet] = append(replicaSets[node.ReplicaSet], member)
+	for i := range d.topology.ConfigSvr {
+		node := &d.topology.ConfigSvr[i]
+		replicaSets[node.ReplicaSet] = append(replicaSets[node.ReplicaSet], newConfigServerMember(node))
 	}
 
 	return replicaSets
@@ -269,9 +238,12 @@ func (d *Deployer) initializeReplicaSet(ctx context.Context, rsName string, memb
 
 	fmt.Printf("  Initializing replica set: %s\n", rsName)
 
-	// Use first member as primary for initialization
-	primary := members[0]
-	primaryHost := fmt.Sprintf("%s:%d", primary.Host, primary.Port)
+	// Initialize from the first data-bearing member
+	primary, err := SeedMember(members)
+	if err != nil {
+		return fmt.Errorf("replica set %s: %w", rsName, err)
+	}
+	primaryHost := primary.Address()
 	connStr := fmt.Sprintf("mongodb://%s", primaryHost)
 
 	// Create context with timeout for initial connection
@@ -300,34 +272,27 @@ func (d *Deployer) initializeReplicaSet(ctx context.Context, rsName string, memb
 	}
 
 	// Build members list for replica set configuration
-	memberDocs := make([]bson.M, len(members))
-	for i, member := range members {
-		memberDoc := bson.M{
-			"_id":  i,
-			"host": fmt.Sprintf("%s:%d", member.Host, member.Port),
-		}
-
-		// Only include optional fields if they differ from defaults
-		if member.Priority != 1.0 {
-			memberDoc["priority"] = member.Priority
-		}
-		if member.Hidden {
-			memberDoc["hidden"] = member.Hidden
-		}
-		if member.Votes != 1 {
-			memberDoc["votes"] = member.Votes
-		}
-
-		memberDocs[i] = memberDoc
+	memberDocs := MemberDocuments(members, d.version)
+	rsOptions, err := NewReplicaSetOptions(d.topology.ReplicaSetSpecFor(rsName), d.version)
+	if err != nil {
+		return fmt.Errorf("replica set %s: %w", rsName, err)
+	}
+	if d.topology.GetTopologyType() == "sharded" {
+		// Default read/write concerns are set through mongos in configureSharding
+		rsOptions = rsOptions.WithoutDefaultRWConcern()
 	}
 
 	// Initialize replica set using replSetInitiate command
+	rsConfig := bson.M{
+		"_id":     rsName,
+		"version": 1,
+		"members": memberDocs,
+	}
+	if rsOptions != nil {
+		rsOptions.ApplyToConfig(rsConfig)
+	}
 	cmd := bson.D{
-		{Key: "replSetInitiate", Value: bson.M{
-			"_id":     rsName,
-			"version": 1,
-			"members": memberDocs,
-		}},
+		{Key: "replSetInitiate", Value: rsConfig},
 	}
 
 	err = client.Database("admin").RunCommand(initCtx, cmd).Err()
@@ -443,7 +408,7 @@ func (d *Deployer) initializeReplicaSet(ctx context.Context, rsName string, memb
 				// This ensures the replica set topology is ready (not RSGhost)
 				var hosts []string
 				for _, member := range members {
-					hosts = append(hosts, fmt.Sprintf("%s:%d", member.Host, member.Port))
+					hosts = append(hosts, topology.GetNodeID(member.Host, member.Port))
 				}
 				replSetConnStr := fmt.Sprintf("mongodb://%s/?replicaSet=%s", strings.Join(hosts, ","), rsName)
 				connectionVerified := false
@@ -458,6 +423,15 @@ func (d *Deployer) initializeReplicaSet(ctx context.Context, rsName string, memb
 						pingCtx, pingCancel := context.WithTimeout(waitCtx, 10*time.Second)
 						err = replSetClient.Ping(pingCtx, nil)
 						pingCancel()
+						if err == nil && rsOptions.HasDefaultRWConcern() {
+							// Default read/write concerns can only be set on the primary
+							err = replSetClient.Database("admin").RunCommand(waitCtx, rsOptions.DefaultRWConcernCommand()).Err()
+							if err != nil {
+								_ = replSetClient.Disconnect(waitCtx)
+								return fmt.Errorf("failed to set default read/write concern on replica set %s: %w", rsName, err)
+							}
+							fmt.Printf("    ✓ Set default read/write concern on replica set %s\n", rsName)
+						}
 						_ = replSetClient.Disconnect(waitCtx)
 
 						if err == nil {
@@ -514,7 +488,7 @@ func (d *Deployer) configureSharding(ctx context.Context) error {
 
 	// Use first mongos for configuration
 	mongos := d.topology.Mongos[0]
-	mongosHost := fmt.Sprintf("%s:%d", mongos.Host, mongos.Port)
+	mongosHost := topology.GetNodeID(mongos.Host, mongos.Port)
 	connStr := fmt.Sprintf("mongodb://%s", mongosHost)
 
 	// Create context with timeout for connection
@@ -574,7 +548,9 @@ func (d *Deployer) configureSharding(ctx context.Context) error {
 		// Format: {replicaSetName}/{host1,host2,host3}
 		var hosts []string
 		for _, member := range members {
-			hosts = append(hosts, fmt.Sprintf("%s:%d", member.Host, member.Port))
+			if !member.ArbiterOnly {
+				hosts = append(hosts, member.Address())
+			}
 		}
 		shardConnStr := fmt.Sprintf("%s/%s", rsName, strings.Join(hosts, ","))
 
@@ -596,6 +572,18 @@ func (d *Deployer) configureSharding(ctx context.Context) error {
 		fmt.Printf("  ✓ Added shard: %s\n", rsName)
 	}
 
+	// Default read/write concerns are cluster-wide and set once through mongos
+	rwOptions, err := NewReplicaSetOptions(d.topology.DefaultRWConcernSpec(), d.version)
+	if err != nil {
+		return err
+	}
+	if rwOptions.HasDefaultRWConcern() {
+		if err := client.Database("admin").RunCommand(initCtx, rwOptions.DefaultRWConcernCommand()).Err(); err != nil {
+			return fmt.Errorf("failed to set default read/write concern through mongos: %w", err)
+		}
+		fmt.Printf("  ✓ Set cluster-wide default read/write concern\n")
+	}
+
 	return nil
 }
 
@@ -688,7 +676,7 @@ func (d *Deployer) waitForMongosProcesses(ctx context.Context) error {
 
 // waitForMongosHealth waits for mongos to successfully connect to config servers
 func (d *Deployer) waitForMongosHealth(ctx context.Context, host string, port int, deadline time.Time) error {
-	mongosHost := fmt.Sprintf("%s:%d", host, port)
+	mongosHost := topology.GetNodeID(host, port)
 	connStr := fmt.Sprintf("mongodb://%s", mongosHost)
 
 	for time.Now().Before(deadline) {
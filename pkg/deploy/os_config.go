@@ -0,0 +1,134 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Recognised topology os_config keys
+const (
+	OSConfigUser                 = "user"
+	OSConfigGroup                = "group"
+	OSConfigNofile               = "nofile"
+	OSConfigNproc                = "nproc"
+	OSConfigTransparentHugepages = "transparent_hugepages"
+	OSConfigSwappiness           = "vm.swappiness"
+	OSConfigMaxMapCount          = "vm.max_map_count"
+	OSConfigFilesystem           = "filesystem"
+)
+
+// OSSettings is the validated form of a topology's os_config
+//
+// Example:
+//
+//	os_config:
+//	  user: mongod
+//	  group: mongod
+//	  nofile: "64000"
+//	  nproc: "64000"
+//	  transparent_hugepages: never
+//	  vm.swappiness: "1"
+//	  vm.max_map_count: "262144"
+//	  filesystem: xfs,ext4
+type OSSettings struct {
+	User                 string   // Service user to create
+	Group                string   // Service group to create; defaults to User
+	Nofile               int      // Open files ulimit for User
+	Nproc                int      // Process ulimit for User
+	TransparentHugepages string   // "never", "madvise" or "always"
+	Sysctls              []Sysctl // Kernel parameters, in key order
+	Filesystems          []string // Accepted data directory filesystem types
+}
+
+// Sysctl is a kernel parameter and its desired value
+type Sysctl struct {
+	Key   string
+	Value string
+}
+
+// ParseOSConfig validates a topology's os_config. It returns nil when
+// os_config is empty, in which case no prepare_os phase is planned.
+func ParseOSConfig(osConfig map[string]string) (*OSSettings, error) {
+	if len(osConfig) == 0 {
+		return nil, nil
+	}
+
+	settings := &OSSettings{}
+	keys := make([]string, 0, len(osConfig))
+	for key := range osConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := strings.TrimSpace(osConfig[key])
+		var err error
+		switch key {
+		case OSConfigUser:
+			settings.User, err = osName(value)
+		case OSConfigGroup:
+			settings.Group, err = osName(value)
+		case OSConfigNofile:
+			settings.Nofile, err = positiveInt(value)
+		case OSConfigNproc:
+			settings.Nproc, err = positiveInt(value)
+		case OSConfigTransparentHugepages:
+			switch value {
+			case "never", "madvise", "always":
+				settings.TransparentHugepages = value
+			default:
+				err = fmt.Errorf("must be one of: never, madvise, always")
+			}
+		case OSConfigSwappiness:
+			var n int
+			if n, err = strconv.Atoi(value); err == nil && (n < 0 || n > 100) {
+				err = fmt.Errorf("must be between 0 and 100")
+			}
+			settings.Sysctls = append(settings.Sysctls, Sysctl{Key: key, Value: value})
+		case OSConfigMaxMapCount:
+			_, err = positiveInt(value)
+			settings.Sysctls = append(settings.Sysctls, Sysctl{Key: key, Value: value})
+		case OSConfigFilesystem:
+			for _, fs := range strings.Split(value, ",") {
+				if fs = strings.TrimSpace(fs); fs != "" {
+					settings.Filesystems = append(settings.Filesystems, fs)
+				}
+			}
+			if len(settings.Filesystems) == 0 {
+				err = fmt.Errorf("must list at least one filesystem type")
+			}
+		default:
+			return nil, fmt.Errorf("unknown os_config key %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid os_config %s %q: %w", key, value, err)
+		}
+	}
+
+	if settings.Group == "" {
+		settings.Group = settings.User
+	}
+	if (settings.Nofile > 0 || settings.Nproc > 0) && settings.User == "" {
+		return nil, fmt.Errorf("os_config nofile and nproc require os_config user")
+	}
+
+	return settings, nil
+}
+
+// osName validates a user or group name
+func osName(value string) (string, error) {
+	if value == "" || strings.ContainsAny(value, " \t:/'\"$`\\") {
+		return "", fmt.Errorf("not a valid user or group name")
+	}
+	return value, nil
+}
+
+func positiveInt(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("must be a positive integer")
+	}
+	return n, nil
+}
@@ -0,0 +1,97 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/topology"
+)
+
+func TestParseOSConfig(t *testing.T) {
+	settings, err := ParseOSConfig(nil)
+	require.NoError(t, err)
+	assert.Nil(t, settings)
+
+	settings, err = ParseOSConfig(map[string]string{
+		"user":                  "mongod",
+		"nofile":                "64000",
+		"transparent_hugepages": "never",
+		"vm.swappiness":         "1",
+		"vm.max_map_count":      "262144",
+		"filesystem":            "xfs, ext4",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "mongod", settings.User)
+	assert.Equal(t, "mongod", settings.Group, "group defaults to user")
+	assert.Equal(t, 64000, settings.Nofile)
+	assert.Equal(t, "never", settings.TransparentHugepages)
+	assert.Equal(t, []Sysctl{{"vm.max_map_count", "262144"}, {"vm.swappiness", "1"}}, settings.Sysctls)
+	assert.Equal(t, []string{"xfs", "ext4"}, settings.Filesystems)
+}
+
+func TestParseOSConfig_Errors(t *testing.T) {
+	tests := map[string]map[string]string{
+		`unknown os_config key "swappiness"`: {"swappiness": "1"},
+		"must be between 0 and 100":          {"vm.swappiness": "200"},
+		"must be one of: never":              {"transparent_hugepages": "off"},
+		"must be a positive integer":         {"user": "mongod", "nproc": "lots"},
+		"require os_config user":             {"nofile": "64000"},
+		"not a valid user or group name":     {"user": "mon god"},
+	}
+	for wantErr, osConfig := range tests {
+		t.Run(wantErr, func(t *testing.T) {
+			_, err := ParseOSConfig(osConfig)
+			assert.ErrorContains(t, err, wantErr)
+		})
+	}
+}
+
+func TestGeneratePrepareOSPhase(t *testing.T) {
+	p := &DeployPlanner{topology: &topology.Topology{
+		Global: topology.GlobalConfig{
+			OSConfig: map[string]string{
+				"user":             "mongod",
+				"vm.max_map_count": "262144",
+				"filesystem":       "xfs",
+			},
+		},
+		Mongod: []topology.MongodNode{
+			{Host: "db2", Port: 27017, DataDir: "/data"},
+			{Host: "db1", Port: 27017, DataDir: "/data"},
+			{Host: "db1", Port: 27018, DataDir: "/data"},
+		},
+	}}
+
+	phase, err := p.generatePrepareOSPhase()
+	require.NoError(t, err)
+	require.NotNil(t, phase)
+	assert.Equal(t, "prepare_os", phase.Name)
+
+	var db1 []plan.PlannedOperation
+	for _, op := range phase.Operations {
+		if op.Target.Host == "db1" {
+			db1 = append(db1, op)
+		}
+	}
+	types := make([]plan.OperationType, 0, len(db1))
+	for _, op := range db1 {
+		types = append(types, op.Type)
+	}
+	assert.Equal(t, []plan.OperationType{
+		plan.OpEnsureGroup, plan.OpEnsureUser, plan.OpSetSysctl,
+		plan.OpCheckFilesystem, plan.OpCheckFilesystem,
+	}, types)
+
+	assert.Equal(t, "db1:vm.max_map_count", db1[2].Changes[0].ResourceID)
+	assert.Equal(t, "262144", db1[2].Changes[0].After)
+	assert.Equal(t, "/data/mongod-27018", db1[4].Params["path"])
+	assert.Equal(t, plan.ActionNone, db1[4].Changes[0].Action)
+
+	// No os_config, no phase
+	p.topology.Global.OSConfig = nil
+	phase, err = p.generatePrepareOSPhase()
+	require.NoError(t, err)
+	assert.Nil(t, phase)
+}
@@ -0,0 +1,130 @@
+package deploy
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/template"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// encryptionKeyFilePath returns where mongod reads the encryption key on
+// the nodes' hosts
+func (p *DeployPlanner) encryptionKeyFilePath() (string, error) {
+	if p.isLocal {
+		return p.layout.EncryptionKeyFile(), nil
+	}
+	return paths.NewRemotePathResolver(&p.topology.Global).EncryptionKeyFile()
+}
+
+// validatePercona fails when the topology's percona section is used with
+// another variant
+func (p *DeployPlanner) validatePercona() error {
+	if p.topology.Percona != nil && p.variant != VariantPercona {
+		return fmt.Errorf("the percona section requires variant %s, not %s", VariantPercona, p.variant)
+	}
+	return nil
+}
+
+// perconaOptions returns the Percona features of a node logging to logDir,
+// nil when none is enabled
+func (p *DeployPlanner) perconaOptions(logDir string) (*template.PerconaOptions, error) {
+	if !p.topology.EncryptionEnabled() && !p.topology.AuditLogEnabled() {
+		return nil, nil
+	}
+
+	opts := &template.PerconaOptions{}
+	if p.topology.EncryptionEnabled() {
+		keyFile, err := p.encryptionKeyFilePath()
+		if err != nil {
+			return nil, err
+		}
+		opts.EncryptionKeyFile = keyFile
+		opts.EncryptionCipherMode = p.topology.Percona.Encryption.CipherModeOrDefault()
+	}
+
+	if audit := p.topology.Percona.AuditLog; audit != nil {
+		opts.AuditLog = &template.AuditLogConfig{
+			Destination: audit.DestinationOrDefault(),
+			Filter:      strings.TrimSpace(audit.Filter),
+		}
+		if opts.AuditLog.Destination == topology.AuditLogFile {
+			opts.AuditLog.Format = audit.FormatOrDefault()
+			opts.AuditLog.Path = audit.Path
+			if opts.AuditLog.Path == "" {
+				opts.AuditLog.Path = filepath.Join(logDir, "auditLog."+strings.ToLower(opts.AuditLog.Format))
+			}
+		}
+	}
+	return opts, nil
+}
+
+// generateEncryptionKeyOperations plans the data-at-rest encryption key:
+// created on this machine and, for remote deployments, shipped to every
+// host. It returns nil when encryption is not enabled.
+func (p *DeployPlanner) generateEncryptionKeyOperations(opIndex *int) ([]plan.PlannedOperation, error) {
+	if !p.topology.EncryptionEnabled() {
+		return nil, nil
+	}
+
+	keyFile := p.layout.EncryptionKeyFile()
+	params := map[string]interface{}{
+		"key_file": keyFile,
+	}
+	if source := p.topology.Percona.Encryption.KeyFile; source != "" {
+		params["source_key_file"] = source
+	}
+
+	operations := []plan.PlannedOperation{{
+		ID:          plan.NewOperationID("prepare", *opIndex),
+		Type:        plan.OpGenerateEncryptionKey,
+		Description: fmt.Sprintf("Generate encryption key %s", keyFile),
+		Target: plan.OperationTarget{
+			Type: "keyfile",
+			Name: p.clusterName,
+		},
+		Params: params,
+		Changes: []plan.Change{
+			{ResourceType: "file", ResourceID: keyFile, Action: plan.ActionCreate},
+		},
+		Parallel: false,
+	}}
+	*opIndex++
+
+	if p.isLocal {
+		return operations, nil
+	}
+
+	dest, err := p.encryptionKeyFilePath()
+	if err != nil {
+		return nil, err
+	}
+	hosts := p.topology.GetAllHosts()
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		operations = append(operations, plan.PlannedOperation{
+			ID:          plan.NewOperationID("prepare", *opIndex),
+			Type:        plan.OpDistributeKeyFile,
+			Description: fmt.Sprintf("Distribute encryption key to %s:%s", host, dest),
+			Target: plan.OperationTarget{
+				Type: "host",
+				Name: host,
+				Host: host,
+			},
+			Params: map[string]interface{}{
+				"source": keyFile,
+				"dest":   dest,
+			},
+			Changes: []plan.Change{
+				{ResourceType: "file", ResourceID: fmt.Sprintf("%s:%s", host, dest), Action: plan.ActionCreate},
+			},
+			Parallel: true,
+		})
+		*opIndex++
+	}
+	return operations, nil
+}
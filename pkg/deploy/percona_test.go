@@ -0,0 +1,71 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/template"
+	"github.com/zph/mup/pkg/topology"
+)
+
+func TestPlanner_Percona(t *testing.T) {
+	p := newAuthPlanner(&topology.Topology{
+		Global: topology.GlobalConfig{User: "mongo", DeployDir: "/opt/mongodb"},
+		Percona: &topology.PerconaConfig{
+			Encryption: &topology.EncryptionConfig{KeyFile: "/keys/cluster.key"},
+			AuditLog:   &topology.AuditLogConfig{Filter: `{ atype: "authenticate" }`},
+		},
+		Mongod: []topology.MongodNode{
+			{Host: "db1", Port: 27018, ReplicaSet: "rs0"},
+			{Host: "db2", Port: 27018, ReplicaSet: "rs0"},
+		},
+	})
+	p.version = "7.0.5-4"
+	p.variant = VariantPercona
+	require.NoError(t, p.validatePercona())
+	keyFile := "/opt/mongodb/secrets/encryption.key"
+
+	prepare, err := p.generatePreparePhase()
+	require.NoError(t, err)
+	generate := opsOfType(prepare, plan.OpGenerateEncryptionKey)
+	require.Len(t, generate, 1)
+	assert.Equal(t, p.layout.EncryptionKeyFile(), generate[0].Params["key_file"])
+	assert.Equal(t, "/keys/cluster.key", generate[0].Params["source_key_file"])
+	distribute := opsOfType(prepare, plan.OpDistributeKeyFile)
+	require.Len(t, distribute, 2, "one per host")
+	assert.Equal(t, keyFile, distribute[0].Params["dest"])
+
+	deployPhase, err := p.generateDeployPhase(context.Background())
+	require.NoError(t, err)
+	for _, op := range opsOfType(deployPhase, plan.OpGenerateConfig) {
+		opts, ok := op.Params["percona"].(*template.PerconaOptions)
+		require.True(t, ok, op.Target.Name)
+		assert.Equal(t, keyFile, opts.EncryptionKeyFile)
+		assert.Equal(t, topology.CipherModeCBC, opts.EncryptionCipherMode)
+		assert.Equal(t, "file", opts.AuditLog.Destination)
+		assert.Equal(t, op.Params["log_dir"].(string)+"/auditLog.json", opts.AuditLog.Path)
+		assert.Contains(t, op.Changes[0].After, "encryptionKeyFile: "+keyFile, op.Target.Name)
+		assert.Contains(t, op.Changes[0].After, "auditLog:", op.Target.Name)
+	}
+}
+
+func TestPlanner_PerconaVariant(t *testing.T) {
+	p := newAuthPlanner(&topology.Topology{
+		Percona: &topology.PerconaConfig{AuditLog: &topology.AuditLogConfig{Destination: "syslog"}},
+		Mongod:  []topology.MongodNode{{Host: "localhost", Port: 30000}},
+	})
+	assert.EqualError(t, p.validatePercona(), "the percona section requires variant percona, not mongo")
+
+	opts, err := p.perconaOptions("/logs")
+	require.NoError(t, err)
+	assert.Equal(t, &template.PerconaOptions{AuditLog: &template.AuditLogConfig{Destination: "syslog"}}, opts, "syslog takes no format or path")
+
+	p.topology.Percona = nil
+	require.NoError(t, p.validatePercona())
+	opts, err = p.perconaOptions("/logs")
+	require.NoError(t, err)
+	assert.Nil(t, opts)
+}
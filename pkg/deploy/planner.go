@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -11,7 +12,9 @@ import (
 	"github.com/zph/mup/pkg/naming"
 	"github.com/zph/mup/pkg/paths"
 	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/ports"
 	"github.com/zph/mup/pkg/supervisor"
+	"github.com/zph/mup/pkg/template"
 	"github.com/zph/mup/pkg/topology"
 )
 
@@ -29,6 +32,7 @@ type DeployPlanner struct {
 	dryRun       bool
 	layout       *paths.ClusterLayout     // REQ-PM-009 to REQ-PM-015
 	pathResolver *paths.LocalPathResolver // REQ-PM-001 to REQ-PM-003
+	ports        *ports.Registry          // Ports reserved by other clusters
 }
 
 // NewDeployPlanner creates a new deploy planner
@@ -40,6 +44,14 @@ func NewDeployPlanner(config *PlannerConfig) (*DeployPlanner, error) {
 	// REQ-PM-002: Initialize local path resolver
 	pathResolver := paths.NewLocalPathResolver(config.MetaDir, config.Version)
 
+	registry := config.Ports
+	if registry == nil {
+		var err error
+		if registry, err = ports.LoadDefault(); err != nil {
+			return nil, err
+		}
+	}
+
 	return &DeployPlanner{
 		clusterName:  config.ClusterName,
 		version:      config.Version,
@@ -52,6 +64,7 @@ func NewDeployPlanner(config *PlannerConfig) (*DeployPlanner, error) {
 		dryRun:       config.DryRun,
 		layout:       layout,
 		pathResolver: pathResolver,
+		ports:        registry,
 	}, nil
 }
 
@@ -66,6 +79,7 @@ type PlannerConfig struct {
 	IsLocal     bool
 	BinPath     string
 	DryRun      bool
+	Ports       *ports.Registry // Port registry; nil loads the default one
 }
 
 // GeneratePlan generates a deployment plan
@@ -98,12 +112,50 @@ func (p *DeployPlanner) GeneratePlan(ctx context.Context) (*plan.Plan, error) {
 		DryRun:      p.dryRun,
 	}
 
+	if err := p.validatePercona(); err != nil {
+		return nil, fmt.Errorf("invalid topology: %w", err)
+	}
+
 	// Generate phases
-	phases := []plan.PlannedPhase{
-		p.generatePreparePhase(),
-		p.generateDeployPhase(),
-		p.generateInitializePhase(),
+	deployPhase, err := p.generateDeployPhase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.topology.ValidateReplicaSetMembers(); err != nil {
+		return nil, fmt.Errorf("invalid topology: %w", err)
+	}
+	if err := ValidateArbiters(p.topology, p.version); err != nil {
+		return nil, fmt.Errorf("invalid topology: %w", err)
+	}
+	if err := p.topology.ValidateReplicaSetSpecs(); err != nil {
+		return nil, fmt.Errorf("invalid topology: %w", err)
+	}
+	initializePhase, err := p.generateInitializePhase()
+	if err != nil {
+		return nil, err
+	}
+
+	prepareOSPhase, err := p.generatePrepareOSPhase()
+	if err != nil {
+		return nil, err
+	}
+	preparePhase, err := p.generatePreparePhase()
+	if err != nil {
+		return nil, err
+	}
+
+	var phases []plan.PlannedPhase
+	if prepareOSPhase != nil {
+		phases = append(phases, *prepareOSPhase)
+	}
+	phases = append(phases,
+		preparePhase,
+		deployPhase,
+		initializePhase,
 		p.generateFinalizePhase(),
+	)
+	for i := range phases {
+		phases[i].Order = i + 1
 	}
 	deployPlan.Phases = phases
 
@@ -196,14 +248,168 @@ func (p *DeployPlanner) Validate(ctx context.Context) (plan.ValidationResult, er
 		}
 	}
 
+	// Ports reserved by other clusters stay taken while those clusters are
+	// stopped, when probing the port finds it free
+	type nodePort struct {
+		host string
+		port int
+	}
+	var nodePorts []nodePort
+	for _, node := range p.topology.ConfigSvr {
+		nodePorts = append(nodePorts, nodePort{node.Host, node.Port})
+	}
+	for _, node := range p.topology.Mongod {
+		nodePorts = append(nodePorts, nodePort{node.Host, node.Port})
+	}
+	for _, node := range p.topology.Mongos {
+		nodePorts = append(nodePorts, nodePort{node.Host, node.Port})
+	}
+	for _, np := range nodePorts {
+		if np.port == 0 {
+			continue
+		}
+		name := fmt.Sprintf("port_reservation_%d", np.port)
+		if owner, ok := p.ports.Owner(np.host, np.port, p.clusterName); ok {
+			runner.AddFunc(func(ctx context.Context) plan.CheckResult {
+				return plan.NewCheckFailure(name, fmt.Sprintf("port %d on %s is reserved by cluster %s (%s)", np.port, np.host, owner.Cluster, owner.Service)).WithHost(np.host)
+			})
+		}
+	}
+
+	// The supervisord port is derived from the cluster directory, so a clash
+	// can only be resolved by another cluster name
+	supervisorPort := supervisor.GetSupervisorHTTPPortForDir(p.layout.VersionDir(p.version))
+	if owner, ok := p.ports.Owner("localhost", supervisorPort, p.clusterName); ok {
+		runner.AddFunc(func(ctx context.Context) plan.CheckResult {
+			return plan.NewCheckWarning("supervisor_port", fmt.Sprintf("supervisord port %d is reserved by cluster %s (%s)", supervisorPort, owner.Cluster, owner.Service))
+		})
+	}
+
 	// Run all validations
 	result := runner.Run(ctx)
 
 	return result, nil
 }
 
+// generatePrepareOSPhase plans host OS preparation from the topology's
+// os_config. It returns nil when os_config is not set.
+func (p *DeployPlanner) generatePrepareOSPhase() (*plan.PlannedPhase, error) {
+	settings, err := ParseOSConfig(p.topology.Global.OSConfig)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		return nil, nil
+	}
+
+	hosts := p.topology.GetAllHosts()
+	sort.Strings(hosts)
+
+	operations := make([]plan.PlannedOperation, 0)
+	opIndex := 0
+	add := func(host string, opType plan.OperationType, description, resourceType, resourceID string, params map[string]interface{}, after interface{}) {
+		// Checks change nothing
+		action := plan.ActionUpdate
+		if opType == plan.OpCheckFilesystem {
+			action = plan.ActionNone
+		}
+		operations = append(operations, plan.PlannedOperation{
+			ID:          plan.NewOperationID("prepare_os", opIndex),
+			Type:        opType,
+			Description: fmt.Sprintf("%s on %s", description, host),
+			Target: plan.OperationTarget{
+				Type: "host",
+				Name: host,
+				Host: host,
+			},
+			Params: params,
+			Changes: []plan.Change{
+				{
+					ResourceType: resourceType,
+					ResourceID:   fmt.Sprintf("%s:%s", host, resourceID),
+					Action:       action,
+					After:        after,
+				},
+			},
+			Parallel: true,
+		})
+		opIndex++
+	}
+
+	for _, host := range hosts {
+		if settings.Group != "" {
+			add(host, plan.OpEnsureGroup, fmt.Sprintf("Ensure group %s exists", settings.Group),
+				"group", settings.Group, map[string]interface{}{"group": settings.Group}, "present")
+		}
+		if settings.User != "" {
+			add(host, plan.OpEnsureUser, fmt.Sprintf("Ensure user %s exists", settings.User),
+				"user", settings.User, map[string]interface{}{"user": settings.User, "group": settings.Group}, "present")
+		}
+		if settings.Nofile > 0 || settings.Nproc > 0 {
+			var limits []string
+			if settings.Nofile > 0 {
+				limits = append(limits, fmt.Sprintf("nofile=%d", settings.Nofile))
+			}
+			if settings.Nproc > 0 {
+				limits = append(limits, fmt.Sprintf("nproc=%d", settings.Nproc))
+			}
+			add(host, plan.OpSetUlimit, fmt.Sprintf("Set ulimits %s for %s", strings.Join(limits, " "), settings.User),
+				"ulimit", settings.User, map[string]interface{}{
+					"user":   settings.User,
+					"nofile": settings.Nofile,
+					"nproc":  settings.Nproc,
+				}, strings.Join(limits, " "))
+		}
+		if settings.TransparentHugepages != "" {
+			add(host, plan.OpSetTHP, fmt.Sprintf("Set transparent huge pages to %s", settings.TransparentHugepages),
+				"transparent_hugepages", "enabled", map[string]interface{}{"value": settings.TransparentHugepages}, settings.TransparentHugepages)
+		}
+		for _, sysctl := range settings.Sysctls {
+			add(host, plan.OpSetSysctl, fmt.Sprintf("Set %s = %s", sysctl.Key, sysctl.Value),
+				"sysctl", sysctl.Key, map[string]interface{}{"key": sysctl.Key, "value": sysctl.Value}, sysctl.Value)
+		}
+		if len(settings.Filesystems) > 0 {
+			for _, dir := range p.hostDataDirs(host) {
+				add(host, plan.OpCheckFilesystem, fmt.Sprintf("Check %s is on %s", dir, strings.Join(settings.Filesystems, " or ")),
+					"filesystem", dir, map[string]interface{}{"path": dir, "filesystems": settings.Filesystems}, strings.Join(settings.Filesystems, " or "))
+			}
+		}
+	}
+
+	return &plan.PlannedPhase{
+		Name:              "prepare_os",
+		Description:       "Prepare host OS: service user, ulimits, kernel settings and filesystem checks",
+		Operations:        operations,
+		EstimatedDuration: "30 seconds",
+	}, nil
+}
+
+// hostDataDirs returns the distinct data directories of the mongod and
+// config server nodes on host
+func (p *DeployPlanner) hostDataDirs(host string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	addDir := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, cs := range p.topology.ConfigSvr {
+		if cs.Host == host {
+			addDir(p.getNodeDataDir(cs.Host, cs.Port, cs.DataDir))
+		}
+	}
+	for _, node := range p.topology.Mongod {
+		if node.Host == host {
+			addDir(p.getNodeDataDir(node.Host, node.Port, node.DataDir))
+		}
+	}
+	return dirs
+}
+
 // generatePreparePhase generates the prepare phase operations
-func (p *DeployPlanner) generatePreparePhase() plan.PlannedPhase {
+func (p *DeployPlanner) generatePreparePhase() (plan.PlannedPhase, error) {
 	operations := make([]plan.PlannedOperation, 0)
 	opIndex := 0
 
@@ -233,6 +439,41 @@ func (p *DeployPlanner) generatePreparePhase() plan.PlannedPhase {
 	})
 	opIndex++
 
+	// Remote deployments: ship the binaries to every host. Each platform's
+	// tarball is built once and the uploads run in parallel (bounded by the
+	// handler), skipping hosts that already hold a matching checksum.
+	if !p.isLocal {
+		distributeOps, err := p.generateDistributeOperations(&opIndex)
+		if err != nil {
+			return plan.PlannedPhase{}, err
+		}
+		operations = append(operations, distributeOps...)
+	}
+
+	// Access control: the keyFile and admin password are created on this
+	// machine and the keyFile shipped to every host
+	keyFileOps, err := p.generateKeyFileOperations(&opIndex)
+	if err != nil {
+		return plan.PlannedPhase{}, err
+	}
+	operations = append(operations, keyFileOps...)
+
+	// Data-at-rest encryption: the key is created on this machine and
+	// shipped to every host like the keyFile
+	encryptionKeyOps, err := p.generateEncryptionKeyOperations(&opIndex)
+	if err != nil {
+		return plan.PlannedPhase{}, err
+	}
+	operations = append(operations, encryptionKeyOps...)
+
+	// TLS: the CA and node certificates are issued on this machine and
+	// each host gets the certificates of its nodes
+	certificateOps, err := p.generateCertificateOperations(&opIndex)
+	if err != nil {
+		return plan.PlannedPhase{}, err
+	}
+	operations = append(operations, certificateOps...)
+
 	// REQ-PM-010: Create version-specific directory structure
 	// REQ-PM-011: Data directories are version-independent
 	dirsToCreate := []string{
@@ -342,20 +583,169 @@ func (p *DeployPlanner) generatePreparePhase() plan.PlannedPhase {
 		Order:             1,
 		Operations:        operations,
 		EstimatedDuration: "2 minutes",
+	}, nil
+}
+
+// generateDistributeOperations plans one binary distribution per remote host
+func (p *DeployPlanner) generateDistributeOperations(opIndex *int) ([]plan.PlannedOperation, error) {
+	destDir, err := paths.NewRemotePathResolver(&p.topology.Global).VersionBinDir(p.version)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := p.topology.GetAllHosts()
+	sort.Strings(hosts)
+
+	operations := make([]plan.PlannedOperation, 0, len(hosts))
+	for _, host := range hosts {
+		operations = append(operations, plan.PlannedOperation{
+			ID:          plan.NewOperationID("prepare", *opIndex),
+			Type:        plan.OpDistributeBinary,
+			Description: fmt.Sprintf("Distribute MongoDB %s (%s) binaries to %s:%s", p.version, p.variant.String(), host, destDir),
+			Target: plan.OperationTarget{
+				Type: "host",
+				Name: host,
+				Host: host,
+				Params: map[string]string{
+					"version": p.version,
+					"variant": p.variant.String(),
+				},
+			},
+			Params: map[string]interface{}{
+				"dest_dir":    destDir,
+				"concurrency": DefaultDistributionConcurrency,
+			},
+			Changes: []plan.Change{
+				{
+					ResourceType: "directory",
+					ResourceID:   fmt.Sprintf("%s:%s", host, destDir),
+					Action:       plan.ActionCreate,
+				},
+			},
+			Parallel: true,
+		})
+		*opIndex++
+	}
+
+	return operations, nil
+}
+
+// detectCgroupModes probes how each host can enforce resource limits.
+// Remote hosts without an executor cannot be probed and get none; local
+// hosts without one are left to detection on this machine.
+func (p *DeployPlanner) detectCgroupModes(ctx context.Context) map[string]supervisor.CgroupMode {
+	modes := make(map[string]supervisor.CgroupMode)
+	for _, host := range p.topology.GetAllHosts() {
+		if exec, ok := p.executors[host]; ok {
+			modes[host] = supervisor.DetectCgroupModeContext(ctx, exec)
+		} else if !p.isLocal {
+			modes[host] = supervisor.CgroupNone
+		}
+	}
+	return modes
+}
+
+// generateCgexecOperations points remote program commands at the hosts' bin
+// directory and plans an upload of mup-cgexec to every cgroupfs host. The
+// operation IDs are assigned by the caller.
+func (p *DeployPlanner) generateCgexecOperations(versionDir string, modes map[string]supervisor.CgroupMode, supervisorParams map[string]interface{}) ([]plan.PlannedOperation, error) {
+	binDir, err := paths.NewRemotePathResolver(&p.topology.Global).BinDir()
+	if err != nil {
+		return nil, err
+	}
+	supervisorParams["cgexec_dir"] = binDir
+
+	localPath := filepath.Join(versionDir, "bin", supervisor.CgexecScriptName)
+	remotePath := filepath.Join(binDir, supervisor.CgexecScriptName)
+
+	hosts := make([]string, 0, len(modes))
+	for host, mode := range modes {
+		if mode == supervisor.CgroupFS {
+			hosts = append(hosts, host)
+		}
 	}
+	sort.Strings(hosts)
+
+	operations := make([]plan.PlannedOperation, 0, len(hosts))
+	for _, host := range hosts {
+		operations = append(operations, plan.PlannedOperation{
+			Type:        plan.OpUploadFile,
+			Description: fmt.Sprintf("Upload %s to %s:%s", supervisor.CgexecScriptName, host, remotePath),
+			Target: plan.OperationTarget{
+				Type: "host",
+				Name: host,
+				Host: host,
+			},
+			Params: map[string]interface{}{
+				"local_path":  localPath,
+				"remote_path": remotePath,
+				"mode":        "0755",
+			},
+			Changes: []plan.Change{
+				{
+					ResourceType: "file",
+					ResourceID:   fmt.Sprintf("%s:%s", host, remotePath),
+					Action:       plan.ActionCreate,
+				},
+			},
+			Parallel: true,
+		})
+	}
+
+	return operations, nil
 }
 
 // generateDeployPhase generates the deploy phase operations
-func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
+// Configs are rendered here so runtime_config errors surface at plan time
+// and each Change records the exact file that will be written.
+func (p *DeployPlanner) generateDeployPhase(ctx context.Context) (plan.PlannedPhase, error) {
 	operations := make([]plan.PlannedOperation, 0)
 	opIndex := 0
 
+	tmplMgr, err := template.NewManager()
+	if err != nil {
+		return plan.PlannedPhase{}, fmt.Errorf("failed to create template manager: %w", err)
+	}
+
+	// With access control every node reads the cluster's keyFile
+	keyFile := ""
+	if p.topology.AuthEnabled() {
+		if keyFile, err = p.keyFilePath(); err != nil {
+			return plan.PlannedPhase{}, err
+		}
+	}
+
 	// Generate config files for each config server
 	for _, cs := range p.topology.ConfigSvr {
 		// REQ-PM-010: Config files in version-specific paths
 		configPath, _ := p.pathResolver.ConfigFile("config", cs.Host, cs.Port)
 		dataDir := p.getNodeDataDir(cs.Host, cs.Port, cs.DataDir)
 		logDir := p.getNodeLogDirWithType(cs.Host, cs.Port, cs.LogDir, "config")
+		tlsOpts, err := p.tlsOptions(cs.Host, cs.Port)
+		if err != nil {
+			return plan.PlannedPhase{}, err
+		}
+		perconaOpts, err := p.perconaOptions(logDir)
+		if err != nil {
+			return plan.PlannedPhase{}, err
+		}
+
+		content, err := tmplMgr.RenderMongod(p.version, template.MongodOptions{
+			Role:          "configsvr",
+			ReplicaSet:    cs.ReplicaSet,
+			Port:          cs.Port,
+			BindIP:        cs.BindAddresses(p.isLocal),
+			DataDir:       dataDir,
+			LogDir:        logDir,
+			KeyFile:       keyFile,
+			TLS:           tlsOpts,
+			Variant:       p.variant.String(),
+			Percona:       perconaOpts,
+			RuntimeConfig: cs.RuntimeConfig,
+		})
+		if err != nil {
+			return plan.PlannedPhase{}, fmt.Errorf("config server %s:%d: %w", cs.Host, cs.Port, err)
+		}
 
 		operations = append(operations, plan.PlannedOperation{
 			ID:          plan.NewOperationID("deploy", opIndex),
@@ -363,26 +753,31 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			Description: fmt.Sprintf("Generate config server configuration: %s (replica set: %s, port: %d)", configPath, cs.ReplicaSet, cs.Port),
 			Target: plan.OperationTarget{
 				Type: "config",
-				Name: fmt.Sprintf("config-%s-%d", cs.Host, cs.Port),
+				Name: naming.GetNodeName("config", cs.Host, cs.Port),
 				Host: cs.Host,
 				Port: cs.Port,
 			},
 			Params: map[string]interface{}{
-				"config_path": configPath,
-				"replica_set": cs.ReplicaSet,
-				"role":        "configsvr",
-				"version":     p.version,
-				"variant":     p.variant.String(),
-				"port":        cs.Port,
-				"data_dir":    dataDir,
-				"log_dir":     logDir,
-				"bind_ip":     "127.0.0.1,::1", // Bind to both IPv4 and IPv6
+				"config_path":    configPath,
+				"replica_set":    cs.ReplicaSet,
+				"role":           "configsvr",
+				"version":        p.version,
+				"variant":        p.variant.String(),
+				"port":           cs.Port,
+				"data_dir":       dataDir,
+				"log_dir":        logDir,
+				"bind_ip":        cs.BindAddresses(p.isLocal),
+				"key_file":       keyFile,
+				"tls":            tlsOpts,
+				"percona":        perconaOpts,
+				"runtime_config": cs.RuntimeConfig,
 			},
 			Changes: []plan.Change{
 				{
 					ResourceType: "file",
 					ResourceID:   configPath,
 					Action:       plan.ActionCreate,
+					After:        string(content),
 				},
 			},
 			Parallel: true,
@@ -409,32 +804,63 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			role = "shardsvr"
 		}
 
+		tlsOpts, err := p.tlsOptions(node.Host, node.Port)
+		if err != nil {
+			return plan.PlannedPhase{}, err
+		}
+		perconaOpts, err := p.perconaOptions(logDir)
+		if err != nil {
+			return plan.PlannedPhase{}, err
+		}
+
+		content, err := tmplMgr.RenderMongod(p.version, template.MongodOptions{
+			Role:          role,
+			ReplicaSet:    node.ReplicaSet,
+			Port:          node.Port,
+			BindIP:        node.BindAddresses(p.isLocal),
+			DataDir:       dataDir,
+			LogDir:        logDir,
+			KeyFile:       keyFile,
+			TLS:           tlsOpts,
+			Variant:       p.variant.String(),
+			Percona:       perconaOpts,
+			RuntimeConfig: node.RuntimeConfig,
+		})
+		if err != nil {
+			return plan.PlannedPhase{}, fmt.Errorf("mongod %s:%d: %w", node.Host, node.Port, err)
+		}
+
 		operations = append(operations, plan.PlannedOperation{
 			ID:          plan.NewOperationID("deploy", opIndex),
 			Type:        plan.OpGenerateConfig,
 			Description: fmt.Sprintf("Generate mongod configuration: %s (host: %s, port: %d%s)", configPath, node.Host, node.Port, rsInfo),
 			Target: plan.OperationTarget{
 				Type: "mongod",
-				Name: fmt.Sprintf("mongod-%s-%d", node.Host, node.Port),
+				Name: naming.GetNodeName("mongod", node.Host, node.Port),
 				Host: node.Host,
 				Port: node.Port,
 			},
 			Params: map[string]interface{}{
-				"config_path": configPath,
-				"replica_set": node.ReplicaSet,
-				"role":        role,
-				"version":     p.version,
-				"variant":     p.variant.String(),
-				"port":        node.Port,
-				"data_dir":    dataDir,
-				"log_dir":     logDir,
-				"bind_ip":     "127.0.0.1,::1", // Bind to both IPv4 and IPv6
+				"config_path":    configPath,
+				"replica_set":    node.ReplicaSet,
+				"role":           role,
+				"version":        p.version,
+				"variant":        p.variant.String(),
+				"port":           node.Port,
+				"data_dir":       dataDir,
+				"log_dir":        logDir,
+				"bind_ip":        node.BindAddresses(p.isLocal),
+				"key_file":       keyFile,
+				"tls":            tlsOpts,
+				"percona":        perconaOpts,
+				"runtime_config": node.RuntimeConfig,
 			},
 			Changes: []plan.Change{
 				{
 					ResourceType: "file",
 					ResourceID:   configPath,
 					Action:       plan.ActionCreate,
+					After:        string(content),
 				},
 			},
 			Parallel: true,
@@ -448,6 +874,29 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 		configPath, _ := p.pathResolver.ConfigFile("mongos", mongos.Host, mongos.Port)
 		logDir := p.getNodeLogDirWithType(mongos.Host, mongos.Port, mongos.LogDir, "mongos")
 		configDB := p.getConfigServerConnectionString()
+		tlsOpts, err := p.tlsOptions(mongos.Host, mongos.Port)
+		if err != nil {
+			return plan.PlannedPhase{}, err
+		}
+		perconaOpts, err := p.perconaOptions(logDir)
+		if err != nil {
+			return plan.PlannedPhase{}, err
+		}
+
+		content, err := tmplMgr.RenderMongos(p.version, template.MongosOptions{
+			Port:          mongos.Port,
+			BindIP:        mongos.BindAddresses(p.isLocal),
+			LogDir:        logDir,
+			ConfigDB:      configDB,
+			KeyFile:       keyFile,
+			TLS:           tlsOpts,
+			Variant:       p.variant.String(),
+			Percona:       perconaOpts,
+			RuntimeConfig: mongos.RuntimeConfig,
+		})
+		if err != nil {
+			return plan.PlannedPhase{}, fmt.Errorf("mongos %s:%d: %w", mongos.Host, mongos.Port, err)
+		}
 
 		operations = append(operations, plan.PlannedOperation{
 			ID:          plan.NewOperationID("deploy", opIndex),
@@ -455,25 +904,30 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			Description: fmt.Sprintf("Generate mongos router configuration: %s (host: %s, port: %d)", configPath, mongos.Host, mongos.Port),
 			Target: plan.OperationTarget{
 				Type: "mongos",
-				Name: fmt.Sprintf("mongos-%s-%d", mongos.Host, mongos.Port),
+				Name: naming.GetNodeName("mongos", mongos.Host, mongos.Port),
 				Host: mongos.Host,
 				Port: mongos.Port,
 			},
 			Params: map[string]interface{}{
-				"config_path": configPath,
-				"role":        "mongos",
-				"version":     p.version,
-				"variant":     p.variant.String(),
-				"port":        mongos.Port,
-				"log_dir":     logDir,
-				"bind_ip":     "127.0.0.1,::1", // Bind to both IPv4 and IPv6
-				"config_db":   configDB,
+				"config_path":    configPath,
+				"role":           "mongos",
+				"version":        p.version,
+				"variant":        p.variant.String(),
+				"port":           mongos.Port,
+				"log_dir":        logDir,
+				"bind_ip":        mongos.BindAddresses(p.isLocal),
+				"config_db":      configDB,
+				"key_file":       keyFile,
+				"tls":            tlsOpts,
+				"percona":        perconaOpts,
+				"runtime_config": mongos.RuntimeConfig,
 			},
 			Changes: []plan.Change{
 				{
 					ResourceType: "file",
 					ResourceID:   configPath,
 					Action:       plan.ActionCreate,
+					After:        string(content),
 				},
 			},
 			Parallel: true,
@@ -481,9 +935,38 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 		opIndex++
 	}
 
+	// resource_control limits are applied by the supervisor program commands
+	// and checked after each process starts
+	limits, err := supervisor.ParseResourceLimits(p.topology.Global.ResourceControl, p.topology.Global.SystemdConfig)
+	if err != nil {
+		return plan.PlannedPhase{}, err
+	}
+
 	// Generate supervisor configuration
 	// REQ-PM-010: Create supervisor.ini in version directory
 	versionDir := p.layout.VersionDir(p.version)
+	supervisorParams := map[string]interface{}{
+		"cluster_dir":  versionDir,
+		"cluster_name": p.clusterName,
+		"version":      p.version,
+		"bin_path":     p.binPath,
+		"topology":     p.topology,
+	}
+
+	// Each host enforces limits the way its own cgroup setup allows, and
+	// cgroupfs hosts run the mup-cgexec wrapper from their bin directory
+	var cgexecOps []plan.PlannedOperation
+	if limits != nil {
+		modes := p.detectCgroupModes(ctx)
+		supervisorParams["cgroup_modes"] = modes
+		if !p.isLocal {
+			cgexecOps, err = p.generateCgexecOperations(versionDir, modes, supervisorParams)
+			if err != nil {
+				return plan.PlannedPhase{}, err
+			}
+		}
+	}
+
 	operations = append(operations, plan.PlannedOperation{
 		ID:          plan.NewOperationID("deploy", opIndex),
 		Type:        plan.OpGenerateSupervisorCfg,
@@ -492,13 +975,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			Type: "config",
 			Name: "supervisor",
 		},
-		Params: map[string]interface{}{
-			"cluster_dir":  versionDir,
-			"cluster_name": p.clusterName,
-			"version":      p.version,
-			"bin_path":     p.binPath,
-			"topology":     p.topology,
-		},
+		Params: supervisorParams,
 		Changes: []plan.Change{
 			{
 				ResourceType: "file",
@@ -510,9 +987,16 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 	})
 	opIndex++
 
+	for _, op := range cgexecOps {
+		op.ID = plan.NewOperationID("deploy", opIndex)
+		operations = append(operations, op)
+		opIndex++
+	}
+
 	// Start supervisord daemon
 	supervisorConfigPath := filepath.Join(versionDir, "supervisor.ini")
 	supervisorPort := supervisor.GetSupervisorHTTPPortForDir(versionDir)
+
 	operations = append(operations, plan.PlannedOperation{
 		ID:          plan.NewOperationID("deploy", opIndex),
 		Type:        plan.OpStartSupervisor,
@@ -546,7 +1030,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			Description: fmt.Sprintf("Start config server via supervisorctl: %s (replica set: %s)", programName, cs.ReplicaSet),
 			Target: plan.OperationTarget{
 				Type: "config",
-				Name: fmt.Sprintf("config-%s-%d", cs.Host, cs.Port),
+				Name: naming.GetNodeName("config", cs.Host, cs.Port),
 				Host: cs.Host,
 				Port: cs.Port,
 			},
@@ -554,6 +1038,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 				"program_name":      programName,
 				"supervisor_config": supervisorConfigPath,
 				"supervisor_port":   supervisorPort,
+				"resource_limits":   limits,
 			},
 			PreConditions: []plan.SafetyCheck{
 				{
@@ -580,7 +1065,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			Changes: []plan.Change{
 				{
 					ResourceType: "process",
-					ResourceID:   fmt.Sprintf("config-%s-%d", cs.Host, cs.Port),
+					ResourceID:   naming.GetNodeName("config", cs.Host, cs.Port),
 					Action:       plan.ActionStart,
 				},
 			},
@@ -603,7 +1088,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			Description: fmt.Sprintf("Start mongod via supervisorctl: %s%s", programName, rsInfo),
 			Target: plan.OperationTarget{
 				Type: "mongod",
-				Name: fmt.Sprintf("mongod-%s-%d", node.Host, node.Port),
+				Name: naming.GetNodeName("mongod", node.Host, node.Port),
 				Host: node.Host,
 				Port: node.Port,
 			},
@@ -611,6 +1096,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 				"program_name":      programName,
 				"supervisor_config": supervisorConfigPath,
 				"supervisor_port":   supervisorPort,
+				"resource_limits":   limits,
 			},
 			PreConditions: []plan.SafetyCheck{
 				{
@@ -637,7 +1123,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			Changes: []plan.Change{
 				{
 					ResourceType: "process",
-					ResourceID:   fmt.Sprintf("mongod-%s-%d", node.Host, node.Port),
+					ResourceID:   naming.GetNodeName("mongod", node.Host, node.Port),
 					Action:       plan.ActionStart,
 				},
 			},
@@ -656,7 +1142,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			Description: fmt.Sprintf("Start mongos router via supervisorctl: %s", programName),
 			Target: plan.OperationTarget{
 				Type: "mongos",
-				Name: fmt.Sprintf("mongos-%s-%d", mongos.Host, mongos.Port),
+				Name: naming.GetNodeName("mongos", mongos.Host, mongos.Port),
 				Host: mongos.Host,
 				Port: mongos.Port,
 			},
@@ -664,6 +1150,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 				"program_name":      programName,
 				"supervisor_config": supervisorConfigPath,
 				"supervisor_port":   supervisorPort,
+				"resource_limits":   limits,
 			},
 			PreConditions: []plan.SafetyCheck{
 				{
@@ -690,7 +1177,7 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 			Changes: []plan.Change{
 				{
 					ResourceType: "process",
-					ResourceID:   fmt.Sprintf("mongos-%s-%d", mongos.Host, mongos.Port),
+					ResourceID:   naming.GetNodeName("mongos", mongos.Host, mongos.Port),
 					Action:       plan.ActionStart,
 				},
 			},
@@ -705,48 +1192,90 @@ func (p *DeployPlanner) generateDeployPhase() plan.PlannedPhase {
 		Order:             2,
 		Operations:        operations,
 		EstimatedDuration: "1 minute",
-	}
+	}, nil
 }
 
 // generateInitializePhase generates the initialize phase operations
-func (p *DeployPlanner) generateInitializePhase() plan.PlannedPhase {
+func (p *DeployPlanner) generateInitializePhase() (plan.PlannedPhase, error) {
 	operations := make([]plan.PlannedOperation, 0)
 	opIndex := 0
 
 	// Track replica sets to initialize
-	replicaSets := make(map[string][]string) // rs name -> members
+	replicaSets := make(map[string][]ReplicaSetMember) // rs name -> members
 
 	// Collect config server replica set
 	if len(p.topology.ConfigSvr) > 0 {
 		rsName := p.topology.ConfigSvr[0].ReplicaSet
-		for _, cs := range p.topology.ConfigSvr {
-			member := fmt.Sprintf("%s:%d", normalizeHost(cs.Host), cs.Port)
-			replicaSets[rsName] = append(replicaSets[rsName], member)
+		for i := range p.topology.ConfigSvr {
+			replicaSets[rsName] = append(replicaSets[rsName], newConfigServerMember(&p.topology.ConfigSvr[i]))
 		}
 	}
 
 	// Collect shard replica sets
-	for _, node := range p.topology.Mongod {
+	for i := range p.topology.Mongod {
+		node := &p.topology.Mongod[i]
 		if node.ReplicaSet != "" {
-			member := fmt.Sprintf("%s:%d", normalizeHost(node.Host), node.Port)
-			replicaSets[node.ReplicaSet] = append(replicaSets[node.ReplicaSet], member)
+			replicaSets[node.ReplicaSet] = append(replicaSets[node.ReplicaSet], NewReplicaSetMember(node))
 		}
 	}
 
 	// Initialize each replica set
-	for rsName, members := range replicaSets {
+	for rsName, rsMembers := range replicaSets {
+		members := make([]string, 0, len(rsMembers))
+		for _, member := range rsMembers {
+			members = append(members, member.Address())
+		}
+		params := map[string]interface{}{
+			"replica_set":     rsName,
+			"members":         members,
+			"member_settings": rsMembers,
+			"version":         p.version,
+		}
+		rsOptions, err := NewReplicaSetOptions(p.topology.ReplicaSetSpecFor(rsName), p.version)
+		if err != nil {
+			return plan.PlannedPhase{}, fmt.Errorf("replica set %s: %w", rsName, err)
+		}
+		if p.topology.GetTopologyType() == "sharded" {
+			// Default read/write concerns are cluster-wide and set once
+			// through mongos after the shards are added
+			rsOptions = rsOptions.WithoutDefaultRWConcern()
+		}
+		if rsOptions != nil {
+			params["replica_set_options"] = rsOptions
+		}
+		if tls := p.tlsParams(); tls != nil {
+			params["tls"] = tls
+		}
+		description := fmt.Sprintf("Initialize replica set '%s' with members: %v", rsName, members)
+
+		// With access control the replica set is initiated and its first
+		// user created from a shell on one member's host, through the
+		// localhost exception
+		targetHost := ""
+		if p.topology.AuthEnabled() {
+			host, port, bindIP, ok := p.bootstrapNode(rsName)
+			if !ok {
+				return plan.PlannedPhase{}, fmt.Errorf("replica set %s has no member that can become primary to create the admin user on", rsName)
+			}
+			auth, err := p.bootstrapAuthParams(bindIP, port)
+			if err != nil {
+				return plan.PlannedPhase{}, fmt.Errorf("replica set %s: %w", rsName, err)
+			}
+			params["auth"] = auth
+			targetHost = host
+			description += fmt.Sprintf(" and create user %s", auth.User)
+		}
+
 		operations = append(operations, plan.PlannedOperation{
 			ID:          plan.NewOperationID("initialize", opIndex),
 			Type:        plan.OpInitReplicaSet,
-			Description: fmt.Sprintf("Initialize replica set '%s' with members: %v", rsName, members),
+			Description: description,
 			Target: plan.OperationTarget{
 				Type: "replica_set",
 				Name: rsName,
+				Host: targetHost,
 			},
-			Params: map[string]interface{}{
-				"replica_set": rsName,
-				"members":     members,
-			},
+			Params: params,
 			Changes: []plan.Change{
 				{
 					ResourceType: "replica_set",
@@ -777,23 +1306,73 @@ func (p *DeployPlanner) generateInitializePhase() plan.PlannedPhase {
 		opIndex++
 	}
 
+	// Standalone mongods have no replica set to initiate, only the first
+	// user to create
+	if p.topology.AuthEnabled() {
+		for _, node := range p.topology.Mongod {
+			if node.ReplicaSet != "" {
+				continue
+			}
+			auth, err := p.bootstrapAuthParams(node.BindAddresses(p.isLocal), node.Port)
+			if err != nil {
+				return plan.PlannedPhase{}, fmt.Errorf("mongod %s:%d: %w", node.Host, node.Port, err)
+			}
+			operations = append(operations, plan.PlannedOperation{
+				ID:          plan.NewOperationID("initialize", opIndex),
+				Type:        plan.OpCreateAdminUser,
+				Description: fmt.Sprintf("Create user %s on %s", auth.User, node.Address()),
+				Target: plan.OperationTarget{
+					Type: "mongod",
+					Name: naming.GetNodeName("mongod", node.Host, node.Port),
+					Host: node.Host,
+					Port: node.Port,
+				},
+				Params: map[string]interface{}{
+					"auth": auth,
+				},
+				Changes: []plan.Change{
+					{
+						ResourceType: "user",
+						ResourceID:   fmt.Sprintf("admin.%s", auth.User),
+						Action:       plan.ActionCreate,
+					},
+				},
+				Parallel: false,
+			})
+			opIndex++
+		}
+	}
+
 	// Add shards if sharded cluster
 	if len(p.topology.Mongos) > 0 {
 		// Group mongod nodes by replica set to create shards
+		// Arbiters hold no data and are left out of the shard seed list
 		shards := make(map[string][]string)
 		for _, node := range p.topology.Mongod {
-			if node.ReplicaSet != "" {
-				member := fmt.Sprintf("%s:%d", normalizeHost(node.Host), node.Port)
+			if node.ReplicaSet != "" && !node.ArbiterOnly {
+				member := topology.GetNodeID(normalizeHost(node.AdvertisedHost()), node.Port)
 				shards[node.ReplicaSet] = append(shards[node.ReplicaSet], member)
 			}
 		}
 
 		// Get first mongos for shard operations
-		mongosHost := fmt.Sprintf("%s:%d", normalizeHost(p.topology.Mongos[0].Host), p.topology.Mongos[0].Port)
+		mongosHost := topology.GetNodeID(normalizeHost(p.topology.Mongos[0].AdvertisedHost()), p.topology.Mongos[0].Port)
 
 		// Add each shard to the cluster
 		for rsName, members := range shards {
 			shardConnStr := fmt.Sprintf("%s/%s", rsName, members[0])
+			params := map[string]interface{}{
+				"shard_name":        rsName,
+				"connection_string": shardConnStr,
+				"mongos_host":       mongosHost,
+				"members":           members,
+			}
+			if auth := p.authParams(); auth != nil {
+				params["auth"] = auth
+			}
+			if tls := p.tlsParams(); tls != nil {
+				params["tls"] = tls
+			}
 			operations = append(operations, plan.PlannedOperation{
 				ID:          plan.NewOperationID("initialize", opIndex),
 				Type:        plan.OpAddShard,
@@ -802,12 +1381,7 @@ func (p *DeployPlanner) generateInitializePhase() plan.PlannedPhase {
 					Type: "shard",
 					Name: rsName,
 				},
-				Params: map[string]interface{}{
-					"shard_name":        rsName,
-					"connection_string": shardConnStr,
-					"mongos_host":       mongosHost,
-					"members":           members,
-				},
+				Params: params,
 				Changes: []plan.Change{
 					{
 						ResourceType: "shard",
@@ -820,6 +1394,45 @@ func (p *DeployPlanner) generateInitializePhase() plan.PlannedPhase {
 			opIndex++
 		}
 
+		// Default read/write concerns of a sharded cluster are stored on the
+		// config servers and set once through mongos
+		rwOptions, err := NewReplicaSetOptions(p.topology.DefaultRWConcernSpec(), p.version)
+		if err != nil {
+			return plan.PlannedPhase{}, err
+		}
+		if rwOptions = rwOptions.DefaultRWConcernOnly(); rwOptions != nil {
+			params := map[string]interface{}{
+				"mongos_host":         mongosHost,
+				"replica_set_options": rwOptions,
+			}
+			if auth := p.authParams(); auth != nil {
+				params["auth"] = auth
+			}
+			if tls := p.tlsParams(); tls != nil {
+				params["tls"] = tls
+			}
+			operations = append(operations, plan.PlannedOperation{
+				ID:          plan.NewOperationID("initialize", opIndex),
+				Type:        plan.OpSetDefaultRWConcern,
+				Description: fmt.Sprintf("Set cluster-wide default read/write concern through mongos %s", mongosHost),
+				Target: plan.OperationTarget{
+					Type: "cluster",
+					Name: p.clusterName,
+				},
+				Params: params,
+				Changes: []plan.Change{
+					{
+						ResourceType: "default_rw_concern",
+						ResourceID:   p.clusterName,
+						Action:       plan.ActionUpdate,
+						After:        rwOptions,
+					},
+				},
+				Parallel: false,
+			})
+			opIndex++
+		}
+
 		// Verify sharding is configured
 		operations = append(operations, plan.PlannedOperation{
 			ID:          plan.NewOperationID("initialize", opIndex),
@@ -843,7 +1456,7 @@ func (p *DeployPlanner) generateInitializePhase() plan.PlannedPhase {
 		Order:             3,
 		Operations:        operations,
 		EstimatedDuration: "3 minutes",
-	}
+	}, nil
 }
 
 // generateFinalizePhase generates the finalize phase operations
@@ -871,6 +1484,23 @@ func (p *DeployPlanner) generateFinalizePhase() plan.PlannedPhase {
 		deployMode = "remote"
 	}
 
+	params := map[string]interface{}{
+		"version":         p.version,
+		"variant":         p.variant.String(),
+		"bin_path":        p.binPath,
+		"deploy_mode":     deployMode,
+		"topology":        p.topology,
+		"supervisor_port": supervisor.GetSupervisorHTTPPortForDir(p.layout.VersionDir(p.version)),
+	}
+	if p.topology.AuthEnabled() {
+		params["key_file"] = p.layout.KeyFile()
+		params["password_file"] = p.layout.AdminPasswordFile()
+		params["credentials_file"] = p.layout.CredentialsFile()
+	}
+	if p.topology.TLSEnabled() {
+		params["ca_file"] = p.layout.CAFile()
+	}
+
 	operations = append(operations, plan.PlannedOperation{
 		ID:          plan.NewOperationID("finalize", opIndex),
 		Type:        plan.OpSaveMetadata,
@@ -879,13 +1509,7 @@ func (p *DeployPlanner) generateFinalizePhase() plan.PlannedPhase {
 			Type: "cluster",
 			Name: p.clusterName,
 		},
-		Params: map[string]interface{}{
-			"version":     p.version,
-			"variant":     p.variant.String(),
-			"bin_path":    p.binPath,
-			"deploy_mode": deployMode,
-			"topology":    p.topology,
-		},
+		Params: params,
 		Changes: []plan.Change{
 			{
 				ResourceType: "file",
@@ -1023,7 +1647,7 @@ func (p *DeployPlanner) getConfigServerConnectionString() string {
 	// Build connection string
 	members := make([]string, 0, len(p.topology.ConfigSvr))
 	for _, cs := range p.topology.ConfigSvr {
-		members = append(members, fmt.Sprintf("%s:%d", normalizeHost(cs.Host), cs.Port))
+		members = append(members, topology.GetNodeID(normalizeHost(cs.AdvertisedHost()), cs.Port))
 	}
 
 	return fmt.Sprintf("%s/%s", rsName, strings.Join(members, ","))
@@ -1035,6 +1659,6 @@ func (p *DeployPlanner) allocatePorts() error {
 		return nil
 	}
 
-	// Use the topology port allocator
-	return topology.AllocatePortsForTopology(p.topology, nil)
+	// Use the topology port allocator, skipping ports other clusters reserved
+	return topology.AllocatePortsForTopology(p.topology, p.ports.Checker("localhost", p.clusterName))
 }
@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/topology"
 )
 
 // prepare implements Phase 2: Prepare
@@ -174,13 +175,13 @@ func (d *Deployer) checkHost(host string, exec executor.Executor) []PreflightIss
 			issues = append(issues, PreflightIssue{
 				Severity: "warning",
 				Message:  fmt.Sprintf("Cannot check port %d: %v", port, err),
-				Node:     fmt.Sprintf("%s:%d", host, port),
+				Node:     topology.GetNodeID(host, port),
 			})
 		} else if !available {
 			issues = append(issues, PreflightIssue{
 				Severity: "error",
 				Message:  fmt.Sprintf("Port %d already in use", port),
-				Node:     fmt.Sprintf("%s:%d", host, port),
+				Node:     topology.GetNodeID(host, port),
 			})
 		}
 	}
@@ -270,7 +271,7 @@ func (d *Deployer) prepareBinaries(ctx context.Context) error {
 		wg.Add(1)
 		go func(p Platform) {
 			defer wg.Done()
-			binPath, err := bm.GetBinPathWithVariant(d.version, d.variant, p)
+			binPath, err := bm.GetBinPathWithVariantContext(ctx, d.version, d.variant, p)
 			if err != nil {
 				// Log error but continue with other platforms
 				fmt.Printf("  Warning: failed to get binaries for %s %s: %v\n", d.variant, p.Key(), err)
@@ -295,7 +296,7 @@ func (d *Deployer) prepareBinaries(ctx context.Context) error {
 			d.binPath = binPath
 		} else {
 			// If not found, try to fetch it
-			binPath, err := bm.GetBinPathWithVariant(d.version, d.variant, currentPlatform)
+			binPath, err := bm.GetBinPathWithVariantContext(ctx, d.version, d.variant, currentPlatform)
 			if err != nil {
 				// Check if this is Percona on macOS - not supported
 				if d.variant == VariantPercona && runtime.GOOS == "darwin" {
@@ -309,7 +310,7 @@ func (d *Deployer) prepareBinaries(ctx context.Context) error {
 						OS:   "darwin",
 						Arch: "amd64",
 					}
-					binPath, err = bm.GetBinPathWithVariant(d.version, d.variant, fallbackPlatform)
+					binPath, err = bm.GetBinPathWithVariantContext(ctx, d.version, d.variant, fallbackPlatform)
 					if err != nil {
 						return fmt.Errorf("failed to ensure %s %s for current platform (tried arm64 and x86_64 fallback): %w", d.variant.String(), d.version, err)
 					}
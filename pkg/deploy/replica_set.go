@@ -0,0 +1,315 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/go-version"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/zph/mup/pkg/topology"
+)
+
+// ReplicaSetMember represents a member of a replica set
+type ReplicaSetMember struct {
+	Host               string            `json:"host"`
+	Port               int               `json:"port"`
+	Priority           float64           `json:"priority"`
+	Hidden             bool              `json:"hidden,omitempty"`
+	Votes              int               `json:"votes"`
+	ArbiterOnly        bool              `json:"arbiter_only,omitempty"`
+	SecondaryDelaySecs int               `json:"secondary_delay_secs,omitempty"`
+	BuildIndexes       bool              `json:"build_indexes"`
+	Tags               map[string]string `json:"tags,omitempty"`
+}
+
+// NewReplicaSetMember returns the member settings for a mongod node
+func NewReplicaSetMember(node *topology.MongodNode) ReplicaSetMember {
+	return ReplicaSetMember{
+		Host:               normalizeHost(node.AdvertisedHost()),
+		Port:               node.Port,
+		Priority:           node.MemberPriority(),
+		Hidden:             node.IsHidden(),
+		Votes:              node.MemberVotes(),
+		ArbiterOnly:        node.ArbiterOnly,
+		SecondaryDelaySecs: node.SecondaryDelaySecs,
+		BuildIndexes:       node.BuildsIndexes(),
+		Tags:               node.Tags,
+	}
+}
+
+// newConfigServerMember returns the member settings for a config server,
+// which always uses MongoDB's defaults
+func newConfigServerMember(node *topology.ConfigNode) ReplicaSetMember {
+	return ReplicaSetMember{
+		Host:         normalizeHost(node.AdvertisedHost()),
+		Port:         node.Port,
+		Priority:     1.0,
+		Votes:        1,
+		BuildIndexes: true,
+	}
+}
+
+// Address returns the member's host:port, as used in the replica set config
+func (m ReplicaSetMember) Address() string {
+	return topology.GetNodeID(m.Host, m.Port)
+}
+
+// Document returns the member's replSetInitiate entry. Options are only
+// included when they differ from MongoDB's defaults. The delay is written
+// as secondaryDelaySecs on MongoDB 5.0+ and slaveDelay before that.
+func (m ReplicaSetMember) Document(id int, mongoVersion string) bson.M {
+	doc := bson.M{
+		"_id":  id,
+		"host": m.Address(),
+	}
+
+	if m.ArbiterOnly {
+		doc["arbiterOnly"] = true
+		return doc
+	}
+	if m.Priority != 1.0 {
+		doc["priority"] = m.Priority
+	}
+	if m.Hidden {
+		doc["hidden"] = true
+	}
+	if m.Votes != 1 {
+		doc["votes"] = m.Votes
+	}
+	if m.SecondaryDelaySecs > 0 {
+		doc[delayField(mongoVersion)] = m.SecondaryDelaySecs
+	}
+	if !m.BuildIndexes {
+		doc["buildIndexes"] = false
+	}
+	if len(m.Tags) > 0 {
+		doc["tags"] = m.Tags
+	}
+	return doc
+}
+
+// ValidateArbiters enforces the one-arbiter-per-replica-set limit, which
+// MongoDB applies from 5.3 unless allowMultipleArbiters is set
+func ValidateArbiters(topo *topology.Topology, mongoVersion string) error {
+	v, err := version.NewVersion(mongoVersion)
+	if err != nil || v.LessThan(version.Must(version.NewVersion("5.3"))) {
+		return nil
+	}
+
+	arbiters := topo.CountArbiters()
+	names := make([]string, 0, len(arbiters))
+	for name := range arbiters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if arbiters[name] > topology.MaxArbiters {
+			return fmt.Errorf("replica set %s has %d arbiters (max %d from MongoDB 5.3)", name, arbiters[name], topology.MaxArbiters)
+		}
+	}
+	return nil
+}
+
+// delayField returns the member delay field name for mongoVersion
+func delayField(mongoVersion string) string {
+	v, err := version.NewVersion(mongoVersion)
+	if err == nil && v.LessThan(version.Must(version.NewVersion("5.0"))) {
+		return "slaveDelay"
+	}
+	return "secondaryDelaySecs"
+}
+
+// MemberDocuments builds the replSetInitiate members array
+func MemberDocuments(members []ReplicaSetMember, mongoVersion string) []bson.M {
+	docs := make([]bson.M, len(members))
+	for i, member := range members {
+		docs[i] = member.Document(i, mongoVersion)
+	}
+	return docs
+}
+
+// SeedMember returns the first data-bearing member, which is where
+// replSetInitiate runs and what addShard uses as its seed. Arbiters hold no
+// data and cannot initiate a replica set.
+func SeedMember(members []ReplicaSetMember) (ReplicaSetMember, error) {
+	for _, member := range members {
+		if !member.ArbiterOnly {
+			return member, nil
+		}
+	}
+	return ReplicaSetMember{}, fmt.Errorf("replica set has no data-bearing members")
+}
+
+// ReplicaSetOptions are the replica-set-level settings from a topology's
+// replica_sets entry, in the form stored in plan params
+type ReplicaSetOptions struct {
+	ProtocolVersion                    int            `json:"protocol_version,omitempty"`
+	WriteConcernMajorityJournalDefault *bool          `json:"write_concern_majority_journal_default,omitempty"`
+	Settings                           map[string]any `json:"settings,omitempty"`
+	DefaultReadConcern                 string         `json:"default_read_concern,omitempty"`
+	DefaultWriteConcern                map[string]any `json:"default_write_concern,omitempty"`
+}
+
+// NewReplicaSetOptions converts a replica_sets entry for mongoVersion. It
+// returns nil when spec is nil or sets nothing.
+func NewReplicaSetOptions(spec *topology.ReplicaSetSpec, mongoVersion string) (*ReplicaSetOptions, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	v, err := version.NewVersion(mongoVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MongoDB version %q: %w", mongoVersion, err)
+	}
+
+	opts := &ReplicaSetOptions{
+		ProtocolVersion:                    spec.ProtocolVersion,
+		WriteConcernMajorityJournalDefault: spec.WriteConcernMajorityJournalDefault,
+	}
+
+	if s := spec.Settings; s != nil {
+		settings := make(map[string]any)
+		if s.ChainingAllowed != nil {
+			settings["chainingAllowed"] = *s.ChainingAllowed
+		}
+		if s.HeartbeatIntervalMillis > 0 {
+			settings["heartbeatIntervalMillis"] = s.HeartbeatIntervalMillis
+		}
+		if s.HeartbeatTimeoutSecs > 0 {
+			settings["heartbeatTimeoutSecs"] = s.HeartbeatTimeoutSecs
+		}
+		if s.ElectionTimeoutMillis > 0 {
+			settings["electionTimeoutMillis"] = s.ElectionTimeoutMillis
+		}
+		if s.CatchUpTimeoutMillis != nil {
+			settings["catchUpTimeoutMillis"] = *s.CatchUpTimeoutMillis
+		}
+		if len(settings) > 0 {
+			opts.Settings = settings
+		}
+	}
+
+	if spec.HasDefaultRWConcern() {
+		if v.LessThan(version.Must(version.NewVersion("4.4"))) {
+			return nil, fmt.Errorf("replica set %s: default read/write concerns require MongoDB >= 4.4", spec.Name)
+		}
+		opts.DefaultReadConcern = spec.DefaultReadConcern
+		if wc := spec.DefaultWriteConcern; wc != nil {
+			opts.DefaultWriteConcern = make(map[string]any)
+			if wc.W != "" {
+				var w any = wc.W
+				if n, err := strconv.Atoi(wc.W); err == nil {
+					w = n
+				}
+				opts.DefaultWriteConcern["w"] = w
+			}
+			if wc.J != nil {
+				opts.DefaultWriteConcern["j"] = *wc.J
+			}
+			if wc.WTimeout > 0 {
+				opts.DefaultWriteConcern["wtimeout"] = wc.WTimeout
+			}
+		}
+	}
+
+	if opts.ProtocolVersion == 0 && opts.WriteConcernMajorityJournalDefault == nil &&
+		opts.Settings == nil && !opts.HasDefaultRWConcern() {
+		return nil, nil
+	}
+	return opts, nil
+}
+
+// HasConfigSettings reports whether the options change the replica set config
+func (o *ReplicaSetOptions) HasConfigSettings() bool {
+	return o != nil && (o.ProtocolVersion != 0 || o.WriteConcernMajorityJournalDefault != nil || len(o.Settings) > 0)
+}
+
+// HasDefaultRWConcern reports whether the options set cluster-wide defaults
+func (o *ReplicaSetOptions) HasDefaultRWConcern() bool {
+	return o != nil && (o.DefaultReadConcern != "" || len(o.DefaultWriteConcern) > 0)
+}
+
+// WithoutDefaultRWConcern returns the options without default read/write
+// concerns, for replica sets of a sharded cluster where they are set through
+// mongos. It returns nil when nothing else is set.
+func (o *ReplicaSetOptions) WithoutDefaultRWConcern() *ReplicaSetOptions {
+	if !o.HasConfigSettings() {
+		return nil
+	}
+	stripped := *o
+	stripped.DefaultReadConcern = ""
+	stripped.DefaultWriteConcern = nil
+	return &stripped
+}
+
+// DefaultRWConcernOnly returns just the default read/write concerns, or nil
+// when none are set
+func (o *ReplicaSetOptions) DefaultRWConcernOnly() *ReplicaSetOptions {
+	if !o.HasDefaultRWConcern() {
+		return nil
+	}
+	return &ReplicaSetOptions{DefaultReadConcern: o.DefaultReadConcern, DefaultWriteConcern: o.DefaultWriteConcern}
+}
+
+// ApplyToConfig sets the options on a replica set config document (the
+// replSetInitiate argument or a replSetGetConfig result) and reports whether
+// anything changed
+func (o *ReplicaSetOptions) ApplyToConfig(config bson.M) bool {
+	if !o.HasConfigSettings() {
+		return false
+	}
+
+	changed := false
+	set := func(doc bson.M, key string, value any) {
+		if fmt.Sprint(doc[key]) != fmt.Sprint(value) {
+			doc[key] = value
+			changed = true
+		}
+	}
+
+	if o.ProtocolVersion != 0 {
+		set(config, "protocolVersion", o.ProtocolVersion)
+	}
+	if o.WriteConcernMajorityJournalDefault != nil {
+		set(config, "writeConcernMajorityJournalDefault", *o.WriteConcernMajorityJournalDefault)
+	}
+	if len(o.Settings) > 0 {
+		var settings bson.M
+		switch existing := config["settings"].(type) {
+		case bson.M:
+			settings = existing
+		case bson.D:
+			settings = existing.Map()
+		default:
+			settings = bson.M{}
+		}
+		config["settings"] = settings
+		keys := make([]string, 0, len(o.Settings))
+		for key := range o.Settings {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			set(settings, key, o.Settings[key])
+		}
+	}
+	return changed
+}
+
+// DefaultRWConcernCommand returns the setDefaultRWConcern command, or nil
+// when no defaults are set
+func (o *ReplicaSetOptions) DefaultRWConcernCommand() bson.D {
+	if !o.HasDefaultRWConcern() {
+		return nil
+	}
+	cmd := bson.D{{Key: "setDefaultRWConcern", Value: 1}}
+	if o.DefaultReadConcern != "" {
+		cmd = append(cmd, bson.E{Key: "defaultReadConcern", Value: bson.M{"level": o.DefaultReadConcern}})
+	}
+	if len(o.DefaultWriteConcern) > 0 {
+		cmd = append(cmd, bson.E{Key: "defaultWriteConcern", Value: bson.M(o.DefaultWriteConcern)})
+	}
+	return cmd
+}
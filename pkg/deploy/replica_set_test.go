@@ -0,0 +1,206 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/topology"
+)
+
+func TestReplicaSetMember_Document(t *testing.T) {
+	hidden := true
+	priority := 0.0
+	noIndexes := false
+
+	delayed := NewReplicaSetMember(&topology.MongodNode{
+		Host:               "localhost",
+		Port:               27019,
+		ReplicaSet:         "rs0",
+		Priority:           &priority,
+		Hidden:             &hidden,
+		SecondaryDelaySecs: 3600,
+		BuildIndexes:       &noIndexes,
+		Tags:               map[string]string{"dc": "east"},
+	})
+
+	assert.Equal(t, bson.M{
+		"_id":                2,
+		"host":               "127.0.0.1:27019",
+		"priority":           0.0,
+		"hidden":             true,
+		"secondaryDelaySecs": 3600,
+		"buildIndexes":       false,
+		"tags":               map[string]string{"dc": "east"},
+	}, delayed.Document(2, "7.0.5"))
+
+	// Before 5.0 the delay is slaveDelay
+	doc := delayed.Document(2, "4.4")
+	assert.Equal(t, 3600, doc["slaveDelay"])
+	assert.NotContains(t, doc, "secondaryDelaySecs")
+
+	arbiter := NewReplicaSetMember(&topology.MongodNode{Host: "db3", Port: 27017, ArbiterOnly: true})
+	assert.Equal(t, bson.M{"_id": 1, "host": "db3:27017", "arbiterOnly": true}, arbiter.Document(1, "7.0"))
+
+	plain := NewReplicaSetMember(&topology.MongodNode{Host: "db1", Port: 27017})
+	assert.Equal(t, bson.M{"_id": 0, "host": "db1:27017"}, plain.Document(0, "7.0"))
+
+	// Members are added under their advertised address
+	advertised := NewReplicaSetMember(&topology.MongodNode{Host: "10.0.0.5", AdvertiseHost: "db1.internal", Port: 27017})
+	assert.Equal(t, "db1.internal:27017", advertised.Address())
+
+	v6 := NewReplicaSetMember(&topology.MongodNode{Host: "::1", Port: 27017})
+	assert.Equal(t, bson.M{"_id": 0, "host": "[::1]:27017"}, v6.Document(0, "7.0"))
+}
+
+func TestSeedMember_SkipsArbiters(t *testing.T) {
+	seed, err := SeedMember([]ReplicaSetMember{
+		{Host: "db3", Port: 27017, ArbiterOnly: true},
+		{Host: "db1", Port: 27017},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "db1:27017", seed.Address())
+
+	_, err = SeedMember([]ReplicaSetMember{{Host: "db3", Port: 27017, ArbiterOnly: true}})
+	assert.Error(t, err)
+}
+
+func TestNewReplicaSetOptions(t *testing.T) {
+	no := false
+	catchUp := -1
+	spec := &topology.ReplicaSetSpec{
+		Name:                               "rs0",
+		WriteConcernMajorityJournalDefault: &no,
+		Settings: &topology.ReplicaSetSettings{
+			ChainingAllowed:       &no,
+			ElectionTimeoutMillis: 5000,
+			CatchUpTimeoutMillis:  &catchUp,
+		},
+		DefaultReadConcern:  "majority",
+		DefaultWriteConcern: &topology.WriteConcern{W: "2", WTimeout: 1000},
+	}
+
+	opts, err := NewReplicaSetOptions(spec, "7.0.0")
+	require.NoError(t, err)
+	require.NotNil(t, opts)
+
+	config := bson.M{"_id": "rs0", "version": 1}
+	assert.True(t, opts.ApplyToConfig(config))
+	assert.Equal(t, false, config["writeConcernMajorityJournalDefault"])
+	assert.Equal(t, bson.M{
+		"chainingAllowed":       false,
+		"electionTimeoutMillis": 5000,
+		"catchUpTimeoutMillis":  -1,
+	}, config["settings"])
+
+	// A running config that already matches is left alone, whatever the
+	// numeric types the server returned
+	running := bson.M{
+		"writeConcernMajorityJournalDefault": false,
+		"settings": bson.D{
+			{Key: "chainingAllowed", Value: false},
+			{Key: "electionTimeoutMillis", Value: int32(5000)},
+			{Key: "catchUpTimeoutMillis", Value: int64(-1)},
+		},
+	}
+	assert.False(t, opts.ApplyToConfig(running))
+
+	cmd := opts.DefaultRWConcernCommand()
+	require.NotEmpty(t, cmd)
+	assert.Equal(t, "setDefaultRWConcern", cmd[0].Key, "command name must come first")
+	assert.Equal(t, bson.D{
+		{Key: "setDefaultRWConcern", Value: 1},
+		{Key: "defaultReadConcern", Value: bson.M{"level": "majority"}},
+		{Key: "defaultWriteConcern", Value: bson.M{"w": 2, "wtimeout": 1000}},
+	}, cmd)
+
+	_, err = NewReplicaSetOptions(spec, "4.2.0")
+	assert.ErrorContains(t, err, "require MongoDB >= 4.4")
+
+	// Nothing set means nothing to apply
+	opts, err = NewReplicaSetOptions(&topology.ReplicaSetSpec{Name: "rs0"}, "7.0.0")
+	require.NoError(t, err)
+	assert.Nil(t, opts)
+	assert.Nil(t, opts.DefaultRWConcernCommand())
+}
+
+func TestPlanner_ShardedDefaultRWConcern(t *testing.T) {
+	no := false
+	p := newAuthPlanner(&topology.Topology{
+		ConfigSvr: []topology.ConfigNode{{Host: "cfg1", Port: 27019, ReplicaSet: "configRS"}},
+		Mongod: []topology.MongodNode{
+			{Host: "db1", Port: 27018, ReplicaSet: "shard1"},
+			{Host: "db2", Port: 27018, ReplicaSet: "shard2"},
+		},
+		Mongos: []topology.MongosNode{{Host: "router", Port: 27017}},
+		ReplicaSets: []topology.ReplicaSetSpec{
+			{
+				Name:                "shard1",
+				Settings:            &topology.ReplicaSetSettings{ChainingAllowed: &no},
+				DefaultReadConcern:  "majority",
+				DefaultWriteConcern: &topology.WriteConcern{W: "majority"},
+			},
+			{Name: "shard2", DefaultReadConcern: "majority", DefaultWriteConcern: &topology.WriteConcern{W: "majority"}},
+		},
+	})
+	require.NoError(t, p.topology.ValidateReplicaSetSpecs())
+
+	phase, err := p.generateInitializePhase()
+	require.NoError(t, err)
+
+	// Shards keep their replica set settings but not the cluster-wide defaults
+	for _, op := range opsOfType(phase, plan.OpInitReplicaSet) {
+		opts, _ := op.Params["replica_set_options"].(*ReplicaSetOptions)
+		assert.False(t, opts.HasDefaultRWConcern(), op.Params["replica_set"])
+		if op.Params["replica_set"] == "shard1" {
+			require.NotNil(t, opts)
+			assert.Equal(t, false, opts.Settings["chainingAllowed"])
+		}
+	}
+
+	rwOps := opsOfType(phase, plan.OpSetDefaultRWConcern)
+	require.Len(t, rwOps, 1, "defaults are set once")
+	assert.Equal(t, "router:27017", rwOps[0].Params["mongos_host"])
+	opts := rwOps[0].Params["replica_set_options"].(*ReplicaSetOptions)
+	assert.Equal(t, bson.D{
+		{Key: "setDefaultRWConcern", Value: 1},
+		{Key: "defaultReadConcern", Value: bson.M{"level": "majority"}},
+		{Key: "defaultWriteConcern", Value: bson.M{"w": "majority"}},
+	}, opts.DefaultRWConcernCommand())
+	assert.False(t, opts.HasConfigSettings())
+
+	// They run after every shard is added
+	var lastAddShard, setDefaults int
+	for i, op := range phase.Operations {
+		switch op.Type {
+		case plan.OpAddShard:
+			lastAddShard = i
+		case plan.OpSetDefaultRWConcern:
+			setDefaults = i
+		}
+	}
+	assert.Greater(t, setDefaults, lastAddShard)
+}
+
+func TestValidateArbiters(t *testing.T) {
+	topo := &topology.Topology{Mongod: []topology.MongodNode{
+		{Host: "db1", Port: 27017, ReplicaSet: "rs0"},
+		{Host: "db2", Port: 27017, ReplicaSet: "rs0", ArbiterOnly: true},
+		{Host: "db3", Port: 27017, ReplicaSet: "rs0", ArbiterOnly: true},
+		{Host: "db4", Port: 27017, ReplicaSet: "rs1"},
+		{Host: "db5", Port: 27017, ReplicaSet: "rs1", ArbiterOnly: true},
+	}}
+
+	// MongoDB allows several arbiters before 5.3
+	assert.NoError(t, ValidateArbiters(topo, "4.4.29"))
+	assert.NoError(t, ValidateArbiters(topo, "5.0.0"))
+
+	assert.EqualError(t, ValidateArbiters(topo, "5.3.0"), "replica set rs0 has 2 arbiters (max 1 from MongoDB 5.3)")
+	assert.ErrorContains(t, ValidateArbiters(topo, "7.0.12"), "rs0 has 2 arbiters")
+
+	topo.Mongod = topo.Mongod[:2]
+	assert.NoError(t, ValidateArbiters(topo, "7.0.12"))
+}
@@ -0,0 +1,96 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/simulation"
+	"github.com/zph/mup/pkg/supervisor"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// cgroupHost is a simulated host whose cgroup probe prints mode
+func cgroupHost(mode supervisor.CgroupMode) executor.Executor {
+	config := simulation.NewConfig()
+	config.SetResponse(supervisor.CgroupProbeCommand, string(mode)+"\n")
+	return simulation.NewExecutor(config)
+}
+
+func TestPlanner_CgroupModesPerHost(t *testing.T) {
+	p := newAuthPlanner(&topology.Topology{
+		Global: topology.GlobalConfig{
+			User:            "mongo",
+			DeployDir:       "/opt/mongodb",
+			ResourceControl: &topology.ResourceControl{MemoryLimit: "2G"},
+		},
+		Mongod: []topology.MongodNode{
+			{Host: "db1", Port: 27017, ReplicaSet: "rs0"},
+			{Host: "db2", Port: 27017, ReplicaSet: "rs0"},
+			{Host: "db3", Port: 27017, ReplicaSet: "rs0"},
+			{Host: "db4", Port: 27017, ReplicaSet: "rs0"},
+		},
+	})
+	p.executors = map[string]executor.Executor{
+		"db1": cgroupHost(supervisor.CgroupFS),
+		"db2": cgroupHost(supervisor.CgroupSystemdUser),
+		"db3": cgroupHost(supervisor.CgroupFS),
+	}
+
+	phase, err := p.generateDeployPhase(context.Background())
+	require.NoError(t, err)
+
+	configOps := opsOfType(phase, plan.OpGenerateSupervisorCfg)
+	require.Len(t, configOps, 1)
+	assert.Equal(t, map[string]supervisor.CgroupMode{
+		"db1": supervisor.CgroupFS,
+		"db2": supervisor.CgroupSystemdUser,
+		"db3": supervisor.CgroupFS,
+		"db4": supervisor.CgroupNone, // no executor to probe it with
+	}, configOps[0].Params["cgroup_modes"])
+	assert.Equal(t, "/opt/mongodb/bin", configOps[0].Params["cgexec_dir"])
+
+	uploads := opsOfType(phase, plan.OpUploadFile)
+	require.Len(t, uploads, 2, "only cgroupfs hosts need the wrapper")
+	for i, host := range []string{"db1", "db3"} {
+		assert.Equal(t, host, uploads[i].Target.Host)
+		assert.Equal(t, "/home/mup/.mup/storage/clusters/prod/v7.0.0/bin/mup-cgexec", uploads[i].Params["local_path"])
+		assert.Equal(t, "/opt/mongodb/bin/mup-cgexec", uploads[i].Params["remote_path"])
+		assert.Equal(t, "0755", uploads[i].Params["mode"])
+	}
+
+	// The wrappers are uploaded after the config generator writes them
+	var configIndex, uploadIndex int
+	for i, op := range phase.Operations {
+		switch op.Type {
+		case plan.OpGenerateSupervisorCfg:
+			configIndex = i
+		case plan.OpUploadFile:
+			if uploadIndex == 0 {
+				uploadIndex = i
+			}
+		}
+	}
+	assert.Greater(t, uploadIndex, configIndex)
+}
+
+func TestPlanner_NoCgroupProbeWithoutLimits(t *testing.T) {
+	host := simulation.NewExecutor(simulation.NewConfig())
+	p := newAuthPlanner(&topology.Topology{
+		Global: topology.GlobalConfig{User: "mongo", DeployDir: "/opt/mongodb"},
+		Mongod: []topology.MongodNode{{Host: "db1", Port: 27017}},
+	})
+	p.executors = map[string]executor.Executor{"db1": host}
+
+	phase, err := p.generateDeployPhase(context.Background())
+	require.NoError(t, err)
+
+	configOps := opsOfType(phase, plan.OpGenerateSupervisorCfg)
+	require.Len(t, configOps, 1)
+	assert.NotContains(t, configOps[0].Params, "cgroup_modes")
+	assert.Empty(t, opsOfType(phase, plan.OpUploadFile))
+	assert.Empty(t, host.GetOperations())
+}
@@ -0,0 +1,178 @@
+package deploy
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/zph/mup/pkg/mongo"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// AuthParams is the "auth" parameter of initialize operations on a cluster
+// with access control. Plans hold the password file, never the password.
+type AuthParams struct {
+	User         string `json:"user"`
+	PasswordFile string `json:"password_file"`
+
+	// CredentialsFile is the encrypted credential store, preferred over
+	// PasswordFile once it exists
+	CredentialsFile string `json:"credentials_file,omitempty"`
+
+	// Shell, Loopback and Port locate the node the first user is created
+	// on through the localhost exception
+	Shell    string `json:"shell,omitempty"`
+	Loopback string `json:"loopback,omitempty"`
+	Port     int    `json:"port,omitempty"`
+
+	// TLSCAFile is the CA on the node's host the shell verifies the node
+	// with when the cluster uses TLS
+	TLSCAFile string `json:"tls_ca_file,omitempty"`
+}
+
+// keyFilePath returns where mongod reads the keyFile on the nodes' hosts
+func (p *DeployPlanner) keyFilePath() (string, error) {
+	if p.isLocal {
+		return p.layout.KeyFile(), nil
+	}
+	return paths.NewRemotePathResolver(&p.topology.Global).KeyFile()
+}
+
+// shellPath returns the MongoDB shell on the nodes' hosts
+func (p *DeployPlanner) shellPath() (string, error) {
+	shell := mongo.GetShellBinary(p.version)
+	if p.isLocal {
+		return filepath.Join(p.layout.BinDir(p.version), shell), nil
+	}
+	binDir, err := paths.NewRemotePathResolver(&p.topology.Global).VersionBinDir(p.version)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(binDir, shell), nil
+}
+
+// authParams returns the auth parameter for operations connecting as the
+// admin user, or nil when the topology does not enable access control
+func (p *DeployPlanner) authParams() *AuthParams {
+	if !p.topology.AuthEnabled() {
+		return nil
+	}
+	return &AuthParams{
+		User:            p.topology.Security.AdminUsername(),
+		PasswordFile:    p.layout.AdminPasswordFile(),
+		CredentialsFile: p.layout.CredentialsFile(),
+	}
+}
+
+// bootstrapAuthParams returns the auth parameter for creating the first user
+// on the node at port bound to bindIP
+func (p *DeployPlanner) bootstrapAuthParams(bindIP string, port int) (*AuthParams, error) {
+	auth := p.authParams()
+	if auth == nil {
+		return nil, nil
+	}
+	loopback := topology.LoopbackAddress(bindIP)
+	if loopback == "" {
+		return nil, fmt.Errorf("bind_ip %q has no loopback address for creating the admin user", bindIP)
+	}
+	shell, err := p.shellPath()
+	if err != nil {
+		return nil, err
+	}
+	auth.Shell = shell
+	auth.Loopback = loopback
+	auth.Port = port
+	if p.topology.TLSEnabled() {
+		if auth.TLSCAFile, err = p.caFilePath(); err != nil {
+			return nil, err
+		}
+	}
+	return auth, nil
+}
+
+// generateKeyFileOperations plans the keyFile and admin password and, for
+// remote deployments, one keyFile distribution per host
+func (p *DeployPlanner) generateKeyFileOperations(opIndex *int) ([]plan.PlannedOperation, error) {
+	if !p.topology.AuthEnabled() {
+		return nil, nil
+	}
+
+	keyFile := p.layout.KeyFile()
+	params := map[string]interface{}{
+		"key_file":         keyFile,
+		"admin_user":       p.topology.Security.AdminUsername(),
+		"credentials_file": p.layout.CredentialsFile(),
+		"password_file":    p.layout.AdminPasswordFile(),
+	}
+	if source := p.topology.Security.KeyFile; source != "" {
+		params["source_key_file"] = source
+	}
+
+	operations := []plan.PlannedOperation{{
+		ID:          plan.NewOperationID("prepare", *opIndex),
+		Type:        plan.OpGenerateKeyFile,
+		Description: fmt.Sprintf("Generate keyFile %s and password for user %s", keyFile, p.topology.Security.AdminUsername()),
+		Target: plan.OperationTarget{
+			Type: "keyfile",
+			Name: p.clusterName,
+		},
+		Params: params,
+		Changes: []plan.Change{
+			{ResourceType: "file", ResourceID: keyFile, Action: plan.ActionCreate},
+			{ResourceType: "file", ResourceID: p.layout.CredentialsFile(), Action: plan.ActionCreate},
+		},
+		Parallel: false,
+	}}
+	*opIndex++
+
+	if p.isLocal {
+		return operations, nil
+	}
+
+	dest, err := p.keyFilePath()
+	if err != nil {
+		return nil, err
+	}
+	hosts := p.topology.GetAllHosts()
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		operations = append(operations, plan.PlannedOperation{
+			ID:          plan.NewOperationID("prepare", *opIndex),
+			Type:        plan.OpDistributeKeyFile,
+			Description: fmt.Sprintf("Distribute keyFile to %s:%s", host, dest),
+			Target: plan.OperationTarget{
+				Type: "host",
+				Name: host,
+				Host: host,
+			},
+			Params: map[string]interface{}{
+				"source": keyFile,
+				"dest":   dest,
+			},
+			Changes: []plan.Change{
+				{ResourceType: "file", ResourceID: fmt.Sprintf("%s:%s", host, dest), Action: plan.ActionCreate},
+			},
+			Parallel: true,
+		})
+		*opIndex++
+	}
+	return operations, nil
+}
+
+// bootstrapNode returns the replica set member the first user is created
+// on. It must become primary, so arbiters and priority 0 members are skipped.
+func (p *DeployPlanner) bootstrapNode(rsName string) (host string, port int, bindIP string, ok bool) {
+	for _, cs := range p.topology.ConfigSvr {
+		if cs.ReplicaSet == rsName {
+			return cs.Host, cs.Port, cs.BindAddresses(p.isLocal), true
+		}
+	}
+	for _, node := range p.topology.Mongod {
+		if node.ReplicaSet == rsName && !node.ArbiterOnly && node.MemberPriority() > 0 {
+			return node.Host, node.Port, node.BindAddresses(p.isLocal), true
+		}
+	}
+	return "", 0, "", false
+}
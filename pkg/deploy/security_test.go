@@ -0,0 +1,148 @@
+package deploy
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/topology"
+)
+
+func newAuthPlanner(topo *topology.Topology) *DeployPlanner {
+	metaDir := "/home/mup/.mup/storage/clusters/prod"
+	return &DeployPlanner{
+		clusterName:  "prod",
+		version:      "7.0.0",
+		topology:     topo,
+		metaDir:      metaDir,
+		layout:       paths.NewClusterLayout(metaDir),
+		pathResolver: paths.NewLocalPathResolver(metaDir, "7.0.0"),
+	}
+}
+
+func opsOfType(phase plan.PlannedPhase, opType plan.OperationType) []plan.PlannedOperation {
+	var ops []plan.PlannedOperation
+	for _, op := range phase.Operations {
+		if op.Type == opType {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+func TestPlanner_Auth(t *testing.T) {
+	noPriority := 0.0
+	p := newAuthPlanner(&topology.Topology{
+		Global:   topology.GlobalConfig{User: "mongo", DeployDir: "/opt/mongodb"},
+		Security: &topology.SecurityConfig{Auth: true},
+		ConfigSvr: []topology.ConfigNode{
+			{Host: "cfg1", Port: 27019, ReplicaSet: "configRS"},
+		},
+		Mongod: []topology.MongodNode{
+			{Host: "db1", Port: 27018, ReplicaSet: "rs0", Priority: &noPriority},
+			{Host: "db2", Port: 27018, ReplicaSet: "rs0"},
+		},
+		Mongos: []topology.MongosNode{{Host: "app1", Port: 27017}},
+	})
+	keyFile := "/opt/mongodb/secrets/keyfile"
+
+	prepare, err := p.generatePreparePhase()
+	require.NoError(t, err)
+	generate := opsOfType(prepare, plan.OpGenerateKeyFile)
+	require.Len(t, generate, 1)
+	assert.Equal(t, p.layout.KeyFile(), generate[0].Params["key_file"])
+	assert.Equal(t, p.layout.AdminPasswordFile(), generate[0].Params["password_file"])
+	distribute := opsOfType(prepare, plan.OpDistributeKeyFile)
+	require.Len(t, distribute, 4, "one per host")
+	assert.Equal(t, "app1", distribute[0].Target.Host)
+	assert.Equal(t, keyFile, distribute[0].Params["dest"])
+
+	deployPhase, err := p.generateDeployPhase(context.Background())
+	require.NoError(t, err)
+	for _, op := range opsOfType(deployPhase, plan.OpGenerateConfig) {
+		assert.Equal(t, keyFile, op.Params["key_file"], op.Target.Name)
+		assert.Contains(t, op.Changes[0].After, "keyFile: "+keyFile, op.Target.Name)
+	}
+
+	initialize, err := p.generateInitializePhase()
+	require.NoError(t, err)
+	for _, op := range opsOfType(initialize, plan.OpInitReplicaSet) {
+		auth, ok := op.Params["auth"].(*AuthParams)
+		require.True(t, ok, op.Target.Name)
+		assert.Equal(t, "admin", auth.User)
+		assert.Equal(t, "/opt/mongodb/7.0.0/bin/mongosh", auth.Shell)
+		assert.Equal(t, "127.0.0.1", auth.Loopback)
+		if op.Target.Name == "rs0" {
+			assert.Equal(t, "db2", op.Target.Host, "priority 0 members cannot create the user")
+			assert.Equal(t, 27018, auth.Port)
+		} else {
+			assert.Equal(t, "cfg1", op.Target.Host)
+		}
+	}
+	addShard := opsOfType(initialize, plan.OpAddShard)
+	require.Len(t, addShard, 1)
+	assert.Equal(t, &AuthParams{User: "admin", PasswordFile: p.layout.AdminPasswordFile(), CredentialsFile: p.layout.CredentialsFile()}, addShard[0].Params["auth"])
+}
+
+func TestPlanner_AuthStandalone(t *testing.T) {
+	p := newAuthPlanner(&topology.Topology{
+		Security: &topology.SecurityConfig{Auth: true, AdminUser: "dba"},
+		Mongod:   []topology.MongodNode{{Host: "localhost", Port: 30000}},
+	})
+	p.isLocal = true
+
+	prepare, err := p.generatePreparePhase()
+	require.NoError(t, err)
+	assert.Len(t, opsOfType(prepare, plan.OpGenerateKeyFile), 1)
+	assert.Empty(t, opsOfType(prepare, plan.OpDistributeKeyFile), "local nodes read the keyFile in place")
+
+	initialize, err := p.generateInitializePhase()
+	require.NoError(t, err)
+	create := opsOfType(initialize, plan.OpCreateAdminUser)
+	require.Len(t, create, 1)
+	auth := create[0].Params["auth"].(*AuthParams)
+	assert.Equal(t, "dba", auth.User)
+	assert.Equal(t, 30000, auth.Port)
+	assert.Equal(t, p.layout.BinDir("7.0.0")+"/mongosh", auth.Shell)
+}
+
+func TestPlanner_NoAuth(t *testing.T) {
+	p := newAuthPlanner(&topology.Topology{
+		Mongod: []topology.MongodNode{{Host: "localhost", Port: 30000, ReplicaSet: "rs0"}},
+	})
+	p.isLocal = true
+
+	prepare, err := p.generatePreparePhase()
+	require.NoError(t, err)
+	assert.Empty(t, opsOfType(prepare, plan.OpGenerateKeyFile))
+
+	initialize, err := p.generateInitializePhase()
+	require.NoError(t, err)
+	for _, op := range initialize.Operations {
+		assert.NotContains(t, op.Params, "auth")
+	}
+}
+
+func TestPlanner_ShellPath(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"10.0.1", "mongosh"},
+		{"7.0.0", "mongosh"},
+		{"4.4.29", "mongo"},
+		{"3.6.23", "mongo"},
+	}
+	for _, tt := range tests {
+		p := newAuthPlanner(&topology.Topology{})
+		p.isLocal = true
+		p.version = tt.version
+		shell, err := p.shellPath()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(p.layout.BinDir(tt.version), tt.want), shell, tt.version)
+	}
+}
@@ -0,0 +1,204 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/zph/mup/pkg/naming"
+	"github.com/zph/mup/pkg/paths"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/template"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// TLSParams is the "tls" parameter of operations connecting to a cluster
+// with TLS
+type TLSParams struct {
+	CAFile string `json:"ca_file"` // CA certificate on the machine running mup
+}
+
+// CertificateRequest is one node certificate of a generate_certificates
+// operation
+type CertificateRequest struct {
+	Node  string   `json:"node"`
+	Hosts []string `json:"hosts"` // Subject alternative names; the first is the common name
+	File  string   `json:"file"`  // Certificate and key, on the machine running mup
+}
+
+// SecretFile is one file a distribute_certificates operation uploads
+type SecretFile struct {
+	Source string      `json:"source"`
+	Dest   string      `json:"dest"`
+	Mode   os.FileMode `json:"mode"`
+}
+
+// certificateNode is a node that gets a certificate
+type certificateNode struct {
+	nodeType string
+	host     string
+	port     int
+	hosts    []string
+}
+
+// certificateNodes returns every node of the topology with the names its
+// certificate is issued for
+func (p *DeployPlanner) certificateNodes() []certificateNode {
+	var nodes []certificateNode
+	for _, cs := range p.topology.ConfigSvr {
+		nodes = append(nodes, certificateNode{"config", cs.Host, cs.Port, topology.CertificateHosts(cs.Host, cs.AdvertiseHost, cs.BindIP)})
+	}
+	for _, node := range p.topology.Mongod {
+		nodes = append(nodes, certificateNode{"mongod", node.Host, node.Port, topology.CertificateHosts(node.Host, node.AdvertiseHost, node.BindIP)})
+	}
+	for _, mongos := range p.topology.Mongos {
+		nodes = append(nodes, certificateNode{"mongos", mongos.Host, mongos.Port, topology.CertificateHosts(mongos.Host, mongos.AdvertiseHost, mongos.BindIP)})
+	}
+	return nodes
+}
+
+// caFilePath returns where nodes read the CA certificate on their hosts
+func (p *DeployPlanner) caFilePath() (string, error) {
+	if p.isLocal {
+		return p.layout.CAFile(), nil
+	}
+	return paths.NewRemotePathResolver(&p.topology.Global).CAFile()
+}
+
+// certificateFilePath returns where a node reads its certificate on its host
+func (p *DeployPlanner) certificateFilePath(host string, port int) (string, error) {
+	if p.isLocal {
+		return p.layout.CertificateFile(host, port), nil
+	}
+	return paths.NewRemotePathResolver(&p.topology.Global).CertificateFile(host, port)
+}
+
+// tlsOptions returns the TLS settings of the node at host:port, or nil when
+// the topology does not enable TLS
+func (p *DeployPlanner) tlsOptions(host string, port int) (*template.TLSOptions, error) {
+	if !p.topology.TLSEnabled() {
+		return nil, nil
+	}
+	caFile, err := p.caFilePath()
+	if err != nil {
+		return nil, err
+	}
+	certFile, err := p.certificateFilePath(host, port)
+	if err != nil {
+		return nil, err
+	}
+	tls := p.topology.Security.TLS
+	return &template.TLSOptions{
+		Mode:               tls.TLSMode(),
+		CertificateKeyFile: certFile,
+		CAFile:             caFile,
+		X509MemberAuth:     tls.X509MemberAuth,
+	}, nil
+}
+
+// tlsParams returns the tls parameter for operations connecting from this
+// machine, or nil when the topology does not enable TLS
+func (p *DeployPlanner) tlsParams() *TLSParams {
+	if !p.topology.TLSEnabled() {
+		return nil
+	}
+	return &TLSParams{CAFile: p.layout.CAFile()}
+}
+
+// generateCertificateOperations plans the cluster CA and node certificates
+// and, for remote deployments, one certificate distribution per host
+func (p *DeployPlanner) generateCertificateOperations(opIndex *int) ([]plan.PlannedOperation, error) {
+	if !p.topology.TLSEnabled() {
+		return nil, nil
+	}
+
+	nodes := p.certificateNodes()
+	requests := make([]CertificateRequest, 0, len(nodes))
+	changes := []plan.Change{
+		{ResourceType: "file", ResourceID: p.layout.CAFile(), Action: plan.ActionCreate},
+	}
+	for _, node := range nodes {
+		file := p.layout.CertificateFile(node.host, node.port)
+		requests = append(requests, CertificateRequest{
+			Node:  naming.GetNodeName(node.nodeType, node.host, node.port),
+			Hosts: node.hosts,
+			File:  file,
+		})
+		changes = append(changes, plan.Change{ResourceType: "file", ResourceID: file, Action: plan.ActionCreate})
+	}
+
+	operations := []plan.PlannedOperation{{
+		ID:          plan.NewOperationID("prepare", *opIndex),
+		Type:        plan.OpGenerateCertificates,
+		Description: fmt.Sprintf("Generate cluster CA and %d node certificates in %s", len(requests), p.layout.TLSDir()),
+		Target: plan.OperationTarget{
+			Type: "certificates",
+			Name: p.clusterName,
+		},
+		Params: map[string]interface{}{
+			"cluster":      p.clusterName,
+			"ca_file":      p.layout.CAFile(),
+			"ca_key_file":  p.layout.CAKeyFile(),
+			"certificates": requests,
+		},
+		Changes:  changes,
+		Parallel: false,
+	}}
+	*opIndex++
+
+	if p.isLocal {
+		return operations, nil
+	}
+
+	// Each host gets the CA and the certificates of the nodes it runs; the
+	// CA key never leaves this machine
+	caDest, err := p.caFilePath()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]SecretFile)
+	for _, node := range nodes {
+		if _, ok := files[node.host]; !ok {
+			files[node.host] = []SecretFile{{Source: p.layout.CAFile(), Dest: caDest, Mode: 0644}}
+		}
+		dest, err := p.certificateFilePath(node.host, node.port)
+		if err != nil {
+			return nil, err
+		}
+		files[node.host] = append(files[node.host], SecretFile{
+			Source: p.layout.CertificateFile(node.host, node.port),
+			Dest:   dest,
+			Mode:   security.CertificateMode,
+		})
+	}
+
+	hosts := make([]string, 0, len(files))
+	for host := range files {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		changes := make([]plan.Change, 0, len(files[host]))
+		for _, f := range files[host] {
+			changes = append(changes, plan.Change{ResourceType: "file", ResourceID: fmt.Sprintf("%s:%s", host, f.Dest), Action: plan.ActionCreate})
+		}
+		operations = append(operations, plan.PlannedOperation{
+			ID:          plan.NewOperationID("prepare", *opIndex),
+			Type:        plan.OpDistributeCertificates,
+			Description: fmt.Sprintf("Distribute CA and %d certificates to %s", len(files[host])-1, host),
+			Target: plan.OperationTarget{
+				Type: "host",
+				Name: host,
+				Host: host,
+			},
+			Params: map[string]interface{}{
+				"files": files[host],
+			},
+			Changes:  changes,
+			Parallel: true,
+		})
+		*opIndex++
+	}
+	return operations, nil
+}
@@ -0,0 +1,107 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/template"
+	"github.com/zph/mup/pkg/topology"
+)
+
+func TestPlanner_TLS(t *testing.T) {
+	p := newAuthPlanner(&topology.Topology{
+		Global: topology.GlobalConfig{User: "mongo", DeployDir: "/opt/mongodb"},
+		Security: &topology.SecurityConfig{
+			Auth: true,
+			TLS:  &topology.TLSConfig{X509MemberAuth: true},
+		},
+		ConfigSvr: []topology.ConfigNode{{Host: "cfg1", Port: 27019, ReplicaSet: "configRS"}},
+		Mongod: []topology.MongodNode{
+			{Host: "db1", Port: 27018, ReplicaSet: "rs0", AdvertiseHost: "db1.example.com"},
+			{Host: "db1", Port: 27028, ReplicaSet: "rs0"},
+		},
+		Mongos: []topology.MongosNode{{Host: "app1", Port: 27017}},
+	})
+	caDest := "/opt/mongodb/secrets/tls/ca.pem"
+
+	prepare, err := p.generatePreparePhase()
+	require.NoError(t, err)
+	generate := opsOfType(prepare, plan.OpGenerateCertificates)
+	require.Len(t, generate, 1)
+	assert.Equal(t, p.layout.CAFile(), generate[0].Params["ca_file"])
+	assert.Equal(t, p.layout.CAKeyFile(), generate[0].Params["ca_key_file"])
+	requests := generate[0].Params["certificates"].([]CertificateRequest)
+	require.Len(t, requests, 4)
+	assert.Equal(t, "mongod-db1-27018", requests[1].Node)
+	assert.Equal(t, []string{"db1", "db1.example.com", "localhost", "127.0.0.1", "::1"}, requests[1].Hosts)
+	assert.Equal(t, p.layout.CertificateFile("db1", 27018), requests[1].File)
+
+	distribute := opsOfType(prepare, plan.OpDistributeCertificates)
+	require.Len(t, distribute, 3, "one per host")
+	assert.Equal(t, "db1", distribute[2].Target.Host)
+	assert.Equal(t, []SecretFile{
+		{Source: p.layout.CAFile(), Dest: caDest, Mode: 0644},
+		{Source: p.layout.CertificateFile("db1", 27018), Dest: "/opt/mongodb/secrets/tls/db1-27018.pem", Mode: security.CertificateMode},
+		{Source: p.layout.CertificateFile("db1", 27028), Dest: "/opt/mongodb/secrets/tls/db1-27028.pem", Mode: security.CertificateMode},
+	}, distribute[2].Params["files"], "a host gets the certificates of all its nodes but never the CA key")
+
+	deployPhase, err := p.generateDeployPhase(context.Background())
+	require.NoError(t, err)
+	for _, op := range opsOfType(deployPhase, plan.OpGenerateConfig) {
+		tls, ok := op.Params["tls"].(*template.TLSOptions)
+		require.True(t, ok, op.Target.Name)
+		assert.Equal(t, "requireTLS", tls.Mode)
+		assert.Equal(t, caDest, tls.CAFile)
+		assert.Contains(t, op.Changes[0].After, "certificateKeyFile: "+tls.CertificateKeyFile, op.Target.Name)
+		assert.Contains(t, op.Changes[0].After, "clusterAuthMode: x509", op.Target.Name)
+	}
+
+	initialize, err := p.generateInitializePhase()
+	require.NoError(t, err)
+	for _, op := range opsOfType(initialize, plan.OpInitReplicaSet) {
+		assert.Equal(t, &TLSParams{CAFile: p.layout.CAFile()}, op.Params["tls"], op.Target.Name)
+		assert.Equal(t, caDest, op.Params["auth"].(*AuthParams).TLSCAFile, "the shell verifies with the CA on its host")
+	}
+	addShard := opsOfType(initialize, plan.OpAddShard)
+	require.Len(t, addShard, 1)
+	assert.Equal(t, &TLSParams{CAFile: p.layout.CAFile()}, addShard[0].Params["tls"])
+
+	finalize := opsOfType(p.generateFinalizePhase(), plan.OpSaveMetadata)
+	require.Len(t, finalize, 1)
+	assert.Equal(t, p.layout.CAFile(), finalize[0].Params["ca_file"])
+}
+
+func TestPlanner_TLSLocal(t *testing.T) {
+	p := newAuthPlanner(&topology.Topology{
+		Security: &topology.SecurityConfig{TLS: &topology.TLSConfig{Mode: "preferTLS"}},
+		Mongod:   []topology.MongodNode{{Host: "localhost", Port: 30000, ReplicaSet: "rs0"}},
+	})
+	p.isLocal = true
+
+	prepare, err := p.generatePreparePhase()
+	require.NoError(t, err)
+	assert.Len(t, opsOfType(prepare, plan.OpGenerateCertificates), 1)
+	assert.Empty(t, opsOfType(prepare, plan.OpDistributeCertificates), "local nodes read certificates in place")
+	assert.Empty(t, opsOfType(prepare, plan.OpGenerateKeyFile), "TLS does not imply access control")
+
+	deployPhase, err := p.generateDeployPhase(context.Background())
+	require.NoError(t, err)
+	configs := opsOfType(deployPhase, plan.OpGenerateConfig)
+	require.Len(t, configs, 1)
+	assert.Equal(t, &template.TLSOptions{
+		Mode:               "preferTLS",
+		CertificateKeyFile: p.layout.CertificateFile("localhost", 30000),
+		CAFile:             p.layout.CAFile(),
+	}, configs[0].Params["tls"])
+
+	initialize, err := p.generateInitializePhase()
+	require.NoError(t, err)
+	init := opsOfType(initialize, plan.OpInitReplicaSet)
+	require.Len(t, init, 1)
+	assert.NotContains(t, init[0].Params, "auth")
+	assert.Equal(t, &TLSParams{CAFile: p.layout.CAFile()}, init[0].Params["tls"])
+}
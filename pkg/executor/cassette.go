@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CassetteVersion is the on-disk format version written by Cassette.Save
+const CassetteVersion = 1
+
+// Interaction is a single recorded Executor call and its result.
+// Method names are normalized (Execute and ExecuteContext are both recorded
+// as "execute") so that replay does not depend on which variant was called.
+type Interaction struct {
+	Host   string   `json:"host"`
+	Method string   `json:"method"`
+	Args   []string `json:"args,omitempty"`
+
+	// Result fields; only the ones relevant to Method are populated
+	Output string   `json:"output,omitempty"`
+	Lines  []string `json:"lines,omitempty"`
+	Bool   bool     `json:"bool,omitempty"`
+	Int    int      `json:"int,omitempty"`
+	Uint   uint64   `json:"uint,omitempty"`
+	OSInfo *OSInfo  `json:"os_info,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// key identifies an interaction for replay matching
+func (i *Interaction) key() string {
+	return i.Host + "\x00" + i.Method + "\x00" + strings.Join(i.Args, "\x00")
+}
+
+// Cassette holds the interactions captured by one or more RecordingExecutors.
+// A single cassette can be shared by the executors of every host in a cluster;
+// each interaction is tagged with the host it ran on.
+type Cassette struct {
+	Version      int           `json:"version"`
+	RecordedAt   time.Time     `json:"recorded_at"`
+	Interactions []Interaction `json:"interactions"`
+
+	mu sync.Mutex
+}
+
+// NewCassette creates an empty cassette
+func NewCassette() *Cassette {
+	return &Cassette{
+		Version:      CassetteVersion,
+		RecordedAt:   time.Now(),
+		Interactions: []Interaction{},
+	}
+}
+
+// LoadCassette reads a cassette previously written with Save
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+
+	if c.Version != CassetteVersion {
+		return nil, fmt.Errorf("unsupported cassette version %d (expected %d)", c.Version, CassetteVersion)
+	}
+
+	return &c, nil
+}
+
+// Append adds an interaction to the cassette
+func (c *Cassette) Append(i Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, i)
+}
+
+// Len returns the number of recorded interactions
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.Interactions)
+}
+
+// Save writes the cassette to path atomically (write to temp, then rename)
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename cassette: %w", err)
+	}
+
+	return nil
+}
+
+// contentDigest summarizes uploaded content so cassettes stay small and
+// replay can still tell different payloads apart
+func contentDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:]))
+}
+
+// errorString returns err's message, or "" for nil
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingExecutor_RecordsCalls(t *testing.T) {
+	dir := t.TempDir()
+	cassette := NewCassette()
+	rec := NewRecordingExecutor(NewLocalExecutor(), "localhost", cassette)
+
+	require.NoError(t, rec.CreateDirectory(filepath.Join(dir, "data"), 0755))
+	exists, err := rec.FileExists(filepath.Join(dir, "data"))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	out, err := rec.ExecuteContext(context.Background(), "echo hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", out)
+
+	_, err = rec.Execute("exit 7")
+	require.Error(t, err)
+
+	require.Equal(t, 4, cassette.Len())
+	assert.Equal(t, "create_directory", cassette.Interactions[0].Method)
+	assert.Equal(t, "file_exists", cassette.Interactions[1].Method)
+	assert.True(t, cassette.Interactions[1].Bool)
+	assert.Equal(t, "execute", cassette.Interactions[2].Method)
+	assert.Equal(t, "hello\n", cassette.Interactions[2].Output)
+	assert.NotEmpty(t, cassette.Interactions[3].Error)
+	for _, i := range cassette.Interactions {
+		assert.Equal(t, "localhost", i.Host)
+	}
+}
+
+func TestCassette_SaveLoadReplay(t *testing.T) {
+	dir := t.TempDir()
+	cassettePath := filepath.Join(dir, "cassettes", "deploy.json")
+
+	// Record a session against the real local executor
+	cassette := NewCassette()
+	rec := NewRecordingExecutor(NewLocalExecutor(), "localhost", cassette)
+
+	configPath := filepath.Join(dir, "mongod.conf")
+	require.NoError(t, rec.UploadContent([]byte("net:\n  port: 27017\n"), configPath))
+	_, err := rec.Execute("cat " + configPath)
+	require.NoError(t, err)
+	var streamed []string
+	require.NoError(t, rec.ExecuteStream(context.Background(), "printf 'a\\nb\\n'", func(line string) {
+		streamed = append(streamed, line)
+	}))
+	osInfo, err := rec.GetOSInfo()
+	require.NoError(t, err)
+
+	require.NoError(t, cassette.Save(cassettePath))
+
+	// Replay it without touching the filesystem
+	loaded, err := LoadCassette(cassettePath)
+	require.NoError(t, err)
+	replay := NewReplayExecutor(loaded, "localhost")
+	assert.Equal(t, 4, replay.Remaining())
+
+	require.NoError(t, replay.UploadContent([]byte("net:\n  port: 27017\n"), configPath))
+	out, err := replay.ExecuteContext(context.Background(), "cat "+configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "net:\n  port: 27017\n", out)
+
+	var replayed []string
+	require.NoError(t, replay.ExecuteStream(context.Background(), "printf 'a\\nb\\n'", func(line string) {
+		replayed = append(replayed, line)
+	}))
+	assert.Equal(t, streamed, replayed)
+
+	replayedOS, err := replay.GetOSInfo()
+	require.NoError(t, err)
+	assert.Equal(t, osInfo, replayedOS)
+
+	assert.Equal(t, 0, replay.Remaining())
+}
+
+func TestReplayExecutor_Matching(t *testing.T) {
+	cassette := NewCassette()
+	cassette.Append(Interaction{Host: "db1", Method: "check_port_available", Args: []string{"27017"}, Bool: true})
+	cassette.Append(Interaction{Host: "db1", Method: "check_port_available", Args: []string{"27017"}, Bool: false})
+	cassette.Append(Interaction{Host: "db2", Method: "execute", Args: []string{"hostname"}, Output: "db2\n"})
+	cassette.Append(Interaction{Host: "db1", Method: "execute", Args: []string{"false"}, Error: "command failed: exit status 1"})
+
+	replay := NewReplayExecutor(cassette, "db1")
+	assert.Equal(t, 3, replay.Remaining(), "only db1 interactions are replayed")
+
+	// Identical calls are served in recorded order, then the last repeats
+	available, err := replay.CheckPortAvailable(27017)
+	require.NoError(t, err)
+	assert.True(t, available)
+	for i := 0; i < 3; i++ {
+		available, err = replay.CheckPortAvailable(27017)
+		require.NoError(t, err)
+		assert.False(t, available)
+	}
+
+	// Recorded errors are returned
+	_, err = replay.Execute("false")
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "exit status 1"))
+
+	// Other hosts' interactions and unknown calls do not match
+	_, err = replay.Execute("hostname")
+	assert.True(t, errors.Is(err, ErrNoInteraction))
+
+	// Cancelled contexts fail before matching
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = replay.CheckPortAvailableContext(ctx, 27017)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLoadCassette_RejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := NewCassette()
+	cassette.Version = 99
+	require.NoError(t, cassette.Save(path))
+
+	_, err := LoadCassette(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported cassette version")
+}
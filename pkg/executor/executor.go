@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"io"
 	"os"
 )
@@ -8,6 +9,10 @@ import (
 // Executor provides a unified interface for executing operations
 // either locally or remotely via SSH. This abstraction allows the same
 // deployment code to work in both local and remote scenarios.
+//
+// Every method has a context-aware variant (suffixed with Context) that
+// aborts when the context is cancelled or its deadline passes. The plain
+// variants behave as if called with context.Background().
 type Executor interface {
 	// File Operations
 	CreateDirectory(path string, mode os.FileMode) error
@@ -40,6 +45,43 @@ type Executor interface {
 	// Connection Management
 	CheckConnectivity() error
 	Close() error
+
+	// Context-aware File Operations
+	CreateDirectoryContext(ctx context.Context, path string, mode os.FileMode) error
+	UploadFileContext(ctx context.Context, localPath, remotePath string) error
+	UploadContentContext(ctx context.Context, content []byte, remotePath string) error
+	DownloadFileContext(ctx context.Context, remotePath, localPath string) error
+	FileExistsContext(ctx context.Context, path string) (bool, error)
+	RemoveFileContext(ctx context.Context, path string) error
+	RemoveDirectoryContext(ctx context.Context, path string) error
+
+	// Context-aware Command Execution
+	// A running command is killed when ctx is done.
+	ExecuteContext(ctx context.Context, command string) (output string, err error)
+	ExecuteWithInputContext(ctx context.Context, command string, stdin io.Reader) (output string, err error)
+	// BackgroundContext only honors ctx while starting the process; the
+	// started process outlives the context.
+	BackgroundContext(ctx context.Context, command string) (pid int, err error)
+	// ExecuteStream runs a command and calls onLine for every line of
+	// combined stdout/stderr as it is produced.
+	ExecuteStream(ctx context.Context, command string, onLine func(line string)) error
+
+	// Context-aware MongoDB Operations
+	MongoExecuteContext(ctx context.Context, host string, command string) (output string, err error)
+
+	// Context-aware Process Management
+	IsProcessRunningContext(ctx context.Context, pid int) (bool, error)
+	KillProcessContext(ctx context.Context, pid int) error
+	StopProcessContext(ctx context.Context, pid int) error
+
+	// Context-aware System Information
+	GetOSInfoContext(ctx context.Context) (*OSInfo, error)
+	GetDiskSpaceContext(ctx context.Context, path string) (available uint64, err error)
+	CheckPortAvailableContext(ctx context.Context, port int) (bool, error)
+	UserExistsContext(ctx context.Context, username string) (bool, error)
+
+	// Context-aware Connection Management
+	CheckConnectivityContext(ctx context.Context) error
 }
 
 // OSInfo contains operating system information
@@ -0,0 +1,26 @@
+package executor
+
+import "errors"
+
+// ExitCode extracts the remote or local process exit status from an error
+// returned by Execute/ExecuteStream. It returns 0 for nil and -1 when the
+// command never produced an exit status (connection failure, cancellation).
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	// *exec.ExitError
+	var local interface{ ExitCode() int }
+	if errors.As(err, &local) {
+		return local.ExitCode()
+	}
+
+	// *ssh.ExitError
+	var remote interface{ ExitStatus() int }
+	if errors.As(err, &remote) {
+		return remote.ExitStatus()
+	}
+
+	return -1
+}
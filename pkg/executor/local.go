@@ -2,6 +2,7 @@ package executor
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -21,6 +22,9 @@ import (
 // LocalExecutor implements Executor for local operations
 type LocalExecutor struct{}
 
+// Ensure LocalExecutor implements Executor interface
+var _ Executor = (*LocalExecutor)(nil)
+
 // NewLocalExecutor creates a new LocalExecutor
 func NewLocalExecutor() *LocalExecutor {
 	return &LocalExecutor{}
@@ -28,11 +32,28 @@ func NewLocalExecutor() *LocalExecutor {
 
 // CreateDirectory creates a directory with the specified permissions
 func (e *LocalExecutor) CreateDirectory(path string, mode os.FileMode) error {
+	return e.CreateDirectoryContext(context.Background(), path, mode)
+}
+
+// CreateDirectoryContext creates a directory unless ctx is already done
+func (e *LocalExecutor) CreateDirectoryContext(ctx context.Context, path string, mode os.FileMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return os.MkdirAll(path, mode)
 }
 
 // UploadFile copies a file from localPath to remotePath (both local in this case)
 func (e *LocalExecutor) UploadFile(localPath, remotePath string) error {
+	return e.UploadFileContext(context.Background(), localPath, remotePath)
+}
+
+// UploadFileContext copies a file unless ctx is already done
+func (e *LocalExecutor) UploadFileContext(ctx context.Context, localPath, remotePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Ensure parent directory exists
 	dir := filepath.Dir(remotePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -55,6 +76,15 @@ func (e *LocalExecutor) UploadFile(localPath, remotePath string) error {
 
 // UploadContent writes content to a file at remotePath
 func (e *LocalExecutor) UploadContent(content []byte, remotePath string) error {
+	return e.UploadContentContext(context.Background(), content, remotePath)
+}
+
+// UploadContentContext writes content to a file unless ctx is already done
+func (e *LocalExecutor) UploadContentContext(ctx context.Context, content []byte, remotePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Ensure parent directory exists
 	dir := filepath.Dir(remotePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -73,8 +103,21 @@ func (e *LocalExecutor) DownloadFile(remotePath, localPath string) error {
 	return e.UploadFile(remotePath, localPath)
 }
 
+// DownloadFileContext copies a file unless ctx is already done
+func (e *LocalExecutor) DownloadFileContext(ctx context.Context, remotePath, localPath string) error {
+	return e.UploadFileContext(ctx, remotePath, localPath)
+}
+
 // FileExists checks if a file exists
 func (e *LocalExecutor) FileExists(path string) (bool, error) {
+	return e.FileExistsContext(context.Background(), path)
+}
+
+// FileExistsContext checks if a file exists unless ctx is already done
+func (e *LocalExecutor) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	_, err := os.Stat(path)
 	if err == nil {
 		return true, nil
@@ -87,43 +130,102 @@ func (e *LocalExecutor) FileExists(path string) (bool, error) {
 
 // RemoveFile removes a file
 func (e *LocalExecutor) RemoveFile(path string) error {
+	return e.RemoveFileContext(context.Background(), path)
+}
+
+// RemoveFileContext removes a file unless ctx is already done
+func (e *LocalExecutor) RemoveFileContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return os.Remove(path)
 }
 
 // RemoveDirectory removes a directory and all its contents
 func (e *LocalExecutor) RemoveDirectory(path string) error {
+	return e.RemoveDirectoryContext(context.Background(), path)
+}
+
+// RemoveDirectoryContext removes a directory unless ctx is already done
+func (e *LocalExecutor) RemoveDirectoryContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return os.RemoveAll(path)
 }
 
 // Execute runs a command and returns its output
 func (e *LocalExecutor) Execute(command string) (string, error) {
-	cmd := exec.Command("sh", "-c", command)
+	return e.ExecuteContext(context.Background(), command)
+}
+
+// ExecuteContext runs a command and returns its output.
+// The command (and any children it spawned) is killed when ctx is done.
+func (e *LocalExecutor) ExecuteContext(ctx context.Context, command string) (string, error) {
+	return e.ExecuteWithInputContext(ctx, command, nil)
+}
+
+// ExecuteWithInput runs a command with stdin and returns output
+func (e *LocalExecutor) ExecuteWithInput(command string, stdin io.Reader) (string, error) {
+	return e.ExecuteWithInputContext(context.Background(), command, stdin)
+}
+
+// ExecuteWithInputContext runs a command with stdin and returns output.
+// The command is killed when ctx is done.
+func (e *LocalExecutor) ExecuteWithInputContext(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	cmd := newShellCommand(ctx, command)
 	var stdout, stderr bytes.Buffer
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	err := cmd.Run()
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("command aborted: %w", ctxErr)
+		}
 		return "", fmt.Errorf("command failed: %w\nstderr: %s", err, stderr.String())
 	}
 
 	return stdout.String(), nil
 }
 
-// ExecuteWithInput runs a command with stdin and returns output
-func (e *LocalExecutor) ExecuteWithInput(command string, stdin io.Reader) (string, error) {
-	cmd := exec.Command("sh", "-c", command)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdin = stdin
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// ExecuteStream runs a command and delivers combined stdout/stderr to onLine
+// line by line as it is produced. The command is killed when ctx is done.
+func (e *LocalExecutor) ExecuteStream(ctx context.Context, command string, onLine func(line string)) error {
+	cmd := newShellCommand(ctx, command)
+	streamer := newLineStreamer(onLine)
+	cmd.Stdout = streamer
+	cmd.Stderr = streamer
 
 	err := cmd.Run()
+	_ = streamer.Close()
 	if err != nil {
-		return "", fmt.Errorf("command failed: %w\nstderr: %s", err, stderr.String())
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("command aborted: %w", ctxErr)
+		}
+		return fmt.Errorf("command failed: %w", err)
 	}
 
-	return stdout.String(), nil
+	return nil
+}
+
+// newShellCommand builds a "sh -c" command bound to ctx.
+// The shell runs in its own process group so cancellation kills the whole
+// pipeline rather than just the shell, and WaitDelay keeps Wait from
+// hanging on grandchildren that still hold stdout open.
+func newShellCommand(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 2 * time.Second
+	return cmd
 }
 
 // MongoExecute runs a MongoDB driver command
@@ -134,8 +236,26 @@ func (e *LocalExecutor) MongoExecute(host string, command string) (string, error
 	return "", nil
 }
 
+// MongoExecuteContext is the context-aware variant of MongoExecute
+func (e *LocalExecutor) MongoExecuteContext(ctx context.Context, host string, command string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return e.MongoExecute(host, command)
+}
+
 // Background starts a command in the background and returns its PID
 func (e *LocalExecutor) Background(command string) (int, error) {
+	return e.BackgroundContext(context.Background(), command)
+}
+
+// BackgroundContext starts a command in the background unless ctx is already done.
+// The started process is not tied to ctx and keeps running after it is cancelled.
+func (e *LocalExecutor) BackgroundContext(ctx context.Context, command string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	cmd := exec.Command("sh", "-c", command)
 
 	// Start the process in a new process group
@@ -152,6 +272,15 @@ func (e *LocalExecutor) Background(command string) (int, error) {
 
 // IsProcessRunning checks if a process with the given PID is running
 func (e *LocalExecutor) IsProcessRunning(pid int) (bool, error) {
+	return e.IsProcessRunningContext(context.Background(), pid)
+}
+
+// IsProcessRunningContext checks if a process is running unless ctx is already done
+func (e *LocalExecutor) IsProcessRunningContext(ctx context.Context, pid int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	process, err := os.FindProcess(pid)
 	if err != nil {
 		return false, nil
@@ -172,6 +301,15 @@ func (e *LocalExecutor) IsProcessRunning(pid int) (bool, error) {
 
 // KillProcess kills a process with the given PID
 func (e *LocalExecutor) KillProcess(pid int) error {
+	return e.KillProcessContext(context.Background(), pid)
+}
+
+// KillProcessContext kills a process unless ctx is already done
+func (e *LocalExecutor) KillProcessContext(ctx context.Context, pid int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	process, err := os.FindProcess(pid)
 	if err != nil {
 		return fmt.Errorf("failed to find process: %w", err)
@@ -182,6 +320,15 @@ func (e *LocalExecutor) KillProcess(pid int) error {
 
 // StopProcess sends SIGINT to a process for graceful shutdown
 func (e *LocalExecutor) StopProcess(pid int) error {
+	return e.StopProcessContext(context.Background(), pid)
+}
+
+// StopProcessContext sends SIGINT to a process unless ctx is already done
+func (e *LocalExecutor) StopProcessContext(ctx context.Context, pid int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	process, err := os.FindProcess(pid)
 	if err != nil {
 		return fmt.Errorf("failed to find process: %w", err)
@@ -192,17 +339,26 @@ func (e *LocalExecutor) StopProcess(pid int) error {
 
 // GetOSInfo returns information about the operating system
 func (e *LocalExecutor) GetOSInfo() (*OSInfo, error) {
+	return e.GetOSInfoContext(context.Background())
+}
+
+// GetOSInfoContext returns information about the operating system
+func (e *LocalExecutor) GetOSInfoContext(ctx context.Context) (*OSInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	var version string
 
 	// Get OS version
 	switch runtime.GOOS {
 	case "linux":
-		output, err := e.Execute("uname -r")
+		output, err := e.ExecuteContext(ctx, "uname -r")
 		if err == nil {
 			version = strings.TrimSpace(output)
 		}
 	case "darwin":
-		output, err := e.Execute("sw_vers -productVersion")
+		output, err := e.ExecuteContext(ctx, "sw_vers -productVersion")
 		if err == nil {
 			version = strings.TrimSpace(output)
 		}
@@ -217,6 +373,15 @@ func (e *LocalExecutor) GetOSInfo() (*OSInfo, error) {
 
 // GetDiskSpace returns available disk space in bytes for the given path
 func (e *LocalExecutor) GetDiskSpace(path string) (uint64, error) {
+	return e.GetDiskSpaceContext(context.Background(), path)
+}
+
+// GetDiskSpaceContext returns available disk space unless ctx is already done
+func (e *LocalExecutor) GetDiskSpaceContext(ctx context.Context, path string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	var stat unix.Statfs_t
 	if err := unix.Statfs(path, &stat); err != nil {
 		return 0, fmt.Errorf("failed to get disk space: %w", err)
@@ -230,10 +395,20 @@ func (e *LocalExecutor) GetDiskSpace(path string) (uint64, error) {
 // 1. Try to bind to the port (tests actual usability)
 // 2. Try to connect to the port (catches listening processes)
 func (e *LocalExecutor) CheckPortAvailable(port int) (bool, error) {
+	return e.CheckPortAvailableContext(context.Background(), port)
+}
+
+// CheckPortAvailableContext is the context-aware variant of CheckPortAvailable
+func (e *LocalExecutor) CheckPortAvailableContext(ctx context.Context, port int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	// Phase 1: Try to bind to the port
 	// This is the most reliable test - if we can't bind, we can't use it
 	addr := fmt.Sprintf("127.0.0.1:%d", port)
-	listener, err := net.Listen("tcp", addr)
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", addr)
 	if err != nil {
 		// Cannot bind - port is not available
 		// This catches ports in use, TIME_WAIT state, or reserved
@@ -246,7 +421,8 @@ func (e *LocalExecutor) CheckPortAvailable(port int) (bool, error) {
 	// Small delay to allow the socket to fully close
 	time.Sleep(10 * time.Millisecond)
 
-	conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+	dialer := net.Dialer{Timeout: 100 * time.Millisecond}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		// Connection failed - port is available
 		return true, nil
@@ -259,6 +435,15 @@ func (e *LocalExecutor) CheckPortAvailable(port int) (bool, error) {
 
 // UserExists checks if a user exists on the system
 func (e *LocalExecutor) UserExists(username string) (bool, error) {
+	return e.UserExistsContext(context.Background(), username)
+}
+
+// UserExistsContext checks if a user exists unless ctx is already done
+func (e *LocalExecutor) UserExistsContext(ctx context.Context, username string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	_, err := user.Lookup(username)
 	if err != nil {
 		var unknownUserError user.UnknownUserError
@@ -272,8 +457,13 @@ func (e *LocalExecutor) UserExists(username string) (bool, error) {
 
 // CheckConnectivity checks if the executor can perform operations
 func (e *LocalExecutor) CheckConnectivity() error {
+	return e.CheckConnectivityContext(context.Background())
+}
+
+// CheckConnectivityContext checks if the executor can perform operations
+func (e *LocalExecutor) CheckConnectivityContext(ctx context.Context) error {
 	// For local executor, just check if we can execute a simple command
-	_, err := e.Execute("echo test")
+	_, err := e.ExecuteContext(ctx, "echo test")
 	return err
 }
 
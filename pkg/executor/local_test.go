@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalExecutor_ExecuteContext(t *testing.T) {
+	exec := NewLocalExecutor()
+
+	output, err := exec.ExecuteContext(context.Background(), "echo hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", output)
+}
+
+func TestLocalExecutor_ExecuteContext_Cancelled(t *testing.T) {
+	exec := NewLocalExecutor()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := exec.ExecuteContext(ctx, "sleep 30 | cat")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline error, got %v", err)
+	assert.Less(t, time.Since(start), 10*time.Second, "cancelled command should return promptly")
+}
+
+func TestLocalExecutor_ContextVariants_RespectCancelledContext(t *testing.T) {
+	exec := NewLocalExecutor()
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, exec.CreateDirectoryContext(ctx, dir+"/sub", 0755), context.Canceled)
+	assert.ErrorIs(t, exec.UploadContentContext(ctx, []byte("x"), dir+"/file"), context.Canceled)
+	_, err := exec.FileExistsContext(ctx, dir)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = exec.BackgroundContext(ctx, "true")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = exec.CheckPortAvailableContext(ctx, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	exists, err := exec.FileExists(dir + "/file")
+	require.NoError(t, err)
+	assert.False(t, exists, "cancelled upload must not write the file")
+}
+
+func TestLocalExecutor_ExecuteStream(t *testing.T) {
+	exec := NewLocalExecutor()
+
+	var lines []string
+	err := exec.ExecuteStream(context.Background(), "echo one; echo two 1>&2; printf three", func(line string) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"one", "two", "three"}, lines)
+}
+
+func TestLocalExecutor_ExecuteStream_Failure(t *testing.T) {
+	exec := NewLocalExecutor()
+
+	var lines []string
+	err := exec.ExecuteStream(context.Background(), "echo partial; exit 3", func(line string) {
+		lines = append(lines, line)
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{"partial"}, lines)
+}
+
+func TestLocalExecutor_ExecuteStream_Cancelled(t *testing.T) {
+	exec := NewLocalExecutor()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := make(chan struct{}, 1)
+	go func() {
+		<-first
+		cancel()
+	}()
+
+	err := exec.ExecuteStream(ctx, "echo started; sleep 30", func(line string) {
+		select {
+		case first <- struct{}{}:
+		default:
+		}
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestExitCode(t *testing.T) {
+	exec := NewLocalExecutor()
+
+	_, err := exec.Execute("exit 3")
+	assert.Equal(t, 3, ExitCode(err))
+
+	_, err = exec.Execute("true")
+	assert.Equal(t, 0, ExitCode(err))
+
+	assert.Equal(t, -1, ExitCode(errors.New("connection refused")))
+}
@@ -0,0 +1,325 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// RecordingExecutor wraps another Executor and appends every call, its
+// arguments and its result to a Cassette. Pair with ReplayExecutor to turn a
+// real deployment into a deterministic test fixture.
+type RecordingExecutor struct {
+	inner    Executor
+	host     string
+	cassette *Cassette
+}
+
+// Ensure RecordingExecutor implements Executor interface
+var _ Executor = (*RecordingExecutor)(nil)
+
+// NewRecordingExecutor creates a RecordingExecutor for host that records into cassette
+func NewRecordingExecutor(inner Executor, host string, cassette *Cassette) *RecordingExecutor {
+	return &RecordingExecutor{
+		inner:    inner,
+		host:     host,
+		cassette: cassette,
+	}
+}
+
+// Cassette returns the cassette interactions are recorded into
+func (r *RecordingExecutor) Cassette() *Cassette {
+	return r.cassette
+}
+
+// Unwrap returns the wrapped executor
+func (r *RecordingExecutor) Unwrap() Executor {
+	return r.inner
+}
+
+// record appends a call and its outcome to the cassette
+func (r *RecordingExecutor) record(method string, args []string, result Interaction, err error) {
+	result.Host = r.host
+	result.Method = method
+	result.Args = args
+	result.Error = errorString(err)
+	r.cassette.Append(result)
+}
+
+// ========== File Operations ==========
+
+// CreateDirectory is CreateDirectoryContext with a background context
+func (r *RecordingExecutor) CreateDirectory(path string, mode os.FileMode) error {
+	return r.CreateDirectoryContext(context.Background(), path, mode)
+}
+
+// CreateDirectoryContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) CreateDirectoryContext(ctx context.Context, path string, mode os.FileMode) error {
+	err := r.inner.CreateDirectoryContext(ctx, path, mode)
+	r.record("create_directory", []string{path, fmt.Sprintf("%o", mode)}, Interaction{}, err)
+	return err
+}
+
+// UploadFile is UploadFileContext with a background context
+func (r *RecordingExecutor) UploadFile(localPath, remotePath string) error {
+	return r.UploadFileContext(context.Background(), localPath, remotePath)
+}
+
+// UploadFileContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) UploadFileContext(ctx context.Context, localPath, remotePath string) error {
+	err := r.inner.UploadFileContext(ctx, localPath, remotePath)
+	r.record("upload_file", []string{localPath, remotePath}, Interaction{}, err)
+	return err
+}
+
+// UploadContent is UploadContentContext with a background context
+func (r *RecordingExecutor) UploadContent(content []byte, remotePath string) error {
+	return r.UploadContentContext(context.Background(), content, remotePath)
+}
+
+// UploadContentContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) UploadContentContext(ctx context.Context, content []byte, remotePath string) error {
+	err := r.inner.UploadContentContext(ctx, content, remotePath)
+	r.record("upload_content", []string{contentDigest(content), remotePath}, Interaction{}, err)
+	return err
+}
+
+// DownloadFile is DownloadFileContext with a background context
+func (r *RecordingExecutor) DownloadFile(remotePath, localPath string) error {
+	return r.DownloadFileContext(context.Background(), remotePath, localPath)
+}
+
+// DownloadFileContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) DownloadFileContext(ctx context.Context, remotePath, localPath string) error {
+	err := r.inner.DownloadFileContext(ctx, remotePath, localPath)
+	result := Interaction{}
+	if err == nil {
+		// Keep the payload so replay can recreate the local file
+		if data, readErr := os.ReadFile(localPath); readErr == nil {
+			result.Output = string(data)
+		}
+	}
+	r.record("download_file", []string{remotePath, localPath}, result, err)
+	return err
+}
+
+// FileExists is FileExistsContext with a background context
+func (r *RecordingExecutor) FileExists(path string) (bool, error) {
+	return r.FileExistsContext(context.Background(), path)
+}
+
+// FileExistsContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	exists, err := r.inner.FileExistsContext(ctx, path)
+	r.record("file_exists", []string{path}, Interaction{Bool: exists}, err)
+	return exists, err
+}
+
+// RemoveFile is RemoveFileContext with a background context
+func (r *RecordingExecutor) RemoveFile(path string) error {
+	return r.RemoveFileContext(context.Background(), path)
+}
+
+// RemoveFileContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) RemoveFileContext(ctx context.Context, path string) error {
+	err := r.inner.RemoveFileContext(ctx, path)
+	r.record("remove_file", []string{path}, Interaction{}, err)
+	return err
+}
+
+// RemoveDirectory is RemoveDirectoryContext with a background context
+func (r *RecordingExecutor) RemoveDirectory(path string) error {
+	return r.RemoveDirectoryContext(context.Background(), path)
+}
+
+// RemoveDirectoryContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) RemoveDirectoryContext(ctx context.Context, path string) error {
+	err := r.inner.RemoveDirectoryContext(ctx, path)
+	r.record("remove_directory", []string{path}, Interaction{}, err)
+	return err
+}
+
+// ========== Command Execution ==========
+
+// Execute is ExecuteContext with a background context
+func (r *RecordingExecutor) Execute(command string) (string, error) {
+	return r.ExecuteContext(context.Background(), command)
+}
+
+// ExecuteContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) ExecuteContext(ctx context.Context, command string) (string, error) {
+	output, err := r.inner.ExecuteContext(ctx, command)
+	r.record("execute", []string{command}, Interaction{Output: output}, err)
+	return output, err
+}
+
+// ExecuteWithInput is ExecuteWithInputContext with a background context
+func (r *RecordingExecutor) ExecuteWithInput(command string, stdin io.Reader) (string, error) {
+	return r.ExecuteWithInputContext(context.Background(), command, stdin)
+}
+
+// ExecuteWithInputContext records a digest of stdin alongside the command
+func (r *RecordingExecutor) ExecuteWithInputContext(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	var input []byte
+	if stdin != nil {
+		var err error
+		input, err = io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+
+	output, err := r.inner.ExecuteWithInputContext(ctx, command, bytes.NewReader(input))
+	r.record("execute_with_input", []string{command, contentDigest(input)}, Interaction{Output: output}, err)
+	return output, err
+}
+
+// ExecuteStream calls the wrapped executor and records the call
+func (r *RecordingExecutor) ExecuteStream(ctx context.Context, command string, onLine func(line string)) error {
+	var lines []string
+	err := r.inner.ExecuteStream(ctx, command, func(line string) {
+		lines = append(lines, line)
+		if onLine != nil {
+			onLine(line)
+		}
+	})
+	r.record("execute_stream", []string{command}, Interaction{Lines: lines}, err)
+	return err
+}
+
+// Background is BackgroundContext with a background context
+func (r *RecordingExecutor) Background(command string) (int, error) {
+	return r.BackgroundContext(context.Background(), command)
+}
+
+// BackgroundContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) BackgroundContext(ctx context.Context, command string) (int, error) {
+	pid, err := r.inner.BackgroundContext(ctx, command)
+	r.record("background", []string{command}, Interaction{Int: pid}, err)
+	return pid, err
+}
+
+// ========== MongoDB Operations ==========
+
+// MongoExecute is MongoExecuteContext with a background context
+func (r *RecordingExecutor) MongoExecute(host string, command string) (string, error) {
+	return r.MongoExecuteContext(context.Background(), host, command)
+}
+
+// MongoExecuteContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) MongoExecuteContext(ctx context.Context, host string, command string) (string, error) {
+	output, err := r.inner.MongoExecuteContext(ctx, host, command)
+	r.record("mongo_execute", []string{host, command}, Interaction{Output: output}, err)
+	return output, err
+}
+
+// ========== Process Management ==========
+
+// IsProcessRunning is IsProcessRunningContext with a background context
+func (r *RecordingExecutor) IsProcessRunning(pid int) (bool, error) {
+	return r.IsProcessRunningContext(context.Background(), pid)
+}
+
+// IsProcessRunningContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) IsProcessRunningContext(ctx context.Context, pid int) (bool, error) {
+	running, err := r.inner.IsProcessRunningContext(ctx, pid)
+	r.record("is_process_running", []string{strconv.Itoa(pid)}, Interaction{Bool: running}, err)
+	return running, err
+}
+
+// KillProcess is KillProcessContext with a background context
+func (r *RecordingExecutor) KillProcess(pid int) error {
+	return r.KillProcessContext(context.Background(), pid)
+}
+
+// KillProcessContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) KillProcessContext(ctx context.Context, pid int) error {
+	err := r.inner.KillProcessContext(ctx, pid)
+	r.record("kill_process", []string{strconv.Itoa(pid)}, Interaction{}, err)
+	return err
+}
+
+// StopProcess is StopProcessContext with a background context
+func (r *RecordingExecutor) StopProcess(pid int) error {
+	return r.StopProcessContext(context.Background(), pid)
+}
+
+// StopProcessContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) StopProcessContext(ctx context.Context, pid int) error {
+	err := r.inner.StopProcessContext(ctx, pid)
+	r.record("stop_process", []string{strconv.Itoa(pid)}, Interaction{}, err)
+	return err
+}
+
+// ========== System Information ==========
+
+// GetOSInfo is GetOSInfoContext with a background context
+func (r *RecordingExecutor) GetOSInfo() (*OSInfo, error) {
+	return r.GetOSInfoContext(context.Background())
+}
+
+// GetOSInfoContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) GetOSInfoContext(ctx context.Context) (*OSInfo, error) {
+	info, err := r.inner.GetOSInfoContext(ctx)
+	r.record("get_os_info", nil, Interaction{OSInfo: info}, err)
+	return info, err
+}
+
+// GetDiskSpace is GetDiskSpaceContext with a background context
+func (r *RecordingExecutor) GetDiskSpace(path string) (uint64, error) {
+	return r.GetDiskSpaceContext(context.Background(), path)
+}
+
+// GetDiskSpaceContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) GetDiskSpaceContext(ctx context.Context, path string) (uint64, error) {
+	avail, err := r.inner.GetDiskSpaceContext(ctx, path)
+	r.record("get_disk_space", []string{path}, Interaction{Uint: avail}, err)
+	return avail, err
+}
+
+// CheckPortAvailable is CheckPortAvailableContext with a background context
+func (r *RecordingExecutor) CheckPortAvailable(port int) (bool, error) {
+	return r.CheckPortAvailableContext(context.Background(), port)
+}
+
+// CheckPortAvailableContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) CheckPortAvailableContext(ctx context.Context, port int) (bool, error) {
+	available, err := r.inner.CheckPortAvailableContext(ctx, port)
+	r.record("check_port_available", []string{strconv.Itoa(port)}, Interaction{Bool: available}, err)
+	return available, err
+}
+
+// UserExists is UserExistsContext with a background context
+func (r *RecordingExecutor) UserExists(username string) (bool, error) {
+	return r.UserExistsContext(context.Background(), username)
+}
+
+// UserExistsContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) UserExistsContext(ctx context.Context, username string) (bool, error) {
+	exists, err := r.inner.UserExistsContext(ctx, username)
+	r.record("user_exists", []string{username}, Interaction{Bool: exists}, err)
+	return exists, err
+}
+
+// ========== Connection Management ==========
+
+// CheckConnectivity is CheckConnectivityContext with a background context
+func (r *RecordingExecutor) CheckConnectivity() error {
+	return r.CheckConnectivityContext(context.Background())
+}
+
+// CheckConnectivityContext calls the wrapped executor and records the call
+func (r *RecordingExecutor) CheckConnectivityContext(ctx context.Context) error {
+	err := r.inner.CheckConnectivityContext(ctx)
+	r.record("check_connectivity", nil, Interaction{}, err)
+	return err
+}
+
+// Close closes the wrapped executor. The cassette is not saved automatically
+// because it is usually shared between hosts; call Cassette.Save when done.
+func (r *RecordingExecutor) Close() error {
+	return r.inner.Close()
+}
@@ -0,0 +1,386 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// ErrNoInteraction is returned by ReplayExecutor when a call has no matching
+// recorded interaction
+var ErrNoInteraction = errors.New("no recorded interaction")
+
+// ReplayExecutor serves results recorded by RecordingExecutor instead of
+// touching the filesystem, processes or the network.
+//
+// Calls are matched on host, method and arguments. Repeated identical calls
+// consume recorded interactions in order; once they are used up the last one
+// keeps being returned, so polling loops (wait for port, wait for primary)
+// replay deterministically regardless of how many iterations they take.
+type ReplayExecutor struct {
+	host string
+
+	mu       sync.Mutex
+	pending  map[string][]Interaction
+	last     map[string]Interaction
+	consumed int
+	total    int
+}
+
+// Ensure ReplayExecutor implements Executor interface
+var _ Executor = (*ReplayExecutor)(nil)
+
+// NewReplayExecutor creates a ReplayExecutor serving cassette's interactions for host
+func NewReplayExecutor(cassette *Cassette, host string) *ReplayExecutor {
+	p := &ReplayExecutor{
+		host:    host,
+		pending: make(map[string][]Interaction),
+		last:    make(map[string]Interaction),
+	}
+
+	cassette.mu.Lock()
+	defer cassette.mu.Unlock()
+	for _, i := range cassette.Interactions {
+		if i.Host != host {
+			continue
+		}
+		k := i.key()
+		p.pending[k] = append(p.pending[k], i)
+		p.total++
+	}
+
+	return p
+}
+
+// Remaining returns how many recorded interactions have not been replayed yet.
+// Tests can assert this is zero to prove the code under test made every call
+// the recording made.
+func (p *ReplayExecutor) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.total - p.consumed
+}
+
+// next returns the recorded interaction for a call
+func (p *ReplayExecutor) next(ctx context.Context, method string, args []string) (Interaction, error) {
+	if err := ctx.Err(); err != nil {
+		return Interaction{}, err
+	}
+
+	call := Interaction{Host: p.host, Method: method, Args: args}
+	k := call.key()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if queue := p.pending[k]; len(queue) > 0 {
+		i := queue[0]
+		p.pending[k] = queue[1:]
+		p.last[k] = i
+		p.consumed++
+		return i, nil
+	}
+
+	if i, ok := p.last[k]; ok {
+		return i, nil
+	}
+
+	return Interaction{}, fmt.Errorf("%w: host=%s method=%s args=%q", ErrNoInteraction, p.host, method, args)
+}
+
+// replay looks up a call and converts its recorded error
+func (p *ReplayExecutor) replay(ctx context.Context, method string, args []string) (Interaction, error) {
+	i, err := p.next(ctx, method, args)
+	if err != nil {
+		return i, err
+	}
+	if i.Error != "" {
+		return i, errors.New(i.Error)
+	}
+	return i, nil
+}
+
+// ========== File Operations ==========
+
+// CreateDirectory is CreateDirectoryContext with a background context
+func (p *ReplayExecutor) CreateDirectory(path string, mode os.FileMode) error {
+	return p.CreateDirectoryContext(context.Background(), path, mode)
+}
+
+// CreateDirectoryContext returns the recorded result of CreateDirectory
+func (p *ReplayExecutor) CreateDirectoryContext(ctx context.Context, path string, mode os.FileMode) error {
+	_, err := p.replay(ctx, "create_directory", []string{path, fmt.Sprintf("%o", mode)})
+	return err
+}
+
+// UploadFile is UploadFileContext with a background context
+func (p *ReplayExecutor) UploadFile(localPath, remotePath string) error {
+	return p.UploadFileContext(context.Background(), localPath, remotePath)
+}
+
+// UploadFileContext returns the recorded result of UploadFile
+func (p *ReplayExecutor) UploadFileContext(ctx context.Context, localPath, remotePath string) error {
+	_, err := p.replay(ctx, "upload_file", []string{localPath, remotePath})
+	return err
+}
+
+// UploadContent is UploadContentContext with a background context
+func (p *ReplayExecutor) UploadContent(content []byte, remotePath string) error {
+	return p.UploadContentContext(context.Background(), content, remotePath)
+}
+
+// UploadContentContext returns the recorded result of UploadContent
+func (p *ReplayExecutor) UploadContentContext(ctx context.Context, content []byte, remotePath string) error {
+	_, err := p.replay(ctx, "upload_content", []string{contentDigest(content), remotePath})
+	return err
+}
+
+// DownloadFile is DownloadFileContext with a background context
+func (p *ReplayExecutor) DownloadFile(remotePath, localPath string) error {
+	return p.DownloadFileContext(context.Background(), remotePath, localPath)
+}
+
+// DownloadFileContext recreates the recorded payload at localPath
+func (p *ReplayExecutor) DownloadFileContext(ctx context.Context, remotePath, localPath string) error {
+	i, err := p.replay(ctx, "download_file", []string{remotePath, localPath})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create local parent directory: %w", err)
+	}
+	if err := os.WriteFile(localPath, []byte(i.Output), 0644); err != nil {
+		return fmt.Errorf("failed to write local file: %w", err)
+	}
+
+	return nil
+}
+
+// FileExists is FileExistsContext with a background context
+func (p *ReplayExecutor) FileExists(path string) (bool, error) {
+	return p.FileExistsContext(context.Background(), path)
+}
+
+// FileExistsContext returns the recorded result of FileExists
+func (p *ReplayExecutor) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	i, err := p.replay(ctx, "file_exists", []string{path})
+	return i.Bool, err
+}
+
+// RemoveFile is RemoveFileContext with a background context
+func (p *ReplayExecutor) RemoveFile(path string) error {
+	return p.RemoveFileContext(context.Background(), path)
+}
+
+// RemoveFileContext returns the recorded result of RemoveFile
+func (p *ReplayExecutor) RemoveFileContext(ctx context.Context, path string) error {
+	_, err := p.replay(ctx, "remove_file", []string{path})
+	return err
+}
+
+// RemoveDirectory is RemoveDirectoryContext with a background context
+func (p *ReplayExecutor) RemoveDirectory(path string) error {
+	return p.RemoveDirectoryContext(context.Background(), path)
+}
+
+// RemoveDirectoryContext returns the recorded result of RemoveDirectory
+func (p *ReplayExecutor) RemoveDirectoryContext(ctx context.Context, path string) error {
+	_, err := p.replay(ctx, "remove_directory", []string{path})
+	return err
+}
+
+// ========== Command Execution ==========
+
+// Execute is ExecuteContext with a background context
+func (p *ReplayExecutor) Execute(command string) (string, error) {
+	return p.ExecuteContext(context.Background(), command)
+}
+
+// ExecuteContext returns the recorded result of Execute
+func (p *ReplayExecutor) ExecuteContext(ctx context.Context, command string) (string, error) {
+	i, err := p.replay(ctx, "execute", []string{command})
+	if err != nil {
+		return "", err
+	}
+	return i.Output, nil
+}
+
+// ExecuteWithInput is ExecuteWithInputContext with a background context
+func (p *ReplayExecutor) ExecuteWithInput(command string, stdin io.Reader) (string, error) {
+	return p.ExecuteWithInputContext(context.Background(), command, stdin)
+}
+
+// ExecuteWithInputContext returns the recorded result of ExecuteWithInput
+func (p *ReplayExecutor) ExecuteWithInputContext(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	var input []byte
+	if stdin != nil {
+		var err error
+		input, err = io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+
+	i, err := p.replay(ctx, "execute_with_input", []string{command, contentDigest(input)})
+	if err != nil {
+		return "", err
+	}
+	return i.Output, nil
+}
+
+// ExecuteStream delivers the recorded lines to onLine, then the recorded error
+func (p *ReplayExecutor) ExecuteStream(ctx context.Context, command string, onLine func(line string)) error {
+	i, err := p.next(ctx, "execute_stream", []string{command})
+	if err != nil {
+		return err
+	}
+
+	if onLine != nil {
+		for _, line := range i.Lines {
+			onLine(line)
+		}
+	}
+
+	if i.Error != "" {
+		return errors.New(i.Error)
+	}
+	return nil
+}
+
+// Background is BackgroundContext with a background context
+func (p *ReplayExecutor) Background(command string) (int, error) {
+	return p.BackgroundContext(context.Background(), command)
+}
+
+// BackgroundContext returns the recorded result of Background
+func (p *ReplayExecutor) BackgroundContext(ctx context.Context, command string) (int, error) {
+	i, err := p.replay(ctx, "background", []string{command})
+	if err != nil {
+		return 0, err
+	}
+	return i.Int, nil
+}
+
+// ========== MongoDB Operations ==========
+
+// MongoExecute is MongoExecuteContext with a background context
+func (p *ReplayExecutor) MongoExecute(host string, command string) (string, error) {
+	return p.MongoExecuteContext(context.Background(), host, command)
+}
+
+// MongoExecuteContext returns the recorded result of MongoExecute
+func (p *ReplayExecutor) MongoExecuteContext(ctx context.Context, host string, command string) (string, error) {
+	i, err := p.replay(ctx, "mongo_execute", []string{host, command})
+	if err != nil {
+		return "", err
+	}
+	return i.Output, nil
+}
+
+// ========== Process Management ==========
+
+// IsProcessRunning is IsProcessRunningContext with a background context
+func (p *ReplayExecutor) IsProcessRunning(pid int) (bool, error) {
+	return p.IsProcessRunningContext(context.Background(), pid)
+}
+
+// IsProcessRunningContext returns the recorded result of IsProcessRunning
+func (p *ReplayExecutor) IsProcessRunningContext(ctx context.Context, pid int) (bool, error) {
+	i, err := p.replay(ctx, "is_process_running", []string{strconv.Itoa(pid)})
+	return i.Bool, err
+}
+
+// KillProcess is KillProcessContext with a background context
+func (p *ReplayExecutor) KillProcess(pid int) error {
+	return p.KillProcessContext(context.Background(), pid)
+}
+
+// KillProcessContext returns the recorded result of KillProcess
+func (p *ReplayExecutor) KillProcessContext(ctx context.Context, pid int) error {
+	_, err := p.replay(ctx, "kill_process", []string{strconv.Itoa(pid)})
+	return err
+}
+
+// StopProcess is StopProcessContext with a background context
+func (p *ReplayExecutor) StopProcess(pid int) error {
+	return p.StopProcessContext(context.Background(), pid)
+}
+
+// StopProcessContext returns the recorded result of StopProcess
+func (p *ReplayExecutor) StopProcessContext(ctx context.Context, pid int) error {
+	_, err := p.replay(ctx, "stop_process", []string{strconv.Itoa(pid)})
+	return err
+}
+
+// ========== System Information ==========
+
+// GetOSInfo is GetOSInfoContext with a background context
+func (p *ReplayExecutor) GetOSInfo() (*OSInfo, error) {
+	return p.GetOSInfoContext(context.Background())
+}
+
+// GetOSInfoContext returns the recorded result of GetOSInfo
+func (p *ReplayExecutor) GetOSInfoContext(ctx context.Context) (*OSInfo, error) {
+	i, err := p.replay(ctx, "get_os_info", nil)
+	if err != nil {
+		return nil, err
+	}
+	return i.OSInfo, nil
+}
+
+// GetDiskSpace is GetDiskSpaceContext with a background context
+func (p *ReplayExecutor) GetDiskSpace(path string) (uint64, error) {
+	return p.GetDiskSpaceContext(context.Background(), path)
+}
+
+// GetDiskSpaceContext returns the recorded result of GetDiskSpace
+func (p *ReplayExecutor) GetDiskSpaceContext(ctx context.Context, path string) (uint64, error) {
+	i, err := p.replay(ctx, "get_disk_space", []string{path})
+	return i.Uint, err
+}
+
+// CheckPortAvailable is CheckPortAvailableContext with a background context
+func (p *ReplayExecutor) CheckPortAvailable(port int) (bool, error) {
+	return p.CheckPortAvailableContext(context.Background(), port)
+}
+
+// CheckPortAvailableContext returns the recorded result of CheckPortAvailable
+func (p *ReplayExecutor) CheckPortAvailableContext(ctx context.Context, port int) (bool, error) {
+	i, err := p.replay(ctx, "check_port_available", []string{strconv.Itoa(port)})
+	return i.Bool, err
+}
+
+// UserExists is UserExistsContext with a background context
+func (p *ReplayExecutor) UserExists(username string) (bool, error) {
+	return p.UserExistsContext(context.Background(), username)
+}
+
+// UserExistsContext returns the recorded result of UserExists
+func (p *ReplayExecutor) UserExistsContext(ctx context.Context, username string) (bool, error) {
+	i, err := p.replay(ctx, "user_exists", []string{username})
+	return i.Bool, err
+}
+
+// ========== Connection Management ==========
+
+// CheckConnectivity is CheckConnectivityContext with a background context
+func (p *ReplayExecutor) CheckConnectivity() error {
+	return p.CheckConnectivityContext(context.Background())
+}
+
+// CheckConnectivityContext returns the recorded result of CheckConnectivity
+func (p *ReplayExecutor) CheckConnectivityContext(ctx context.Context) error {
+	_, err := p.replay(ctx, "check_connectivity", nil)
+	return err
+}
+
+// Close is a no-op; replay holds no resources
+func (p *ReplayExecutor) Close() error {
+	return nil
+}
@@ -0,0 +1,29 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// UploadSecret writes content to dest on exec's host with mode, in a
+// directory readable by the owner only
+func UploadSecret(ctx context.Context, exec Executor, content []byte, dest string, mode os.FileMode) error {
+	if err := exec.CreateDirectoryContext(ctx, filepath.Dir(dest), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+	}
+	// A previous file may be read-only and cannot be overwritten in place
+	if exists, _ := exec.FileExistsContext(ctx, dest); exists {
+		if err := exec.RemoveFileContext(ctx, dest); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", dest, err)
+		}
+	}
+	if err := exec.UploadContentContext(ctx, content, dest); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", dest, err)
+	}
+	if _, err := exec.ExecuteContext(ctx, fmt.Sprintf("chmod %o %s", mode, ShellQuote(dest))); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", dest, err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadSecret_ReplacesReadOnlyFile(t *testing.T) {
+	exec := NewLocalExecutor()
+	dest := filepath.Join(t.TempDir(), "tls", "node.pem")
+
+	require.NoError(t, UploadSecret(context.Background(), exec, []byte("first"), dest, 0400))
+	require.NoError(t, UploadSecret(context.Background(), exec, []byte("second"), dest, 0400))
+
+	content, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+
+	info, err := os.Stat(dest)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0400), info.Mode().Perm())
+
+	dir, err := os.Stat(filepath.Dir(dest))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), dir.Mode().Perm())
+}
@@ -2,6 +2,7 @@ package executor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net"
@@ -32,6 +33,9 @@ type SSHExecutor struct {
 	agentConn net.Conn // Keep agent connection alive for the lifetime of the executor
 }
 
+// Ensure SSHExecutor implements Executor interface
+var _ Executor = (*SSHExecutor)(nil)
+
 // NewSSHExecutor creates a new SSH executor and establishes connection
 func NewSSHExecutor(config SSHConfig) (*SSHExecutor, error) {
 	// Set defaults
@@ -96,7 +100,7 @@ func NewSSHExecutor(config SSHConfig) (*SSHExecutor, error) {
 	}
 
 	// Connect to SSH server
-	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
 	client, err := ssh.Dial("tcp", addr, sshConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to SSH server at %s: %w", addr, err)
@@ -113,8 +117,13 @@ func NewSSHExecutor(config SSHConfig) (*SSHExecutor, error) {
 
 // CreateDirectory creates a directory with the specified permissions
 func (e *SSHExecutor) CreateDirectory(path string, mode os.FileMode) error {
+	return e.CreateDirectoryContext(context.Background(), path, mode)
+}
+
+// CreateDirectoryContext creates a directory with the specified permissions
+func (e *SSHExecutor) CreateDirectoryContext(ctx context.Context, path string, mode os.FileMode) error {
 	// First check if directory already exists
-	exists, err := e.FileExists(path)
+	exists, err := e.FileExistsContext(ctx, path)
 	if err != nil {
 		return fmt.Errorf("failed to check if directory exists: %w", err)
 	}
@@ -126,39 +135,47 @@ func (e *SSHExecutor) CreateDirectory(path string, mode os.FileMode) error {
 
 	// Create directory and set permissions only if it didn't exist
 	cmd := fmt.Sprintf("mkdir -p %s && chmod %o %s", path, mode, path)
-	_, err = e.Execute(cmd)
+	_, err = e.ExecuteContext(ctx, cmd)
 	return err
 }
 
 // UploadFile copies a file from localPath to remotePath
 func (e *SSHExecutor) UploadFile(localPath, remotePath string) error {
-	// Read local file
-	data, err := os.ReadFile(localPath)
+	return e.UploadFileContext(context.Background(), localPath, remotePath)
+}
+
+// UploadFileContext copies a file from localPath to remotePath, streaming
+// it over the session's stdin
+func (e *SSHExecutor) UploadFileContext(ctx context.Context, localPath, remotePath string) error {
+	f, err := os.Open(localPath)
 	if err != nil {
 		return fmt.Errorf("failed to read local file: %w", err)
 	}
+	defer func() { _ = f.Close() }()
 
-	return e.UploadContent(data, remotePath)
+	return e.upload(ctx, f, remotePath)
 }
 
 // UploadContent writes content to a file at remotePath
 func (e *SSHExecutor) UploadContent(content []byte, remotePath string) error {
+	return e.UploadContentContext(context.Background(), content, remotePath)
+}
+
+// UploadContentContext writes content to a file at remotePath
+func (e *SSHExecutor) UploadContentContext(ctx context.Context, content []byte, remotePath string) error {
+	return e.upload(ctx, bytes.NewReader(content), remotePath)
+}
+
+// upload writes r to remotePath byte for byte. The content goes over stdin
+// rather than into the command, so binary data and quotes pass unchanged.
+func (e *SSHExecutor) upload(ctx context.Context, r io.Reader, remotePath string) error {
 	// Ensure parent directory exists
 	dir := filepath.Dir(remotePath)
-	if err := e.CreateDirectory(dir, 0755); err != nil {
+	if err := e.CreateDirectoryContext(ctx, dir, 0755); err != nil {
 		return fmt.Errorf("failed to create parent directory: %w", err)
 	}
 
-	// Use cat with heredoc to write content
-	// Escape single quotes in content
-	escapedContent := string(content)
-	escapedContent = strings.ReplaceAll(escapedContent, "'", "'\\''")
-
-	// Use heredoc without adding trailing newline
-	// The -n flag in the EOF marker prevents adding a newline after content
-	cmd := fmt.Sprintf("cat > %s << 'MUPEOF'\n%sMUPEOF", remotePath, escapedContent)
-	_, err := e.Execute(cmd)
-	if err != nil {
+	if _, err := e.ExecuteWithInputContext(ctx, "cat > "+ShellQuote(remotePath), r); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -167,8 +184,13 @@ func (e *SSHExecutor) UploadContent(content []byte, remotePath string) error {
 
 // DownloadFile copies a file from remotePath to localPath
 func (e *SSHExecutor) DownloadFile(remotePath, localPath string) error {
+	return e.DownloadFileContext(context.Background(), remotePath, localPath)
+}
+
+// DownloadFileContext copies a file from remotePath to localPath
+func (e *SSHExecutor) DownloadFileContext(ctx context.Context, remotePath, localPath string) error {
 	// Read remote file
-	output, err := e.Execute(fmt.Sprintf("cat %s", remotePath))
+	output, err := e.ExecuteContext(ctx, fmt.Sprintf("cat %s", remotePath))
 	if err != nil {
 		return fmt.Errorf("failed to read remote file: %w", err)
 	}
@@ -189,8 +211,16 @@ func (e *SSHExecutor) DownloadFile(remotePath, localPath string) error {
 
 // FileExists checks if a file exists
 func (e *SSHExecutor) FileExists(path string) (bool, error) {
-	_, err := e.Execute(fmt.Sprintf("test -e %s", path))
+	return e.FileExistsContext(context.Background(), path)
+}
+
+// FileExistsContext checks if a file exists
+func (e *SSHExecutor) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	_, err := e.ExecuteContext(ctx, fmt.Sprintf("test -e %s", path))
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return false, ctxErr
+		}
 		// Command failed - file doesn't exist
 		return false, nil
 	}
@@ -199,55 +229,107 @@ func (e *SSHExecutor) FileExists(path string) (bool, error) {
 
 // RemoveFile removes a file
 func (e *SSHExecutor) RemoveFile(path string) error {
-	_, err := e.Execute(fmt.Sprintf("rm -f %s", path))
+	return e.RemoveFileContext(context.Background(), path)
+}
+
+// RemoveFileContext removes a file
+func (e *SSHExecutor) RemoveFileContext(ctx context.Context, path string) error {
+	_, err := e.ExecuteContext(ctx, fmt.Sprintf("rm -f %s", path))
 	return err
 }
 
 // RemoveDirectory removes a directory and all its contents
 func (e *SSHExecutor) RemoveDirectory(path string) error {
-	_, err := e.Execute(fmt.Sprintf("rm -rf %s", path))
+	return e.RemoveDirectoryContext(context.Background(), path)
+}
+
+// RemoveDirectoryContext removes a directory and all its contents
+func (e *SSHExecutor) RemoveDirectoryContext(ctx context.Context, path string) error {
+	_, err := e.ExecuteContext(ctx, fmt.Sprintf("rm -rf %s", path))
 	return err
 }
 
 // Execute runs a command and returns its output
 func (e *SSHExecutor) Execute(command string) (string, error) {
-	session, err := e.client.NewSession()
-	if err != nil {
-		return "", fmt.Errorf("failed to create SSH session: %w", err)
-	}
-	defer func() { _ = session.Close() }()
+	return e.ExecuteContext(context.Background(), command)
+}
 
-	var stdout, stderr bytes.Buffer
-	session.Stdout = &stdout
-	session.Stderr = &stderr
+// ExecuteContext runs a command and returns its output.
+// The remote session is signalled and closed when ctx is done.
+func (e *SSHExecutor) ExecuteContext(ctx context.Context, command string) (string, error) {
+	return e.ExecuteWithInputContext(ctx, command, nil)
+}
 
-	err = session.Run(command)
+// ExecuteWithInput runs a command with stdin and returns output
+func (e *SSHExecutor) ExecuteWithInput(command string, stdin io.Reader) (string, error) {
+	return e.ExecuteWithInputContext(context.Background(), command, stdin)
+}
+
+// ExecuteWithInputContext runs a command with stdin and returns output
+func (e *SSHExecutor) ExecuteWithInputContext(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := e.runSession(ctx, command, stdin, &stdout, &stderr)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("command aborted: %w", ctxErr)
+		}
 		return "", fmt.Errorf("command failed: %w\nstderr: %s", err, stderr.String())
 	}
 
 	return stdout.String(), nil
 }
 
-// ExecuteWithInput runs a command with stdin and returns output
-func (e *SSHExecutor) ExecuteWithInput(command string, stdin io.Reader) (string, error) {
+// ExecuteStream runs a command and delivers combined stdout/stderr to onLine
+// line by line as it is produced
+func (e *SSHExecutor) ExecuteStream(ctx context.Context, command string, onLine func(line string)) error {
+	streamer := newLineStreamer(onLine)
+	err := e.runSession(ctx, command, nil, streamer, streamer)
+	_ = streamer.Close()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("command aborted: %w", ctxErr)
+		}
+		return fmt.Errorf("command failed: %w", err)
+	}
+
+	return nil
+}
+
+// runSession runs command in a new SSH session bound to ctx.
+// On cancellation the remote process is sent SIGKILL (servers that ignore
+// signals still see the channel close, which delivers SIGPIPE/SIGHUP).
+func (e *SSHExecutor) runSession(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	session, err := e.client.NewSession()
 	if err != nil {
-		return "", fmt.Errorf("failed to create SSH session: %w", err)
+		return fmt.Errorf("failed to create SSH session: %w", err)
 	}
 	defer func() { _ = session.Close() }()
 
-	var stdout, stderr bytes.Buffer
-	session.Stdin = stdin
-	session.Stdout = &stdout
-	session.Stderr = &stderr
+	if stdin != nil {
+		session.Stdin = stdin
+	}
+	session.Stdout = stdout
+	session.Stderr = stderr
 
-	err = session.Run(command)
-	if err != nil {
-		return "", fmt.Errorf("command failed: %w\nstderr: %s", err, stderr.String())
+	if err := session.Start(command); err != nil {
+		return err
 	}
 
-	return stdout.String(), nil
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+		return ctx.Err()
+	}
 }
 
 // MongoExecute runs a MongoDB driver command
@@ -258,13 +340,27 @@ func (e *SSHExecutor) MongoExecute(host string, command string) (string, error)
 	return "", nil
 }
 
+// MongoExecuteContext is the context-aware variant of MongoExecute
+func (e *SSHExecutor) MongoExecuteContext(ctx context.Context, host string, command string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return e.MongoExecute(host, command)
+}
+
 // Background starts a command in the background and returns its PID
 func (e *SSHExecutor) Background(command string) (int, error) {
+	return e.BackgroundContext(context.Background(), command)
+}
+
+// BackgroundContext starts a command in the background and returns its PID.
+// ctx bounds only the launch; the started process outlives it.
+func (e *SSHExecutor) BackgroundContext(ctx context.Context, command string) (int, error) {
 	// Use nohup and capture PID
 	// The command format ensures the process stays alive after SSH session closes
 	cmd := fmt.Sprintf("nohup %s > /dev/null 2>&1 & echo $!", command)
 
-	output, err := e.Execute(cmd)
+	output, err := e.ExecuteContext(ctx, cmd)
 	if err != nil {
 		return 0, fmt.Errorf("failed to start background process: %w", err)
 	}
@@ -281,9 +377,17 @@ func (e *SSHExecutor) Background(command string) (int, error) {
 
 // IsProcessRunning checks if a process with the given PID is running
 func (e *SSHExecutor) IsProcessRunning(pid int) (bool, error) {
+	return e.IsProcessRunningContext(context.Background(), pid)
+}
+
+// IsProcessRunningContext checks if a process with the given PID is running
+func (e *SSHExecutor) IsProcessRunningContext(ctx context.Context, pid int) (bool, error) {
 	// Use kill -0 to check if process exists
-	_, err := e.Execute(fmt.Sprintf("kill -0 %d", pid))
+	_, err := e.ExecuteContext(ctx, fmt.Sprintf("kill -0 %d", pid))
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return false, ctxErr
+		}
 		// Command failed - process doesn't exist
 		return false, nil
 	}
@@ -292,27 +396,42 @@ func (e *SSHExecutor) IsProcessRunning(pid int) (bool, error) {
 
 // KillProcess kills a process with the given PID
 func (e *SSHExecutor) KillProcess(pid int) error {
-	_, err := e.Execute(fmt.Sprintf("kill -9 %d", pid))
+	return e.KillProcessContext(context.Background(), pid)
+}
+
+// KillProcessContext kills a process with the given PID
+func (e *SSHExecutor) KillProcessContext(ctx context.Context, pid int) error {
+	_, err := e.ExecuteContext(ctx, fmt.Sprintf("kill -9 %d", pid))
 	return err
 }
 
 // StopProcess sends SIGINT to a process for graceful shutdown
 func (e *SSHExecutor) StopProcess(pid int) error {
-	_, err := e.Execute(fmt.Sprintf("kill -INT %d", pid))
+	return e.StopProcessContext(context.Background(), pid)
+}
+
+// StopProcessContext sends SIGINT to a process for graceful shutdown
+func (e *SSHExecutor) StopProcessContext(ctx context.Context, pid int) error {
+	_, err := e.ExecuteContext(ctx, fmt.Sprintf("kill -INT %d", pid))
 	return err
 }
 
 // GetOSInfo returns information about the operating system
 func (e *SSHExecutor) GetOSInfo() (*OSInfo, error) {
+	return e.GetOSInfoContext(context.Background())
+}
+
+// GetOSInfoContext returns information about the operating system
+func (e *SSHExecutor) GetOSInfoContext(ctx context.Context) (*OSInfo, error) {
 	// Get OS type
-	osType, err := e.Execute("uname -s")
+	osType, err := e.ExecuteContext(ctx, "uname -s")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get OS type: %w", err)
 	}
 	osType = strings.ToLower(strings.TrimSpace(osType))
 
 	// Get architecture
-	arch, err := e.Execute("uname -m")
+	arch, err := e.ExecuteContext(ctx, "uname -m")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get architecture: %w", err)
 	}
@@ -327,7 +446,7 @@ func (e *SSHExecutor) GetOSInfo() (*OSInfo, error) {
 	}
 
 	// Get OS version
-	version, err := e.Execute("uname -r")
+	version, err := e.ExecuteContext(ctx, "uname -r")
 	if err != nil {
 		version = "" // Non-fatal
 	} else {
@@ -343,8 +462,13 @@ func (e *SSHExecutor) GetOSInfo() (*OSInfo, error) {
 
 // GetDiskSpace returns available disk space in bytes for the given path
 func (e *SSHExecutor) GetDiskSpace(path string) (uint64, error) {
+	return e.GetDiskSpaceContext(context.Background(), path)
+}
+
+// GetDiskSpaceContext returns available disk space in bytes for the given path
+func (e *SSHExecutor) GetDiskSpaceContext(ctx context.Context, path string) (uint64, error) {
 	// Use df to get available space
-	output, err := e.Execute(fmt.Sprintf("df -B1 %s | tail -n1 | awk '{print $4}'", path))
+	output, err := e.ExecuteContext(ctx, fmt.Sprintf("df -B1 %s | tail -n1 | awk '{print $4}'", path))
 	if err != nil {
 		return 0, fmt.Errorf("failed to get disk space: %w", err)
 	}
@@ -362,9 +486,14 @@ func (e *SSHExecutor) GetDiskSpace(path string) (uint64, error) {
 // CheckPortAvailable checks if a port is available
 // For SSH executor, we use multiple methods to check if port is in use
 func (e *SSHExecutor) CheckPortAvailable(port int) (bool, error) {
+	return e.CheckPortAvailableContext(context.Background(), port)
+}
+
+// CheckPortAvailableContext is the context-aware variant of CheckPortAvailable
+func (e *SSHExecutor) CheckPortAvailableContext(ctx context.Context, port int) (bool, error) {
 	// Try using sudo lsof first (can see all processes)
 	cmd := fmt.Sprintf("sudo lsof -i :%d -sTCP:LISTEN 2>/dev/null", port)
-	output, err := e.Execute(cmd)
+	output, err := e.ExecuteContext(ctx, cmd)
 
 	if err == nil && len(strings.TrimSpace(output)) > 0 {
 		// Got output, something is listening on the port
@@ -373,21 +502,33 @@ func (e *SSHExecutor) CheckPortAvailable(port int) (bool, error) {
 
 	// Try without sudo as backup (might work for processes owned by current user)
 	cmd = fmt.Sprintf("lsof -i :%d -sTCP:LISTEN 2>/dev/null || netstat -tuln 2>/dev/null | grep ':%d ' | grep LISTEN", port, port)
-	output, err = e.Execute(cmd)
+	output, err = e.ExecuteContext(ctx, cmd)
 
 	if err == nil && len(strings.TrimSpace(output)) > 0 {
 		// Got output, something is listening on the port
 		return false, nil
 	}
 
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return false, ctxErr
+	}
+
 	// If both commands failed or returned empty output, port is available
 	return true, nil
 }
 
 // UserExists checks if a user exists on the system
 func (e *SSHExecutor) UserExists(username string) (bool, error) {
-	_, err := e.Execute(fmt.Sprintf("id %s", username))
+	return e.UserExistsContext(context.Background(), username)
+}
+
+// UserExistsContext checks if a user exists on the system
+func (e *SSHExecutor) UserExistsContext(ctx context.Context, username string) (bool, error) {
+	_, err := e.ExecuteContext(ctx, fmt.Sprintf("id %s", username))
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return false, ctxErr
+		}
 		// Command failed - user doesn't exist
 		return false, nil
 	}
@@ -396,7 +537,12 @@ func (e *SSHExecutor) UserExists(username string) (bool, error) {
 
 // CheckConnectivity checks if the executor can perform operations
 func (e *SSHExecutor) CheckConnectivity() error {
-	_, err := e.Execute("echo test")
+	return e.CheckConnectivityContext(context.Background())
+}
+
+// CheckConnectivityContext checks if the executor can perform operations
+func (e *SSHExecutor) CheckConnectivityContext(ctx context.Context) error {
+	_, err := e.ExecuteContext(ctx, "echo test")
 	return err
 }
 
@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestSSHServer starts an SSH server on the loopback interface that runs
+// exec requests with the local sh, and returns the config to reach it
+func newTestSSHServer(t *testing.T) SSHConfig {
+	t.Helper()
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "secret" {
+				return nil, fmt.Errorf("password rejected")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, config)
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return SSHConfig{Host: "127.0.0.1", Port: addr.Port, User: "mup", Password: "secret"}
+}
+
+func serveTestSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			_ = newChan.Reject(ssh.UnknownChannelType, "sessions only")
+			continue
+		}
+		ch, requests, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		go serveTestSSHSession(ch, requests)
+	}
+}
+
+func serveTestSSHSession(ch ssh.Channel, requests <-chan *ssh.Request) {
+	defer func() { _ = ch.Close() }()
+	for req := range requests {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			_ = req.Reply(false, nil)
+			return
+		}
+		_ = req.Reply(true, nil)
+
+		cmd := exec.Command("sh", "-c", payload.Command)
+		cmd.Stdin = ch
+		cmd.Stdout = ch
+		cmd.Stderr = ch.Stderr()
+		status := 0
+		if err := cmd.Run(); err != nil {
+			status = 255
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				status = exitErr.ExitCode()
+			}
+		}
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+		return
+	}
+}
+
+func TestSSHExecutor_UploadBinary(t *testing.T) {
+	sshExec, err := NewSSHExecutor(newTestSSHServer(t))
+	require.NoError(t, err)
+	defer func() { _ = sshExec.Close() }()
+
+	// A binary with quotes, NUL bytes and no trailing newline
+	binary := []byte("\x7fELF\x00\x00'MUPEOF'\n'\\''\x00\xff")
+	random := make([]byte, 64*1024)
+	_, err = rand.Read(random)
+	require.NoError(t, err)
+	binary = append(binary, random...)
+
+	var tarball bytes.Buffer
+	gz := gzip.NewWriter(&tarball)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "bin/mongod", Mode: 0755, Size: int64(len(binary))}))
+	_, err = tw.Write(binary)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	local := filepath.Join(t.TempDir(), "mongo.tar.gz")
+	require.NoError(t, os.WriteFile(local, tarball.Bytes(), 0644))
+
+	// The server runs on this machine, so "remote" paths are local
+	remoteDir := filepath.Join(t.TempDir(), "bin")
+	remoteTarball := filepath.Join(remoteDir, ".mup.tar.gz")
+	require.NoError(t, sshExec.UploadFile(local, remoteTarball))
+
+	uploaded, err := os.ReadFile(remoteTarball)
+	require.NoError(t, err)
+	assert.Equal(t, tarball.Bytes(), uploaded)
+
+	_, err = sshExec.Execute(fmt.Sprintf("tar -xzf %s -C %s", ShellQuote(remoteTarball), ShellQuote(remoteDir)))
+	require.NoError(t, err)
+	extracted, err := os.ReadFile(filepath.Join(remoteDir, "bin", "mongod"))
+	require.NoError(t, err)
+	assert.Equal(t, binary, extracted)
+
+	checksum := filepath.Join(remoteDir, "checksum")
+	require.NoError(t, sshExec.UploadContent([]byte("it's\x00done"), checksum))
+	content, err := os.ReadFile(checksum)
+	require.NoError(t, err)
+	assert.Equal(t, "it's\x00done", string(content))
+}
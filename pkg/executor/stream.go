@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"bufio"
+	"io"
+)
+
+// maxStreamLineSize bounds a single streamed line (mongod can log large JSON lines)
+const maxStreamLineSize = 1024 * 1024
+
+// lineStreamer turns writes into complete lines delivered to a callback.
+// It is safe to use the same streamer for stdout and stderr concurrently.
+type lineStreamer struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+}
+
+// newLineStreamer starts a goroutine that calls onLine for each line written
+// to the returned streamer. Call Close to flush the final partial line.
+func newLineStreamer(onLine func(line string)) *lineStreamer {
+	pr, pw := io.Pipe()
+	s := &lineStreamer{pw: pw, done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+		for scanner.Scan() {
+			if onLine != nil {
+				onLine(scanner.Text())
+			}
+		}
+		// Drain anything left (e.g. an over-long line) so writers never block
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+
+	return s
+}
+
+// Write implements io.Writer
+func (s *lineStreamer) Write(p []byte) (int, error) {
+	return s.pw.Write(p)
+}
+
+// Close flushes remaining output and waits for the callback to finish
+func (s *lineStreamer) Close() error {
+	err := s.pw.Close()
+	<-s.done
+	return err
+}
@@ -13,6 +13,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/topology"
 )
 
 // DiscoveryMode represents the mode of discovery
@@ -331,7 +332,7 @@ func (d *Discoverer) queryMongoDBInfo(instance MongoInstance) (MongoDBInfo, erro
 	info := MongoDBInfo{}
 
 	// Build connection string
-	uri := fmt.Sprintf("mongodb://%s:%d", instance.Host, instance.Port)
+	uri := "mongodb://" + topology.GetNodeID(instance.Host, instance.Port)
 
 	// Connect to MongoDB with short timeout (2 seconds) to avoid hanging in tests
 	ctx := context.Background()
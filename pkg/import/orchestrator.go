@@ -13,6 +13,7 @@ import (
 	"github.com/zph/mup/pkg/executor"
 	"github.com/zph/mup/pkg/meta"
 	"github.com/zph/mup/pkg/supervisor"
+	"github.com/zph/mup/pkg/topology"
 )
 
 // ImportOrchestrator coordinates the complete import workflow
@@ -189,7 +190,7 @@ func (o *ImportOrchestrator) Import(ctx context.Context, opts ImportOptions) (*I
 // GetReplicaSetStatus queries a replica set for member status
 // IMP-017: Identify replica set members and their roles
 func (o *ImportOrchestrator) GetReplicaSetStatus(ctx context.Context, host string, port int) ([]ReplicaSetMember, error) {
-	uri := fmt.Sprintf("mongodb://%s:%d", host, port)
+	uri := "mongodb://" + topology.GetNodeID(host, port)
 
 	client, err := mongo.Connect(ctx, options.Client().
 		ApplyURI(uri).
@@ -246,7 +247,7 @@ func (o *ImportOrchestrator) GetReplicaSetStatus(ctx context.Context, host strin
 // StepDownPrimary steps down the current primary in a replica set
 // IMP-019: Step down PRIMARY using rs.stepDown()
 func (o *ImportOrchestrator) StepDownPrimary(ctx context.Context, host string, port int, stepDownSecs int) error {
-	uri := fmt.Sprintf("mongodb://%s:%d", host, port)
+	uri := "mongodb://" + topology.GetNodeID(host, port)
 
 	client, err := mongo.Connect(ctx, options.Client().
 		ApplyURI(uri).
@@ -279,7 +280,7 @@ func (o *ImportOrchestrator) StepDownPrimary(ctx context.Context, host string, p
 // CheckReplicationLag checks if replication lag is acceptable
 // IMP-021: Verify replication lag < 30 seconds
 func (o *ImportOrchestrator) CheckReplicationLag(ctx context.Context, host string, port int, maxLagSeconds int) error {
-	uri := fmt.Sprintf("mongodb://%s:%d", host, port)
+	uri := "mongodb://" + topology.GetNodeID(host, port)
 
 	client, err := mongo.Connect(ctx, options.Client().
 		ApplyURI(uri).
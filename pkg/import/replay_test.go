@@ -0,0 +1,43 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/executor"
+)
+
+// IMP-002, IMP-004: Auto-detect replayed from a host running a mongod and a
+// mongos under systemd, so discovery runs without MongoDB present
+func TestDiscoverAuto_Replay(t *testing.T) {
+	cassette, err := executor.LoadCassette("testdata/discovery.cassette.json")
+	require.NoError(t, err)
+	replay := executor.NewReplayExecutor(cassette, "localhost")
+
+	result, err := NewDiscoverer(replay).Discover(DiscoveryOptions{Mode: AutoDetectMode})
+	require.NoError(t, err)
+	assert.Equal(t, 0, replay.Remaining(), "discovery should make every recorded call")
+
+	assert.Equal(t, AutoDetectMode, result.Mode)
+	require.Len(t, result.Instances, 2)
+
+	mongod := result.Instances[0]
+	assert.Equal(t, 1187, mongod.PID)
+	assert.Equal(t, "localhost", mongod.Host, "0.0.0.0 is reached via localhost")
+	assert.Equal(t, 37017, mongod.Port)
+	assert.Equal(t, "/etc/mongod.conf", mongod.ConfigFile)
+	assert.Equal(t, "/var/lib/mongodb", mongod.DataDir)
+	assert.Equal(t, "/var/log/mongodb/mongod.log", mongod.LogPath)
+
+	mongos := result.Instances[1]
+	assert.Equal(t, 1342, mongos.PID)
+	assert.Equal(t, "127.0.0.1", mongos.Host)
+	assert.Equal(t, 37018, mongos.Port)
+	assert.Equal(t, "/etc/mongos.conf", mongos.ConfigFile)
+
+	assert.Equal(t, []SystemdService{
+		{Name: "mongod", UnitFile: "/lib/systemd/system/mongod.service", Status: "active"},
+		{Name: "mongos", UnitFile: "/etc/systemd/system/mongos.service", Status: "inactive"},
+	}, result.SystemdServices)
+}
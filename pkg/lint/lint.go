@@ -0,0 +1,538 @@
+package lint
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity ranks how urgently a finding needs fixing
+type Severity string
+
+// Severities, lowest first
+const (
+	SeverityInfo   Severity = "info"
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+// rank orders severities
+func (s Severity) rank() int {
+	switch s {
+	case SeverityLow:
+		return 1
+	case SeverityMedium:
+		return 2
+	case SeverityHigh:
+		return 3
+	}
+	return 0
+}
+
+// AtLeast reports whether s is as severe as other
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// ParseSeverity parses a severity name
+func ParseSeverity(name string) (Severity, error) {
+	for _, s := range []Severity{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh} {
+		if strings.EqualFold(name, string(s)) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown severity %q: use info, low, medium or high", name)
+}
+
+// Finding is one problem a check found
+type Finding struct {
+	Check       string   `json:"check"`
+	Severity    Severity `json:"severity"`
+	Target      string   `json:"target"` // Node, host or cluster the finding is about
+	Message     string   `json:"message"`
+	Remediation string   `json:"remediation,omitempty"`
+}
+
+// Report is the findings of one audit, most severe first
+type Report struct {
+	Cluster  string    `json:"cluster"`
+	Findings []Finding `json:"findings"`
+}
+
+// Count returns the number of findings with severity s
+func (r *Report) Count(s Severity) int {
+	count := 0
+	for _, f := range r.Findings {
+		if f.Severity == s {
+			count++
+		}
+	}
+	return count
+}
+
+// HasAtLeast reports whether any finding is as severe as s
+func (r *Report) HasAtLeast(s Severity) bool {
+	for _, f := range r.Findings {
+		if f.Severity.AtLeast(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Node is what an audit knows about one mongod or mongos
+type Node struct {
+	ID     string                 // host:port
+	Type   string                 // "mongod", "mongos" or "config"
+	Config map[string]interface{} // Generated config file, nil when unreadable
+	// Live is the node's getCmdLineOpts "parsed" document, nil when the
+	// node could not be queried. Live settings win over Config.
+	Live      map[string]interface{}
+	LiveError string
+}
+
+// KeyFile is a keyFile's permissions on one host
+type KeyFile struct {
+	Host string
+	Path string
+	Mode os.FileMode
+}
+
+// Endpoint is a monitoring component's listen address
+type Endpoint struct {
+	Name    string // e.g. "grafana", "mongodb_exporter"
+	Address string // host:port
+}
+
+// Input is everything the checks look at
+type Input struct {
+	Cluster  string
+	Version  string // MongoDB version from meta.yaml
+	Nodes    []Node
+	KeyFiles []KeyFile
+
+	// AdminUser is empty without access control, AdminPassword when it
+	// could not be read
+	AdminUser     string
+	AdminPassword string
+	// PlaintextPasswordFile is the admin password file when it still exists
+	PlaintextPasswordFile string
+
+	Monitoring []Endpoint
+}
+
+// check inspects an input and returns its findings
+type check func(in *Input, now time.Time) []Finding
+
+// checks run in this order; Run sorts their findings by severity
+var checks = []check{
+	checkLiveOptions,
+	checkAccessControl,
+	checkTLS,
+	checkKeyFiles,
+	checkAdminPassword,
+	checkVersion,
+	checkMonitoring,
+}
+
+// Run runs every check against in
+func Run(in *Input, now time.Time) *Report {
+	report := &Report{Cluster: in.Cluster, Findings: []Finding{}}
+	for _, c := range checks {
+		report.Findings = append(report.Findings, c(in, now)...)
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Severity.rank() > report.Findings[j].Severity.rank()
+	})
+	return report
+}
+
+// setting returns the value at a dotted path of node's effective options:
+// the live options when known, the generated config otherwise
+func (n *Node) setting(path string) (interface{}, bool) {
+	doc := n.Live
+	if doc == nil {
+		doc = n.Config
+	}
+	var value interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		m, ok := asMap(value)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// asMap returns v as a string-keyed map. YAML and BSON decode documents
+// into different map types.
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			converted[fmt.Sprint(k)] = val
+		}
+		return converted, true
+	}
+	return nil, false
+}
+
+// stringSetting returns a setting as a string, "" when unset
+func (n *Node) stringSetting(path string) string {
+	value, ok := n.setting(path)
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// boolSetting reports whether a setting is true
+func (n *Node) boolSetting(path string) bool {
+	value, ok := n.setting(path)
+	if !ok {
+		return false
+	}
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// tlsSetting returns a net.tls setting, falling back to the net.ssl name
+// older versions use
+func (n *Node) tlsSetting(name, sslName string) string {
+	if value := n.stringSetting("net.tls." + name); value != "" {
+		return value
+	}
+	return n.stringSetting("net.ssl." + sslName)
+}
+
+// bindsAll reports whether the node listens on every interface
+func (n *Node) bindsAll() bool {
+	if n.boolSetting("net.bindIpAll") {
+		return true
+	}
+	for _, ip := range strings.Split(n.stringSetting("net.bindIp"), ",") {
+		if isWildcard(strings.TrimSpace(ip)) {
+			return true
+		}
+	}
+	return false
+}
+
+// enforcesAuth reports whether clients must authenticate. mongos has no
+// security.authorization and enforces it whenever a keyFile is set.
+func (n *Node) enforcesAuth() bool {
+	if n.boolSetting("security.transitionToAuth") {
+		return false
+	}
+	if n.Type == "mongos" {
+		return n.stringSetting("security.keyFile") != "" || n.stringSetting("security.clusterAuthMode") == "x509"
+	}
+	return n.stringSetting("security.authorization") == "enabled"
+}
+
+// known reports whether anything is known about the node's options
+func (n *Node) known() bool {
+	return n.Live != nil || n.Config != nil
+}
+
+// isWildcard reports whether ip listens on every interface
+func isWildcard(ip string) bool {
+	return ip == "0.0.0.0" || ip == "::" || ip == "*"
+}
+
+// checkLiveOptions notes nodes whose running options could not be read
+func checkLiveOptions(in *Input, _ time.Time) []Finding {
+	var findings []Finding
+	for i := range in.Nodes {
+		node := &in.Nodes[i]
+		if node.Live != nil {
+			continue
+		}
+		message := "could not read running options, checked the generated config"
+		if !node.known() {
+			message = "could not read running options or the generated config, node not checked"
+		}
+		if node.LiveError != "" {
+			message += ": " + node.LiveError
+		}
+		findings = append(findings, Finding{
+			Check:    "live-options",
+			Severity: SeverityInfo,
+			Target:   node.ID,
+			Message:  message,
+		})
+	}
+	return findings
+}
+
+// checkAccessControl flags nodes that do not require authentication
+func checkAccessControl(in *Input, _ time.Time) []Finding {
+	var findings []Finding
+	for i := range in.Nodes {
+		node := &in.Nodes[i]
+		if !node.known() || node.enforcesAuth() {
+			continue
+		}
+		if node.boolSetting("security.transitionToAuth") {
+			findings = append(findings, Finding{
+				Check:       "transition-to-auth",
+				Severity:    SeverityMedium,
+				Target:      node.ID,
+				Message:     "security.transitionToAuth is set, unauthenticated clients are accepted",
+				Remediation: "finish 'mup cluster enable-auth' so the nodes restart without transitionToAuth",
+			})
+			continue
+		}
+		if node.bindsAll() {
+			findings = append(findings, Finding{
+				Check:       "bind-all-without-auth",
+				Severity:    SeverityHigh,
+				Target:      node.ID,
+				Message:     "listens on all interfaces without authorization",
+				Remediation: "run 'mup cluster enable-auth', or bind to private addresses only",
+			})
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:       "auth-disabled",
+			Severity:    SeverityMedium,
+			Target:      node.ID,
+			Message:     "authorization is disabled",
+			Remediation: "run 'mup cluster enable-auth'",
+		})
+	}
+	return findings
+}
+
+// checkTLS flags nodes without TLS or that accept invalid certificates
+func checkTLS(in *Input, _ time.Time) []Finding {
+	var findings []Finding
+	for i := range in.Nodes {
+		node := &in.Nodes[i]
+		if !node.known() {
+			continue
+		}
+		mode := node.tlsSetting("mode", "mode")
+		switch mode {
+		case "", "disabled":
+			findings = append(findings, Finding{
+				Check:       "tls-disabled",
+				Severity:    SeverityMedium,
+				Target:      node.ID,
+				Message:     "TLS is disabled, traffic is unencrypted",
+				Remediation: "deploy with TLS, see 'mup cluster rotate-certs'",
+			})
+		case "allowTLS", "preferTLS", "allowSSL", "preferSSL":
+			findings = append(findings, Finding{
+				Check:       "tls-optional",
+				Severity:    SeverityLow,
+				Target:      node.ID,
+				Message:     fmt.Sprintf("net.tls.mode is %s, clients may connect without TLS", mode),
+				Remediation: "raise net.tls.mode to requireTLS",
+			})
+		}
+
+		for _, setting := range []struct{ tls, ssl string }{
+			{"allowInvalidCertificates", "allowInvalidCertificates"},
+			{"allowInvalidHostnames", "allowInvalidHostnames"},
+		} {
+			if value, _ := strconv.ParseBool(node.tlsSetting(setting.tls, setting.ssl)); value {
+				findings = append(findings, Finding{
+					Check:       "tls-invalid-certificates",
+					Severity:    SeverityHigh,
+					Target:      node.ID,
+					Message:     fmt.Sprintf("net.tls.%s is set, peers are not verified", setting.tls),
+					Remediation: fmt.Sprintf("remove net.tls.%s and issue certificates from the cluster CA", setting.tls),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkKeyFiles flags keyFiles other users can read
+func checkKeyFiles(in *Input, _ time.Time) []Finding {
+	var findings []Finding
+	for _, key := range in.KeyFiles {
+		perm := key.Mode.Perm()
+		severity := SeverityMedium
+		who := "its group"
+		switch {
+		case perm&0o007 != 0:
+			severity, who = SeverityHigh, "every user"
+		case perm&0o070 == 0:
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:       "keyfile-permissions",
+			Severity:    severity,
+			Target:      key.Host,
+			Message:     fmt.Sprintf("keyFile %s has mode %04o and is readable by %s", key.Path, perm, who),
+			Remediation: fmt.Sprintf("chmod 600 %s", key.Path),
+		})
+	}
+	return findings
+}
+
+// weakPasswords are passwords tried first by anyone guessing
+var weakPasswords = []string{
+	"admin", "administrator", "password", "passw0rd", "mongo", "mongodb",
+	"root", "changeme", "secret", "123456", "12345678", "test", "default",
+}
+
+// checkAdminPassword flags a default or guessable admin password and one
+// still stored in plaintext
+func checkAdminPassword(in *Input, _ time.Time) []Finding {
+	if in.AdminUser == "" {
+		return nil
+	}
+	var findings []Finding
+	target := in.AdminUser + "@admin"
+	weak := in.AdminPassword != "" && (strings.EqualFold(in.AdminPassword, in.AdminUser) || len(in.AdminPassword) < 8)
+	for _, password := range weakPasswords {
+		if strings.EqualFold(in.AdminPassword, password) {
+			weak = true
+		}
+	}
+	if weak {
+		findings = append(findings, Finding{
+			Check:       "default-admin-password",
+			Severity:    SeverityHigh,
+			Target:      target,
+			Message:     "the admin user has a default or guessable password",
+			Remediation: fmt.Sprintf("run 'mup cluster credentials rotate %s --user %s'", in.Cluster, in.AdminUser),
+		})
+	}
+	if in.PlaintextPasswordFile != "" {
+		findings = append(findings, Finding{
+			Check:       "plaintext-password",
+			Severity:    SeverityLow,
+			Target:      target,
+			Message:     fmt.Sprintf("the admin password is stored in plaintext in %s", in.PlaintextPasswordFile),
+			Remediation: fmt.Sprintf("move it to the credential store with 'mup cluster credentials rotate %s --user %s'", in.Cluster, in.AdminUser),
+		})
+	}
+	return findings
+}
+
+// endOfLife is when MongoDB release series stop getting fixes
+var endOfLife = map[string]time.Time{
+	"3.6": date(2021, time.April, 30),
+	"4.0": date(2022, time.April, 30),
+	"4.2": date(2023, time.April, 30),
+	"4.4": date(2024, time.February, 29),
+	"5.0": date(2024, time.October, 31),
+	"6.0": date(2025, time.July, 31),
+	"7.0": date(2027, time.August, 31),
+	"8.0": date(2029, time.October, 31),
+}
+
+// eolWarning is how long before end of life checkVersion starts warning
+const eolWarning = 180 * 24 * time.Hour
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// releaseSeries returns a version's major.minor, e.g. "7.0" for "7.0.5-4"
+func releaseSeries(version string) (string, int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return "", 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", 0, false
+	}
+	minor, err := strconv.Atoi(strings.TrimFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return "", 0, false
+	}
+	return fmt.Sprintf("%d.%d", major, minor), major, true
+}
+
+// checkVersion flags MongoDB versions past or near end of life
+func checkVersion(in *Input, now time.Time) []Finding {
+	series, major, ok := releaseSeries(in.Version)
+	if !ok {
+		return nil
+	}
+	eol, known := endOfLife[series]
+	if !known {
+		if major >= 4 {
+			return nil // Newer than the table, or a rapid release
+		}
+		eol = endOfLife["3.6"]
+	}
+	remediation := fmt.Sprintf("run 'mup cluster upgrade %s' to a supported version", in.Cluster)
+	switch {
+	case !now.Before(eol):
+		return []Finding{{
+			Check:       "eol-version",
+			Severity:    SeverityMedium,
+			Target:      in.Cluster,
+			Message:     fmt.Sprintf("MongoDB %s reached end of life on %s", in.Version, eol.Format("2006-01-02")),
+			Remediation: remediation,
+		}}
+	case eol.Sub(now) < eolWarning:
+		return []Finding{{
+			Check:       "eol-version",
+			Severity:    SeverityLow,
+			Target:      in.Cluster,
+			Message:     fmt.Sprintf("MongoDB %s reaches end of life on %s", in.Version, eol.Format("2006-01-02")),
+			Remediation: remediation,
+		}}
+	}
+	return nil
+}
+
+// checkMonitoring flags monitoring endpoints listening on all interfaces or
+// a public address. They serve metrics without authentication.
+func checkMonitoring(in *Input, _ time.Time) []Finding {
+	var findings []Finding
+	for _, endpoint := range in.Monitoring {
+		address := endpoint.Address
+		if u, err := url.Parse(address); err == nil && u.Host != "" {
+			address = u.Host
+		}
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		var exposure string
+		switch ip := net.ParseIP(host); {
+		case host == "" || isWildcard(host):
+			exposure = "on all interfaces"
+		case ip != nil && !ip.IsLoopback() && !ip.IsPrivate():
+			exposure = "on public address " + host
+		default:
+			continue // Loopback, private or a host name
+		}
+		findings = append(findings, Finding{
+			Check:       "monitoring-exposed",
+			Severity:    SeverityMedium,
+			Target:      endpoint.Address,
+			Message:     fmt.Sprintf("%s listens %s without authentication", endpoint.Name, exposure),
+			Remediation: "firewall the port so only the monitoring server reaches it",
+		})
+	}
+	return findings
+}
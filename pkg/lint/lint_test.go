@@ -0,0 +1,178 @@
+package lint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var now = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// config parses a generated config file
+func config(t *testing.T, content string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(content), &doc))
+	return doc
+}
+
+// checksOf returns the check names of findings, keyed by target
+func checksOf(report *Report) map[string][]string {
+	checks := make(map[string][]string)
+	for _, f := range report.Findings {
+		checks[f.Target] = append(checks[f.Target], f.Check)
+	}
+	return checks
+}
+
+func TestRun_Nodes(t *testing.T) {
+	in := &Input{
+		Cluster: "prod",
+		Version: "7.0.5",
+		Nodes: []Node{
+			{ID: "open:27017", Type: "mongod", Config: config(t, "net:\n  bindIp: 127.0.0.1,0.0.0.0\n")},
+			{ID: "local:27017", Type: "mongod", Config: config(t, "net:\n  bindIp: 127.0.0.1\n")},
+			{
+				ID: "secure:27017", Type: "mongod",
+				Config: config(t, "net:\n  bindIpAll: true\n"),
+				Live: config(t, "net:\n  bindIpAll: true\n  tls:\n    mode: requireTLS\n"+
+					"security:\n  authorization: enabled\n  keyFile: /k\n"),
+			},
+			{
+				ID: "lax:27017", Type: "mongos",
+				Config: config(t, "net:\n  tls:\n    mode: preferTLS\n    allowInvalidHostnames: true\n"+
+					"security:\n  keyFile: /k\n"),
+				LiveError: "connection refused",
+			},
+			{
+				ID: "legacy:27017", Type: "mongod",
+				Live: config(t, "net:\n  ssl:\n    mode: requireSSL\n    allowInvalidCertificates: true\n"+
+					"security:\n  authorization: enabled\n  transitionToAuth: true\n"),
+			},
+		},
+	}
+
+	report := Run(in, now)
+	checks := checksOf(report)
+
+	assert.Equal(t, []string{"bind-all-without-auth", "tls-disabled", "live-options"}, checks["open:27017"])
+	assert.Equal(t, []string{"auth-disabled", "tls-disabled", "live-options"}, checks["local:27017"])
+	assert.Empty(t, checks["secure:27017"], "live options win over the generated config")
+	assert.Equal(t, []string{"tls-invalid-certificates", "tls-optional", "live-options"}, checks["lax:27017"])
+	assert.Equal(t, []string{"tls-invalid-certificates", "transition-to-auth"}, checks["legacy:27017"])
+
+	assert.Equal(t, SeverityHigh, report.Findings[0].Severity, "most severe first")
+	assert.True(t, report.HasAtLeast(SeverityHigh))
+	assert.Equal(t, 3, report.Count(SeverityHigh))
+}
+
+func TestRun_Clean(t *testing.T) {
+	in := &Input{
+		Cluster:       "prod",
+		Version:       "8.0.3",
+		AdminUser:     "admin",
+		AdminPassword: "k8sW2pQx9vLm4RtZ",
+		KeyFiles:      []KeyFile{{Host: "db1", Path: "/k", Mode: 0o600}},
+		Monitoring:    []Endpoint{{Name: "grafana", Address: "http://localhost:3000"}, {Name: "node_exporter", Address: "10.0.0.5:9100"}},
+	}
+
+	report := Run(in, now)
+	assert.Empty(t, report.Findings)
+	assert.False(t, report.HasAtLeast(SeverityInfo))
+}
+
+func TestCheckKeyFiles(t *testing.T) {
+	findings := checkKeyFiles(&Input{KeyFiles: []KeyFile{
+		{Host: "db1", Path: "/k", Mode: 0o644},
+		{Host: "db2", Path: "/k", Mode: 0o640},
+		{Host: "db3", Path: "/k", Mode: 0o400},
+	}}, now)
+
+	require.Len(t, findings, 2)
+	assert.Equal(t, SeverityHigh, findings[0].Severity)
+	assert.Equal(t, "keyFile /k has mode 0644 and is readable by every user", findings[0].Message)
+	assert.Equal(t, SeverityMedium, findings[1].Severity)
+	assert.Equal(t, "db2", findings[1].Target)
+}
+
+func TestCheckAdminPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		weak     bool
+	}{
+		{"default", "admin", true},
+		{"listed", "ChangeMe", true},
+		{"short", "x7#Lq", true},
+		{"strong", "k8sW2pQx9vLm4RtZ", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := checkAdminPassword(&Input{Cluster: "prod", AdminUser: "admin", AdminPassword: tt.password}, now)
+			assert.Equal(t, tt.weak, len(findings) == 1)
+		})
+	}
+
+	findings := checkAdminPassword(&Input{AdminUser: "admin", AdminPassword: "k8sW2pQx9vLm4RtZ", PlaintextPasswordFile: "/p"}, now)
+	require.Len(t, findings, 1)
+	assert.Equal(t, "plaintext-password", findings[0].Check)
+
+	assert.Empty(t, checkAdminPassword(&Input{}, now), "no access control, no admin user")
+}
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		severity Severity
+	}{
+		{"3.4.24", SeverityMedium},
+		{"5.0.26", SeverityMedium},
+		{"6.0.15-12", SeverityMedium},
+		{"7.0.5", ""},
+		{"8.0.3", ""},
+		{"9.0.0", ""},
+		{"unknown", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			findings := checkVersion(&Input{Cluster: "prod", Version: tt.version}, now)
+			if tt.severity == "" {
+				assert.Empty(t, findings)
+				return
+			}
+			require.Len(t, findings, 1)
+			assert.Equal(t, tt.severity, findings[0].Severity)
+		})
+	}
+
+	findings := checkVersion(&Input{Version: "7.0.5"}, date(2027, time.May, 1))
+	require.Len(t, findings, 1)
+	assert.Equal(t, SeverityLow, findings[0].Severity, "warns ahead of end of life")
+}
+
+func TestCheckMonitoring(t *testing.T) {
+	findings := checkMonitoring(&Input{Monitoring: []Endpoint{
+		{Name: "mongodb_exporter", Address: "0.0.0.0:9216"},
+		{Name: "node_exporter", Address: "203.0.113.7:9100"},
+		{Name: "node_exporter", Address: "192.168.1.4:9100"},
+		{Name: "victoriametrics", Address: "http://127.0.0.1:8428"},
+	}}, now)
+
+	require.Len(t, findings, 2)
+	assert.Equal(t, "mongodb_exporter listens on all interfaces without authentication", findings[0].Message)
+	assert.Equal(t, "node_exporter listens on public address 203.0.113.7 without authentication", findings[1].Message)
+}
+
+func TestParseSeverity(t *testing.T) {
+	s, err := ParseSeverity("HIGH")
+	require.NoError(t, err)
+	assert.Equal(t, SeverityHigh, s)
+	assert.True(t, SeverityHigh.AtLeast(SeverityMedium))
+	assert.False(t, SeverityLow.AtLeast(SeverityMedium))
+
+	_, err = ParseSeverity("critical")
+	assert.Error(t, err)
+}
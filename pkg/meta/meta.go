@@ -32,6 +32,13 @@ type ClusterMetadata struct {
 
 	// Monitoring fields
 	Monitoring *MonitoringMetadata `yaml:"monitoring,omitempty"`
+
+	// Access control
+	Security *SecurityMetadata `yaml:"security,omitempty"`
+
+	// Protected clusters refuse destructive commands until the operator
+	// confirms with the cluster's name
+	Protected bool `yaml:"protected,omitempty"`
 }
 
 // GetFullVersion returns the full version string including variant
@@ -74,6 +81,25 @@ type MonitoringMetadata struct {
 	SupervisorPIDFile    string                    `yaml:"supervisor_pid_file,omitempty"`
 }
 
+// SecurityMetadata tracks a cluster's access control and TLS. The files
+// live on the machine running mup.
+type SecurityMetadata struct {
+	Auth         bool   `yaml:"auth"`
+	AdminUser    string `yaml:"admin_user,omitempty"`
+	KeyFile      string `yaml:"key_file,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"` // AdminUser's password
+
+	// CredentialsFile is the encrypted credential store. Passwords are read
+	// from it rather than PasswordFile once it exists.
+	CredentialsFile string `yaml:"credentials_file,omitempty"`
+
+	// TLS is the nodes' net.tls.mode, empty without TLS. Clients verify
+	// the nodes with the CA in CAFile.
+	TLS            string `yaml:"tls,omitempty"`
+	CAFile         string `yaml:"ca_file,omitempty"`
+	X509MemberAuth bool   `yaml:"x509_member_auth,omitempty"`
+}
+
 // NodeExporterMetadata tracks a node_exporter instance
 type NodeExporterMetadata struct {
 	Host string `yaml:"host"`
@@ -101,11 +127,29 @@ type NodeMetadata struct {
 	ConfigFile string `yaml:"config_file"`
 	PID        int    `yaml:"pid,omitempty"` // Deprecated: supervisord manages PIDs now
 
+	// AdvertiseHost is the host other members and clients use, when it
+	// differs from Host
+	AdvertiseHost string `yaml:"advertise_host,omitempty"`
+
 	// Supervisord fields
 	SupervisorProgramName string `yaml:"supervisor_program_name,omitempty"` // Name in supervisor config (e.g., "mongod-27017")
 	SupervisorConfigFile  string `yaml:"supervisor_config_file,omitempty"`  // Path to node's supervisor config
 }
 
+// ID returns the node's host:port identifier, see topology.GetNodeID
+func (n NodeMetadata) ID() string {
+	return topology.GetNodeID(n.Host, n.Port)
+}
+
+// Address returns the host:port clients connect to: advertise_host when
+// set, otherwise the node's host
+func (n NodeMetadata) Address() string {
+	if n.AdvertiseHost != "" {
+		return topology.GetNodeID(n.AdvertiseHost, n.Port)
+	}
+	return n.ID()
+}
+
 // Manager manages cluster metadata
 type Manager struct {
 	baseDir string
@@ -172,6 +216,19 @@ func (m *Manager) Save(metadata *ClusterMetadata) error {
 	return nil
 }
 
+// IsProtected reports whether the cluster is protected. A cluster without
+// metadata, one not deployed yet, is not.
+func (m *Manager) IsProtected(clusterName string) (bool, error) {
+	if _, err := os.Stat(m.GetMetaFile(clusterName)); os.IsNotExist(err) {
+		return false, nil
+	}
+	metadata, err := m.Load(clusterName)
+	if err != nil {
+		return false, err
+	}
+	return metadata.Protected, nil
+}
+
 // Delete deletes cluster metadata
 func (m *Manager) Delete(clusterName string) error {
 	clusterDir := m.GetClusterDir(clusterName)
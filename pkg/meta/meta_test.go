@@ -129,3 +129,42 @@ func TestClusterMetadata_FullVersionRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeMetadata_Address(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     NodeMetadata
+		wantID   string
+		wantAddr string
+	}{
+		{
+			name:     "hostname",
+			node:     NodeMetadata{Host: "db1", Port: 27017},
+			wantID:   "db1:27017",
+			wantAddr: "db1:27017",
+		},
+		{
+			name:     "ipv6",
+			node:     NodeMetadata{Host: "::1", Port: 30000},
+			wantID:   "[::1]:30000",
+			wantAddr: "[::1]:30000",
+		},
+		{
+			name:     "advertise host",
+			node:     NodeMetadata{Host: "10.0.0.5", AdvertiseHost: "2001:db8::5", Port: 27017},
+			wantID:   "10.0.0.5:27017",
+			wantAddr: "[2001:db8::5]:27017",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.node.ID(); got != tt.wantID {
+				t.Errorf("ID() = %q, want %q", got, tt.wantID)
+			}
+			if got := tt.node.Address(); got != tt.wantAddr {
+				t.Errorf("Address() = %q, want %q", got, tt.wantAddr)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package meta
+
+import (
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/zph/mup/pkg/security"
+)
+
+// TLSEnabled reports whether the cluster's nodes accept TLS connections
+func (s *SecurityMetadata) TLSEnabled() bool {
+	return s != nil && s.TLS != "" && s.CAFile != ""
+}
+
+// ApplyTLS makes opts connect over TLS, verifying the nodes with the
+// cluster CA. It leaves opts unchanged for clusters without TLS.
+func (s *SecurityMetadata) ApplyTLS(opts *options.ClientOptions) error {
+	if !s.TLSEnabled() {
+		return nil
+	}
+	tlsConfig, err := security.ClientTLSConfig(s.CAFile)
+	if err != nil {
+		return err
+	}
+	opts.SetTLSConfig(tlsConfig)
+	return nil
+}
+
+// ApplyAuth makes opts log in as the admin user. It leaves opts unchanged
+// for clusters without access control.
+func (s *SecurityMetadata) ApplyAuth(opts *options.ClientOptions) error {
+	if s == nil || !s.Auth {
+		return nil
+	}
+	password, err := s.AdminPassword()
+	if err != nil {
+		return err
+	}
+	opts.SetAuth(options.Credential{
+		AuthSource: "admin",
+		Username:   s.AdminUser,
+		Password:   password,
+	})
+	return nil
+}
+
+// AdminPassword returns the admin user's password from the credential store,
+// or the password file before the store exists
+func (s *SecurityMetadata) AdminPassword() (string, error) {
+	return security.LookupPassword(s.CredentialsFile, s.AdminUser, s.PasswordFile)
+}
@@ -0,0 +1,48 @@
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/zph/mup/pkg/security"
+)
+
+func TestSecurityMetadata_ApplyTLS(t *testing.T) {
+	var none *SecurityMetadata
+	opts := options.Client()
+	require.NoError(t, none.ApplyTLS(opts))
+	assert.Nil(t, opts.TLSConfig)
+
+	ca, err := security.NewCA("test")
+	require.NoError(t, err)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.CertPEM(), 0644))
+
+	sec := &SecurityMetadata{TLS: "requireTLS", CAFile: caFile}
+	require.NoError(t, sec.ApplyTLS(opts))
+	require.NotNil(t, opts.TLSConfig)
+	assert.NotNil(t, opts.TLSConfig.RootCAs)
+
+	sec.CAFile = filepath.Join(t.TempDir(), "missing.pem")
+	assert.Error(t, sec.ApplyTLS(options.Client()))
+}
+
+func TestSecurityMetadata_ApplyAuth(t *testing.T) {
+	opts := options.Client()
+	require.NoError(t, (&SecurityMetadata{TLS: "requireTLS"}).ApplyAuth(opts))
+	assert.Nil(t, opts.Auth)
+
+	passwordFile := filepath.Join(t.TempDir(), "admin.password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("s3cret\n"), 0600))
+	sec := &SecurityMetadata{Auth: true, AdminUser: "dba", PasswordFile: passwordFile}
+	require.NoError(t, sec.ApplyAuth(opts))
+	require.NotNil(t, opts.Auth)
+	assert.Equal(t, "dba", opts.Auth.Username)
+	assert.Equal(t, "s3cret", opts.Auth.Password)
+	assert.Equal(t, "admin", opts.Auth.AuthSource)
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/topology"
 )
 
 // MongoDBExporterManager manages mongodb_exporter instances
@@ -64,7 +65,7 @@ func (m *MongoDBExporterManager) Start(ctx context.Context, host string, exporte
 	}
 
 	// Build MongoDB URI
-	mongoURI := fmt.Sprintf("mongodb://%s:%d", host, mongoDBPort)
+	mongoURI := "mongodb://" + topology.GetNodeID(host, mongoDBPort)
 
 	// Create log file
 	logFile := filepath.Join(m.logsDir, fmt.Sprintf("mongodb_exporter-%s-%d.log", host, exporterPort))
@@ -72,7 +73,7 @@ func (m *MongoDBExporterManager) Start(ctx context.Context, host string, exporte
 	// Build command with extra args
 	args := []string{
 		fmt.Sprintf("--mongodb.uri=%s", mongoURI),
-		fmt.Sprintf("--web.listen-address=%s:%d", host, exporterPort),
+		"--web.listen-address=" + topology.GetNodeID(host, exporterPort),
 	}
 	args = append(args, m.extraArgs...)
 
@@ -121,8 +122,8 @@ func (m *MongoDBExporterManager) GenerateSupervisorConfig(programName, host stri
 	}
 
 	args := []string{
-		fmt.Sprintf("--mongodb.uri=mongodb://%s:%d", host, mongoDBPort),
-		fmt.Sprintf("--web.listen-address=%s:%d", listenAddr, exporterPort),
+		"--mongodb.uri=mongodb://" + topology.GetNodeID(host, mongoDBPort),
+		"--web.listen-address=" + topology.GetNodeID(listenAddr, exporterPort),
 	}
 	args = append(args, m.extraArgs...)
 
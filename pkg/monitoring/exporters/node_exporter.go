@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/topology"
 )
 
 // NodeExporterManager manages node_exporter instances
@@ -62,10 +63,9 @@ func (m *NodeExporterManager) Start(ctx context.Context, host string, port int)
 	logFile := filepath.Join(m.logsDir, fmt.Sprintf("node_exporter-%s-%d.log", host, port))
 
 	// Build command
-	cmd := fmt.Sprintf("%s --web.listen-address=%s:%d > %s 2>&1 &",
+	cmd := fmt.Sprintf("%s --web.listen-address=%s > %s 2>&1 &",
 		binaryPath,
-		host,
-		port,
+		topology.GetNodeID(host, port),
 		logFile,
 	)
 
@@ -106,7 +106,7 @@ func (m *NodeExporterManager) GenerateSupervisorConfig(programName, host string,
 	}
 
 	return fmt.Sprintf(`[program:%s]
-command = %s --web.listen-address=%s:%d
+command = %s --web.listen-address=%s
 autostart = false
 autorestart = unexpected
 startsecs = 3
@@ -118,8 +118,7 @@ stopsignal = TERM
 `,
 		programName,
 		binaryPath,
-		listenAddr,
-		port,
+		topology.GetNodeID(listenAddr, port),
 		logFile,
 		logFile,
 	)
@@ -72,7 +72,7 @@ func GenerateScrapeConfig(clusterName string, topo *topology.Topology, registry
 
 		for _, ne := range registry.NodeExporters {
 			nodeExporterJob.StaticConfigs = append(nodeExporterJob.StaticConfigs, StaticConfig{
-				Targets: []string{fmt.Sprintf("%s:%d", ne.Host, ne.Port)},
+				Targets: []string{topology.GetNodeID(ne.Host, ne.Port)},
 				Labels: map[string]string{
 					"host": ne.Host,
 					"role": "mongodb-host",
@@ -127,7 +127,7 @@ func GenerateScrapeConfig(clusterName string, topo *topology.Topology, registry
 			}
 
 			mongoExporterJob.StaticConfigs = append(mongoExporterJob.StaticConfigs, StaticConfig{
-				Targets: []string{fmt.Sprintf("%s:%d", me.Host, me.ExporterPort)},
+				Targets: []string{topology.GetNodeID(me.Host, me.ExporterPort)},
 				Labels:  labels,
 			})
 		}
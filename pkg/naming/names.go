@@ -1,6 +1,9 @@
 package naming
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // GetProgramName returns the supervisor program name for a MongoDB node.
 // This name is used by supervisor to identify and control the process.
@@ -36,6 +39,31 @@ func GetProcessDir(nodeType string, port int) string {
 	return fmt.Sprintf("%s-%d", nodeType, port)
 }
 
+// GetHostPortDir returns the directory name for a node on a host, used for
+// data directories and per-node config directories. Colons and the zone
+// separator of IPv6 literals are replaced so the name is one plain path
+// element; hostnames and IPv4 addresses are unchanged.
+//
+// Examples:
+//   - GetHostPortDir("localhost", 30000) returns "localhost-30000"
+//   - GetHostPortDir("::1", 30000) returns "__1-30000"
+//   - GetHostPortDir("[fe80::1%eth0]", 27017) returns "fe80__1_eth0-27017"
+func GetHostPortDir(host string, port int) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	host = strings.NewReplacer(":", "_", "%", "_").Replace(host)
+	return fmt.Sprintf("%s-%d", host, port)
+}
+
+// GetNodeName returns a name for a node that is unique across hosts, used
+// for plan targets and resources.
+//
+// Examples:
+//   - GetNodeName("mongod", "db1", 27017) returns "mongod-db1-27017"
+//   - GetNodeName("mongod", "::1", 27017) returns "mongod-__1-27017"
+func GetNodeName(nodeType, host string, port int) string {
+	return fmt.Sprintf("%s-%s", nodeType, GetHostPortDir(host, port))
+}
+
 // GetLogFileName returns the log filename for any MongoDB node type.
 // All node types use the same generic log file name; the parent directory
 // provides the node type context.
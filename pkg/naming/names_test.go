@@ -144,3 +144,49 @@ func TestGetProcessDir(t *testing.T) {
 		})
 	}
 }
+
+func TestGetHostPortDir(t *testing.T) {
+	tests := []struct {
+		name string
+		host string
+		port int
+		want string
+	}{
+		{name: "localhost", host: "localhost", port: 30000, want: "localhost-30000"},
+		{name: "ipv4", host: "10.0.0.5", port: 27017, want: "10.0.0.5-27017"},
+		{name: "ipv6 loopback", host: "::1", port: 30000, want: "__1-30000"},
+		{name: "bracketed ipv6", host: "[::1]", port: 30000, want: "__1-30000"},
+		{name: "ipv6 with zone", host: "[fe80::1%eth0]", port: 27017, want: "fe80__1_eth0-27017"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetHostPortDir(tt.host, tt.port)
+			if got != tt.want {
+				t.Errorf("GetHostPortDir(%q, %d) = %q, want %q", tt.host, tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetNodeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodeType string
+		host     string
+		port     int
+		want     string
+	}{
+		{name: "hostname", nodeType: "mongod", host: "db1", port: 27017, want: "mongod-db1-27017"},
+		{name: "ipv6", nodeType: "mongos", host: "::1", port: 30300, want: "mongos-__1-30300"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetNodeName(tt.nodeType, tt.host, tt.port)
+			if got != tt.want {
+				t.Errorf("GetNodeName(%q, %q, %d) = %q, want %q", tt.nodeType, tt.host, tt.port, got, tt.want)
+			}
+		})
+	}
+}
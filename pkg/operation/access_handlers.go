@@ -0,0 +1,301 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/zph/mup/pkg/access"
+	"github.com/zph/mup/pkg/apply"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+)
+
+// Error codes that make re-running a users plan safe
+const (
+	userNotFoundCode = 11
+	roleNotFoundCode = 31
+	roleExistsCode   = 51002
+)
+
+// ManageDBUserParams defines typed parameters for manage_db_user operation
+type ManageDBUserParams struct {
+	Action  string      `json:"action" validate:"required"`  // create, update or drop
+	Address string      `json:"address" validate:"required"` // mongos or replica set seed list
+	User    access.User `json:"user"`
+
+	// CredentialsFile holds the password of created users. Without an
+	// explicit password reference one is generated if the store has none.
+	CredentialsFile  string `json:"credentials_file,omitempty"`
+	GeneratePassword bool   `json:"generate_password,omitempty"`
+}
+
+// ManageDBRoleParams defines typed parameters for manage_db_role operation
+type ManageDBRoleParams struct {
+	Action  string      `json:"action" validate:"required"`
+	Address string      `json:"address" validate:"required"`
+	Role    access.Role `json:"role"`
+}
+
+// ManageDBUserHandler creates, updates or drops a database user, logged in
+// as the admin user. Creating an existing user updates its roles and
+// dropping a missing one succeeds, so a users plan can be re-run.
+type ManageDBUserHandler struct{}
+
+// IsComplete always returns false; Execute is idempotent
+// REQ-PES-036: Check if operation was already completed
+func (h *ManageDBUserHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	return false, nil
+}
+
+// PreHook validates parameters
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *ManageDBUserHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if _, err := h.unmarshalParams(op.Params); err != nil {
+		result.AddError(err.Error())
+	}
+	if _, err := clientSecurity(op, exec); err != nil {
+		result.AddError(err.Error())
+	}
+	return result, nil
+}
+
+// Execute runs createUser, updateUser or dropUser on the user's database
+func (h *ManageDBUserHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	sec, err := clientSecurity(op, exec)
+	if err != nil {
+		return nil, err
+	}
+	user := &params.User
+
+	client, err := NewSecureMongoDBClient(ctx, params.Address, exec, false, sec)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+
+	updateRoles := bson.D{
+		{Key: "updateUser", Value: user.Name},
+		{Key: "roles", Value: access.RolesBSON(user.Roles)},
+	}
+	switch params.Action {
+	case access.ActionCreate:
+		cmd := bson.D{{Key: "createUser", Value: user.Name}}
+		if user.DB != access.ExternalDB {
+			password, err := h.password(params, exec)
+			if err != nil {
+				return nil, err
+			}
+			cmd = append(cmd, bson.E{Key: "pwd", Value: password})
+		}
+		cmd = append(cmd, bson.E{Key: "roles", Value: access.RolesBSON(user.Roles)})
+		_, err = client.RunOrderedCommandOn(ctx, user.DB, cmd)
+		if isCommandError(err, userExistsCode) {
+			_, err = client.RunOrderedCommandOn(ctx, user.DB, updateRoles)
+		}
+	case access.ActionUpdate:
+		_, err = client.RunOrderedCommandOn(ctx, user.DB, updateRoles)
+	case access.ActionDrop:
+		_, err = client.RunOrderedCommandOn(ctx, user.DB, bson.D{{Key: "dropUser", Value: user.Name}})
+		if isCommandError(err, userNotFoundCode) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s user %s: %w", params.Action, user.ID(), err)
+	}
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("%s user %s", params.Action, user.ID()),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"user":   user.ID(),
+			"action": params.Action,
+		},
+	}, nil
+}
+
+// PostHook reports the changed user
+// REQ-PES-048: Post-execution verification
+func (h *ManageDBUserHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if params, err := h.unmarshalParams(op.Params); err == nil {
+		result.Metadata["user"] = params.User.ID()
+	}
+	return result, nil
+}
+
+// password returns the created user's password from the credential store
+func (h *ManageDBUserHandler) password(params *ManageDBUserParams, exec executor.Executor) (string, error) {
+	if isSimulation(exec) {
+		return simulatedPassword, nil
+	}
+	name := params.User.CredentialName()
+	if params.GeneratePassword {
+		return security.EnsurePassword(params.CredentialsFile, name)
+	}
+	return security.LookupPassword(params.CredentialsFile, name, "")
+}
+
+func (h *ManageDBUserHandler) unmarshalParams(params map[string]interface{}) (*ManageDBUserParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed ManageDBUserParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if err := validateAccessAction(typed.Action, typed.Address); err != nil {
+		return nil, err
+	}
+	if typed.User.Name == "" || typed.User.DB == "" {
+		return nil, fmt.Errorf("user needs a name and db")
+	}
+	if typed.Action == access.ActionCreate && typed.User.DB != access.ExternalDB && typed.CredentialsFile == "" {
+		return nil, fmt.Errorf("credentials_file is required to create user %s", typed.User.ID())
+	}
+
+	return &typed, nil
+}
+
+// ManageDBRoleHandler creates, updates or drops a custom role, logged in as
+// the admin user. Like ManageDBUserHandler it can be re-run.
+type ManageDBRoleHandler struct{}
+
+// IsComplete always returns false; Execute is idempotent
+// REQ-PES-036: Check if operation was already completed
+func (h *ManageDBRoleHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	return false, nil
+}
+
+// PreHook validates parameters
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *ManageDBRoleHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if _, err := h.unmarshalParams(op.Params); err != nil {
+		result.AddError(err.Error())
+	}
+	if _, err := clientSecurity(op, exec); err != nil {
+		result.AddError(err.Error())
+	}
+	return result, nil
+}
+
+// Execute runs createRole, updateRole or dropRole on the role's database
+func (h *ManageDBRoleHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	sec, err := clientSecurity(op, exec)
+	if err != nil {
+		return nil, err
+	}
+	role := &params.Role
+
+	client, err := NewSecureMongoDBClient(ctx, params.Address, exec, false, sec)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+
+	grants := func(command string) bson.D {
+		return bson.D{
+			{Key: command, Value: role.Name},
+			{Key: "privileges", Value: access.PrivilegesBSON(role.Privileges)},
+			{Key: "roles", Value: access.RolesBSON(role.Roles)},
+		}
+	}
+	switch params.Action {
+	case access.ActionCreate:
+		_, err = client.RunOrderedCommandOn(ctx, role.DB, grants("createRole"))
+		if isCommandError(err, roleExistsCode) {
+			_, err = client.RunOrderedCommandOn(ctx, role.DB, grants("updateRole"))
+		}
+	case access.ActionUpdate:
+		_, err = client.RunOrderedCommandOn(ctx, role.DB, grants("updateRole"))
+	case access.ActionDrop:
+		_, err = client.RunOrderedCommandOn(ctx, role.DB, bson.D{{Key: "dropRole", Value: role.Name}})
+		if isCommandError(err, roleNotFoundCode) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s role %s: %w", params.Action, role.ID(), err)
+	}
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("%s role %s", params.Action, role.ID()),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"role":   role.ID(),
+			"action": params.Action,
+		},
+	}, nil
+}
+
+// PostHook reports the changed role
+// REQ-PES-048: Post-execution verification
+func (h *ManageDBRoleHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if params, err := h.unmarshalParams(op.Params); err == nil {
+		result.Metadata["role"] = params.Role.ID()
+	}
+	return result, nil
+}
+
+func (h *ManageDBRoleHandler) unmarshalParams(params map[string]interface{}) (*ManageDBRoleParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed ManageDBRoleParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if err := validateAccessAction(typed.Action, typed.Address); err != nil {
+		return nil, err
+	}
+	if typed.Role.Name == "" || typed.Role.DB == "" {
+		return nil, fmt.Errorf("role needs a name and db")
+	}
+
+	return &typed, nil
+}
+
+// validateAccessAction checks the action and address of a user or role
+// operation
+func validateAccessAction(action, address string) error {
+	switch action {
+	case access.ActionCreate, access.ActionUpdate, access.ActionDrop:
+	default:
+		return fmt.Errorf("action must be create, update or drop, got %q", action)
+	}
+	if address == "" {
+		return fmt.Errorf("address is required")
+	}
+	return nil
+}
+
+// isCommandError reports whether err is a MongoDB command error with code
+func isCommandError(err error, code int32) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == code
+}
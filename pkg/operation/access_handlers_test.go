@@ -0,0 +1,122 @@
+package operation_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/access"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/operation"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/simulation"
+)
+
+func recordedCommands(exec *simulation.SimulationExecutor) string {
+	var commands []string
+	for _, recorded := range exec.GetOperations() {
+		commands = append(commands, recorded.Details)
+	}
+	return strings.Join(commands, "\n")
+}
+
+func TestManageDBUserHandler_Simulation(t *testing.T) {
+	handler := &operation.ManageDBUserHandler{}
+	ctx := context.Background()
+	auth := &deploy.AuthParams{User: "admin", PasswordFile: "/nonexistent/admin.password"}
+	user := access.User{Name: "app", DB: "app", Roles: []access.RoleRef{{Role: "readWrite", DB: "app"}}}
+
+	tests := []struct {
+		action string
+		want   string
+	}{
+		{access.ActionCreate, `client.Database("app").RunCommand({"createUser":"app","pwd":"simulated","roles":[{"role":"readWrite","db":"app"}]})`},
+		{access.ActionUpdate, `client.Database("app").RunCommand({"updateUser":"app","roles":[{"role":"readWrite","db":"app"}]})`},
+		{access.ActionDrop, `client.Database("app").RunCommand({"dropUser":"app"})`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			op := NewTestOperation(plan.OpManageDBUser, map[string]interface{}{
+				"action":            tt.action,
+				"address":           "app1:27017",
+				"auth":              auth,
+				"user":              user,
+				"credentials_file":  "/nonexistent/credentials.enc",
+				"generate_password": true,
+			})
+			exec := simulation.NewExecutor(simulation.NewConfig())
+
+			pre, err := handler.PreHook(ctx, op, exec)
+			require.NoError(t, err)
+			assert.True(t, pre.Valid, pre.Errors)
+
+			result, err := handler.Execute(ctx, op, exec)
+			require.NoError(t, err)
+			assert.Equal(t, "app@app", result.Metadata["user"])
+			assert.Contains(t, recordedCommands(exec), tt.want)
+		})
+	}
+}
+
+func TestManageDBUserHandler_ExternalUser(t *testing.T) {
+	op := NewTestOperation(plan.OpManageDBUser, map[string]interface{}{
+		"action":  access.ActionCreate,
+		"address": "app1:27017",
+		"user":    access.User{Name: "CN=reporting", DB: access.ExternalDB},
+	})
+	exec := simulation.NewExecutor(simulation.NewConfig())
+
+	_, err := (&operation.ManageDBUserHandler{}).Execute(context.Background(), op, exec)
+	require.NoError(t, err)
+	assert.Contains(t, recordedCommands(exec), `client.Database("$external").RunCommand({"createUser":"CN=reporting","roles":[]})`)
+}
+
+func TestManageDBUserHandler_PreHook(t *testing.T) {
+	handler := &operation.ManageDBUserHandler{}
+	exec := executor.NewLocalExecutor()
+
+	op := NewTestOperation(plan.OpManageDBUser, map[string]interface{}{
+		"action":  "rename",
+		"address": "app1:27017",
+		"user":    access.User{Name: "app", DB: "app"},
+	})
+	pre, err := handler.PreHook(context.Background(), op, exec)
+	require.NoError(t, err)
+	assert.False(t, pre.Valid)
+	assert.Contains(t, strings.Join(pre.Errors, "\n"), "action must be create, update or drop")
+
+	op = NewTestOperation(plan.OpManageDBUser, map[string]interface{}{
+		"action":  access.ActionCreate,
+		"address": "app1:27017",
+		"user":    access.User{Name: "app", DB: "app"},
+	})
+	pre, err = handler.PreHook(context.Background(), op, exec)
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(pre.Errors, "\n"), "credentials_file is required")
+}
+
+func TestManageDBRoleHandler_Simulation(t *testing.T) {
+	role := access.Role{
+		Name: "ops",
+		DB:   "admin",
+		Privileges: []access.Privilege{
+			{Resource: access.Resource{Cluster: true}, Actions: []string{"serverStatus"}},
+			{Resource: access.Resource{DB: "app"}, Actions: []string{"find"}},
+		},
+	}
+	op := NewTestOperation(plan.OpManageDBRole, map[string]interface{}{
+		"action":  access.ActionCreate,
+		"address": "app1:27017",
+		"role":    role,
+	})
+	exec := simulation.NewExecutor(simulation.NewConfig())
+
+	_, err := (&operation.ManageDBRoleHandler{}).Execute(context.Background(), op, exec)
+	require.NoError(t, err)
+	assert.Contains(t, recordedCommands(exec), `client.Database("admin").RunCommand({"createRole":"ops","privileges":[`+
+		`{"resource":{"cluster":true},"actions":["serverStatus"]},`+
+		`{"resource":{"db":"app","collection":""},"actions":["find"]}],"roles":[]})`)
+}
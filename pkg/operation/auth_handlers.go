@@ -0,0 +1,426 @@
+package operation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zph/mup/pkg/apply"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/plan"
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// restartTimeout bounds how long a restarted node may take to rejoin
+	restartTimeout = 2 * time.Minute
+	// restartPollInterval is how often a restarting node is checked
+	restartPollInterval = 2 * time.Second
+	// userExistsCode is the error code of createUser for an existing user
+	userExistsCode = 51003
+)
+
+// ConfigureNodeAuthParams defines typed parameters for configure_node_auth operation
+type ConfigureNodeAuthParams struct {
+	ConfigFile       string `json:"config_file" validate:"required"`
+	KeyFile          string `json:"key_file" validate:"required"`
+	Authorization    bool   `json:"authorization"` // Set security.authorization, which mongos does not accept
+	TransitionToAuth bool   `json:"transition_to_auth"`
+}
+
+// ConfigureNodeAuthHandler edits a node's configuration file to use the
+// cluster keyFile, with or without security.transitionToAuth. Everything
+// else in the file is kept. The node picks the change up when it restarts.
+type ConfigureNodeAuthHandler struct{}
+
+// IsComplete always returns false; Execute is a no-op on a configured node
+// REQ-PES-036: Check if operation was already completed
+func (h *ConfigureNodeAuthHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	return false, nil
+}
+
+// PreHook validates parameters and that the configuration file exists
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *ConfigureNodeAuthHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		result.AddError(err.Error())
+		return result, nil
+	}
+	if isSimulation(exec) {
+		return result, nil
+	}
+	exists, err := exec.FileExistsContext(ctx, params.ConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("check config exists: %w", err)
+	}
+	if !exists {
+		result.AddError(fmt.Sprintf("config file not found: %s", params.ConfigFile))
+	}
+	return result, nil
+}
+
+// Execute rewrites the security section of the configuration file
+func (h *ConfigureNodeAuthHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	result := &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("Set keyFile %s in %s (transitionToAuth: %t)", params.KeyFile, params.ConfigFile, params.TransitionToAuth),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"config_file":        params.ConfigFile,
+			"transition_to_auth": params.TransitionToAuth,
+		},
+	}
+
+	// The configuration file is not read in simulation
+	if isSimulation(exec) {
+		result.Output = fmt.Sprintf("Would set keyFile %s in %s (transitionToAuth: %t)", params.KeyFile, params.ConfigFile, params.TransitionToAuth)
+		return result, nil
+	}
+
+	content, err := exec.ExecuteContext(ctx, "cat "+executor.ShellQuote(params.ConfigFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", params.ConfigFile, err)
+	}
+	updated, err := setNodeAuth([]byte(content), params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update %s: %w", params.ConfigFile, err)
+	}
+	if err := exec.UploadContentContext(ctx, updated, params.ConfigFile); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", params.ConfigFile, err)
+	}
+
+	return result, nil
+}
+
+// PostHook verifies the configuration file holds the keyFile
+// REQ-PES-048: Post-execution verification
+func (h *ConfigureNodeAuthHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if isSimulation(exec) {
+		return result, nil
+	}
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	content, err := exec.ExecuteContext(ctx, "cat "+executor.ShellQuote(params.ConfigFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", params.ConfigFile, err)
+	}
+	if !strings.Contains(content, params.KeyFile) {
+		result.AddError(fmt.Sprintf("keyFile is not set in %s", params.ConfigFile))
+	}
+	result.Metadata["verified"] = result.Valid
+	return result, nil
+}
+
+func (h *ConfigureNodeAuthHandler) unmarshalParams(params map[string]interface{}) (*ConfigureNodeAuthParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed ConfigureNodeAuthParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if typed.ConfigFile == "" {
+		return nil, fmt.Errorf("config_file is required")
+	}
+	if typed.KeyFile == "" {
+		return nil, fmt.Errorf("key_file is required")
+	}
+
+	return &typed, nil
+}
+
+// setNodeAuth returns the YAML configuration content with the security
+// settings in params, leaving every other setting as it was
+func setNodeAuth(content []byte, params *ConfigureNodeAuthParams) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("configuration is not a YAML mapping")
+	}
+
+	security := mappingValue(root, "security")
+	if security.Kind != yaml.MappingNode {
+		*security = yaml.Node{Kind: yaml.MappingNode}
+	}
+	if params.Authorization {
+		setScalar(security, "authorization", "enabled", "!!str")
+	}
+	setScalar(security, "keyFile", params.KeyFile, "!!str")
+	if params.TransitionToAuth {
+		setScalar(security, "transitionToAuth", "true", "!!bool")
+	} else {
+		removeKey(security, "transitionToAuth")
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mappingValue returns the value of key in mapping, adding an empty value
+// when the key is missing
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
+
+// setScalar sets key in mapping to a scalar value with tag
+func setScalar(mapping *yaml.Node, key, value, tag string) {
+	*mappingValue(mapping, key) = yaml.Node{Kind: yaml.ScalarNode, Value: value, Tag: tag}
+}
+
+// removeKey removes key and its value from mapping
+func removeKey(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// RestartNodeParams defines typed parameters for restart_node operation
+type RestartNodeParams struct {
+	Node           string `json:"node" validate:"required"` // host:port clients connect to
+	ReplicaSet     string `json:"replica_set,omitempty"`
+	Members        int    `json:"members,omitempty"` // Members of ReplicaSet
+	RestartCommand string `json:"restart_command" validate:"required"`
+}
+
+// RestartNodeHandler restarts one node of a rolling restart. A primary
+// steps down first, and the operation completes once the node is back as
+// a primary or secondary, or answers commands outside a replica set.
+type RestartNodeHandler struct{}
+
+// IsComplete always returns false so the node is restarted
+// REQ-PES-036: Check if operation was already completed
+func (h *RestartNodeHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	return false, nil
+}
+
+// PreHook validates parameters
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *RestartNodeHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if _, err := h.unmarshalParams(op.Params); err != nil {
+		result.AddError(err.Error())
+	}
+	if _, err := clientSecurity(op, exec); err != nil {
+		result.AddError(err.Error())
+	}
+	return result, nil
+}
+
+// Execute steps the node down if it is primary, restarts it and waits
+// until it is healthy
+func (h *RestartNodeHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	sec, err := clientSecurity(op, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single-member replica set has no one to hand over to
+	if params.ReplicaSet != "" && params.Members > 1 && !isSimulation(exec) {
+		if err := h.stepDown(ctx, params, exec, sec); err != nil {
+			return nil, err
+		}
+	}
+
+	if output, err := exec.ExecuteContext(ctx, params.RestartCommand); err != nil {
+		return nil, fmt.Errorf("failed to restart %s: %w (output: %s)", params.Node, err, strings.TrimSpace(output))
+	}
+
+	state := "restarted"
+	if !isSimulation(exec) {
+		waitCtx, cancel := context.WithTimeout(ctx, restartTimeout)
+		defer cancel()
+		if state, err = h.waitHealthy(waitCtx, params, exec, sec); err != nil {
+			return nil, fmt.Errorf("node %s did not come back: %w", params.Node, err)
+		}
+	}
+	fmt.Printf("  ✓ %s %s\n", params.Node, state)
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("Restarted %s (%s)", params.Node, state),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"node":  params.Node,
+			"state": state,
+		},
+	}, nil
+}
+
+// PostHook reports the restarted node
+// REQ-PES-048: Post-execution verification
+func (h *RestartNodeHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if params, err := h.unmarshalParams(op.Params); err == nil {
+		result.Metadata["node"] = params.Node
+	}
+	result.Metadata["verified"] = true
+	return result, nil
+}
+
+// stepDown makes the node hand over to another member if it is primary
+// and waits until another member is primary
+func (h *RestartNodeHandler) stepDown(ctx context.Context, params *RestartNodeParams, exec executor.Executor, sec *ClientSecurity) error {
+	hello, err := isMaster(ctx, params.Node, exec, sec)
+	if err != nil {
+		return fmt.Errorf("failed to check %s before restarting: %w", params.Node, err)
+	}
+	if primary, _ := hello["ismaster"].(bool); !primary {
+		return nil
+	}
+
+	fmt.Printf("  Stepping down primary %s\n", params.Node)
+	client, err := NewSecureMongoDBClient(ctx, params.Node, exec, true, sec)
+	if err != nil {
+		return err
+	}
+	// The primary closes connections as it steps down, so the command may
+	// fail after succeeding
+	_, _ = client.RunOrderedCommand(ctx, bson.D{
+		{Key: "replSetStepDown", Value: 60},
+		{Key: "secondaryCatchUpPeriodSecs", Value: 10},
+	})
+	_ = client.Disconnect(ctx)
+
+	waitCtx, cancel := context.WithTimeout(ctx, restartTimeout)
+	defer cancel()
+	for {
+		hello, err := isMaster(waitCtx, params.Node, exec, sec)
+		if err == nil {
+			primary, _ := hello["primary"].(string)
+			if isPrimary, _ := hello["ismaster"].(bool); !isPrimary && primary != "" {
+				return nil
+			}
+		}
+		if err := sleepContext(waitCtx, restartPollInterval); err != nil {
+			return fmt.Errorf("no new primary in %s after %s stepped down: %w", params.ReplicaSet, params.Node, err)
+		}
+	}
+}
+
+// waitHealthy polls the node until it is a primary or secondary, or for a
+// node outside a replica set, until it answers
+func (h *RestartNodeHandler) waitHealthy(ctx context.Context, params *RestartNodeParams, exec executor.Executor, sec *ClientSecurity) (string, error) {
+	var lastErr error
+	for {
+		hello, err := isMaster(ctx, params.Node, exec, sec)
+		switch {
+		case err != nil:
+			lastErr = err
+		case params.ReplicaSet == "":
+			return "healthy", nil
+		default:
+			if primary, _ := hello["ismaster"].(bool); primary {
+				return "PRIMARY", nil
+			}
+			if secondary, _ := hello["secondary"].(bool); secondary {
+				return "SECONDARY", nil
+			}
+			lastErr = fmt.Errorf("not yet primary or secondary")
+		}
+		if err := sleepContext(ctx, restartPollInterval); err != nil {
+			return "", fmt.Errorf("%w (last error: %v)", err, lastErr)
+		}
+	}
+}
+
+func (h *RestartNodeHandler) unmarshalParams(params map[string]interface{}) (*RestartNodeParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed RestartNodeParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if typed.Node == "" {
+		return nil, fmt.Errorf("node is required")
+	}
+	if typed.RestartCommand == "" {
+		return nil, fmt.Errorf("restart_command is required")
+	}
+
+	return &typed, nil
+}
+
+// isMaster runs isMaster on the node at address over a direct connection
+func isMaster(ctx context.Context, address string, exec executor.Executor, sec *ClientSecurity) (bson.M, error) {
+	client, err := NewSecureMongoDBClient(ctx, address, exec, true, sec)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+	return client.RunCommand(ctx, bson.M{"isMaster": 1}, false)
+}
+
+// createUserAt creates cred's user with the root role through the cluster
+// at address, a replica set seed list or mongos, connecting without
+// logging in. Nodes running with transitionToAuth allow this.
+func createUserAt(ctx context.Context, address string, exec executor.Executor, cred *Credential, caFile string) (bool, error) {
+	client, err := NewSecureMongoDBClient(ctx, address, exec, false, &ClientSecurity{CAFile: caFile})
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+
+	_, err = client.RunOrderedCommand(ctx, bson.D{
+		{Key: "createUser", Value: cred.Username},
+		{Key: "pwd", Value: cred.Password},
+		{Key: "roles", Value: bson.A{bson.M{"role": "root", "db": "admin"}}},
+	})
+	if isCommandError(err, userExistsCode) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create user %s: %w", cred.Username, err)
+	}
+	return false, nil
+}
@@ -0,0 +1,154 @@
+package operation_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/operation"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/simulation"
+)
+
+const mongodConfig = `# mongod configuration
+net:
+  port: 27017
+  bindIp: 127.0.0.1
+storage:
+  dbPath: /data/db
+security:
+  authorization: disabled
+`
+
+func TestConfigureNodeAuthHandler(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "mongod.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte(mongodConfig), 0644))
+
+	handler := &operation.ConfigureNodeAuthHandler{}
+	exec := executor.NewLocalExecutor()
+	ctx := context.Background()
+	params := map[string]interface{}{
+		"config_file":        configFile,
+		"key_file":           "/opt/mup/secrets/keyfile",
+		"authorization":      true,
+		"transition_to_auth": true,
+	}
+
+	// Running twice leaves the same configuration
+	for i := 0; i < 2; i++ {
+		op := NewTestOperation(plan.OpConfigureNodeAuth, params)
+		pre, err := handler.PreHook(ctx, op, exec)
+		require.NoError(t, err)
+		assert.True(t, pre.Valid, pre.Errors)
+		_, err = handler.Execute(ctx, op, exec)
+		require.NoError(t, err)
+	}
+
+	content, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, `# mongod configuration
+net:
+  port: 27017
+  bindIp: 127.0.0.1
+storage:
+  dbPath: /data/db
+security:
+  authorization: enabled
+  keyFile: /opt/mup/secrets/keyfile
+  transitionToAuth: true
+`, string(content))
+
+	params["transition_to_auth"] = false
+	op := NewTestOperation(plan.OpConfigureNodeAuth, params)
+	_, err = handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	post, err := handler.PostHook(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, post.Valid, post.Errors)
+
+	content, err = os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "keyFile: /opt/mup/secrets/keyfile")
+	assert.NotContains(t, string(content), "transitionToAuth")
+}
+
+func TestConfigureNodeAuthHandler_Mongos(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "mongos.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte("net:\n  port: 27016\nsecurity:\n"), 0644))
+
+	op := NewTestOperation(plan.OpConfigureNodeAuth, map[string]interface{}{
+		"config_file":        configFile,
+		"key_file":           "/opt/mup/secrets/keyfile",
+		"transition_to_auth": true,
+	})
+	_, err := (&operation.ConfigureNodeAuthHandler{}).Execute(context.Background(), op, executor.NewLocalExecutor())
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "net:\n  port: 27016\nsecurity:\n  keyFile: /opt/mup/secrets/keyfile\n  transitionToAuth: true\n", string(content))
+}
+
+func TestRestartNodeHandler_Simulation(t *testing.T) {
+	op := NewTestOperation(plan.OpRestartNode, map[string]interface{}{
+		"node":            "db1:27017",
+		"replica_set":     "rs0",
+		"members":         3,
+		"restart_command": "supervisord ctl restart mongod-27017",
+	})
+	handler := &operation.RestartNodeHandler{}
+	exec := simulation.NewExecutor(simulation.NewConfig())
+	ctx := context.Background()
+
+	pre, err := handler.PreHook(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, pre.Valid, pre.Errors)
+
+	result, err := handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, "db1:27017", result.Metadata["node"])
+
+	var commands []string
+	for _, recorded := range exec.GetOperations() {
+		commands = append(commands, recorded.Details)
+	}
+	assert.Contains(t, commands, "supervisord ctl restart mongod-27017")
+}
+
+func TestRestartNodeHandler_PreHook(t *testing.T) {
+	op := NewTestOperation(plan.OpRestartNode, map[string]interface{}{"node": "db1:27017"})
+	pre, err := (&operation.RestartNodeHandler{}).PreHook(context.Background(), op, executor.NewLocalExecutor())
+	require.NoError(t, err)
+	assert.False(t, pre.Valid)
+	assert.Contains(t, pre.Errors, "restart_command is required")
+}
+
+func TestCreateAdminUserHandler_Address(t *testing.T) {
+	op := NewTestOperation(plan.OpCreateAdminUser, map[string]interface{}{
+		"address": "db1:27017,db2:27017/?replicaSet=rs0",
+		"auth":    &deploy.AuthParams{User: "admin", PasswordFile: "/nonexistent/admin.password"},
+	})
+	handler := &operation.CreateAdminUserHandler{}
+	exec := simulation.NewExecutor(simulation.NewConfig())
+	ctx := context.Background()
+
+	pre, err := handler.PreHook(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, pre.Valid, "no shell is needed with an address: %v", pre.Errors)
+
+	result, err := handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, false, result.Metadata["already_exists"])
+
+	var commands []string
+	for _, recorded := range exec.GetOperations() {
+		commands = append(commands, recorded.Details)
+	}
+	assert.Contains(t, strings.Join(commands, "\n"), `"createUser":"admin"`)
+}
@@ -0,0 +1,221 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/zph/mup/pkg/apply"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/plan"
+)
+
+// DistributeBinaryParams defines typed parameters for distribute_binary operation
+type DistributeBinaryParams struct {
+	DestDir     string `json:"dest_dir" validate:"required"`
+	Concurrency int    `json:"concurrency,omitempty"`
+}
+
+// DistributeBinaryHandler uploads and extracts MongoDB binaries on a remote host.
+// One handler instance serves every distribute_binary operation in a plan, so
+// the tarball for each platform is downloaded and built once, and concurrent
+// uploads are capped by a shared semaphore.
+type DistributeBinaryHandler struct {
+	binaryMgr *deploy.BinaryManager
+
+	semOnce sync.Once
+	sem     chan struct{}
+}
+
+// NewDistributeBinaryHandler creates a new DistributeBinaryHandler
+func NewDistributeBinaryHandler() (*DistributeBinaryHandler, error) {
+	bm, err := deploy.NewBinaryManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create binary manager: %w", err)
+	}
+	return &DistributeBinaryHandler{binaryMgr: bm}, nil
+}
+
+// IsComplete checks whether the host already holds the same package. It
+// compares the host's checksum marker with the locally cached package and
+// never downloads: without a cached package the host cannot be verified, so
+// Execute fetches it.
+// REQ-PES-036: Check if operation was already completed
+func (h *DistributeBinaryHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return false, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	remote := deploy.RemoteChecksum(ctx, exec, params.DestDir)
+	if remote == "" || h.binaryMgr == nil {
+		return false, nil
+	}
+
+	// Let Execute surface variant and platform errors
+	variant, err := parseVariant(op.Target.Params["variant"])
+	if err != nil {
+		return false, nil
+	}
+	osInfo, err := exec.GetOSInfoContext(ctx)
+	if err != nil {
+		return false, nil
+	}
+
+	pkg, ok := h.binaryMgr.CachedPackage(op.Target.Params["version"], variant, deploy.PlatformFromOSInfo(osInfo))
+	return ok && remote == pkg.Checksum, nil
+}
+
+// PreHook validates parameters
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *DistributeBinaryHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+
+	if _, err := h.unmarshalParams(op.Params); err != nil {
+		result.AddError(err.Error())
+		return result, nil
+	}
+	if _, ok := op.Target.Params["version"]; !ok {
+		result.AddError("missing required parameter: version")
+	}
+	if _, err := parseVariant(op.Target.Params["variant"]); err != nil {
+		result.AddError(err.Error())
+	}
+	if h.binaryMgr == nil {
+		result.AddError("binary manager not initialized")
+	}
+
+	return result, nil
+}
+
+// Execute uploads and extracts the platform's tarball unless the host is up to date
+func (h *DistributeBinaryHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	pkg, err := h.packageFor(ctx, op, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bound the number of hosts uploading at once
+	sem := h.semaphore(params.Concurrency)
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("distribution aborted: %w", ctx.Err())
+	}
+	defer func() { <-sem }()
+
+	uploaded, err := deploy.InstallPackage(ctx, exec, pkg, params.DestDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to distribute binaries to %s: %w", op.Target.Host, err)
+	}
+
+	output := fmt.Sprintf("Distributed %s binaries to %s:%s", pkg.Platform.Key(), op.Target.Host, params.DestDir)
+	if !uploaded {
+		output = fmt.Sprintf("Binaries on %s:%s already match %s, skipped upload", op.Target.Host, params.DestDir, pkg.Checksum[:12])
+	}
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  output,
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"platform": pkg.Platform.Key(),
+			"checksum": pkg.Checksum,
+			"uploaded": uploaded,
+		},
+	}, nil
+}
+
+// PostHook verifies the checksum marker and mongod are present
+// REQ-PES-048: Post-execution verification and user hooks
+func (h *DistributeBinaryHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	result := NewHookResult()
+	if deploy.RemoteChecksum(ctx, exec, params.DestDir) == "" {
+		result.AddError(fmt.Sprintf("checksum marker missing in %s", params.DestDir))
+		return result, nil
+	}
+
+	result.Metadata["verified"] = true
+	return result, nil
+}
+
+// Close releases the binary manager
+func (h *DistributeBinaryHandler) Close() error {
+	if h.binaryMgr != nil {
+		return h.binaryMgr.Close()
+	}
+	return nil
+}
+
+// packageFor detects the host platform and returns its (shared) package
+func (h *DistributeBinaryHandler) packageFor(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*deploy.BinaryPackage, error) {
+	if h.binaryMgr == nil {
+		return nil, fmt.Errorf("binary manager not initialized")
+	}
+
+	variant, err := parseVariant(op.Target.Params["variant"])
+	if err != nil {
+		return nil, err
+	}
+
+	osInfo, err := exec.GetOSInfoContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect platform of %s: %w", op.Target.Host, err)
+	}
+
+	return h.binaryMgr.PackageBinaries(op.Target.Params["version"], variant, deploy.PlatformFromOSInfo(osInfo))
+}
+
+// semaphore returns the upload semaphore, sized by the first operation seen
+func (h *DistributeBinaryHandler) semaphore(concurrency int) chan struct{} {
+	h.semOnce.Do(func() {
+		if concurrency <= 0 {
+			concurrency = deploy.DefaultDistributionConcurrency
+		}
+		h.sem = make(chan struct{}, concurrency)
+	})
+	return h.sem
+}
+
+// unmarshalParams handles JSON unmarshaling with type conversion
+func (h *DistributeBinaryHandler) unmarshalParams(params map[string]interface{}) (*DistributeBinaryParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed DistributeBinaryParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if typed.DestDir == "" {
+		return nil, fmt.Errorf("dest_dir is required")
+	}
+
+	return &typed, nil
+}
+
+// parseVariant converts a plan variant string to a deploy.Variant
+func parseVariant(s string) (deploy.Variant, error) {
+	switch s {
+	case "mongo":
+		return deploy.VariantMongo, nil
+	case "percona":
+		return deploy.VariantPercona, nil
+	default:
+		return deploy.VariantMongo, fmt.Errorf("invalid variant: %s (must be 'mongo' or 'percona')", s)
+	}
+}
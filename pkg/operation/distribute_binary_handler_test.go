@@ -0,0 +1,58 @@
+package operation_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/operation"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/simulation"
+)
+
+// IsComplete compares the host's checksum marker with the cached package
+// and never downloads binaries
+func TestDistributeBinaryHandler_IsCompleteDoesNotDownload(t *testing.T) {
+	ctx := context.Background()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	handler, err := operation.NewDistributeBinaryHandler()
+	require.NoError(t, err)
+	op := NewTestOperation(plan.OpDistributeBinary, map[string]interface{}{"dest_dir": "/opt/mup/7.0.12/bin"})
+	op.Target.Host = "db1"
+	op.Target.Params = map[string]string{"version": "7.0.12", "variant": "mongo"}
+
+	tarball := []byte("mongo-7.0.12-linux-amd64 tarball")
+	sum := sha256.Sum256(tarball)
+	checksum := hex.EncodeToString(sum[:])
+
+	config := simulation.NewConfig()
+	config.Responses["cat '/opt/mup/7.0.12/bin/.mup-sha256' 2>/dev/null"] = checksum + "\n"
+	exec := simulation.NewExecutor(config)
+
+	// Without a cached package the host cannot be verified
+	done, err := handler.IsComplete(ctx, op, exec)
+	require.NoError(t, err)
+	assert.False(t, done)
+	packages := filepath.Join(home, ".mup", "storage", "packages")
+	assert.NoDirExists(t, filepath.Join(packages, "mongo-7.0.12-linux-amd64"), "IsComplete must not download binaries")
+
+	distDir := filepath.Join(packages, "dist")
+	require.NoError(t, os.MkdirAll(distDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(distDir, "mongo-7.0.12-linux-amd64.tar.gz"), tarball, 0644))
+
+	done, err = handler.IsComplete(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, done, "host holds the cached package")
+
+	config.Responses["cat '/opt/mup/7.0.12/bin/.mup-sha256' 2>/dev/null"] = "0000\n"
+	done, err = handler.IsComplete(ctx, op, exec)
+	require.NoError(t, err)
+	assert.False(t, done, "host holds a different package")
+}
@@ -62,6 +62,12 @@ func NewExecutorWithStorage(executors map[string]executor.Executor, storageDir s
 		downloadHandler = &DownloadBinaryHandler{} // Use empty handler as fallback
 	}
 
+	distributeHandler, err := NewDistributeBinaryHandler()
+	if err != nil {
+		fmt.Printf("Warning: failed to create DistributeBinaryHandler: %v\n", err)
+		distributeHandler = &DistributeBinaryHandler{} // Use empty handler as fallback
+	}
+
 	configHandler, err := NewGenerateConfigHandler()
 	if err != nil {
 		fmt.Printf("Warning: failed to create GenerateConfigHandler: %v\n", err)
@@ -74,6 +80,7 @@ func NewExecutorWithStorage(executors map[string]executor.Executor, storageDir s
 	// All handlers migrated to four-phase interface
 	e.RegisterHandler(plan.OpDownloadBinary, downloadHandler)
 	e.RegisterHandler(plan.OpCopyBinary, NewCopyBinaryHandler())
+	e.RegisterHandler(plan.OpDistributeBinary, distributeHandler)
 	e.RegisterHandler(plan.OpCreateDirectory, &CreateDirectoryHandler{})
 	e.RegisterHandler(plan.OpCreateSymlink, &CreateSymlinkHandler{})
 	e.RegisterHandler(plan.OpUploadFile, &UploadFileHandler{})
@@ -85,10 +92,29 @@ func NewExecutorWithStorage(executors map[string]executor.Executor, storageDir s
 	e.RegisterHandler(plan.OpWaitForReady, &WaitForReadyHandler{})
 	e.RegisterHandler(plan.OpInitReplicaSet, &InitReplicaSetHandler{})
 	e.RegisterHandler(plan.OpAddShard, &AddShardHandler{})
+	e.RegisterHandler(plan.OpSetDefaultRWConcern, &SetDefaultRWConcernHandler{})
 	e.RegisterHandler(plan.OpVerifyHealth, &VerifyHealthHandler{})
 	e.RegisterHandler(plan.OpSaveMetadata, saveMetadataHandler)
 	e.RegisterHandler(plan.OpStopProcess, &StopProcessHandler{})
 	e.RegisterHandler(plan.OpGenerateSupervisorCfg, &GenerateSupervisorConfigHandler{})
+	e.RegisterHandler(plan.OpGenerateKeyFile, &GenerateKeyFileHandler{})
+	e.RegisterHandler(plan.OpDistributeKeyFile, &DistributeKeyFileHandler{})
+	e.RegisterHandler(plan.OpCreateAdminUser, &CreateAdminUserHandler{})
+	e.RegisterHandler(plan.OpConfigureNodeAuth, &ConfigureNodeAuthHandler{})
+	e.RegisterHandler(plan.OpRestartNode, &RestartNodeHandler{})
+	e.RegisterHandler(plan.OpManageDBUser, &ManageDBUserHandler{})
+	e.RegisterHandler(plan.OpManageDBRole, &ManageDBRoleHandler{})
+	e.RegisterHandler(plan.OpGenerateCertificates, &GenerateCertificatesHandler{})
+	e.RegisterHandler(plan.OpDistributeCertificates, &DistributeCertificatesHandler{})
+	e.RegisterHandler(plan.OpGenerateEncryptionKey, &GenerateEncryptionKeyHandler{})
+
+	prepareOSHandler := NewPrepareOSHandler()
+	for _, opType := range []plan.OperationType{
+		plan.OpEnsureGroup, plan.OpEnsureUser, plan.OpSetUlimit,
+		plan.OpSetSysctl, plan.OpSetTHP, plan.OpCheckFilesystem,
+	} {
+		e.RegisterHandler(opType, prepareOSHandler)
+	}
 
 	return e
 }
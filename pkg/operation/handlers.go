@@ -1,7 +1,6 @@
 package operation
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -18,6 +17,8 @@ import (
 	"github.com/zph/mup/pkg/meta"
 	"github.com/zph/mup/pkg/mongo"
 	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/ports"
+	"github.com/zph/mup/pkg/security"
 	"github.com/zph/mup/pkg/supervisor"
 	"github.com/zph/mup/pkg/template"
 	"github.com/zph/mup/pkg/topology"
@@ -90,15 +91,25 @@ func (h *DownloadBinaryHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 		return nil, fmt.Errorf("unknown variant: %s", variantStr)
 	}
 
-	// Determine platform
-	platform := deploy.Platform{
-		OS:   "darwin", // TODO: Get from executor
-		Arch: "arm64",  // TODO: Get from executor
+	// Determine platform from the target executor
+	platform := deploy.GetCurrentPlatform()
+	osInfo, err := exec.GetOSInfoContext(ctx)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("download aborted: %w", ctxErr)
+		}
+		fmt.Printf("Warning: failed to detect target platform, using %s: %v\n", platform.Key(), err)
+	} else {
+		platform = deploy.Platform{OS: osInfo.OS, Arch: osInfo.Arch}
 	}
 
-	// Download binaries
-	binPath, err := h.binaryMgr.GetBinPathWithVariant(version, variant, platform)
+	// Cancellation (Ctrl-C, --timeout) stops the transfer and extraction
+	// before this returns, so nothing keeps writing after the operation fails
+	binPath, err := h.binaryMgr.GetBinPathWithVariantContext(ctx, version, variant, platform)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("download aborted: %w", ctxErr)
+		}
 		return nil, fmt.Errorf("failed to download binaries: %w", err)
 	}
 
@@ -159,8 +170,8 @@ func (h *CopyBinaryHandler) IsComplete(ctx context.Context, op *plan.PlannedOper
 	mongodPath := filepath.Join(destDir, "mongod")
 	shellPath := filepath.Join(destDir, shellBinary)
 
-	mongodExists, _ := exec.FileExists(mongodPath)
-	shellExists, _ := exec.FileExists(shellPath)
+	mongodExists, _ := exec.FileExistsContext(ctx, mongodPath)
+	shellExists, _ := exec.FileExistsContext(ctx, shellPath)
 
 	return mongodExists && shellExists, nil
 }
@@ -210,13 +221,13 @@ func (h *CopyBinaryHandler) Execute(ctx context.Context, op *plan.PlannedOperati
 		destFile := filepath.Join(destDir, binary)
 
 		// Copy file using UploadFile (works for both local and remote executors)
-		err := exec.UploadFile(srcFile, destFile)
+		err := exec.UploadFileContext(ctx, srcFile, destFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to copy %s: %w", binary, err)
 		}
 
 		// Set executable permissions
-		_, err = exec.Execute(fmt.Sprintf("chmod +x %s", destFile))
+		_, err = exec.ExecuteContext(ctx, fmt.Sprintf("chmod +x %s", destFile))
 		if err != nil {
 			return nil, fmt.Errorf("failed to set permissions on %s: %w", binary, err)
 		}
@@ -249,7 +260,7 @@ func (h *CopyBinaryHandler) PostHook(ctx context.Context, op *plan.PlannedOperat
 
 	for _, binary := range binaries {
 		binPath := filepath.Join(destDir, binary)
-		exists, err := exec.FileExists(binPath)
+		exists, err := exec.FileExistsContext(ctx, binPath)
 		if err != nil {
 			result.AddWarning(fmt.Sprintf("failed to verify %s: %v", binary, err))
 		} else if !exists {
@@ -273,7 +284,7 @@ type CreateDirectoryHandler struct{}
 func (h *CreateDirectoryHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
 	path := op.Params["path"].(string)
 
-	exists, err := exec.FileExists(path)
+	exists, err := exec.FileExistsContext(ctx, path)
 	if err != nil {
 		return false, fmt.Errorf("check directory exists: %w", err)
 	}
@@ -292,7 +303,7 @@ func (h *CreateDirectoryHandler) PreHook(ctx context.Context, op *plan.PlannedOp
 	}
 
 	// Check if already exists (warning)
-	exists, err := exec.FileExists(path)
+	exists, err := exec.FileExistsContext(ctx, path)
 	if err != nil {
 		result.AddWarning(fmt.Sprintf("unable to check if directory exists: %v", err))
 	} else if exists {
@@ -314,7 +325,7 @@ func (h *CreateDirectoryHandler) Execute(ctx context.Context, op *plan.PlannedOp
 		}
 	}
 
-	if err := exec.CreateDirectory(path, mode); err != nil {
+	if err := exec.CreateDirectoryContext(ctx, path, mode); err != nil {
 		return nil, fmt.Errorf("failed to create directory %s: %w", path, err)
 	}
 
@@ -331,7 +342,7 @@ func (h *CreateDirectoryHandler) PostHook(ctx context.Context, op *plan.PlannedO
 
 	path := op.Params["path"].(string)
 
-	exists, err := exec.FileExists(path)
+	exists, err := exec.FileExistsContext(ctx, path)
 	if err != nil {
 		return nil, fmt.Errorf("check directory exists: %w", err)
 	}
@@ -500,7 +511,7 @@ func (h *UploadFileHandler) IsComplete(ctx context.Context, op *plan.PlannedOper
 	remotePath := op.Params["remote_path"].(string)
 
 	// Check if file exists at remote path
-	exists, err := exec.FileExists(remotePath)
+	exists, err := exec.FileExistsContext(ctx, remotePath)
 	if err != nil {
 		return false, fmt.Errorf("check file exists: %w", err)
 	}
@@ -522,7 +533,7 @@ func (h *UploadFileHandler) PreHook(ctx context.Context, op *plan.PlannedOperati
 	}
 
 	// Check if file already exists (warning)
-	exists, err := exec.FileExists(remotePath)
+	exists, err := exec.FileExistsContext(ctx, remotePath)
 	if err != nil {
 		result.AddWarning(fmt.Sprintf("unable to check if remote file exists: %v", err))
 	} else if exists {
@@ -537,10 +548,18 @@ func (h *UploadFileHandler) Execute(ctx context.Context, op *plan.PlannedOperati
 	localPath := op.Params["local_path"].(string)
 	remotePath := op.Params["remote_path"].(string)
 
-	if err := exec.UploadFile(localPath, remotePath); err != nil {
+	if err := exec.UploadFileContext(ctx, localPath, remotePath); err != nil {
 		return nil, fmt.Errorf("failed to upload file %s to %s: %w", localPath, remotePath, err)
 	}
 
+	// Uploads do not carry the local file mode; an optional octal mode
+	// such as "0755" is applied afterwards
+	if mode, ok := op.Params["mode"].(string); ok {
+		if _, err := exec.ExecuteContext(ctx, fmt.Sprintf("chmod %s %s", executor.ShellQuote(mode), executor.ShellQuote(remotePath))); err != nil {
+			return nil, fmt.Errorf("failed to set mode %s on %s: %w", mode, remotePath, err)
+		}
+	}
+
 	return &apply.OperationResult{
 		Success: true,
 		Output:  fmt.Sprintf("Uploaded file: %s -> %s", localPath, remotePath),
@@ -555,7 +574,7 @@ func (h *UploadFileHandler) PostHook(ctx context.Context, op *plan.PlannedOperat
 	remotePath := op.Params["remote_path"].(string)
 
 	// Verify file exists remotely
-	exists, err := exec.FileExists(remotePath)
+	exists, err := exec.FileExistsContext(ctx, remotePath)
 	if err != nil {
 		return nil, fmt.Errorf("check file exists: %w", err)
 	}
@@ -595,7 +614,7 @@ func (h *GenerateConfigHandler) IsComplete(ctx context.Context, op *plan.Planned
 	}
 
 	// Check if config file exists
-	exists, err := exec.FileExists(configPath)
+	exists, err := exec.FileExistsContext(ctx, configPath)
 	if err != nil {
 		return false, fmt.Errorf("check config exists: %w", err)
 	}
@@ -645,7 +664,7 @@ func (h *GenerateConfigHandler) PreHook(ctx context.Context, op *plan.PlannedOpe
 
 	// Check if config already exists
 	configPath, _ := op.Params["config_path"].(string)
-	exists, err := exec.FileExists(configPath)
+	exists, err := exec.FileExistsContext(ctx, configPath)
 	if err != nil {
 		result.AddWarning(fmt.Sprintf("unable to check if config exists: %v", err))
 	} else if exists {
@@ -706,7 +725,7 @@ func (h *GenerateConfigHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 	}
 
 	// Upload configuration
-	if err := exec.UploadContent(configContent, configPath); err != nil {
+	if err := exec.UploadContentContext(ctx, configContent, configPath); err != nil {
 		return nil, fmt.Errorf("failed to upload config: %w", err)
 	}
 
@@ -734,74 +753,36 @@ func (h *GenerateConfigHandler) generateMongodConfig(op *plan.PlannedOperation,
 		return nil, fmt.Errorf("log_dir parameter not found or invalid type")
 	}
 
-	replicaSet, _ := op.Params["replica_set"].(string)
-
-	// Use simple generic names - folder context provides node type
-	logFileName := "process.log"
-	pidFileName := "process.pid"
-
-	// Build template data
-	data := template.MongodConfig{
-		Net: template.NetConfig{
-			Port:   port,
-			BindIP: bindIP,
-		},
-		Storage: template.StorageConfig{
-			DBPath: dataDir,
-			Journal: template.JournalConfig{
-				Enabled: true,
-			},
-			Engine: "wiredTiger",
-			WiredTiger: &template.WiredTigerConfig{
-				EngineConfig: template.WiredTigerEngineConfig{
-					CacheSizeGB: 1.0,
-				},
-			},
-		},
-		SystemLog: template.SystemLogConfig{
-			Destination: "file",
-			Path:        filepath.Join(logDir, logFileName),
-			LogAppend:   true,
-		},
-		ProcessManagement: template.ProcessManagementConfig{
-			Fork:        false,
-			PIDFilePath: filepath.Join(dataDir, pidFileName),
-		},
-	}
-
-	// Add replication if replica set specified
-	if replicaSet != "" {
-		data.Replication = &template.ReplicationConfig{
-			ReplSetName:               replicaSet,
-			EnableMajorityReadConcern: true,
-		}
-	}
-
-	// Add sharding configuration based on role
-	if role == "configsvr" || role == "shardsvr" {
-		data.Sharding = &template.ShardingConfig{
-			ClusterRole: role,
-		}
-	}
-
-	// Get appropriate template (config servers use "config" templates, shards use "mongod")
-	templateType := "mongod"
-	if role == "configsvr" {
-		templateType = "config"
+	runtimeConfig, err := runtimeConfigParam(op)
+	if err != nil {
+		return nil, err
 	}
 
-	tmpl, err := h.templateMgr.GetTemplate(templateType, version)
+	replicaSet, _ := op.Params["replica_set"].(string)
+	keyFile, _ := op.Params["key_file"].(string)
+	tlsOpts, err := tlsOptionsParam(op)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get template: %w", err)
+		return nil, err
 	}
-
-	// Execute template
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return nil, fmt.Errorf("failed to execute template: %w", err)
+	variant, _ := op.Params["variant"].(string)
+	perconaOpts, err := perconaOptionsParam(op)
+	if err != nil {
+		return nil, err
 	}
 
-	return buf.Bytes(), nil
+	return h.templateMgr.RenderMongod(version, template.MongodOptions{
+		Role:          role,
+		ReplicaSet:    replicaSet,
+		Port:          port,
+		BindIP:        bindIP,
+		DataDir:       dataDir,
+		LogDir:        logDir,
+		KeyFile:       keyFile,
+		TLS:           tlsOpts,
+		Variant:       variant,
+		Percona:       perconaOpts,
+		RuntimeConfig: runtimeConfig,
+	})
 }
 
 func (h *GenerateConfigHandler) generateMongosConfig(op *plan.PlannedOperation, version string, port int, bindIP string) ([]byte, error) {
@@ -815,39 +796,46 @@ func (h *GenerateConfigHandler) generateMongosConfig(op *plan.PlannedOperation,
 		return nil, fmt.Errorf("config_db parameter not found or invalid type")
 	}
 
-	// Use simple generic name - folder context provides node type
-	logFileName := "process.log"
-
-	// Build template data
-	data := template.MongosConfig{
-		Net: template.NetConfig{
-			Port:   port,
-			BindIP: bindIP,
-		},
-		SystemLog: template.SystemLogConfig{
-			Destination: "file",
-			Path:        filepath.Join(logDir, logFileName),
-			LogAppend:   true,
-		},
-		// ProcessManagement is nil (omitted) for mongos 3.6 compatibility
-		Sharding: template.MongosShardingConfig{
-			ConfigDB: configDB,
-		},
+	runtimeConfig, err := runtimeConfigParam(op)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get mongos template
-	tmpl, err := h.templateMgr.GetTemplate("mongos", version)
+	keyFile, _ := op.Params["key_file"].(string)
+	tlsOpts, err := tlsOptionsParam(op)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get template: %w", err)
+		return nil, err
 	}
-
-	// Execute template
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return nil, fmt.Errorf("failed to execute template: %w", err)
+	variant, _ := op.Params["variant"].(string)
+	perconaOpts, err := perconaOptionsParam(op)
+	if err != nil {
+		return nil, err
 	}
 
-	return buf.Bytes(), nil
+	return h.templateMgr.RenderMongos(version, template.MongosOptions{
+		Port:          port,
+		BindIP:        bindIP,
+		LogDir:        logDir,
+		ConfigDB:      configDB,
+		KeyFile:       keyFile,
+		TLS:           tlsOpts,
+		Variant:       variant,
+		Percona:       perconaOpts,
+		RuntimeConfig: runtimeConfig,
+	})
+}
+
+// runtimeConfigParam returns the optional runtime_config parameter
+func runtimeConfigParam(op *plan.PlannedOperation) (map[string]any, error) {
+	raw, ok := op.Params["runtime_config"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	runtimeConfig, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("runtime_config parameter has invalid type %T", raw)
+	}
+	return runtimeConfig, nil
 }
 
 // REQ-PES-048: Post-execution verification
@@ -861,7 +849,7 @@ func (h *GenerateConfigHandler) PostHook(ctx context.Context, op *plan.PlannedOp
 	}
 
 	// Verify config file was created
-	exists, err := exec.FileExists(configPath)
+	exists, err := exec.FileExistsContext(ctx, configPath)
 	if err != nil {
 		return nil, fmt.Errorf("check config exists: %w", err)
 	}
@@ -939,26 +927,17 @@ func (h *StartProcessHandler) Execute(ctx context.Context, op *plan.PlannedOpera
 		}
 	}
 
-	// Get supervisor binary path
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
-	}
-	cacheDir := filepath.Join(homeDir, ".mup", "storage", "bin")
-	binaryPath, err := supervisor.GetSupervisordBinary(cacheDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get supervisord binary: %w", err)
-	}
-
 	// Construct supervisorctl command
 	// supervisord ctl -c <config> -s http://localhost:<port> start <program-name>
-	serverURL := fmt.Sprintf("http://localhost:%d", supervisorPort)
-	command := fmt.Sprintf("%s ctl -c %s -s %s start %s", binaryPath, supervisorConfig, serverURL, programName)
+	command, err := supervisorctlCommand(supervisorConfig, supervisorPort, "start "+programName)
+	if err != nil {
+		return nil, err
+	}
 
 	fmt.Printf("  Starting %s via supervisorctl...\n", programName)
 
 	// Execute the supervisorctl command
-	output, err := exec.Execute(command)
+	output, err := exec.ExecuteContext(ctx, command)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start process %s: %w", programName, err)
 	}
@@ -988,9 +967,128 @@ func (h *StartProcessHandler) PostHook(ctx context.Context, op *plan.PlannedOper
 	result.Metadata["verified"] = true
 	result.Metadata["program_name"] = programName
 
+	// Check that resource_control limits actually reached the process
+	limits, err := resourceLimitsParam(op)
+	if err != nil {
+		result.AddWarning(err.Error())
+	} else if limits != nil {
+		h.verifyResourceLimits(ctx, op, exec, programName, limits, result)
+	}
+
 	return result, nil
 }
 
+// verifyResourceLimits compares the cgroup limits of the started process with
+// the configured ones. Mismatches are warnings: the process is running, but
+// without the isolation the topology asked for.
+func (h *StartProcessHandler) verifyResourceLimits(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor, programName string, limits *supervisor.ResourceLimits, result *HookResult) {
+	supervisorConfig, _ := op.Params["supervisor_config"].(string)
+	supervisorPort, ok := op.Params["supervisor_port"].(int)
+	if !ok {
+		if portFloat, ok := op.Params["supervisor_port"].(float64); ok {
+			supervisorPort = int(portFloat)
+		}
+	}
+
+	command, err := supervisorctlCommand(supervisorConfig, supervisorPort, "status "+programName)
+	if err != nil {
+		result.AddWarning(fmt.Sprintf("unable to verify resource limits: %v", err))
+		return
+	}
+	output, _ := exec.ExecuteContext(ctx, command)
+
+	pid := 0
+	for _, status := range supervisor.ParseStatusOutput(output) {
+		if status.Name == programName {
+			pid = status.PID
+		}
+	}
+	if pid == 0 {
+		result.AddWarning(fmt.Sprintf("unable to verify resource limits: no PID for %s", programName))
+		return
+	}
+
+	effective, err := supervisor.ReadEffectiveLimits(ctx, exec, pid)
+	if err != nil {
+		result.AddWarning(fmt.Sprintf("unable to verify resource limits: %v", err))
+		return
+	}
+
+	result.Metadata["resource_limits"] = limits.String()
+	result.Metadata["effective_limits"] = effective.String()
+	if !limits.Satisfies(effective) {
+		result.AddWarning(fmt.Sprintf("%s is running with %s, expected %s", programName, effective, limits))
+		return
+	}
+	fmt.Printf("  ✓ %s limits: %s\n", programName, effective)
+}
+
+// supervisorctlCommand builds a "supervisord ctl" command line for the
+// supervisord listening on port
+func supervisorctlCommand(supervisorConfig string, supervisorPort int, args string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	cacheDir := filepath.Join(homeDir, ".mup", "storage", "bin")
+	binaryPath, err := supervisor.GetSupervisordBinary(cacheDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get supervisord binary: %w", err)
+	}
+
+	serverURL := fmt.Sprintf("http://localhost:%d", supervisorPort)
+	return fmt.Sprintf("%s ctl -c %s -s %s %s", binaryPath, supervisorConfig, serverURL, args), nil
+}
+
+// memberSettingsParam returns the optional member_settings parameter, which
+// is a []deploy.ReplicaSetMember in memory and a JSON array once a plan is
+// loaded from disk
+func memberSettingsParam(op *plan.PlannedOperation) ([]deploy.ReplicaSetMember, error) {
+	raw, ok := op.Params["member_settings"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	if members, ok := raw.([]deploy.ReplicaSetMember); ok {
+		return members, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshal member_settings: %w", err)
+	}
+	var members []deploy.ReplicaSetMember
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("invalid member_settings parameter: %w", err)
+	}
+	return members, nil
+}
+
+// resourceLimitsParam returns the optional resource_limits parameter, which
+// is a *supervisor.ResourceLimits in memory and a JSON object once a plan is
+// loaded from disk
+func resourceLimitsParam(op *plan.PlannedOperation) (*supervisor.ResourceLimits, error) {
+	raw, ok := op.Params["resource_limits"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	if limits, ok := raw.(*supervisor.ResourceLimits); ok {
+		return limits, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshal resource_limits: %w", err)
+	}
+	var limits supervisor.ResourceLimits
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return nil, fmt.Errorf("invalid resource_limits parameter: %w", err)
+	}
+	if limits.IsZero() {
+		return nil, nil
+	}
+	return &limits, nil
+}
+
 // WaitForProcessHandler waits for a process to be ready
 // REQ-SIM-001: Works transparently in simulation mode
 // REQ-PES-036, REQ-PES-047, REQ-PES-048: Four-phase handler
@@ -1027,7 +1125,7 @@ func (h *WaitForProcessHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 	if isSimulation {
 		// REQ-SIM-004: Record operation in simulation mode
 		cmdStr := fmt.Sprintf("Wait for process on port %d (timeout: %ds)", port, timeoutSec)
-		_, _ = exec.Execute(cmdStr)
+		_, _ = exec.ExecuteContext(ctx, cmdStr)
 
 		return &apply.OperationResult{
 			Success: true,
@@ -1041,12 +1139,15 @@ func (h *WaitForProcessHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 		}, nil
 	}
 
-	// Real execution - check port availability
-	timeout := time.Duration(timeoutSec) * time.Second
-	deadline := time.Now().Add(timeout)
+	// Real execution - check port availability until ready, timed out or cancelled
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSec)*time.Second)
+	defer cancel()
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
 
-	for time.Now().Before(deadline) {
-		available, err := exec.CheckPortAvailable(port)
+	for {
+		available, err := exec.CheckPortAvailableContext(waitCtx, port)
 		if err == nil && !available {
 			// Port is in use, meaning process is listening
 			return &apply.OperationResult{
@@ -1055,10 +1156,16 @@ func (h *WaitForProcessHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 				Changes: op.Changes,
 			}, nil
 		}
-		time.Sleep(1 * time.Second)
-	}
 
-	return nil, fmt.Errorf("timeout waiting for process on port %d", port)
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("wait for process on port %d aborted: %w", port, ctx.Err())
+			}
+			return nil, fmt.Errorf("timeout waiting for process on port %d", port)
+		case <-ticker.C:
+		}
+	}
 }
 
 // REQ-PES-048: Post-execution verification
@@ -1104,7 +1211,9 @@ func (h *WaitForReadyHandler) Execute(ctx context.Context, op *plan.PlannedOpera
 		sleepTime = timeout
 	}
 
-	time.Sleep(sleepTime)
+	if err := sleepContext(ctx, sleepTime); err != nil {
+		return nil, fmt.Errorf("wait for replica set '%s' aborted: %w", replicaSet, err)
+	}
 
 	return &apply.OperationResult{
 		Success: true,
@@ -1195,12 +1304,45 @@ func (h *InitReplicaSetHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 	// Use MongoDB client abstraction (handles both simulation and real execution)
 	primaryHost := memberDocs[0]["host"].(string)
 
+	// Plans carry per-member options (arbiters, delays, tags...) alongside the
+	// plain host list; older plans only have the host list
+	settings, err := memberSettingsParam(op)
+	if err != nil {
+		return nil, err
+	}
+	if settings != nil {
+		mongoVersion, _ := op.Params["version"].(string)
+		memberDocs = deploy.MemberDocuments(settings, mongoVersion)
+		seed, err := deploy.SeedMember(settings)
+		if err != nil {
+			return nil, fmt.Errorf("replica set %s: %w", rsName, err)
+		}
+		primaryHost = seed.Address()
+	}
+
+	rsOptions, err := replicaSetOptionsParam(op)
+	if err != nil {
+		return nil, err
+	}
+
+	sec, err := clientSecurity(op, exec)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := authParam(op)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		return h.executeWithAuth(ctx, op, exec, rsName, members, memberDocs, primaryHost, rsOptions, auth, sec)
+	}
+
 	// Create context with timeout
 	initCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	// Create MongoDB client (automatically handles simulation vs real mode)
-	mongoClient, err := NewMongoDBClient(initCtx, primaryHost, exec)
+	mongoClient, err := NewSecureMongoDBClient(initCtx, primaryHost, exec, true, sec)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to primary node %s: %w", primaryHost, err)
 	}
@@ -1209,8 +1351,12 @@ func (h *InitReplicaSetHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 	// Safety check: Check if replica set is already initialized
 	status, err := mongoClient.RunCommand(initCtx, bson.M{"replSetGetStatus": 1}, true)
 	if err == nil && status["ok"] != nil {
-		// Already initialized
+		// Already initialized; bring replica-set-level settings up to date
 		fmt.Printf("  ✓ Replica set %s already initialized\n", rsName)
+		applied, err := applyReplicaSetOptions(initCtx, exec, statusPrimary(status, primaryHost), rsOptions, true, sec)
+		if err != nil {
+			return nil, fmt.Errorf("replica set %s: %w", rsName, err)
+		}
 		return &apply.OperationResult{
 			Success: true,
 			Output:  fmt.Sprintf("Replica set '%s' already initialized", rsName),
@@ -1219,17 +1365,22 @@ func (h *InitReplicaSetHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 				"replica_set":         rsName,
 				"members":             members,
 				"already_initialized": true,
+				"settings_applied":    applied,
 			},
 		}, nil
 	}
 
 	// Initialize replica set using replSetInitiate command
+	rsConfig := bson.M{
+		"_id":     rsName,
+		"version": 1,
+		"members": memberDocs,
+	}
+	if rsOptions != nil {
+		rsOptions.ApplyToConfig(rsConfig)
+	}
 	initCmd := bson.M{
-		"replSetInitiate": bson.M{
-			"_id":     rsName,
-			"version": 1,
-			"members": memberDocs,
-		},
+		"replSetInitiate": rsConfig,
 	}
 
 	_, err = mongoClient.RunCommand(initCtx, initCmd, false)
@@ -1258,13 +1409,14 @@ func (h *InitReplicaSetHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 	fmt.Printf("  ⏳ Waiting for primary to be elected in replica set %s...\n", rsName)
 
 	// Create a new client for verification (reconnect after replSetInitiate)
-	verifyClient, err := NewMongoDBClient(initCtx, primaryHost, exec)
+	verifyClient, err := NewSecureMongoDBClient(initCtx, primaryHost, exec, true, sec)
 	if err != nil {
 		return nil, fmt.Errorf("failed to reconnect for verification: %w", err)
 	}
 	defer func() { _ = verifyClient.Disconnect(initCtx) }()
 
 	// Poll replSetGetStatus to verify primary election
+	electedPrimary := primaryHost
 	maxRetries := 10 // Reduced for simulation - real code uses 60
 	for i := 0; i < maxRetries; i++ {
 		// Verification check: Poll replica set status (not a safety check - we're verifying what we just did)
@@ -1278,6 +1430,9 @@ func (h *InitReplicaSetHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 						stateStr, _ := member["stateStr"].(string)
 						if stateStr == "PRIMARY" {
 							hasPrimary = true
+							if name, ok := member["name"].(string); ok {
+								electedPrimary = name
+							}
 							break
 						}
 					}
@@ -1293,7 +1448,9 @@ func (h *InitReplicaSetHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 		// In real mode, wait between retries
 		execType := fmt.Sprintf("%T", exec)
 		if !strings.Contains(execType, "Simulation") {
-			time.Sleep(2 * time.Second)
+			if err := sleepContext(ctx, 2*time.Second); err != nil {
+				return nil, fmt.Errorf("wait for primary in replica set %s aborted: %w", rsName, err)
+			}
 		}
 
 		if i == maxRetries-1 {
@@ -1301,6 +1458,13 @@ func (h *InitReplicaSetHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 		}
 	}
 
+	// Config settings went into replSetInitiate; default read/write concerns
+	// can only be set once there is a primary
+	applied, err := applyReplicaSetOptions(initCtx, exec, electedPrimary, rsOptions, false, sec)
+	if err != nil {
+		return nil, fmt.Errorf("replica set %s: %w", rsName, err)
+	}
+
 	fmt.Printf("  ✓ Initialized replica set '%s' with %d member(s)\n", rsName, len(memberDocs))
 
 	return &apply.OperationResult{
@@ -1308,12 +1472,167 @@ func (h *InitReplicaSetHandler) Execute(ctx context.Context, op *plan.PlannedOpe
 		Output:  fmt.Sprintf("Initialized replica set '%s' with %d members", rsName, len(memberDocs)),
 		Changes: op.Changes,
 		Metadata: map[string]interface{}{
-			"replica_set": rsName,
-			"members":     members,
+			"replica_set":      rsName,
+			"members":          members,
+			"settings_applied": applied,
 		},
 	}, nil
 }
 
+// executeWithAuth initiates a replica set with access control: the replica
+// set is initiated and its first user created through the localhost
+// exception, and settings are applied as that user
+func (h *InitReplicaSetHandler) executeWithAuth(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor, rsName string, members []interface{}, memberDocs []bson.M, seed string, rsOptions *deploy.ReplicaSetOptions, auth *deploy.AuthParams, sec *ClientSecurity) (*apply.OperationResult, error) {
+	rsConfig := bson.M{
+		"_id":     rsName,
+		"version": 1,
+		"members": memberDocs,
+	}
+	if rsOptions != nil {
+		rsOptions.ApplyToConfig(rsConfig)
+	}
+
+	// The shell waits up to a minute for the election
+	initCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	fmt.Printf("  ⏳ Initiating replica set %s and creating user %s...\n", rsName, auth.User)
+	result, err := bootstrapAdminUser(initCtx, exec, auth, sec.Credential, rsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("replica set %s: %w", rsName, err)
+	}
+	primary := result.primary
+	if primary == "" {
+		primary = seed
+	}
+
+	// An existing user means an earlier deploy initiated the replica set,
+	// so settings may need a reconfig
+	applied, err := applyReplicaSetOptions(initCtx, exec, primary, rsOptions, result.existed, sec)
+	if err != nil {
+		return nil, fmt.Errorf("replica set %s: %w", rsName, err)
+	}
+
+	output := fmt.Sprintf("Initialized replica set '%s' with %d members and created user %s", rsName, len(memberDocs), auth.User)
+	if result.existed {
+		output = fmt.Sprintf("Replica set '%s' already initialized with user %s", rsName, auth.User)
+	}
+	fmt.Printf("  ✓ %s\n", output)
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  output,
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"replica_set":         rsName,
+			"members":             members,
+			"already_initialized": result.existed,
+			"user":                auth.User,
+			"settings_applied":    applied,
+		},
+	}, nil
+}
+
+// applyReplicaSetOptions applies replica-set-level settings through primary,
+// connecting as sec describes.
+// With reconfig, config settings that differ from the running config are
+// applied with replSetReconfig. Default read/write concerns are always
+// (re)applied. It returns what was applied.
+func applyReplicaSetOptions(ctx context.Context, exec executor.Executor, primary string, opts *deploy.ReplicaSetOptions, reconfig bool, sec *ClientSecurity) ([]string, error) {
+	applied := []string{}
+	if opts == nil || (!opts.HasDefaultRWConcern() && !(reconfig && opts.HasConfigSettings())) {
+		return applied, nil
+	}
+
+	client, err := NewSecureMongoDBClient(ctx, primary, exec, true, sec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to primary %s: %w", primary, err)
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+
+	if reconfig && opts.HasConfigSettings() {
+		result, err := client.RunCommand(ctx, bson.M{"replSetGetConfig": 1}, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read replica set config: %w", err)
+		}
+		config, ok := result["config"].(bson.M)
+		if !ok {
+			return nil, fmt.Errorf("replSetGetConfig returned no config")
+		}
+		if opts.ApplyToConfig(config) {
+			config["version"] = configVersion(config) + 1
+			if _, err := client.RunCommand(ctx, bson.M{"replSetReconfig": config}, false); err != nil {
+				return nil, fmt.Errorf("replSetReconfig failed: %w", err)
+			}
+			fmt.Printf("  ✓ Updated replica set settings (config version %v)\n", config["version"])
+			applied = append(applied, "config")
+		}
+	}
+
+	if cmd := opts.DefaultRWConcernCommand(); cmd != nil {
+		if _, err := client.RunOrderedCommand(ctx, cmd); err != nil {
+			return nil, fmt.Errorf("setDefaultRWConcern failed: %w", err)
+		}
+		fmt.Printf("  ✓ Set default read/write concern\n")
+		applied = append(applied, "default_rw_concern")
+	}
+
+	return applied, nil
+}
+
+// statusPrimary returns the PRIMARY's address from replSetGetStatus output,
+// or fallback when none is reported
+func statusPrimary(status bson.M, fallback string) string {
+	members, _ := status["members"].(bson.A)
+	for _, m := range members {
+		if member, ok := m.(bson.M); ok && member["stateStr"] == "PRIMARY" {
+			if name, ok := member["name"].(string); ok {
+				return name
+			}
+		}
+	}
+	return fallback
+}
+
+// configVersion returns a replica set config's version as an int
+func configVersion(config bson.M) int {
+	switch v := config["version"].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// replicaSetOptionsParam returns the optional replica_set_options parameter,
+// which is a *deploy.ReplicaSetOptions in memory and a JSON object once a
+// plan is loaded from disk
+func replicaSetOptionsParam(op *plan.PlannedOperation) (*deploy.ReplicaSetOptions, error) {
+	raw, ok := op.Params["replica_set_options"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	if opts, ok := raw.(*deploy.ReplicaSetOptions); ok {
+		return opts, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshal replica_set_options: %w", err)
+	}
+	var opts deploy.ReplicaSetOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return nil, fmt.Errorf("invalid replica_set_options parameter: %w", err)
+	}
+	return &opts, nil
+}
+
 // REQ-PES-048: Post-execution verification
 func (h *InitReplicaSetHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
 	result := NewHookResult()
@@ -1380,8 +1699,14 @@ func (h *AddShardHandler) Execute(ctx context.Context, op *plan.PlannedOperation
 	initCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
+	// With access control the shard is added as the admin user
+	sec, err := clientSecurity(op, exec)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create MongoDB client for mongos (automatically handles simulation vs real mode)
-	mongoClient, err := NewMongoDBClientForMongos(initCtx, mongosHost, exec)
+	mongoClient, err := NewSecureMongoDBClient(initCtx, mongosHost, exec, false, sec)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to mongos at %s: %w", mongosHost, err)
 	}
@@ -1461,6 +1786,78 @@ func (h *AddShardHandler) PostHook(ctx context.Context, op *plan.PlannedOperatio
 	return result, nil
 }
 
+// SetDefaultRWConcernHandler sets a sharded cluster's default read/write
+// concerns through mongos
+// REQ-PES-036, REQ-PES-047, REQ-PES-048: Four-phase handler
+type SetDefaultRWConcernHandler struct{}
+
+// REQ-PES-036: setDefaultRWConcern is idempotent, so it is always run
+func (h *SetDefaultRWConcernHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	return false, nil
+}
+
+// REQ-PES-047: Pre-execution validation
+func (h *SetDefaultRWConcernHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+
+	if _, ok := op.Params["mongos_host"].(string); !ok {
+		result.AddError("missing required parameter: mongos_host")
+	}
+	opts, err := replicaSetOptionsParam(op)
+	if err != nil {
+		result.AddError(err.Error())
+	} else if !opts.HasDefaultRWConcern() {
+		result.AddError("replica_set_options sets no default read/write concern")
+	}
+
+	return result, nil
+}
+
+func (h *SetDefaultRWConcernHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	mongosHost, ok := op.Params["mongos_host"].(string)
+	if !ok {
+		return nil, fmt.Errorf("mongos_host parameter not found")
+	}
+	opts, err := replicaSetOptionsParam(op)
+	if err != nil {
+		return nil, err
+	}
+
+	initCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	sec, err := clientSecurity(op, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	mongoClient, err := NewSecureMongoDBClient(initCtx, mongosHost, exec, false, sec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mongos at %s: %w", mongosHost, err)
+	}
+	defer func() { _ = mongoClient.Disconnect(initCtx) }()
+
+	if _, err := mongoClient.RunOrderedCommand(initCtx, opts.DefaultRWConcernCommand()); err != nil {
+		return nil, fmt.Errorf("setDefaultRWConcern through mongos %s failed: %w", mongosHost, err)
+	}
+
+	fmt.Printf("  ✓ Set cluster-wide default read/write concern\n")
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("Set default read/write concern through mongos %s", mongosHost),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"mongos_host": mongosHost,
+		},
+	}, nil
+}
+
+// REQ-PES-048: Post-execution verification
+func (h *SetDefaultRWConcernHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	return NewHookResult(), nil
+}
+
 // VerifyHealthHandler verifies cluster health
 // REQ-PES-036, REQ-PES-047, REQ-PES-048: Four-phase handler
 type VerifyHealthHandler struct{}
@@ -1493,7 +1890,7 @@ func (h *VerifyHealthHandler) Execute(ctx context.Context, op *plan.PlannedOpera
 	var unhealthyPorts []int
 
 	for _, port := range portsToCheck {
-		available, err := exec.CheckPortAvailable(port)
+		available, err := exec.CheckPortAvailableContext(ctx, port)
 		if err != nil {
 			unhealthyPorts = append(unhealthyPorts, port)
 			continue
@@ -1577,6 +1974,7 @@ func (h *SaveMetadataHandler) Execute(ctx context.Context, op *plan.PlannedOpera
 			nodes = append(nodes, meta.NodeMetadata{
 				Type:                  "config",
 				Host:                  cs.Host,
+				AdvertiseHost:         cs.AdvertiseHost,
 				Port:                  cs.Port,
 				ReplicaSet:            cs.ReplicaSet,
 				DataDir:               cs.DataDir,
@@ -1589,6 +1987,7 @@ func (h *SaveMetadataHandler) Execute(ctx context.Context, op *plan.PlannedOpera
 			nodes = append(nodes, meta.NodeMetadata{
 				Type:                  "mongod",
 				Host:                  mongod.Host,
+				AdvertiseHost:         mongod.AdvertiseHost,
 				Port:                  mongod.Port,
 				ReplicaSet:            mongod.ReplicaSet,
 				DataDir:               mongod.DataDir,
@@ -1601,6 +2000,7 @@ func (h *SaveMetadataHandler) Execute(ctx context.Context, op *plan.PlannedOpera
 			nodes = append(nodes, meta.NodeMetadata{
 				Type:                  "mongos",
 				Host:                  mongos.Host,
+				AdvertiseHost:         mongos.AdvertiseHost,
 				Port:                  mongos.Port,
 				LogDir:                mongos.LogDir,
 				ConfigDir:             mongos.ConfigDir,
@@ -1621,6 +2021,25 @@ func (h *SaveMetadataHandler) Execute(ctx context.Context, op *plan.PlannedOpera
 		DeployMode: deployMode,
 		Nodes:      nodes,
 	}
+	if topo != nil && (topo.AuthEnabled() || topo.TLSEnabled()) {
+		metadata.Security = &meta.SecurityMetadata{}
+		if topo.AuthEnabled() {
+			keyFile, _ := op.Params["key_file"].(string)
+			passwordFile, _ := op.Params["password_file"].(string)
+			credentialsFile, _ := op.Params["credentials_file"].(string)
+			metadata.Security.Auth = true
+			metadata.Security.AdminUser = topo.Security.AdminUsername()
+			metadata.Security.KeyFile = keyFile
+			metadata.Security.PasswordFile = passwordFile
+			metadata.Security.CredentialsFile = credentialsFile
+		}
+		if topo.TLSEnabled() {
+			caFile, _ := op.Params["ca_file"].(string)
+			metadata.Security.TLS = topo.Security.TLS.TLSMode()
+			metadata.Security.CAFile = caFile
+			metadata.Security.X509MemberAuth = topo.Security.TLS.X509MemberAuth
+		}
+	}
 
 	// Generate connection command
 	if len(nodes) > 0 {
@@ -1634,7 +2053,16 @@ func (h *SaveMetadataHandler) Execute(ctx context.Context, op *plan.PlannedOpera
 				}
 				// Use absolute path to shell binary
 				shellPath := filepath.Join(binPath, shell)
-				metadata.ConnectionCommand = fmt.Sprintf("%s mongodb://%s:%d", shellPath, node.Host, node.Port)
+				metadata.ConnectionCommand = fmt.Sprintf("%s mongodb://%s", shellPath, node.Address())
+				if metadata.Security != nil && metadata.Security.Auth {
+					// The shell prompts for the password
+					metadata.ConnectionCommand = fmt.Sprintf("%s 'mongodb://%s@%s/?authSource=admin'", shellPath, metadata.Security.AdminUser, node.Address())
+				}
+				if metadata.Security.TLSEnabled() {
+					for _, arg := range security.ShellTLSFlags(shellPath, metadata.Security.CAFile) {
+						metadata.ConnectionCommand += " " + executor.ShellQuote(arg)
+					}
+				}
 				break
 			}
 		}
@@ -1650,9 +2078,25 @@ func (h *SaveMetadataHandler) Execute(ctx context.Context, op *plan.PlannedOpera
 		return nil, fmt.Errorf("failed to save metadata: %w", err)
 	}
 
+	// Reserve the cluster's ports so they are not reused while it is stopped
+	supervisorPort, ok := op.Params["supervisor_port"].(int)
+	if !ok {
+		if portFloat, ok := op.Params["supervisor_port"].(float64); ok {
+			supervisorPort = int(portFloat)
+		}
+	}
+	conflicts, err := ports.Record(metadata, supervisorPort)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve ports: %w", err)
+	}
+	output := fmt.Sprintf("Saved cluster metadata for '%s'", clusterName)
+	for _, c := range conflicts {
+		output += fmt.Sprintf("\nWarning: port %d on %s is also reserved by cluster %s (%s)", c.Port, c.Host, c.Cluster, c.Service)
+	}
+
 	return &apply.OperationResult{
 		Success: true,
-		Output:  fmt.Sprintf("Saved cluster metadata for '%s'", clusterName),
+		Output:  output,
 		Changes: op.Changes,
 		Metadata: map[string]interface{}{
 			"cluster_name": clusterName,
@@ -1675,7 +2119,7 @@ type StopProcessHandler struct{}
 // REQ-PES-036: Check if process already stopped
 func (h *StopProcessHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
 	pid := int(op.Params["pid"].(float64))
-	running, err := exec.IsProcessRunning(pid)
+	running, err := exec.IsProcessRunningContext(ctx, pid)
 	if err != nil {
 		return false, fmt.Errorf("failed to check if process %d is running: %w", pid, err)
 	}
@@ -1692,7 +2136,7 @@ func (h *StopProcessHandler) PreHook(ctx context.Context, op *plan.PlannedOperat
 	}
 
 	pid := int(op.Params["pid"].(float64))
-	running, err := exec.IsProcessRunning(pid)
+	running, err := exec.IsProcessRunningContext(ctx, pid)
 	if err != nil {
 		result.AddWarning(fmt.Sprintf("unable to check if process %d is running: %v", pid, err))
 	} else if !running {
@@ -1706,7 +2150,7 @@ func (h *StopProcessHandler) PreHook(ctx context.Context, op *plan.PlannedOperat
 func (h *StopProcessHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
 	pid := int(op.Params["pid"].(float64))
 
-	if err := exec.StopProcess(pid); err != nil {
+	if err := exec.StopProcessContext(ctx, pid); err != nil {
 		return nil, fmt.Errorf("failed to stop process %d: %w", pid, err)
 	}
 
@@ -1722,7 +2166,7 @@ func (h *StopProcessHandler) PostHook(ctx context.Context, op *plan.PlannedOpera
 	result := NewHookResult()
 
 	pid := int(op.Params["pid"].(float64))
-	running, err := exec.IsProcessRunning(pid)
+	running, err := exec.IsProcessRunningContext(ctx, pid)
 	if err != nil {
 		result.AddWarning(fmt.Sprintf("unable to verify process %d stopped: %v", pid, err))
 	} else if running {
@@ -1742,7 +2186,7 @@ func (h *RemoveDirectoryHandler) IsComplete(ctx context.Context, op *plan.Planne
 	path := op.Params["path"].(string)
 
 	// Check if directory exists
-	exists, err := exec.FileExists(path)
+	exists, err := exec.FileExistsContext(ctx, path)
 	if err != nil {
 		return false, fmt.Errorf("check directory exists: %w", err)
 	}
@@ -1764,7 +2208,7 @@ func (h *RemoveDirectoryHandler) PreHook(ctx context.Context, op *plan.PlannedOp
 	}
 
 	// Check if directory exists
-	exists, err := exec.FileExists(path)
+	exists, err := exec.FileExistsContext(ctx, path)
 	if err != nil {
 		return nil, fmt.Errorf("check directory exists: %w", err)
 	}
@@ -1790,7 +2234,7 @@ func (h *RemoveDirectoryHandler) Execute(ctx context.Context, op *plan.PlannedOp
 	path := op.Params["path"].(string)
 
 	// Use RemoveDirectory which works with simulation
-	if err := exec.RemoveDirectory(path); err != nil {
+	if err := exec.RemoveDirectoryContext(ctx, path); err != nil {
 		return nil, fmt.Errorf("failed to remove directory %s: %w", path, err)
 	}
 
@@ -1808,7 +2252,7 @@ func (h *RemoveDirectoryHandler) PostHook(ctx context.Context, op *plan.PlannedO
 	result := NewHookResult()
 
 	// Verify directory was removed
-	exists, err := exec.FileExists(path)
+	exists, err := exec.FileExistsContext(ctx, path)
 	if err != nil {
 		return nil, fmt.Errorf("check directory exists: %w", err)
 	}
@@ -1838,7 +2282,7 @@ func (h *GenerateSupervisorConfigHandler) IsComplete(ctx context.Context, op *pl
 	}
 
 	configPath := filepath.Join(clusterDir, "supervisor.ini")
-	exists, err := exec.FileExists(configPath)
+	exists, err := exec.FileExistsContext(ctx, configPath)
 	if err != nil {
 		return false, fmt.Errorf("check supervisor config exists: %w", err)
 	}
@@ -1864,7 +2308,7 @@ func (h *GenerateSupervisorConfigHandler) PreHook(ctx context.Context, op *plan.
 
 	// Validate cluster directory exists
 	clusterDir := op.Params["cluster_dir"].(string)
-	exists, err := exec.FileExists(clusterDir)
+	exists, err := exec.FileExistsContext(ctx, clusterDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if cluster directory exists: %w", err)
 	}
@@ -1886,7 +2330,7 @@ func (h *GenerateSupervisorConfigHandler) PreHook(ctx context.Context, op *plan.
 
 	// Check if config already exists
 	configPath := filepath.Join(clusterDir, "supervisor.ini")
-	exists, err = exec.FileExists(configPath)
+	exists, err = exec.FileExistsContext(ctx, configPath)
 	if err != nil {
 		result.AddWarning(fmt.Sprintf("unable to check if supervisor config exists: %v", err))
 	} else if exists {
@@ -1923,6 +2367,20 @@ func (h *GenerateSupervisorConfigHandler) Execute(ctx context.Context, op *plan.
 	// The supervisor.NewConfigGenerator expects version-specific directory
 	gen := supervisor.NewConfigGenerator(clusterDir, clusterName, topo, version, binPath)
 
+	// The planner detects the cgroup mode of each host through its executor;
+	// without it the mode of this machine is used
+	if modes, ok := op.Params["cgroup_modes"]; ok {
+		hostModes := map[string]supervisor.CgroupMode{}
+		modeBytes, _ := json.Marshal(modes)
+		if err := json.Unmarshal(modeBytes, &hostModes); err != nil {
+			return nil, fmt.Errorf("invalid cgroup_modes parameter: %w", err)
+		}
+		gen.WithHostCgroupModes(hostModes)
+	}
+	if cgexecDir, ok := op.Params["cgexec_dir"].(string); ok {
+		gen.WithCgexecDir(cgexecDir)
+	}
+
 	// Generate all supervisor configs (unified config with all programs)
 	if err := gen.GenerateAll(); err != nil {
 		return nil, fmt.Errorf("failed to generate supervisor configs: %w", err)
@@ -1947,7 +2405,7 @@ func (h *GenerateSupervisorConfigHandler) PostHook(ctx context.Context, op *plan
 	configPath := filepath.Join(clusterDir, "supervisor.ini")
 
 	// Verify config file was created
-	exists, err := exec.FileExists(configPath)
+	exists, err := exec.FileExistsContext(ctx, configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify supervisor config: %w", err)
 	}
@@ -2059,16 +2517,18 @@ func (h *StartSupervisorHandler) Execute(ctx context.Context, op *plan.PlannedOp
 	fmt.Printf("  Starting supervisord daemon for cluster %s (HTTP port: %d)...\n", clusterName, httpPort)
 
 	// Start supervisor in the background (it's a long-running daemon)
-	pid, err := exec.Background(command)
+	pid, err := exec.BackgroundContext(ctx, command)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start supervisord: %w", err)
 	}
 
 	// Give supervisord a moment to initialize
-	time.Sleep(500 * time.Millisecond)
+	if err := sleepContext(ctx, 500*time.Millisecond); err != nil {
+		return nil, fmt.Errorf("supervisord startup aborted: %w", err)
+	}
 
 	// Verify it's still running
-	running, err := exec.IsProcessRunning(pid)
+	running, err := exec.IsProcessRunningContext(ctx, pid)
 	if err != nil || !running {
 		return nil, fmt.Errorf("supervisord failed to start (PID: %d)", pid)
 	}
@@ -2106,3 +2566,16 @@ func (h *StartSupervisorHandler) PostHook(ctx context.Context, op *plan.PlannedO
 
 	return result, nil
 }
+
+// sleepContext sleeps for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
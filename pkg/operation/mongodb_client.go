@@ -11,6 +11,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/security"
 )
 
 // MongoDBClient abstracts MongoDB operations for both simulation and real execution
@@ -23,18 +24,37 @@ type MongoDBClient struct {
 	host         string
 }
 
+// Credential authenticates a MongoDBClient against the admin database
+type Credential struct {
+	Username string
+	Password string
+}
+
+// ClientSecurity is how a MongoDBClient logs in and encrypts its
+// connection. Nil or the zero value connects in plain text without logging in.
+type ClientSecurity struct {
+	Credential *Credential
+	CAFile     string // Enables TLS, trusting the CAs in this file
+}
+
 // NewMongoDBClient creates a client that works in both simulation and real modes
 // For direct connections to mongod nodes (replica set members)
 func NewMongoDBClient(ctx context.Context, host string, exec executor.Executor) (*MongoDBClient, error) {
-	return newMongoDBClient(ctx, host, exec, true)
+	return newMongoDBClient(ctx, host, exec, true, nil)
 }
 
 // NewMongoDBClientForMongos creates a client for connecting to mongos (not direct)
 func NewMongoDBClientForMongos(ctx context.Context, host string, exec executor.Executor) (*MongoDBClient, error) {
-	return newMongoDBClient(ctx, host, exec, false)
+	return newMongoDBClient(ctx, host, exec, false, nil)
 }
 
-func newMongoDBClient(ctx context.Context, host string, exec executor.Executor, direct bool) (*MongoDBClient, error) {
+// NewSecureMongoDBClient creates a client connecting as sec describes,
+// directly to a mongod or, with direct false, to a mongos
+func NewSecureMongoDBClient(ctx context.Context, host string, exec executor.Executor, direct bool, sec *ClientSecurity) (*MongoDBClient, error) {
+	return newMongoDBClient(ctx, host, exec, direct, sec)
+}
+
+func newMongoDBClient(ctx context.Context, host string, exec executor.Executor, direct bool, sec *ClientSecurity) (*MongoDBClient, error) {
 	execType := fmt.Sprintf("%T", exec)
 	isSimulation := strings.Contains(execType, "Simulation")
 
@@ -58,6 +78,20 @@ func newMongoDBClient(ctx context.Context, host string, exec executor.Executor,
 		if direct {
 			opts.SetDirect(true)
 		}
+		if sec != nil && sec.Credential != nil {
+			opts.SetAuth(options.Credential{
+				AuthSource: "admin",
+				Username:   sec.Credential.Username,
+				Password:   sec.Credential.Password,
+			})
+		}
+		if sec != nil && sec.CAFile != "" {
+			tlsConfig, err := security.ClientTLSConfig(sec.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			opts.SetTLSConfig(tlsConfig)
+		}
 
 		realClient, err := mongo.Connect(ctx, opts)
 		if err != nil {
@@ -117,6 +151,26 @@ func (c *MongoDBClient) RunCommand(ctx context.Context, cmd bson.M, isSafetyChec
 	return result, err
 }
 
+// RunOrderedCommand executes an admin command whose fields must keep their
+// order, such as commands with options after the command name
+func (c *MongoDBClient) RunOrderedCommand(ctx context.Context, cmd bson.D) (bson.M, error) {
+	return c.RunOrderedCommandOn(ctx, "admin", cmd)
+}
+
+// RunOrderedCommandOn executes a command on database db, preserving key
+// order
+func (c *MongoDBClient) RunOrderedCommandOn(ctx context.Context, db string, cmd bson.D) (bson.M, error) {
+	if c.isSimulation {
+		cmdJSON, _ := bson.MarshalExtJSON(cmd, false, false)
+		_, _ = c.executor.MongoExecute(c.host, fmt.Sprintf("client.Database(%q).RunCommand(%s)", db, string(cmdJSON)))
+		return bson.M{"ok": 1}, nil
+	}
+
+	var result bson.M
+	err := c.realClient.Database(db).RunCommand(ctx, cmd).Decode(&result)
+	return result, err
+}
+
 // Disconnect closes the connection
 func (c *MongoDBClient) Disconnect(ctx context.Context) error {
 	if c.isSimulation {
@@ -223,6 +223,56 @@ func TestAddShardHandler_Simulation(t *testing.T) {
 	assert.True(t, foundAddShard, "Expected to find addShard command")
 }
 
+// TestSetDefaultRWConcernHandler_Simulation tests that the defaults are set
+// through mongos
+func TestSetDefaultRWConcernHandler_Simulation(t *testing.T) {
+	handler := &SetDefaultRWConcernHandler{}
+	ctx := context.Background()
+	simExec := simulation.NewExecutor(simulation.NewConfig())
+
+	op := &plan.PlannedOperation{
+		Type: plan.OpSetDefaultRWConcern,
+		Params: map[string]interface{}{
+			"mongos_host": "localhost:27016",
+			"replica_set_options": map[string]interface{}{
+				"default_read_concern":  "majority",
+				"default_write_concern": map[string]interface{}{"w": "majority"},
+			},
+		},
+	}
+
+	pre, err := handler.PreHook(ctx, op, simExec)
+	require.NoError(t, err)
+	assert.True(t, pre.Valid, pre.Errors)
+
+	result, err := handler.Execute(ctx, op, simExec)
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+
+	var connected, set bool
+	for _, recorded := range simExec.GetOperations() {
+		if recorded.Type != "mongo_execute" {
+			continue
+		}
+		if strings.Contains(recorded.Details, "mongo.Connect") {
+			connected = true
+			assert.Contains(t, recorded.Target, "localhost:27016")
+		}
+		if strings.Contains(recorded.Details, "setDefaultRWConcern") {
+			set = true
+			assert.Contains(t, recorded.Details, "majority")
+		}
+	}
+	assert.True(t, connected, "Expected to connect to mongos")
+	assert.True(t, set, "Expected setDefaultRWConcern through mongos")
+
+	// Options without defaults have nothing to set
+	op.Params["replica_set_options"] = map[string]interface{}{"protocol_version": 1}
+	pre, err = handler.PreHook(ctx, op, simExec)
+	require.NoError(t, err)
+	assert.False(t, pre.Valid)
+}
+
 // TestAddShardHandler_Validation tests parameter validation
 func TestAddShardHandler_Validation(t *testing.T) {
 	handler := &AddShardHandler{}
@@ -0,0 +1,376 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/zph/mup/pkg/apply"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/plan"
+)
+
+const (
+	limitsDir = "/etc/security/limits.d"
+	sysctlDir = "/etc/sysctl.d"
+	thpDir    = "/sys/kernel/mm/transparent_hugepage"
+)
+
+// PrepareOSParams defines typed parameters for the prepare_os operations.
+// Each operation type uses a subset of the fields.
+type PrepareOSParams struct {
+	User        string   `json:"user,omitempty"`        // ensure_user, set_ulimit
+	Group       string   `json:"group,omitempty"`       // ensure_group, ensure_user
+	Nofile      int      `json:"nofile,omitempty"`      // set_ulimit
+	Nproc       int      `json:"nproc,omitempty"`       // set_ulimit
+	Key         string   `json:"key,omitempty"`         // set_sysctl
+	Value       string   `json:"value,omitempty"`       // set_sysctl, set_transparent_hugepages
+	Path        string   `json:"path,omitempty"`        // check_filesystem
+	Filesystems []string `json:"filesystems,omitempty"` // check_filesystem
+}
+
+// osSetting is one host setting managed by the prepare_os phase. read
+// returns the current value, so every operation can report before/after.
+type osSetting struct {
+	resourceType string
+	resourceID   string
+	desired      string
+	read         func(ctx context.Context, exec executor.Executor) (string, error)
+	// apply brings the host to desired; nil for read-only checks
+	apply func(ctx context.Context, exec executor.Executor) error
+	// satisfied reports whether current meets desired; defaults to equality
+	satisfied func(current string) bool
+}
+
+// PrepareOSHandler handles every prepare_os operation type: service
+// user and group, ulimits, sysctls, transparent huge pages and the data
+// directory filesystem check. All operations read the current state first
+// and only change what differs, so re-running the phase is a no-op.
+type PrepareOSHandler struct{}
+
+// NewPrepareOSHandler creates a new PrepareOSHandler
+func NewPrepareOSHandler() *PrepareOSHandler {
+	return &PrepareOSHandler{}
+}
+
+// IsComplete checks whether the host already has the desired setting
+// REQ-PES-036: Check if operation was already completed
+func (h *PrepareOSHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	setting, err := h.settingFor(op)
+	if err != nil {
+		return false, err
+	}
+	// Checks always run so the result is reported
+	if setting.apply == nil {
+		return false, nil
+	}
+
+	current, err := setting.read(ctx, exec)
+	if err != nil {
+		return false, nil
+	}
+	return setting.isSatisfied(current), nil
+}
+
+// PreHook validates parameters and that the host can be changed
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *PrepareOSHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+
+	setting, err := h.settingFor(op)
+	if err != nil {
+		result.AddError(err.Error())
+		return result, nil
+	}
+
+	if setting.apply != nil {
+		if _, err := exec.ExecuteContext(ctx, `[ "$(id -u)" -eq 0 ] || sudo -n true`); err != nil {
+			result.AddError(fmt.Sprintf("%s requires root or passwordless sudo on %s", op.Type, op.Target.Host))
+		}
+	}
+
+	return result, nil
+}
+
+// Execute applies the setting if the host differs and records before/after
+// REQ-PES-013: Execute the operation idempotently
+func (h *PrepareOSHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	setting, err := h.settingFor(op)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := setting.read(ctx, exec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s %s: %w", setting.resourceType, setting.resourceID, err)
+	}
+
+	change := plan.Change{
+		ResourceType: setting.resourceType,
+		ResourceID:   setting.resourceID,
+		Action:       plan.ActionNone,
+		Before:       before,
+		After:        before,
+	}
+
+	if !setting.isSatisfied(before) {
+		if setting.apply == nil {
+			return nil, fmt.Errorf("%s %s is %s, expected %s", setting.resourceType, setting.resourceID, before, setting.desired)
+		}
+		if err := setting.apply(ctx, exec); err != nil {
+			return nil, fmt.Errorf("failed to set %s %s: %w", setting.resourceType, setting.resourceID, err)
+		}
+		after, err := setting.read(ctx, exec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s %s: %w", setting.resourceType, setting.resourceID, err)
+		}
+		change.After = after
+		change.Action = plan.ActionUpdate
+		if before == "" || before == "absent" {
+			change.Action = plan.ActionCreate
+		}
+	}
+
+	output := fmt.Sprintf("%s %s: %s", setting.resourceType, setting.resourceID, change.After)
+	if change.Action != plan.ActionNone {
+		output = fmt.Sprintf("%s %s: %s -> %s", setting.resourceType, setting.resourceID, before, change.After)
+	}
+
+	return &apply.OperationResult{
+		Success:  true,
+		Output:   output,
+		Changes:  []plan.Change{change},
+		Metadata: make(map[string]interface{}),
+	}, nil
+}
+
+// PostHook verifies the host now has the desired setting
+// REQ-PES-048: Post-execution verification and user hooks
+func (h *PrepareOSHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	setting, err := h.settingFor(op)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewHookResult()
+	current, err := setting.read(ctx, exec)
+	if err != nil {
+		result.AddError(fmt.Sprintf("failed to verify %s %s: %v", setting.resourceType, setting.resourceID, err))
+		return result, nil
+	}
+	if !setting.isSatisfied(current) {
+		result.AddError(fmt.Sprintf("%s %s is %s, expected %s", setting.resourceType, setting.resourceID, current, setting.desired))
+		return result, nil
+	}
+
+	result.Metadata["verified"] = true
+	result.Metadata["value"] = current
+	return result, nil
+}
+
+// settingFor builds the setting managed by op
+func (h *PrepareOSHandler) settingFor(op *plan.PlannedOperation) (*osSetting, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	switch op.Type {
+	case plan.OpEnsureGroup:
+		if params.Group == "" {
+			return nil, fmt.Errorf("group is required")
+		}
+		return &osSetting{
+			resourceType: "group",
+			resourceID:   params.Group,
+			desired:      "present",
+			read:         presence("getent group " + executor.ShellQuote(params.Group)),
+			apply:        privileged("groupadd --system " + executor.ShellQuote(params.Group)),
+		}, nil
+
+	case plan.OpEnsureUser:
+		if params.User == "" {
+			return nil, fmt.Errorf("user is required")
+		}
+		command := "useradd --system --no-create-home --shell /sbin/nologin"
+		if params.Group != "" {
+			command += " --gid " + executor.ShellQuote(params.Group)
+		}
+		return &osSetting{
+			resourceType: "user",
+			resourceID:   params.User,
+			desired:      "present",
+			read:         presence("getent passwd " + executor.ShellQuote(params.User)),
+			apply:        privileged(command + " " + executor.ShellQuote(params.User)),
+		}, nil
+
+	case plan.OpSetUlimit:
+		if params.User == "" || (params.Nofile <= 0 && params.Nproc <= 0) {
+			return nil, fmt.Errorf("user and nofile or nproc are required")
+		}
+		path := fmt.Sprintf("%s/99-mup-%s.conf", limitsDir, params.User)
+		desired := ulimitContent(params.User, params.Nofile, params.Nproc)
+		return &osSetting{
+			resourceType: "ulimit",
+			resourceID:   params.User,
+			desired:      summarizeUlimits(desired),
+			read: func(ctx context.Context, exec executor.Executor) (string, error) {
+				out, _ := exec.ExecuteContext(ctx, "cat "+path+" 2>/dev/null")
+				return summarizeUlimits(out), nil
+			},
+			apply: privileged(fmt.Sprintf("printf %%s %s > %s", executor.ShellQuote(desired), path)),
+		}, nil
+
+	case plan.OpSetSysctl:
+		if params.Key == "" || params.Value == "" {
+			return nil, fmt.Errorf("key and value are required")
+		}
+		// Applied now and persisted for the next boot
+		persist := fmt.Sprintf("%s/99-mup-%s.conf", sysctlDir, params.Key)
+		return &osSetting{
+			resourceType: "sysctl",
+			resourceID:   params.Key,
+			desired:      params.Value,
+			read: func(ctx context.Context, exec executor.Executor) (string, error) {
+				out, err := exec.ExecuteContext(ctx, "sysctl -n "+executor.ShellQuote(params.Key))
+				return strings.TrimSpace(out), err
+			},
+			apply: privileged(fmt.Sprintf("sysctl -w %s=%s && echo %s > %s",
+				executor.ShellQuote(params.Key), executor.ShellQuote(params.Value), executor.ShellQuote(params.Key+" = "+params.Value), persist)),
+		}, nil
+
+	case plan.OpSetTHP:
+		if params.Value == "" {
+			return nil, fmt.Errorf("value is required")
+		}
+		return &osSetting{
+			resourceType: "transparent_hugepages",
+			resourceID:   thpDir,
+			desired:      params.Value,
+			read: func(ctx context.Context, exec executor.Executor) (string, error) {
+				out, err := exec.ExecuteContext(ctx, "cat "+thpDir+"/enabled")
+				if err != nil {
+					return "", err
+				}
+				return selectedOption(out), nil
+			},
+			apply: privileged(fmt.Sprintf("echo %s > %s/enabled && echo %s > %s/defrag",
+				executor.ShellQuote(params.Value), thpDir, executor.ShellQuote(params.Value), thpDir)),
+		}, nil
+
+	case plan.OpCheckFilesystem:
+		if params.Path == "" || len(params.Filesystems) == 0 {
+			return nil, fmt.Errorf("path and filesystems are required")
+		}
+		// The data directory may not exist yet; check the nearest existing parent
+		command := fmt.Sprintf(`p=%s; while [ ! -e "$p" ]; do p=$(dirname "$p"); done; df -PT "$p" | awk 'NR==2 {print $2}'`, executor.ShellQuote(params.Path))
+		allowed := params.Filesystems
+		return &osSetting{
+			resourceType: "filesystem",
+			resourceID:   params.Path,
+			desired:      strings.Join(allowed, " or "),
+			read: func(ctx context.Context, exec executor.Executor) (string, error) {
+				out, err := exec.ExecuteContext(ctx, command)
+				return strings.TrimSpace(out), err
+			},
+			satisfied: func(current string) bool {
+				for _, fs := range allowed {
+					if fs == current {
+						return true
+					}
+				}
+				return false
+			},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported prepare_os operation: %s", op.Type)
+	}
+}
+
+// unmarshalParams handles JSON unmarshaling with type conversion
+func (h *PrepareOSHandler) unmarshalParams(params map[string]interface{}) (*PrepareOSParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed PrepareOSParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	return &typed, nil
+}
+
+// isSatisfied reports whether current meets the desired value
+func (s *osSetting) isSatisfied(current string) bool {
+	if s.satisfied != nil {
+		return s.satisfied(current)
+	}
+	return current == s.desired
+}
+
+// presence reads "present" or "absent" depending on whether check succeeds
+func presence(check string) func(ctx context.Context, exec executor.Executor) (string, error) {
+	return func(ctx context.Context, exec executor.Executor) (string, error) {
+		out, err := exec.ExecuteContext(ctx, check+" >/dev/null && echo present || echo absent")
+		return strings.TrimSpace(out), err
+	}
+}
+
+// privileged runs command as root, via passwordless sudo when not already root
+func privileged(command string) func(ctx context.Context, exec executor.Executor) error {
+	return func(ctx context.Context, exec executor.Executor) error {
+		quoted := executor.ShellQuote(command)
+		wrapped := fmt.Sprintf(`if [ "$(id -u)" -eq 0 ]; then sh -c %s; else sudo -n sh -c %s; fi`, quoted, quoted)
+		if out, err := exec.ExecuteContext(ctx, wrapped); err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(out))
+		}
+		return nil
+	}
+}
+
+// ulimitContent renders a limits.d file. Limits apply to the service user's
+// PAM sessions; processes started by supervisord inherit its limits instead.
+func ulimitContent(user string, nofile, nproc int) string {
+	var b strings.Builder
+	b.WriteString("# Managed by mup\n")
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{{"nofile", nofile}, {"nproc", nproc}} {
+		if limit.value > 0 {
+			fmt.Fprintf(&b, "%s soft %s %d\n", user, limit.name, limit.value)
+			fmt.Fprintf(&b, "%s hard %s %d\n", user, limit.name, limit.value)
+		}
+	}
+	return b.String()
+}
+
+// summarizeUlimits reduces a limits.d file to "nofile=N nproc=M", using the
+// hard limits, or "unset" when it has none
+func summarizeUlimits(content string) string {
+	var parts []string
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 4 && fields[1] == "hard" {
+			parts = append(parts, fields[2]+"="+fields[3])
+		}
+	}
+	if len(parts) == 0 {
+		return "unset"
+	}
+	return strings.Join(parts, " ")
+}
+
+// selectedOption returns the bracketed choice from a sysfs option list such
+// as "always madvise [never]"
+func selectedOption(out string) string {
+	for _, field := range strings.Fields(out) {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			return strings.Trim(field, "[]")
+		}
+	}
+	return strings.TrimSpace(out)
+}
@@ -0,0 +1,61 @@
+package operation_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/operation"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/simulation"
+)
+
+func TestPrepareOSHandler_ExistingGroupIsComplete(t *testing.T) {
+	config := simulation.NewConfig()
+	config.Responses["getent group mongod >/dev/null && echo present || echo absent"] = "present\n"
+	exec := simulation.NewExecutor(config)
+
+	handler := operation.NewPrepareOSHandler()
+	op := NewTestOperation(plan.OpEnsureGroup, map[string]interface{}{"group": "mongod"})
+
+	done, err := handler.IsComplete(context.Background(), op, exec)
+	require.NoError(t, err)
+	assert.True(t, done)
+
+	result, err := handler.Execute(context.Background(), op, exec)
+	require.NoError(t, err)
+	require.Len(t, result.Changes, 1)
+	assert.Equal(t, plan.ActionNone, result.Changes[0].Action)
+	assert.Equal(t, "present", result.Changes[0].Before)
+}
+
+func TestPrepareOSHandler_CheckFilesystem(t *testing.T) {
+	config := simulation.NewConfig()
+	config.Responses[`p=/data/mongod-27017; while [ ! -e "$p" ]; do p=$(dirname "$p"); done; df -PT "$p" | awk 'NR==2 {print $2}'`] = "ext4\n"
+	exec := simulation.NewExecutor(config)
+
+	handler := operation.NewPrepareOSHandler()
+	ctx := context.Background()
+
+	op := NewTestOperation(plan.OpCheckFilesystem, map[string]interface{}{
+		"path":        "/data/mongod-27017",
+		"filesystems": []string{"xfs"},
+	})
+	_, err := handler.Execute(ctx, op, exec)
+	assert.ErrorContains(t, err, "filesystem /data/mongod-27017 is ext4, expected xfs")
+
+	op.Params["filesystems"] = []string{"xfs", "ext4"}
+	result, err := handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, "ext4", result.Changes[0].After)
+}
+
+func TestPrepareOSHandler_InvalidParams(t *testing.T) {
+	exec := simulation.NewExecutor(simulation.NewConfig())
+	handler := operation.NewPrepareOSHandler()
+
+	result, err := handler.PreHook(context.Background(), NewTestOperation(plan.OpSetSysctl, map[string]interface{}{"key": "vm.swappiness"}), exec)
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+}
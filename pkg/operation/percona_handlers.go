@@ -0,0 +1,169 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/zph/mup/pkg/apply"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/template"
+)
+
+// GenerateEncryptionKeyParams defines typed parameters for generate_encryption_key operation
+type GenerateEncryptionKeyParams struct {
+	KeyFile       string `json:"key_file" validate:"required"`
+	SourceKeyFile string `json:"source_key_file,omitempty"` // Existing key to copy instead of generating one
+}
+
+// GenerateEncryptionKeyHandler creates the cluster's data-at-rest encryption
+// key on the machine running mup. An existing key is kept: data encrypted
+// with it cannot be read with another.
+type GenerateEncryptionKeyHandler struct{}
+
+// IsComplete checks whether the key exists
+// REQ-PES-036: Check if operation was already completed
+func (h *GenerateEncryptionKeyHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return false, fmt.Errorf("unmarshal params: %w", err)
+	}
+	_, err = os.Stat(params.KeyFile)
+	return err == nil, nil
+}
+
+// PreHook validates parameters and the source key
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *GenerateEncryptionKeyHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		result.AddError(err.Error())
+		return result, nil
+	}
+	if params.SourceKeyFile != "" {
+		if _, err := readEncryptionKey(params.SourceKeyFile); err != nil {
+			result.AddError(err.Error())
+		}
+	}
+	if _, err := os.Stat(params.KeyFile); err == nil {
+		result.AddWarning(fmt.Sprintf("encryption key already exists and is kept: %s", params.KeyFile))
+	}
+
+	return result, nil
+}
+
+// Execute writes the key unless it exists
+func (h *GenerateEncryptionKeyHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	// Secrets are never written in simulation
+	if isSimulation(exec) {
+		return &apply.OperationResult{
+			Success: true,
+			Output:  fmt.Sprintf("Would generate encryption key %s", params.KeyFile),
+			Changes: op.Changes,
+		}, nil
+	}
+
+	created, err := security.EnsureSecret(params.KeyFile, security.KeyFileMode, func() ([]byte, error) {
+		if params.SourceKeyFile != "" {
+			return readEncryptionKey(params.SourceKeyFile)
+		}
+		return security.GenerateEncryptionKey()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption key: %w", err)
+	}
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("Encryption key %s (created: %t)", params.KeyFile, created),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"key_file": params.KeyFile,
+			"created":  created,
+		},
+	}, nil
+}
+
+// PostHook verifies the key is valid and private
+// REQ-PES-048: Post-execution verification
+func (h *GenerateEncryptionKeyHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if isSimulation(exec) {
+		return result, nil
+	}
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	if _, err := readEncryptionKey(params.KeyFile); err != nil {
+		result.AddError(err.Error())
+	}
+	if info, err := os.Stat(params.KeyFile); err == nil && info.Mode().Perm()&0077 != 0 {
+		result.AddError(fmt.Sprintf("encryption key %s is accessible by other users (mode %o)", params.KeyFile, info.Mode().Perm()))
+	}
+	result.Metadata["verified"] = result.Valid
+	return result, nil
+}
+
+func (h *GenerateEncryptionKeyHandler) unmarshalParams(params map[string]interface{}) (*GenerateEncryptionKeyParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed GenerateEncryptionKeyParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if typed.KeyFile == "" {
+		return nil, fmt.Errorf("key_file is required")
+	}
+
+	return &typed, nil
+}
+
+// readEncryptionKey reads and validates an encryption key
+func readEncryptionKey(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key: %w", err)
+	}
+	if err := security.ValidateEncryptionKey(content); err != nil {
+		return nil, fmt.Errorf("invalid encryption key %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// perconaOptionsParam returns the optional percona parameter of a
+// generate_config operation
+func perconaOptionsParam(op *plan.PlannedOperation) (*template.PerconaOptions, error) {
+	raw, ok := op.Params["percona"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	if opts, ok := raw.(*template.PerconaOptions); ok {
+		return opts, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshal percona: %w", err)
+	}
+	var opts template.PerconaOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return nil, fmt.Errorf("invalid percona parameter: %w", err)
+	}
+	return &opts, nil
+}
@@ -0,0 +1,73 @@
+package operation_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/operation"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/simulation"
+)
+
+func TestGenerateEncryptionKeyHandler(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "secrets", "encryption.key")
+	op := NewTestOperation(plan.OpGenerateEncryptionKey, map[string]interface{}{
+		"key_file": keyFile,
+	})
+	handler := &operation.GenerateEncryptionKeyHandler{}
+	exec := executor.NewLocalExecutor()
+	ctx := context.Background()
+
+	done, err := handler.IsComplete(ctx, op, exec)
+	require.NoError(t, err)
+	assert.False(t, done)
+
+	_, err = handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	post, err := handler.PostHook(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, post.Valid, post.Errors)
+
+	key, err := os.ReadFile(keyFile)
+	require.NoError(t, err)
+	require.NoError(t, security.ValidateEncryptionKey(key))
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, security.KeyFileMode, info.Mode().Perm())
+
+	// Re-running keeps the key the data is encrypted with
+	done, err = handler.IsComplete(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, done)
+	_, err = handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	again, err := os.ReadFile(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+}
+
+func TestGenerateEncryptionKeyHandler_SourceKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "mine")
+	require.NoError(t, os.WriteFile(source, []byte("c2hvcnQ=\n"), 0600))
+
+	op := NewTestOperation(plan.OpGenerateEncryptionKey, map[string]interface{}{
+		"key_file":        filepath.Join(dir, "encryption.key"),
+		"source_key_file": source,
+	})
+	result, err := (&operation.GenerateEncryptionKeyHandler{}).PreHook(context.Background(), op, executor.NewLocalExecutor())
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Contains(t, result.Errors[0], "invalid encryption key")
+
+	_, err = (&operation.GenerateEncryptionKeyHandler{}).Execute(context.Background(), op, simulation.NewExecutor(simulation.NewConfig()))
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "encryption.key"), "simulation writes no secrets")
+}
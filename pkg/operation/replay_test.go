@@ -0,0 +1,39 @@
+package operation_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/operation"
+	"github.com/zph/mup/pkg/plan"
+)
+
+// Handlers driven by a ReplayExecutor reproduce a recorded run exactly,
+// without touching the filesystem
+func TestCreateDirectoryHandler_RecordAndReplay(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cluster", "data")
+	op := NewTestOperation(plan.OpCreateDirectory, MarshalParams(operation.CreateDirectoryParams{
+		Path: dir,
+		Mode: 0755,
+	}))
+	handler := operation.NewCreateDirectoryHandlerV2()
+
+	// Record a real run
+	cassette := executor.NewCassette()
+	rec := executor.NewRecordingExecutor(executor.NewLocalExecutor(), "localhost", cassette)
+	NewHandlerTestSuite(t, handler, rec).TestFullLifecycle(op)
+	require.Positive(t, cassette.Len())
+
+	cassettePath := filepath.Join(t.TempDir(), "create_directory.json")
+	require.NoError(t, cassette.Save(cassettePath))
+
+	// Replay it
+	loaded, err := executor.LoadCassette(cassettePath)
+	require.NoError(t, err)
+	replay := executor.NewReplayExecutor(loaded, "localhost")
+	NewHandlerTestSuite(t, handler, replay).TestFullLifecycle(op)
+	assert.Equal(t, 0, replay.Remaining(), "replayed run should make every recorded call")
+}
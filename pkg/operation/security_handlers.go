@@ -0,0 +1,579 @@
+package operation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zph/mup/pkg/apply"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// simulatedPassword stands in for the admin password in simulation, where
+// no password file is written
+const simulatedPassword = "simulated"
+
+// GenerateKeyFileParams defines typed parameters for generate_keyfile operation
+type GenerateKeyFileParams struct {
+	KeyFile       string `json:"key_file" validate:"required"`
+	SourceKeyFile string `json:"source_key_file,omitempty"` // Existing keyFile to copy instead of generating one
+
+	// AdminUser's password is kept in CredentialsFile, the encrypted
+	// credential store
+	AdminUser       string `json:"admin_user" validate:"required"`
+	CredentialsFile string `json:"credentials_file" validate:"required"`
+
+	// PasswordFile is the plaintext admin password written by earlier
+	// versions. It is moved into the credential store, never created.
+	PasswordFile string `json:"password_file,omitempty"`
+}
+
+// adminPasswordStored reports whether the admin password is in the
+// credential store and no plaintext copy is left
+func (p *GenerateKeyFileParams) adminPasswordStored() bool {
+	if p.PasswordFile != "" {
+		if _, err := os.Stat(p.PasswordFile); err == nil {
+			return false
+		}
+	}
+	if _, err := os.Stat(p.CredentialsFile); err != nil {
+		return false
+	}
+	keys, err := security.DefaultKeySource()
+	if err != nil {
+		return false
+	}
+	creds, err := security.LoadCredentials(p.CredentialsFile, keys)
+	if err != nil {
+		return false
+	}
+	_, err = creds.Password(p.AdminUser)
+	return err == nil
+}
+
+// ensureAdminPassword stores the admin password in the credential store
+// unless it holds one: the plaintext password file's when it exists, or a
+// generated one. The plaintext file is removed. Reports whether a password
+// was generated.
+func (p *GenerateKeyFileParams) ensureAdminPassword() (bool, error) {
+	keys, err := security.DefaultKeySource()
+	if err != nil {
+		return false, err
+	}
+	creds, err := security.LoadCredentials(p.CredentialsFile, keys)
+	if err != nil {
+		return false, err
+	}
+
+	generated := false
+	if _, err := creds.Password(p.AdminUser); err != nil {
+		var password string
+		if _, statErr := os.Stat(p.PasswordFile); p.PasswordFile != "" && statErr == nil {
+			if password, err = security.ReadPassword(p.PasswordFile); err != nil {
+				return false, err
+			}
+		} else {
+			if password, err = security.GeneratePassword(); err != nil {
+				return false, err
+			}
+			generated = true
+		}
+		creds.Set(p.AdminUser, password)
+		if err := security.SaveCredentials(p.CredentialsFile, creds, keys); err != nil {
+			return false, err
+		}
+	}
+
+	if p.PasswordFile != "" {
+		if err := os.Remove(p.PasswordFile); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to remove plaintext password file: %w", err)
+		}
+	}
+	return generated, nil
+}
+
+// GenerateKeyFileHandler creates the cluster's keyFile and admin password on
+// the machine running mup, the password straight into the encrypted
+// credential store. Existing secrets are kept, so re-running a deploy never
+// locks the nodes out of each other or the admin out of the cluster.
+type GenerateKeyFileHandler struct{}
+
+// IsComplete checks whether the keyFile exists and the admin password is
+// stored
+// REQ-PES-036: Check if operation was already completed
+func (h *GenerateKeyFileHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return false, fmt.Errorf("unmarshal params: %w", err)
+	}
+	if _, err := os.Stat(params.KeyFile); err != nil {
+		return false, nil
+	}
+	return params.adminPasswordStored(), nil
+}
+
+// PreHook validates parameters and the source keyFile
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *GenerateKeyFileHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		result.AddError(err.Error())
+		return result, nil
+	}
+	if params.SourceKeyFile != "" {
+		if _, err := readKeyFile(params.SourceKeyFile); err != nil {
+			result.AddError(err.Error())
+		}
+	}
+	if _, err := os.Stat(params.KeyFile); err == nil {
+		result.AddWarning(fmt.Sprintf("keyFile already exists and is kept: %s", params.KeyFile))
+	}
+
+	return result, nil
+}
+
+// Execute writes the keyFile and admin password unless they exist
+func (h *GenerateKeyFileHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	// Secrets are never written in simulation
+	if isSimulation(exec) {
+		return &apply.OperationResult{
+			Success: true,
+			Output:  fmt.Sprintf("Would generate keyFile %s and store the password of %s in %s", params.KeyFile, params.AdminUser, params.CredentialsFile),
+			Changes: op.Changes,
+		}, nil
+	}
+
+	keyFileCreated, err := security.EnsureSecret(params.KeyFile, security.KeyFileMode, func() ([]byte, error) {
+		if params.SourceKeyFile != "" {
+			return readKeyFile(params.SourceKeyFile)
+		}
+		return security.GenerateKeyFile()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create keyFile: %w", err)
+	}
+
+	passwordCreated, err := params.ensureAdminPassword()
+	if err != nil {
+		return nil, fmt.Errorf("failed to store admin password: %w", err)
+	}
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("KeyFile %s (created: %t), password of %s in %s (created: %t)", params.KeyFile, keyFileCreated, params.AdminUser, params.CredentialsFile, passwordCreated),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"key_file":         params.KeyFile,
+			"key_file_created": keyFileCreated,
+			"credentials_file": params.CredentialsFile,
+			"password_created": passwordCreated,
+		},
+	}, nil
+}
+
+// PostHook verifies the keyFile is valid and private
+// REQ-PES-048: Post-execution verification
+func (h *GenerateKeyFileHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if isSimulation(exec) {
+		return result, nil
+	}
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	if _, err := readKeyFile(params.KeyFile); err != nil {
+		result.AddError(err.Error())
+	}
+	if info, err := os.Stat(params.KeyFile); err == nil && info.Mode().Perm()&0077 != 0 {
+		result.AddError(fmt.Sprintf("keyFile %s is accessible by other users (mode %o)", params.KeyFile, info.Mode().Perm()))
+	}
+	result.Metadata["verified"] = result.Valid
+	return result, nil
+}
+
+func (h *GenerateKeyFileHandler) unmarshalParams(params map[string]interface{}) (*GenerateKeyFileParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed GenerateKeyFileParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if typed.KeyFile == "" {
+		return nil, fmt.Errorf("key_file is required")
+	}
+	if typed.AdminUser == "" {
+		return nil, fmt.Errorf("admin_user is required")
+	}
+	if typed.CredentialsFile == "" {
+		return nil, fmt.Errorf("credentials_file is required")
+	}
+
+	return &typed, nil
+}
+
+// readKeyFile reads and validates a keyFile
+func readKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyFile: %w", err)
+	}
+	if err := security.ValidateKeyFile(content); err != nil {
+		return nil, fmt.Errorf("invalid keyFile %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// DistributeKeyFileParams defines typed parameters for distribute_keyfile operation
+type DistributeKeyFileParams struct {
+	Source string `json:"source" validate:"required"`
+	Dest   string `json:"dest" validate:"required"`
+}
+
+// DistributeKeyFileHandler copies the cluster's keyFile to a remote host with
+// the permissions mongod requires
+type DistributeKeyFileHandler struct{}
+
+// IsComplete always returns false so the host gets the current keyFile
+// REQ-PES-036: Check if operation was already completed
+func (h *DistributeKeyFileHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	return false, nil
+}
+
+// PreHook validates parameters
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *DistributeKeyFileHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if _, err := h.unmarshalParams(op.Params); err != nil {
+		result.AddError(err.Error())
+	}
+	return result, nil
+}
+
+// Execute uploads the keyFile and restricts it to the owner
+func (h *DistributeKeyFileHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	// The keyFile is not generated in simulation
+	content, err := os.ReadFile(params.Source)
+	if err != nil && !isSimulation(exec) {
+		return nil, fmt.Errorf("failed to read keyFile: %w", err)
+	}
+
+	if err := executor.UploadSecret(ctx, exec, content, params.Dest, security.KeyFileMode); err != nil {
+		return nil, err
+	}
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("Distributed keyFile to %s", params.Dest),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"dest": params.Dest,
+		},
+	}, nil
+}
+
+// PostHook verifies the keyFile is on the host
+// REQ-PES-048: Post-execution verification
+func (h *DistributeKeyFileHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	exists, err := exec.FileExistsContext(ctx, params.Dest)
+	if err != nil {
+		return nil, fmt.Errorf("check keyFile exists: %w", err)
+	}
+	if !exists {
+		result.AddError(fmt.Sprintf("keyFile was not created: %s", params.Dest))
+	}
+	result.Metadata["verified"] = exists
+	return result, nil
+}
+
+func (h *DistributeKeyFileHandler) unmarshalParams(params map[string]interface{}) (*DistributeKeyFileParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed DistributeKeyFileParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if typed.Source == "" {
+		return nil, fmt.Errorf("source is required")
+	}
+	if typed.Dest == "" {
+		return nil, fmt.Errorf("dest is required")
+	}
+
+	return &typed, nil
+}
+
+// CreateAdminUserHandler creates the first user on a standalone mongod
+// through the localhost exception. With an address parameter it creates
+// the user through a running cluster whose nodes use transitionToAuth.
+type CreateAdminUserHandler struct{}
+
+// IsComplete always returns false; Execute detects an existing user
+// REQ-PES-036: Check if operation was already completed
+func (h *CreateAdminUserHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	return false, nil
+}
+
+// PreHook validates the auth parameter
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *CreateAdminUserHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	auth, err := authParam(op)
+	address, _ := op.Params["address"].(string)
+	switch {
+	case err != nil:
+		result.AddError(err.Error())
+	case auth == nil:
+		result.AddError("missing required parameter: auth")
+	case address == "" && (auth.Shell == "" || auth.Port == 0):
+		result.AddError("missing required parameter: auth with shell and port")
+	}
+	if _, err := tlsParam(op); err != nil {
+		result.AddError(err.Error())
+	}
+	return result, nil
+}
+
+// Execute creates the user unless it can already log in
+func (h *CreateAdminUserHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	auth, err := authParam(op)
+	if err != nil {
+		return nil, err
+	}
+	if auth == nil {
+		return nil, fmt.Errorf("auth parameter not found")
+	}
+	cred, err := authCredential(auth, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrapCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	result := &bootstrapResult{}
+	if address, _ := op.Params["address"].(string); address != "" {
+		tls, err := tlsParam(op)
+		if err != nil {
+			return nil, err
+		}
+		caFile := ""
+		if tls != nil {
+			caFile = tls.CAFile
+		}
+		if result.existed, err = createUserAt(bootstrapCtx, address, exec, cred, caFile); err != nil {
+			return nil, err
+		}
+	} else if result, err = bootstrapAdminUser(bootstrapCtx, exec, auth, cred, nil); err != nil {
+		return nil, err
+	}
+
+	output := fmt.Sprintf("Created user %s", auth.User)
+	if result.existed {
+		output = fmt.Sprintf("User %s already exists", auth.User)
+	}
+	fmt.Printf("  ✓ %s\n", output)
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  output,
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"user":           auth.User,
+			"already_exists": result.existed,
+		},
+	}, nil
+}
+
+// PostHook reports the created user
+// REQ-PES-048: Post-execution verification
+func (h *CreateAdminUserHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if auth, err := authParam(op); err == nil && auth != nil {
+		result.Metadata["user"] = auth.User
+	}
+	result.Metadata["verified"] = true
+	return result, nil
+}
+
+// authParam returns the optional auth parameter, which is a
+// *deploy.AuthParams in memory and a JSON object once a plan is loaded from
+// disk
+func authParam(op *plan.PlannedOperation) (*deploy.AuthParams, error) {
+	raw, ok := op.Params["auth"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	if auth, ok := raw.(*deploy.AuthParams); ok {
+		return auth, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshal auth: %w", err)
+	}
+	var auth deploy.AuthParams
+	if err := json.Unmarshal(data, &auth); err != nil {
+		return nil, fmt.Errorf("invalid auth parameter: %w", err)
+	}
+	if auth.User == "" || auth.PasswordFile == "" {
+		return nil, fmt.Errorf("auth parameter needs user and password_file")
+	}
+	return &auth, nil
+}
+
+// authCredential reads the password for auth's user from the credential
+// store, or the password file before the store exists
+func authCredential(auth *deploy.AuthParams, exec executor.Executor) (*Credential, error) {
+	if isSimulation(exec) {
+		return &Credential{Username: auth.User, Password: simulatedPassword}, nil
+	}
+	password, err := security.LookupPassword(auth.CredentialsFile, auth.User, auth.PasswordFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{Username: auth.User, Password: password}, nil
+}
+
+// bootstrapResult is the outcome of bootstrapAdminUser
+type bootstrapResult struct {
+	existed bool   // The user could already log in
+	primary string // host:port of the node the user was created on, if known
+}
+
+// bootstrapAdminUser creates the first user on auth's node through the
+// localhost exception. With rsConfig the replica set is initiated first and
+// the user created once the node is primary. A shell on the node's host
+// runs the script from stdin, which keeps the password out of process
+// listings. It is a no-op when the user can already log in.
+func bootstrapAdminUser(ctx context.Context, exec executor.Executor, auth *deploy.AuthParams, cred *Credential, rsConfig bson.M) (*bootstrapResult, error) {
+	script, err := bootstrapScript(cred, rsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	command := fmt.Sprintf("%s --quiet --host %s --port %d", executor.ShellQuote(auth.Shell), executor.ShellQuote(auth.Loopback), auth.Port)
+	if filepath.Base(auth.Shell) == "mongo" && strings.Contains(auth.Loopback, ":") {
+		command += " --ipv6"
+	}
+	if auth.TLSCAFile != "" {
+		for _, arg := range security.ShellTLSFlags(auth.Shell, auth.TLSCAFile) {
+			command += " " + executor.ShellQuote(arg)
+		}
+	}
+
+	output, err := exec.ExecuteWithInputContext(ctx, command, strings.NewReader(script))
+	if isSimulation(exec) {
+		return &bootstrapResult{}, err
+	}
+
+	result := &bootstrapResult{}
+	done := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "mup:error "):
+			return nil, fmt.Errorf("failed to create user %s: %s", auth.User, strings.TrimPrefix(line, "mup:error "))
+		case line == "mup:user-exists":
+			result.existed, done = true, true
+		case line == "mup:user-created":
+			done = true
+		case strings.HasPrefix(line, "mup:primary "):
+			result.primary = strings.TrimPrefix(line, "mup:primary ")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user %s: %w (output: %s)", auth.User, err, strings.TrimSpace(output))
+	}
+	if !done {
+		return nil, fmt.Errorf("failed to create user %s: unexpected shell output: %s", auth.User, strings.TrimSpace(output))
+	}
+	if result.primary == "undefined" {
+		result.primary = ""
+	}
+	return result, nil
+}
+
+// bootstrapScript returns the shell script run by bootstrapAdminUser. It
+// only uses features shared by mongosh and the legacy mongo shell.
+func bootstrapScript(cred *Credential, rsConfig bson.M) (string, error) {
+	user, err := json.Marshal(cred.Username)
+	if err != nil {
+		return "", err
+	}
+	pwd, err := json.Marshal(cred.Password)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "var user = %s, pwd = %s;\n", user, pwd)
+	b.WriteString(`var admin = db.getSiblingDB("admin");
+function fail(msg) { print("mup:error " + msg); quit(1); }
+var authenticated = false;
+try { authenticated = admin.auth(user, pwd); } catch (e) {}
+if (authenticated) { print("mup:user-exists"); print("mup:primary " + admin.runCommand({isMaster: 1}).primary); quit(0); }
+`)
+	if rsConfig != nil {
+		config, err := bson.MarshalExtJSON(rsConfig, false, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode replica set config: %w", err)
+		}
+		// Code 23 is AlreadyInitialized, left by an interrupted deploy
+		fmt.Fprintf(&b, "var res = admin.runCommand({replSetInitiate: %s});\n", config)
+		b.WriteString(`if (!res.ok && res.code !== 23) { fail(tojson(res)); }
+var hello = admin.runCommand({isMaster: 1});
+for (var i = 0; i < 120 && !hello.ismaster; i++) { sleep(500); hello = admin.runCommand({isMaster: 1}); }
+if (!hello.ismaster) { fail("node did not become primary within 60s, primary is " + hello.primary); }
+`)
+	} else {
+		b.WriteString("var hello = admin.runCommand({isMaster: 1});\n")
+	}
+	b.WriteString(`try { admin.createUser({user: user, pwd: pwd, roles: [{role: "root", db: "admin"}]}); } catch (e) { fail(e); }
+print("mup:user-created");
+print("mup:primary " + hello.me);
+`)
+	return b.String(), nil
+}
+
+// isSimulation reports whether exec is a simulation executor
+func isSimulation(exec executor.Executor) bool {
+	return strings.Contains(fmt.Sprintf("%T", exec), "Simulation")
+}
@@ -0,0 +1,208 @@
+package operation_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/operation"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/simulation"
+)
+
+// shellExecutor answers ExecuteWithInputContext with a fixed output and
+// records the command and script it was given
+type shellExecutor struct {
+	executor.Executor
+	output  string
+	command string
+	script  string
+}
+
+func (e *shellExecutor) ExecuteWithInputContext(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	script, err := io.ReadAll(stdin)
+	e.command, e.script = command, string(script)
+	return e.output, err
+}
+
+func TestGenerateKeyFileHandler(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(security.PassphraseEnv, "")
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "secrets", "credentials.enc")
+	passwordFile := filepath.Join(dir, "secrets", "admin.password")
+	op := NewTestOperation(plan.OpGenerateKeyFile, map[string]interface{}{
+		"key_file":         filepath.Join(dir, "secrets", "keyfile"),
+		"admin_user":       "admin",
+		"credentials_file": credentialsFile,
+		"password_file":    passwordFile,
+	})
+	handler := &operation.GenerateKeyFileHandler{}
+	exec := executor.NewLocalExecutor()
+	ctx := context.Background()
+
+	done, err := handler.IsComplete(ctx, op, exec)
+	require.NoError(t, err)
+	assert.False(t, done)
+
+	_, err = handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	post, err := handler.PostHook(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, post.Valid, post.Errors)
+
+	keyFile, err := os.ReadFile(filepath.Join(dir, "secrets", "keyfile"))
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, "secrets", "keyfile"))
+	require.NoError(t, err)
+	assert.Equal(t, security.KeyFileMode, info.Mode().Perm())
+
+	assert.NoFileExists(t, passwordFile, "the admin password is never written in plaintext")
+	password, err := security.LookupPassword(credentialsFile, "admin", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, password)
+
+	// Re-running keeps the secrets the cluster already uses
+	done, err = handler.IsComplete(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, done)
+	_, err = handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	again, err := os.ReadFile(filepath.Join(dir, "secrets", "keyfile"))
+	require.NoError(t, err)
+	assert.Equal(t, keyFile, again)
+	samePassword, err := security.LookupPassword(credentialsFile, "admin", "")
+	require.NoError(t, err)
+	assert.Equal(t, password, samePassword)
+}
+
+func TestGenerateKeyFileHandler_MovesPlaintextPassword(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(security.PassphraseEnv, "")
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials.enc")
+	passwordFile := filepath.Join(dir, "admin.password")
+	require.NoError(t, security.WriteSecret(passwordFile, []byte("s3cret\n"), security.SecretMode))
+
+	op := NewTestOperation(plan.OpGenerateKeyFile, map[string]interface{}{
+		"key_file":         filepath.Join(dir, "keyfile"),
+		"admin_user":       "root",
+		"credentials_file": credentialsFile,
+		"password_file":    passwordFile,
+	})
+	handler := &operation.GenerateKeyFileHandler{}
+	ctx := context.Background()
+
+	result, err := handler.Execute(ctx, op, executor.NewLocalExecutor())
+	require.NoError(t, err)
+	assert.Equal(t, false, result.Metadata["password_created"])
+	assert.NoFileExists(t, passwordFile)
+
+	password, err := security.LookupPassword(credentialsFile, "root", "")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", password, "the password the cluster uses is kept")
+}
+
+func TestGenerateKeyFileHandler_SourceKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "mine")
+	require.NoError(t, os.WriteFile(source, []byte("not a key!"), 0600))
+
+	op := NewTestOperation(plan.OpGenerateKeyFile, map[string]interface{}{
+		"key_file":         filepath.Join(dir, "keyfile"),
+		"source_key_file":  source,
+		"admin_user":       "admin",
+		"credentials_file": filepath.Join(dir, "credentials.enc"),
+	})
+	result, err := (&operation.GenerateKeyFileHandler{}).PreHook(context.Background(), op, executor.NewLocalExecutor())
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Contains(t, result.Errors[0], "base64")
+}
+
+func TestGenerateKeyFileHandler_Simulation(t *testing.T) {
+	dir := t.TempDir()
+	op := NewTestOperation(plan.OpGenerateKeyFile, map[string]interface{}{
+		"key_file":         filepath.Join(dir, "keyfile"),
+		"admin_user":       "admin",
+		"credentials_file": filepath.Join(dir, "credentials.enc"),
+	})
+	_, err := (&operation.GenerateKeyFileHandler{}).Execute(context.Background(), op, simulation.NewExecutor(simulation.NewConfig()))
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "keyfile"), "simulation writes no secrets")
+	assert.NoFileExists(t, filepath.Join(dir, "credentials.enc"), "simulation writes no secrets")
+}
+
+func TestCreateAdminUserHandler(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "admin.password")
+	require.NoError(t, security.WriteSecret(passwordFile, []byte("s3cret\n"), security.SecretMode))
+
+	op := NewTestOperation(plan.OpCreateAdminUser, map[string]interface{}{
+		"auth": &deploy.AuthParams{
+			User:         "admin",
+			PasswordFile: passwordFile,
+			Shell:        "/opt/mongodb/bin/mongosh",
+			Loopback:     "127.0.0.1",
+			Port:         27017,
+		},
+	})
+	handler := &operation.CreateAdminUserHandler{}
+	ctx := context.Background()
+
+	exec := &shellExecutor{output: "mup:user-created\nmup:primary undefined\n"}
+	result, err := handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, false, result.Metadata["already_exists"])
+	assert.Equal(t, "/opt/mongodb/bin/mongosh --quiet --host 127.0.0.1 --port 27017", exec.command)
+	assert.NotContains(t, exec.command, "s3cret", "the password stays out of argv")
+	assert.Contains(t, exec.script, `pwd = "s3cret"`)
+	assert.Contains(t, exec.script, `roles: [{role: "root", db: "admin"}]`)
+	assert.NotContains(t, exec.script, "replSetInitiate")
+
+	exec = &shellExecutor{output: "mup:user-exists\n"}
+	result, err = handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, true, result.Metadata["already_exists"])
+
+	exec = &shellExecutor{output: "mup:error command createUser requires authentication\n"}
+	_, err = handler.Execute(ctx, op, exec)
+	assert.ErrorContains(t, err, "requires authentication")
+
+	exec = &shellExecutor{output: "MongoNetworkError: connect ECONNREFUSED\n"}
+	_, err = handler.Execute(ctx, op, exec)
+	assert.ErrorContains(t, err, "unexpected shell output")
+}
+
+func TestInitReplicaSetHandler_Auth(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "admin.password")
+	require.NoError(t, security.WriteSecret(passwordFile, []byte("s3cret\n"), security.SecretMode))
+
+	op := NewTestOperation(plan.OpInitReplicaSet, map[string]interface{}{
+		"replica_set": "rs0",
+		"members":     []interface{}{"db1:27017", "db2:27017"},
+		// As loaded from a saved plan
+		"auth": map[string]interface{}{
+			"user":          "admin",
+			"password_file": passwordFile,
+			"shell":         "/opt/mongodb/bin/mongo",
+			"loopback":      "::1",
+			"port":          float64(27017),
+		},
+	})
+
+	exec := &shellExecutor{output: "mup:user-created\nmup:primary db1:27017\n"}
+	result, err := (&operation.InitReplicaSetHandler{}).Execute(context.Background(), op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", result.Metadata["user"])
+	assert.Contains(t, exec.command, "--ipv6", "the legacy shell needs --ipv6 for ::1")
+	assert.Contains(t, exec.script, `replSetInitiate: {`)
+	assert.Contains(t, exec.script, `"_id":"rs0"`)
+	assert.Contains(t, exec.script, `"host":"db2:27017"`)
+}
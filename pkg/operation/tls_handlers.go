@@ -0,0 +1,374 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/zph/mup/pkg/apply"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/template"
+)
+
+// GenerateCertificatesParams defines typed parameters for generate_certificates operation
+type GenerateCertificatesParams struct {
+	Cluster      string                      `json:"cluster" validate:"required"`
+	CAFile       string                      `json:"ca_file" validate:"required"`
+	CAKeyFile    string                      `json:"ca_key_file" validate:"required"`
+	Certificates []deploy.CertificateRequest `json:"certificates"`
+}
+
+// GenerateCertificatesHandler creates the cluster CA and issues node
+// certificates on the machine running mup. The CA is kept once created and
+// a certificate is only reissued when it no longer matches its node or is
+// close to expiry, so re-running a deploy leaves valid certificates alone.
+type GenerateCertificatesHandler struct{}
+
+// IsComplete always returns false; Execute keeps valid certificates
+// REQ-PES-036: Check if operation was already completed
+func (h *GenerateCertificatesHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	return false, nil
+}
+
+// PreHook validates parameters and an existing CA
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *GenerateCertificatesHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		result.AddError(err.Error())
+		return result, nil
+	}
+	if _, err := os.Stat(params.CAFile); err == nil {
+		if _, err := loadCA(params.CAFile, params.CAKeyFile); err != nil {
+			result.AddError(err.Error())
+		} else {
+			result.AddWarning(fmt.Sprintf("CA already exists and is kept: %s", params.CAFile))
+		}
+	}
+
+	return result, nil
+}
+
+// Execute creates the CA unless it exists and issues missing or stale
+// node certificates
+func (h *GenerateCertificatesHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	// Keys are never written in simulation
+	if isSimulation(exec) {
+		return &apply.OperationResult{
+			Success: true,
+			Output:  fmt.Sprintf("Would generate CA %s and %d node certificates", params.CAFile, len(params.Certificates)),
+			Changes: op.Changes,
+		}, nil
+	}
+
+	ca, caCreated, err := ensureCA(params)
+	if err != nil {
+		return nil, err
+	}
+
+	issued := []string{}
+	for _, req := range params.Certificates {
+		if existing, err := os.ReadFile(req.File); err == nil && ca.Verify(existing, req.Hosts) == nil {
+			continue
+		}
+		bundle, err := ca.Issue(req.Hosts)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", req.Node, err)
+		}
+		if err := security.WriteSecret(req.File, bundle, security.CertificateMode); err != nil {
+			return nil, fmt.Errorf("node %s: %w", req.Node, err)
+		}
+		issued = append(issued, req.Node)
+	}
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("CA %s (created: %t), issued %d of %d node certificates", params.CAFile, caCreated, len(issued), len(params.Certificates)),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"ca_file":    params.CAFile,
+			"ca_created": caCreated,
+			"issued":     issued,
+		},
+	}, nil
+}
+
+// PostHook verifies every node certificate against the CA
+// REQ-PES-048: Post-execution verification
+func (h *GenerateCertificatesHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if isSimulation(exec) {
+		return result, nil
+	}
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	ca, err := loadCA(params.CAFile, params.CAKeyFile)
+	if err != nil {
+		result.AddError(err.Error())
+		return result, nil
+	}
+	for _, req := range params.Certificates {
+		content, err := os.ReadFile(req.File)
+		if err != nil {
+			result.AddError(fmt.Sprintf("node %s: certificate was not created: %s", req.Node, req.File))
+			continue
+		}
+		if err := ca.Verify(content, req.Hosts); err != nil {
+			result.AddError(fmt.Sprintf("node %s: %v", req.Node, err))
+		}
+	}
+	result.Metadata["verified"] = result.Valid
+	return result, nil
+}
+
+func (h *GenerateCertificatesHandler) unmarshalParams(params map[string]interface{}) (*GenerateCertificatesParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed GenerateCertificatesParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if typed.Cluster == "" {
+		return nil, fmt.Errorf("cluster is required")
+	}
+	if typed.CAFile == "" || typed.CAKeyFile == "" {
+		return nil, fmt.Errorf("ca_file and ca_key_file are required")
+	}
+	for _, req := range typed.Certificates {
+		if req.File == "" || len(req.Hosts) == 0 {
+			return nil, fmt.Errorf("certificate for %s needs file and hosts", req.Node)
+		}
+	}
+
+	return &typed, nil
+}
+
+// ensureCA loads the cluster CA, creating it when neither its certificate
+// nor its key exists
+func ensureCA(params *GenerateCertificatesParams) (*security.CA, bool, error) {
+	_, certErr := os.Stat(params.CAFile)
+	_, keyErr := os.Stat(params.CAKeyFile)
+	if certErr == nil || keyErr == nil {
+		ca, err := loadCA(params.CAFile, params.CAKeyFile)
+		return ca, false, err
+	}
+
+	ca, err := security.NewCA(params.Cluster)
+	if err != nil {
+		return nil, false, err
+	}
+	keyPEM, err := ca.KeyPEM()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := security.WriteSecret(params.CAKeyFile, keyPEM, security.SecretMode); err != nil {
+		return nil, false, fmt.Errorf("failed to write CA key: %w", err)
+	}
+	if err := security.WriteSecret(params.CAFile, ca.CertPEM(), 0644); err != nil {
+		return nil, false, fmt.Errorf("failed to write CA certificate: %w", err)
+	}
+	return ca, true, nil
+}
+
+// loadCA reads the cluster CA from its certificate and key files
+func loadCA(certFile, keyFile string) (*security.CA, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	return security.LoadCA(certPEM, keyPEM)
+}
+
+// DistributeCertificatesParams defines typed parameters for distribute_certificates operation
+type DistributeCertificatesParams struct {
+	Files []deploy.SecretFile `json:"files" validate:"required"`
+}
+
+// DistributeCertificatesHandler copies the CA and a host's node certificates
+// to the host
+type DistributeCertificatesHandler struct{}
+
+// IsComplete always returns false so the host gets the current certificates
+// REQ-PES-036: Check if operation was already completed
+func (h *DistributeCertificatesHandler) IsComplete(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (bool, error) {
+	return false, nil
+}
+
+// PreHook validates parameters
+// REQ-PES-047: Pre-execution validation and user hooks
+func (h *DistributeCertificatesHandler) PreHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+	if _, err := h.unmarshalParams(op.Params); err != nil {
+		result.AddError(err.Error())
+	}
+	return result, nil
+}
+
+// Execute uploads each file with its permissions
+func (h *DistributeCertificatesHandler) Execute(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*apply.OperationResult, error) {
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	dests := make([]string, 0, len(params.Files))
+	for _, f := range params.Files {
+		// Certificates are not issued in simulation
+		content, err := os.ReadFile(f.Source)
+		if err != nil && !isSimulation(exec) {
+			return nil, fmt.Errorf("failed to read certificate: %w", err)
+		}
+		if err := executor.UploadSecret(ctx, exec, content, f.Dest, f.Mode); err != nil {
+			return nil, err
+		}
+		dests = append(dests, f.Dest)
+	}
+
+	return &apply.OperationResult{
+		Success: true,
+		Output:  fmt.Sprintf("Distributed %d certificate files", len(dests)),
+		Changes: op.Changes,
+		Metadata: map[string]interface{}{
+			"dests": dests,
+		},
+	}, nil
+}
+
+// PostHook verifies the files are on the host
+// REQ-PES-048: Post-execution verification
+func (h *DistributeCertificatesHandler) PostHook(ctx context.Context, op *plan.PlannedOperation, exec executor.Executor) (*HookResult, error) {
+	result := NewHookResult()
+
+	params, err := h.unmarshalParams(op.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+	for _, f := range params.Files {
+		exists, err := exec.FileExistsContext(ctx, f.Dest)
+		if err != nil {
+			return nil, fmt.Errorf("check certificate exists: %w", err)
+		}
+		if !exists {
+			result.AddError(fmt.Sprintf("certificate file was not created: %s", f.Dest))
+		}
+	}
+	result.Metadata["verified"] = result.Valid
+	return result, nil
+}
+
+func (h *DistributeCertificatesHandler) unmarshalParams(params map[string]interface{}) (*DistributeCertificatesParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+
+	var typed DistributeCertificatesParams
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	if len(typed.Files) == 0 {
+		return nil, fmt.Errorf("files is required")
+	}
+	for _, f := range typed.Files {
+		if f.Source == "" || f.Dest == "" {
+			return nil, fmt.Errorf("each file needs source and dest")
+		}
+	}
+
+	return &typed, nil
+}
+
+// tlsParam returns the optional tls parameter, which is a *deploy.TLSParams
+// in memory and a JSON object once a plan is loaded from disk
+func tlsParam(op *plan.PlannedOperation) (*deploy.TLSParams, error) {
+	raw, ok := op.Params["tls"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	if tls, ok := raw.(*deploy.TLSParams); ok {
+		return tls, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshal tls: %w", err)
+	}
+	var tls deploy.TLSParams
+	if err := json.Unmarshal(data, &tls); err != nil {
+		return nil, fmt.Errorf("invalid tls parameter: %w", err)
+	}
+	if tls.CAFile == "" {
+		return nil, fmt.Errorf("tls parameter needs ca_file")
+	}
+	return &tls, nil
+}
+
+// tlsOptionsParam returns the optional tls parameter of generate_config,
+// a node's TLS settings
+func tlsOptionsParam(op *plan.PlannedOperation) (*template.TLSOptions, error) {
+	raw, ok := op.Params["tls"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	if opts, ok := raw.(*template.TLSOptions); ok {
+		return opts, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshal tls: %w", err)
+	}
+	var opts template.TLSOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return nil, fmt.Errorf("invalid tls parameter: %w", err)
+	}
+	return &opts, nil
+}
+
+// clientSecurity returns how op's handler connects: as the auth
+// parameter's user and over TLS when op has a tls parameter
+func clientSecurity(op *plan.PlannedOperation, exec executor.Executor) (*ClientSecurity, error) {
+	sec := &ClientSecurity{}
+
+	auth, err := authParam(op)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		if sec.Credential, err = authCredential(auth, exec); err != nil {
+			return nil, err
+		}
+	}
+
+	tls, err := tlsParam(op)
+	if err != nil {
+		return nil, err
+	}
+	if tls != nil {
+		sec.CAFile = tls.CAFile
+	}
+	return sec, nil
+}
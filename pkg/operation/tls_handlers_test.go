@@ -0,0 +1,137 @@
+package operation_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/operation"
+	"github.com/zph/mup/pkg/plan"
+	"github.com/zph/mup/pkg/security"
+	"github.com/zph/mup/pkg/simulation"
+)
+
+func TestGenerateCertificatesHandler(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "db1-27017.pem")
+	hosts := []string{"db1", "localhost", "127.0.0.1", "::1"}
+	op := NewTestOperation(plan.OpGenerateCertificates, map[string]interface{}{
+		"cluster":     "prod",
+		"ca_file":     caFile,
+		"ca_key_file": filepath.Join(dir, "ca.key"),
+		"certificates": []deploy.CertificateRequest{
+			{Node: "mongod-db1-27017", Hosts: hosts, File: certFile},
+		},
+	})
+	handler := &operation.GenerateCertificatesHandler{}
+	exec := executor.NewLocalExecutor()
+	ctx := context.Background()
+
+	result, err := handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, true, result.Metadata["ca_created"])
+	assert.Equal(t, []string{"mongod-db1-27017"}, result.Metadata["issued"])
+	post, err := handler.PostHook(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, post.Valid, post.Errors)
+
+	info, err := os.Stat(certFile)
+	require.NoError(t, err)
+	assert.Equal(t, security.CertificateMode, info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(dir, "ca.key"))
+	require.NoError(t, err)
+	assert.Equal(t, security.SecretMode, info.Mode().Perm())
+
+	// Re-running keeps the CA and valid certificates
+	caPEM, err := os.ReadFile(caFile)
+	require.NoError(t, err)
+	result, err = handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, false, result.Metadata["ca_created"])
+	assert.Empty(t, result.Metadata["issued"])
+	again, err := os.ReadFile(caFile)
+	require.NoError(t, err)
+	assert.Equal(t, caPEM, again)
+
+	// A node with a new name gets a new certificate from the same CA
+	op.Params["certificates"] = []deploy.CertificateRequest{
+		{Node: "mongod-db1-27017", Hosts: append(hosts, "db1.example.com"), File: certFile},
+	}
+	result, err = handler.Execute(ctx, op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mongod-db1-27017"}, result.Metadata["issued"])
+	post, err = handler.PostHook(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, post.Valid, post.Errors)
+}
+
+func TestGenerateCertificatesHandler_Simulation(t *testing.T) {
+	dir := t.TempDir()
+	op := NewTestOperation(plan.OpGenerateCertificates, map[string]interface{}{
+		"cluster":     "prod",
+		"ca_file":     filepath.Join(dir, "ca.pem"),
+		"ca_key_file": filepath.Join(dir, "ca.key"),
+	})
+	_, err := (&operation.GenerateCertificatesHandler{}).Execute(context.Background(), op, simulation.NewExecutor(simulation.NewConfig()))
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "ca.key"), "simulation writes no keys")
+}
+
+func TestDistributeCertificatesHandler(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "ca.pem"), []byte("ca"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "db1.pem"), []byte("node"), 0600))
+	dest := filepath.Join(t.TempDir(), "secrets", "tls")
+
+	op := NewTestOperation(plan.OpDistributeCertificates, map[string]interface{}{
+		"files": []deploy.SecretFile{
+			{Source: filepath.Join(src, "ca.pem"), Dest: filepath.Join(dest, "ca.pem"), Mode: 0644},
+			{Source: filepath.Join(src, "db1.pem"), Dest: filepath.Join(dest, "db1.pem"), Mode: security.CertificateMode},
+		},
+	})
+	handler := &operation.DistributeCertificatesHandler{}
+	exec := executor.NewLocalExecutor()
+	ctx := context.Background()
+
+	// Twice: a read-only certificate from an earlier deploy is replaced
+	for i := 0; i < 2; i++ {
+		_, err := handler.Execute(ctx, op, exec)
+		require.NoError(t, err)
+	}
+	post, err := handler.PostHook(ctx, op, exec)
+	require.NoError(t, err)
+	assert.True(t, post.Valid, post.Errors)
+
+	info, err := os.Stat(filepath.Join(dest, "db1.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, security.CertificateMode, info.Mode().Perm())
+	content, err := os.ReadFile(filepath.Join(dest, "ca.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, "ca", string(content))
+}
+
+func TestCreateAdminUserHandler_TLS(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "admin.password")
+	require.NoError(t, security.WriteSecret(passwordFile, []byte("s3cret\n"), security.SecretMode))
+
+	op := NewTestOperation(plan.OpCreateAdminUser, map[string]interface{}{
+		"auth": &deploy.AuthParams{
+			User:         "admin",
+			PasswordFile: passwordFile,
+			Shell:        "/opt/mongodb/bin/mongo",
+			Loopback:     "127.0.0.1",
+			Port:         27017,
+			TLSCAFile:    "/opt/mongodb/secrets/tls/ca.pem",
+		},
+	})
+	exec := &shellExecutor{output: "mup:user-created\n"}
+	_, err := (&operation.CreateAdminUserHandler{}).Execute(context.Background(), op, exec)
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/mongodb/bin/mongo --quiet --host 127.0.0.1 --port 27017 --ssl --sslCAFile /opt/mongodb/secrets/tls/ca.pem", exec.command)
+}
@@ -167,3 +167,37 @@ func TestUploadFileHandler_PostHook(t *testing.T) {
 		t.Error("PostHook should mark as verified")
 	}
 }
+
+// Test the optional mode param is applied after the upload
+func TestUploadFileHandler_Mode(t *testing.T) {
+	localPath := filepath.Join(t.TempDir(), "mup-cgexec")
+	if err := os.WriteFile(localPath, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	exec := simulation.NewExecutor(simulation.NewConfig())
+	handler := &operation.UploadFileHandler{}
+	op := &plan.PlannedOperation{
+		ID:   "test-upload-mode",
+		Type: plan.OpUploadFile,
+		Params: map[string]interface{}{
+			"local_path":  localPath,
+			"remote_path": "/opt/mongodb/bin/mup-cgexec",
+			"mode":        "0755",
+		},
+	}
+
+	if _, err := handler.Execute(context.Background(), op, exec); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	var chmod bool
+	for _, recorded := range exec.GetOperations() {
+		if recorded.Type == "execute" && recorded.Details == "chmod 0755 /opt/mongodb/bin/mup-cgexec" {
+			chmod = true
+		}
+	}
+	if !chmod {
+		t.Errorf("expected chmod of the uploaded file, got %+v", exec.GetOperations())
+	}
+}
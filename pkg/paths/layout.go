@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/zph/mup/pkg/naming"
 )
 
 // REQ-PM-009 to REQ-PM-015: ClusterLayout manages version directories and symlinks
@@ -34,6 +36,57 @@ func (l *ClusterLayout) DataDir() string {
 	return filepath.Join(l.clusterDir, "data")
 }
 
+// SecretsDir returns the directory holding the cluster's keyFile and
+// passwords, readable by the owner only
+// Pattern: <cluster-dir>/secrets
+func (l *ClusterLayout) SecretsDir() string {
+	return filepath.Join(l.clusterDir, "secrets")
+}
+
+// KeyFile returns the path of the cluster's keyFile on the machine running mup
+func (l *ClusterLayout) KeyFile() string {
+	return filepath.Join(l.SecretsDir(), "keyfile")
+}
+
+// EncryptionKeyFile returns the path of the cluster's data-at-rest
+// encryption key on the machine running mup
+func (l *ClusterLayout) EncryptionKeyFile() string {
+	return filepath.Join(l.SecretsDir(), "encryption.key")
+}
+
+// AdminPasswordFile returns the path of the first user's password
+func (l *ClusterLayout) AdminPasswordFile() string {
+	return filepath.Join(l.SecretsDir(), "admin.password")
+}
+
+// CredentialsFile returns the path of the cluster's encrypted credential store
+func (l *ClusterLayout) CredentialsFile() string {
+	return filepath.Join(l.SecretsDir(), "credentials.enc")
+}
+
+// TLSDir returns the directory holding the cluster CA and node certificates
+// Pattern: <cluster-dir>/secrets/tls
+func (l *ClusterLayout) TLSDir() string {
+	return filepath.Join(l.SecretsDir(), "tls")
+}
+
+// CAFile returns the path of the cluster CA certificate
+func (l *ClusterLayout) CAFile() string {
+	return filepath.Join(l.TLSDir(), "ca.pem")
+}
+
+// CAKeyFile returns the path of the cluster CA's private key, which never
+// leaves the machine running mup
+func (l *ClusterLayout) CAKeyFile() string {
+	return filepath.Join(l.TLSDir(), "ca.key")
+}
+
+// CertificateFile returns the path of a node's certificate and key
+// Pattern: <cluster-dir>/secrets/tls/<host>-<port>.pem
+func (l *ClusterLayout) CertificateFile(host string, port int) string {
+	return filepath.Join(l.TLSDir(), naming.GetHostPortDir(host, port)+".pem")
+}
+
 // REQ-PM-012: CurrentLink returns the path to the "current" symlink
 // The current symlink points to the active version directory (e.g., v7.0.0)
 // This provides a stable reference for monitoring and management tools
@@ -71,7 +124,7 @@ func (l *ClusterLayout) ProcessDir(version, nodeType string, port int) string {
 // NodeDataDir returns the data directory for a specific node
 // REQ-PM-011: Pattern: <cluster-dir>/data/<host>-<port>
 func (l *ClusterLayout) NodeDataDir(host string, port int) string {
-	return filepath.Join(l.DataDir(), fmt.Sprintf("%s-%d", host, port))
+	return filepath.Join(l.DataDir(), naming.GetHostPortDir(host, port))
 }
 
 // BinDir returns the binary directory for a version
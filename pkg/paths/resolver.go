@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/zph/mup/pkg/naming"
 	"github.com/zph/mup/pkg/topology"
 )
 
@@ -60,7 +61,7 @@ func (r *LocalPathResolver) DataDir(host string, port int) (string, error) {
 	}
 
 	// Version-independent data directory
-	return filepath.Join(r.clusterDir, "data", fmt.Sprintf("%s-%d", host, port)), nil
+	return filepath.Join(r.clusterDir, "data", naming.GetHostPortDir(host, port)), nil
 }
 
 // REQ-PM-010: Log directories are version-specific to enable side-by-side versions
@@ -259,6 +260,16 @@ func (r *RemotePathResolver) BinDir() (string, error) {
 	return filepath.Join(r.global.DeployDir, "bin"), nil
 }
 
+// VersionBinDir returns the directory that holds one MongoDB version's binaries
+// on a remote host. Pattern: <deploy_dir>/<version>/bin
+func (r *RemotePathResolver) VersionBinDir(version string) (string, error) {
+	if r.global.DeployDir == "" {
+		return "", fmt.Errorf("failed to resolve version bin_dir: global deploy_dir is empty")
+	}
+
+	return filepath.Join(r.global.DeployDir, version, "bin"), nil
+}
+
 // ConfigFile returns the full path to the config file for a remote node
 // Pattern: <config-dir>/<nodeType>.conf
 // File name is simple since directory path includes instance-specific information
@@ -280,3 +291,54 @@ func (r *RemotePathResolver) LogFile(nodeType, host string, port int, instanceDe
 	}
 	return filepath.Join(logDir, fmt.Sprintf("%s.log", nodeType)), nil
 }
+
+// SupervisorDir returns the directory holding supervisor.ini, supervisor.pid and
+// supervisor.log on a remote host
+func (r *RemotePathResolver) SupervisorDir() (string, error) {
+	if r.global.DeployDir == "" {
+		return "", fmt.Errorf("failed to resolve supervisor_dir: global deploy_dir is empty")
+	}
+
+	return filepath.Join(r.global.DeployDir, "supervisor"), nil
+}
+
+// KeyFile returns the path of the cluster's keyFile on a remote host
+// Pattern: <deploy_dir>/secrets/keyfile
+func (r *RemotePathResolver) KeyFile() (string, error) {
+	if r.global.DeployDir == "" {
+		return "", fmt.Errorf("failed to resolve keyFile: global deploy_dir is empty")
+	}
+
+	return filepath.Join(r.global.DeployDir, "secrets", "keyfile"), nil
+}
+
+// EncryptionKeyFile returns the path of the cluster's data-at-rest
+// encryption key on a remote host
+// Pattern: <deploy_dir>/secrets/encryption.key
+func (r *RemotePathResolver) EncryptionKeyFile() (string, error) {
+	if r.global.DeployDir == "" {
+		return "", fmt.Errorf("failed to resolve encryption key file: global deploy_dir is empty")
+	}
+
+	return filepath.Join(r.global.DeployDir, "secrets", "encryption.key"), nil
+}
+
+// CAFile returns the path of the cluster CA certificate on a remote host
+// Pattern: <deploy_dir>/secrets/tls/ca.pem
+func (r *RemotePathResolver) CAFile() (string, error) {
+	if r.global.DeployDir == "" {
+		return "", fmt.Errorf("failed to resolve CA file: global deploy_dir is empty")
+	}
+
+	return filepath.Join(r.global.DeployDir, "secrets", "tls", "ca.pem"), nil
+}
+
+// CertificateFile returns the path of a node's certificate and key on its host
+// Pattern: <deploy_dir>/secrets/tls/<host>-<port>.pem
+func (r *RemotePathResolver) CertificateFile(host string, port int) (string, error) {
+	if r.global.DeployDir == "" {
+		return "", fmt.Errorf("failed to resolve certificate file: global deploy_dir is empty")
+	}
+
+	return filepath.Join(r.global.DeployDir, "secrets", "tls", naming.GetHostPortDir(host, port)+".pem"), nil
+}
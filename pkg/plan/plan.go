@@ -68,6 +68,7 @@ type OperationType string
 const (
 	OpDownloadBinary        OperationType = "download_binary"
 	OpCopyBinary            OperationType = "copy_binary"
+	OpDistributeBinary      OperationType = "distribute_binary"
 	OpCreateDirectory       OperationType = "create_directory"
 	OpCreateSymlink         OperationType = "create_symlink"
 	OpUploadFile            OperationType = "upload_file"
@@ -78,6 +79,7 @@ const (
 	OpWaitForProcess        OperationType = "wait_for_process"
 	OpInitReplicaSet        OperationType = "init_replica_set"
 	OpAddShard              OperationType = "add_shard"
+	OpSetDefaultRWConcern   OperationType = "set_default_rw_concern"
 	OpVerifyHealth          OperationType = "verify_health"
 	OpSaveMetadata          OperationType = "save_metadata"
 	OpStopProcess           OperationType = "stop_process"
@@ -89,6 +91,30 @@ const (
 	OpDrainNode             OperationType = "drain_node"
 	OpImportData            OperationType = "import_data"
 	OpValidateData          OperationType = "validate_data"
+
+	// Host OS preparation (prepare_os phase)
+	OpEnsureGroup     OperationType = "ensure_group"
+	OpEnsureUser      OperationType = "ensure_user"
+	OpSetUlimit       OperationType = "set_ulimit"
+	OpSetSysctl       OperationType = "set_sysctl"
+	OpSetTHP          OperationType = "set_transparent_hugepages"
+	OpCheckFilesystem OperationType = "check_filesystem"
+
+	// Access control
+	OpGenerateKeyFile   OperationType = "generate_keyfile"
+	OpDistributeKeyFile OperationType = "distribute_keyfile"
+	OpCreateAdminUser   OperationType = "create_admin_user"
+	OpConfigureNodeAuth OperationType = "configure_node_auth"
+	OpRestartNode       OperationType = "restart_node"
+	OpManageDBUser      OperationType = "manage_db_user"
+	OpManageDBRole      OperationType = "manage_db_role"
+
+	// TLS
+	OpGenerateCertificates   OperationType = "generate_certificates"
+	OpDistributeCertificates OperationType = "distribute_certificates"
+
+	// Percona Server for MongoDB
+	OpGenerateEncryptionKey OperationType = "generate_encryption_key"
 )
 
 // OperationTarget describes what the operation acts on
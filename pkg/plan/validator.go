@@ -223,6 +223,59 @@ func ValidateTopology(topo interface{}) []ValidationIssue {
 	return issues
 }
 
+// IsDestructive reports whether op removes data or takes the cluster down:
+// it removes a directory, stops a process or deletes a resource such as a
+// database user or role
+func IsDestructive(op *PlannedOperation) bool {
+	switch op.Type {
+	case OpRemoveDirectory, OpStopProcess:
+		return true
+	}
+	for _, change := range op.Changes {
+		if change.Action == ActionDelete {
+			return true
+		}
+	}
+	return false
+}
+
+// HasDestructiveOperations reports whether any operation of p is destructive
+func HasDestructiveOperations(p *Plan) bool {
+	for _, phase := range p.Phases {
+		for i := range phase.Operations {
+			if IsDestructive(&phase.Operations[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateProtected fails every destructive operation of p when p's cluster
+// is protected
+func ValidateProtected(p *Plan, protected bool) ValidationResult {
+	result := ValidationResult{Valid: true}
+	if !protected {
+		return result
+	}
+	for _, phase := range p.Phases {
+		for i := range phase.Operations {
+			op := &phase.Operations[i]
+			if !IsDestructive(op) {
+				continue
+			}
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationIssue{
+				Code:     "protected_cluster",
+				Message:  fmt.Sprintf("%s (%s %s) is destructive but cluster %s is protected (mup cluster unprotect %s)", op.ID, op.Type, op.Target.Name, p.ClusterName, p.ClusterName),
+				Host:     op.Target.Host,
+				Severity: "error",
+			})
+		}
+	}
+	return result
+}
+
 // CombineValidationResults merges multiple validation results
 func CombineValidationResults(results ...ValidationResult) ValidationResult {
 	combined := ValidationResult{
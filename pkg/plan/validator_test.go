@@ -160,6 +160,38 @@ func TestValidateTopology_Nil(t *testing.T) {
 	assert.Equal(t, "error", issues[0].Severity)
 }
 
+func TestValidateProtected(t *testing.T) {
+	p := &Plan{
+		ClusterName: "prod",
+		Phases: []PlannedPhase{{
+			Name: "cleanup",
+			Operations: []PlannedOperation{
+				{ID: "cleanup-000", Type: OpStopProcess, Target: OperationTarget{Name: "mongod-27017"}},
+				{ID: "cleanup-001", Type: OpRemoveDirectory, Target: OperationTarget{Name: "/data/db", Host: "db1"}},
+				{ID: "users-002", Type: OpManageDBUser, Target: OperationTarget{Name: "app@admin"},
+					Changes: []Change{{ResourceType: "db_user", ResourceID: "app@admin", Action: ActionCreate}}},
+				{ID: "drop-003", Type: OpManageDBUser, Target: OperationTarget{Name: "old@admin"},
+					Changes: []Change{{ResourceType: "db_user", ResourceID: "old@admin", Action: ActionDelete}}},
+			},
+		}},
+	}
+
+	assert.True(t, ValidateProtected(p, false).Valid)
+	assert.True(t, HasDestructiveOperations(p))
+
+	result := ValidateProtected(p, true)
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 3, "creating a user is not destructive")
+	assert.Equal(t, "protected_cluster", result.Errors[0].Code)
+	assert.Contains(t, result.Errors[0].Message, "cleanup-000 (stop_process mongod-27017) is destructive but cluster prod is protected")
+	assert.Equal(t, "db1", result.Errors[1].Host)
+	assert.Contains(t, result.Errors[2].Message, "drop-003 (manage_db_user old@admin)")
+
+	p.Phases[0].Operations = p.Phases[0].Operations[2:3]
+	assert.False(t, HasDestructiveOperations(p))
+	assert.True(t, ValidateProtected(p, true).Valid)
+}
+
 func TestCombineValidationResults(t *testing.T) {
 	result1 := ValidationResult{
 		Valid: true,
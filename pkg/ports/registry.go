@@ -0,0 +1,332 @@
+package ports
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// Services recorded in the registry
+const (
+	ServiceMongod          = "mongod"
+	ServiceMongos          = "mongos"
+	ServiceConfig          = "config"
+	ServiceSupervisor      = "supervisor"
+	ServiceVictoriaMetrics = "victoria-metrics"
+	ServiceGrafana         = "grafana"
+	ServiceNodeExporter    = "node_exporter"
+	ServiceMongoDBExporter = "mongodb_exporter"
+)
+
+// sharedServices run once per machine or host and are used by every cluster,
+// so two clusters reserving the same one is not a conflict
+var sharedServices = map[string]bool{
+	ServiceVictoriaMetrics: true,
+	ServiceGrafana:         true,
+	ServiceNodeExporter:    true,
+}
+
+// Reservation is a port held by a managed cluster, whether or not the
+// process behind it is currently running
+type Reservation struct {
+	Cluster string `yaml:"cluster" json:"cluster"`
+	Host    string `yaml:"host" json:"host"`
+	Port    int    `yaml:"port" json:"port"`
+	Service string `yaml:"service" json:"service"`
+	Node    string `yaml:"node,omitempty" json:"node,omitempty"` // host:port of the MongoDB process an exporter scrapes
+}
+
+// Registry is the persistent record of ports reserved by every cluster mup
+// manages. Port probing only sees running processes; the registry also
+// covers stopped clusters so their ports are not handed out again.
+type Registry struct {
+	path         string
+	lock         *os.File      // held from Open until Save or Close
+	Reservations []Reservation `yaml:"reservations"`
+}
+
+// DefaultPath returns ~/.mup/storage/ports.yaml
+func DefaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".mup", "storage", "ports.yaml"), nil
+}
+
+// OpenDefault opens the registry at DefaultPath for update
+func OpenDefault() (*Registry, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Open(path)
+}
+
+// LoadDefault reads the registry at DefaultPath without locking it
+func LoadDefault() (*Registry, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// Open locks the registry at path and loads it. The lock is held until Save
+// or Close, so concurrent deploys and destroys cannot overwrite each other's
+// reservations.
+func Open(path string) (*Registry, error) {
+	lock, err := lockFile(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	r, err := Load(path)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	r.lock = lock
+	return r, nil
+}
+
+// Load reads the registry at path without locking it, for callers that only
+// check reservations. A missing file is an empty registry.
+func Load(path string) (*Registry, error) {
+	r := &Registry{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read port registry: %w", err)
+	}
+	if err := yaml.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse port registry %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// lockFile opens path and takes an exclusive flock on it, waiting for other
+// holders to finish
+func lockFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create port registry directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open port registry lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock port registry: %w", err)
+	}
+	return f, nil
+}
+
+// Close releases the lock taken by Open. It is safe to call more than once
+// and on registries from Load.
+func (r *Registry) Close() error {
+	if r.lock == nil {
+		return nil
+	}
+	err := r.lock.Close()
+	r.lock = nil
+	if err != nil {
+		return fmt.Errorf("failed to unlock port registry: %w", err)
+	}
+	return nil
+}
+
+// Save writes the registry, replacing the file atomically, and releases the
+// lock taken by Open
+func (r *Registry) Save() error {
+	defer r.Close()
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create port registry directory: %w", err)
+	}
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal port registry: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write port registry: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to write port registry: %w", err)
+	}
+	return nil
+}
+
+// Reserve replaces the cluster's reservations and returns the reservations
+// of other clusters that hold the same ports
+func (r *Registry) Reserve(cluster string, reservations []Reservation) []Reservation {
+	r.Release(cluster)
+
+	var conflicts []Reservation
+	for _, res := range reservations {
+		res.Cluster = cluster
+		res.Host = normalizeHost(res.Host)
+		for _, other := range r.Reservations {
+			if conflicting(res, other) {
+				conflicts = append(conflicts, other)
+			}
+		}
+		r.Reservations = append(r.Reservations, res)
+	}
+	r.sort()
+	return conflicts
+}
+
+// Release removes every reservation of the cluster, reporting whether any
+// existed
+func (r *Registry) Release(cluster string) bool {
+	kept := r.Reservations[:0]
+	for _, res := range r.Reservations {
+		if res.Cluster != cluster {
+			kept = append(kept, res)
+		}
+	}
+	released := len(kept) != len(r.Reservations)
+	r.Reservations = kept
+	return released
+}
+
+// Owner returns the reservation another cluster than except holds on
+// host:port
+func (r *Registry) Owner(host string, port int, except string) (Reservation, bool) {
+	host = normalizeHost(host)
+	for _, res := range r.Reservations {
+		if res.Cluster != except && res.Host == host && res.Port == port {
+			return res, true
+		}
+	}
+	return Reservation{}, false
+}
+
+// Checker returns a topology.PortChecker that rejects ports other clusters
+// have reserved on host
+func (r *Registry) Checker(host, cluster string) topology.PortChecker {
+	return func(port int) (bool, error) {
+		_, reserved := r.Owner(host, port, cluster)
+		return !reserved, nil
+	}
+}
+
+// Cluster returns the cluster's reservations
+func (r *Registry) Cluster(cluster string) []Reservation {
+	var reservations []Reservation
+	for _, res := range r.Reservations {
+		if res.Cluster == cluster {
+			reservations = append(reservations, res)
+		}
+	}
+	return reservations
+}
+
+func (r *Registry) sort() {
+	sort.SliceStable(r.Reservations, func(i, j int) bool {
+		a, b := r.Reservations[i], r.Reservations[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		return a.Port < b.Port
+	})
+}
+
+func conflicting(a, b Reservation) bool {
+	if a.Cluster == b.Cluster || a.Host != b.Host || a.Port != b.Port {
+		return false
+	}
+	return !(a.Service == b.Service && sharedServices[a.Service])
+}
+
+// ForCluster lists the ports a cluster holds: its MongoDB processes, its
+// supervisord HTTP API (when supervisorPort is set) and its monitoring
+// services and exporters
+func ForCluster(metadata *meta.ClusterMetadata, supervisorPort int) []Reservation {
+	var reservations []Reservation
+	add := func(host string, port int, service, node string) {
+		if port > 0 {
+			reservations = append(reservations, Reservation{Cluster: metadata.Name, Host: normalizeHost(host), Port: port, Service: service, Node: node})
+		}
+	}
+
+	for _, node := range metadata.Nodes {
+		add(node.Host, node.Port, node.Type, "")
+	}
+	if supervisorPort > 0 {
+		add("localhost", supervisorPort, ServiceSupervisor, "")
+	}
+
+	if m := metadata.Monitoring; m != nil && m.Enabled {
+		add("localhost", urlPort(m.VictoriaMetricsURL), ServiceVictoriaMetrics, "")
+		add("localhost", urlPort(m.GrafanaURL), ServiceGrafana, "")
+		for _, ne := range m.NodeExporters {
+			add(ne.Host, ne.Port, ServiceNodeExporter, "")
+		}
+		for _, me := range m.MongoDBExporters {
+			add(me.Host, me.ExporterPort, ServiceMongoDBExporter, topology.GetNodeID(me.Host, me.MongoDBPort))
+		}
+	}
+	return reservations
+}
+
+// Record reserves the cluster's ports in the default registry, returning
+// reservations of other clusters on the same ports
+func Record(metadata *meta.ClusterMetadata, supervisorPort int) ([]Reservation, error) {
+	r, err := OpenDefault()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	conflicts := r.Reserve(metadata.Name, ForCluster(metadata, supervisorPort))
+	if err := r.Save(); err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// ReleaseCluster removes the cluster's reservations from the default registry
+func ReleaseCluster(cluster string) error {
+	r, err := OpenDefault()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if !r.Release(cluster) {
+		return nil
+	}
+	return r.Save()
+}
+
+// urlPort returns the port of a URL such as http://localhost:8428, or 0
+func urlPort(raw string) int {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+// normalizeHost maps the local host aliases to localhost
+func normalizeHost(host string) string {
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1", "[::1]", "0.0.0.0":
+		return "localhost"
+	}
+	return host
+}
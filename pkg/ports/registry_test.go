@@ -0,0 +1,136 @@
+package ports
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zph/mup/pkg/meta"
+)
+
+func TestRegistryReserveAndRelease(t *testing.T) {
+	r, err := Open(filepath.Join(t.TempDir(), "ports.yaml"))
+	require.NoError(t, err)
+	assert.Empty(t, r.Reservations)
+
+	conflicts := r.Reserve("a", []Reservation{
+		{Host: "127.0.0.1", Port: 30000, Service: ServiceMongod},
+		{Host: "localhost", Port: 8428, Service: ServiceVictoriaMetrics},
+	})
+	assert.Empty(t, conflicts)
+
+	// Shared monitoring services are not conflicts, node ports are
+	conflicts = r.Reserve("b", []Reservation{
+		{Host: "localhost", Port: 30000, Service: ServiceMongod},
+		{Host: "localhost", Port: 8428, Service: ServiceVictoriaMetrics},
+	})
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "a", conflicts[0].Cluster)
+	assert.Equal(t, 30000, conflicts[0].Port)
+
+	// Reserving again replaces the cluster's reservations
+	r.Reserve("b", []Reservation{{Host: "localhost", Port: 30010, Service: ServiceMongod}})
+	assert.Len(t, r.Cluster("b"), 1)
+
+	owner, ok := r.Owner("::1", 30000, "b")
+	require.True(t, ok)
+	assert.Equal(t, "a", owner.Cluster)
+	_, ok = r.Owner("localhost", 30000, "a")
+	assert.False(t, ok, "a cluster's own ports are not reserved against it")
+
+	assert.True(t, r.Release("a"))
+	assert.False(t, r.Release("a"))
+	_, ok = r.Owner("localhost", 30000, "b")
+	assert.False(t, ok)
+}
+
+func TestRegistrySaveAndOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage", "ports.yaml")
+	r, err := Open(path)
+	require.NoError(t, err)
+	r.Reserve("a", []Reservation{{Host: "db1", Port: 27017, Service: ServiceMongod}})
+	require.NoError(t, r.Save())
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Reservation{{Cluster: "a", Host: "db1", Port: 27017, Service: ServiceMongod}}, loaded.Reservations)
+}
+
+func TestRegistryOpenWaitsForSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.yaml")
+	first, err := Open(path)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		second, err := Open(path)
+		if err != nil {
+			done <- err
+			return
+		}
+		second.Reserve("b", []Reservation{{Host: "localhost", Port: 30010, Service: ServiceMongod}})
+		done <- second.Save()
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("second Open did not wait for the lock: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	first.Reserve("a", []Reservation{{Host: "localhost", Port: 30000, Service: ServiceMongod}})
+	require.NoError(t, first.Save())
+	require.NoError(t, <-done)
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Len(t, loaded.Cluster("a"), 1, "the first writer's reservations survive")
+	assert.Len(t, loaded.Cluster("b"), 1)
+}
+
+func TestRegistryChecker(t *testing.T) {
+	r, err := Open(filepath.Join(t.TempDir(), "ports.yaml"))
+	require.NoError(t, err)
+	r.Reserve("stopped", []Reservation{{Host: "localhost", Port: 30001, Service: ServiceMongod}})
+
+	check := r.Checker("localhost", "new")
+	ok, err := check(30001)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = check(30002)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, _ = r.Checker("localhost", "stopped")(30001)
+	assert.True(t, ok)
+}
+
+func TestForCluster(t *testing.T) {
+	metadata := &meta.ClusterMetadata{
+		Name: "prod",
+		Nodes: []meta.NodeMetadata{
+			{Type: "mongod", Host: "localhost", Port: 30000},
+			{Type: "mongos", Host: "localhost", Port: 30001},
+		},
+		Monitoring: &meta.MonitoringMetadata{
+			Enabled:            true,
+			VictoriaMetricsURL: "http://localhost:8428",
+			GrafanaURL:         "http://localhost:3000",
+			NodeExporters:      []meta.NodeExporterMetadata{{Host: "localhost", Port: 9100}},
+			MongoDBExporters:   []meta.MongoDBExporterMetadata{{Host: "localhost", ExporterPort: 9216, MongoDBPort: 30000}},
+		},
+	}
+
+	assert.Equal(t, []Reservation{
+		{Cluster: "prod", Host: "localhost", Port: 30000, Service: ServiceMongod},
+		{Cluster: "prod", Host: "localhost", Port: 30001, Service: ServiceMongos},
+		{Cluster: "prod", Host: "localhost", Port: 19123, Service: ServiceSupervisor},
+		{Cluster: "prod", Host: "localhost", Port: 8428, Service: ServiceVictoriaMetrics},
+		{Cluster: "prod", Host: "localhost", Port: 3000, Service: ServiceGrafana},
+		{Cluster: "prod", Host: "localhost", Port: 9100, Service: ServiceNodeExporter},
+		{Cluster: "prod", Host: "localhost", Port: 9216, Service: ServiceMongoDBExporter, Node: "localhost:30000"},
+	}, ForCluster(metadata, 19123))
+}
@@ -0,0 +1,313 @@
+package security
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CertificateMode is the permission of files holding a private key
+const CertificateMode os.FileMode = 0400
+
+// CAValidity is how long a cluster CA is valid
+const CAValidity = 10 * 365 * 24 * time.Hour
+
+// CertificateValidity is how long a node certificate is valid
+const CertificateValidity = 2 * 365 * 24 * time.Hour
+
+// RenewBefore is how close to expiry a node certificate is reissued
+const RenewBefore = 30 * 24 * time.Hour
+
+// certificateOrganization is the O of every certificate mup issues. With
+// x509 member authentication mongod treats certificates sharing O, OU and
+// DC as cluster members, so the OU holds the cluster name.
+const certificateOrganization = "mup"
+
+// rsaBits is the key size of CAs and node certificates. RSA keeps the
+// certificates usable by the OpenSSL builds of old MongoDB versions.
+const rsaBits = 2048
+
+// CA is a cluster's certificate authority
+type CA struct {
+	cert    *x509.Certificate
+	key     crypto.Signer
+	certPEM []byte
+}
+
+// NewCA creates a self-signed CA for cluster
+func NewCA(cluster string) (*CA, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization:       []string{certificateOrganization},
+			OrganizationalUnit: []string{cluster},
+			CommonName:         fmt.Sprintf("mup CA %s", cluster),
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(CAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	return &CA{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// LoadCA reads a CA written from CertPEM and KeyPEM
+func LoadCA(certPEM, keyPEM []byte) (*CA, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("certificate %q is not a CA", cert.Subject.CommonName)
+	}
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	return &CA{cert: cert, key: key, certPEM: certPEM}, nil
+}
+
+// Certificate returns the CA's certificate
+func (ca *CA) Certificate() *x509.Certificate {
+	return ca.cert
+}
+
+// CertPEM returns the CA certificate, which nodes and clients trust
+func (ca *CA) CertPEM() []byte {
+	return ca.certPEM
+}
+
+// KeyPEM returns the CA's private key
+func (ca *CA) KeyPEM() ([]byte, error) {
+	return encodePrivateKey(ca.key)
+}
+
+// Issue returns a PEM holding a new certificate and its key for a node
+// reachable under hosts. The first host is the common name. The certificate
+// is valid for server and client use so members can authenticate to each
+// other with it.
+func (ca *CA) Issue(hosts []string) ([]byte, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("failed to issue certificate: no hosts")
+	}
+	key, err := rsa.GenerateKey(rand.Reader, rsaBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key for %s: %w", hosts[0], err)
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization:       ca.cert.Subject.Organization,
+			OrganizationalUnit: ca.cert.Subject.OrganizationalUnit,
+			CommonName:         hosts[0],
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(CertificateValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if template.NotAfter.After(ca.cert.NotAfter) {
+		template.NotAfter = ca.cert.NotAfter
+	}
+	for _, host := range hosts {
+		if ip := parseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue certificate for %s: %w", hosts[0], err)
+	}
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM...), nil
+}
+
+// Verify checks that a PEM written by Issue was signed by this CA, covers
+// hosts and is not due for renewal
+func (ca *CA) Verify(certPEM []byte, hosts []string) error {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		return fmt.Errorf("certificate %q is not valid for this CA: %w", cert.Subject.CommonName, err)
+	}
+	for _, host := range hosts {
+		if err := cert.VerifyHostname(host); err != nil {
+			return fmt.Errorf("certificate %q does not cover %s", cert.Subject.CommonName, host)
+		}
+	}
+	if time.Until(cert.NotAfter) < RenewBefore {
+		return fmt.Errorf("certificate %q expires %s", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// CABundle returns the certificates of cas as one PEM. Nodes trusting the
+// bundle accept certificates issued by any of them, which lets a cluster
+// move to a new CA one node at a time. LoadCA reads the first CA.
+func CABundle(cas ...*CA) []byte {
+	var bundle []byte
+	for _, ca := range cas {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+	}
+	return bundle
+}
+
+// CertificateExpiry returns when the first certificate in a PEM expires
+func CertificateExpiry(certPEM []byte) (time.Time, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+// ClientTLSConfig returns the TLS configuration for connecting to nodes
+// whose certificates were issued by the CAs in caFile
+func ClientTLSConfig(caFile string) (*tls.Config, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA file %s holds no certificates", caFile)
+	}
+	return &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}, nil
+}
+
+// parseCertificate returns the first certificate in a PEM
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no certificate found in PEM")
+		}
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate: %w", err)
+			}
+			return cert, nil
+		}
+	}
+}
+
+// parsePrivateKey returns the first private key in a PEM
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no private key found in PEM")
+		}
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			signer, ok := key.(crypto.Signer)
+			if !ok {
+				return nil, fmt.Errorf("unsupported private key type %T", key)
+			}
+			return signer, nil
+		}
+	}
+}
+
+// encodePrivateKey returns key as PEM, PKCS#1 for RSA keys as older
+// MongoDB versions expect
+func encodePrivateKey(key crypto.Signer) ([]byte, error) {
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// parseIP parses an IP address SAN, dropping an IPv6 zone which
+// certificates cannot hold
+func parseIP(host string) net.IP {
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host)
+}
+
+func newSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certificate serial: %w", err)
+	}
+	return serial, nil
+}
+
+// ShellTLSFlags returns the arguments that make a MongoDB shell connect over
+// TLS, trusting caFile. The legacy mongo shell mup runs for versions before
+// 4.0 only knows the ssl names.
+func ShellTLSFlags(shell, caFile string) []string {
+	if filepath.Base(shell) == "mongo" {
+		return []string{"--ssl", "--sslCAFile", caFile}
+	}
+	return []string{"--tls", "--tlsCAFile", caFile}
+}
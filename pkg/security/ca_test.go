@@ -0,0 +1,125 @@
+package security
+
+import (
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCA_Issue(t *testing.T) {
+	ca, err := NewCA("prod")
+	require.NoError(t, err)
+	assert.True(t, ca.Certificate().IsCA)
+	assert.Equal(t, []string{"prod"}, ca.Certificate().Subject.OrganizationalUnit)
+
+	hosts := []string{"db1", "db1.example.com", "10.0.0.5", "localhost", "127.0.0.1", "::1"}
+	bundle, err := ca.Issue(hosts)
+	require.NoError(t, err)
+	assert.Contains(t, string(bundle), "BEGIN CERTIFICATE")
+	assert.Contains(t, string(bundle), "BEGIN RSA PRIVATE KEY")
+
+	cert, err := parseCertificate(bundle)
+	require.NoError(t, err)
+	assert.Equal(t, "db1", cert.Subject.CommonName)
+	assert.Equal(t, []string{"db1", "db1.example.com", "localhost"}, cert.DNSNames)
+	require.Len(t, cert.IPAddresses, 3)
+	assert.True(t, cert.IPAddresses[2].Equal(net.ParseIP("::1")))
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+	assert.Equal(t, ca.Certificate().Subject.OrganizationalUnit, cert.Subject.OrganizationalUnit,
+		"members share O and OU for x509 member authentication")
+
+	assert.NoError(t, ca.Verify(bundle, hosts))
+	assert.ErrorContains(t, ca.Verify(bundle, []string{"db2"}), "does not cover db2")
+
+	other, err := NewCA("prod")
+	require.NoError(t, err)
+	assert.ErrorContains(t, other.Verify(bundle, hosts), "not valid for this CA")
+
+	_, err = ca.Issue(nil)
+	assert.Error(t, err)
+}
+
+func TestLoadCA(t *testing.T) {
+	ca, err := NewCA("prod")
+	require.NoError(t, err)
+	keyPEM, err := ca.KeyPEM()
+	require.NoError(t, err)
+
+	loaded, err := LoadCA(ca.CertPEM(), keyPEM)
+	require.NoError(t, err)
+	bundle, err := loaded.Issue([]string{"db1"})
+	require.NoError(t, err)
+	assert.NoError(t, ca.Verify(bundle, []string{"db1"}), "certificates issued by the loaded CA verify")
+
+	node, err := ca.Issue([]string{"db1"})
+	require.NoError(t, err)
+	_, err = LoadCA(node, keyPEM)
+	assert.ErrorContains(t, err, "not a CA")
+	_, err = LoadCA(ca.CertPEM(), nil)
+	assert.Error(t, err)
+}
+
+func TestCABundle(t *testing.T) {
+	oldCA, err := NewCA("prod")
+	require.NoError(t, err)
+	newCA, err := NewCA("prod")
+	require.NoError(t, err)
+
+	bundle := CABundle(newCA, oldCA)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(bundle))
+	for _, ca := range []*CA{oldCA, newCA} {
+		node, err := ca.Issue([]string{"db1"})
+		require.NoError(t, err)
+		cert, err := parseCertificate(node)
+		require.NoError(t, err)
+		_, err = cert.Verify(x509.VerifyOptions{Roots: roots})
+		assert.NoError(t, err, "the bundle trusts certificates of both CAs")
+	}
+
+	keyPEM, err := newCA.KeyPEM()
+	require.NoError(t, err)
+	loaded, err := LoadCA(bundle, keyPEM)
+	require.NoError(t, err)
+	assert.Equal(t, newCA.Certificate().Raw, loaded.Certificate().Raw, "LoadCA reads the first CA of a bundle")
+}
+
+func TestCertificateExpiry(t *testing.T) {
+	ca, err := NewCA("prod")
+	require.NoError(t, err)
+	bundle, err := ca.Issue([]string{"db1"})
+	require.NoError(t, err)
+
+	expiry, err := CertificateExpiry(bundle)
+	require.NoError(t, err)
+	assert.True(t, expiry.Before(ca.Certificate().NotAfter))
+
+	_, err = CertificateExpiry([]byte("not a pem"))
+	assert.Error(t, err)
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	ca, err := NewCA("prod")
+	require.NoError(t, err)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.CertPEM(), 0644))
+
+	config, err := ClientTLSConfig(caFile)
+	require.NoError(t, err)
+	assert.NotNil(t, config.RootCAs)
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, nil, 0644))
+	_, err = ClientTLSConfig(empty)
+	assert.ErrorContains(t, err, "no certificates")
+}
+
+func TestShellTLSFlags(t *testing.T) {
+	assert.Equal(t, []string{"--tls", "--tlsCAFile", "/ca.pem"}, ShellTLSFlags("/opt/bin/mongosh", "/ca.pem"))
+	assert.Equal(t, []string{"--ssl", "--sslCAFile", "/ca.pem"}, ShellTLSFlags("/opt/bin/mongo", "/ca.pem"))
+}
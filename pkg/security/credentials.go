@@ -0,0 +1,303 @@
+package security
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv names the environment variable holding the passphrase
+// credential stores are encrypted with. Without it a random key in the
+// user's keyring file is used.
+const PassphraseEnv = "MUP_CREDENTIALS_PASSPHRASE"
+
+// Key sources a credential store can be encrypted with
+const (
+	KeySourcePassphrase = "passphrase"
+	KeySourceKeyring    = "keyring"
+)
+
+const (
+	credentialsVersion = 1
+	keyringBytes       = 32
+
+	// scrypt parameters recommended for interactive use
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// KeySource supplies the secret a credential store's key is derived from
+type KeySource struct {
+	Passphrase  string // Takes precedence over the keyring when set
+	KeyringFile string // Random secret, created on first use
+}
+
+// DefaultKeySource returns the passphrase from PassphraseEnv, falling back
+// to the keyring in ~/.mup/keyring
+func DefaultKeySource() (KeySource, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return KeySource{}, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return KeySource{
+		Passphrase:  os.Getenv(PassphraseEnv),
+		KeyringFile: filepath.Join(homeDir, ".mup", "keyring"),
+	}, nil
+}
+
+// Name returns the source new stores are encrypted with
+func (k KeySource) Name() string {
+	if k.Passphrase != "" {
+		return KeySourcePassphrase
+	}
+	return KeySourceKeyring
+}
+
+// secret returns the secret of the named source
+func (k KeySource) secret(name string) ([]byte, error) {
+	switch name {
+	case KeySourcePassphrase:
+		if k.Passphrase == "" {
+			return nil, fmt.Errorf("credentials are encrypted with a passphrase: set %s", PassphraseEnv)
+		}
+		return []byte(k.Passphrase), nil
+	case KeySourceKeyring:
+		if _, err := EnsureSecret(k.KeyringFile, SecretMode, func() ([]byte, error) {
+			raw := make([]byte, keyringBytes)
+			_, err := rand.Read(raw)
+			return raw, err
+		}); err != nil {
+			return nil, fmt.Errorf("failed to create keyring: %w", err)
+		}
+		content, err := os.ReadFile(k.KeyringFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keyring: %w", err)
+		}
+		return content, nil
+	default:
+		return nil, fmt.Errorf("unknown key source %q", name)
+	}
+}
+
+// StoredCredential is one user's entry in a credential store
+type StoredCredential struct {
+	Password  string    `json:"password"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Credentials are the decrypted content of a credential store
+type Credentials struct {
+	Users     map[string]StoredCredential `json:"users"`
+	KeySource string                      `json:"-"` // Source the store was read with
+}
+
+// Set stores password for user
+func (c *Credentials) Set(user, password string) {
+	if c.Users == nil {
+		c.Users = make(map[string]StoredCredential)
+	}
+	c.Users[user] = StoredCredential{Password: password, UpdatedAt: time.Now().UTC()}
+}
+
+// Password returns user's password
+func (c *Credentials) Password(user string) (string, error) {
+	cred, ok := c.Users[user]
+	if !ok {
+		return "", fmt.Errorf("no credentials stored for user %s", user)
+	}
+	return cred.Password, nil
+}
+
+// UserNames returns the stored users in order
+func (c *Credentials) UserNames() []string {
+	names := make([]string, 0, len(c.Users))
+	for name := range c.Users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// credentialsFile is the encrypted form of Credentials on disk
+type credentialsFile struct {
+	Version    int    `json:"version"`
+	KeySource  string `json:"key_source"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// LoadCredentials decrypts the credential store at path. A missing store
+// holds no credentials.
+func LoadCredentials(path string, keys KeySource) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Credentials{Users: make(map[string]StoredCredential)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	var file credentialsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials %s: %w", path, err)
+	}
+	if file.Version != credentialsVersion {
+		return nil, fmt.Errorf("unsupported credentials version %d in %s", file.Version, path)
+	}
+
+	aead, err := credentialsCipher(keys, file.KeySource, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, []byte(file.KeySource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials %s: wrong %s", path, file.KeySource)
+	}
+
+	creds := &Credentials{}
+	if err := json.Unmarshal(plaintext, creds); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials %s: %w", path, err)
+	}
+	if creds.Users == nil {
+		creds.Users = make(map[string]StoredCredential)
+	}
+	creds.KeySource = file.KeySource
+	return creds, nil
+}
+
+// SaveCredentials encrypts creds into the credential store at path with a
+// fresh salt and nonce. Stores keep the key source they were read with, so
+// setting PassphraseEnv does not re-encrypt a keyring store; new stores use
+// keys.Name(). RekeyCredentials changes the source.
+func SaveCredentials(path string, creds *Credentials, keys KeySource) error {
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+
+	source := creds.KeySource
+	if source == "" {
+		source = keys.Name()
+	}
+	file := credentialsFile{
+		Version:   credentialsVersion,
+		KeySource: source,
+		Salt:      make([]byte, 16),
+		Nonce:     make([]byte, chacha20poly1305.NonceSizeX),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	aead, err := credentialsCipher(keys, file.KeySource, file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, []byte(file.KeySource))
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	if err := WriteSecret(path, data, SecretMode); err != nil {
+		return err
+	}
+	creds.KeySource = source
+	return nil
+}
+
+// RekeyCredentials re-encrypts the credential store at path with the named
+// key source, returning the source it was encrypted with before
+func RekeyCredentials(path string, keys KeySource, source string) (string, error) {
+	if source != KeySourcePassphrase && source != KeySourceKeyring {
+		return "", fmt.Errorf("unknown key source %q (use %s or %s)", source, KeySourcePassphrase, KeySourceKeyring)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("failed to read credentials: %w", err)
+	}
+	creds, err := LoadCredentials(path, keys)
+	if err != nil {
+		return "", err
+	}
+	previous := creds.KeySource
+	creds.KeySource = source
+	if err := SaveCredentials(path, creds, keys); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// credentialsCipher derives the store key from the named source with scrypt
+func credentialsCipher(keys KeySource, source string, salt []byte) (cipher.AEAD, error) {
+	secret, err := keys.secret(source)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(secret, salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive credentials key: %w", err)
+	}
+	return chacha20poly1305.NewX(key)
+}
+
+// EnsurePassword returns name's password from the credential store at path,
+// generating and storing one when the store has none
+func EnsurePassword(path, name string) (string, error) {
+	keys, err := DefaultKeySource()
+	if err != nil {
+		return "", err
+	}
+	creds, err := LoadCredentials(path, keys)
+	if err != nil {
+		return "", err
+	}
+	if password, err := creds.Password(name); err == nil {
+		return password, nil
+	}
+	password, err := GeneratePassword()
+	if err != nil {
+		return "", err
+	}
+	creds.Set(name, password)
+	if err := SaveCredentials(path, creds, keys); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// LookupPassword returns user's password from the credential store at
+// credentialsFile, or from passwordFile when the store does not hold it
+func LookupPassword(credentialsFile, user, passwordFile string) (string, error) {
+	if credentialsFile != "" {
+		if _, err := os.Stat(credentialsFile); err == nil {
+			keys, err := DefaultKeySource()
+			if err != nil {
+				return "", err
+			}
+			creds, err := LoadCredentials(credentialsFile, keys)
+			if err != nil {
+				return "", err
+			}
+			if password, err := creds.Password(user); err == nil || passwordFile == "" {
+				return password, err
+			}
+		}
+	}
+	if passwordFile == "" {
+		return "", fmt.Errorf("no credentials stored for user %s", user)
+	}
+	return ReadPassword(passwordFile)
+}
@@ -0,0 +1,187 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentials_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets", "credentials.enc")
+	keys := KeySource{Passphrase: "correct horse"}
+
+	creds, err := LoadCredentials(path, keys)
+	require.NoError(t, err)
+	assert.Empty(t, creds.Users, "a missing store holds no credentials")
+
+	creds.Set("admin", "s3cret")
+	creds.Set("backup", "other")
+	require.NoError(t, SaveCredentials(path, creds, keys))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, SecretMode, info.Mode().Perm())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "s3cret", "passwords are never stored in plaintext")
+
+	loaded, err := LoadCredentials(path, keys)
+	require.NoError(t, err)
+	assert.Equal(t, KeySourcePassphrase, loaded.KeySource)
+	assert.Equal(t, []string{"admin", "backup"}, loaded.UserNames())
+	password, err := loaded.Password("admin")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", password)
+
+	_, err = loaded.Password("missing")
+	assert.ErrorContains(t, err, "no credentials stored for user missing")
+}
+
+func TestCredentials_WrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	creds := &Credentials{}
+	creds.Set("admin", "s3cret")
+	require.NoError(t, SaveCredentials(path, creds, KeySource{Passphrase: "right"}))
+
+	_, err := LoadCredentials(path, KeySource{Passphrase: "wrong"})
+	assert.ErrorContains(t, err, "wrong passphrase")
+
+	_, err = LoadCredentials(path, KeySource{KeyringFile: filepath.Join(t.TempDir(), "keyring")})
+	assert.ErrorContains(t, err, PassphraseEnv)
+}
+
+func TestCredentials_Keyring(t *testing.T) {
+	dir := t.TempDir()
+	keys := KeySource{KeyringFile: filepath.Join(dir, "keyring")}
+	path := filepath.Join(dir, "credentials.enc")
+
+	creds := &Credentials{}
+	creds.Set("admin", "s3cret")
+	require.NoError(t, SaveCredentials(path, creds, keys))
+
+	info, err := os.Stat(keys.KeyringFile)
+	require.NoError(t, err, "the keyring is created on first use")
+	assert.Equal(t, SecretMode, info.Mode().Perm())
+
+	// Setting a passphrase later does not lock out a keyring store
+	keys.Passphrase = "ignored"
+	loaded, err := LoadCredentials(path, keys)
+	require.NoError(t, err)
+	assert.Equal(t, KeySourceKeyring, loaded.KeySource)
+
+	// Nor does saving with it set re-encrypt the store under the passphrase
+	loaded.Set("backup", "other")
+	require.NoError(t, SaveCredentials(path, loaded, keys))
+	loaded, err = LoadCredentials(path, KeySource{KeyringFile: keys.KeyringFile})
+	require.NoError(t, err)
+	assert.Equal(t, KeySourceKeyring, loaded.KeySource)
+	assert.Equal(t, []string{"admin", "backup"}, loaded.UserNames())
+}
+
+func TestRekeyCredentials(t *testing.T) {
+	dir := t.TempDir()
+	keys := KeySource{KeyringFile: filepath.Join(dir, "keyring"), Passphrase: "correct horse"}
+	path := filepath.Join(dir, "credentials.enc")
+
+	_, err := RekeyCredentials(path, keys, KeySourcePassphrase)
+	assert.Error(t, err, "there is no store to rekey")
+
+	creds := &Credentials{KeySource: KeySourceKeyring}
+	creds.Set("admin", "s3cret")
+	require.NoError(t, SaveCredentials(path, creds, keys))
+
+	previous, err := RekeyCredentials(path, keys, KeySourcePassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, KeySourceKeyring, previous)
+	_, err = LoadCredentials(path, KeySource{KeyringFile: keys.KeyringFile})
+	assert.ErrorContains(t, err, PassphraseEnv, "the keyring no longer opens the store")
+	loaded, err := LoadCredentials(path, keys)
+	require.NoError(t, err)
+	assert.Equal(t, KeySourcePassphrase, loaded.KeySource)
+
+	previous, err = RekeyCredentials(path, keys, KeySourceKeyring)
+	require.NoError(t, err)
+	assert.Equal(t, KeySourcePassphrase, previous)
+	loaded, err = LoadCredentials(path, KeySource{KeyringFile: keys.KeyringFile})
+	require.NoError(t, err)
+	password, err := loaded.Password("admin")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", password)
+
+	_, err = RekeyCredentials(path, keys, "vault")
+	assert.ErrorContains(t, err, `unknown key source "vault"`)
+}
+
+func TestLookupPassword(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(PassphraseEnv, "")
+
+	passwordFile := filepath.Join(dir, "admin.password")
+	require.NoError(t, WriteSecret(passwordFile, []byte("from-file\n"), SecretMode))
+	credentialsFile := filepath.Join(dir, "credentials.enc")
+
+	password, err := LookupPassword(credentialsFile, "admin", passwordFile)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", password, "falls back to the password file without a store")
+
+	keys, err := DefaultKeySource()
+	require.NoError(t, err)
+	creds := &Credentials{}
+	creds.Set("admin", "from-store")
+	require.NoError(t, SaveCredentials(credentialsFile, creds, keys))
+
+	password, err = LookupPassword(credentialsFile, "admin", passwordFile)
+	require.NoError(t, err)
+	assert.Equal(t, "from-store", password)
+
+	password, err = LookupPassword(credentialsFile, "other", passwordFile)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", password, "users missing from the store fall back to the password file")
+
+	_, err = LookupPassword("", "admin", "")
+	assert.Error(t, err)
+}
+
+func TestEnsurePassword(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(PassphraseEnv, "test")
+	path := filepath.Join(dir, "credentials.enc")
+
+	password, err := EnsurePassword(path, "app@app")
+	require.NoError(t, err)
+	assert.Len(t, password, 32)
+
+	again, err := EnsurePassword(path, "app@app")
+	require.NoError(t, err)
+	assert.Equal(t, password, again, "a stored password is reused")
+}
+
+func TestEnsurePassword_KeepsKeySource(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(PassphraseEnv, "")
+	path := filepath.Join(dir, "credentials.enc")
+
+	_, err := EnsurePassword(path, "admin")
+	require.NoError(t, err)
+
+	// A passphrase set for another command must not re-encrypt the store
+	t.Setenv(PassphraseEnv, "test")
+	_, err = EnsurePassword(path, "app@app")
+	require.NoError(t, err)
+
+	t.Setenv(PassphraseEnv, "")
+	keys, err := DefaultKeySource()
+	require.NoError(t, err)
+	creds, err := LoadCredentials(path, keys)
+	require.NoError(t, err)
+	assert.Equal(t, KeySourceKeyring, creds.KeySource)
+	assert.Equal(t, []string{"admin", "app@app"}, creds.UserNames())
+}
@@ -0,0 +1,133 @@
+package security
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeyFileMode is the permission mongod requires on a keyFile
+const KeyFileMode os.FileMode = 0400
+
+// SecretMode is the permission of secrets mup reads back, such as passwords
+const SecretMode os.FileMode = 0600
+
+// keyFileBytes of random data encode to 756 base64 characters; mongod
+// accepts 6 to 1024
+const keyFileBytes = 567
+
+// GenerateKeyFile returns the content of a new random keyFile
+func GenerateKeyFile() ([]byte, error) {
+	raw := make([]byte, keyFileBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate keyFile: %w", err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(raw) + "\n"), nil
+}
+
+// ValidateKeyFile checks that content is a keyFile mongod accepts: 6 to
+// 1024 base64 characters, whitespace ignored
+func ValidateKeyFile(content []byte) error {
+	key := strings.Join(strings.Fields(string(content)), "")
+	if len(key) < 6 || len(key) > 1024 {
+		return fmt.Errorf("keyFile must hold 6 to 1024 characters, has %d", len(key))
+	}
+	for _, r := range key {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '+' || r == '/' || r == '=') {
+			return fmt.Errorf("keyFile may only hold base64 characters, found %q", r)
+		}
+	}
+	return nil
+}
+
+// encryptionKeyBytes is the AES-256 key size of Percona Server for MongoDB
+// data-at-rest encryption
+const encryptionKeyBytes = 32
+
+// GenerateEncryptionKey returns the content of a new encryptionKeyFile: a
+// random 256-bit key, base64 encoded
+func GenerateEncryptionKey() ([]byte, error) {
+	raw := make([]byte, encryptionKeyBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(raw) + "\n"), nil
+}
+
+// ValidateEncryptionKey checks that content is an encryptionKeyFile mongod
+// accepts: a base64 encoded 256-bit key
+func ValidateEncryptionKey(content []byte) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return fmt.Errorf("encryption key must be base64 encoded: %w", err)
+	}
+	if len(key) != encryptionKeyBytes {
+		return fmt.Errorf("encryption key must hold %d bytes, has %d", encryptionKeyBytes, len(key))
+	}
+	return nil
+}
+
+// GeneratePassword returns a random 32 character password that needs no
+// quoting in URIs or shells
+func GeneratePassword() (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// WriteSecret writes content to path with mode, replacing any existing file.
+// The directory is created readable by the owner only.
+func WriteSecret(path string, content []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// EnsureSecret writes generate's output to path unless the file exists,
+// reporting whether it was created. Existing secrets are never replaced so
+// re-running a deploy keeps the cluster's keyFile and passwords.
+func EnsureSecret(path string, mode os.FileMode, generate func() ([]byte, error)) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to check %s: %w", path, err)
+	}
+
+	content, err := generate()
+	if err != nil {
+		return false, err
+	}
+	if err := WriteSecret(path, content, mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReadPassword reads a password file written by EnsureSecret
+func ReadPassword(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	password := strings.TrimSpace(string(data))
+	if password == "" {
+		return "", fmt.Errorf("password file %s is empty", path)
+	}
+	return password, nil
+}
@@ -0,0 +1,83 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateKeyFile(t *testing.T) {
+	key, err := GenerateKeyFile()
+	require.NoError(t, err)
+	assert.Len(t, strings.TrimSpace(string(key)), 756)
+	assert.NoError(t, ValidateKeyFile(key))
+
+	other, err := GenerateKeyFile()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestValidateKeyFile(t *testing.T) {
+	assert.NoError(t, ValidateKeyFile([]byte("abc def\n==\n")), "whitespace is ignored")
+	assert.ErrorContains(t, ValidateKeyFile([]byte("abc")), "6 to 1024")
+	assert.ErrorContains(t, ValidateKeyFile([]byte(strings.Repeat("a", 1025))), "6 to 1024")
+	assert.ErrorContains(t, ValidateKeyFile([]byte("abcdef!")), "base64")
+}
+
+func TestGenerateEncryptionKey(t *testing.T) {
+	key, err := GenerateEncryptionKey()
+	require.NoError(t, err)
+	assert.NoError(t, ValidateEncryptionKey(key))
+
+	assert.ErrorContains(t, ValidateEncryptionKey([]byte("c2hvcnQ=")), "must hold 32 bytes, has 5")
+	assert.ErrorContains(t, ValidateEncryptionKey([]byte("not base64!")), "base64")
+}
+
+func TestGeneratePassword(t *testing.T) {
+	password, err := GeneratePassword()
+	require.NoError(t, err)
+	assert.Len(t, password, 32)
+	assert.NotContains(t, password, "/")
+	assert.NotContains(t, password, "+")
+}
+
+func TestEnsureSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets", "keyfile")
+	generate := func() ([]byte, error) { return []byte("first\n"), nil }
+
+	created, err := EnsureSecret(path, KeyFileMode, generate)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, KeyFileMode, info.Mode().Perm())
+	dir, err := os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), dir.Mode().Perm())
+
+	// An existing secret is never replaced
+	created, err = EnsureSecret(path, KeyFileMode, func() ([]byte, error) { return []byte("second\n"), nil })
+	require.NoError(t, err)
+	assert.False(t, created)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", string(content))
+}
+
+func TestReadPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.password")
+	require.NoError(t, WriteSecret(path, []byte("s3cret\n"), SecretMode))
+
+	password, err := ReadPassword(path)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", password)
+
+	require.NoError(t, WriteSecret(path, []byte("\n"), SecretMode))
+	_, err = ReadPassword(path)
+	assert.ErrorContains(t, err, "empty")
+}
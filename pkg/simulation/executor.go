@@ -1,6 +1,7 @@
 package simulation
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -514,3 +515,179 @@ func (e *SimulationExecutor) Close() error {
 	// Nothing to close in simulation
 	return nil
 }
+
+// ========== Context-aware Variants ==========
+// Simulated operations complete instantly, so the context is only consulted
+// up front: a cancelled context fails the call without recording it.
+
+// CreateDirectoryContext is the context-aware variant of CreateDirectory
+func (e *SimulationExecutor) CreateDirectoryContext(ctx context.Context, path string, mode os.FileMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.CreateDirectory(path, mode)
+}
+
+// UploadFileContext is the context-aware variant of UploadFile
+func (e *SimulationExecutor) UploadFileContext(ctx context.Context, localPath, remotePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.UploadFile(localPath, remotePath)
+}
+
+// UploadContentContext is the context-aware variant of UploadContent
+func (e *SimulationExecutor) UploadContentContext(ctx context.Context, content []byte, remotePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.UploadContent(content, remotePath)
+}
+
+// DownloadFileContext is the context-aware variant of DownloadFile
+func (e *SimulationExecutor) DownloadFileContext(ctx context.Context, remotePath, localPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.DownloadFile(remotePath, localPath)
+}
+
+// FileExistsContext is the context-aware variant of FileExists
+func (e *SimulationExecutor) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return e.FileExists(path)
+}
+
+// RemoveFileContext is the context-aware variant of RemoveFile
+func (e *SimulationExecutor) RemoveFileContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.RemoveFile(path)
+}
+
+// RemoveDirectoryContext is the context-aware variant of RemoveDirectory
+func (e *SimulationExecutor) RemoveDirectoryContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.RemoveDirectory(path)
+}
+
+// ExecuteContext is the context-aware variant of Execute
+func (e *SimulationExecutor) ExecuteContext(ctx context.Context, command string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return e.Execute(command)
+}
+
+// ExecuteWithInputContext is the context-aware variant of ExecuteWithInput
+func (e *SimulationExecutor) ExecuteWithInputContext(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return e.ExecuteWithInput(command, stdin)
+}
+
+// ExecuteStream simulates a streamed command by replaying the configured
+// response one line at a time
+// REQ-SIM-013: Return configured response
+func (e *SimulationExecutor) ExecuteStream(ctx context.Context, command string, onLine func(line string)) error {
+	output, err := e.ExecuteContext(ctx, command)
+	if err != nil {
+		return err
+	}
+
+	output = strings.TrimRight(output, "\n")
+	if output == "" || onLine == nil {
+		return nil
+	}
+	for _, line := range strings.Split(output, "\n") {
+		onLine(line)
+	}
+
+	return nil
+}
+
+// BackgroundContext is the context-aware variant of Background
+func (e *SimulationExecutor) BackgroundContext(ctx context.Context, command string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return e.Background(command)
+}
+
+// MongoExecuteContext is the context-aware variant of MongoExecute
+func (e *SimulationExecutor) MongoExecuteContext(ctx context.Context, host string, command string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return e.MongoExecute(host, command)
+}
+
+// IsProcessRunningContext is the context-aware variant of IsProcessRunning
+func (e *SimulationExecutor) IsProcessRunningContext(ctx context.Context, pid int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return e.IsProcessRunning(pid)
+}
+
+// KillProcessContext is the context-aware variant of KillProcess
+func (e *SimulationExecutor) KillProcessContext(ctx context.Context, pid int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.KillProcess(pid)
+}
+
+// StopProcessContext is the context-aware variant of StopProcess
+func (e *SimulationExecutor) StopProcessContext(ctx context.Context, pid int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.StopProcess(pid)
+}
+
+// GetOSInfoContext is the context-aware variant of GetOSInfo
+func (e *SimulationExecutor) GetOSInfoContext(ctx context.Context) (*executor.OSInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return e.GetOSInfo()
+}
+
+// GetDiskSpaceContext is the context-aware variant of GetDiskSpace
+func (e *SimulationExecutor) GetDiskSpaceContext(ctx context.Context, path string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return e.GetDiskSpace(path)
+}
+
+// CheckPortAvailableContext is the context-aware variant of CheckPortAvailable
+func (e *SimulationExecutor) CheckPortAvailableContext(ctx context.Context, port int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return e.CheckPortAvailable(port)
+}
+
+// UserExistsContext is the context-aware variant of UserExists
+func (e *SimulationExecutor) UserExistsContext(ctx context.Context, username string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return e.UserExists(username)
+}
+
+// CheckConnectivityContext is the context-aware variant of CheckConnectivity
+func (e *SimulationExecutor) CheckConnectivityContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.CheckConnectivity()
+}
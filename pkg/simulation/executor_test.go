@@ -1,6 +1,7 @@
 package simulation
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -270,3 +271,34 @@ func TestSimulationExecutor_InstantReadiness(t *testing.T) {
 	// In real execution, this would take seconds to minutes
 	// In simulation, it's instant
 }
+
+// REQ-SIM-013: Streamed commands replay the configured response line by line
+func TestSimulationExecutor_ExecuteStream(t *testing.T) {
+	config := NewConfig()
+	config.Responses["df -h"] = "Filesystem Size\n/dev/sda1 100G\n"
+	executor := NewExecutor(config)
+
+	var lines []string
+	err := executor.ExecuteStream(context.Background(), "df -h", func(line string) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Filesystem Size", "/dev/sda1 100G"}, lines)
+
+	ops := executor.GetOperations()
+	require.Len(t, ops, 1)
+	assert.Equal(t, "execute", ops[0].Type)
+}
+
+// Cancelled contexts fail without recording an operation
+func TestSimulationExecutor_ContextCancelled(t *testing.T) {
+	executor := NewExecutor(NewConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := executor.ExecuteContext(ctx, "echo test")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, executor.CreateDirectoryContext(ctx, "/tmp/x", 0755), context.Canceled)
+	assert.Empty(t, executor.GetOperations())
+}
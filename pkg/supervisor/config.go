@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 	"text/template"
 
 	"github.com/zph/mup/pkg/naming"
@@ -20,6 +22,13 @@ type ConfigGenerator struct {
 	topology    *topology.Topology
 	version     string
 	binPath     string
+
+	// Resource limits from the topology, and how they are enforced on
+	// each host. cgexecDir is where programs find mup-cgexec.
+	limits     *ResourceLimits
+	cgroupMode CgroupMode
+	hostModes  map[string]CgroupMode
+	cgexecDir  string
 }
 
 // NewConfigGenerator creates a new config generator
@@ -38,10 +47,36 @@ func NewConfigGenerator(clusterDir, clusterName string, topo *topology.Topology,
 	}
 }
 
+// WithCgroupMode overrides cgroup detection, e.g. to generate configs for
+// another host or in tests
+func (g *ConfigGenerator) WithCgroupMode(mode CgroupMode) *ConfigGenerator {
+	g.cgroupMode = mode
+	return g
+}
+
+// WithHostCgroupModes sets the cgroup mode detected on each host. Hosts
+// missing from modes use the WithCgroupMode mode, or none.
+func (g *ConfigGenerator) WithHostCgroupModes(modes map[string]CgroupMode) *ConfigGenerator {
+	g.hostModes = modes
+	return g
+}
+
+// WithCgexecDir sets the directory programs run mup-cgexec from, e.g. the
+// bin directory of remote hosts. It defaults to the cluster's bin directory.
+func (g *ConfigGenerator) WithCgexecDir(dir string) *ConfigGenerator {
+	g.cgexecDir = dir
+	return g
+}
+
 // GenerateAll generates all supervisord configuration files
 // REQ-PM-010: Creates per-node supervisor.conf in version-specific directories,
 // then a main supervisor.ini that includes them via [include] directive
 func (g *ConfigGenerator) GenerateAll() error {
+	// Resolve resource_control limits before writing any program command
+	if err := g.prepareResourceLimits(); err != nil {
+		return err
+	}
+
 	// Step 1: Generate per-node supervisor.conf files
 	if err := g.GeneratePerNodeConfigs(); err != nil {
 		return fmt.Errorf("failed to generate per-node configs: %w", err)
@@ -73,6 +108,10 @@ func (g *ConfigGenerator) GenerateAll() error {
 
 // GenerateUnifiedConfig generates a single supervisor.ini with all programs
 func (g *ConfigGenerator) GenerateUnifiedConfig() error {
+	if err := g.prepareResourceLimits(); err != nil {
+		return err
+	}
+
 	configPath := filepath.Join(g.clusterDir, "supervisor.ini")
 	file, err := os.Create(configPath)
 	if err != nil {
@@ -134,11 +173,11 @@ func (g *ConfigGenerator) writeMongodProgram(file *os.File, host string, port in
 	configPath := filepath.Join(processDir, "config", "mongod.conf")
 	logFile := filepath.Join(processDir, "log", fmt.Sprintf("supervisor-mongod-%d.log", port))
 	// Data is version-independent (in clusterRoot/data/, NOT clusterDir/data/)
-	dataDir := filepath.Join(g.clusterRoot, "data", fmt.Sprintf("%s-%d", host, port))
+	dataDir := filepath.Join(g.clusterRoot, "data", naming.GetHostPortDir(host, port))
 	mongodPath := filepath.Join(g.binPath, "mongod")
 
 	fmt.Fprintf(file, "[program:%s]\n", programName)
-	fmt.Fprintf(file, "command = %s --config %s\n", mongodPath, configPath)
+	fmt.Fprintf(file, "command = %s\n", g.programCommand(host, programName, fmt.Sprintf("%s --config %s", mongodPath, configPath)))
 	fmt.Fprintf(file, "directory = %s\n", dataDir)
 	fmt.Fprintf(file, "autostart = false\n")
 	fmt.Fprintf(file, "autorestart = unexpected\n")
@@ -160,7 +199,7 @@ func (g *ConfigGenerator) writeMongodProgram(file *os.File, host string, port in
 }
 
 // writeMongosProgram writes a mongos program section to the config file
-func (g *ConfigGenerator) writeMongosProgram(file *os.File, _ string, port int) error { //nolint:unparam // error is always nil
+func (g *ConfigGenerator) writeMongosProgram(file *os.File, host string, port int) error { //nolint:unparam // error is always nil
 	programName := fmt.Sprintf("mongos-%d", port)
 	// Per-process directory structure: mongos-{port}/{config,log,bin}
 	processDir := filepath.Join(g.clusterDir, programName)
@@ -169,7 +208,7 @@ func (g *ConfigGenerator) writeMongosProgram(file *os.File, _ string, port int)
 	mongosPath := filepath.Join(g.binPath, "mongos")
 
 	fmt.Fprintf(file, "[program:%s]\n", programName)
-	fmt.Fprintf(file, "command = %s --config %s\n", mongosPath, configPath)
+	fmt.Fprintf(file, "command = %s\n", g.programCommand(host, programName, fmt.Sprintf("%s --config %s", mongosPath, configPath)))
 	fmt.Fprintf(file, "autostart = false\n")
 	fmt.Fprintf(file, "autorestart = unexpected\n")
 	fmt.Fprintf(file, "startsecs = 5\n")
@@ -250,11 +289,11 @@ func (g *ConfigGenerator) generateNodeSupervisorConf(nodeType, host string, port
 	confFileName := naming.GetConfigFileName(nodeType)
 	configPath := filepath.Join(processDir, "config", confFileName)
 	logFile := filepath.Join(processDir, "log", fmt.Sprintf("supervisor-mongod-%d.log", port))
-	dataDir := filepath.Join(g.clusterRoot, "data", fmt.Sprintf("%s-%d", host, port))
+	dataDir := filepath.Join(g.clusterRoot, "data", naming.GetHostPortDir(host, port))
 	mongodPath := filepath.Join(g.binPath, "mongod")
 
 	fmt.Fprintf(file, "[program:%s]\n", programName)
-	fmt.Fprintf(file, "command = %s --config %s\n", mongodPath, configPath)
+	fmt.Fprintf(file, "command = %s\n", g.programCommand(host, programName, fmt.Sprintf("%s --config %s", mongodPath, configPath)))
 	fmt.Fprintf(file, "directory = %s\n", dataDir)
 	fmt.Fprintf(file, "autostart = false\n")
 	fmt.Fprintf(file, "autorestart = unexpected\n")
@@ -270,12 +309,13 @@ func (g *ConfigGenerator) generateNodeSupervisorConf(nodeType, host string, port
 	if replicaSet != "" {
 		fmt.Fprintf(file, "; Replica Set: %s\n", replicaSet)
 	}
+	g.writeLimitsComment(file, host)
 
 	return nil
 }
 
 // generateMongosNodeSupervisorConf creates a supervisor.conf for a mongos router
-func (g *ConfigGenerator) generateMongosNodeSupervisorConf(_ string, port int) error {
+func (g *ConfigGenerator) generateMongosNodeSupervisorConf(host string, port int) error {
 	programName := naming.GetProgramName("mongos", port)
 	processDir := filepath.Join(g.clusterDir, naming.GetProcessDir("mongos", port))
 	confPath := filepath.Join(processDir, "supervisor.conf")
@@ -297,7 +337,7 @@ func (g *ConfigGenerator) generateMongosNodeSupervisorConf(_ string, port int) e
 	mongosPath := filepath.Join(g.binPath, "mongos")
 
 	fmt.Fprintf(file, "[program:%s]\n", programName)
-	fmt.Fprintf(file, "command = %s --config %s\n", mongosPath, configPath)
+	fmt.Fprintf(file, "command = %s\n", g.programCommand(host, programName, fmt.Sprintf("%s --config %s", mongosPath, configPath)))
 	fmt.Fprintf(file, "autostart = false\n")
 	fmt.Fprintf(file, "autorestart = unexpected\n")
 	fmt.Fprintf(file, "startsecs = 5\n")
@@ -309,10 +349,87 @@ func (g *ConfigGenerator) generateMongosNodeSupervisorConf(_ string, port int) e
 	fmt.Fprintf(file, "stopwaitsecs = 30\n")
 	fmt.Fprintf(file, "stopsignal = INT\n")
 	fmt.Fprintf(file, "environment = HOME=\"%s\",USER=\"%s\"\n", os.Getenv("HOME"), os.Getenv("USER"))
+	g.writeLimitsComment(file, host)
 
 	return nil
 }
 
+// prepareResourceLimits parses resource_control and, when limits are set,
+// picks the cgroup mode and installs the cgroupfs wrapper if any host needs
+// it. Remote hosts get their copy uploaded from the cluster's bin directory.
+func (g *ConfigGenerator) prepareResourceLimits() error {
+	limits, err := ParseResourceLimits(g.topology.Global.ResourceControl, g.topology.Global.SystemdConfig)
+	if err != nil {
+		return err
+	}
+	g.limits = limits
+	if limits == nil {
+		return nil
+	}
+
+	if g.cgroupMode == "" {
+		if len(g.hostModes) > 0 {
+			g.cgroupMode = CgroupNone
+		} else {
+			g.cgroupMode = DetectCgroupMode()
+		}
+	}
+
+	needsCgexec := false
+	var unlimited []string
+	for _, host := range g.topology.GetAllHosts() {
+		switch g.modeFor(host) {
+		case CgroupFS:
+			needsCgexec = true
+		case CgroupNone:
+			unlimited = append(unlimited, host)
+		}
+	}
+
+	if needsCgexec {
+		binDir := filepath.Join(g.clusterDir, "bin")
+		if err := os.MkdirAll(binDir, 0755); err != nil {
+			return fmt.Errorf("failed to create cluster bin directory: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(binDir, CgexecScriptName), []byte(cgexecScript), 0755); err != nil {
+			return fmt.Errorf("failed to write %s: %w", CgexecScriptName, err)
+		}
+	}
+	if len(unlimited) > 0 {
+		sort.Strings(unlimited)
+		fmt.Printf("  ⚠ resource_control is set but cgroup v2 is not available on %s; processes there will run without limits\n", strings.Join(unlimited, ", "))
+	}
+
+	return nil
+}
+
+// modeFor returns the cgroup mode of host
+func (g *ConfigGenerator) modeFor(host string) CgroupMode {
+	if mode, ok := g.hostModes[host]; ok {
+		return mode
+	}
+	return g.cgroupMode
+}
+
+// programCommand wraps a program's command line with the resource limits
+// enforced on its host
+func (g *ConfigGenerator) programCommand(host, programName, command string) string {
+	cgexecDir := g.cgexecDir
+	if cgexecDir == "" {
+		cgexecDir = filepath.Join(g.clusterDir, "bin")
+	}
+	cgexecPath := filepath.Join(cgexecDir, CgexecScriptName)
+	return g.limits.WrapCommand(g.modeFor(host), ScopeName(g.clusterName, programName), cgexecPath, command)
+}
+
+// writeLimitsComment records the configured limits in a program section
+func (g *ConfigGenerator) writeLimitsComment(file *os.File, host string) {
+	if g.limits == nil {
+		return
+	}
+	fmt.Fprintf(file, "; Resource limits: %s (enforced via %s)\n", g.limits, g.modeFor(host))
+}
+
 // GenerateMainConfigWithIncludes creates supervisor.ini with [include] directives
 // REQ-PM-010: Main config includes all per-node configs via relative paths
 func (g *ConfigGenerator) GenerateMainConfigWithIncludes() error {
@@ -409,9 +526,9 @@ func (g *ConfigGenerator) GenerateMainConfig() error {
 // GenerateMongodConfig generates supervisord config for a mongod node
 func (g *ConfigGenerator) GenerateMongodConfig(node topology.MongodNode) error {
 	programName := fmt.Sprintf("mongod-%d", node.Port)
-	configPath := filepath.Join(g.clusterDir, "conf", fmt.Sprintf("%s-%d", node.Host, node.Port), "supervisor-mongod.ini")
-	mongodConfigPath := filepath.Join(g.clusterDir, "conf", fmt.Sprintf("%s-%d", node.Host, node.Port), "mongod.conf")
-	dataDir := filepath.Join(g.clusterDir, "data", fmt.Sprintf("%s-%d", node.Host, node.Port))
+	configPath := filepath.Join(g.clusterDir, "conf", naming.GetHostPortDir(node.Host, node.Port), "supervisor-mongod.ini")
+	mongodConfigPath := filepath.Join(g.clusterDir, "conf", naming.GetHostPortDir(node.Host, node.Port), "mongod.conf")
+	dataDir := filepath.Join(g.clusterDir, "data", naming.GetHostPortDir(node.Host, node.Port))
 	logFile := filepath.Join(g.clusterDir, "logs", fmt.Sprintf("supervisor-mongod-%d.log", node.Port))
 
 	tmpl := template.Must(template.New("mongod").Parse(mongodProgramTemplate))
@@ -457,8 +574,8 @@ func (g *ConfigGenerator) GenerateMongodConfig(node topology.MongodNode) error {
 // GenerateMongosConfig generates supervisord config for a mongos router
 func (g *ConfigGenerator) GenerateMongosConfig(node topology.MongosNode) error {
 	programName := fmt.Sprintf("mongos-%d", node.Port)
-	configPath := filepath.Join(g.clusterDir, "conf", fmt.Sprintf("%s-%d", node.Host, node.Port), "supervisor-mongos.ini")
-	mongosConfigPath := filepath.Join(g.clusterDir, "conf", fmt.Sprintf("%s-%d", node.Host, node.Port), "mongos.conf")
+	configPath := filepath.Join(g.clusterDir, "conf", naming.GetHostPortDir(node.Host, node.Port), "supervisor-mongos.ini")
+	mongosConfigPath := filepath.Join(g.clusterDir, "conf", naming.GetHostPortDir(node.Host, node.Port), "mongos.conf")
 	logFile := filepath.Join(g.clusterDir, "logs", fmt.Sprintf("supervisor-mongos-%d.log", node.Port))
 
 	tmpl := template.Must(template.New("mongos").Parse(mongosProgramTemplate))
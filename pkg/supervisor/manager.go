@@ -182,6 +182,12 @@ func (m *Manager) ctl(args ...string) *exec.Cmd {
 	return exec.Command(m.binaryPath, ctlArgs...)
 }
 
+// CtlCommand returns the shell command line running "supervisord ctl" with
+// args, for callers that run it through an executor
+func (m *Manager) CtlCommand(args ...string) string {
+	return ctlCommand(m.binaryPath, m.configPath, m.httpPort, args)
+}
+
 // Stop stops the supervisord daemon
 func (m *Manager) Stop(ctx context.Context) error {
 	if !m.IsRunning() {
@@ -334,39 +340,9 @@ func (m *Manager) GetProcessStatus(name string) (*ProcessStatus, error) {
 
 	// Parse supervisord ctl status output
 	// Format: "progname  STATE  pid 12345, uptime 0:01:23"
-	// Note: May contain ANSI color codes
-	line := strings.TrimSpace(string(output))
-
-	// Remove ANSI color codes
-	line = strings.ReplaceAll(line, "\x1b[0;32m", "")
-	line = strings.ReplaceAll(line, "\x1b[0m", "")
-	line = strings.ReplaceAll(line, "[0;32m", "")
-	line = strings.ReplaceAll(line, "[0m", "")
-
-	fields := strings.Fields(line)
-	if len(fields) < 2 {
-		return nil, fmt.Errorf("unexpected status output format: %s", line)
-	}
-
-	status := &ProcessStatus{
-		Name:  fields[0],
-		State: fields[1],
-	}
-
-	// Extract PID if running - format: "pid 12345,"
-	for i, field := range fields {
-		if field == "pid" && i+1 < len(fields) {
-			pidStr := strings.TrimSuffix(fields[i+1], ",")
-			if pid, err := strconv.Atoi(pidStr); err == nil {
-				status.PID = pid
-			}
-			break
-		}
-	}
-
-	// Get description (rest of line after state)
-	if len(fields) > 2 {
-		status.Description = strings.Join(fields[2:], " ")
+	status, ok := parseStatusLine(string(output))
+	if !ok {
+		return nil, fmt.Errorf("unexpected status output format: %s", strings.TrimSpace(string(output)))
 	}
 
 	return status, nil
@@ -380,50 +356,7 @@ func (m *Manager) GetAllProcesses() ([]*ProcessStatus, error) {
 		return nil, fmt.Errorf("failed to get all process status: %w", err)
 	}
 
-	var statuses []*ProcessStatus
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// Remove ANSI color codes
-		line = strings.ReplaceAll(line, "\x1b[0;32m", "")
-		line = strings.ReplaceAll(line, "\x1b[0m", "")
-		line = strings.ReplaceAll(line, "[0;32m", "")
-		line = strings.ReplaceAll(line, "[0m", "")
-
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			continue
-		}
-
-		status := &ProcessStatus{
-			Name:  fields[0],
-			State: fields[1],
-		}
-
-		// Extract PID - format: "pid 12345,"
-		for i, field := range fields {
-			if field == "pid" && i+1 < len(fields) {
-				pidStr := strings.TrimSuffix(fields[i+1], ",")
-				if pid, err := strconv.Atoi(pidStr); err == nil {
-					status.PID = pid
-				}
-				break
-			}
-		}
-
-		// Get description
-		if len(fields) > 2 {
-			status.Description = strings.Join(fields[2:], " ")
-		}
-
-		statuses = append(statuses, status)
-	}
-
-	return statuses, nil
+	return ParseStatusOutput(string(output)), nil
 }
 
 // StartGroup starts all processes in a group
@@ -466,8 +399,13 @@ func readPortFromConfig(configPath string) (int, error) {
 		return 0, fmt.Errorf("failed to read config: %w", err)
 	}
 
+	return parsePortFromConfig(string(content))
+}
+
+// parsePortFromConfig extracts the HTTP port from supervisor.ini content
+func parsePortFromConfig(content string) (int, error) {
 	// Look for line like: port = 127.0.0.1:19639
-	lines := strings.Split(string(content), "\n")
+	lines := strings.Split(content, "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if strings.HasPrefix(line, "port = 127.0.0.1:") {
@@ -519,3 +457,55 @@ func FindAvailablePort() (int, error) {
 	return 0, fmt.Errorf("no available port found in range %d-%d",
 		DefaultSupervisorBasePort, DefaultSupervisorBasePort+MaxSupervisorPortScanAttempts-1)
 }
+
+// parseStatusLine parses one line of supervisord ctl status output
+// Format: "progname  STATE  pid 12345, uptime 0:01:23"
+// Note: May contain ANSI color codes
+func parseStatusLine(line string) (*ProcessStatus, bool) {
+	line = strings.TrimSpace(line)
+
+	// Remove ANSI color codes
+	line = strings.ReplaceAll(line, "\x1b[0;32m", "")
+	line = strings.ReplaceAll(line, "\x1b[0m", "")
+	line = strings.ReplaceAll(line, "[0;32m", "")
+	line = strings.ReplaceAll(line, "[0m", "")
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, false
+	}
+
+	status := &ProcessStatus{
+		Name:  fields[0],
+		State: fields[1],
+	}
+
+	// Extract PID if running - format: "pid 12345,"
+	for i, field := range fields {
+		if field == "pid" && i+1 < len(fields) {
+			pidStr := strings.TrimSuffix(fields[i+1], ",")
+			if pid, err := strconv.Atoi(pidStr); err == nil {
+				status.PID = pid
+			}
+			break
+		}
+	}
+
+	// Get description (rest of line after state)
+	if len(fields) > 2 {
+		status.Description = strings.Join(fields[2:], " ")
+	}
+
+	return status, true
+}
+
+// ParseStatusOutput parses multi-line supervisord ctl status output
+func ParseStatusOutput(output string) []*ProcessStatus {
+	var statuses []*ProcessStatus
+	for _, line := range strings.Split(output, "\n") {
+		if status, ok := parseStatusLine(line); ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
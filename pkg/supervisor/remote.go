@@ -0,0 +1,197 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zph/mup/pkg/executor"
+)
+
+// RemoteManager drives a supervisord daemon on another host through an Executor.
+// It mirrors Manager, but every command is run via the executor (typically SSH)
+// instead of os/exec, and PID checks happen on the remote host.
+type RemoteManager struct {
+	exec          executor.Executor
+	host          string
+	supervisorDir string // Directory holding supervisor.ini, supervisor.pid, supervisor.log
+	binaryPath    string // Path to supervisord binary on the remote host
+	configPath    string
+	httpPort      int
+}
+
+// NewRemoteManager creates a manager for the supervisord instance on host.
+// The HTTP port is read from the remote supervisor.ini when available.
+func NewRemoteManager(ctx context.Context, exec executor.Executor, host, supervisorDir, binDir string) (*RemoteManager, error) {
+	m := &RemoteManager{
+		exec:          exec,
+		host:          host,
+		supervisorDir: supervisorDir,
+		binaryPath:    filepath.Join(binDir, "supervisord"),
+		configPath:    filepath.Join(supervisorDir, "supervisor.ini"),
+	}
+
+	content, err := exec.ExecuteContext(ctx, fmt.Sprintf("cat %s", shellQuote(m.configPath)))
+	if err != nil {
+		return nil, fmt.Errorf("supervisor config not found at %s on %s: %w", m.configPath, host, err)
+	}
+
+	port, err := parsePortFromConfig(content)
+	if err != nil {
+		// Fall back to the same hash the config generator uses
+		port = getSupervisorHTTPPort(supervisorDir)
+	}
+	m.httpPort = port
+
+	return m, nil
+}
+
+// Host returns the host this manager controls
+func (m *RemoteManager) Host() string {
+	return m.host
+}
+
+// Executor returns the executor used to reach the remote host
+func (m *RemoteManager) Executor() executor.Executor {
+	return m.exec
+}
+
+// IsRunning checks if supervisord is running on the remote host
+func (m *RemoteManager) IsRunning(ctx context.Context) bool {
+	pidFile := shellQuote(filepath.Join(m.supervisorDir, "supervisor.pid"))
+	cmd := fmt.Sprintf("test -f %s && kill -0 $(cat %s) 2>/dev/null", pidFile, pidFile)
+	_, err := m.exec.ExecuteContext(ctx, cmd)
+	return err == nil
+}
+
+// Start starts the supervisord daemon on the remote host
+func (m *RemoteManager) Start(ctx context.Context) error {
+	if m.IsRunning(ctx) {
+		return nil
+	}
+
+	// supervisord daemonizes itself when the config sets nodaemon=false
+	cmd := fmt.Sprintf("%s -c %s -d", shellQuote(m.binaryPath), shellQuote(m.configPath))
+	if _, err := m.exec.ExecuteContext(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to start supervisord on %s: %w", m.host, err)
+	}
+
+	// Wait for the PID file to appear
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if m.IsRunning(ctx) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	return fmt.Errorf("supervisord failed to start on %s - check %s/supervisor.log", m.host, m.supervisorDir)
+}
+
+// Stop shuts down the supervisord daemon on the remote host
+func (m *RemoteManager) Stop(ctx context.Context) error {
+	if !m.IsRunning(ctx) {
+		return nil
+	}
+
+	if _, err := m.ctl(ctx, "shutdown"); err != nil {
+		return fmt.Errorf("failed to stop supervisord on %s: %w", m.host, err)
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		if !m.IsRunning(ctx) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	return fmt.Errorf("supervisord on %s did not stop within 30 seconds", m.host)
+}
+
+// StartProcesses starts the named programs in a single ctl call
+func (m *RemoteManager) StartProcesses(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	output, err := m.ctl(ctx, append([]string{"start"}, names...)...)
+	if err != nil {
+		return fmt.Errorf("failed to start processes on %s: %w (output: %s)", m.host, err, output)
+	}
+	return nil
+}
+
+// StopProcesses stops the named programs in a single ctl call
+func (m *RemoteManager) StopProcesses(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	output, err := m.ctl(ctx, append([]string{"stop"}, names...)...)
+	if err != nil {
+		return fmt.Errorf("failed to stop processes on %s: %w (output: %s)", m.host, err, output)
+	}
+	return nil
+}
+
+// GetProcessStatus returns the status of a program on the remote host
+func (m *RemoteManager) GetProcessStatus(ctx context.Context, name string) (*ProcessStatus, error) {
+	output, err := m.ctl(ctx, "status", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status for %s on %s: %w", name, m.host, err)
+	}
+
+	status, ok := parseStatusLine(output)
+	if !ok {
+		return nil, fmt.Errorf("unexpected status output format: %s", strings.TrimSpace(output))
+	}
+	return status, nil
+}
+
+// GetAllProcesses returns the status of every program on the remote host
+func (m *RemoteManager) GetAllProcesses(ctx context.Context) ([]*ProcessStatus, error) {
+	output, err := m.ctl(ctx, "status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all process status on %s: %w", m.host, err)
+	}
+	return ParseStatusOutput(output), nil
+}
+
+// ctl runs a supervisord ctl command on the remote host
+func (m *RemoteManager) ctl(ctx context.Context, args ...string) (string, error) {
+	return m.exec.ExecuteContext(ctx, m.CtlCommand(args...))
+}
+
+// CtlCommand returns the shell command line running "supervisord ctl" with
+// args on the remote host
+func (m *RemoteManager) CtlCommand(args ...string) string {
+	// The ctl server address is loopback on the remote host, so it is never
+	// exposed beyond the SSH session
+	return ctlCommand(m.binaryPath, m.configPath, m.httpPort, args)
+}
+
+// ctlCommand builds a shell-quoted "supervisord ctl" command line
+func ctlCommand(binaryPath, configPath string, httpPort int, args []string) string {
+	serverURL := fmt.Sprintf("http://localhost:%d", httpPort)
+	parts := []string{shellQuote(binaryPath), "ctl", "-c", shellQuote(configPath), "-s", serverURL}
+	for _, arg := range args {
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes s for safe use as a single POSIX shell word
+func shellQuote(s string) string {
+	return executor.ShellQuote(s)
+}
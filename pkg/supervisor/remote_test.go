@@ -0,0 +1,39 @@
+package supervisor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/simulation"
+)
+
+func TestRemoteManager_GetProcessStatus(t *testing.T) {
+	ctx := context.Background()
+	config := simulation.NewConfig()
+	config.Responses["cat /opt/mup/supervisor/supervisor.ini"] = "[inet_http_server]\nport = 127.0.0.1:19123\n"
+	config.Responses["/opt/mup/bin/supervisord ctl -c /opt/mup/supervisor/supervisor.ini -s http://localhost:19123 status mongod-27017"] =
+		"mongod-27017   RUNNING   pid 4242, uptime 0:10:00"
+	exec := simulation.NewExecutor(config)
+
+	mgr, err := NewRemoteManager(ctx, exec, "db1", "/opt/mup/supervisor", "/opt/mup/bin")
+	require.NoError(t, err)
+	assert.Equal(t, 19123, mgr.httpPort)
+	assert.Equal(t, "db1", mgr.Host())
+
+	status, err := mgr.GetProcessStatus(ctx, "mongod-27017")
+	require.NoError(t, err)
+	assert.Equal(t, "mongod-27017", status.Name)
+	assert.Equal(t, "RUNNING", status.State)
+	assert.Equal(t, 4242, status.PID)
+	assert.Equal(t, "/opt/mup/bin/supervisord ctl -c /opt/mup/supervisor/supervisor.ini -s http://localhost:19123 restart 'shard:*'",
+		mgr.CtlCommand("restart", "shard:*"))
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "/opt/mup/bin", shellQuote("/opt/mup/bin"))
+	assert.Equal(t, "'monitoring:*'", shellQuote("monitoring:*"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+	assert.Equal(t, "''", shellQuote(""))
+}
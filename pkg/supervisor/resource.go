@@ -0,0 +1,370 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// CgroupMode is how resource limits are applied to supervised processes
+type CgroupMode string
+
+const (
+	// CgroupSystemd wraps each program in a transient systemd scope (systemd-run --scope)
+	CgroupSystemd CgroupMode = "systemd"
+	// CgroupSystemdUser is CgroupSystemd through an unprivileged user's systemd manager
+	CgroupSystemdUser CgroupMode = "systemd-user"
+	// CgroupFS creates a cgroup v2 group directly through /sys/fs/cgroup
+	CgroupFS CgroupMode = "cgroupfs"
+	// CgroupNone means limits cannot be enforced on this host
+	CgroupNone CgroupMode = "none"
+)
+
+// cgroupRoot is the cgroup v2 unified hierarchy mount point
+const cgroupRoot = "/sys/fs/cgroup"
+
+// CgexecScriptName is the wrapper used in cgroupfs mode
+const CgexecScriptName = "mup-cgexec"
+
+// memoryPageSize is the granularity the kernel stores memory.max in
+const memoryPageSize = 4096
+
+// cpuPeriodUs is the cpu.max period used when translating CPU percentages
+const cpuPeriodUs = 100000
+
+// ResourceLimits are the per-process limits from a topology's resource_control
+type ResourceLimits struct {
+	MemoryMax  int64  `json:"memory_max,omitempty"`  // Bytes; 0 means unlimited
+	CPUPercent int    `json:"cpu_percent,omitempty"` // Percent of one CPU (200 = two cores); 0 means unlimited
+	IOWeight   uint64 `json:"io_weight,omitempty"`
+	// SystemdProperties are extra unit properties from systemd_config, only
+	// applied in systemd mode
+	SystemdProperties map[string]string `json:"systemd_properties,omitempty"`
+}
+
+// ParseResourceLimits validates a topology's resource_control and
+// systemd_config. It returns nil if neither sets anything.
+func ParseResourceLimits(rc *topology.ResourceControl, systemdConfig map[string]string) (*ResourceLimits, error) {
+	for key := range systemdConfig {
+		if !systemdPropertyName.MatchString(key) {
+			return nil, fmt.Errorf("invalid systemd_config key %q: must be a unit property name such as LimitNOFILE", key)
+		}
+	}
+	limits := &ResourceLimits{SystemdProperties: systemdConfig}
+
+	if rc != nil {
+		if rc.MemoryLimit != "" {
+			bytes, err := parseMemory(rc.MemoryLimit)
+			if err != nil {
+				return nil, fmt.Errorf("invalid resource_control.memory_limit: %w", err)
+			}
+			limits.MemoryMax = bytes
+		}
+		if rc.CPUQuota != "" {
+			percent, err := parseCPUQuota(rc.CPUQuota)
+			if err != nil {
+				return nil, fmt.Errorf("invalid resource_control.cpu_quota: %w", err)
+			}
+			limits.CPUPercent = percent
+		}
+		if rc.IOWeight != 0 {
+			if rc.IOWeight > 10000 {
+				return nil, fmt.Errorf("invalid resource_control.io_weight: %d (must be 1-10000)", rc.IOWeight)
+			}
+			limits.IOWeight = rc.IOWeight
+		}
+	}
+
+	if limits.IsZero() {
+		return nil, nil
+	}
+	return limits, nil
+}
+
+// IsZero reports whether no limit is set
+func (l *ResourceLimits) IsZero() bool {
+	return l == nil || (l.MemoryMax == 0 && l.CPUPercent == 0 && l.IOWeight == 0 && len(l.SystemdProperties) == 0)
+}
+
+// String returns a compact summary such as "memory=4GiB cpu=200% io_weight=500"
+func (l *ResourceLimits) String() string {
+	if l == nil {
+		return "unlimited"
+	}
+	var parts []string
+	if l.MemoryMax > 0 {
+		parts = append(parts, "memory="+formatMemory(l.MemoryMax))
+	}
+	if l.CPUPercent > 0 {
+		parts = append(parts, fmt.Sprintf("cpu=%d%%", l.CPUPercent))
+	}
+	if l.IOWeight > 0 {
+		parts = append(parts, fmt.Sprintf("io_weight=%d", l.IOWeight))
+	}
+	if len(parts) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Satisfies reports whether effective limits match every limit set in l.
+// The kernel rounds memory.max down to a whole page, so memory is compared
+// after the same rounding.
+func (l *ResourceLimits) Satisfies(effective *ResourceLimits) bool {
+	if l == nil {
+		return true
+	}
+	if effective == nil {
+		return l.MemoryMax == 0 && l.CPUPercent == 0 && l.IOWeight == 0
+	}
+	return (l.MemoryMax == 0 || l.MemoryMax/memoryPageSize == effective.MemoryMax/memoryPageSize) &&
+		(l.CPUPercent == 0 || l.CPUPercent == effective.CPUPercent) &&
+		(l.IOWeight == 0 || l.IOWeight == effective.IOWeight)
+}
+
+// WrapCommand returns command wrapped so it runs under the limits.
+// unit names the systemd scope or cgroup; cgexecPath is the wrapper
+// script used in cgroupfs mode.
+func (l *ResourceLimits) WrapCommand(mode CgroupMode, unit, cgexecPath, command string) string {
+	if l.IsZero() {
+		return command
+	}
+
+	switch mode {
+	case CgroupSystemd, CgroupSystemdUser:
+		args := []string{"systemd-run"}
+		if mode == CgroupSystemdUser {
+			args = append(args, "--user")
+		}
+		args = append(args, "--scope", "--quiet", "--collect", "--unit="+unit)
+		if l.MemoryMax > 0 {
+			args = append(args, "-p", fmt.Sprintf("MemoryMax=%d", l.MemoryMax))
+		}
+		if l.CPUPercent > 0 {
+			args = append(args, "-p", fmt.Sprintf("CPUQuota=%d%%", l.CPUPercent))
+		}
+		if l.IOWeight > 0 {
+			args = append(args, "-p", fmt.Sprintf("IOWeight=%d", l.IOWeight))
+		}
+		keys := make([]string, 0, len(l.SystemdProperties))
+		for key := range l.SystemdProperties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			args = append(args, "-p", shellQuote(key+"="+l.SystemdProperties[key]))
+		}
+		return strings.Join(args, " ") + " " + command
+
+	case CgroupFS:
+		memory, cpu, io := "-", "-", "-"
+		if l.MemoryMax > 0 {
+			memory = strconv.FormatInt(l.MemoryMax, 10)
+		}
+		if l.CPUPercent > 0 {
+			cpu = fmt.Sprintf("%d", l.CPUPercent*cpuPeriodUs/100)
+		}
+		if l.IOWeight > 0 {
+			io = strconv.FormatUint(l.IOWeight, 10)
+		}
+		return fmt.Sprintf("%s mup/%s %s %s %s %s", cgexecPath, unit, memory, cpu, io, command)
+
+	default:
+		return command
+	}
+}
+
+// CgroupProbeCommand prints the cgroup mode of the host it runs on, in the
+// order DetectCgroupModeContext prefers them
+const CgroupProbeCommand = `if [ ! -e /sys/fs/cgroup/cgroup.controllers ]; then echo none; ` +
+	`elif command -v systemd-run >/dev/null 2>&1 && [ "$(id -u)" = 0 ]; then echo systemd; ` +
+	`elif command -v systemd-run >/dev/null 2>&1 && [ -e "/run/user/$(id -u)/systemd" ]; then echo systemd-user; ` +
+	`elif [ -w /sys/fs/cgroup ]; then echo cgroupfs; ` +
+	`else echo none; fi`
+
+// DetectCgroupModeContext picks how limits can be enforced on the executor's
+// host: systemd scopes when systemd-run and cgroup v2 are available (through
+// the user manager when not root), direct cgroupfs writes when the hierarchy
+// is writable, and none otherwise or when the host cannot be probed.
+func DetectCgroupModeContext(ctx context.Context, exec executor.Executor) CgroupMode {
+	out, err := exec.ExecuteContext(ctx, CgroupProbeCommand)
+	if err != nil {
+		return CgroupNone
+	}
+	switch mode := CgroupMode(strings.TrimSpace(out)); mode {
+	case CgroupSystemd, CgroupSystemdUser, CgroupFS:
+		return mode
+	default:
+		return CgroupNone
+	}
+}
+
+// DetectCgroupMode picks how limits can be enforced on the local host
+func DetectCgroupMode() CgroupMode {
+	return DetectCgroupModeContext(context.Background(), executor.NewLocalExecutor())
+}
+
+// invalidUnitChars matches characters systemd does not allow in unit names
+var invalidUnitChars = regexp.MustCompile(`[^A-Za-z0-9:_.\-]`)
+
+// systemdPropertyName matches unit property names such as LimitNOFILE
+var systemdPropertyName = regexp.MustCompile(`^[A-Za-z]+$`)
+
+// ScopeName returns the systemd scope / cgroup name for a cluster program
+func ScopeName(clusterName, programName string) string {
+	return invalidUnitChars.ReplaceAllString(fmt.Sprintf("mup-%s-%s", clusterName, programName), "_")
+}
+
+// cgexecScript creates a cgroup v2 group, applies the limits and execs the command.
+// Usage: mup-cgexec <group> <memory.max|-> <cpu quota us|-> <io.weight|-> <command...>
+const cgexecScript = `#!/bin/sh
+# Auto-generated by mup: run a command inside a cgroup v2 group with resource limits
+set -e
+
+root=/sys/fs/cgroup
+group="$1"; memory="$2"; cpu="$3"; io="$4"
+shift 4
+
+dir="$root"
+for part in $(echo "$group" | tr '/' ' '); do
+    for controller in memory cpu io; do
+        echo "+$controller" > "$dir/cgroup.subtree_control" 2>/dev/null || true
+    done
+    dir="$dir/$part"
+    mkdir -p "$dir"
+done
+
+if [ "$memory" != "-" ]; then echo "$memory" > "$dir/memory.max"; fi
+if [ "$cpu" != "-" ]; then echo "$cpu 100000" > "$dir/cpu.max"; fi
+if [ "$io" != "-" ]; then echo "default $io" > "$dir/io.weight"; fi
+
+echo $$ > "$dir/cgroup.procs"
+exec "$@"
+`
+
+// ReadEffectiveLimits reads the cgroup v2 limits currently applied to pid on
+// the executor's host. Limits the kernel reports as "max" are returned as 0.
+func ReadEffectiveLimits(ctx context.Context, exec executor.Executor, pid int) (*ResourceLimits, error) {
+	out, err := exec.ExecuteContext(ctx, fmt.Sprintf("cat /proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cgroup of pid %d: %w", pid, err)
+	}
+
+	var group string
+	for _, line := range strings.Split(out, "\n") {
+		if path, ok := strings.CutPrefix(strings.TrimSpace(line), "0::"); ok {
+			group = path
+			break
+		}
+	}
+	if group == "" {
+		return nil, fmt.Errorf("pid %d is not in a cgroup v2 hierarchy", pid)
+	}
+
+	dir := filepath.Join(cgroupRoot, group)
+	read := func(name string) string {
+		value, err := exec.ExecuteContext(ctx, fmt.Sprintf("cat %s 2>/dev/null", shellQuote(filepath.Join(dir, name))))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(value)
+	}
+
+	return parseCgroupLimits(read("memory.max"), read("cpu.max"), read("io.weight")), nil
+}
+
+// parseCgroupLimits converts cgroup v2 interface files into ResourceLimits
+func parseCgroupLimits(memoryMax, cpuMax, ioWeight string) *ResourceLimits {
+	limits := &ResourceLimits{}
+
+	if n, err := strconv.ParseInt(memoryMax, 10, 64); err == nil {
+		limits.MemoryMax = n
+	}
+
+	// cpu.max is "<quota> <period>" or "max <period>"
+	if fields := strings.Fields(cpuMax); len(fields) == 2 {
+		quota, errQ := strconv.Atoi(fields[0])
+		period, errP := strconv.Atoi(fields[1])
+		if errQ == nil && errP == nil && period > 0 {
+			limits.CPUPercent = quota * 100 / period
+		}
+	}
+
+	// io.weight is "default <n>" followed by optional per-device lines
+	if fields := strings.Fields(ioWeight); len(fields) >= 2 && fields[0] == "default" {
+		if n, err := strconv.ParseUint(fields[1], 10, 64); err == nil && n != 100 {
+			limits.IOWeight = n
+		}
+	}
+
+	return limits
+}
+
+// parseMemory parses sizes like "512M", "4G", "1.5GiB" or a byte count
+func parseMemory(s string) (int64, error) {
+	value := strings.TrimSpace(s)
+	upper := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(value), "IB"), "B")
+
+	multiplier := int64(1)
+	if upper != "" {
+		switch upper[len(upper)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			upper = upper[:len(upper)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a size (examples: 512M, 4G)", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// parseCPUQuota parses a systemd-style CPU quota such as "200%"
+func parseCPUQuota(s string) (int, error) {
+	value := strings.TrimSpace(s)
+	number, ok := strings.CutSuffix(value, "%")
+	if !ok {
+		return 0, fmt.Errorf("%q must be a percentage such as 150%%", s)
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q must be a positive percentage", s)
+	}
+	return n, nil
+}
+
+// formatMemory renders a byte count with the largest whole binary unit
+func formatMemory(bytes int64) string {
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}}
+	for _, u := range units {
+		if bytes >= u.size && bytes%u.size == 0 {
+			return fmt.Sprintf("%d%s", bytes/u.size, u.suffix)
+		}
+	}
+	for _, u := range units {
+		if bytes >= u.size {
+			return fmt.Sprintf("%.1f%s", float64(bytes)/float64(u.size), u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", bytes)
+}
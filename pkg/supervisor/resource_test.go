@@ -0,0 +1,184 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/simulation"
+	"github.com/zph/mup/pkg/topology"
+)
+
+func TestParseResourceLimits(t *testing.T) {
+	limits, err := ParseResourceLimits(&topology.ResourceControl{
+		MemoryLimit: "4G",
+		CPUQuota:    "200%",
+		IOWeight:    500,
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4<<30), limits.MemoryMax)
+	assert.Equal(t, 200, limits.CPUPercent)
+	assert.Equal(t, uint64(500), limits.IOWeight)
+	assert.Equal(t, "memory=4GiB cpu=200% io_weight=500", limits.String())
+
+	// Nothing configured means no limits at all
+	limits, err = ParseResourceLimits(&topology.ResourceControl{}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, limits)
+	assert.Equal(t, "unlimited", limits.String())
+
+	_, err = ParseResourceLimits(&topology.ResourceControl{MemoryLimit: "lots"}, nil)
+	assert.ErrorContains(t, err, "resource_control.memory_limit")
+
+	_, err = ParseResourceLimits(&topology.ResourceControl{CPUQuota: "2"}, nil)
+	assert.ErrorContains(t, err, "resource_control.cpu_quota")
+
+	_, err = ParseResourceLimits(&topology.ResourceControl{IOWeight: 20000}, nil)
+	assert.ErrorContains(t, err, "resource_control.io_weight")
+
+	_, err = ParseResourceLimits(nil, map[string]string{"LimitNOFILE; rm -rf /": "1"})
+	assert.ErrorContains(t, err, `invalid systemd_config key "LimitNOFILE; rm -rf /"`)
+}
+
+func TestResourceLimits_WrapCommand(t *testing.T) {
+	limits := &ResourceLimits{
+		MemoryMax:         512 << 20,
+		CPUPercent:        150,
+		SystemdProperties: map[string]string{"LimitNOFILE": "64000", "Environment": "A=1 B=$(id)"},
+	}
+
+	systemd := limits.WrapCommand(CgroupSystemd, "mup-prod-mongod-27017", "", "/bin/mongod --config /c.conf")
+	assert.True(t, strings.HasPrefix(systemd, "systemd-run --scope --quiet --collect --unit=mup-prod-mongod-27017"))
+	assert.Contains(t, systemd, "-p MemoryMax=536870912 -p CPUQuota=150% -p 'Environment=A=1 B=$(id)' -p LimitNOFILE=64000")
+	assert.True(t, strings.HasSuffix(systemd, " /bin/mongod --config /c.conf"))
+
+	user := limits.WrapCommand(CgroupSystemdUser, "mup-prod-mongod-27017", "", "/bin/mongod")
+	assert.True(t, strings.HasPrefix(user, "systemd-run --user --scope "))
+
+	cgfs := limits.WrapCommand(CgroupFS, "mup-prod-mongod-27017", "/c/bin/mup-cgexec", "/bin/mongod")
+	assert.Equal(t, "/c/bin/mup-cgexec mup/mup-prod-mongod-27017 536870912 150000 - /bin/mongod", cgfs)
+
+	assert.Equal(t, "/bin/mongod", limits.WrapCommand(CgroupNone, "x", "", "/bin/mongod"))
+
+	var none *ResourceLimits
+	assert.Equal(t, "/bin/mongod", none.WrapCommand(CgroupSystemd, "x", "", "/bin/mongod"))
+}
+
+func TestParseCgroupLimits(t *testing.T) {
+	effective := parseCgroupLimits("536870912", "150000 100000", "default 500")
+	assert.Equal(t, int64(536870912), effective.MemoryMax)
+	assert.Equal(t, 150, effective.CPUPercent)
+	assert.Equal(t, uint64(500), effective.IOWeight)
+
+	unlimited := parseCgroupLimits("max", "max 100000", "default 100")
+	assert.Equal(t, "unlimited", unlimited.String())
+
+	configured := &ResourceLimits{MemoryMax: 536870912, CPUPercent: 150}
+	assert.True(t, configured.Satisfies(effective))
+	assert.False(t, configured.Satisfies(unlimited))
+
+	// memory.max reads back rounded down to a whole page
+	unaligned := &ResourceLimits{MemoryMax: 1181116006} // 1.1G
+	assert.True(t, unaligned.Satisfies(parseCgroupLimits("1181114368", "max 100000", "default 100")))
+	assert.False(t, unaligned.Satisfies(parseCgroupLimits("1181110272", "max 100000", "default 100")))
+}
+
+func TestDetectCgroupModeContext(t *testing.T) {
+	ctx := context.Background()
+	for output, want := range map[string]CgroupMode{
+		"systemd\n":      CgroupSystemd,
+		"systemd-user\n": CgroupSystemdUser,
+		"cgroupfs\n":     CgroupFS,
+		"none\n":         CgroupNone,
+		"":               CgroupNone,
+	} {
+		config := simulation.NewConfig()
+		config.SetResponse(CgroupProbeCommand, output)
+		assert.Equal(t, want, DetectCgroupModeContext(ctx, simulation.NewExecutor(config)), output)
+	}
+
+	// A host that cannot be probed cannot enforce limits
+	config := simulation.NewConfig()
+	config.SetFailure("execute", CgroupProbeCommand, "connection lost")
+	assert.Equal(t, CgroupNone, DetectCgroupModeContext(ctx, simulation.NewExecutor(config)))
+}
+
+func TestScopeName(t *testing.T) {
+	assert.Equal(t, "mup-my_cluster-mongod-27017", ScopeName("my_cluster", "mongod-27017"))
+	assert.Equal(t, "mup-a_b-mongos-27016", ScopeName("a b", "mongos-27016"))
+}
+
+func TestConfigGenerator_ResourceLimits(t *testing.T) {
+	versionDir := filepath.Join(t.TempDir(), "limits-cluster", "v7.0")
+	binPath := filepath.Join(versionDir, "bin")
+	require.NoError(t, os.MkdirAll(binPath, 0755))
+
+	topo := &topology.Topology{
+		Global: topology.GlobalConfig{
+			ResourceControl: &topology.ResourceControl{MemoryLimit: "1G"},
+		},
+		Mongod: []topology.MongodNode{
+			{Host: "localhost", Port: 27017, ReplicaSet: "rs0"},
+		},
+	}
+
+	gen := NewConfigGenerator(versionDir, "limits-cluster", topo, "7.0", binPath).WithCgroupMode(CgroupFS)
+	require.NoError(t, gen.GenerateAll())
+
+	content, err := os.ReadFile(filepath.Join(versionDir, "mongod-27017", "supervisor.conf"))
+	require.NoError(t, err)
+	conf := string(content)
+
+	assert.Contains(t, conf, "; Resource limits: memory=1GiB (enforced via cgroupfs)")
+	assert.Contains(t, conf, CgexecScriptName+" mup/mup-limits-cluster-mongod-27017 1073741824 - - ")
+
+	info, err := os.Stat(filepath.Join(versionDir, "bin", CgexecScriptName))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0111, "cgexec wrapper should be executable")
+}
+
+func TestConfigGenerator_HostCgroupModes(t *testing.T) {
+	versionDir := filepath.Join(t.TempDir(), "prod", "v7.0")
+	topo := &topology.Topology{
+		Global: topology.GlobalConfig{
+			ResourceControl: &topology.ResourceControl{CPUQuota: "100%"},
+		},
+		Mongod: []topology.MongodNode{
+			{Host: "db1", Port: 27017, ReplicaSet: "rs0"},
+			{Host: "db2", Port: 27018, ReplicaSet: "rs0"},
+			{Host: "db3", Port: 27019, ReplicaSet: "rs0"},
+		},
+	}
+
+	gen := NewConfigGenerator(versionDir, "prod", topo, "7.0", "/opt/mongodb/bin").
+		WithHostCgroupModes(map[string]CgroupMode{"db1": CgroupFS, "db2": CgroupSystemdUser}).
+		WithCgexecDir("/opt/mongodb/bin")
+	require.NoError(t, gen.GenerateAll())
+
+	read := func(port string) string {
+		content, err := os.ReadFile(filepath.Join(versionDir, "mongod-"+port, "supervisor.conf"))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	db1 := read("27017")
+	assert.Contains(t, db1, "command = /opt/mongodb/bin/mup-cgexec mup/mup-prod-mongod-27017 - 100000 - ")
+	assert.Contains(t, db1, "(enforced via cgroupfs)")
+
+	db2 := read("27018")
+	assert.Contains(t, db2, "command = systemd-run --user --scope ")
+	assert.Contains(t, db2, "(enforced via systemd-user)")
+
+	// Hosts without a detected mode run unlimited
+	db3 := read("27019")
+	assert.Contains(t, db3, "command = /opt/mongodb/bin/mongod --config ")
+	assert.Contains(t, db3, "(enforced via none)")
+
+	// The local copy is the source uploaded to cgroupfs hosts
+	_, err := os.Stat(filepath.Join(versionDir, "bin", CgexecScriptName))
+	assert.NoError(t, err)
+}
@@ -3,6 +3,7 @@ package template
 import (
 	"embed"
 	"fmt"
+	"strings"
 	"text/template"
 
 	"github.com/hashicorp/go-version"
@@ -45,6 +46,7 @@ func (m *Manager) GetTemplate(nodeType string, mongoVersion string) (*template.T
 		"supportsJournalEnabled": func() bool {
 			return m.supportsJournalEnabled(mongoVersion)
 		},
+		"yamlQuote": yamlQuote,
 	})
 
 	// Parse the template content
@@ -59,7 +61,7 @@ func (m *Manager) GetTemplate(nodeType string, mongoVersion string) (*template.T
 // supportsJournalEnabled checks if the MongoDB version supports storage.journal.enabled
 // This option was removed in MongoDB 6.1+
 func (m *Manager) supportsJournalEnabled(mongoVersion string) bool {
-	v, err := version.NewVersion(mongoVersion)
+	v, err := parseVersion(mongoVersion)
 	if err != nil {
 		// If version parsing fails, assume it's a newer version that doesn't support it
 		return false
@@ -76,7 +78,7 @@ func (m *Manager) supportsJournalEnabled(mongoVersion string) bool {
 
 // selectTemplateVersion maps MongoDB version to template version
 func (m *Manager) selectTemplateVersion(mongoVersion string) string {
-	v, err := version.NewVersion(mongoVersion)
+	v, err := parseVersion(mongoVersion)
 	if err != nil {
 		// Default to latest if version parsing fails
 		return "7.0"
@@ -107,3 +109,19 @@ func (m *Manager) selectTemplateVersion(mongoVersion string) string {
 	// Default to oldest supported version
 	return "3.6"
 }
+
+// parseVersion parses a MongoDB version. The release suffix of Percona
+// versions such as 7.0.5-4 is dropped: go-version reads it as a
+// pre-release, which no constraint without one matches.
+func parseVersion(mongoVersion string) (*version.Version, error) {
+	v, err := version.NewVersion(mongoVersion)
+	if err != nil {
+		return nil, err
+	}
+	return v.Core(), nil
+}
+
+// yamlQuote returns s as a single-quoted YAML scalar
+func yamlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
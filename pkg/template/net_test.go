@@ -0,0 +1,49 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender_IPv6BindIP(t *testing.T) {
+	mgr, err := NewManager()
+	require.NoError(t, err)
+
+	for _, version := range []string{"3.6", "4.2", "5.0", "7.0"} {
+		content, err := mgr.RenderMongod(version, MongodOptions{
+			Role:       "standalone",
+			ReplicaSet: "rs0",
+			Port:       27017,
+			BindIP:     "127.0.0.1,::1",
+			DataDir:    "/data",
+			LogDir:     "/logs",
+		})
+		require.NoError(t, err)
+		out := string(content)
+		assert.Contains(t, out, "bindIp: 127.0.0.1,::1", version)
+		assert.Contains(t, out, "ipv6: true", version)
+
+		content, err = mgr.RenderMongod(version, MongodOptions{
+			Role:       "standalone",
+			ReplicaSet: "rs0",
+			Port:       27017,
+			BindIP:     "127.0.0.1,10.0.0.5",
+			DataDir:    "/data",
+			LogDir:     "/logs",
+		})
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "ipv6:", version)
+
+		content, err = mgr.RenderMongos(version, MongosOptions{
+			Port:     27016,
+			BindIP:   "127.0.0.1,2001:db8::5",
+			LogDir:   "/logs",
+			ConfigDB: "configRS/[2001:db8::5]:27019",
+		})
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "ipv6: true", version)
+		assert.Contains(t, string(content), "configRS/[2001:db8::5]:27019", version)
+	}
+}
@@ -0,0 +1,89 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+)
+
+// perconaVariant is the variant name of Percona Server for MongoDB
+const perconaVariant = "percona"
+
+// PerconaOptions are a node's Percona Server for MongoDB features. They
+// travel in plan parameters, hence the json tags.
+type PerconaOptions struct {
+	// EncryptionKeyFile enables data-at-rest encryption with the key in
+	// this file on the node's host. mongos stores no data and ignores it.
+	EncryptionKeyFile    string `json:"encryption_key_file,omitempty"`
+	EncryptionCipherMode string `json:"encryption_cipher_mode,omitempty"`
+
+	// AuditLog is rendered as is; the planner fills in its defaults
+	AuditLog *AuditLogConfig `json:"audit_log,omitempty"`
+}
+
+// perconaFeature is a feature of Percona Server for MongoDB and the
+// versions that have it
+type perconaFeature struct {
+	name       string
+	constraint string
+}
+
+var (
+	featureEncryption = perconaFeature{"data-at-rest encryption", ">= 3.6.8"}
+	featureAuditLog   = perconaFeature{"audit log", ">= 3.6"}
+)
+
+// checkPercona fails when opts enables features that variant and
+// mongoVersion do not have, so the templates never render them for a
+// server that would refuse to start
+func checkPercona(variant, mongoVersion string, opts *PerconaOptions, mongos bool) error {
+	if opts == nil {
+		return nil
+	}
+	var features []perconaFeature
+	if opts.EncryptionKeyFile != "" && !mongos {
+		features = append(features, featureEncryption)
+	}
+	if opts.AuditLog != nil {
+		features = append(features, featureAuditLog)
+	}
+
+	for _, feature := range features {
+		if variant != perconaVariant {
+			return fmt.Errorf("%s requires Percona Server for MongoDB (variant percona)", feature.name)
+		}
+		v, err := parseVersion(mongoVersion)
+		if err != nil {
+			return fmt.Errorf("invalid MongoDB version %q: %w", mongoVersion, err)
+		}
+		constraint, err := version.NewConstraint(feature.constraint)
+		if err != nil {
+			return fmt.Errorf("invalid constraint for %s: %w", feature.name, err)
+		}
+		if !constraint.Check(v) {
+			return fmt.Errorf("%s is not supported by Percona Server for MongoDB %s (requires %s)", feature.name, mongoVersion, feature.constraint)
+		}
+	}
+	return nil
+}
+
+// applyPercona adds opts' encryption to security, creating it when needed,
+// and returns the auditLog section
+func applyPercona(security **SecurityConfig, opts *PerconaOptions) *AuditLogConfig {
+	if opts == nil {
+		return nil
+	}
+	if opts.EncryptionKeyFile != "" {
+		if *security == nil {
+			*security = &SecurityConfig{}
+		}
+		(*security).EnableEncryption = true
+		(*security).EncryptionKeyFile = opts.EncryptionKeyFile
+		(*security).EncryptionCipherMode = opts.EncryptionCipherMode
+	}
+	if opts.AuditLog == nil {
+		return nil
+	}
+	auditLog := *opts.AuditLog
+	return &auditLog
+}
@@ -0,0 +1,95 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRender_Percona(t *testing.T) {
+	mgr, err := NewManager()
+	require.NoError(t, err)
+
+	percona := &PerconaOptions{
+		EncryptionKeyFile:    "/opt/mongodb/secrets/encryption.key",
+		EncryptionCipherMode: "AES256-GCM",
+		AuditLog: &AuditLogConfig{
+			Destination: "file",
+			Format:      "JSON",
+			Path:        "/logs/auditLog.json",
+			Filter:      `{ atype: { $in: ['authenticate', 'dropDatabase'] } }`,
+		},
+	}
+
+	for _, version := range []string{"3.6.23-13", "4.2.25-25", "5.0.26-22", "7.0.5-4"} {
+		content, err := mgr.RenderMongod(version, MongodOptions{
+			Role:    "standalone",
+			Port:    27017,
+			BindIP:  "127.0.0.1",
+			DataDir: "/data",
+			LogDir:  "/logs",
+			Variant: "percona",
+			Percona: percona,
+		})
+		require.NoError(t, err, version)
+
+		var cfg map[string]any
+		require.NoError(t, yaml.Unmarshal(content, &cfg), version)
+		assert.Equal(t, map[string]any{
+			"enableEncryption":     true,
+			"encryptionKeyFile":    "/opt/mongodb/secrets/encryption.key",
+			"encryptionCipherMode": "AES256-GCM",
+		}, cfg["security"], "no authorization without a keyFile, %s", version)
+		assert.Equal(t, map[string]any{
+			"destination": "file",
+			"format":      "JSON",
+			"path":        "/logs/auditLog.json",
+			"filter":      percona.AuditLog.Filter,
+		}, cfg["auditLog"], version)
+
+		// mongos stores no data, only its audit log is configured
+		content, err = mgr.RenderMongos(version, MongosOptions{
+			Port:     27016,
+			BindIP:   "127.0.0.1",
+			LogDir:   "/logs",
+			ConfigDB: "configRS/cfg1:27019",
+			Variant:  "percona",
+			Percona:  percona,
+		})
+		require.NoError(t, err, version)
+		assert.Contains(t, string(content), "auditLog:", version)
+		assert.NotContains(t, string(content), "encryption", version)
+	}
+}
+
+func TestRender_PerconaSupport(t *testing.T) {
+	mgr, err := NewManager()
+	require.NoError(t, err)
+	opts := MongodOptions{Role: "standalone", Port: 27017, BindIP: "127.0.0.1", DataDir: "/data", LogDir: "/logs"}
+
+	opts.Variant = "mongo"
+	opts.Percona = &PerconaOptions{AuditLog: &AuditLogConfig{Destination: "syslog"}}
+	_, err = mgr.RenderMongod("7.0.5", opts)
+	assert.EqualError(t, err, "audit log requires Percona Server for MongoDB (variant percona)")
+
+	opts.Variant = "percona"
+	opts.Percona = &PerconaOptions{EncryptionKeyFile: "/k"}
+	_, err = mgr.RenderMongod("3.6.7-1.5", opts)
+	assert.EqualError(t, err, "data-at-rest encryption is not supported by Percona Server for MongoDB 3.6.7-1.5 (requires >= 3.6.8)")
+
+	content, err := mgr.RenderMongod("3.6.8-2.0", opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "enableEncryption: true")
+}
+
+func TestSelectTemplateVersion_Percona(t *testing.T) {
+	mgr, err := NewManager()
+	require.NoError(t, err)
+
+	assert.Equal(t, "7.0", mgr.selectTemplateVersion("7.0.5-4"), "the Percona release is not a pre-release")
+	assert.Equal(t, "4.2", mgr.selectTemplateVersion("4.4.29-28"))
+	assert.True(t, mgr.supportsJournalEnabled("5.0.26-22"))
+	assert.False(t, mgr.supportsJournalEnabled("7.0.5-4"))
+}
@@ -0,0 +1,227 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/zph/mup/pkg/naming"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// MongodOptions are the per-node values used to generate a mongod or config
+// server configuration
+type MongodOptions struct {
+	Role          string // "configsvr", "shardsvr" or "standalone"
+	ReplicaSet    string
+	Port          int
+	BindIP        string
+	DataDir       string
+	LogDir        string
+	KeyFile       string         // Enables keyFile internal auth and authorization
+	TLS           *TLSOptions    // Enables TLS with certificates issued by mup
+	RuntimeConfig map[string]any // Topology runtime_config overrides
+
+	// Variant is "mongo" or "percona"; Percona requires "percona"
+	Variant string
+	Percona *PerconaOptions
+}
+
+// MongosOptions are the per-node values used to generate a mongos configuration
+type MongosOptions struct {
+	Port          int
+	BindIP        string
+	LogDir        string
+	ConfigDB      string
+	KeyFile       string         // Enables keyFile internal auth
+	TLS           *TLSOptions    // Enables TLS with certificates issued by mup
+	RuntimeConfig map[string]any // Topology runtime_config overrides
+
+	// Variant is "mongo" or "percona"; Percona requires "percona"
+	Variant string
+	Percona *PerconaOptions // Only the audit log applies to mongos
+}
+
+// TLSOptions are a node's TLS settings. They travel in plan parameters,
+// hence the json tags.
+type TLSOptions struct {
+	Mode               string `json:"mode"` // requireTLS, preferTLS or allowTLS
+	CertificateKeyFile string `json:"certificate_key_file"`
+	CAFile             string `json:"ca_file"`
+
+	// X509MemberAuth makes members authenticate with their certificates
+	X509MemberAuth bool `json:"x509_member_auth,omitempty"`
+}
+
+// newTLSConfig returns net.tls for opts. Clients are not issued
+// certificates, so nodes accept connections without one.
+func newTLSConfig(opts *TLSOptions) *TLSConfig {
+	if opts == nil {
+		return nil
+	}
+	return &TLSConfig{
+		Mode:                                opts.Mode,
+		CertificateKeyFile:                  opts.CertificateKeyFile,
+		CAFile:                              opts.CAFile,
+		AllowConnectionsWithoutCertificates: true,
+	}
+}
+
+// NewMongodConfig returns mup's default mongod configuration for a node.
+// runtime_config is not applied; see ApplyMongodRuntimeConfig.
+func NewMongodConfig(opts MongodOptions) *MongodConfig {
+	cfg := &MongodConfig{
+		Net: NetConfig{
+			Port:   opts.Port,
+			BindIP: opts.BindIP,
+			IPv6:   topology.BindsIPv6(opts.BindIP),
+			TLS:    newTLSConfig(opts.TLS),
+		},
+		Storage: StorageConfig{
+			DBPath: opts.DataDir,
+			Journal: JournalConfig{
+				Enabled: true,
+			},
+			Engine: "wiredTiger",
+			WiredTiger: &WiredTigerConfig{
+				EngineConfig: WiredTigerEngineConfig{
+					CacheSizeGB: 1.0,
+				},
+			},
+		},
+		SystemLog: SystemLogConfig{
+			Destination: "file",
+			Path:        filepath.Join(opts.LogDir, naming.GetLogFileName()),
+			LogAppend:   true,
+		},
+		ProcessManagement: ProcessManagementConfig{
+			Fork:        false,
+			PIDFilePath: filepath.Join(opts.DataDir, naming.GetPIDFileName()),
+		},
+	}
+
+	if opts.ReplicaSet != "" {
+		cfg.Replication = &ReplicationConfig{
+			ReplSetName:               opts.ReplicaSet,
+			EnableMajorityReadConcern: true,
+		}
+	}
+
+	if opts.Role == "configsvr" || opts.Role == "shardsvr" {
+		cfg.Sharding = &ShardingConfig{
+			ClusterRole: opts.Role,
+		}
+	}
+
+	if opts.KeyFile != "" {
+		cfg.Security = &SecurityConfig{
+			Authorization: "enabled",
+			KeyFile:       opts.KeyFile,
+		}
+		if opts.TLS != nil && opts.TLS.X509MemberAuth {
+			cfg.Security.ClusterAuthMode = "x509"
+		}
+	}
+	cfg.AuditLog = applyPercona(&cfg.Security, opts.Percona)
+
+	return cfg
+}
+
+// NewMongosConfig returns mup's default mongos configuration for a node.
+// runtime_config is not applied; see ApplyMongosRuntimeConfig.
+func NewMongosConfig(opts MongosOptions) *MongosConfig {
+	cfg := &MongosConfig{
+		Net: NetConfig{
+			Port:   opts.Port,
+			BindIP: opts.BindIP,
+			IPv6:   topology.BindsIPv6(opts.BindIP),
+			TLS:    newTLSConfig(opts.TLS),
+		},
+		SystemLog: SystemLogConfig{
+			Destination: "file",
+			Path:        filepath.Join(opts.LogDir, naming.GetLogFileName()),
+			LogAppend:   true,
+		},
+		// ProcessManagement is nil (omitted) for mongos 3.6 compatibility
+		Sharding: MongosShardingConfig{
+			ConfigDB: opts.ConfigDB,
+		},
+	}
+
+	// mongos has no security.authorization; the keyFile alone makes it
+	// require authentication
+	if opts.KeyFile != "" {
+		cfg.Security = &SecurityConfig{KeyFile: opts.KeyFile}
+		if opts.TLS != nil && opts.TLS.X509MemberAuth {
+			cfg.Security.ClusterAuthMode = "x509"
+		}
+	}
+	if opts.Percona != nil && opts.Percona.AuditLog != nil {
+		auditLog := *opts.Percona.AuditLog
+		cfg.AuditLog = &auditLog
+	}
+
+	return cfg
+}
+
+// RenderMongod builds a mongod or config server configuration, applies the
+// node's runtime_config and renders it with the template for mongoVersion.
+// It fails when opts enables Percona features the server lacks.
+func (m *Manager) RenderMongod(mongoVersion string, opts MongodOptions) ([]byte, error) {
+	if err := checkPercona(opts.Variant, mongoVersion, opts.Percona, false); err != nil {
+		return nil, err
+	}
+	cfg := NewMongodConfig(opts)
+	if err := ApplyMongodRuntimeConfig(cfg, opts.RuntimeConfig, mongoVersion); err != nil {
+		return nil, err
+	}
+
+	m.applyTLSNaming(&cfg.Net, mongoVersion)
+
+	// Config servers use "config" templates, everything else uses "mongod"
+	templateType := "mongod"
+	if opts.Role == "configsvr" {
+		templateType = "config"
+	}
+	return m.Render(templateType, mongoVersion, cfg)
+}
+
+// RenderMongos builds a mongos configuration, applies the node's
+// runtime_config and renders it with the template for mongoVersion. It
+// fails when opts enables Percona features the server lacks.
+func (m *Manager) RenderMongos(mongoVersion string, opts MongosOptions) ([]byte, error) {
+	if err := checkPercona(opts.Variant, mongoVersion, opts.Percona, true); err != nil {
+		return nil, err
+	}
+	cfg := NewMongosConfig(opts)
+	if err := ApplyMongosRuntimeConfig(cfg, opts.RuntimeConfig, mongoVersion); err != nil {
+		return nil, err
+	}
+	m.applyTLSNaming(&cfg.Net, mongoVersion)
+	return m.Render("mongos", mongoVersion, cfg)
+}
+
+// applyTLSNaming switches net.tls to the net.ssl names of versions before
+// 4.2, whose templates render an ssl section with modes such as requireSSL
+func (m *Manager) applyTLSNaming(net *NetConfig, mongoVersion string) {
+	if net.TLS == nil || m.selectTemplateVersion(mongoVersion) != "3.6" {
+		return
+	}
+	net.TLS.UseSSLNaming = true
+	net.TLS.Mode = strings.Replace(net.TLS.Mode, "TLS", "SSL", 1)
+}
+
+// Render executes the template for nodeType and mongoVersion with data
+func (m *Manager) Render(nodeType, mongoVersion string, data any) ([]byte, error) {
+	tmpl, err := m.GetTemplate(nodeType, mongoVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,367 @@
+package template
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// setParameterPrefix marks runtime_config keys passed through to setParameter
+const setParameterPrefix = "setParameter."
+
+// runtimeOption maps one runtime_config key onto the generated config.
+// A nil setter means the key does not apply to that process type and is
+// skipped, so a single global runtime_config can be shared by every node.
+type runtimeOption struct {
+	// constraint limits the MongoDB versions that accept the key; empty means all
+	constraint string
+	// check validates the value against the target version (optional)
+	check  func(value any, v *version.Version) error
+	mongod func(cfg *MongodConfig, value any) error
+	mongos func(cfg *MongosConfig, value any) error
+}
+
+var runtimeOptions = map[string]runtimeOption{
+	"net.maxIncomingConnections": {
+		mongod: func(cfg *MongodConfig, value any) error { return setInt(&cfg.Net.MaxIncomingConnections, value) },
+		mongos: func(cfg *MongosConfig, value any) error { return setInt(&cfg.Net.MaxIncomingConnections, value) },
+	},
+	"storage.engine": {
+		mongod: func(cfg *MongodConfig, value any) error {
+			return setEnum(&cfg.Storage.Engine, value, "wiredTiger", "inMemory")
+		},
+	},
+	"storage.directoryPerDB": {
+		mongod: func(cfg *MongodConfig, value any) error { return setBool(&cfg.Storage.DirectoryPerDB, value) },
+	},
+	"storage.journal.enabled": {
+		// Removed in 6.1, journaling is always on
+		constraint: "< 6.1",
+		mongod:     func(cfg *MongodConfig, value any) error { return setBool(&cfg.Storage.Journal.Enabled, value) },
+	},
+	"storage.wiredTiger.engineConfig.cacheSizeGB": {
+		mongod: func(cfg *MongodConfig, value any) error {
+			f, err := toFloat(value)
+			if err != nil {
+				return err
+			}
+			if f <= 0 {
+				return fmt.Errorf("must be greater than 0, got %v", value)
+			}
+			wiredTiger(cfg).EngineConfig.CacheSizeGB = f
+			return nil
+		},
+	},
+	"storage.wiredTiger.collectionConfig.blockCompressor": {
+		check: func(value any, v *version.Version) error {
+			if value == "zstd" && v.LessThan(version.Must(version.NewVersion("4.2"))) {
+				return fmt.Errorf("zstd requires MongoDB >= 4.2")
+			}
+			return nil
+		},
+		mongod: func(cfg *MongodConfig, value any) error {
+			return setEnum(&wiredTiger(cfg).CollectionConfig.BlockCompressor, value, "snappy", "zlib", "zstd", "none")
+		},
+	},
+	"storage.wiredTiger.indexConfig.prefixCompression": {
+		mongod: func(cfg *MongodConfig, value any) error {
+			b, err := toBool(value)
+			if err != nil {
+				return err
+			}
+			wiredTiger(cfg).IndexConfig.PrefixCompression = &b
+			return nil
+		},
+	},
+	"systemLog.timeStampFormat": {
+		mongod: func(cfg *MongodConfig, value any) error {
+			return setEnum(&cfg.SystemLog.TimeStampFormat, value, "iso8601-utc", "iso8601-local")
+		},
+		mongos: func(cfg *MongosConfig, value any) error {
+			return setEnum(&cfg.SystemLog.TimeStampFormat, value, "iso8601-utc", "iso8601-local")
+		},
+	},
+	"operationProfiling.mode": {
+		mongod: func(cfg *MongodConfig, value any) error {
+			return setEnum(&profiling(cfg).Mode, value, "off", "slowOp", "all")
+		},
+	},
+	"operationProfiling.slowOpThresholdMs": {
+		mongod: func(cfg *MongodConfig, value any) error { return setInt(&profiling(cfg).SlowOpThresholdMs, value) },
+	},
+	"replication.oplogSizeMB": {
+		mongod: func(cfg *MongodConfig, value any) error {
+			if cfg.Replication == nil {
+				return nil
+			}
+			return setInt(&cfg.Replication.OplogSizeMB, value)
+		},
+	},
+	"replication.enableMajorityReadConcern": {
+		check: func(value any, v *version.Version) error {
+			if b, err := toBool(value); err == nil && !b && !v.LessThan(version.Must(version.NewVersion("5.0"))) {
+				return fmt.Errorf("cannot be disabled on MongoDB >= 5.0")
+			}
+			return nil
+		},
+		mongod: func(cfg *MongodConfig, value any) error {
+			if cfg.Replication == nil {
+				return nil
+			}
+			return setBool(&cfg.Replication.EnableMajorityReadConcern, value)
+		},
+	},
+}
+
+// RuntimeConfigKeys returns the supported runtime_config keys, sorted.
+// Any key under "setParameter." is also accepted.
+func RuntimeConfigKeys() []string {
+	keys := make([]string, 0, len(runtimeOptions))
+	for key := range runtimeOptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ApplyMongodRuntimeConfig overlays a topology runtime_config onto a mongod
+// (or config server) configuration. Keys may be dotted ("storage.directoryPerDB")
+// or nested maps. Unknown keys and keys the target version does not support
+// are rejected.
+func ApplyMongodRuntimeConfig(cfg *MongodConfig, runtimeConfig map[string]any, mongoVersion string) error {
+	return applyRuntimeConfig(runtimeConfig, mongoVersion, func(opt runtimeOption, value any) error {
+		if opt.mongod == nil {
+			return nil
+		}
+		return opt.mongod(cfg, value)
+	}, func(name string, value any) {
+		if cfg.SetParameter == nil {
+			cfg.SetParameter = make(map[string]interface{})
+		}
+		cfg.SetParameter[name] = value
+	})
+}
+
+// ApplyMongosRuntimeConfig overlays a topology runtime_config onto a mongos
+// configuration. Storage, replication and profiling keys do not apply to
+// mongos and are ignored.
+func ApplyMongosRuntimeConfig(cfg *MongosConfig, runtimeConfig map[string]any, mongoVersion string) error {
+	return applyRuntimeConfig(runtimeConfig, mongoVersion, func(opt runtimeOption, value any) error {
+		if opt.mongos == nil {
+			return nil
+		}
+		return opt.mongos(cfg, value)
+	}, func(name string, value any) {
+		if cfg.SetParameter == nil {
+			cfg.SetParameter = make(map[string]interface{})
+		}
+		cfg.SetParameter[name] = value
+	})
+}
+
+// applyRuntimeConfig validates every key against the target version and hands
+// known keys to set, and setParameter entries to setParam
+func applyRuntimeConfig(runtimeConfig map[string]any, mongoVersion string, set func(opt runtimeOption, value any) error, setParam func(name string, value any)) error {
+	if len(runtimeConfig) == 0 {
+		return nil
+	}
+
+	v, err := parseVersion(mongoVersion)
+	if err != nil {
+		return fmt.Errorf("invalid MongoDB version %q: %w", mongoVersion, err)
+	}
+
+	flat := make(map[string]any)
+	flattenRuntimeConfig("", runtimeConfig, flat)
+
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := flat[key]
+
+		if name, ok := strings.CutPrefix(key, setParameterPrefix); ok {
+			if name == "" {
+				return fmt.Errorf("runtime_config key %q: missing parameter name", key)
+			}
+			setParam(name, normalizeScalar(value))
+			continue
+		}
+
+		opt, ok := runtimeOptions[key]
+		if !ok {
+			return fmt.Errorf("unknown runtime_config key %q", key)
+		}
+		if opt.constraint != "" {
+			constraint, err := version.NewConstraint(opt.constraint)
+			if err != nil {
+				return fmt.Errorf("invalid constraint for %q: %w", key, err)
+			}
+			if !constraint.Check(v) {
+				return fmt.Errorf("runtime_config key %q is not supported by MongoDB %s (requires %s)", key, mongoVersion, opt.constraint)
+			}
+		}
+		if opt.check != nil {
+			if err := opt.check(value, v); err != nil {
+				return fmt.Errorf("runtime_config key %q: %w", key, err)
+			}
+		}
+		if err := set(opt, value); err != nil {
+			return fmt.Errorf("runtime_config key %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// flattenRuntimeConfig converts nested maps into dotted keys. Values under
+// setParameter are kept whole since parameter names are opaque to mup.
+func flattenRuntimeConfig(prefix string, in map[string]any, out map[string]any) {
+	for key, value := range in {
+		full := key
+		if prefix != "" {
+			full = prefix + "." + key
+		}
+
+		nested, isMap := value.(map[string]any)
+		if !isMap {
+			out[full] = value
+			continue
+		}
+		if full == strings.TrimSuffix(setParameterPrefix, ".") {
+			for name, param := range nested {
+				out[setParameterPrefix+name] = param
+			}
+			continue
+		}
+		flattenRuntimeConfig(full, nested, out)
+	}
+}
+
+// wiredTiger returns the config's WiredTiger section, creating it if needed
+func wiredTiger(cfg *MongodConfig) *WiredTigerConfig {
+	if cfg.Storage.WiredTiger == nil {
+		cfg.Storage.WiredTiger = &WiredTigerConfig{}
+	}
+	return cfg.Storage.WiredTiger
+}
+
+// profiling returns the config's operationProfiling section, creating it if needed
+func profiling(cfg *MongodConfig) *OperationProfilingConfig {
+	if cfg.OperationProfiling == nil {
+		cfg.OperationProfiling = &OperationProfilingConfig{Mode: "off"}
+	}
+	return cfg.OperationProfiling
+}
+
+func setInt(dst *int, value any) error {
+	n, err := toInt(value)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("must not be negative, got %d", n)
+	}
+	*dst = n
+	return nil
+}
+
+func setBool(dst *bool, value any) error {
+	b, err := toBool(value)
+	if err != nil {
+		return err
+	}
+	*dst = b
+	return nil
+}
+
+func setEnum(dst *string, value any, allowed ...string) error {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("expected a string, got %T", value)
+	}
+	for _, a := range allowed {
+		if s == a {
+			*dst = s
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q (must be one of: %s)", s, strings.Join(allowed, ", "))
+}
+
+// toInt accepts YAML ints and JSON numbers (float64) with no fractional part
+func toInt(value any) (int, error) {
+	switch n := value.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case uint64:
+		return int(n), nil
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, fmt.Errorf("expected an integer, got %v", n)
+		}
+		return int(n), nil
+	case string:
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, fmt.Errorf("expected an integer, got %q", n)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %T", value)
+	}
+}
+
+func toFloat(value any) (float64, error) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected a number, got %q", n)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", value)
+	}
+}
+
+func toBool(value any) (bool, error) {
+	switch b := value.(type) {
+	case bool:
+		return b, nil
+	case string:
+		parsed, err := strconv.ParseBool(b)
+		if err != nil {
+			return false, fmt.Errorf("expected a boolean, got %q", b)
+		}
+		return parsed, nil
+	default:
+		return false, fmt.Errorf("expected a boolean, got %T", value)
+	}
+}
+
+// normalizeScalar turns whole-number floats (from JSON plans) back into
+// integers so setParameter values render as 1000000 rather than 1e+06
+func normalizeScalar(value any) any {
+	if f, ok := value.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return value
+}
@@ -0,0 +1,152 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderMongod_RuntimeConfig(t *testing.T) {
+	mgr, err := NewManager()
+	require.NoError(t, err)
+
+	content, err := mgr.RenderMongod("7.0.5", MongodOptions{
+		Role:       "shardsvr",
+		ReplicaSet: "rs0",
+		Port:       27017,
+		BindIP:     "127.0.0.1",
+		DataDir:    "/data/27017",
+		LogDir:     "/logs/27017",
+		RuntimeConfig: map[string]any{
+			// Nested and dotted keys are equivalent
+			"storage": map[string]any{
+				"wiredTiger": map[string]any{
+					"engineConfig": map[string]any{"cacheSizeGB": 4},
+				},
+			},
+			"storage.wiredTiger.collectionConfig.blockCompressor": "zstd",
+			"net.maxIncomingConnections":                          2000,
+			"operationProfiling.slowOpThresholdMs":                200,
+			"setParameter": map[string]any{
+				"transactionLifetimeLimitSeconds": float64(1000000),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	out := string(content)
+	assert.Contains(t, out, "cacheSizeGB: 4\n")
+	assert.Contains(t, out, "blockCompressor: zstd")
+	assert.Contains(t, out, "maxIncomingConnections: 2000")
+	assert.Contains(t, out, "mode: off")
+	assert.Contains(t, out, "slowOpThresholdMs: 200")
+	assert.Contains(t, out, "transactionLifetimeLimitSeconds: 1000000")
+	assert.Contains(t, out, "replSetName: rs0")
+}
+
+func TestRenderMongod_DefaultsWithoutRuntimeConfig(t *testing.T) {
+	mgr, err := NewManager()
+	require.NoError(t, err)
+
+	content, err := mgr.RenderMongod("6.0", MongodOptions{
+		Role:       "standalone",
+		ReplicaSet: "rs0",
+		Port:       27017,
+		BindIP:     "127.0.0.1",
+		DataDir:    "/data",
+		LogDir:     "/logs",
+	})
+	require.NoError(t, err)
+
+	out := string(content)
+	assert.Contains(t, out, "cacheSizeGB: 1\n")
+	assert.Contains(t, out, "enableMajorityReadConcern: true")
+	assert.NotContains(t, out, "sharding:")
+	assert.NotContains(t, out, "setParameter:")
+}
+
+func TestApplyMongodRuntimeConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		config  map[string]any
+		wantErr string
+	}{
+		{
+			name:    "unknown key",
+			version: "7.0",
+			config:  map[string]any{"storage.wiredTiger.engineConfig.cacheSize": 2},
+			wantErr: `unknown runtime_config key "storage.wiredTiger.engineConfig.cacheSize"`,
+		},
+		{
+			name:    "journal removed in 6.1",
+			version: "7.0",
+			config:  map[string]any{"storage.journal.enabled": true},
+			wantErr: "not supported by MongoDB 7.0",
+		},
+		{
+			name:    "zstd before 4.2",
+			version: "3.6",
+			config:  map[string]any{"storage.wiredTiger.collectionConfig.blockCompressor": "zstd"},
+			wantErr: "zstd requires MongoDB >= 4.2",
+		},
+		{
+			name:    "majority read concern is mandatory from 5.0",
+			version: "5.0",
+			config:  map[string]any{"replication.enableMajorityReadConcern": false},
+			wantErr: "cannot be disabled",
+		},
+		{
+			name:    "invalid enum value",
+			version: "7.0",
+			config:  map[string]any{"operationProfiling.mode": "slow"},
+			wantErr: "must be one of: off, slowOp, all",
+		},
+		{
+			name:    "non-integer",
+			version: "7.0",
+			config:  map[string]any{"net.maxIncomingConnections": 1.5},
+			wantErr: "expected an integer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewMongodConfig(MongodOptions{ReplicaSet: "rs0"})
+			err := ApplyMongodRuntimeConfig(cfg, tt.config, tt.version)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestApplyMongosRuntimeConfig_SkipsMongodOnlyKeys(t *testing.T) {
+	cfg := NewMongosConfig(MongosOptions{Port: 27016})
+	err := ApplyMongosRuntimeConfig(cfg, map[string]any{
+		"storage.wiredTiger.engineConfig.cacheSizeGB":  2,
+		"net.maxIncomingConnections":                   500,
+		"setParameter.diagnosticDataCollectionEnabled": false,
+	}, "4.2")
+	require.NoError(t, err)
+
+	assert.Equal(t, 500, cfg.Net.MaxIncomingConnections)
+	assert.Equal(t, false, cfg.SetParameter["diagnosticDataCollectionEnabled"])
+}
+
+func TestRenderMongod_PrefixCompressionFalse(t *testing.T) {
+	mgr, err := NewManager()
+	require.NoError(t, err)
+
+	content, err := mgr.RenderMongod("4.2", MongodOptions{
+		Port:    27017,
+		BindIP:  "127.0.0.1",
+		DataDir: "/data",
+		LogDir:  "/logs",
+		RuntimeConfig: map[string]any{
+			"storage.wiredTiger.indexConfig.prefixCompression": false,
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "prefixCompression: false")
+}
@@ -0,0 +1,99 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender_KeyFile(t *testing.T) {
+	mgr, err := NewManager()
+	require.NoError(t, err)
+
+	for _, version := range []string{"3.6", "4.2", "5.0", "7.0"} {
+		content, err := mgr.RenderMongod(version, MongodOptions{
+			Role:       "shardsvr",
+			ReplicaSet: "rs0",
+			Port:       27017,
+			BindIP:     "127.0.0.1",
+			DataDir:    "/data",
+			LogDir:     "/logs",
+			KeyFile:    "/opt/mongodb/secrets/keyfile",
+		})
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "authorization: enabled", version)
+		assert.Contains(t, string(content), "keyFile: /opt/mongodb/secrets/keyfile", version)
+
+		// mongos enforces the config servers' users but rejects authorization
+		content, err = mgr.RenderMongos(version, MongosOptions{
+			Port:     27016,
+			BindIP:   "127.0.0.1",
+			LogDir:   "/logs",
+			ConfigDB: "configRS/cfg1:27019",
+			KeyFile:  "/opt/mongodb/secrets/keyfile",
+		})
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "keyFile: /opt/mongodb/secrets/keyfile", version)
+		assert.NotContains(t, string(content), "authorization", version)
+
+		content, err = mgr.RenderMongod(version, MongodOptions{
+			Role:    "standalone",
+			Port:    27017,
+			BindIP:  "127.0.0.1",
+			DataDir: "/data",
+			LogDir:  "/logs",
+		})
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "security:", version)
+	}
+}
+
+func TestRender_TLS(t *testing.T) {
+	mgr, err := NewManager()
+	require.NoError(t, err)
+
+	tls := &TLSOptions{
+		Mode:               "requireTLS",
+		CertificateKeyFile: "/opt/mongodb/secrets/tls/db1-27017.pem",
+		CAFile:             "/opt/mongodb/secrets/tls/ca.pem",
+		X509MemberAuth:     true,
+	}
+	for _, version := range []string{"3.6", "4.2", "5.0", "7.0"} {
+		content, err := mgr.RenderMongod(version, MongodOptions{
+			Role:       "shardsvr",
+			ReplicaSet: "rs0",
+			Port:       27017,
+			BindIP:     "127.0.0.1",
+			DataDir:    "/data",
+			LogDir:     "/logs",
+			KeyFile:    "/opt/mongodb/secrets/keyfile",
+			TLS:        tls,
+		})
+		require.NoError(t, err)
+		rendered := string(content)
+		assert.Contains(t, rendered, "CAFile: /opt/mongodb/secrets/tls/ca.pem", version)
+		assert.Contains(t, rendered, "allowConnectionsWithoutCertificates: true", version)
+		assert.Contains(t, rendered, "clusterAuthMode: x509", version)
+		if version == "3.6" {
+			assert.Contains(t, rendered, "mode: requireSSL", version)
+			assert.Contains(t, rendered, "PEMKeyFile: /opt/mongodb/secrets/tls/db1-27017.pem", version)
+		} else {
+			assert.Contains(t, rendered, "mode: requireTLS", version)
+			assert.Contains(t, rendered, "certificateKeyFile: /opt/mongodb/secrets/tls/db1-27017.pem", version)
+		}
+
+		content, err = mgr.RenderMongos(version, MongosOptions{
+			Port:     27016,
+			BindIP:   "127.0.0.1",
+			LogDir:   "/logs",
+			ConfigDB: "configRS/cfg1:27019",
+			KeyFile:  "/opt/mongodb/secrets/keyfile",
+			TLS:      tls,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "allowConnectionsWithoutCertificates: true", version)
+		assert.Contains(t, string(content), "clusterAuthMode: x509", version)
+	}
+	assert.Equal(t, "requireTLS", tls.Mode, "options are not modified by ssl naming")
+}
@@ -26,6 +26,9 @@ type MongodConfig struct {
 	// OperationProfiling (optional)
 	OperationProfiling *OperationProfilingConfig `yaml:"operationProfiling,omitempty"`
 
+	// AuditLog (optional, Percona Server for MongoDB)
+	AuditLog *AuditLogConfig `yaml:"auditLog,omitempty"`
+
 	// SetParameter (optional, version-specific)
 	SetParameter map[string]interface{} `yaml:"setParameter,omitempty"`
 }
@@ -33,6 +36,7 @@ type MongodConfig struct {
 type NetConfig struct {
 	Port                   int    `yaml:"port,omitempty"`
 	BindIP                 string `yaml:"bindIp,omitempty"`
+	IPv6                   bool   `yaml:"ipv6,omitempty"` // Required to bind IPv6 addresses
 	MaxIncomingConnections int    `yaml:"maxIncomingConnections,omitempty"`
 
 	// TLS/SSL (version-dependent naming)
@@ -45,6 +49,9 @@ type TLSConfig struct {
 	CAFile                   string `yaml:"CAFile,omitempty"`
 	AllowInvalidCertificates bool   `yaml:"allowInvalidCertificates,omitempty"`
 
+	// Lets clients without a certificate connect, verifying only the server
+	AllowConnectionsWithoutCertificates bool `yaml:"allowConnectionsWithoutCertificates,omitempty"`
+
 	// Use "tls" or "ssl" based on version
 	UseSSLNaming bool // true for versions < 4.2
 }
@@ -78,7 +85,7 @@ type WiredTigerCollectionConfig struct {
 }
 
 type WiredTigerIndexConfig struct {
-	PrefixCompression bool `yaml:"prefixCompression,omitempty"`
+	PrefixCompression *bool `yaml:"prefixCompression,omitempty"` // nil keeps the server default
 }
 
 type SystemLogConfig struct {
@@ -108,6 +115,11 @@ type SecurityConfig struct {
 	KeyFile         string `yaml:"keyFile,omitempty"`
 	ClusterAuthMode string `yaml:"clusterAuthMode,omitempty"` // "keyFile", "x509"
 
+	// Data-at-rest encryption (optional, Percona Server for MongoDB)
+	EnableEncryption     bool   `yaml:"enableEncryption,omitempty"`
+	EncryptionKeyFile    string `yaml:"encryptionKeyFile,omitempty"`
+	EncryptionCipherMode string `yaml:"encryptionCipherMode,omitempty"` // "AES256-CBC", "AES256-GCM"
+
 	// LDAP (optional)
 	LDAP *LDAPConfig `yaml:"ldap,omitempty"`
 }
@@ -123,6 +135,15 @@ type BindConfig struct {
 	QueryPassword string `yaml:"queryPassword,omitempty"`
 }
 
+// AuditLogConfig is the auditLog section of Percona Server for MongoDB.
+// It travels in plan parameters, hence the json tags.
+type AuditLogConfig struct {
+	Destination string `yaml:"destination,omitempty" json:"destination"` // "file", "syslog" or "console"
+	Format      string `yaml:"format,omitempty" json:"format,omitempty"` // "JSON" or "BSON", file only
+	Path        string `yaml:"path,omitempty" json:"path,omitempty"`
+	Filter      string `yaml:"filter,omitempty" json:"filter,omitempty"` // Query document on audit events
+}
+
 type OperationProfilingConfig struct {
 	Mode              string `yaml:"mode,omitempty"` // "off", "slowOp", "all"
 	SlowOpThresholdMs int    `yaml:"slowOpThresholdMs,omitempty"`
@@ -135,6 +156,8 @@ type MongosConfig struct {
 	ProcessManagement *ProcessManagementConfig `yaml:"processManagement,omitempty"`
 	Sharding          MongosShardingConfig     `yaml:"sharding"`
 	Security          *SecurityConfig          `yaml:"security,omitempty"`
+	AuditLog          *AuditLogConfig          `yaml:"auditLog,omitempty"`
+	SetParameter      map[string]interface{}   `yaml:"setParameter,omitempty"`
 }
 
 type MongosShardingConfig struct {
@@ -0,0 +1,167 @@
+package topology
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// GetNodeID returns a unique identifier for a node: host:port, with IPv6
+// literals in brackets ([::1]:27017). It is also the address form MongoDB
+// uses in replica set configs and connection strings.
+func GetNodeID(host string, port int) string {
+	return net.JoinHostPort(TrimHost(host), strconv.Itoa(port))
+}
+
+// ParseNodeID parses a node ID such as db1:27017 or [::1]:27017 into host
+// and port. The host of an IPv6 node ID is returned without brackets.
+func ParseNodeID(nodeID string) (string, int, error) {
+	host, portText, err := net.SplitHostPort(nodeID)
+	if err != nil || host == "" {
+		return "", 0, fmt.Errorf("invalid node ID format: %s", nodeID)
+	}
+
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in node ID: %s", nodeID)
+	}
+
+	return host, port, nil
+}
+
+// TrimHost removes the brackets of an IPv6 literal written as [::1]
+func TrimHost(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// IsIPv6 reports whether host is an IPv6 literal, with or without brackets
+func IsIPv6(host string) bool {
+	host = TrimHost(host)
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host = host[:i] // Zone, as in fe80::1%eth0
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
+}
+
+// IsLocalHost reports whether host is a loopback alias
+func IsLocalHost(host string) bool {
+	switch TrimHost(host) {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// LocalBindIP is net.bindIp for local deployments: IPv4 and IPv6 loopback
+const LocalBindIP = "127.0.0.1,::1"
+
+// AdvertisedHost returns the host other members and clients use to reach
+// the node: advertise_host when set, otherwise host
+func (n MongodNode) AdvertisedHost() string {
+	return advertisedHost(n.Host, n.AdvertiseHost)
+}
+
+// Address returns the node's advertised host:port
+func (n MongodNode) Address() string {
+	return GetNodeID(n.AdvertisedHost(), n.Port)
+}
+
+// BindAddresses returns net.bindIp for the node, see bindAddresses
+func (n MongodNode) BindAddresses(local bool) string {
+	return bindAddresses(n.BindIP, n.Host, n.AdvertiseHost, local)
+}
+
+// AdvertisedHost returns the host clients use to reach the router
+func (n MongosNode) AdvertisedHost() string {
+	return advertisedHost(n.Host, n.AdvertiseHost)
+}
+
+// Address returns the router's advertised host:port
+func (n MongosNode) Address() string {
+	return GetNodeID(n.AdvertisedHost(), n.Port)
+}
+
+// BindAddresses returns net.bindIp for the router, see bindAddresses
+func (n MongosNode) BindAddresses(local bool) string {
+	return bindAddresses(n.BindIP, n.Host, n.AdvertiseHost, local)
+}
+
+// AdvertisedHost returns the host other members and routers use to reach
+// the config server
+func (n ConfigNode) AdvertisedHost() string {
+	return advertisedHost(n.Host, n.AdvertiseHost)
+}
+
+// Address returns the config server's advertised host:port
+func (n ConfigNode) Address() string {
+	return GetNodeID(n.AdvertisedHost(), n.Port)
+}
+
+// BindAddresses returns net.bindIp for the config server, see bindAddresses
+func (n ConfigNode) BindAddresses(local bool) string {
+	return bindAddresses(n.BindIP, n.Host, n.AdvertiseHost, local)
+}
+
+func advertisedHost(host, advertise string) string {
+	if advertise != "" {
+		return TrimHost(advertise)
+	}
+	return TrimHost(host)
+}
+
+// bindAddresses returns bind_ip when set. Otherwise local nodes listen on
+// loopback only and remote nodes on loopback plus their host and
+// advertise_host, so members reach each other on the advertised interface.
+func bindAddresses(bindIP, host, advertise string, local bool) string {
+	if bindIP != "" {
+		return bindIP
+	}
+	if local {
+		return LocalBindIP
+	}
+
+	addresses := []string{"127.0.0.1"}
+	seen := map[string]bool{"127.0.0.1": true}
+	for _, h := range []string{TrimHost(host), TrimHost(advertise)} {
+		if h == "" || seen[h] || IsLocalHost(h) {
+			continue
+		}
+		seen[h] = true
+		addresses = append(addresses, h)
+	}
+	return strings.Join(addresses, ",")
+}
+
+// BindsIPv6 reports whether a bindIp value lists an IPv6 address, which
+// requires net.ipv6
+func BindsIPv6(bindIP string) bool {
+	for _, address := range strings.Split(bindIP, ",") {
+		address = strings.TrimSpace(address)
+		if address == "::" || IsIPv6(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAddressing checks a node's host, advertise_host and bind_ip
+func validateAddressing(host, advertise, bindIP string) error {
+	for _, field := range []struct{ name, value string }{{"host", host}, {"advertise_host", advertise}} {
+		if strings.ContainsAny(field.value, " ,/") || (strings.Count(field.value, ":") == 1 && !IsIPv6(field.value)) {
+			return fmt.Errorf("%s %q must be a hostname or IP address without a port", field.name, field.value)
+		}
+	}
+	if bindIP != "" {
+		for _, address := range strings.Split(bindIP, ",") {
+			if strings.TrimSpace(address) == "" {
+				return fmt.Errorf("bind_ip %q has an empty address", bindIP)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetNodeID(t *testing.T) {
+	assert.Equal(t, "db1:27017", GetNodeID("db1", 27017))
+	assert.Equal(t, "10.0.0.5:27017", GetNodeID("10.0.0.5", 27017))
+	assert.Equal(t, "[::1]:27017", GetNodeID("::1", 27017))
+	assert.Equal(t, "[::1]:27017", GetNodeID("[::1]", 27017), "brackets are not doubled")
+	assert.Equal(t, "[fe80::1%eth0]:27017", GetNodeID("fe80::1%eth0", 27017))
+}
+
+func TestParseNodeID(t *testing.T) {
+	tests := []struct {
+		id   string
+		host string
+		port int
+	}{
+		{"db1:27017", "db1", 27017},
+		{"[::1]:27017", "::1", 27017},
+		{"[2001:db8::5]:30000", "2001:db8::5", 30000},
+	}
+	for _, tt := range tests {
+		host, port, err := ParseNodeID(tt.id)
+		require.NoError(t, err, tt.id)
+		assert.Equal(t, tt.host, host)
+		assert.Equal(t, tt.port, port)
+		assert.Equal(t, tt.id, GetNodeID(host, port), "round trip")
+	}
+
+	for _, id := range []string{"db1", "::1:27017", ":27017", "db1:port"} {
+		_, _, err := ParseNodeID(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func TestIsIPv6(t *testing.T) {
+	assert.True(t, IsIPv6("::1"))
+	assert.True(t, IsIPv6("[::1]"))
+	assert.True(t, IsIPv6("fe80::1%eth0"))
+	assert.False(t, IsIPv6("127.0.0.1"))
+	assert.False(t, IsIPv6("::ffff:10.0.0.1"), "IPv4-mapped addresses are IPv4")
+	assert.False(t, IsIPv6("db1"))
+}
+
+func TestNodeAddresses(t *testing.T) {
+	node := MongodNode{Host: "10.0.0.5", Port: 27017}
+	assert.Equal(t, "10.0.0.5:27017", node.Address())
+	assert.Equal(t, "127.0.0.1,10.0.0.5", node.BindAddresses(false))
+	assert.Equal(t, LocalBindIP, node.BindAddresses(true))
+
+	// A host with several interfaces: members reach each other on the
+	// advertised one, which must also be bound
+	node.AdvertiseHost = "192.168.1.5"
+	assert.Equal(t, "192.168.1.5:27017", node.Address())
+	assert.Equal(t, "127.0.0.1,10.0.0.5,192.168.1.5", node.BindAddresses(false))
+
+	node.BindIP = "0.0.0.0"
+	assert.Equal(t, "0.0.0.0", node.BindAddresses(false), "bind_ip wins")
+
+	v6 := ConfigNode{Host: "2001:db8::5", Port: 27019}
+	assert.Equal(t, "[2001:db8::5]:27019", v6.Address())
+	assert.Equal(t, "127.0.0.1,2001:db8::5", v6.BindAddresses(false))
+
+	mongos := MongosNode{Host: "localhost", AdvertiseHost: "app.example.com", Port: 27016}
+	assert.Equal(t, "app.example.com:27016", mongos.Address())
+	assert.Equal(t, "127.0.0.1,app.example.com", mongos.BindAddresses(false), "loopback aliases are not repeated")
+}
+
+func TestBindsIPv6(t *testing.T) {
+	assert.True(t, BindsIPv6(LocalBindIP))
+	assert.True(t, BindsIPv6("127.0.0.1, 2001:db8::5"))
+	assert.True(t, BindsIPv6("::"))
+	assert.False(t, BindsIPv6("127.0.0.1,10.0.0.5"))
+	assert.False(t, BindsIPv6("0.0.0.0"))
+}
+
+func TestValidateAddressing(t *testing.T) {
+	topo := &Topology{
+		Global: GlobalConfig{User: "mongo", DeployDir: "/opt/mongodb"},
+		Mongod: []MongodNode{
+			{Host: "[::1]", Port: 27017, ReplicaSet: "rs0"},
+			{Host: "::1", Port: 27018, ReplicaSet: "rs0", BindIP: "::1"},
+			{Host: "db1", Port: 27017, AdvertiseHost: "db1.example.com", ReplicaSet: "rs0"},
+		},
+	}
+	require.NoError(t, topo.applyDefaults())
+	assert.Empty(t, topo.validationProblems())
+	assert.Equal(t, "::1", topo.Mongod[0].Host, "brackets are trimmed")
+
+	bad := []struct {
+		node MongodNode
+		want string
+	}{
+		{MongodNode{Host: "db1:27017", Port: 27017}, "without a port"},
+		{MongodNode{Host: "db1", Port: 27017, AdvertiseHost: "db1 db2"}, "advertise_host"},
+		{MongodNode{Host: "db1", Port: 27017, BindIP: "127.0.0.1,,10.0.0.5"}, "empty address"},
+	}
+	for _, tt := range bad {
+		topo := &Topology{Mongod: []MongodNode{tt.node}}
+		problems := topo.validationProblems()
+		require.NotEmpty(t, problems, "%+v", tt.node)
+		assert.Contains(t, problems.Error(), tt.want)
+	}
+
+	// The same IPv6 host written with and without brackets is one host
+	dup := &Topology{Mongod: []MongodNode{{Host: "[::1]", Port: 27017}, {Host: "::1", Port: 27017}}}
+	require.NoError(t, dup.applyDefaults())
+	assert.NotEmpty(t, dup.validationProblems())
+}
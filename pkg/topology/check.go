@@ -0,0 +1,358 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	yamlSyntaxError = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+	pathSegment     = regexp.MustCompile(`[^.\[\]]+|\[\d+\]`)
+)
+
+// Problem is one thing wrong with a topology. Line and Column are 1-based
+// positions in the YAML source, or 0 when unknown. Path names the offending
+// entry, e.g. mongod_servers[2].port.
+type Problem struct {
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) Error() string {
+	switch {
+	case p.Line > 0 && p.Column > 0:
+		return fmt.Sprintf("line %d, column %d: %s", p.Line, p.Column, p.Message)
+	case p.Line > 0:
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	default:
+		return p.Message
+	}
+}
+
+// Problems is every problem found in a topology
+type Problems []Problem
+
+func (ps Problems) Error() string {
+	msgs := make([]string, len(ps))
+	for i, p := range ps {
+		msgs[i] = p.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// CheckTopology parses and validates topology YAML, returning every problem
+// with its source position. The topology is nil when the YAML could not be
+// decoded at all.
+func CheckTopology(data []byte) (*Topology, Problems) {
+	topo, root, err := parseTopology(data)
+	if err != nil {
+		var problems Problems
+		if errors.As(err, &problems) {
+			return nil, problems
+		}
+		return nil, Problems{{Message: err.Error()}}
+	}
+
+	problems := topo.validationProblems()
+	for i := range problems {
+		problems[i].locate(root)
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return topo, problems
+}
+
+// parseTopology decodes topology YAML strictly: unknown keys and values of
+// the wrong type are reported as Problems with positions
+func parseTopology(data []byte) (*Topology, *yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		problem := Problem{Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+		if m := yamlSyntaxError.FindStringSubmatch(err.Error()); m != nil {
+			problem.Line, _ = strconv.Atoi(m[1])
+			problem.Message = m[2]
+		}
+		return nil, nil, Problems{problem}
+	}
+
+	if problems := checkNode(&root, reflect.TypeOf(Topology{}), ""); len(problems) > 0 {
+		return nil, nil, problems
+	}
+	if err := interpolateVars(&root); err != nil {
+		return nil, nil, err
+	}
+
+	var topology Topology
+	if err := root.Decode(&topology); err != nil {
+		return nil, nil, Problems{{Message: strings.TrimPrefix(err.Error(), "yaml: ")}}
+	}
+	if err := topology.expand(); err != nil {
+		var problem Problem
+		if errors.As(err, &problem) {
+			problem.locate(&root)
+			return nil, nil, Problems{problem}
+		}
+		return nil, nil, err
+	}
+
+	// Apply global defaults to nodes
+	if err := topology.applyDefaults(); err != nil {
+		return nil, nil, fmt.Errorf("failed to apply defaults: %w", err)
+	}
+
+	return &topology, &root, nil
+}
+
+// locate sets the problem's position from its Path, using the deepest
+// node of the path that exists in the source
+func (p *Problem) locate(root *yaml.Node) {
+	if p.Line > 0 || root == nil {
+		return
+	}
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	p.Line, p.Column = node.Line, node.Column
+
+	for _, segment := range pathSegment.FindAllString(p.Path, -1) {
+		var next *yaml.Node
+		switch {
+		case strings.HasPrefix(segment, "[") && node.Kind == yaml.SequenceNode:
+			if i, err := strconv.Atoi(segment[1 : len(segment)-1]); err == nil && i < len(node.Content) {
+				next = node.Content[i]
+			}
+		case node.Kind == yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					next = node.Content[i+1]
+				}
+			}
+		}
+		if next == nil {
+			return
+		}
+		node = next
+		p.Line, p.Column = node.Line, node.Column
+	}
+}
+
+// sourcePath maps a path into the expanded topology back to the source
+// entry it came from, e.g. mongod_servers[4].port to shard_templates[1].base_port
+func (t *Topology) sourcePath(path string) string {
+	end := strings.Index(path, "]")
+	if end < 0 {
+		return path
+	}
+	source, ok := t.sources[path[:end+1]]
+	if !ok {
+		return path
+	}
+	field := path[end+1:]
+	if strings.HasPrefix(source, "shard_templates") {
+		switch field {
+		case ".host":
+			field = ".hosts"
+		case ".port":
+			field = ".base_port"
+		default:
+			field = ""
+		}
+	}
+	return source + field
+}
+
+// checkNode reports keys that do not exist in typ and scalars that cannot
+// be decoded into it. Scalars containing ${ are checked after interpolation.
+func checkNode(node *yaml.Node, typ reflect.Type, path string) Problems {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return checkNode(node.Content[0], typ, path)
+	case yaml.AliasNode:
+		return checkNode(node.Alias, typ, path)
+	}
+	if node.Tag == "!!null" {
+		return nil
+	}
+
+	problem := func(format string, args ...any) Problems {
+		return Problems{{Line: node.Line, Column: node.Column, Path: path, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return problem("%s must be a mapping", describePath(path))
+		}
+		fields := yamlFields(typ)
+		var problems Problems
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				problems = append(problems, checkNode(value, typ, path)...)
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q in %s", key.Value, describePath(path))
+				if suggestion := closestKey(key.Value, fields); suggestion != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+				}
+				problems = append(problems, Problem{Line: key.Line, Column: key.Column, Path: joinPath(path, key.Value), Message: msg})
+				continue
+			}
+			problems = append(problems, checkNode(value, field.Type, joinPath(path, key.Value))...)
+		}
+		return problems
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return problem("%s must be a list", describePath(path))
+		}
+		var problems Problems
+		for i, item := range node.Content {
+			problems = append(problems, checkNode(item, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return problem("%s must be a mapping", describePath(path))
+		}
+		var problems Problems
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			problems = append(problems, checkNode(node.Content[i+1], typ.Elem(), joinPath(path, node.Content[i].Value))...)
+		}
+		return problems
+
+	case reflect.Interface:
+		return nil
+
+	default:
+		if node.Kind != yaml.ScalarNode {
+			return problem("%s must be %s", describePath(path), withArticle(scalarName(typ)))
+		}
+		if strings.Contains(node.Value, "${") {
+			return nil
+		}
+		if err := node.Decode(reflect.New(typ).Interface()); err != nil {
+			return problem("%s must be %s, got %q", describePath(path), withArticle(scalarName(typ)), node.Value)
+		}
+		return nil
+	}
+}
+
+// yamlFields maps a struct's YAML keys to its fields
+func yamlFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// closestKey suggests the known key a typo was probably meant to be
+func closestKey(key string, fields map[string]reflect.StructField) string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(s))
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if normalize(name) == normalize(key) {
+			return name
+		}
+		if d := editDistance(key, name); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "topology"
+	}
+	return path
+}
+
+func withArticle(name string) string {
+	if name == "integer" {
+		return "an " + name
+	}
+	return "a " + name
+}
+
+// scalarName is the JSON Schema type name for a scalar Go type
+func scalarName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,89 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTopology_UnknownKeysAndTypes(t *testing.T) {
+	_, problems := CheckTopology([]byte(`global:
+  user: mongodb
+  deploy_dir: /opt/mongodb
+mongod_servers:
+  - host: db1
+    port: 27017
+    replicaset: rs0
+  - host: db2
+    port: twenty
+    hidden: maybe
+`))
+
+	require.Len(t, problems, 3)
+	assert.Equal(t, Problem{
+		Line:    7,
+		Column:  5,
+		Path:    "mongod_servers[0].replicaset",
+		Message: `unknown key "replicaset" in mongod_servers[0] (did you mean "replica_set"?)`,
+	}, problems[0])
+	assert.Equal(t, 9, problems[1].Line)
+	assert.Equal(t, 11, problems[1].Column)
+	assert.Equal(t, `mongod_servers[1].port must be an integer, got "twenty"`, problems[1].Message)
+	assert.Equal(t, `mongod_servers[1].hidden must be a boolean, got "maybe"`, problems[2].Message)
+
+	// ParseTopology rejects the same file with all positions in the error
+	_, err := ParseTopology([]byte("global:\n  usr: mongodb\n"))
+	assert.EqualError(t, err, `line 2, column 3: unknown key "usr" in global (did you mean "user"?)`)
+}
+
+func TestCheckTopology_ValidationPositions(t *testing.T) {
+	topo, problems := CheckTopology([]byte(`global:
+  user: mongodb
+mongod_servers:
+  - host: db1
+    port: 27017
+  - host: db2
+shard_templates:
+  - count: 2
+    hosts: [db1]
+    base_port: 27016
+`))
+	require.NotNil(t, topo)
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.Error())
+	}
+	assert.Equal(t, []string{
+		"line 2, column 3: global.deploy_dir is required",
+		"line 5, column 11: duplicate port 27017 on host db1",
+		"line 6, column 5: mongod node db2 missing port",
+	}, got)
+}
+
+func TestCheckTopology_ExpansionErrors(t *testing.T) {
+	_, problems := CheckTopology([]byte("mongod_servers:\n  - host: db[5:1]\n    port: 27017\n"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, 2, problems[0].Line)
+	assert.Contains(t, problems[0].Message, "end must not be before start")
+
+	_, problems = CheckTopology([]byte("global:\n  user: [oops\n"))
+	require.Len(t, problems, 1)
+	assert.Positive(t, problems[0].Line)
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema := JSONSchema()
+	assert.Equal(t, false, schema["additionalProperties"])
+
+	properties := schema["properties"].(map[string]any)
+	for _, key := range []string{"global", "mongod_servers", "replica_sets", "vars", "shard_templates"} {
+		assert.Contains(t, properties, key)
+	}
+
+	mongod := properties["mongod_servers"].(map[string]any)["items"].(map[string]any)
+	assert.Equal(t, []string{"host"}, mongod["required"])
+	assert.Contains(t, mongod["properties"], "replica_set")
+	assert.NotContains(t, mongod["properties"], "sources")
+}
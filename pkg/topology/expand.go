@@ -0,0 +1,382 @@
+package topology
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxRangeExpansion bounds how many hosts a single range pattern may produce
+const maxRangeExpansion = 1000
+
+var (
+	varPattern   = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+	varName      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	rangePattern = regexp.MustCompile(`\[(\d+):(\d+)\]`)
+)
+
+// ShardTemplate describes a group of identically shaped shard replica sets.
+// Each of the Count shards gets one member on every host in Hosts; shard i
+// (1-based) listens on BasePort + (i-1)*PortStep on all of them.
+//
+// Example:
+//
+//	shard_templates:
+//	  - replica_set: "shard{index}"
+//	    count: 3
+//	    hosts: ["db[01:03].example.com"]
+//	    base_port: 27018
+//	    data_dir: /data/mongodb-{port}
+//
+// String fields may use {index}, {port} and {replica_set}; {port} needs
+// base_port, since auto-allocated ports are not known yet.
+type ShardTemplate struct {
+	ReplicaSet    string         `yaml:"replica_set,omitempty"` // Name pattern, default "shard{index}"
+	Count         int            `yaml:"count"`
+	Hosts         []string       `yaml:"hosts"`
+	BasePort      int            `yaml:"base_port,omitempty"` // 0 auto-allocates ports for local deployments
+	PortStep      int            `yaml:"port_step,omitempty"` // Default 1
+	DeployDir     string         `yaml:"deploy_dir,omitempty"`
+	DataDir       string         `yaml:"data_dir,omitempty"`
+	LogDir        string         `yaml:"log_dir,omitempty"`
+	ConfigDir     string         `yaml:"config_dir,omitempty"`
+	RuntimeConfig map[string]any `yaml:"runtime_config,omitempty"`
+}
+
+// ParseTopology parses topology YAML. Before decoding, ${var} references
+// are replaced with values from the vars block ($${ escapes a literal ${).
+// After decoding, shard_templates and host ranges such as
+// db[01:03].example.com are expanded into individual nodes, so the result
+// never contains vars or shard_templates. Unknown keys are rejected; the
+// error is a Problems with source positions.
+func ParseTopology(data []byte) (*Topology, error) {
+	topology, _, err := parseTopology(data)
+	return topology, err
+}
+
+// interpolateVars resolves the document's vars block and substitutes
+// ${name} references in every other scalar
+func interpolateVars(root *yaml.Node) error {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var varsNode *yaml.Node
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == "vars" {
+			varsNode = doc.Content[i+1]
+		}
+	}
+
+	raw := make(map[string]string)
+	if varsNode != nil {
+		if err := varsNode.Decode(&raw); err != nil {
+			return Problems{{Line: varsNode.Line, Column: varsNode.Column, Path: "vars", Message: fmt.Sprintf("invalid vars: %v", err)}}
+		}
+	}
+	vars, err := resolveVars(raw)
+	if err != nil {
+		problem := Problem{Path: "vars", Message: err.Error()}
+		problem.locate(root)
+		return Problems{problem}
+	}
+
+	var walk func(n *yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		if n == varsNode {
+			return nil
+		}
+		if n.Kind == yaml.ScalarNode {
+			value, err := substituteVars(n.Value, vars)
+			if err != nil {
+				return Problems{{Line: n.Line, Column: n.Column, Message: err.Error()}}
+			}
+			if value != n.Value {
+				n.Value = value
+				// Let plain scalars resolve again, so ${port} can fill an int field
+				if n.Style == 0 {
+					n.Tag = ""
+				}
+			}
+			return nil
+		}
+		for _, child := range n.Content {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(doc)
+}
+
+// resolveVars expands references between vars, rejecting cycles
+func resolveVars(raw map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(raw))
+	resolving := make(map[string]bool)
+
+	var resolve func(name string) (string, error)
+	resolve = func(name string) (string, error) {
+		if value, ok := resolved[name]; ok {
+			return value, nil
+		}
+		value, ok := raw[name]
+		if !ok {
+			return "", fmt.Errorf("undefined variable ${%s}", name)
+		}
+		if resolving[name] {
+			return "", fmt.Errorf("variable ${%s} refers to itself", name)
+		}
+		resolving[name] = true
+		expanded, err := replaceVars(value, resolve)
+		if err != nil {
+			return "", err
+		}
+		resolving[name] = false
+		resolved[name] = expanded
+		return expanded, nil
+	}
+
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		if !varName.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := resolve(name); err != nil {
+			return nil, fmt.Errorf("vars.%s: %w", name, err)
+		}
+	}
+	return resolved, nil
+}
+
+// substituteVars replaces ${name} references in s with resolved vars
+func substituteVars(s string, vars map[string]string) (string, error) {
+	return replaceVars(s, func(name string) (string, error) {
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("undefined variable ${%s}", name)
+		}
+		return value, nil
+	})
+}
+
+func replaceVars(s string, lookup func(name string) (string, error)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var firstErr error
+	out := varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		name := match[2 : len(match)-1]
+		value, err := lookup(name)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return value
+	})
+	return out, firstErr
+}
+
+// ExpandHostRange expands numeric ranges in a host pattern:
+// db[01:03].example.com becomes db01, db02 and db03.example.com. A start
+// with a leading zero pads every number to its width. Several ranges in one
+// pattern expand to every combination. A host without ranges is returned
+// as is.
+func ExpandHostRange(pattern string) ([]string, error) {
+	loc := rangePattern.FindStringSubmatchIndex(pattern)
+	if loc == nil {
+		return []string{pattern}, nil
+	}
+
+	startText := pattern[loc[2]:loc[3]]
+	start, _ := strconv.Atoi(startText)
+	end, err := strconv.Atoi(pattern[loc[4]:loc[5]])
+	if err != nil || end < start {
+		return nil, fmt.Errorf("invalid host range in %q: end must not be before start", pattern)
+	}
+	if end-start+1 > maxRangeExpansion {
+		return nil, fmt.Errorf("host range in %q expands to more than %d hosts", pattern, maxRangeExpansion)
+	}
+
+	format := "%d"
+	if len(startText) > 1 && startText[0] == '0' {
+		format = fmt.Sprintf("%%0%dd", len(startText))
+	}
+
+	prefix, suffix := pattern[:loc[0]], pattern[loc[1]:]
+	var hosts []string
+	for i := start; i <= end; i++ {
+		rest, err := ExpandHostRange(suffix)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rest {
+			hosts = append(hosts, prefix+fmt.Sprintf(format, i)+r)
+		}
+		if len(hosts) > maxRangeExpansion {
+			return nil, fmt.Errorf("host range in %q expands to more than %d hosts", pattern, maxRangeExpansion)
+		}
+	}
+	return hosts, nil
+}
+
+// expand turns shard_templates and host ranges into individual nodes,
+// recording where each node came from for sourcePath
+func (t *Topology) expand() error {
+	sources := make(map[string]string)
+
+	var mongod []MongodNode
+	for i, tmpl := range t.ShardTemplates {
+		source := fmt.Sprintf("shard_templates[%d]", i)
+		nodes, err := tmpl.nodes()
+		if err != nil {
+			return Problem{Path: source, Message: fmt.Sprintf("%s: %v", source, err)}
+		}
+		for _, node := range nodes {
+			sources[fmt.Sprintf("mongod_servers[%d]", len(mongod))] = source
+			mongod = append(mongod, node)
+		}
+	}
+	for i, node := range t.Mongod {
+		source := fmt.Sprintf("mongod_servers[%d]", i)
+		hosts, err := ExpandHostRange(node.Host)
+		if err != nil {
+			return Problem{Path: source + ".host", Message: fmt.Sprintf("%s.host: %v", source, err)}
+		}
+		for _, host := range hosts {
+			sources[fmt.Sprintf("mongod_servers[%d]", len(mongod))] = source
+			expanded := node
+			expanded.Host = host
+			mongod = append(mongod, expanded)
+		}
+	}
+
+	var mongos []MongosNode
+	for i, node := range t.Mongos {
+		source := fmt.Sprintf("mongos_servers[%d]", i)
+		hosts, err := ExpandHostRange(node.Host)
+		if err != nil {
+			return Problem{Path: source + ".host", Message: fmt.Sprintf("%s.host: %v", source, err)}
+		}
+		for _, host := range hosts {
+			sources[fmt.Sprintf("mongos_servers[%d]", len(mongos))] = source
+			expanded := node
+			expanded.Host = host
+			mongos = append(mongos, expanded)
+		}
+	}
+
+	var configSvr []ConfigNode
+	for i, node := range t.ConfigSvr {
+		source := fmt.Sprintf("config_servers[%d]", i)
+		hosts, err := ExpandHostRange(node.Host)
+		if err != nil {
+			return Problem{Path: source + ".host", Message: fmt.Sprintf("%s.host: %v", source, err)}
+		}
+		for _, host := range hosts {
+			sources[fmt.Sprintf("config_servers[%d]", len(configSvr))] = source
+			expanded := node
+			expanded.Host = host
+			configSvr = append(configSvr, expanded)
+		}
+	}
+
+	t.Mongod, t.Mongos, t.ConfigSvr = mongod, mongos, configSvr
+	t.Vars = nil
+	t.ShardTemplates = nil
+	t.sources = sources
+	return nil
+}
+
+// nodes returns the template's mongod nodes, shard by shard
+func (s ShardTemplate) nodes() ([]MongodNode, error) {
+	name := s.ReplicaSet
+	if name == "" {
+		name = "shard{index}"
+	}
+	if s.Count <= 0 {
+		return nil, fmt.Errorf("count must be at least 1")
+	}
+	if s.Count > 1 && !strings.Contains(name, "{index}") {
+		return nil, fmt.Errorf("replica_set %q must contain {index} when count is above 1", name)
+	}
+	if s.BasePort < 0 || s.PortStep < 0 {
+		return nil, fmt.Errorf("base_port and port_step must not be negative")
+	}
+	step := s.PortStep
+	if step == 0 {
+		step = 1
+	}
+	if s.BasePort == 0 {
+		// Auto-allocated ports are only known after expansion, so {port}
+		// would expand to 0 and every shard would share the same paths
+		fields := []struct{ name, value string }{
+			{"deploy_dir", s.DeployDir}, {"data_dir", s.DataDir}, {"log_dir", s.LogDir}, {"config_dir", s.ConfigDir},
+		}
+		for _, field := range fields {
+			if strings.Contains(field.value, "{port}") {
+				return nil, fmt.Errorf("%s uses {port}, which needs base_port; use {index} or {replica_set} with auto-allocated ports", field.name)
+			}
+		}
+	}
+
+	var hosts []string
+	for _, pattern := range s.Hosts {
+		expanded, err := ExpandHostRange(pattern)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, expanded...)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("hosts must list at least one host")
+	}
+
+	var nodes []MongodNode
+	for index := 1; index <= s.Count; index++ {
+		port := 0
+		if s.BasePort > 0 {
+			port = s.BasePort + (index-1)*step
+		}
+		rsName := strings.ReplaceAll(name, "{index}", strconv.Itoa(index))
+		fill := strings.NewReplacer(
+			"{index}", strconv.Itoa(index),
+			"{port}", strconv.Itoa(port),
+			"{replica_set}", rsName,
+		).Replace
+
+		for _, host := range hosts {
+			node := MongodNode{
+				Host:       host,
+				Port:       port,
+				ReplicaSet: rsName,
+				DeployDir:  fill(s.DeployDir),
+				DataDir:    fill(s.DataDir),
+				LogDir:     fill(s.LogDir),
+				ConfigDir:  fill(s.ConfigDir),
+			}
+			if s.RuntimeConfig != nil {
+				node.RuntimeConfig = make(map[string]any, len(s.RuntimeConfig))
+				for k, v := range s.RuntimeConfig {
+					node.RuntimeConfig[k] = v
+				}
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,115 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandHostRange(t *testing.T) {
+	hosts, err := ExpandHostRange("db[01:03].example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db01.example.com", "db02.example.com", "db03.example.com"}, hosts)
+
+	hosts, err = ExpandHostRange("rack[1:2]-db[8:10]")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rack1-db8", "rack1-db9", "rack1-db10", "rack2-db8", "rack2-db9", "rack2-db10"}, hosts)
+
+	hosts, err = ExpandHostRange("db1.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1.example.com"}, hosts)
+
+	_, err = ExpandHostRange("db[3:1]")
+	assert.ErrorContains(t, err, "end must not be before start")
+
+	_, err = ExpandHostRange("db[0:5000]")
+	assert.ErrorContains(t, err, "more than 1000 hosts")
+}
+
+func TestParseTopology_VarsAndTemplates(t *testing.T) {
+	topo, err := ParseTopology([]byte(`
+vars:
+  domain: example.com
+  hosts: db[1:2].${domain}
+  port: "27019"
+global:
+  user: mongodb
+  deploy_dir: /opt/mongodb
+  data_dir: /data/$${literal}
+config_servers:
+  - host: cfg.${domain}
+    port: ${port}
+    replica_set: configRS
+mongos_servers:
+  - host: ${hosts}
+    port: 27017
+shard_templates:
+  - count: 2
+    hosts: ["${hosts}"]
+    base_port: 27020
+    port_step: 10
+    data_dir: /data/{replica_set}-{port}
+`))
+	require.NoError(t, err)
+
+	assert.Nil(t, topo.Vars)
+	assert.Nil(t, topo.ShardTemplates)
+	assert.Equal(t, "/data/${literal}", topo.Global.DataDir)
+	assert.Equal(t, 27019, topo.ConfigSvr[0].Port)
+	assert.Equal(t, "cfg.example.com", topo.ConfigSvr[0].Host)
+	require.Len(t, topo.Mongos, 2)
+	assert.Equal(t, "db2.example.com", topo.Mongos[1].Host)
+
+	require.Len(t, topo.Mongod, 4)
+	assert.Equal(t, MongodNode{
+		Host:       "db2.example.com",
+		Port:       27030,
+		ReplicaSet: "shard2",
+		DeployDir:  "/opt/mongodb",
+		DataDir:    "/data/shard2-27030",
+	}, topo.Mongod[3])
+	assert.Equal(t, "shard1", topo.Mongod[0].ReplicaSet)
+	assert.Equal(t, 27020, topo.Mongod[0].Port)
+}
+
+func TestParseTopology_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "undefined variable",
+			yaml:    "global:\n  user: ${nobody}\n",
+			wantErr: "line 2, column 9: undefined variable ${nobody}",
+		},
+		{
+			name:    "variable cycle",
+			yaml:    "vars:\n  a: ${b}\n  b: ${a}\n",
+			wantErr: "refers to itself",
+		},
+		{
+			name:    "template without index",
+			yaml:    "shard_templates:\n  - replica_set: rs\n    count: 2\n    hosts: [db1]\n",
+			wantErr: "must contain {index}",
+		},
+		{
+			name:    "template without hosts",
+			yaml:    "shard_templates:\n  - count: 1\n",
+			wantErr: "hosts must list at least one host",
+		},
+		{
+			name:    "template port without base_port",
+			yaml:    "shard_templates:\n  - count: 2\n    hosts: [localhost]\n    data_dir: /data/mongodb-{port}\n",
+			wantErr: "data_dir uses {port}, which needs base_port",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTopology([]byte(tt.yaml))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package topology
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Data-at-rest encryption cipher modes accepted in
+// percona.encryption.cipher_mode
+const (
+	CipherModeCBC = "AES256-CBC"
+	CipherModeGCM = "AES256-GCM"
+)
+
+// Audit log destinations accepted in percona.audit_log.destination
+const (
+	AuditLogFile    = "file"
+	AuditLogSyslog  = "syslog"
+	AuditLogConsole = "console"
+)
+
+// PerconaConfig is the topology's percona section: features of Percona
+// Server for MongoDB. Deploy rejects it for other variants.
+type PerconaConfig struct {
+	// Encryption encrypts the data files with a key mup generates
+	Encryption *EncryptionConfig `yaml:"encryption,omitempty"`
+
+	// AuditLog records authentication, authorization and DDL events
+	AuditLog *AuditLogConfig `yaml:"audit_log,omitempty"`
+
+	// Backup configures hot backups taken with 'mup cluster backup'
+	Backup *BackupConfig `yaml:"backup,omitempty"`
+}
+
+// EncryptionConfig is the topology's percona.encryption section
+type EncryptionConfig struct {
+	// KeyFile is an existing encryption key on the machine running mup to
+	// use instead of generating one
+	KeyFile string `yaml:"key_file,omitempty"`
+
+	// CipherMode is AES256-CBC (default) or AES256-GCM
+	CipherMode string `yaml:"cipher_mode,omitempty"`
+}
+
+// AuditLogConfig is the topology's percona.audit_log section
+type AuditLogConfig struct {
+	// Destination is file (default), syslog or console
+	Destination string `yaml:"destination,omitempty"`
+
+	// Format is JSON (default) or BSON, for the file destination
+	Format string `yaml:"format,omitempty"`
+
+	// Path is the audit log file, default auditLog.json or auditLog.bson
+	// in the node's log directory
+	Path string `yaml:"path,omitempty"`
+
+	// Filter is a query document selecting the events to log, such as
+	// '{ atype: { $in: ["authenticate", "dropDatabase"] } }'
+	Filter string `yaml:"filter,omitempty"`
+}
+
+// BackupConfig is the topology's percona.backup section
+type BackupConfig struct {
+	// Dir is where hot backups are written on the nodes' hosts, one
+	// timestamped directory per backup
+	Dir string `yaml:"dir"`
+}
+
+// EncryptionEnabled reports whether the topology encrypts data at rest
+func (t *Topology) EncryptionEnabled() bool {
+	return t.Percona != nil && t.Percona.Encryption != nil
+}
+
+// AuditLogEnabled reports whether the topology enables the audit log
+func (t *Topology) AuditLogEnabled() bool {
+	return t.Percona != nil && t.Percona.AuditLog != nil
+}
+
+// BackupDir returns where hot backups are written, "" when not configured
+func (t *Topology) BackupDir() string {
+	if t == nil || t.Percona == nil || t.Percona.Backup == nil {
+		return ""
+	}
+	return t.Percona.Backup.Dir
+}
+
+// CipherModeOrDefault returns the encryption cipher mode
+func (c *EncryptionConfig) CipherModeOrDefault() string {
+	if c == nil || c.CipherMode == "" {
+		return CipherModeCBC
+	}
+	return c.CipherMode
+}
+
+// DestinationOrDefault returns the audit log destination
+func (c *AuditLogConfig) DestinationOrDefault() string {
+	if c == nil || c.Destination == "" {
+		return AuditLogFile
+	}
+	return c.Destination
+}
+
+// FormatOrDefault returns the audit log file format
+func (c *AuditLogConfig) FormatOrDefault() string {
+	if c == nil || c.Format == "" {
+		return "JSON"
+	}
+	return c.Format
+}
+
+// validate checks the percona section, returning the offending path
+func (p *PerconaConfig) validate() (string, error) {
+	if p == nil {
+		return "", nil
+	}
+	if enc := p.Encryption; enc != nil {
+		switch enc.CipherModeOrDefault() {
+		case CipherModeCBC, CipherModeGCM:
+		default:
+			return "percona.encryption.cipher_mode", fmt.Errorf("percona.encryption.cipher_mode %q must be %s or %s", enc.CipherMode, CipherModeCBC, CipherModeGCM)
+		}
+	}
+	if audit := p.AuditLog; audit != nil {
+		switch audit.DestinationOrDefault() {
+		case AuditLogFile:
+			if format := audit.FormatOrDefault(); format != "JSON" && format != "BSON" {
+				return "percona.audit_log.format", fmt.Errorf("percona.audit_log.format %q must be JSON or BSON", audit.Format)
+			}
+		case AuditLogSyslog, AuditLogConsole:
+			if audit.Format != "" || audit.Path != "" {
+				return "percona.audit_log.destination", fmt.Errorf("percona.audit_log.format and path require destination %s", AuditLogFile)
+			}
+		default:
+			return "percona.audit_log.destination", fmt.Errorf("percona.audit_log.destination %q must be one of file, syslog, console", audit.Destination)
+		}
+		if audit.Path != "" && !filepath.IsAbs(audit.Path) {
+			return "percona.audit_log.path", fmt.Errorf("percona.audit_log.path %q must be absolute", audit.Path)
+		}
+		if filter := strings.TrimSpace(audit.Filter); filter != "" && (!strings.HasPrefix(filter, "{") || !strings.HasSuffix(filter, "}")) {
+			return "percona.audit_log.filter", fmt.Errorf("percona.audit_log.filter must be a query document such as '{ atype: \"authenticate\" }'")
+		}
+	}
+	if backup := p.Backup; backup != nil && !filepath.IsAbs(backup.Dir) {
+		return "percona.backup.dir", fmt.Errorf("percona.backup.dir %q must be an absolute path", backup.Dir)
+	}
+	return "", nil
+}
@@ -0,0 +1,50 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerconaConfig(t *testing.T) {
+	var topo Topology
+	assert.False(t, topo.EncryptionEnabled())
+	assert.False(t, topo.AuditLogEnabled())
+	assert.Empty(t, topo.BackupDir())
+
+	topo.Percona = &PerconaConfig{
+		Encryption: &EncryptionConfig{},
+		AuditLog:   &AuditLogConfig{},
+		Backup:     &BackupConfig{Dir: "/backups"},
+	}
+	assert.True(t, topo.EncryptionEnabled())
+	assert.True(t, topo.AuditLogEnabled())
+	assert.Equal(t, "/backups", topo.BackupDir())
+	assert.Equal(t, CipherModeCBC, topo.Percona.Encryption.CipherModeOrDefault())
+	assert.Equal(t, AuditLogFile, topo.Percona.AuditLog.DestinationOrDefault())
+	assert.Equal(t, "JSON", topo.Percona.AuditLog.FormatOrDefault())
+}
+
+func TestPerconaConfig_Validate(t *testing.T) {
+	tests := []struct {
+		percona PerconaConfig
+		path    string
+	}{
+		{PerconaConfig{Encryption: &EncryptionConfig{CipherMode: CipherModeGCM}}, ""},
+		{PerconaConfig{Encryption: &EncryptionConfig{CipherMode: "AES128-CBC"}}, "percona.encryption.cipher_mode"},
+		{PerconaConfig{AuditLog: &AuditLogConfig{Format: "BSON", Path: "/var/log/audit.bson", Filter: `{ atype: "authenticate" }`}}, ""},
+		{PerconaConfig{AuditLog: &AuditLogConfig{Destination: "syslog"}}, ""},
+		{PerconaConfig{AuditLog: &AuditLogConfig{Destination: "syslog", Format: "BSON"}}, "percona.audit_log.destination"},
+		{PerconaConfig{AuditLog: &AuditLogConfig{Destination: "kafka"}}, "percona.audit_log.destination"},
+		{PerconaConfig{AuditLog: &AuditLogConfig{Format: "XML"}}, "percona.audit_log.format"},
+		{PerconaConfig{AuditLog: &AuditLogConfig{Path: "audit.json"}}, "percona.audit_log.path"},
+		{PerconaConfig{AuditLog: &AuditLogConfig{Filter: "atype: authenticate"}}, "percona.audit_log.filter"},
+		{PerconaConfig{Backup: &BackupConfig{Dir: "/backups"}}, ""},
+		{PerconaConfig{Backup: &BackupConfig{}}, "percona.backup.dir"},
+	}
+	for _, tt := range tests {
+		path, err := tt.percona.validate()
+		assert.Equal(t, tt.path, path, "%+v", tt.percona)
+		assert.Equal(t, tt.path != "", err != nil, "%+v", tt.percona)
+	}
+}
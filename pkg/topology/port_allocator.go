@@ -179,7 +179,8 @@ func (pa *PortAllocator) GetAllocatedPorts() []int {
 
 // AllocatePortsForTopology allocates ports for all nodes in a local topology
 // It finds a contiguous block of available ports to ensure all can be allocated
-// Tries 10 times, incrementing base port by 20 each time
+// Tries 10 times, incrementing base port by 100 each time. A port must be
+// free on the system and, when checker is set, accepted by it.
 func AllocatePortsForTopology(topo *Topology, checker PortChecker) error {
 	if !topo.IsLocalDeployment() {
 		return fmt.Errorf("port allocation is only for local deployments")
@@ -226,6 +227,16 @@ func AllocatePortsForTopology(topo *Topology, checker PortChecker) error {
 			port := tryBase + i
 			ports[i] = port
 
+			if checker != nil {
+				ok, err := checker(port)
+				if err != nil {
+					return fmt.Errorf("failed to check port %d: %w", port, err)
+				}
+				if !ok {
+					allAvailable = false
+					break
+				}
+			}
 			if !isPortAvailable(port) {
 				allAvailable = false
 				break
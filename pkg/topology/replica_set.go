@@ -0,0 +1,331 @@
+package topology
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// MongoDB replica set limits
+const (
+	MaxReplicaSetMembers = 50
+	MaxVotingMembers     = 7
+	MaxArbiters          = 1 // From MongoDB 5.3 unless allowMultipleArbiters is set; see CountArbiters
+	MaxMemberPriority    = 1000
+)
+
+// MemberPriority returns the node's effective priority: 1 by default and
+// 0 for arbiters
+func (n *MongodNode) MemberPriority() float64 {
+	if n.Priority != nil {
+		return *n.Priority
+	}
+	if n.ArbiterOnly {
+		return 0
+	}
+	return 1
+}
+
+// MemberVotes returns the node's effective number of votes (default 1)
+func (n *MongodNode) MemberVotes() int {
+	if n.Votes != nil {
+		return *n.Votes
+	}
+	return 1
+}
+
+// IsHidden reports whether the node is a hidden member
+func (n *MongodNode) IsHidden() bool {
+	return n.Hidden != nil && *n.Hidden
+}
+
+// BuildsIndexes reports whether the node builds indexes (default true)
+func (n *MongodNode) BuildsIndexes() bool {
+	return n.BuildIndexes == nil || *n.BuildIndexes
+}
+
+// CountArbiters returns the number of arbiters in each replica set
+func (t *Topology) CountArbiters() map[string]int {
+	arbiters := make(map[string]int)
+	for _, node := range t.Mongod {
+		if node.ReplicaSet != "" && node.ArbiterOnly {
+			arbiters[node.ReplicaSet]++
+		}
+	}
+	return arbiters
+}
+
+// ValidateReplicaSetMembers enforces MongoDB's rules for replica set member
+// options, so a bad topology fails before anything is deployed:
+//   - priority is 0-1000 and votes is 0 or 1
+//   - members with 0 votes must have priority 0
+//   - hidden members, delayed members and members that do not build indexes
+//     must have priority 0; delayed members must also be hidden
+//   - arbiters cannot be hidden, delayed, tagged, skip index builds or have
+//     priority above 0, and must vote
+//   - a replica set has at most 50 members and 7 voting members, and at
+//     least one member that can become primary
+//
+// The arbiter limit depends on the MongoDB version and is checked when the
+// plan is generated.
+func (t *Topology) ValidateReplicaSetMembers() error {
+	replicaSets := make(map[string][]MongodNode)
+	for _, node := range t.Mongod {
+		id := GetNodeID(node.Host, node.Port)
+		if err := node.validateMemberOptions(); err != nil {
+			return fmt.Errorf("mongod %s: %w", id, err)
+		}
+		if node.ReplicaSet == "" {
+			if node.ArbiterOnly || node.SecondaryDelaySecs > 0 || node.IsHidden() || len(node.Tags) > 0 {
+				return fmt.Errorf("mongod %s: replica set member options require replica_set", id)
+			}
+			continue
+		}
+		replicaSets[node.ReplicaSet] = append(replicaSets[node.ReplicaSet], node)
+	}
+
+	names := make([]string, 0, len(replicaSets))
+	for name := range replicaSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		members := replicaSets[name]
+		voting, electable := 0, 0
+		for i := range members {
+			member := &members[i]
+			if member.MemberVotes() > 0 {
+				voting++
+			}
+			if !member.ArbiterOnly && member.MemberPriority() > 0 {
+				electable++
+			}
+		}
+
+		switch {
+		case len(members) > MaxReplicaSetMembers:
+			return fmt.Errorf("replica set %s has %d members (max %d)", name, len(members), MaxReplicaSetMembers)
+		case voting > MaxVotingMembers:
+			return fmt.Errorf("replica set %s has %d voting members (max %d); set votes: 0 and priority: 0 on the rest", name, voting, MaxVotingMembers)
+		case electable == 0:
+			return fmt.Errorf("replica set %s has no member that can become primary", name)
+		}
+	}
+
+	return nil
+}
+
+// validateMemberOptions checks one member's options against each other
+func (n *MongodNode) validateMemberOptions() error {
+	priority := n.MemberPriority()
+	votes := n.MemberVotes()
+
+	if priority < 0 || priority > MaxMemberPriority {
+		return fmt.Errorf("priority must be between 0 and %d, got %v", MaxMemberPriority, priority)
+	}
+	if votes != 0 && votes != 1 {
+		return fmt.Errorf("votes must be 0 or 1, got %d", votes)
+	}
+	if n.SecondaryDelaySecs < 0 {
+		return fmt.Errorf("secondary_delay_secs must not be negative, got %d", n.SecondaryDelaySecs)
+	}
+	for key, value := range n.Tags {
+		if key == "" || value == "" {
+			return fmt.Errorf("tags must have non-empty keys and values")
+		}
+	}
+
+	if n.ArbiterOnly {
+		switch {
+		case priority > 0:
+			return fmt.Errorf("arbiters must have priority 0")
+		case votes != 1:
+			return fmt.Errorf("arbiters must have votes 1")
+		case n.IsHidden():
+			return fmt.Errorf("arbiters cannot be hidden")
+		case n.SecondaryDelaySecs > 0:
+			return fmt.Errorf("arbiters cannot be delayed")
+		case !n.BuildsIndexes():
+			return fmt.Errorf("arbiters cannot set build_indexes")
+		case len(n.Tags) > 0:
+			return fmt.Errorf("arbiters cannot have tags")
+		}
+		return nil
+	}
+
+	switch {
+	case votes == 0 && priority > 0:
+		return fmt.Errorf("non-voting members must have priority 0")
+	case n.IsHidden() && priority > 0:
+		return fmt.Errorf("hidden members must have priority 0")
+	case n.SecondaryDelaySecs > 0 && (priority > 0 || !n.IsHidden()):
+		return fmt.Errorf("delayed members must be hidden with priority 0")
+	case !n.BuildsIndexes() && priority > 0:
+		return fmt.Errorf("members with build_indexes: false must have priority 0")
+	}
+	return nil
+}
+
+// ReplicaSetSpecFor returns the replica_sets entry for name, or nil
+func (t *Topology) ReplicaSetSpecFor(name string) *ReplicaSetSpec {
+	for i := range t.ReplicaSets {
+		if t.ReplicaSets[i].Name == name {
+			return &t.ReplicaSets[i]
+		}
+	}
+	return nil
+}
+
+// applyReplicaSetMembership assigns nodes listed in a replica_sets entry to
+// that replica set when they do not name one themselves
+func (t *Topology) applyReplicaSetMembership() {
+	for _, spec := range t.ReplicaSets {
+		for _, member := range spec.Members {
+			for i := range t.Mongod {
+				node := &t.Mongod[i]
+				if node.ReplicaSet == "" && GetNodeID(node.Host, node.Port) == canonicalNodeID(member) {
+					node.ReplicaSet = spec.Name
+				}
+			}
+		}
+	}
+}
+
+// ValidateReplicaSetSpecs checks the replica_sets block: every entry names a
+// replica set defined by the nodes, listed members belong to it, and the
+// settings are within MongoDB's accepted values. Version-specific rules are
+// checked when the plan is generated.
+func (t *Topology) ValidateReplicaSetSpecs() error {
+	nodeReplicaSets := make(map[string]string) // host:port -> replica set
+	configReplicaSets := make(map[string]bool)
+	for _, node := range t.Mongod {
+		nodeReplicaSets[GetNodeID(node.Host, node.Port)] = node.ReplicaSet
+	}
+	for _, node := range t.ConfigSvr {
+		nodeReplicaSets[GetNodeID(node.Host, node.Port)] = node.ReplicaSet
+		configReplicaSets[node.ReplicaSet] = true
+	}
+	defined := make(map[string]bool)
+	for _, rs := range nodeReplicaSets {
+		if rs != "" {
+			defined[rs] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var clusterDefaults *ReplicaSetSpec
+	for _, spec := range t.ReplicaSets {
+		if spec.Name == "" {
+			return fmt.Errorf("replica_sets entry missing name")
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("replica_sets: duplicate entry for %s", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		if !defined[spec.Name] {
+			return fmt.Errorf("replica_sets: %s has no nodes", spec.Name)
+		}
+		for _, member := range spec.Members {
+			rs, ok := nodeReplicaSets[canonicalNodeID(member)]
+			if !ok {
+				return fmt.Errorf("replica_sets: %s member %s is not a node in the topology", spec.Name, member)
+			}
+			if rs != spec.Name {
+				return fmt.Errorf("replica_sets: %s member %s belongs to replica set %s", spec.Name, member, rs)
+			}
+		}
+
+		if err := spec.validate(); err != nil {
+			return fmt.Errorf("replica_sets: %s: %w", spec.Name, err)
+		}
+
+		if configReplicaSets[spec.Name] && spec.WriteConcernMajorityJournalDefault != nil && !*spec.WriteConcernMajorityJournalDefault {
+			return fmt.Errorf("replica_sets: %s: config server replica sets require write_concern_majority_journal_default: true", spec.Name)
+		}
+		// A sharded cluster has one set of defaults, set once through mongos
+		if t.GetTopologyType() == "sharded" && spec.HasDefaultRWConcern() {
+			if clusterDefaults == nil {
+				clusterDefaults = &spec
+			} else if !sameDefaultRWConcern(clusterDefaults, &spec) {
+				return fmt.Errorf("replica_sets: %s: default read/write concerns apply to the whole sharded cluster and must match those of %s", spec.Name, clusterDefaults.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DefaultRWConcernSpec returns the first replica_sets entry that sets
+// default read/write concerns, or nil. In sharded clusters every such entry
+// sets the same defaults.
+func (t *Topology) DefaultRWConcernSpec() *ReplicaSetSpec {
+	for i := range t.ReplicaSets {
+		if t.ReplicaSets[i].HasDefaultRWConcern() {
+			return &t.ReplicaSets[i]
+		}
+	}
+	return nil
+}
+
+// sameDefaultRWConcern reports whether a and b set the same defaults
+func sameDefaultRWConcern(a, b *ReplicaSetSpec) bool {
+	return a.DefaultReadConcern == b.DefaultReadConcern && reflect.DeepEqual(a.DefaultWriteConcern, b.DefaultWriteConcern)
+}
+
+// HasDefaultRWConcern reports whether the spec sets a default read or write concern
+func (s *ReplicaSetSpec) HasDefaultRWConcern() bool {
+	return s.DefaultReadConcern != "" || s.DefaultWriteConcern != nil
+}
+
+// validate checks the spec's values
+func (s *ReplicaSetSpec) validate() error {
+	// protocolVersion 0 was removed in MongoDB 4.0
+	if s.ProtocolVersion != 0 && s.ProtocolVersion != 1 {
+		return fmt.Errorf("protocol_version must be 1, got %d", s.ProtocolVersion)
+	}
+
+	if settings := s.Settings; settings != nil {
+		switch {
+		case settings.HeartbeatIntervalMillis < 0:
+			return fmt.Errorf("settings.heartbeat_interval_millis must not be negative")
+		case settings.HeartbeatTimeoutSecs < 0:
+			return fmt.Errorf("settings.heartbeat_timeout_secs must not be negative")
+		case settings.ElectionTimeoutMillis < 0:
+			return fmt.Errorf("settings.election_timeout_millis must not be negative")
+		case settings.CatchUpTimeoutMillis != nil && *settings.CatchUpTimeoutMillis < -1:
+			return fmt.Errorf("settings.catch_up_timeout_millis must be -1 (no limit) or more")
+		}
+	}
+
+	switch s.DefaultReadConcern {
+	case "", "local", "available", "majority":
+	default:
+		return fmt.Errorf("default_read_concern must be one of: local, available, majority")
+	}
+
+	if wc := s.DefaultWriteConcern; wc != nil {
+		if wc.W != "" && wc.W != "majority" {
+			if n, err := strconv.Atoi(wc.W); err != nil || n < 0 {
+				return fmt.Errorf("default_write_concern.w must be a member count or \"majority\", got %q", wc.W)
+			}
+		}
+		if wc.WTimeout < 0 {
+			return fmt.Errorf("default_write_concern.wtimeout must not be negative")
+		}
+	}
+
+	return nil
+}
+
+// canonicalNodeID rewrites a host:port member as GetNodeID would build it,
+// so [::1]:27017 and a node with host ::1 match
+func canonicalNodeID(member string) string {
+	host, port, err := ParseNodeID(member)
+	if err != nil {
+		return member
+	}
+	return GetNodeID(host, port)
+}
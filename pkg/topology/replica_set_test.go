@@ -0,0 +1,208 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReplicaSetMembers(t *testing.T) {
+	yes, zero, two := true, 0.0, 2.0
+	noVotes := 0
+
+	member := func(port int, mutate func(n *MongodNode)) MongodNode {
+		n := MongodNode{Host: "db", Port: port, ReplicaSet: "rs0"}
+		if mutate != nil {
+			mutate(&n)
+		}
+		return n
+	}
+
+	tests := []struct {
+		name    string
+		nodes   []MongodNode
+		wantErr string
+	}{
+		{
+			name: "primary, arbiter and delayed hidden member",
+			nodes: []MongodNode{
+				member(27017, nil),
+				member(27018, func(n *MongodNode) { n.ArbiterOnly = true }),
+				member(27019, func(n *MongodNode) {
+					n.Priority, n.Hidden, n.SecondaryDelaySecs = &zero, &yes, 3600
+					n.Tags = map[string]string{"use": "backup"}
+				}),
+			},
+		},
+		{
+			name: "delayed member not hidden",
+			nodes: []MongodNode{
+				member(27017, nil),
+				member(27018, func(n *MongodNode) { n.Priority, n.SecondaryDelaySecs = &zero, 60 }),
+			},
+			wantErr: "delayed members must be hidden with priority 0",
+		},
+		{
+			name: "hidden member with priority",
+			nodes: []MongodNode{
+				member(27017, nil),
+				member(27018, func(n *MongodNode) { n.Hidden = &yes }),
+			},
+			wantErr: "hidden members must have priority 0",
+		},
+		{
+			name: "arbiter with priority",
+			nodes: []MongodNode{
+				member(27017, nil),
+				member(27018, func(n *MongodNode) { n.ArbiterOnly, n.Priority = true, &two }),
+			},
+			wantErr: "arbiters must have priority 0",
+		},
+		{
+			name: "non-voting member with priority",
+			nodes: []MongodNode{
+				member(27017, nil),
+				member(27018, func(n *MongodNode) { n.Votes = &noVotes }),
+			},
+			wantErr: "non-voting members must have priority 0",
+		},
+		{
+			name: "two arbiters",
+			nodes: []MongodNode{
+				member(27017, nil),
+				member(27018, func(n *MongodNode) { n.ArbiterOnly = true }),
+				member(27019, func(n *MongodNode) { n.ArbiterOnly = true }),
+			},
+		},
+		{
+			name: "only arbiters and hidden members",
+			nodes: []MongodNode{
+				member(27017, func(n *MongodNode) { n.Priority, n.Hidden = &zero, &yes }),
+				member(27018, func(n *MongodNode) { n.ArbiterOnly = true }),
+			},
+			wantErr: "no member that can become primary",
+		},
+	}
+
+	eightVoters := make([]MongodNode, 0, 8)
+	for i := 0; i < 8; i++ {
+		eightVoters = append(eightVoters, member(27017+i, nil))
+	}
+	tests = append(tests, struct {
+		name    string
+		nodes   []MongodNode
+		wantErr string
+	}{name: "eight voting members", nodes: eightVoters, wantErr: "8 voting members (max 7)"})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topo := &Topology{Mongod: tt.nodes}
+			err := topo.ValidateReplicaSetMembers()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateReplicaSetSpecs(t *testing.T) {
+	no := false
+	base := func() *Topology {
+		return &Topology{
+			Mongod: []MongodNode{
+				{Host: "db1", Port: 27017, ReplicaSet: "rs0"},
+				{Host: "db2", Port: 27017, ReplicaSet: "rs0"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(t *Topology)
+		wantErr string
+	}{
+		{
+			name: "settings and default concerns",
+			mutate: func(t *Topology) {
+				t.ReplicaSets = []ReplicaSetSpec{{
+					Name:                "rs0",
+					Members:             []string{"db1:27017"},
+					Settings:            &ReplicaSetSettings{ElectionTimeoutMillis: 5000},
+					DefaultReadConcern:  "majority",
+					DefaultWriteConcern: &WriteConcern{W: "majority"},
+				}}
+			},
+		},
+		{
+			name:    "unknown replica set",
+			mutate:  func(t *Topology) { t.ReplicaSets = []ReplicaSetSpec{{Name: "rs1"}} },
+			wantErr: "rs1 has no nodes",
+		},
+		{
+			name: "member from another replica set",
+			mutate: func(t *Topology) {
+				t.Mongod = append(t.Mongod, MongodNode{Host: "db3", Port: 27017, ReplicaSet: "rs1"})
+				t.ReplicaSets = []ReplicaSetSpec{{Name: "rs0", Members: []string{"db3:27017"}}}
+			},
+			wantErr: "belongs to replica set rs1",
+		},
+		{
+			name:    "protocol version 0",
+			mutate:  func(t *Topology) { t.ReplicaSets = []ReplicaSetSpec{{Name: "rs0", ProtocolVersion: 2}} },
+			wantErr: "protocol_version must be 1",
+		},
+		{
+			name: "invalid write concern",
+			mutate: func(t *Topology) {
+				t.ReplicaSets = []ReplicaSetSpec{{Name: "rs0", DefaultWriteConcern: &WriteConcern{W: "most"}}}
+			},
+			wantErr: "default_write_concern.w",
+		},
+		{
+			name: "config server without journaled majority writes",
+			mutate: func(t *Topology) {
+				t.ConfigSvr = []ConfigNode{{Host: "cfg", Port: 27019, ReplicaSet: "configRS"}}
+				t.ReplicaSets = []ReplicaSetSpec{{Name: "configRS", WriteConcernMajorityJournalDefault: &no}}
+			},
+			wantErr: "require write_concern_majority_journal_default: true",
+		},
+		{
+			name: "sharded cluster with matching default concerns",
+			mutate: func(t *Topology) {
+				t.ConfigSvr = []ConfigNode{{Host: "cfg", Port: 27019, ReplicaSet: "configRS"}}
+				t.Mongos = []MongosNode{{Host: "router", Port: 27016}}
+				t.ReplicaSets = []ReplicaSetSpec{
+					{Name: "configRS", DefaultReadConcern: "majority"},
+					{Name: "rs0", DefaultReadConcern: "majority"},
+				}
+			},
+		},
+		{
+			name: "sharded cluster with conflicting default concerns",
+			mutate: func(t *Topology) {
+				t.ConfigSvr = []ConfigNode{{Host: "cfg", Port: 27019, ReplicaSet: "configRS"}}
+				t.Mongos = []MongosNode{{Host: "router", Port: 27016}}
+				t.ReplicaSets = []ReplicaSetSpec{
+					{Name: "configRS", DefaultReadConcern: "majority"},
+					{Name: "rs0", DefaultReadConcern: "local"},
+				}
+			},
+			wantErr: "rs0: default read/write concerns apply to the whole sharded cluster and must match those of configRS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topo := base()
+			tt.mutate(topo)
+			err := topo.ValidateReplicaSetSpecs()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package topology
+
+import "reflect"
+
+// schemaRequired lists the keys each type must set; everything else is
+// optional or defaulted
+var schemaRequired = map[reflect.Type][]string{
+	reflect.TypeOf(GlobalConfig{}):   {"user", "deploy_dir"},
+	reflect.TypeOf(MongodNode{}):     {"host"},
+	reflect.TypeOf(MongosNode{}):     {"host"},
+	reflect.TypeOf(ConfigNode{}):     {"host", "replica_set"},
+	reflect.TypeOf(ReplicaSetSpec{}): {"name"},
+	reflect.TypeOf(ShardTemplate{}):  {"count", "hosts"},
+	reflect.TypeOf(BackupConfig{}):   {"dir"},
+}
+
+// JSONSchema returns a JSON Schema (draft-07) for topology files, generated
+// from the Topology type so it always matches what ParseTopology accepts.
+// Numbers and booleans also accept strings containing ${var} references.
+func JSONSchema() map[string]any {
+	schema := schemaFor(reflect.TypeOf(Topology{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "mup topology"
+	return schema
+}
+
+func schemaFor(typ reflect.Type) map[string]any {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]any)
+		for name, field := range yamlFields(typ) {
+			properties[name] = schemaFor(field.Type)
+		}
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if required := schemaRequired[typ]; len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(typ.Elem())}
+
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(typ.Elem())}
+
+	case reflect.Interface:
+		return map[string]any{}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	default:
+		return map[string]any{
+			"anyOf": []any{
+				map[string]any{"type": scalarName(typ)},
+				map[string]any{"type": "string", "pattern": `\$\{`},
+			},
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package topology
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SecurityConfig is the topology's security section
+type SecurityConfig struct {
+	// Auth enables access control: members authenticate to each other
+	// with a keyFile and clients must log in. The first user is created
+	// through the localhost exception during deploy.
+	Auth bool `yaml:"auth"`
+
+	// KeyFile is an existing keyFile on the machine running mup to use
+	// instead of generating one
+	KeyFile string `yaml:"key_file,omitempty"`
+
+	// AdminUser names the first user, which gets the root role (default admin)
+	AdminUser string `yaml:"admin_user,omitempty"`
+
+	// TLS encrypts connections with certificates issued by a per-cluster
+	// CA that mup creates
+	TLS *TLSConfig `yaml:"tls,omitempty"`
+}
+
+// TLS modes accepted in security.tls.mode
+const (
+	TLSModeRequire = "requireTLS"
+	TLSModePrefer  = "preferTLS"
+	TLSModeAllow   = "allowTLS"
+)
+
+// TLSConfig is the topology's security.tls section
+type TLSConfig struct {
+	// Mode is net.tls.mode: requireTLS (default), or preferTLS and allowTLS
+	// while clients move to TLS
+	Mode string `yaml:"mode,omitempty"`
+
+	// X509MemberAuth makes members authenticate to each other with their
+	// certificates instead of the keyFile. Requires auth.
+	X509MemberAuth bool `yaml:"x509_member_auth,omitempty"`
+}
+
+// AuthEnabled reports whether the topology enables access control
+func (t *Topology) AuthEnabled() bool {
+	return t.Security != nil && t.Security.Auth
+}
+
+// TLSEnabled reports whether the topology enables TLS
+func (t *Topology) TLSEnabled() bool {
+	return t.Security != nil && t.Security.TLS != nil
+}
+
+// TLSMode returns net.tls.mode for the nodes
+func (c *TLSConfig) TLSMode() string {
+	if c == nil || c.Mode == "" {
+		return TLSModeRequire
+	}
+	return c.Mode
+}
+
+// AdminUsername returns the first user's name
+func (s *SecurityConfig) AdminUsername() string {
+	if s == nil || s.AdminUser == "" {
+		return "admin"
+	}
+	return s.AdminUser
+}
+
+// validate checks the security section, returning the offending path
+func (s *SecurityConfig) validate() (string, error) {
+	if s == nil {
+		return "", nil
+	}
+	if !s.Auth && s.KeyFile != "" {
+		return "security.key_file", fmt.Errorf("security.key_file requires security.auth: true")
+	}
+	if strings.ContainsAny(s.AdminUser, " \t\n\"'$:@/") {
+		return "security.admin_user", fmt.Errorf("security.admin_user %q contains characters that are not allowed in a user name", s.AdminUser)
+	}
+	if s.TLS != nil {
+		switch s.TLS.TLSMode() {
+		case TLSModeRequire, TLSModePrefer:
+		case TLSModeAllow:
+			// Members connect to each other without TLS, so they cannot
+			// present certificates
+			if s.TLS.X509MemberAuth {
+				return "security.tls.x509_member_auth", fmt.Errorf("security.tls.x509_member_auth requires security.tls.mode requireTLS or preferTLS")
+			}
+		default:
+			return "security.tls.mode", fmt.Errorf("security.tls.mode %q must be one of requireTLS, preferTLS, allowTLS", s.TLS.Mode)
+		}
+		if s.TLS.X509MemberAuth && !s.Auth {
+			return "security.tls.x509_member_auth", fmt.Errorf("security.tls.x509_member_auth requires security.auth: true")
+		}
+	}
+	return "", nil
+}
+
+// CertificateHosts returns the names a node's certificate is issued for:
+// its host, advertised host, specific bind addresses and the loopback names
+// mup connects through
+func CertificateHosts(host, advertiseHost, bindIP string) []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		name = TrimHost(strings.TrimSpace(name))
+		if name == "" || name == "0.0.0.0" || name == "::" || seen[name] {
+			return
+		}
+		seen[name] = true
+		hosts = append(hosts, name)
+	}
+	add(host)
+	add(advertiseHost)
+	for _, address := range strings.Split(bindIP, ",") {
+		add(address)
+	}
+	for _, loopback := range []string{"localhost", "127.0.0.1", "::1"} {
+		add(loopback)
+	}
+	return hosts
+}
+
+// NodeCertificateHosts returns the CertificateHosts of the node at
+// host:port, or nil when the topology has no such node
+func (t *Topology) NodeCertificateHosts(host string, port int) []string {
+	for _, node := range t.ConfigSvr {
+		if node.Host == host && node.Port == port {
+			return CertificateHosts(node.Host, node.AdvertiseHost, node.BindIP)
+		}
+	}
+	for _, node := range t.Mongod {
+		if node.Host == host && node.Port == port {
+			return CertificateHosts(node.Host, node.AdvertiseHost, node.BindIP)
+		}
+	}
+	for _, node := range t.Mongos {
+		if node.Host == host && node.Port == port {
+			return CertificateHosts(node.Host, node.AdvertiseHost, node.BindIP)
+		}
+	}
+	return nil
+}
+
+// LoopbackAddress returns the loopback address a node bound to bindIP
+// listens on, preferring IPv4, or "" when it binds none. The first user is
+// created over it through the localhost exception.
+func LoopbackAddress(bindIP string) string {
+	v6 := false
+	for _, address := range strings.Split(bindIP, ",") {
+		switch strings.TrimSpace(address) {
+		case "127.0.0.1", "0.0.0.0", "localhost":
+			return "127.0.0.1"
+		case "::1", "::":
+			v6 = true
+		}
+	}
+	if v6 {
+		return "::1"
+	}
+	return ""
+}
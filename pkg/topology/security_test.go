@@ -0,0 +1,97 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityConfig(t *testing.T) {
+	var topo Topology
+	assert.False(t, topo.AuthEnabled())
+	assert.Equal(t, "admin", topo.Security.AdminUsername())
+
+	topo.Security = &SecurityConfig{Auth: true, AdminUser: "root"}
+	assert.True(t, topo.AuthEnabled())
+	assert.Equal(t, "root", topo.Security.AdminUsername())
+}
+
+func TestSecurityConfig_Validate(t *testing.T) {
+	tests := []struct {
+		security SecurityConfig
+		path     string
+	}{
+		{SecurityConfig{Auth: true, KeyFile: "/etc/mongo/keyfile", AdminUser: "dba"}, ""},
+		{SecurityConfig{KeyFile: "/etc/mongo/keyfile"}, "security.key_file"},
+		{SecurityConfig{Auth: true, AdminUser: "dba@example"}, "security.admin_user"},
+		{SecurityConfig{TLS: &TLSConfig{}}, ""},
+		{SecurityConfig{Auth: true, TLS: &TLSConfig{Mode: "preferTLS", X509MemberAuth: true}}, ""},
+		{SecurityConfig{TLS: &TLSConfig{Mode: "requireSSL"}}, "security.tls.mode"},
+		{SecurityConfig{TLS: &TLSConfig{X509MemberAuth: true}}, "security.tls.x509_member_auth"},
+		{SecurityConfig{Auth: true, TLS: &TLSConfig{Mode: "allowTLS", X509MemberAuth: true}}, "security.tls.x509_member_auth"},
+	}
+	for _, tt := range tests {
+		path, err := tt.security.validate()
+		assert.Equal(t, tt.path, path, "%+v", tt.security)
+		assert.Equal(t, tt.path != "", err != nil, "%+v", tt.security)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	var topo Topology
+	assert.False(t, topo.TLSEnabled())
+
+	topo.Security = &SecurityConfig{TLS: &TLSConfig{}}
+	assert.True(t, topo.TLSEnabled())
+	assert.Equal(t, TLSModeRequire, topo.Security.TLS.TLSMode())
+
+	topo.Security.TLS.Mode = TLSModePrefer
+	assert.Equal(t, TLSModePrefer, topo.Security.TLS.TLSMode())
+}
+
+func TestCertificateHosts(t *testing.T) {
+	assert.Equal(t,
+		[]string{"db1", "db1.example.com", "127.0.0.1", "10.0.0.5", "localhost", "::1"},
+		CertificateHosts("db1", "db1.example.com", "127.0.0.1,10.0.0.5"))
+	assert.Equal(t,
+		[]string{"2001:db8::5", "localhost", "127.0.0.1", "::1"},
+		CertificateHosts("[2001:db8::5]", "", "0.0.0.0,::"), "wildcards are skipped")
+}
+
+func TestNodeCertificateHosts(t *testing.T) {
+	topo := &Topology{
+		ConfigSvr: []ConfigNode{{Host: "cfg1", Port: 27019}},
+		Mongod:    []MongodNode{{Host: "db1", Port: 27018, AdvertiseHost: "db1.example.com"}},
+		Mongos:    []MongosNode{{Host: "app1", Port: 27017, BindIP: "10.0.0.9"}},
+	}
+	assert.Equal(t, []string{"cfg1", "localhost", "127.0.0.1", "::1"}, topo.NodeCertificateHosts("cfg1", 27019))
+	assert.Equal(t, []string{"db1", "db1.example.com", "localhost", "127.0.0.1", "::1"}, topo.NodeCertificateHosts("db1", 27018))
+	assert.Equal(t, []string{"app1", "10.0.0.9", "localhost", "127.0.0.1", "::1"}, topo.NodeCertificateHosts("app1", 27017))
+	assert.Nil(t, topo.NodeCertificateHosts("db1", 27019))
+}
+
+func TestLoopbackAddress(t *testing.T) {
+	assert.Equal(t, "127.0.0.1", LoopbackAddress(LocalBindIP))
+	assert.Equal(t, "127.0.0.1", LoopbackAddress("10.0.0.5, 0.0.0.0"))
+	assert.Equal(t, "::1", LoopbackAddress("2001:db8::5,::1"))
+	assert.Equal(t, "", LoopbackAddress("10.0.0.5"))
+}
+
+func TestValidate_AuthNeedsLoopback(t *testing.T) {
+	topo := &Topology{
+		Global:   GlobalConfig{User: "mongo", DeployDir: "/opt/mongodb"},
+		Security: &SecurityConfig{Auth: true},
+		Mongod: []MongodNode{
+			{Host: "db1", Port: 27017, BindIP: "10.0.0.5"},
+			{Host: "db2", Port: 27017, BindIP: "10.0.0.6,127.0.0.1"},
+		},
+	}
+	problems := topo.validationProblems()
+	require.Len(t, problems, 1)
+	assert.Equal(t, "mongod_servers[0].bind_ip", problems[0].Path)
+	assert.Contains(t, problems.Error(), "loopback")
+
+	topo.Security.Auth = false
+	assert.Empty(t, topo.validationProblems())
+}
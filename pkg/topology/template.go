@@ -0,0 +1,271 @@
+package topology
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Starter topology types accepted by GenerateTemplate
+const (
+	TemplateStandalone = "standalone"
+	TemplateReplicaSet = "replica-set"
+	TemplateSharded    = "sharded"
+)
+
+// DefaultRemoteBasePort is the first mongod port on each host of a remote
+// starter topology; mongos and config servers follow PortAllocator offsets
+const DefaultRemoteBasePort = 27017
+
+// TemplateOptions describes a starter topology
+type TemplateOptions struct {
+	Type           string   // standalone, replica-set or sharded
+	Shards         int      // Shard replica sets (sharded only)
+	Replicas       int      // Members per replica set
+	Mongos         int      // mongos routers (sharded only)
+	ConfigReplicas int      // Config server replica set members (sharded only)
+	Hosts          []string // Hosts to spread nodes across
+	Local          bool     // Everything on localhost with local paths
+	BasePort       int      // First port; 0 uses DefaultBasePort locally and DefaultRemoteBasePort otherwise
+
+	RuntimeConfig bool // Include a documented runtime_config section
+	Monitoring    bool // Include documentation of the monitoring stack
+}
+
+// templateNode is one node of a starter topology
+type templateNode struct {
+	host, replicaSet string
+	port             int
+}
+
+// GenerateTemplate returns a commented starter topology YAML. Replica set
+// members rotate across hosts so each replica set's first member, and with
+// it the likely primary, lands on a different host. Ports come from a
+// PortAllocator per host. The result is checked with CheckTopology.
+func GenerateTemplate(opts TemplateOptions) ([]byte, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+
+	allocators := make(map[string]*PortAllocator)
+	counts := make(map[string]int)
+	allocate := func(host, role string) int {
+		pa, ok := allocators[host]
+		if !ok {
+			pa = NewPortAllocator(opts.BasePort)
+			allocators[host] = pa
+		}
+		index := counts[host+"/"+role]
+		counts[host+"/"+role]++
+		var port int
+		switch role {
+		case "mongos":
+			port, _ = pa.AllocateMongosPort(host, index)
+		case "config":
+			port, _ = pa.AllocateConfigSvrPort(host, index)
+		default:
+			port, _ = pa.AllocateMongodPort(host, index)
+		}
+		return port
+	}
+
+	var mongod, mongos, config []templateNode
+	switch opts.Type {
+	case TemplateStandalone:
+		mongod = append(mongod, templateNode{host: opts.Hosts[0], port: allocate(opts.Hosts[0], "mongod")})
+	case TemplateReplicaSet:
+		mongod = opts.replicaSet("rs0", 0, opts.Replicas, "mongod", allocate)
+	case TemplateSharded:
+		config = opts.replicaSet("configRS", 0, opts.ConfigReplicas, "config", allocate)
+		for shard := 0; shard < opts.Shards; shard++ {
+			mongod = append(mongod, opts.replicaSet(fmt.Sprintf("shard%d", shard+1), shard, opts.Replicas, "mongod", allocate)...)
+		}
+		for i := 0; i < opts.Mongos; i++ {
+			host := opts.Hosts[i%len(opts.Hosts)]
+			mongos = append(mongos, templateNode{host: host, port: allocate(host, "mongos")})
+		}
+	}
+
+	var b strings.Builder
+	opts.writeHeader(&b)
+	opts.writeGlobal(&b)
+	writeNodes(&b, "config_servers", "Config server replica set", config)
+	writeNodes(&b, "mongod_servers", opts.mongodTitle(), mongod)
+	writeNodes(&b, "mongos_servers", "Query routers", mongos)
+	if opts.Monitoring {
+		writeMonitoring(&b)
+	}
+
+	out := []byte(b.String())
+	if _, problems := CheckTopology(out); len(problems) > 0 {
+		return nil, fmt.Errorf("generated topology is invalid: %w", problems)
+	}
+	return out, nil
+}
+
+// normalize fills defaults and rejects impossible shapes
+func (o *TemplateOptions) normalize() error {
+	switch o.Type {
+	case "":
+		o.Type = TemplateReplicaSet
+	case TemplateStandalone, TemplateReplicaSet, TemplateSharded:
+	default:
+		return fmt.Errorf("unknown topology type %q (expected %s, %s or %s)", o.Type, TemplateStandalone, TemplateReplicaSet, TemplateSharded)
+	}
+
+	if o.Replicas == 0 {
+		o.Replicas = 3
+	}
+	if o.Shards == 0 {
+		o.Shards = 2
+	}
+	if o.Mongos == 0 {
+		o.Mongos = 1
+	}
+	if o.ConfigReplicas == 0 {
+		o.ConfigReplicas = 3
+	}
+	switch {
+	case o.Replicas < 1 || o.Replicas > MaxVotingMembers:
+		return fmt.Errorf("replicas must be between 1 and %d", MaxVotingMembers)
+	case o.ConfigReplicas < 1 || o.ConfigReplicas > MaxVotingMembers:
+		return fmt.Errorf("config replicas must be between 1 and %d", MaxVotingMembers)
+	case o.Shards < 1:
+		return fmt.Errorf("shards must be at least 1")
+	case o.Mongos < 1:
+		return fmt.Errorf("mongos must be at least 1")
+	}
+
+	if o.Local {
+		if len(o.Hosts) > 0 {
+			return fmt.Errorf("--hosts cannot be combined with --local")
+		}
+		o.Hosts = []string{"localhost"}
+	}
+	if len(o.Hosts) == 0 {
+		return fmt.Errorf("hosts are required unless the topology is local")
+	}
+	seen := make(map[string]bool)
+	for _, host := range o.Hosts {
+		if host == "" || seen[host] {
+			return fmt.Errorf("hosts must be non-empty and unique")
+		}
+		seen[host] = true
+	}
+
+	if o.BasePort == 0 {
+		o.BasePort = DefaultRemoteBasePort
+		if o.Local {
+			o.BasePort = DefaultBasePort
+		}
+	}
+	return nil
+}
+
+// replicaSet places size members of a replica set, starting at host offset
+func (o *TemplateOptions) replicaSet(name string, offset, size int, role string, allocate func(host, role string) int) []templateNode {
+	nodes := make([]templateNode, 0, size)
+	for member := 0; member < size; member++ {
+		host := o.Hosts[(offset+member)%len(o.Hosts)]
+		nodes = append(nodes, templateNode{host: host, replicaSet: name, port: allocate(host, role)})
+	}
+	return nodes
+}
+
+func (o *TemplateOptions) mongodTitle() string {
+	switch o.Type {
+	case TemplateStandalone:
+		return "Standalone mongod"
+	case TemplateSharded:
+		return fmt.Sprintf("Shard replica sets (%d x %d members)", o.Shards, o.Replicas)
+	default:
+		return fmt.Sprintf("Replica set rs0 (%d members)", o.Replicas)
+	}
+}
+
+func (o *TemplateOptions) writeHeader(b *strings.Builder) {
+	fmt.Fprintf(b, "# Starter %s topology generated by mup cluster template\n", o.Type)
+	fmt.Fprintf(b, "# Hosts: %s\n", strings.Join(o.Hosts, ", "))
+	fmt.Fprintf(b, "# Ports per host: mongod from %d, mongos from %d, config servers from %d\n",
+		o.BasePort+MongodPortOffset, o.BasePort+MongosPortOffset, o.BasePort+ConfigSvrPortOffset)
+	b.WriteString("#\n")
+	b.WriteString("# Review it, then check and deploy it with:\n")
+	b.WriteString("#   mup topology validate <file>\n")
+	b.WriteString("#   mup cluster deploy <cluster-name> <file> --version 7.0 --plan-only\n")
+
+	var notes []string
+	if o.Type != TemplateStandalone && o.Replicas%2 == 0 {
+		notes = append(notes, "An even number of replicas can leave elections without a majority; consider an odd count or an arbiter.")
+	}
+	if !o.Local && o.Type != TemplateStandalone && o.Replicas > len(o.Hosts) {
+		notes = append(notes, fmt.Sprintf("%d replicas on %d hosts puts several members of a replica set on one host; losing that host can lose the majority.", o.Replicas, len(o.Hosts)))
+	}
+	for _, note := range notes {
+		fmt.Fprintf(b, "#\n# NOTE: %s\n", note)
+	}
+	b.WriteString("\n")
+}
+
+func (o *TemplateOptions) writeGlobal(b *strings.Builder) {
+	b.WriteString("global:\n")
+	b.WriteString("  user: mongodb            # SSH user and owner of MongoDB processes\n")
+	if o.Local {
+		b.WriteString("  deploy_dir: mongodb      # Relative paths live under the cluster directory\n")
+		b.WriteString("  data_dir: data\n")
+		b.WriteString("  log_dir: log\n")
+		b.WriteString("  config_dir: etc\n")
+	} else {
+		b.WriteString("  ssh_port: 22\n")
+		b.WriteString("  # ssh_key_file: /home/ops/.ssh/id_ed25519  # Default: keys from the SSH agent\n")
+		b.WriteString("  deploy_dir: /opt/mongodb\n")
+		b.WriteString("  data_dir: /data/mongodb\n")
+		b.WriteString("  log_dir: /var/log/mongodb\n")
+		b.WriteString("  config_dir: /etc/mongodb\n")
+	}
+
+	if o.RuntimeConfig {
+		b.WriteString("\n")
+		b.WriteString("  # Settings applied to every generated mongod/mongos config; keys that do\n")
+		b.WriteString("  # not apply to a process type are skipped. Nodes may override them with\n")
+		b.WriteString("  # their own runtime_config.\n")
+		b.WriteString("  runtime_config:\n")
+		b.WriteString("    # Cap the WiredTiger cache when several mongod share a host\n")
+		b.WriteString("    storage.wiredTiger.engineConfig.cacheSizeGB: 1\n")
+		b.WriteString("    storage.wiredTiger.collectionConfig.blockCompressor: snappy  # snappy, zlib, zstd or none\n")
+		b.WriteString("    net.maxIncomingConnections: 20000\n")
+		b.WriteString("    # setParameter.* keys are passed through to setParameter\n")
+		b.WriteString("    # setParameter.diagnosticDataCollectionEnabled: true\n")
+	}
+	b.WriteString("\n")
+}
+
+func writeNodes(b *strings.Builder, key, title string, nodes []templateNode) {
+	if len(nodes) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "# %s\n", title)
+	fmt.Fprintf(b, "%s:\n", key)
+	for i, node := range nodes {
+		if i > 0 && node.replicaSet != nodes[i-1].replicaSet {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "  - host: %s\n", node.host)
+		fmt.Fprintf(b, "    port: %d\n", node.port)
+		if node.replicaSet != "" {
+			fmt.Fprintf(b, "    replica_set: %s\n", node.replicaSet)
+		}
+	}
+	b.WriteString("\n")
+}
+
+func writeMonitoring(b *strings.Builder) {
+	b.WriteString(`# Monitoring
+#
+# Monitoring is deployed with the cluster by default and is not configured
+# in this file. Pass --no-monitoring to mup cluster deploy to skip it.
+# It runs, with default ports:
+#   - Victoria Metrics (8428): time-series storage, 30d retention
+#   - Grafana (3000): dashboards for MongoDB overview and replication
+#   - node_exporter (9100): OS and hardware metrics on every host
+#   - mongodb_exporter (9216+): one per MongoDB process
+`)
+}
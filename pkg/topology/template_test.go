@@ -0,0 +1,68 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateTemplate_Sharded(t *testing.T) {
+	out, err := GenerateTemplate(TemplateOptions{
+		Type:          TemplateSharded,
+		Shards:        3,
+		Replicas:      3,
+		Mongos:        2,
+		Hosts:         []string{"db1", "db2", "db3"},
+		RuntimeConfig: true,
+		Monitoring:    true,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "runtime_config:")
+	assert.Contains(t, string(out), "# Monitoring")
+
+	topo, problems := CheckTopology(out)
+	require.Empty(t, problems)
+	assert.Equal(t, "sharded", topo.GetTopologyType())
+	assert.Len(t, topo.ConfigSvr, 3)
+	assert.Len(t, topo.Mongos, 2)
+	require.Len(t, topo.Mongod, 9)
+
+	// Each shard starts on a different host, and ports count up per host
+	assert.Equal(t, "db1", topo.Mongod[0].Host)
+	assert.Equal(t, "db2", topo.Mongod[3].Host)
+	assert.Equal(t, "db3", topo.Mongod[6].Host)
+	assert.Equal(t, 27017, topo.Mongod[0].Port)
+	assert.Equal(t, 27018, topo.Mongod[3].Port)
+	assert.Equal(t, 29017, topo.ConfigSvr[0].Port)
+	assert.Equal(t, 28017, topo.Mongos[0].Port)
+}
+
+func TestGenerateTemplate_Local(t *testing.T) {
+	out, err := GenerateTemplate(TemplateOptions{Type: TemplateReplicaSet, Local: true, Replicas: 4})
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "NOTE: An even number of replicas")
+
+	topo, problems := CheckTopology(out)
+	require.Empty(t, problems)
+	require.Len(t, topo.Mongod, 4)
+	for i, node := range topo.Mongod {
+		assert.Equal(t, "localhost", node.Host)
+		assert.Equal(t, DefaultBasePort+i, node.Port)
+		assert.Equal(t, "rs0", node.ReplicaSet)
+	}
+}
+
+func TestGenerateTemplate_Errors(t *testing.T) {
+	_, err := GenerateTemplate(TemplateOptions{Type: "cluster", Local: true})
+	assert.ErrorContains(t, err, "unknown topology type")
+
+	_, err = GenerateTemplate(TemplateOptions{Type: TemplateReplicaSet})
+	assert.ErrorContains(t, err, "hosts are required")
+
+	_, err = GenerateTemplate(TemplateOptions{Type: TemplateReplicaSet, Local: true, Hosts: []string{"db1"}})
+	assert.ErrorContains(t, err, "cannot be combined")
+
+	_, err = GenerateTemplate(TemplateOptions{Type: TemplateReplicaSet, Local: true, Replicas: 9})
+	assert.ErrorContains(t, err, "replicas must be between 1 and 7")
+}
@@ -4,9 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
-
-	"gopkg.in/yaml.v3"
 )
 
 // Topology represents the complete cluster topology
@@ -16,12 +13,22 @@ type Topology struct {
 	Mongos      []MongosNode     `yaml:"mongos_servers,omitempty"`
 	ConfigSvr   []ConfigNode     `yaml:"config_servers,omitempty"`
 	ReplicaSets []ReplicaSetSpec `yaml:"replica_sets,omitempty"`
+	Security    *SecurityConfig  `yaml:"security,omitempty"`
+	Percona     *PerconaConfig   `yaml:"percona,omitempty"`
+
+	// Authoring helpers, consumed by ParseTopology and empty afterwards
+	Vars           map[string]string `yaml:"vars,omitempty"`
+	ShardTemplates []ShardTemplate   `yaml:"shard_templates,omitempty"`
+
+	// sources maps expanded node paths to the source entries they came from
+	sources map[string]string
 }
 
 // GlobalConfig contains global configuration for all nodes
 type GlobalConfig struct {
 	User            string            `yaml:"user"`
 	SSHPort         int               `yaml:"ssh_port"`
+	SSHKeyFile      string            `yaml:"ssh_key_file,omitempty"` // Private key for user, tried before the SSH agent
 	DeployDir       string            `yaml:"deploy_dir"`
 	DataDir         string            `yaml:"data_dir"`
 	LogDir          string            `yaml:"log_dir"`
@@ -41,24 +48,32 @@ type ResourceControl struct {
 
 // MongodNode represents a mongod server configuration
 type MongodNode struct {
-	Host       string `yaml:"host"`
-	Port       int    `yaml:"port"`
-	ReplicaSet string `yaml:"replica_set,omitempty"`
-	//Role          string            `yaml:"role,omitempty"` // "primary", "secondary", "arbiter"
-	Priority      *float64       `yaml:"priority,omitempty"`
-	Hidden        *bool          `yaml:"hidden,omitempty"`
-	Votes         *int           `yaml:"votes,omitempty"`
-	DeployDir     string         `yaml:"deploy_dir,omitempty"`
-	DataDir       string         `yaml:"data_dir,omitempty"`
-	LogDir        string         `yaml:"log_dir,omitempty"`
-	ConfigDir     string         `yaml:"config_dir,omitempty"`
-	RuntimeConfig map[string]any `yaml:"runtime_config,omitempty"`
+	Host          string `yaml:"host"`
+	Port          int    `yaml:"port"`
+	AdvertiseHost string `yaml:"advertise_host,omitempty"` // Address members and clients use, default host
+	BindIP        string `yaml:"bind_ip,omitempty"`        // net.bindIp, see BindAddresses for the default
+	ReplicaSet    string `yaml:"replica_set,omitempty"`
+	// Replica set member options, see ValidateReplicaSetMembers for the rules
+	Priority           *float64          `yaml:"priority,omitempty"`
+	Hidden             *bool             `yaml:"hidden,omitempty"`
+	Votes              *int              `yaml:"votes,omitempty"`
+	ArbiterOnly        bool              `yaml:"arbiter_only,omitempty"`
+	SecondaryDelaySecs int               `yaml:"secondary_delay_secs,omitempty"` // slaveDelay before MongoDB 5.0
+	BuildIndexes       *bool             `yaml:"build_indexes,omitempty"`
+	Tags               map[string]string `yaml:"tags,omitempty"`
+	DeployDir          string            `yaml:"deploy_dir,omitempty"`
+	DataDir            string            `yaml:"data_dir,omitempty"`
+	LogDir             string            `yaml:"log_dir,omitempty"`
+	ConfigDir          string            `yaml:"config_dir,omitempty"`
+	RuntimeConfig      map[string]any    `yaml:"runtime_config,omitempty"`
 }
 
 // MongosNode represents a mongos router configuration
 type MongosNode struct {
 	Host          string         `yaml:"host"`
 	Port          int            `yaml:"port"`
+	AdvertiseHost string         `yaml:"advertise_host,omitempty"` // Address clients use, default host
+	BindIP        string         `yaml:"bind_ip,omitempty"`        // net.bindIp, see BindAddresses for the default
 	DeployDir     string         `yaml:"deploy_dir,omitempty"`
 	LogDir        string         `yaml:"log_dir,omitempty"`
 	ConfigDir     string         `yaml:"config_dir,omitempty"`
@@ -69,6 +84,8 @@ type MongosNode struct {
 type ConfigNode struct {
 	Host          string         `yaml:"host"`
 	Port          int            `yaml:"port"`
+	AdvertiseHost string         `yaml:"advertise_host,omitempty"` // Address members and routers use, default host
+	BindIP        string         `yaml:"bind_ip,omitempty"`        // net.bindIp, see BindAddresses for the default
 	ReplicaSet    string         `yaml:"replica_set"`
 	DeployDir     string         `yaml:"deploy_dir,omitempty"`
 	DataDir       string         `yaml:"data_dir,omitempty"`
@@ -77,10 +94,36 @@ type ConfigNode struct {
 	RuntimeConfig map[string]any `yaml:"runtime_config,omitempty"`
 }
 
-// ReplicaSetSpec defines a replica set configuration
+// ReplicaSetSpec defines replica-set-level settings. Membership normally
+// comes from each node's replica_set field; nodes listed in Members that
+// have no replica_set are assigned to this one.
 type ReplicaSetSpec struct {
 	Name    string   `yaml:"name"`
-	Members []string `yaml:"members"` // host:port format
+	Members []string `yaml:"members,omitempty"` // host:port format
+
+	ProtocolVersion                    int                 `yaml:"protocol_version,omitempty"`
+	WriteConcernMajorityJournalDefault *bool               `yaml:"write_concern_majority_journal_default,omitempty"`
+	Settings                           *ReplicaSetSettings `yaml:"settings,omitempty"`
+
+	// Cluster-wide defaults applied with setDefaultRWConcern (MongoDB 4.4+)
+	DefaultReadConcern  string        `yaml:"default_read_concern,omitempty"` // "local", "available" or "majority"
+	DefaultWriteConcern *WriteConcern `yaml:"default_write_concern,omitempty"`
+}
+
+// ReplicaSetSettings maps to the settings document of a replica set config
+type ReplicaSetSettings struct {
+	ChainingAllowed         *bool `yaml:"chaining_allowed,omitempty"`
+	HeartbeatIntervalMillis int   `yaml:"heartbeat_interval_millis,omitempty"`
+	HeartbeatTimeoutSecs    int   `yaml:"heartbeat_timeout_secs,omitempty"`
+	ElectionTimeoutMillis   int   `yaml:"election_timeout_millis,omitempty"`
+	CatchUpTimeoutMillis    *int  `yaml:"catch_up_timeout_millis,omitempty"` // -1 means no limit
+}
+
+// WriteConcern is a default write concern. W is a member count or "majority".
+type WriteConcern struct {
+	W        string `yaml:"w,omitempty"`
+	J        *bool  `yaml:"j,omitempty"`
+	WTimeout int    `yaml:"wtimeout,omitempty"` // Milliseconds
 }
 
 // ParseTopologyFile parses a topology YAML file
@@ -90,26 +133,19 @@ func ParseTopologyFile(path string) (*Topology, error) {
 		return nil, fmt.Errorf("failed to read topology file: %w", err)
 	}
 
-	var topology Topology
-	if err := yaml.Unmarshal(data, &topology); err != nil {
-		return nil, fmt.Errorf("failed to parse topology YAML: %w", err)
-	}
-
-	// Apply global defaults to nodes
-	if err := topology.applyDefaults(); err != nil {
-		return nil, fmt.Errorf("failed to apply defaults: %w", err)
-	}
-
-	return &topology, nil
+	return ParseTopology(data)
 }
 
 // applyDefaults applies global configuration to individual nodes
 //
 //nolint:unparam
 func (t *Topology) applyDefaults() error {
+	t.applyReplicaSetMembership()
+
 	// Apply defaults to mongod nodes
 	for i := range t.Mongod {
 		node := &t.Mongod[i]
+		node.Host, node.AdvertiseHost = TrimHost(node.Host), TrimHost(node.AdvertiseHost)
 		if node.DeployDir == "" {
 			node.DeployDir = t.Global.DeployDir
 		}
@@ -136,6 +172,7 @@ func (t *Topology) applyDefaults() error {
 	// Apply defaults to mongos nodes
 	for i := range t.Mongos {
 		node := &t.Mongos[i]
+		node.Host, node.AdvertiseHost = TrimHost(node.Host), TrimHost(node.AdvertiseHost)
 		if node.DeployDir == "" {
 			node.DeployDir = t.Global.DeployDir
 		}
@@ -159,6 +196,7 @@ func (t *Topology) applyDefaults() error {
 	// Apply defaults to config server nodes
 	for i := range t.ConfigSvr {
 		node := &t.ConfigSvr[i]
+		node.Host, node.AdvertiseHost = TrimHost(node.Host), TrimHost(node.AdvertiseHost)
 		if node.DeployDir == "" {
 			node.DeployDir = t.Global.DeployDir
 		}
@@ -185,19 +223,34 @@ func (t *Topology) applyDefaults() error {
 	return nil
 }
 
-// Validate validates the topology configuration
+// Validate validates the topology configuration. The error is a Problems
+// listing everything wrong, not just the first problem found.
 func (t *Topology) Validate() error {
+	if problems := t.validationProblems(); len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// validationProblems checks the topology. Each problem's Path names the
+// node in the source YAML (see sourcePath) so callers can add positions.
+func (t *Topology) validationProblems() Problems {
+	var problems Problems
+	add := func(path, format string, args ...any) {
+		problems = append(problems, Problem{Path: t.sourcePath(path), Message: fmt.Sprintf(format, args...)})
+	}
+
 	// Check that we have at least some nodes
 	if len(t.Mongod) == 0 && len(t.Mongos) == 0 && len(t.ConfigSvr) == 0 {
-		return fmt.Errorf("topology must contain at least one node")
+		add("", "topology must contain at least one node")
 	}
 
 	// Validate global config
 	if t.Global.User == "" {
-		return fmt.Errorf("global.user is required")
+		add("global.user", "global.user is required")
 	}
 	if t.Global.DeployDir == "" {
-		return fmt.Errorf("global.deploy_dir is required")
+		add("global.deploy_dir", "global.deploy_dir is required")
 	}
 	if t.Global.DataDir == "" {
 		t.Global.DataDir = filepath.Join(t.Global.DeployDir, "data")
@@ -212,67 +265,59 @@ func (t *Topology) Validate() error {
 	// Check if this is a local deployment (allows port 0 for auto-allocation)
 	isLocal := t.IsLocalDeployment()
 
-	// Validate mongod nodes
+	// Port 0 is allowed for local deployments (means auto-allocate); ports
+	// must not conflict on the same host
 	seenPorts := make(map[string]bool)
-	for _, node := range t.Mongod {
-		if node.Host == "" {
-			return fmt.Errorf("mongod node missing host")
-		}
-		// Port 0 is allowed for local deployments (means auto-allocate)
-		if node.Port == 0 && !isLocal {
-			return fmt.Errorf("mongod node %s missing port", node.Host)
-		}
-
-		// Check for port conflicts on the same host (skip if port is 0)
-		if node.Port > 0 {
-			key := fmt.Sprintf("%s:%d", node.Host, node.Port)
+	checkNode := func(path, role, host string, port int, advertise, bindIP string) {
+		switch {
+		case host == "":
+			add(path+".host", "%s node missing host", role)
+		case port == 0 && !isLocal:
+			add(path+".port", "%s node %s missing port", role, host)
+		case port < 0 || port > 65535:
+			add(path+".port", "%s node %s port %d is out of range", role, host, port)
+		case port > 0:
+			key := GetNodeID(host, port)
 			if seenPorts[key] {
-				return fmt.Errorf("duplicate port %d on host %s", node.Port, node.Host)
+				add(path+".port", "duplicate port %d on host %s", port, host)
 			}
 			seenPorts[key] = true
 		}
-	}
-
-	// Validate mongos nodes
-	for _, node := range t.Mongos {
-		if node.Host == "" {
-			return fmt.Errorf("mongos node missing host")
-		}
-		if node.Port == 0 && !isLocal {
-			return fmt.Errorf("mongos node %s missing port", node.Host)
+		if err := validateAddressing(host, advertise, bindIP); err != nil {
+			add(path, "%s node %s: %s", role, host, err)
 		}
-
-		if node.Port > 0 {
-			key := fmt.Sprintf("%s:%d", node.Host, node.Port)
-			if seenPorts[key] {
-				return fmt.Errorf("duplicate port %d on host %s", node.Port, node.Host)
-			}
-			seenPorts[key] = true
+		if t.AuthEnabled() && bindIP != "" && LoopbackAddress(bindIP) == "" {
+			add(path+".bind_ip", "%s node %s: bind_ip must include a loopback address when security.auth is enabled", role, host)
 		}
 	}
 
-	// Validate config server nodes
-	for _, node := range t.ConfigSvr {
-		if node.Host == "" {
-			return fmt.Errorf("config server node missing host")
-		}
-		if node.Port == 0 && !isLocal {
-			return fmt.Errorf("config server node %s missing port", node.Host)
-		}
+	for i, node := range t.Mongod {
+		checkNode(fmt.Sprintf("mongod_servers[%d]", i), "mongod", node.Host, node.Port, node.AdvertiseHost, node.BindIP)
+	}
+	for i, node := range t.Mongos {
+		checkNode(fmt.Sprintf("mongos_servers[%d]", i), "mongos", node.Host, node.Port, node.AdvertiseHost, node.BindIP)
+	}
+	for i, node := range t.ConfigSvr {
+		path := fmt.Sprintf("config_servers[%d]", i)
+		checkNode(path, "config server", node.Host, node.Port, node.AdvertiseHost, node.BindIP)
 		if node.ReplicaSet == "" {
-			return fmt.Errorf("config server node %s missing replica_set", node.Host)
-		}
-
-		if node.Port > 0 {
-			key := fmt.Sprintf("%s:%d", node.Host, node.Port)
-			if seenPorts[key] {
-				return fmt.Errorf("duplicate port %d on host %s", node.Port, node.Host)
-			}
-			seenPorts[key] = true
+			add(path, "config server node %s missing replica_set", node.Host)
 		}
 	}
 
-	return nil
+	if err := t.ValidateReplicaSetSpecs(); err != nil {
+		add("replica_sets", "%s", err)
+	}
+	if path, err := t.Security.validate(); err != nil {
+		add(path, "%s", err)
+	}
+	if path, err := t.Percona.validate(); err != nil {
+		add(path, "%s", err)
+	}
+	if err := t.ValidateReplicaSetMembers(); err != nil {
+		add("mongod_servers", "%s", err)
+	}
+	return problems
 }
 
 // GetTopologyType returns the type of topology (standalone, replica_set, sharded)
@@ -293,9 +338,7 @@ func (t *Topology) GetTopologyType() string {
 
 // IsLocalDeployment returns true if all hosts are localhost
 func (t *Topology) IsLocalDeployment() bool {
-	isLocal := func(host string) bool {
-		return host == "localhost" || host == "127.0.0.1" || host == "::1"
-	}
+	isLocal := IsLocalHost
 
 	for _, node := range t.Mongod {
 		if !isLocal(node.Host) {
@@ -339,24 +382,3 @@ func (t *Topology) GetAllHosts() []string {
 
 	return hosts
 }
-
-// GetNodeID returns a unique identifier for a node
-func GetNodeID(host string, port int) string {
-	return fmt.Sprintf("%s:%d", host, port)
-}
-
-// ParseNodeID parses a node ID into host and port
-func ParseNodeID(nodeID string) (string, int, error) {
-	parts := strings.Split(nodeID, ":")
-	if len(parts) != 2 {
-		return "", 0, fmt.Errorf("invalid node ID format: %s", nodeID)
-	}
-
-	port := 0
-	_, err := fmt.Sscanf(parts[1], "%d", &port)
-	if err != nil {
-		return "", 0, fmt.Errorf("invalid port in node ID: %s", nodeID)
-	}
-
-	return parts[0], port, nil
-}
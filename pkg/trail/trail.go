@@ -0,0 +1,172 @@
+// Package trail keeps the audit trail of mup commands: one JSON record per
+// line, appended to ~/.mup/audit.log for every command that changes a
+// cluster.
+package trail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileName is the audit trail's file name, in ~/.mup and, when enabled, in
+// each cluster's directory
+const FileName = "audit.log"
+
+// Environment variables configuring the audit trail
+const (
+	// PathEnv overrides the audit trail path; "off" disables it
+	PathEnv = "MUP_AUDIT_LOG"
+
+	// ClusterLogEnv set to true also appends records to the cluster's
+	// directory
+	ClusterLogEnv = "MUP_AUDIT_CLUSTER_LOG"
+)
+
+// Outcome is how a command ended
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Record is one command in the audit trail
+type Record struct {
+	Identity string    `json:"identity"` // user@hostname:pid
+	Command  string    `json:"command"`  // Command path, such as "mup cluster destroy"
+	Args     []string  `json:"args"`     // Command line, without the program name
+	Cluster  string    `json:"cluster,omitempty"`
+	PlanID   string    `json:"plan_id,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Outcome  Outcome   `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Duration returns how long the command ran
+func (r *Record) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// DefaultPath returns the audit trail path: $MUP_AUDIT_LOG or
+// ~/.mup/audit.log. It returns "" when the trail is turned off.
+func DefaultPath() (string, error) {
+	if path := os.Getenv(PathEnv); path != "" {
+		if path == "off" {
+			return "", nil
+		}
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".mup", FileName), nil
+}
+
+// ClusterLogEnabled reports whether records are also appended to the
+// cluster's directory
+func ClusterLogEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(ClusterLogEnv))
+	return enabled
+}
+
+// Append adds rec to the audit trail at path, creating it readable by the
+// owner only. Each record is a single write so concurrent mup processes do
+// not interleave lines.
+func Append(path string, rec *Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Filter selects records
+type Filter struct {
+	Cluster string    // Only this cluster's records, when set
+	Since   time.Time // Only records started at or after this time, when set
+}
+
+// matches reports whether f selects rec
+func (f Filter) matches(rec *Record) bool {
+	if f.Cluster != "" && rec.Cluster != f.Cluster {
+		return false
+	}
+	return f.Since.IsZero() || !rec.Start.Before(f.Since)
+}
+
+// Read returns the records at path that f selects, oldest first. A
+// missing audit trail has no records.
+func Read(path string, f Filter) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	var records []Record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid audit record: %w", path, lineNum, err)
+		}
+		if f.matches(&rec) {
+			records = append(records, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return records, nil
+}
+
+// ParseSince parses a --since value relative to now: a duration such as
+// 7d, 12h or 30m, or a date (2006-01-02) or RFC 3339 time
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (expected a duration such as 7d or 12h, or a date such as 2006-01-02)", s)
+}
@@ -0,0 +1,85 @@
+package trail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mup", FileName)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	records := []Record{
+		{Identity: "ops@admin1:100", Command: "mup cluster deploy", Args: []string{"cluster", "deploy", "prod", "prod.yaml"}, Cluster: "prod", PlanID: "plan-1", Start: start, End: start.Add(time.Minute), Outcome: OutcomeSuccess},
+		{Identity: "ops@admin1:200", Command: "mup cluster stop", Args: []string{"cluster", "stop", "dev"}, Cluster: "dev", Start: start.Add(time.Hour), End: start.Add(time.Hour), Outcome: OutcomeSuccess},
+		{Identity: "ops@admin1:300", Command: "mup cluster destroy", Args: []string{"cluster", "destroy", "prod", "--yes"}, Cluster: "prod", Start: start.Add(48 * time.Hour), End: start.Add(48 * time.Hour), Outcome: OutcomeFailure, Error: "boom"},
+	}
+	for i := range records {
+		require.NoError(t, Append(path, &records[i]))
+	}
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	all, err := Read(path, Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, records[0].Args, all[0].Args)
+	assert.Equal(t, time.Minute, all[0].Duration())
+
+	prod, err := Read(path, Filter{Cluster: "prod", Since: start.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, prod, 1)
+	assert.Equal(t, "boom", prod[0].Error)
+
+	missing, err := Read(filepath.Join(t.TempDir(), FileName), Filter{})
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	require.NoError(t, os.WriteFile(path, []byte("{\n"), 0600))
+	_, err = Read(path, Filter{})
+	assert.ErrorContains(t, err, ":1: invalid audit record")
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv(PathEnv, "/var/log/mup.log")
+	path, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, "/var/log/mup.log", path)
+
+	t.Setenv(PathEnv, "off")
+	path, err = DefaultPath()
+	require.NoError(t, err)
+	assert.Empty(t, path, "turned off")
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"7d", now.AddDate(0, 0, -7)},
+		{"12h", now.Add(-12 * time.Hour)},
+		{"30m", now.Add(-30 * time.Minute)},
+		{"2026-10-01", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"2026-10-01T08:00:00Z", time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseSince(tt.in, now)
+		require.NoError(t, err, tt.in)
+		assert.True(t, tt.want.Equal(got), "%s: got %s", tt.in, got)
+	}
+
+	_, err := ParseSince("last week", now)
+	assert.Error(t, err)
+	_, err = ParseSince("-1d", now)
+	assert.Error(t, err)
+}
@@ -12,7 +12,6 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/zph/mup/pkg/deploy"
 	"github.com/zph/mup/pkg/meta"
@@ -212,7 +211,7 @@ func (lu *LocalUpgrader) ValidatePrerequisites(ctx context.Context) error {
 		Arch: "arm64",  // TODO: Get from runtime
 	}
 
-	binPath, err := lu.binaryMgr.GetBinPathWithVariant(lu.config.ToVersion, variant, platform)
+	binPath, err := lu.binaryMgr.GetBinPathWithVariantContext(ctx, lu.config.ToVersion, variant, platform)
 	if err != nil {
 		fmt.Println("✗")
 		return fmt.Errorf("failed to get binaries: %w", err)
@@ -348,7 +347,7 @@ func (lu *LocalUpgrader) UpgradeMongos(ctx context.Context) error {
 
 	// Mongos can be upgraded in any order (stateless routers)
 	for _, node := range lu.config.Topology.Mongos {
-		hostPort := fmt.Sprintf("%s:%d", node.Host, node.Port)
+		hostPort := topology.GetNodeID(node.Host, node.Port)
 
 		// Prompt if needed
 		if lu.config.PromptLevel == PromptLevelNode {
@@ -540,7 +539,7 @@ func (lu *LocalUpgrader) cleanupNextSymlink() {
 
 // setupVersionDirectories creates the new version directory structure
 // [UPG-006] Per-version directory management with symlinks
-func (lu *LocalUpgrader) setupVersionDirectories(_ context.Context) error {
+func (lu *LocalUpgrader) setupVersionDirectories(ctx context.Context) error {
 	fmt.Println("\n=== Setting Up Version Directories ===")
 
 	// 1. Create version directory structure
@@ -572,7 +571,7 @@ func (lu *LocalUpgrader) setupVersionDirectories(_ context.Context) error {
 	}
 
 	// Download binaries (this caches them)
-	binPath, err := lu.binaryMgr.GetBinPathWithVariant(lu.config.ToVersion, lu.config.TargetVariant, platform)
+	binPath, err := lu.binaryMgr.GetBinPathWithVariantContext(ctx, lu.config.ToVersion, lu.config.TargetVariant, platform)
 	if err != nil {
 		return fmt.Errorf("failed to download binaries: %w", err)
 	}
@@ -813,7 +812,7 @@ func (lu *LocalUpgrader) upgradeReplicaSet(ctx context.Context, nodes []topology
 	rsName := nodes[0].ReplicaSet
 	var allHosts []string
 	for _, node := range nodes {
-		allHosts = append(allHosts, fmt.Sprintf("%s:%d", node.Host, node.Port))
+		allHosts = append(allHosts, topology.GetNodeID(node.Host, node.Port))
 	}
 
 	fmt.Printf("\n  Detecting node roles in replica set '%s'...\n", rsName)
@@ -827,7 +826,7 @@ func (lu *LocalUpgrader) upgradeReplicaSet(ctx context.Context, nodes []topology
 	var primaryNode *topology.MongodNode
 
 	for _, node := range nodes {
-		hostPort := fmt.Sprintf("%s:%d", node.Host, node.Port)
+		hostPort := topology.GetNodeID(node.Host, node.Port)
 
 		// Skip if already completed
 		if nodeState, exists := lu.state.Nodes[hostPort]; exists && nodeState.Status == NodeStatusCompleted {
@@ -835,7 +834,7 @@ func (lu *LocalUpgrader) upgradeReplicaSet(ctx context.Context, nodes []topology
 			continue
 		}
 
-		role, err := DetectNodeRole(ctx, hostPort, rsName, allHosts)
+		role, err := DetectNodeRole(ctx, hostPort, rsName, allHosts, lu.clusterMeta.Security)
 		if err != nil {
 			fmt.Printf("    ⚠️  Could not detect role for %s: %v (assuming SECONDARY)\n", hostPort, err)
 			role = "SECONDARY"
@@ -874,7 +873,7 @@ func (lu *LocalUpgrader) upgradeReplicaSet(ctx context.Context, nodes []topology
 
 // upgradeReplicaSetNode upgrades a single replica set node with role tracking
 func (lu *LocalUpgrader) upgradeReplicaSetNode(ctx context.Context, node topology.MongodNode, role string, rsName string, allHosts []string, isPrimary bool) error {
-	hostPort := fmt.Sprintf("%s:%d", node.Host, node.Port)
+	hostPort := topology.GetNodeID(node.Host, node.Port)
 
 	// Skip if already completed
 	if nodeState, exists := lu.state.Nodes[hostPort]; exists && nodeState.Status == NodeStatusCompleted {
@@ -922,7 +921,7 @@ func (lu *LocalUpgrader) upgradeReplicaSetNode(ctx context.Context, node topolog
 		}
 
 		fmt.Printf("\n  Initiating primary stepdown for %s...\n", hostPort)
-		failoverEvent, err := StepDownPrimary(ctx, hostPort, rsName, allHosts)
+		failoverEvent, err := StepDownPrimary(ctx, hostPort, rsName, allHosts, lu.clusterMeta.Security)
 		if err != nil {
 			return fmt.Errorf("failed to step down primary: %w", err)
 		}
@@ -1044,7 +1043,7 @@ func (lu *LocalUpgrader) connectToCluster(ctx context.Context) (*mongo.Client, e
 			return nil, fmt.Errorf("no mongos instances found in topology")
 		}
 		mongos := lu.config.Topology.Mongos[0]
-		uri = fmt.Sprintf("mongodb://%s:%d", mongos.Host, mongos.Port)
+		uri = "mongodb://" + topology.GetNodeID(mongos.Host, mongos.Port)
 
 	case "replica_set":
 		// Connect to replica set with replica set name
@@ -1054,7 +1053,7 @@ func (lu *LocalUpgrader) connectToCluster(ctx context.Context) (*mongo.Client, e
 		rsName := lu.config.Topology.Mongod[0].ReplicaSet
 		var hosts []string
 		for _, node := range lu.config.Topology.Mongod {
-			hosts = append(hosts, fmt.Sprintf("%s:%d", node.Host, node.Port))
+			hosts = append(hosts, topology.GetNodeID(node.Host, node.Port))
 		}
 		uri = fmt.Sprintf("mongodb://%s/?replicaSet=%s", strings.Join(hosts, ","), rsName)
 
@@ -1064,18 +1063,18 @@ func (lu *LocalUpgrader) connectToCluster(ctx context.Context) (*mongo.Client, e
 			return nil, fmt.Errorf("no mongod instance found in topology")
 		}
 		node := lu.config.Topology.Mongod[0]
-		uri = fmt.Sprintf("mongodb://%s:%d", node.Host, node.Port)
+		uri = "mongodb://" + topology.GetNodeID(node.Host, node.Port)
 
 	default:
 		return nil, fmt.Errorf("unsupported topology type: %s", topoType)
 	}
 
 	// Create client options
-	clientOpts := options.Client().
-		ApplyURI(uri).
-		SetConnectTimeout(10 * time.Second).
-		SetServerSelectionTimeout(10 * time.Second).
-		SetDirect(topoType == "standalone")
+	clientOpts, err := clientOptions(uri, lu.clusterMeta.Security)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts.SetDirect(topoType == "standalone")
 
 	// Connect to MongoDB
 	client, err := mongo.Connect(ctx, clientOpts)
@@ -191,7 +191,7 @@ func (ops *LocalNodeOperations) PrepareBinaries(ctx context.Context, version str
 	}
 
 	// Download binaries to cache
-	binPath, err := ops.binaryMgr.GetBinPathWithVariant(version, variant, platform)
+	binPath, err := ops.binaryMgr.GetBinPathWithVariantContext(ctx, version, variant, platform)
 	if err != nil {
 		return fmt.Errorf("failed to get binaries: %w", err)
 	}
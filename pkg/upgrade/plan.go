@@ -130,7 +130,7 @@ func (lu *LocalUpgrader) generateShardedClusterPlan(stepNum *int) []PlanPhase {
 		configPhase.Steps = append(configPhase.Steps, PlanStep{
 			Step:        *stepNum,
 			Action:      "upgrade-node",
-			Target:      fmt.Sprintf("%s:%d", node.Host, node.Port),
+			Target:      topology.GetNodeID(node.Host, node.Port),
 			Description: fmt.Sprintf("Stop, replace binary, start config server %s:%d", node.Host, node.Port),
 		})
 		*stepNum++
@@ -152,7 +152,7 @@ func (lu *LocalUpgrader) generateShardedClusterPlan(stepNum *int) []PlanPhase {
 			shardPhase.Steps = append(shardPhase.Steps, PlanStep{
 				Step:        *stepNum,
 				Action:      "upgrade-node",
-				Target:      fmt.Sprintf("%s:%d", node.Host, node.Port),
+				Target:      topology.GetNodeID(node.Host, node.Port),
 				Description: fmt.Sprintf("Stop, replace binary, start shard node %s:%d", node.Host, node.Port),
 			})
 			*stepNum++
@@ -172,7 +172,7 @@ func (lu *LocalUpgrader) generateShardedClusterPlan(stepNum *int) []PlanPhase {
 		mongosPhase.Steps = append(mongosPhase.Steps, PlanStep{
 			Step:        *stepNum,
 			Action:      "upgrade-node",
-			Target:      fmt.Sprintf("%s:%d", node.Host, node.Port),
+			Target:      topology.GetNodeID(node.Host, node.Port),
 			Description: fmt.Sprintf("Stop, replace binary, start mongos %s:%d", node.Host, node.Port),
 		})
 		*stepNum++
@@ -198,7 +198,7 @@ func (lu *LocalUpgrader) generateReplicaSetPlan(stepNum *int) []PlanPhase {
 		rsPhase.Steps = append(rsPhase.Steps, PlanStep{
 			Step:        *stepNum,
 			Action:      "upgrade-node",
-			Target:      fmt.Sprintf("%s:%d", node.Host, node.Port),
+			Target:      topology.GetNodeID(node.Host, node.Port),
 			Description: fmt.Sprintf("Stop, replace binary, start node %s:%d (runtime order: secondaries first)", node.Host, node.Port),
 		})
 		*stepNum++
@@ -223,7 +223,7 @@ func (lu *LocalUpgrader) generateStandalonePlan(stepNum *int) []PlanPhase {
 	standalonePhase.Steps = append(standalonePhase.Steps, PlanStep{
 		Step:        *stepNum,
 		Action:      "upgrade-node",
-		Target:      fmt.Sprintf("%s:%d", node.Host, node.Port),
+		Target:      topology.GetNodeID(node.Host, node.Port),
 		Description: fmt.Sprintf("Stop, replace binary, start node %s:%d", node.Host, node.Port),
 		Critical:    true,
 	})
@@ -0,0 +1,152 @@
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zph/mup/pkg/deploy"
+	"github.com/zph/mup/pkg/executor"
+	"github.com/zph/mup/pkg/supervisor"
+	"github.com/zph/mup/pkg/topology"
+)
+
+// supervisorNodeOps implements NodeOperations on a supervisord reached
+// through an executor, so an upgrade can be replayed from a cassette
+type supervisorNodeOps struct {
+	mgr    *supervisor.RemoteManager
+	binDir string
+}
+
+func (o *supervisorNodeOps) StopNode(ctx context.Context, nodeID string) error {
+	return o.mgr.StopProcesses(ctx, []string{nodeID})
+}
+
+func (o *supervisorNodeOps) StartNode(ctx context.Context, nodeID string) error {
+	return o.mgr.StartProcesses(ctx, []string{nodeID})
+}
+
+func (o *supervisorNodeOps) RestartNode(ctx context.Context, nodeID string) error {
+	if err := o.StopNode(ctx, nodeID); err != nil {
+		return err
+	}
+	return o.StartNode(ctx, nodeID)
+}
+
+func (o *supervisorNodeOps) GetNodeStatus(ctx context.Context, nodeID string) (*ProcessStatus, error) {
+	status, err := o.mgr.GetProcessStatus(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return &ProcessStatus{State: status.State, IsHealthy: status.State == "RUNNING", PID: status.PID}, nil
+}
+
+func (o *supervisorNodeOps) WaitForNodeHealthy(ctx context.Context, nodeID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		status, err := o.GetNodeStatus(ctx, nodeID)
+		if err != nil {
+			return err
+		}
+		if status.IsHealthy {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("%s did not start within %v", nodeID, timeout)
+}
+
+func (o *supervisorNodeOps) VerifyNodeVersion(ctx context.Context, nodeID string, expectedVersion string) error {
+	output, err := o.mgr.Executor().ExecuteContext(ctx, filepath.Join(o.binDir, "mongod")+" --version")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(output, "db version v"+expectedVersion) {
+		return fmt.Errorf("%s is not running %s: %s", nodeID, expectedVersion, output)
+	}
+	return nil
+}
+
+func (o *supervisorNodeOps) PrepareBinaries(ctx context.Context, version string, variant deploy.Variant) error {
+	return nil
+}
+
+func (o *supervisorNodeOps) UpdateNodeConfig(ctx context.Context, nodeID string, version string) error {
+	return nil
+}
+
+func (o *supervisorNodeOps) SetupVersionEnvironment(ctx context.Context, version string) error {
+	return nil
+}
+
+func (o *supervisorNodeOps) SwitchToNewVersion(ctx context.Context, oldVersion, newVersion string) error {
+	return nil
+}
+
+func (o *supervisorNodeOps) CleanupOldVersion(ctx context.Context, version string) error {
+	return nil
+}
+
+// [UPG-004, UPG-010] The replica set upgrade plan replayed node by node
+// against a recorded supervisord, without MongoDB present
+func TestReplicaSetUpgradePlan_Replay(t *testing.T) {
+	ctx := context.Background()
+	cassette, err := executor.LoadCassette("testdata/replica_set_upgrade.cassette.json")
+	require.NoError(t, err)
+	replay := executor.NewReplayExecutor(cassette, "localhost")
+
+	versionDir := "/opt/mup/rs/v7.0.12"
+	mgr, err := supervisor.NewRemoteManager(ctx, replay, "localhost", versionDir, filepath.Join(versionDir, "bin"))
+	require.NoError(t, err)
+
+	topo := &topology.Topology{Mongod: []topology.MongodNode{
+		{Host: "localhost", Port: 27017, ReplicaSet: "rs0"},
+		{Host: "localhost", Port: 27018, ReplicaSet: "rs0"},
+		{Host: "localhost", Port: 27019, ReplicaSet: "rs0"},
+	}}
+	stateMgr, err := NewStateManager("rs", t.TempDir())
+	require.NoError(t, err)
+	u := &Upgrader{
+		config: UpgradeConfig{
+			ClusterName:  "rs",
+			FromVersion:  "6.0.15",
+			ToVersion:    "7.0.12",
+			Topology:     topo,
+			StateManager: stateMgr,
+			HookRegistry: NewHookRegistry(),
+		},
+		state:   stateMgr.InitializeState("rs", "mongo-6.0.15", "mongo-7.0.12"),
+		nodeOps: &supervisorNodeOps{mgr: mgr, binDir: filepath.Join(versionDir, "bin")},
+	}
+
+	upgradePlan, err := (&LocalUpgrader{Upgrader: u}).GenerateUpgradePlan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "replica_set", upgradePlan.TopologyType)
+
+	var upgraded []string
+	for _, phase := range upgradePlan.Phases {
+		for _, step := range phase.Steps {
+			if step.Action != "upgrade-node" {
+				continue
+			}
+			var node *topology.MongodNode
+			for i := range topo.Mongod {
+				if topology.GetNodeID(topo.Mongod[i].Host, topo.Mongod[i].Port) == step.Target {
+					node = &topo.Mongod[i]
+				}
+			}
+			require.NotNil(t, node, "plan step targets unknown node %s", step.Target)
+			require.NoError(t, u.upgradeNode(ctx, *node, "SECONDARY"))
+			upgraded = append(upgraded, step.Target)
+		}
+	}
+
+	assert.Equal(t, []string{"localhost:27017", "localhost:27018", "localhost:27019"}, upgraded)
+	assert.Equal(t, 3, u.state.GetCompletedNodeCount())
+	assert.Equal(t, 0, replay.Remaining(), "upgrade should make every recorded call")
+}
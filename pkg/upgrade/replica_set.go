@@ -9,6 +9,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/zph/mup/pkg/meta"
+	"github.com/zph/mup/pkg/topology"
 )
 
 // ReplicaSetMember represents a member of a replica set
@@ -21,17 +24,33 @@ type ReplicaSetMember struct {
 	IsPrimary bool
 }
 
+// clientOptions returns the options for connecting to uri as the cluster's
+// security requires: over TLS and as the admin user when enabled
+func clientOptions(uri string, sec *meta.SecurityMetadata) (*options.ClientOptions, error) {
+	clientOpts := options.Client().
+		ApplyURI(uri).
+		SetConnectTimeout(10 * time.Second).
+		SetServerSelectionTimeout(10 * time.Second)
+	if err := sec.ApplyTLS(clientOpts); err != nil {
+		return nil, err
+	}
+	if err := sec.ApplyAuth(clientOpts); err != nil {
+		return nil, err
+	}
+	return clientOpts, nil
+}
+
 // GetReplicaSetStatus gets the current replica set status
-func GetReplicaSetStatus(ctx context.Context, rsName string, hosts []string) ([]ReplicaSetMember, error) {
+func GetReplicaSetStatus(ctx context.Context, rsName string, hosts []string, sec *meta.SecurityMetadata) ([]ReplicaSetMember, error) {
 	// Connect to replica set
 	var hostsStr []string
 	hostsStr = append(hostsStr, hosts...)
 	uri := fmt.Sprintf("mongodb://%s/?replicaSet=%s", strings.Join(hostsStr, ","), rsName)
 
-	clientOpts := options.Client().
-		ApplyURI(uri).
-		SetConnectTimeout(10 * time.Second).
-		SetServerSelectionTimeout(10 * time.Second)
+	clientOpts, err := clientOptions(uri, sec)
+	if err != nil {
+		return nil, err
+	}
 
 	client, err := mongo.Connect(ctx, clientOpts)
 	if err != nil {
@@ -87,7 +106,7 @@ func GetReplicaSetStatus(ctx context.Context, rsName string, hosts []string) ([]
 }
 
 // StepDownPrimary steps down the primary of a replica set
-func StepDownPrimary(ctx context.Context, primaryHost string, rsName string, allHosts []string) (*FailoverEvent, error) {
+func StepDownPrimary(ctx context.Context, primaryHost string, rsName string, allHosts []string, sec *meta.SecurityMetadata) (*FailoverEvent, error) {
 	fmt.Printf("\n    Initiating primary stepdown for %s\n", primaryHost)
 	fmt.Printf("    This will trigger a controlled failover...\n")
 
@@ -95,11 +114,11 @@ func StepDownPrimary(ctx context.Context, primaryHost string, rsName string, all
 
 	// Connect directly to the primary
 	uri := fmt.Sprintf("mongodb://%s", primaryHost)
-	clientOpts := options.Client().
-		ApplyURI(uri).
-		SetConnectTimeout(10 * time.Second).
-		SetServerSelectionTimeout(10 * time.Second).
-		SetDirect(true)
+	clientOpts, err := clientOptions(uri, sec)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts.SetDirect(true)
 
 	client, err := mongo.Connect(ctx, clientOpts)
 	if err != nil {
@@ -138,11 +157,11 @@ func StepDownPrimary(ctx context.Context, primaryHost string, rsName string, all
 	elapsed := time.Duration(0)
 
 	for elapsed < maxWait {
-		members, err := GetReplicaSetStatus(ctx, rsName, allHosts)
+		members, err := GetReplicaSetStatus(ctx, rsName, allHosts, sec)
 		if err == nil {
 			for _, member := range members {
 				if member.IsPrimary {
-					newPrimary = fmt.Sprintf("%s:%d", member.Host, member.Port)
+					newPrimary = topology.GetNodeID(member.Host, member.Port)
 					break
 				}
 			}
@@ -176,14 +195,14 @@ func StepDownPrimary(ctx context.Context, primaryHost string, rsName string, all
 }
 
 // DetectNodeRole detects the role of a node in a replica set
-func DetectNodeRole(ctx context.Context, hostPort string, rsName string, allHosts []string) (string, error) {
-	members, err := GetReplicaSetStatus(ctx, rsName, allHosts)
+func DetectNodeRole(ctx context.Context, hostPort string, rsName string, allHosts []string, sec *meta.SecurityMetadata) (string, error) {
+	members, err := GetReplicaSetStatus(ctx, rsName, allHosts, sec)
 	if err != nil {
 		return "UNKNOWN", err
 	}
 
 	for _, member := range members {
-		memberHostPort := fmt.Sprintf("%s:%d", member.Host, member.Port)
+		memberHostPort := topology.GetNodeID(member.Host, member.Port)
 		if memberHostPort == hostPort {
 			return member.StateStr, nil
 		}
@@ -191,3 +210,66 @@ func DetectNodeRole(ctx context.Context, hostPort string, rsName string, allHost
 
 	return "UNKNOWN", fmt.Errorf("node %s not found in replica set", hostPort)
 }
+
+// memberPollInterval is how often a restarted node is polled while waiting
+// for it to become healthy
+const memberPollInterval = 2 * time.Second
+
+// WaitForMemberState waits until the member at hostPort reports PRIMARY or
+// SECONDARY, as a restarted member does once it has rejoined its replica set
+func WaitForMemberState(ctx context.Context, hostPort string, rsName string, allHosts []string, sec *meta.SecurityMetadata, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		role, err := DetectNodeRole(ctx, hostPort, rsName, allHosts, sec)
+		if err == nil && (role == "PRIMARY" || role == "SECONDARY") {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("member %s not healthy after %v: %w", hostPort, timeout, err)
+			}
+			return fmt.Errorf("member %s still %s after %v", hostPort, role, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(memberPollInterval):
+		}
+	}
+}
+
+// WaitForNode waits until the node at hostPort answers a ping, for nodes
+// outside a replica set such as mongos
+func WaitForNode(ctx context.Context, hostPort string, sec *meta.SecurityMetadata, timeout time.Duration) error {
+	clientOpts, err := clientOptions(fmt.Sprintf("mongodb://%s", hostPort), sec)
+	if err != nil {
+		return err
+	}
+	clientOpts.SetDirect(true)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := pingNode(ctx, clientOpts)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("node %s not reachable after %v: %w", hostPort, timeout, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(memberPollInterval):
+		}
+	}
+}
+
+// pingNode connects with clientOpts and pings the node
+func pingNode(ctx context.Context, clientOpts *options.ClientOptions) error {
+	client, err := mongo.Connect(ctx, clientOpts)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+	return client.Ping(ctx, nil)
+}
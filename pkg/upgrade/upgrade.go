@@ -396,7 +396,7 @@ func (u *Upgrader) upgradeStandalone(ctx context.Context) error {
 	}
 
 	node := u.config.Topology.Mongod[0]
-	hostPort := fmt.Sprintf("%s:%d", node.Host, node.Port)
+	hostPort := topology.GetNodeID(node.Host, node.Port)
 
 	fmt.Printf("\nUpgrading standalone instance: %s\n", hostPort)
 
@@ -609,13 +609,13 @@ func (u *Upgrader) upgradeNode(ctx context.Context, node interface{}, role strin
 	switch n := node.(type) {
 	case topology.MongodNode:
 		nodeID = fmt.Sprintf("mongod-%d", n.Port)
-		hostPort = fmt.Sprintf("%s:%d", n.Host, n.Port)
+		hostPort = topology.GetNodeID(n.Host, n.Port)
 	case topology.MongosNode:
 		nodeID = fmt.Sprintf("mongos-%d", n.Port)
-		hostPort = fmt.Sprintf("%s:%d", n.Host, n.Port)
+		hostPort = topology.GetNodeID(n.Host, n.Port)
 	case topology.ConfigNode:
 		nodeID = fmt.Sprintf("mongod-%d", n.Port)
-		hostPort = fmt.Sprintf("%s:%d", n.Host, n.Port)
+		hostPort = topology.GetNodeID(n.Host, n.Port)
 	default:
 		return fmt.Errorf("unsupported node type: %T", node)
 	}